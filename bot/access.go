@@ -0,0 +1,310 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// groupAdminTTL is how long a getChatMember answer is trusted, so promoting
+// or demoting a group admin takes effect within a few minutes
+const groupAdminTTL = 5 * time.Minute
+
+// access is what a Telegram user may do with the bot in a chat
+type access struct {
+	All      bool            // Every command and button
+	Commands map[string]bool // Otherwise only these
+}
+
+var (
+	accessNone = access{}
+	accessRead = access{Commands: readOnlyCommands} // Members of group_chat_id
+	accessFull = access{All: true}
+)
+
+// readOnlyCommands are open to every member of group_chat_id: they only show
+// state. Buttons in their replies that change something need the permission
+// of the command doing the same, see callbackCommand.
+var readOnlyCommands = map[string]bool{
+	"start":     true,
+	"help":      true,
+	"id":        true,
+	"perms":     true,
+	"accounts":  true,
+	"listip":    true,
+	"checkip":   true,
+	"compare":   true,
+	"status":    true,
+	"billing":   true,
+	"metrics":   true,
+	"network":   true,
+	"regions":   true,
+	"exportips": true,
+	"capacity":  true,
+	"whoami":    true,
+	"health":    true,
+	"orphans":   true,
+	"protected": true,
+}
+
+// alwaysAllowed are open to anyone with some access
+var alwaysAllowed = []string{"start", "help", "id", "perms"}
+
+// allows reports whether the command may run
+func (a access) allows(command string) bool {
+	return a.All || a.Commands[command]
+}
+
+// none reports whether the user may do nothing at all
+func (a access) none() bool {
+	return !a.All && len(a.Commands) == 0
+}
+
+// writes reports whether the user may run commands that change something, and
+// so answer wizards
+func (a access) writes() bool {
+	if a.All {
+		return true
+	}
+	for command := range a.Commands {
+		if !readOnlyCommands[command] {
+			return true
+		}
+	}
+	return false
+}
+
+// union returns the commands allowed by either a or other
+func (a access) union(other access) access {
+	if a.All || other.All {
+		return accessFull
+	}
+	commands := make(map[string]bool, len(a.Commands)+len(other.Commands))
+	for command := range a.Commands {
+		commands[command] = true
+	}
+	for command := range other.Commands {
+		commands[command] = true
+	}
+	return access{Commands: commands}
+}
+
+// sortedCommands lists the allowed commands, "*" for all
+func (a access) sortedCommands() []string {
+	if a.All {
+		return []string{"*"}
+	}
+	commands := make([]string, 0, len(a.Commands))
+	for command := range a.Commands {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// callbackCommands maps button actions to the command whose permission they
+// need. Buttons not listed need full access.
+var callbackCommands = map[string]string{
+	"use":        "use",
+	"del":        "delip",
+	"sel":        "delip",
+	"orphans":    "delip",
+	"releaseip":  "delip",
+	"leaked":     "delip",
+	"newip":      "newip",
+	"refresh":    "listip",
+	"check":      "checkip",
+	"autoip":     "autoip",
+	"autovps":    "autovps",
+	"backupvps":  "backupvps",
+	"restorevps": "restorevps",
+	"metrics":    "metrics",
+	"network":    "network",
+	"conn":       "network",
+	"ren":        "rename",
+	"mvip":       "pin",
+	"vbk":        "volbackup",
+	"prof":       "profiles",
+	"rotkey":     "rotatekey",
+	"watch":      "watch",
+	"rgn":        "region",
+	"cfg":        "settings",
+	"tune":       "tune",
+	"job":        "jobs",
+}
+
+// callbackCommand returns the command a button press needs permission for,
+// "" when only full access will do
+func callbackCommand(parts []string) string {
+	if parts[0] == "acct" && len(parts) >= 2 {
+		switch parts[1] {
+		case "disable", "enable":
+			return parts[1] + "account"
+		default:
+			return "rmaccount"
+		}
+	}
+	return callbackCommands[parts[0]]
+}
+
+// allowsCallback reports whether the button press may run
+func (a access) allowsCallback(parts []string) bool {
+	if a.All {
+		return true
+	}
+	command := callbackCommand(parts)
+	return command != "" && a.Commands[command]
+}
+
+// userAccess returns the perm_<user_id> commands of user, accessNone when the
+// user has none
+func (b *Bot) userAccess(userID int64) access {
+	commands := b.cfg.Permissions[userID]
+	if len(commands) == 0 {
+		return accessNone
+	}
+	if slices.Contains(commands, "*") {
+		return accessFull
+	}
+	allowed := make(map[string]bool, len(commands)+len(alwaysAllowed))
+	for _, command := range slices.Concat(commands, alwaysAllowed) {
+		allowed[command] = true
+	}
+	return access{Commands: allowed}
+}
+
+// accessFor returns what user may do in chatID: everything for the admin,
+// the perm_<user_id> commands for users listed in the config, and in the
+// shared group everything for its Telegram admins and read-only commands for
+// other members. senderChat is set for messages an anonymous group admin
+// sends on behalf of the group.
+func (b *Bot) accessFor(chatID, userID int64, senderChat *tgbotapi.Chat) access {
+	if userID == b.adminID {
+		return accessFull
+	}
+	granted := b.userAccess(userID)
+	if b.cfg.GroupChatID == 0 || chatID != b.cfg.GroupChatID {
+		return granted
+	}
+	if senderChat != nil && senderChat.ID == chatID {
+		return accessFull
+	}
+	if granted.All || b.isGroupAdmin(chatID, userID) {
+		return accessFull
+	}
+	return granted.union(accessRead)
+}
+
+// isGroupAdmin asks Telegram whether user administers the group, caching the
+// answer for groupAdminTTL. Failed lookups count as not admin.
+func (b *Bot) isGroupAdmin(chatID, userID int64) bool {
+	b.mu.Lock()
+	cached, ok := b.groupAdmins[userID]
+	b.mu.Unlock()
+	if ok && time.Now().Before(cached.Expires) {
+		return cached.Admin
+	}
+
+	resp, err := b.api.Request(tgbotapi.GetChatMemberConfig{ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID}})
+	var member tgbotapi.ChatMember
+	if err == nil {
+		err = json.Unmarshal(resp.Result, &member)
+	}
+	if err != nil {
+		logger.Warnf("Failed to look up group member %d: %v", userID, err)
+		return false
+	}
+	admin := member.IsCreator() || member.IsAdministrator()
+
+	b.mu.Lock()
+	b.groupAdmins[userID] = groupAdmin{Admin: admin, Expires: time.Now().Add(groupAdminTTL)}
+	b.mu.Unlock()
+	return admin
+}
+
+// groupAdmin is a cached getChatMember answer
+type groupAdmin struct {
+	Admin   bool
+	Expires time.Time
+}
+
+// checkPermissions warns about perm_<user_id> entries naming commands the bot
+// doesn't have
+func checkPermissions(permissions map[int64][]string, commands []tgbotapi.BotCommand) {
+	known := make(map[string]bool, len(commands))
+	for _, c := range commands {
+		known[c.Command] = true
+	}
+	for userID, granted := range permissions {
+		for _, command := range granted {
+			if command != "*" && !known[command] && !slices.Contains(alwaysAllowed, command) {
+				logger.Warnf("perm_%d: unknown command %q", userID, command)
+			}
+		}
+	}
+}
+
+// showPerms shows the effective permissions of the caller, or for the admin
+// those of every configured user or of the given user ID
+func (b *Bot) showPerms(msg *tgbotapi.Message, args string) {
+	chatID := msg.Chat.ID
+	if msg.From.ID != b.adminID {
+		a := b.accessFor(chatID, msg.From.ID, msg.SenderChat)
+		b.reply(chatID, "🔑 你在此对话中可以使用:\n"+describeAccess(a))
+		return
+	}
+
+	if args != "" {
+		userID, err := strconv.ParseInt(strings.TrimSpace(args), 10, 64)
+		if err != nil {
+			b.reply(chatID, "用法: /perms [用户ID]")
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("🔑 用户 %d\n\n私聊: %s\n", userID, describeAccess(b.accessFor(userID, userID, nil))))
+		if b.cfg.GroupChatID != 0 {
+			sb.WriteString(fmt.Sprintf("群组: %s\n", describeAccess(b.accessFor(b.cfg.GroupChatID, userID, nil))))
+		}
+		b.reply(chatID, strings.TrimRight(sb.String(), "\n"))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔑 权限\n\n👑 %d (chat_id): 全部\n", b.adminID))
+	if b.cfg.GroupChatID != 0 {
+		sb.WriteString(fmt.Sprintf("👥 群组 %d: 管理员全部，成员只读\n", b.cfg.GroupChatID))
+	}
+	userIDs := make([]int64, 0, len(b.cfg.Permissions))
+	for userID := range b.cfg.Permissions {
+		userIDs = append(userIDs, userID)
+	}
+	slices.Sort(userIDs)
+	for _, userID := range userIDs {
+		sb.WriteString(fmt.Sprintf("👤 %d: %s\n", userID, describeAccess(b.userAccess(userID))))
+	}
+	if len(userIDs) == 0 {
+		sb.WriteString("\n可在配置文件中用 perm_<用户ID>=命令1,命令2 授权其他用户")
+	}
+	b.reply(chatID, strings.TrimRight(sb.String(), "\n"))
+}
+
+// describeAccess lists the commands of a, for /perms
+func describeAccess(a access) string {
+	switch {
+	case a.All:
+		return "全部命令"
+	case a.none():
+		return "无"
+	}
+	commands := a.sortedCommands()
+	for i, command := range commands {
+		commands[i] = "/" + command
+	}
+	return strings.Join(commands, " ")
+}
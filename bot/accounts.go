@@ -0,0 +1,274 @@
+package bot
+
+import (
+	"fmt"
+
+	"oci-bot/config"
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// accountAction is what /rmaccount, /disableaccount and /enableaccount do
+type accountAction struct {
+	Verb string                                  // Shown in prompts and replies
+	pick func(b *Bot) []string                   // Accounts offered without an argument
+	run  func(b *Bot, chatID int64, name string) // Runs after the checks
+}
+
+var accountActions = map[string]accountAction{
+	"rm": {
+		Verb: "删除",
+		pick: (*Bot).configuredAccounts,
+		run:  (*Bot).confirmRemoveAccount,
+	},
+	"disable": {
+		Verb: "停用",
+		pick: func(b *Bot) []string {
+			return b.accountNamesWhere(func(a *config.OCIAccount) bool { return !a.Disabled })
+		},
+		run: (*Bot).disableAccount,
+	},
+	"enable": {
+		Verb: "启用",
+		pick: func(b *Bot) []string {
+			return b.accountNamesWhere(func(a *config.OCIAccount) bool { return a.Disabled })
+		},
+		run: (*Bot).enableAccount,
+	},
+}
+
+// configuredAccounts returns every account in the config, disabled ones too
+func (b *Bot) configuredAccounts() []string {
+	return b.accountNamesWhere(func(*config.OCIAccount) bool { return true })
+}
+
+// accountNamesWhere returns the configured accounts matching keep, in config order
+func (b *Bot) accountNamesWhere(keep func(a *config.OCIAccount) bool) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var names []string
+	for i := range b.cfg.Accounts {
+		if keep(&b.cfg.Accounts[i]) {
+			names = append(names, b.cfg.Accounts[i].Name)
+		}
+	}
+	return names
+}
+
+// handleAccountCommand runs an account action, or lets the admin pick the
+// account when none is given
+func (b *Bot) handleAccountCommand(chatID int64, action, name string) {
+	a := accountActions[action]
+	if name == "" {
+		names := a.pick(b)
+		if len(names) == 0 {
+			b.reply(chatID, fmt.Sprintf("⚠️ 没有可%s的账号", a.Verb))
+			return
+		}
+		var buttons [][]tgbotapi.InlineKeyboardButton
+		for _, n := range names {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(n, "acct:"+action+":"+n)))
+		}
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("选择要%s的账号:", a.Verb))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+		b.send(msg)
+		return
+	}
+
+	if b.accountConfig(name) == nil {
+		b.reply(chatID, "❌ 账号不存在: "+name)
+		return
+	}
+	if action != "enable" {
+		if reason := b.accountBusy(name); reason != "" {
+			b.reply(chatID, fmt.Sprintf("⚠️ [%s] %s，请先停止后再%s", name, reason, a.Verb))
+			return
+		}
+	}
+	a.run(b, chatID, name)
+}
+
+// handleAccountCallback handles acct:<rm|disable|enable|rmgo|cancel>:<account>
+func (b *Bot) handleAccountCallback(chatID int64, action string, parts []string) {
+	name := ""
+	if len(parts) >= 3 {
+		name = parts[2]
+	}
+	switch action {
+	case "rm", "disable", "enable":
+		b.handleAccountCommand(chatID, action, name)
+	case "rmgo":
+		if reason := b.accountBusy(name); reason != "" {
+			b.reply(chatID, fmt.Sprintf("⚠️ [%s] %s，请先停止后再删除", name, reason))
+			return
+		}
+		b.removeAccount(chatID, name)
+	case "cancel":
+		b.reply(chatID, "❌ 已取消")
+	}
+}
+
+// accountBusy describes the task running on the account or left unfinished
+// on it, "" when there is none
+func (b *Bot) accountBusy(name string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.autoApply != nil && b.autoApply.Active && b.autoApply.AccountName == name {
+		return "正在自动刷IP"
+	}
+	if b.autoVPS != nil && b.autoVPS.Active && b.autoVPS.AccountName == name {
+		return "正在自动申请VPS"
+	}
+	// Halted jobs count too: resuming them would need the account
+	for _, job := range b.jobs.Jobs() {
+		if jobAccount(job) == name {
+			return fmt.Sprintf("有未完成的%s任务 (/jobs)", job.Title())
+		}
+	}
+	return ""
+}
+
+// dropClientLocked removes the account's service and moves the current
+// account elsewhere if needed. Refuses to drop the last account. Caller must
+// hold b.mu.
+func (b *Bot) dropClientLocked(name string) error {
+	if _, ok := b.clients[name]; !ok {
+		return nil
+	}
+	if len(b.clients) == 1 {
+		return fmt.Errorf("[%s] 是最后一个启用的账号", name)
+	}
+	delete(b.clients, name)
+	if b.currentClient.AccountName() == name {
+		for _, acc := range b.cfg.Accounts {
+			if client, ok := b.clients[acc.Name]; ok {
+				b.currentClient = client
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// confirmRemoveAccount asks before an account is deleted from the config
+func (b *Bot) confirmRemoveAccount(chatID int64, name string) {
+	text := fmt.Sprintf("🗑 *删除账号* [%s]\n\n将从配置文件中删除该账号的整个配置段，私钥文件保留在本地。OCI 中的资源不受影响。", escapeMarkdown(name))
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🗑 确认删除", "acct:rmgo:"+name)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "acct:cancel:")),
+	)
+	b.send(msg)
+}
+
+// removeAccount deletes the account from the config and the running bot
+func (b *Bot) removeAccount(chatID int64, name string) {
+	b.reply(chatID, b.removeAccountFromConfig(name))
+}
+
+// disableAccount marks the account disabled in the config and unloads it
+func (b *Bot) disableAccount(chatID int64, name string) {
+	b.reply(chatID, b.disableAccountInConfig(name))
+}
+
+// removeAccountFromConfig does the work of removeAccount and returns the
+// reply. The config file is rewritten under b.mu so the running accounts and
+// the file can't disagree.
+func (b *Bot) removeAccountFromConfig(name string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var remaining []config.OCIAccount
+	var keyFile string
+	for _, acc := range b.cfg.Accounts {
+		if acc.Name == name {
+			keyFile = acc.KeyFile
+		} else {
+			remaining = append(remaining, acc)
+		}
+	}
+	if len(remaining) == len(b.cfg.Accounts) {
+		return "❌ 账号不存在: " + name
+	}
+
+	current, client, loaded := b.currentClient, b.clients[name], b.clients[name] != nil
+	if err := b.dropClientLocked(name); err != nil {
+		return "❌ 无法删除: " + err.Error()
+	}
+	if err := config.RemoveAccount(b.cfg.File, name); err != nil {
+		if loaded {
+			b.clients[name] = client
+		}
+		b.currentClient = current
+		return "❌ 更新配置文件失败: " + err.Error()
+	}
+	// A new slice, tasks started at launch keep pointers into the old one
+	b.cfg.Accounts = remaining
+	logger.Infof("Removed OCI account [%s]", name)
+
+	return fmt.Sprintf("✅ 账号 [%s] 已删除\n私钥文件保留在: %s\n当前账号: [%s]", name, keyFile, b.currentClient.AccountName())
+}
+
+// disableAccountInConfig does the work of disableAccount and returns the
+// reply, see removeAccountFromConfig
+func (b *Bot) disableAccountInConfig(name string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	account := b.accountConfigLocked(name)
+	if account == nil || account.Disabled {
+		return "⚠️ 账号已停用: " + name
+	}
+	current, client, loaded := b.currentClient, b.clients[name], b.clients[name] != nil
+	if err := b.dropClientLocked(name); err != nil {
+		return "❌ 无法停用: " + err.Error()
+	}
+	if err := config.SetAccountValues(b.cfg.File, name, map[string]string{"disabled": "true"}); err != nil {
+		if loaded {
+			b.clients[name] = client
+		}
+		b.currentClient = current
+		return "❌ 更新配置文件失败: " + err.Error()
+	}
+	account.Disabled = true
+	logger.Infof("Disabled OCI account [%s]", name)
+
+	return fmt.Sprintf("⏸ 账号 [%s] 已停用，重启后仍保持停用\n使用 /enableaccount %s 重新启用\n当前账号: [%s]", name, name, b.currentClient.AccountName())
+}
+
+// enableAccount clears the disabled flag and loads the account again
+func (b *Bot) enableAccount(chatID int64, name string) {
+	b.mu.Lock()
+	account := b.accountConfigLocked(name)
+	var enabled config.OCIAccount
+	if account != nil {
+		enabled = *account
+	}
+	b.mu.Unlock()
+	if account == nil || !enabled.Disabled {
+		b.reply(chatID, "⚠️ 账号未停用: "+name)
+		return
+	}
+
+	client, err := oci.NewClient(&enabled)
+	if err != nil {
+		b.reply(chatID, "❌ 无法加载账号: "+err.Error())
+		return
+	}
+
+	b.mu.Lock()
+	err = config.SetAccountValues(b.cfg.File, name, map[string]string{"disabled": ""})
+	if err == nil {
+		account.Disabled = false
+		b.clients[name] = client
+	}
+	b.mu.Unlock()
+	if err != nil {
+		b.reply(chatID, "❌ 更新配置文件失败: "+err.Error())
+		return
+	}
+	logger.Infof("Enabled OCI account [%s]", name)
+	b.reply(chatID, fmt.Sprintf("▶️ 账号 [%s] 已启用\n保号、定时备份等后台任务重启后生效", name))
+}
@@ -0,0 +1,41 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"oci-bot/oci/ocifake"
+)
+
+func TestAccountBusyWithHaltedJob(t *testing.T) {
+	b, api := newTestBot(t, ocifake.New("main", "ap-tokyo-1"), ocifake.New("backup", "us-ashburn-1"))
+
+	// A deployment that failed on main before the bot restarted
+	halted := []map[string]any{{
+		"id": jobDeploy, "kind": jobDeploy, "label": "main", "chat_id": testAdmin, "step": "bind",
+		"started": time.Now(), "halted": true, "error": "timeout",
+		"data": map[string]any{"account": "main", "instance_id": "ocid1.instance.fake.1"},
+	}}
+	if err := b.store.Set(jobsKey, halted); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.jobs.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if reason := b.accountBusy("main"); !strings.Contains(reason, "一键部署") {
+		t.Errorf("accountBusy(main) = %q, want the deployment", reason)
+	}
+	if reason := b.accountBusy("backup"); reason != "" {
+		t.Errorf("accountBusy(backup) = %q, want none", reason)
+	}
+
+	b.handleAccountCallback(testAdmin, "rmgo", []string{"acct", "rmgo", "main"})
+	if !strings.Contains(lastText(api), "请先停止后再删除") {
+		t.Errorf("remove: got %q", lastText(api))
+	}
+	if b.accountConfig("main") == nil {
+		t.Error("main was removed")
+	}
+}
@@ -0,0 +1,331 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"oci-bot/config"
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxKeyFileSize bounds the private key download, PEM keys are a few KB
+const maxKeyFileSize = 16 << 10
+
+// /addaccount wizard steps
+const (
+	accountStepName = iota
+	accountStepTenancy
+	accountStepUser
+	accountStepFingerprint
+	accountStepRegion
+	accountStepKey
+	accountStepPassphrase // Only for encrypted keys
+)
+
+var (
+	accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+	fingerprintPattern = regexp.MustCompile(`^([0-9a-f]{2}:){15}[0-9a-f]{2}$`)
+	regionPattern      = regexp.MustCompile(`^[a-z]{2,}-[a-z]+-[0-9]+$`)
+	// ocid1.<type>.<realm>.[region][.future use].<unique ID>
+	tenancyOCIDPattern = regexp.MustCompile(`^ocid1\.tenancy\.oc[0-9]+\.[a-z0-9-]*(\.[a-z0-9-]+)?\.[a-z0-9]+$`)
+	userOCIDPattern    = regexp.MustCompile(`^ocid1\.user\.oc[0-9]+\.[a-z0-9-]*(\.[a-z0-9-]+)?\.[a-z0-9]+$`)
+)
+
+// accountWizard collects the credentials of a new account
+type accountWizard struct {
+	Step    int
+	Account config.OCIAccount
+	Key     []byte // Private key, kept until the passphrase arrives
+	Expires time.Time
+}
+
+// accountWizardLocked is autoWizardLocked for the /addaccount wizard.
+func (b *Bot) accountWizardLocked(chatID int64) *accountWizard {
+	wizard := b.accountWizards[chatID]
+	if wizard == nil {
+		return nil
+	}
+	if time.Now().After(wizard.Expires) {
+		delete(b.accountWizards, chatID)
+		return nil
+	}
+	wizard.Expires = time.Now().Add(b.wizardTimeout())
+	return wizard
+}
+
+// startAddAccount starts the /addaccount wizard
+func (b *Bot) startAddAccount(chatID int64) {
+	if chatID == b.cfg.GroupChatID {
+		b.reply(chatID, "🔒 私钥不应发到群组中，请在私聊中使用 /addaccount")
+		return
+	}
+	b.mu.Lock()
+	b.accountWizards[chatID] = &accountWizard{Expires: time.Now().Add(b.wizardTimeout())}
+	b.mu.Unlock()
+
+	b.replyMarkdown(chatID, "➕ *添加账号* (1/6)\n\n请输入账号名称（字母、数字、`-`、`_`、`.`），如 `tokyo`\n/cancel 取消")
+}
+
+// handleAccountInput takes the next answer of a running /addaccount wizard.
+// Returns false when the chat has no such wizard.
+func (b *Bot) handleAccountInput(msg *tgbotapi.Message) bool {
+	chatID := msg.Chat.ID
+	b.mu.Lock()
+	wizard := b.accountWizardLocked(chatID)
+	if wizard == nil {
+		b.mu.Unlock()
+		return false
+	}
+	step := wizard.Step
+	b.mu.Unlock()
+
+	text := strings.TrimSpace(msg.Text)
+	switch step {
+	case accountStepName:
+		if !accountNamePattern.MatchString(text) {
+			b.reply(chatID, "❌ 名称只能包含字母、数字、-、_、.，最长 32 个字符，请重新输入")
+			return true
+		}
+		if b.accountConfig(text) != nil {
+			b.reply(chatID, "❌ 账号已存在: "+text+"\n请输入其他名称")
+			return true
+		}
+		b.nextAccountStep(chatID, func(a *config.OCIAccount) { a.Name = text },
+			"➕ *添加账号* (2/6)\n\n请输入租户 OCID (`ocid1.tenancy.oc1..`)")
+
+	case accountStepTenancy:
+		if !tenancyOCIDPattern.MatchString(text) {
+			b.reply(chatID, "❌ 租户 OCID 格式不正确，应形如 ocid1.tenancy.oc1..aaaa…，请重新输入")
+			return true
+		}
+		b.nextAccountStep(chatID, func(a *config.OCIAccount) { a.Tenancy = text },
+			"➕ *添加账号* (3/6)\n\n请输入用户 OCID (`ocid1.user.oc1..`)")
+
+	case accountStepUser:
+		if !userOCIDPattern.MatchString(text) {
+			b.reply(chatID, "❌ 用户 OCID 格式不正确，应形如 ocid1.user.oc1..aaaa…，请重新输入")
+			return true
+		}
+		b.nextAccountStep(chatID, func(a *config.OCIAccount) { a.User = text },
+			"➕ *添加账号* (4/6)\n\n请输入 API 密钥指纹，如 `aa:bb:cc:...`")
+
+	case accountStepFingerprint:
+		text = strings.ToLower(text)
+		if !fingerprintPattern.MatchString(text) {
+			b.reply(chatID, "❌ 指纹应为 16 组以冒号分隔的十六进制数，请重新输入")
+			return true
+		}
+		b.nextAccountStep(chatID, func(a *config.OCIAccount) { a.Fingerprint = text },
+			"➕ *添加账号* (5/6)\n\n请输入区域，如 `ap-tokyo-1`")
+
+	case accountStepRegion:
+		text = strings.ToLower(text)
+		if !regionPattern.MatchString(text) {
+			b.reply(chatID, "❌ 区域格式不正确，如 ap-tokyo-1，请重新输入")
+			return true
+		}
+		b.nextAccountStep(chatID, func(a *config.OCIAccount) { a.Region = text },
+			"➕ *添加账号* (6/6)\n\n请以文件形式发送 API 私钥 (`.pem`)，也可以直接粘贴私钥内容。收到后会删除该消息")
+
+	case accountStepKey:
+		b.handleAccountKey(chatID, msg)
+
+	case accountStepPassphrase:
+		// The passphrase should not stay in the chat history
+		b.api.Request(tgbotapi.NewDeleteMessage(chatID, msg.MessageID))
+		// It is written to the conf file as one key=value line
+		if strings.ContainsAny(msg.Text, "\r\n") {
+			b.reply(chatID, "❌ 密码不能包含换行，请重新输入")
+			return true
+		}
+		b.mu.Lock()
+		wizard.Account.KeyPassphrase = msg.Text
+		b.mu.Unlock()
+		b.finishAddAccount(chatID, wizard)
+	}
+	return true
+}
+
+// nextAccountStep stores an answer and asks the next question
+func (b *Bot) nextAccountStep(chatID int64, set func(a *config.OCIAccount), prompt string) {
+	b.mu.Lock()
+	wizard := b.accountWizardLocked(chatID)
+	if wizard == nil {
+		b.mu.Unlock()
+		return
+	}
+	set(&wizard.Account)
+	wizard.Step++
+	b.mu.Unlock()
+
+	b.replyMarkdown(chatID, prompt)
+}
+
+// handleAccountKey takes the private key as a document or pasted PEM text and
+// removes the message holding it
+func (b *Bot) handleAccountKey(chatID int64, msg *tgbotapi.Message) {
+	var key []byte
+	switch {
+	case msg.Document != nil:
+		ctx, cancel := b.withTimeout(callTimeout)
+		defer cancel()
+		var err error
+		if key, err = b.downloadDocument(ctx, msg.Document, maxKeyFileSize); err != nil {
+			b.reply(chatID, "❌ 下载私钥失败: "+err.Error()+"\n请重新发送")
+			return
+		}
+	case strings.HasPrefix(strings.TrimSpace(msg.Text), "-----BEGIN"):
+		key = []byte(strings.TrimSpace(msg.Text) + "\n")
+	default:
+		b.reply(chatID, "❌ 请发送私钥文件 (.pem) 或粘贴私钥内容")
+		return
+	}
+	b.api.Request(tgbotapi.NewDeleteMessage(chatID, msg.MessageID))
+
+	b.mu.Lock()
+	wizard := b.accountWizardLocked(chatID)
+	if wizard == nil {
+		b.mu.Unlock()
+		return
+	}
+	wizard.Key = key
+	encrypted := oci.KeyEncrypted(key)
+	if encrypted {
+		wizard.Step = accountStepPassphrase
+	}
+	b.mu.Unlock()
+
+	if encrypted {
+		b.reply(chatID, "🔐 私钥已加密，请输入密码（收到后会删除该消息）")
+		return
+	}
+	b.finishAddAccount(chatID, wizard)
+}
+
+// downloadDocument fetches a file sent to the bot, refusing files over limit
+// bytes
+func (b *Bot) downloadDocument(ctx context.Context, doc *tgbotapi.Document, limit int) ([]byte, error) {
+	if doc.FileSize > limit {
+		return nil, fmt.Errorf("file too large (%d bytes)", doc.FileSize)
+	}
+	if d, ok := b.api.(fileDownloader); ok {
+		return d.DownloadFile(ctx, doc.FileID, limit)
+	}
+
+	resp, err := b.api.Request(tgbotapi.FileConfig{FileID: doc.FileID})
+	if err != nil {
+		return nil, err
+	}
+	var file tgbotapi.File
+	if err := json.Unmarshal(resp.Result, &file); err != nil {
+		return nil, err
+	}
+
+	// A Bot API server running with --local hands out paths on its own disk
+	if filepath.IsAbs(file.FilePath) {
+		data, err := os.ReadFile(file.FilePath)
+		if err == nil && len(data) > limit {
+			return nil, fmt.Errorf("file too large")
+		}
+		return data, err
+	}
+
+	client, err := telegramHTTPClient(b.cfg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileLink(b.cfg, file), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, withoutURL(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", res.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, fmt.Errorf("file too large")
+	}
+	return data, nil
+}
+
+// finishAddAccount saves the key next to the conf file, checks the
+// credentials with a test call and on success adds the account to the conf
+// file and the running bot
+func (b *Bot) finishAddAccount(chatID int64, wizard *accountWizard) {
+	b.mu.Lock()
+	delete(b.accountWizards, chatID)
+	account := wizard.Account
+	key := wizard.Key
+	b.mu.Unlock()
+
+	account.KeyFile = filepath.Join(filepath.Dir(b.cfg.File), account.Name+"-api-key.pem")
+	if err := account.Validate(); err != nil {
+		b.reply(chatID, "❌ 账号配置错误: "+err.Error())
+		return
+	}
+	sealed, err := config.SealSecret(key)
+	var keyFile *os.File
+	if err == nil {
+		keyFile, err = os.OpenFile(account.KeyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	}
+	if err == nil {
+		_, err = keyFile.Write(sealed)
+		if closeErr := keyFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(account.KeyFile)
+		}
+	}
+	if err != nil {
+		b.reply(chatID, "❌ 保存私钥失败: "+err.Error())
+		return
+	}
+
+	b.reply(chatID, fmt.Sprintf("🔍 [%s] 验证凭据...", account.Name))
+	client, err := oci.NewClient(&account)
+	var tenancy *oci.TenancyInfo
+	if err == nil {
+		ctx, cancel := b.withTimeout(callTimeout)
+		tenancy, err = client.GetTenancyInfo(ctx)
+		cancel()
+	}
+	if err != nil {
+		os.Remove(account.KeyFile)
+		logger.Warnf("[%s] New account check failed: %v", account.Name, err)
+		b.reply(chatID, "❌ 凭据验证失败，账号未添加: "+describeError(err)+"\n请检查后重新使用 /addaccount")
+		return
+	}
+
+	if err := config.AppendAccount(b.cfg.File, &account); err != nil {
+		os.Remove(account.KeyFile)
+		b.reply(chatID, "❌ 更新配置文件失败: "+err.Error())
+		return
+	}
+	b.mu.Lock()
+	b.cfg.Accounts = append(b.cfg.Accounts, account)
+	b.clients[account.Name] = client
+	b.mu.Unlock()
+	logger.Infof("Added OCI account [%s] (%s)", account.Name, account.Region)
+
+	b.replyMarkdown(chatID, fmt.Sprintf("✅ *账号已添加* [%s]\n\n🏢 租户: %s\n🏠 主区域: %s\n📍 区域: %s\n🔑 私钥: `%s`\n\n使用 /accounts 切换到该账号。VPS、保号、定时任务等设置请在配置文件中补充，重启后生效",
+		escapeMarkdown(account.Name), escapeMarkdown(tenancy.Name), tenancy.HomeRegion, account.Region, account.KeyFile))
+}
@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// postAlertWebhook posts an alert as JSON to alert_webhook_url, if set.
+// Failures are only logged; the Telegram alert is sent regardless.
+func (b *Bot) postAlertWebhook(ctx context.Context, event any) {
+	if b.cfg.AlertWebhookURL == "" {
+		return
+	}
+	if err := postJSON(ctx, b.cfg.AlertWebhookURL, event); err != nil {
+		logger.Warnf("Alert webhook failed: %v", err)
+	}
+}
+
+// postJSON posts v as JSON and expects a 2xx answer
+func postJSON(ctx context.Context, url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// approval is a destructive button press in the shared group waiting for a
+// second admin
+type approval struct {
+	Parts       []string // Callback data of the pressed button
+	What        string
+	ChatID      int64
+	MessageID   int // Message with the pressed button
+	RequestedBy int64
+	Requester   string
+	Expires     time.Time
+	// The IPs a bulk delete removes, fixed when it is requested: the
+	// selection or auto-apply config may change before it is approved
+	Account string
+	IPs     []oci.PublicIPInfo
+}
+
+// destructiveAction describes the button press when it deletes or releases
+// resources in bulk, "" for everything else
+func destructiveAction(parts []string) string {
+	param := ""
+	if len(parts) >= 2 {
+		param = parts[1]
+	}
+	switch {
+	case parts[0] == "autoip" && param == "delall":
+		return "删除所有IP后开始自动刷IP"
+	case parts[0] == "sel" && param == "do":
+		return "删除所选IP"
+	case parts[0] == "orphans":
+		return "释放未绑定的预留IP"
+	case parts[0] == "releaseip":
+		return fmt.Sprintf("释放 [%s] 全部未绑定IP", param)
+	case parts[0] == "leaked" && param == "del" && len(parts) >= 3:
+		return fmt.Sprintf("释放 [%s] 泄漏的IP", parts[2])
+	case parts[0] == "acct" && param == "rmgo" && len(parts) >= 3:
+		return fmt.Sprintf("删除账号 [%s]", parts[2])
+	}
+	return ""
+}
+
+// userLabel names a Telegram user in messages
+func userLabel(u *tgbotapi.User) string {
+	if u.UserName != "" {
+		return "@" + u.UserName
+	}
+	if name := strings.TrimSpace(u.FirstName + " " + u.LastName); name != "" {
+		return name
+	}
+	return fmt.Sprintf("%d", u.ID)
+}
+
+// approvalTimeout is how long a destructive action waits for a second admin
+func (b *Bot) approvalTimeout() time.Duration {
+	return time.Duration(b.cfg.ApprovalTimeoutMinutes) * time.Minute
+}
+
+// requestApproval holds a destructive button press in the shared group until
+// another admin approves it
+func (b *Bot) requestApproval(cb *tgbotapi.CallbackQuery, what string) {
+	b.api.Request(tgbotapi.NewCallback(cb.ID, "需要另一位管理员批准"))
+
+	parts := strings.Split(cb.Data, ":")
+	account, ips, ok := b.approvalTargets(cb.Message.Chat.ID, parts)
+	if !ok {
+		return
+	}
+
+	var raw [6]byte
+	rand.Read(raw[:])
+	id := hex.EncodeToString(raw[:])
+	timeout := b.approvalTimeout()
+	pending := &approval{
+		Parts:       parts,
+		What:        what,
+		ChatID:      cb.Message.Chat.ID,
+		MessageID:   cb.Message.MessageID,
+		RequestedBy: cb.From.ID,
+		Requester:   userLabel(cb.From),
+		Expires:     time.Now().Add(timeout),
+		Account:     account,
+		IPs:         ips,
+	}
+
+	text := fmt.Sprintf("🔐 %s 请求: %s\n", pending.Requester, what)
+	if account != "" {
+		text += fmt.Sprintf("\n[%s] 将删除 %d 个IP:\n", account, len(ips))
+		for _, ip := range ips {
+			text += "• " + ip.IPAddress + "\n"
+		}
+	}
+	text += fmt.Sprintf("\n需要另一位管理员在 %d 分钟内批准", int(timeout.Minutes()))
+	msg := tgbotapi.NewMessage(pending.ChatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ 批准", "apv:ok:"+id),
+		tgbotapi.NewInlineKeyboardButtonData("❌ 拒绝", "apv:no:"+id),
+	))
+	sent, err := b.send(msg)
+	if err != nil {
+		logger.Errorf("Failed to send approval request: %v", err)
+		return
+	}
+
+	b.mu.Lock()
+	b.approvals[id] = pending
+	b.mu.Unlock()
+	logger.Infof("Approval %s requested by %d: %s", id, cb.From.ID, what)
+
+	time.AfterFunc(timeout, func() {
+		b.mu.Lock()
+		_, open := b.approvals[id]
+		delete(b.approvals, id)
+		b.mu.Unlock()
+		if open {
+			b.edit(pending.ChatID, tgbotapi.NewEditMessageText(pending.ChatID, sent.MessageID, fmt.Sprintf("⌛ %s 请求的「%s」未获批准，已过期", pending.Requester, what)))
+		}
+	})
+}
+
+// handleApprovalCallback handles apv:<ok|no>:<id>. Approving takes a user
+// other than the requester who may press the held button; any such user, the
+// requester included, may reject.
+func (b *Bot) handleApprovalCallback(cb *tgbotapi.CallbackQuery, allowed access, parts []string) {
+	if len(parts) < 3 {
+		return
+	}
+	id := parts[2]
+
+	b.mu.Lock()
+	pending := b.approvals[id]
+	if pending != nil && time.Now().After(pending.Expires) {
+		delete(b.approvals, id)
+		pending = nil
+	}
+	if pending == nil {
+		b.mu.Unlock()
+		b.api.Request(tgbotapi.NewCallback(cb.ID, "请求已失效"))
+		return
+	}
+	if !allowed.allowsCallback(pending.Parts) {
+		b.mu.Unlock()
+		b.api.Request(tgbotapi.NewCallback(cb.ID, "⛔ 没有权限"))
+		return
+	}
+	if parts[1] == "ok" && cb.From.ID == pending.RequestedBy {
+		b.mu.Unlock()
+		b.api.Request(tgbotapi.NewCallback(cb.ID, "需要另一位管理员批准"))
+		return
+	}
+	delete(b.approvals, id)
+	b.mu.Unlock()
+
+	b.api.Request(tgbotapi.NewCallback(cb.ID, ""))
+	by := userLabel(cb.From)
+	if parts[1] != "ok" {
+		logger.Infof("Approval %s rejected by %d", id, cb.From.ID)
+		b.edit(cb.Message.Chat.ID, tgbotapi.NewEditMessageText(cb.Message.Chat.ID, cb.Message.MessageID, fmt.Sprintf("❌ %s 请求的「%s」已被 %s 拒绝", pending.Requester, pending.What, by)))
+		return
+	}
+
+	logger.Infof("Approval %s approved by %d: %s", id, cb.From.ID, pending.What)
+	b.edit(cb.Message.Chat.ID, tgbotapi.NewEditMessageText(cb.Message.Chat.ID, cb.Message.MessageID, fmt.Sprintf("✅ %s 请求的「%s」已由 %s 批准", pending.Requester, pending.What, by)))
+	b.runApproved(pending)
+}
+
+// approvalTargets fixes the IPs of a bulk delete when it is requested, so
+// the approver approves the set listed to them. Other actions have no
+// targets. ok is false, with the reason told, when there is nothing to
+// request.
+func (b *Bot) approvalTargets(chatID int64, parts []string) (account string, ips []oci.PublicIPInfo, ok bool) {
+	switch parts[0] {
+	case "sel":
+		b.mu.Lock()
+		sel := b.selections[chatID]
+		var snapshot ipSelection
+		if sel != nil {
+			snapshot = ipSelection{AccountName: sel.AccountName, IPs: sel.IPs, Selected: maps.Clone(sel.Selected)}
+		}
+		b.mu.Unlock()
+		if sel == nil {
+			b.reply(chatID, "⚠️ 选择已失效，请重新使用 /listip")
+			return "", nil, false
+		}
+		if snapshot.count() == 0 {
+			b.reply(chatID, "⚠️ 未选择任何IP")
+			return "", nil, false
+		}
+		client, found := b.client(snapshot.AccountName)
+		if !found {
+			b.reply(chatID, "❌ 账号不存在: "+snapshot.AccountName)
+			return "", nil, false
+		}
+		selected, _, err := b.selectedIPs(client, &snapshot)
+		if err != nil {
+			b.reply(chatID, errorText(err))
+			return "", nil, false
+		}
+		if len(selected) == 0 {
+			b.reply(chatID, "⚠️ 所选IP已不存在")
+			return "", nil, false
+		}
+		return snapshot.AccountName, selected, true
+
+	case "autoip":
+		b.mu.Lock()
+		config := b.autoApply
+		b.mu.Unlock()
+		if config == nil {
+			b.reply(chatID, "⚠️ 配置已失效，请重新使用 /autoip")
+			return "", nil, false
+		}
+		deletable, _, err := b.deletableIPs(config.AccountName)
+		if err != nil {
+			b.reply(chatID, "❌ 获取IP列表失败: "+describeError(err))
+			return "", nil, false
+		}
+		return config.AccountName, deletable, true
+	}
+	return "", nil, true
+}
+
+// runApproved runs an approved action. Bulk deletes remove exactly the IPs
+// fixed in the request.
+func (b *Bot) runApproved(pending *approval) {
+	switch pending.Parts[0] {
+	case "sel":
+		b.mu.Lock()
+		delete(b.selections, pending.ChatID)
+		b.mu.Unlock()
+		b.edit(pending.ChatID, tgbotapi.NewEditMessageText(pending.ChatID, pending.MessageID, fmt.Sprintf("🗑 [%s] 删除 %d 个IP", pending.Account, len(pending.IPs))))
+		b.deleteIPs(pending.ChatID, pending.Account, pending.IPs, nil)
+	case "autoip":
+		b.mu.Lock()
+		config := b.autoApply
+		b.mu.Unlock()
+		if config == nil || config.AccountName != pending.Account {
+			b.reply(pending.ChatID, "⚠️ 自动刷IP配置已变更，请重新使用 /autoip")
+			return
+		}
+		b.deleteIPsAndStart(pending.ChatID, config, pending.IPs)
+	default:
+		b.dispatchCallback(pending.ChatID, pending.RequestedBy, pending.MessageID, pending.Parts)
+	}
+}
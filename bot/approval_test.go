@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"oci-bot/oci"
+	"oci-bot/oci/ocifake"
+)
+
+func TestApprovalDeletesRequestedIPs(t *testing.T) {
+	client := ocifake.New("main", "ap-tokyo-1")
+	client.AddReservedIP(oci.PublicIPInfo{ID: "ocid1.publicip.a", IPAddress: "192.0.2.1"})
+	client.AddReservedIP(oci.PublicIPInfo{ID: "ocid1.publicip.b", IPAddress: "192.0.2.2"})
+	b, api := newTestBot(t, client)
+
+	const group, requester, approver = -100, 7, 8
+	b.cfg.GroupChatID = group
+	b.limiter.chats[group] = newTokenBucket(1000, 1000)
+	b.groupAdmins[requester] = groupAdmin{Admin: true, Expires: time.Now().Add(time.Hour)}
+	b.groupAdmins[approver] = groupAdmin{Admin: true, Expires: time.Now().Add(time.Hour)}
+	click := func(from int64, data string) {
+		b.handleCallback(&tgbotapi.CallbackQuery{
+			ID:      data,
+			From:    &tgbotapi.User{ID: from},
+			Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: group}},
+			Data:    data,
+		})
+	}
+
+	b.startIPSelection(group)
+	click(requester, "sel:toggle:0")
+	click(requester, "sel:do")
+	if text := lastText(api); !strings.Contains(text, "192.0.2.1") || strings.Contains(text, "192.0.2.2") {
+		t.Fatalf("approval request %q doesn't list just the selected IP", text)
+	}
+
+	// Toggling after the request doesn't change what gets approved
+	click(requester, "sel:toggle:1")
+	b.mu.Lock()
+	ids := slices.Collect(maps.Keys(b.approvals))
+	b.mu.Unlock()
+	if len(ids) != 1 {
+		t.Fatalf("%d approvals pending, want 1", len(ids))
+	}
+	click(approver, "apv:ok:"+ids[0])
+
+	if got := reservedIPs(t, client); !slices.Equal(got, []string{"192.0.2.2"}) {
+		t.Errorf("left %v, want 192.0.2.2", got)
+	}
+}
@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"oci-bot/ippure"
+)
+
+// IP archive: every IP auto-apply checked, matched or released, as a CSV row
+// in ip_archive. Unlike released_ips it is never pruned and keeps no
+// addresses, only their prefix, so /exportips can be shared to find out which
+// OCI ranges tend to be clean.
+var archiveHeader = []string{"timestamp", "region", "prefix", "score", "purity", "ip_type", "native", "matched"}
+
+// archiveMu serializes appends to ip_archive
+var archiveMu sync.Mutex
+
+// archiveIP appends the check result of an auto-apply IP to ip_archive
+func (b *Bot) archiveIP(region string, info *ippure.IPInfo, score ipScore, matched bool) {
+	row := []string{
+		time.Now().UTC().Format(time.RFC3339),
+		region,
+		ipPrefix(info.IPAddress),
+		strconv.Itoa(score.Value),
+		"", "", "", // Checked without a browser
+		strconv.FormatBool(matched),
+	}
+	if info.Available() {
+		row[4], row[5], row[6] = strconv.Itoa(purityValue(info)), archiveIPType(info), archiveNative(info)
+	}
+	if err := appendArchive(b.cfg.IPArchive, row); err != nil {
+		logger.Errorf("Failed to write ip_archive: %v", err)
+	}
+}
+
+// appendArchive appends row to the CSV file at path, starting it with the
+// header when it is new
+func appendArchive(path string, row []string) error {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		w.Write(archiveHeader)
+	}
+	w.Write(row)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ipPrefix returns the /24 (IPv4) or /48 (IPv6) network of ip
+func ipPrefix(ip string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+	if v4 := addr.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: addr.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// archiveIPType names the IP type as in autoip_type
+func archiveIPType(info *ippure.IPInfo) string {
+	switch info.IPType {
+	case ippure.TypeDatacenter:
+		return "datacenter"
+	case ippure.TypeResidential:
+		return "residential"
+	}
+	return info.IPType
+}
+
+// archiveNative names the origin as in autoip_native
+func archiveNative(info *ippure.IPInfo) string {
+	switch info.IsNative {
+	case ippure.OriginNative:
+		return "native"
+	case ippure.OriginNonNative:
+		return "non-native"
+	}
+	return info.IsNative
+}
+
+// exportArchive sends ip_archive as a CSV file, only the rows of the last
+// args days when given
+func (b *Bot) exportArchive(chatID int64, args string) {
+	var since time.Time
+	if args = strings.TrimSpace(args); args != "" {
+		days, err := strconv.Atoi(args)
+		if err != nil || days <= 0 {
+			b.reply(chatID, "用法: /exportips [天数]")
+			return
+		}
+		since = time.Now().AddDate(0, 0, -days)
+	}
+
+	data, rows, err := readArchive(b.cfg.IPArchive, since)
+	switch {
+	case errors.Is(err, os.ErrNotExist) || err == nil && rows == 0:
+		b.reply(chatID, "📦 暂无记录\n/autoip 检测过的IP会记录在 ip_archive 中")
+		return
+	case err != nil:
+		logger.Errorf("Failed to read ip_archive: %v", err)
+		b.reply(chatID, "❌ 读取记录失败: "+err.Error())
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  "oci-ip-archive-" + time.Now().Format("20060102") + ".csv",
+		Bytes: data,
+	})
+	doc.Caption = fmt.Sprintf("📦 %d 条IP检测记录 (只含地址段，不含完整IP)", rows)
+	if _, err := b.sendFile(chatID, doc); err != nil {
+		logger.Errorf("Failed to send ip_archive: %v", err)
+		b.reply(chatID, "❌ 发送失败: "+err.Error())
+	}
+}
+
+// readArchive returns the CSV at path with the rows from since on and how
+// many there are
+func readArchive(path string, since time.Time) ([]byte, int, error) {
+	archiveMu.Lock()
+	records, err := readCSV(path)
+	archiveMu.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(archiveHeader)
+	rows := 0
+	for _, record := range records {
+		if t, err := time.Parse(time.RFC3339, record[0]); err != nil || t.Before(since) {
+			continue // The header, or too old
+		}
+		w.Write(record)
+		rows++
+	}
+	w.Flush()
+	return buf.Bytes(), rows, w.Error()
+}
+
+// readCSV reads every row of the CSV file at path
+func readCSV(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1 // Rows of a later version may have more columns
+	return r.ReadAll()
+}
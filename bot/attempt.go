@@ -0,0 +1,32 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// launchAttempt identifies a launch across restarts by the display name of
+// the instance and the nonce of its retry token
+type launchAttempt struct {
+	Name  string
+	Nonce string
+}
+
+// attemptID is the ID of a create attempt named name in the account. Names
+// carry the time in seconds, so the nonce keeps two attempts made in the same
+// second from sharing a retry token. Attempts saved without one keep the ID
+// they had.
+func attemptID(account, name, nonce string) string {
+	id := account + "/" + name
+	if nonce != "" {
+		id += "/" + nonce
+	}
+	return id
+}
+
+// newNonce returns a random nonce for attemptID
+func newNonce() string {
+	var raw [8]byte
+	rand.Read(raw[:])
+	return hex.EncodeToString(raw[:])
+}
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"oci-bot/oci"
+)
+
+const (
+	// Audit events can take up to ~15 minutes to show up, so each check
+	// looks back this far and de-duplicates by event ID
+	auditLookback      = 30 * time.Minute
+	maxAuditEventsShow = 15
+)
+
+// runAuditMonitor polls the Audit service of every account and alerts about
+// write operations that weren't made by the bot itself
+func (b *Bot) runAuditMonitor(ctx context.Context) {
+	interval := time.Duration(b.cfg.AuditCheckMinutes) * time.Minute
+	logger.Infof("Audit monitor started (every %s)", interval)
+
+	seen := make(map[string]time.Time) // Event ID -> event time
+	since := time.Now()                // Don't report history from before startup
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.checkAuditEvents(ctx, seen, since)
+		}
+	}
+}
+
+// checkAuditEvents sends one alert per account with new external write events
+func (b *Bot) checkAuditEvents(ctx context.Context, seen map[string]time.Time, since time.Time) {
+	end := time.Now()
+	start := end.Add(-auditLookback)
+	if start.Before(since) {
+		start = since
+	}
+
+	for _, client := range b.sortedClients() {
+		name := client.AccountName()
+		listCtx, cancel := context.WithTimeout(ctx, reportTimeout)
+		events, err := client.ListWriteEvents(listCtx, start, end)
+		cancel()
+		if err != nil {
+			logger.Warnf("[%s] Audit check failed: %v", name, err)
+			continue
+		}
+
+		var external []oci.AuditEventInfo
+		for _, ev := range events {
+			if _, ok := seen[ev.ID]; ok {
+				continue
+			}
+			seen[ev.ID] = ev.Time
+			if !ev.ByBot {
+				external = append(external, ev)
+			}
+		}
+
+		if len(external) > 0 {
+			b.alert(formatAuditAlert(name, external))
+		}
+	}
+
+	for id, t := range seen {
+		if t.Before(end.Add(-2 * auditLookback)) {
+			delete(seen, id)
+		}
+	}
+}
+
+func formatAuditAlert(account string, events []oci.AuditEventInfo) string {
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔔 [%s] 检测到外部操作 (%d)\n\n", account, len(events)))
+	for i, ev := range events {
+		if i >= maxAuditEventsShow {
+			sb.WriteString(fmt.Sprintf("...另有 %d 条\n", len(events)-maxAuditEventsShow))
+			break
+		}
+		resource := ev.ResourceName
+		if resource == "" {
+			resource = ev.ResourceID
+		}
+		sb.WriteString(fmt.Sprintf("• %s %s %s", ev.Time.Local().Format("01-02 15:04"), ev.EventName, resource))
+		if ev.Principal != "" {
+			sb.WriteString(" (" + ev.Principal + ")")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,185 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"oci-bot/config"
+	"oci-bot/schedule"
+)
+
+// autoIPSchedule is the parsed autoip_start / autoip_stop of an account
+type autoIPSchedule struct {
+	start, stop *schedule.Cron // nil when not configured
+}
+
+// autoIPScheduleFor parses the account's auto-apply schedule
+func autoIPScheduleFor(account *config.OCIAccount) (autoIPSchedule, error) {
+	var s autoIPSchedule
+	var err error
+	if account.AutoIPStart != "" {
+		if s.start, err = schedule.Parse(account.AutoIPStart); err != nil {
+			return s, err
+		}
+	}
+	if account.AutoIPStop != "" {
+		if s.stop, err = schedule.Parse(account.AutoIPStop); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// next returns the next start or stop after t, whichever comes first, and
+// whether it is a start. Zero when neither ever fires.
+func (s autoIPSchedule) next(t time.Time) (time.Time, bool) {
+	var start, stop time.Time
+	if s.start != nil {
+		start = s.start.Next(t)
+	}
+	if s.stop != nil {
+		stop = s.stop.Next(t)
+	}
+	if !start.IsZero() && (stop.IsZero() || !stop.Before(start)) {
+		return start, true
+	}
+	return stop, false
+}
+
+// startAutoIPSchedules starts a scheduler for every account that configures
+// autoip_start or autoip_stop
+func (b *Bot) startAutoIPSchedules(ctx context.Context) {
+	for i := range b.cfg.Accounts {
+		account := &b.cfg.Accounts[i]
+		if _, ok := b.client(account.Name); !ok || (account.AutoIPStart == "" && account.AutoIPStop == "") {
+			continue
+		}
+		// Already validated by config.Validate
+		sched, err := autoIPScheduleFor(account)
+		if err != nil {
+			logger.Errorf("[%s] Invalid auto-apply schedule: %v", account.Name, err)
+			continue
+		}
+		go b.supervise(ctx, "auto-apply schedule ["+account.Name+"]", func(ctx context.Context) {
+			b.runAutoIPSchedule(ctx, account, sched)
+		})
+	}
+}
+
+// runAutoIPSchedule starts and stops auto-apply on the account at the
+// configured times
+func (b *Bot) runAutoIPSchedule(ctx context.Context, account *config.OCIAccount, sched autoIPSchedule) {
+	logger.Infof("[%s] Auto-apply schedule started (start %q, stop %q)", account.Name, account.AutoIPStart, account.AutoIPStop)
+
+	for {
+		next, start := sched.next(time.Now())
+		if next.IsZero() {
+			logger.Errorf("[%s] Auto-apply schedule never fires", account.Name)
+			return
+		}
+		action := "stop"
+		if start {
+			action = "start"
+		}
+		logger.Debugf("[%s] Next scheduled auto-apply %s at %s", account.Name, action, next.Format(time.RFC3339))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		if _, ok := b.client(account.Name); !ok {
+			continue // Disabled or removed
+		}
+		if start {
+			b.scheduledAutoIPStart(account)
+		} else {
+			b.scheduledAutoIPStop(account)
+		}
+	}
+}
+
+// scheduledAutoIPStart starts auto-apply with the account's preset, keeping any
+// existing IPs. Nothing happens when a task is already running.
+func (b *Bot) scheduledAutoIPStart(account *config.OCIAccount) {
+	chatID := b.alertChatID()
+	preset := account.AutoIP
+
+	b.mu.Lock()
+	if running := b.autoApply; running != nil && running.Active {
+		b.mu.Unlock()
+		logger.Infof("[%s] Scheduled auto-apply skipped, [%s] is already running", account.Name, running.AccountName)
+		b.replyIn(chatID, topicAuto, fmt.Sprintf("⏰ [%s] 定时刷IP跳过: [%s] 的任务正在运行", account.Name, running.AccountName))
+		return
+	}
+	config := &AutoApplyConfig{
+		AccountName:     account.Name,
+		PurityThreshold: preset.PurityThreshold,
+		NativeRequired:  preset.NativeRequired,
+		TypeRequired:    preset.TypeRequired,
+		MatchMode:       preset.MatchMode,
+		IntervalMin:     preset.IntervalMin,
+		IntervalMax:     preset.IntervalMax,
+		ChatID:          chatID,
+	}
+	b.autoApply = config
+	b.mu.Unlock()
+
+	logger.Infof("[%s] Starting scheduled auto-apply", account.Name)
+	b.replyIn(chatID, topicAuto, fmt.Sprintf("⏰ [%s] 定时刷IP开始", account.Name))
+	b.doStartAutoApply(chatID, config)
+}
+
+// scheduledAutoIPStop stops auto-apply if it is running on the account, letting
+// the current attempt finish
+func (b *Bot) scheduledAutoIPStop(account *config.OCIAccount) {
+	b.mu.Lock()
+	running := b.autoApply
+	b.mu.Unlock()
+	if running == nil || !running.Active || running.AccountName != account.Name {
+		logger.Debugf("[%s] Scheduled auto-apply stop: nothing running", account.Name)
+		return
+	}
+
+	logger.Infof("[%s] Stopping auto-apply on schedule", account.Name)
+	b.replyIn(running.ChatID, topicAuto, fmt.Sprintf("⏰ [%s] 定时停止刷IP", account.Name))
+	b.softStopAutoApply(running.ChatID)
+}
+
+// autoIPScheduleSummary lists the next scheduled start and stop per account
+// for /status, or "" when nothing is scheduled
+func (b *Bot) autoIPScheduleSummary() string {
+	b.mu.Lock()
+	accounts := slices.Clone(b.cfg.Accounts)
+	b.mu.Unlock()
+
+	var sb strings.Builder
+	now := time.Now()
+	for i := range accounts {
+		account := &accounts[i]
+		if _, ok := b.client(account.Name); !ok {
+			continue
+		}
+		sched, err := autoIPScheduleFor(account)
+		if err != nil || (sched.start == nil && sched.stop == nil) {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("[%s]", account.Name))
+		if sched.start != nil {
+			if next := sched.start.Next(now); !next.IsZero() {
+				sb.WriteString(" 启动 " + next.Format("01-02 15:04"))
+			}
+		}
+		if sched.stop != nil {
+			if next := sched.stop.Next(now); !next.IsZero() {
+				sb.WriteString(" 停止 " + next.Format("01-02 15:04"))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
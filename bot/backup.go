@@ -0,0 +1,262 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// backupVPS creates a custom image from an instance, or shows the instance list when no name is given
+func (b *Bot) backupVPS(chatID int64, args string) {
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	instances, err := client.ListInstances(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	if args != "" {
+		for _, inst := range instances {
+			if inst.ID == args || inst.DisplayName == args {
+				b.doBackupVPS(chatID, client, inst)
+				return
+			}
+		}
+		b.reply(chatID, "❌ 未找到实例: "+args)
+		return
+	}
+
+	if len(instances) == 0 {
+		b.reply(chatID, fmt.Sprintf("📋 [%s] 暂无实例", client.AccountName()))
+		return
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, inst := range instances {
+		label := fmt.Sprintf("%s (%s)", inst.DisplayName, inst.State)
+		btn := tgbotapi.NewInlineKeyboardButtonData(label, "backupvps:"+b.accountRef(client.AccountName(), inst.ID))
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("💾 *[%s] 选择要备份的实例*", client.AccountName()))
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
+
+// backupVPSFromCallback backs up the instance chosen from the backup list, on
+// the account it was listed for
+func (b *Bot) backupVPSFromCallback(chatID int64, ref string) {
+	accountName, instanceID, ok := b.resolveAccountRef(ref)
+	if !ok {
+		b.reply(chatID, "⚠️ 按钮已过期，请重新使用 /backupvps")
+		return
+	}
+	client, ok := b.client(accountName)
+	if !ok {
+		b.reply(chatID, "❌ 账号不存在: "+accountName)
+		return
+	}
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	instances, err := client.ListInstances(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+	for _, inst := range instances {
+		if inst.ID == instanceID {
+			b.doBackupVPS(chatID, client, inst)
+			return
+		}
+	}
+	b.reply(chatID, "❌ 未找到实例: "+instanceID)
+}
+
+// doBackupVPS starts custom image creation and reports the result in the background
+func (b *Bot) doBackupVPS(chatID int64, client oci.Service, instance oci.InstanceInfo) {
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	release, err := b.acquireAccount(b.runCtx, chatID, client.AccountName())
+	if err != nil {
+		return
+	}
+	displayName := fmt.Sprintf("backup-%s-%d", instance.DisplayName, time.Now().Unix())
+	image, err := client.CreateImageFromInstance(ctx, instance, displayName)
+	release()
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	b.reply(chatID, fmt.Sprintf("⏳ [%s] 正在创建镜像 %s ...\n实例在创建期间可能短暂不可用，完成后会通知", client.AccountName(), displayName))
+
+	go b.runRecovered("backup "+displayName, func() {
+		waitCtx, waitCancel := b.withTimeout(imageWaitTimeout)
+		defer waitCancel()
+
+		if image.WorkRequestID != "" {
+			what := fmt.Sprintf("[%s] 镜像 %s 创建", client.AccountName(), displayName)
+			if err := b.trackWorkRequest(waitCtx, chatID, topicNone, client, image.WorkRequestID, what); err != nil {
+				return
+			}
+		}
+
+		image, err := client.WaitForImageAvailable(waitCtx, image.ID, imageWaitTimeout)
+		if err != nil {
+			logger.Errorf("Backup image %s failed: %v", displayName, err)
+			b.reply(chatID, fmt.Sprintf("❌ 镜像 %s 创建失败: %s", displayName, describeError(err)))
+			return
+		}
+
+		logger.Infof("Backup image ready: %s", image.DisplayName)
+		b.reply(chatID, fmt.Sprintf("✅ 镜像创建完成: %s\n使用 /restorevps 从镜像恢复", image.DisplayName))
+	})
+}
+
+// restoreVPS shows the custom image list to launch a new instance from
+func (b *Bot) restoreVPS(chatID int64) {
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	images, err := client.ListCustomImages(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, img := range images {
+		label := fmt.Sprintf("%s (%s)", img.DisplayName, img.TimeCreated.Format("01-02 15:04"))
+		btn := tgbotapi.NewInlineKeyboardButtonData(label, "restorevps:"+b.accountRef(client.AccountName(), img.ID))
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
+	}
+
+	if len(buttons) == 0 {
+		b.reply(chatID, fmt.Sprintf("📋 [%s] 暂无可用镜像\n使用 /backupvps 创建", client.AccountName()))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("♻️ *[%s] 选择要恢复的镜像*", client.AccountName()))
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
+
+// doRestoreVPS launches a new instance from a custom image using the VPS
+// network settings of the account the image was listed for
+func (b *Bot) doRestoreVPS(chatID int64, ref string) {
+	accountName, imageID, ok := b.resolveAccountRef(ref)
+	if !ok {
+		b.reply(chatID, "⚠️ 按钮已过期，请重新使用 /restorevps")
+		return
+	}
+	client, ok := b.client(accountName)
+	if !ok {
+		b.reply(chatID, "❌ 账号不存在: "+accountName)
+		return
+	}
+
+	account := b.cfg.GetAccount(client.AccountName())
+	if account == nil || account.VPSAvailabilityDomain == "" || account.VPSSubnetID == "" {
+		b.reply(chatID, "❌ VPS配置错误: vps_ad 和 vps_subnet_id 是必需的")
+		return
+	}
+
+	ctx, cancel := b.withTimeout(launchTimeout)
+	defer cancel()
+
+	images, err := client.ListCustomImages(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	var image *oci.ImageInfo
+	for i := range images {
+		if images[i].ID == imageID {
+			image = &images[i]
+			break
+		}
+	}
+	if image == nil {
+		b.reply(chatID, "❌ 镜像不存在")
+		return
+	}
+	if image.Shape == "" {
+		b.reply(chatID, "❌ 镜像缺少规格信息，无法恢复")
+		return
+	}
+
+	b.reply(chatID, fmt.Sprintf("⏳ [%s] 正在从镜像 %s 创建实例...", client.AccountName(), image.DisplayName))
+
+	details := oci.VPSLaunchDetails{
+		AvailabilityDomain: account.VPSAvailabilityDomain,
+		SubnetID:           account.VPSSubnetID,
+		ImageID:            image.ID,
+		Shape:              image.Shape,
+		DisplayName:        fmt.Sprintf("restore-%d", time.Now().Unix()),
+		SSHAuthorizedKeys:  account.VPSSSHKeys,
+		BootVolumeGB:       account.VPSBootVolumeGB,
+	}
+	if strings.HasSuffix(image.Shape, ".Flex") {
+		details.OCPUs = image.OCPUs
+		details.MemoryGB = image.MemoryGB
+	}
+
+	release, err := b.acquireAccount(b.runCtx, chatID, client.AccountName())
+	if err != nil {
+		return
+	}
+	launched := time.Now()
+	instance, err := client.LaunchInstanceWithFallback(ctx, details, account.VPSFaultDomainFallback)
+	release()
+	b.recordLaunch(details.Shape, instance, err)
+	if err != nil {
+		b.reply(chatID, "❌ 恢复失败: "+describeError(err))
+		return
+	}
+
+	instanceID := ""
+	if instance.Id != nil {
+		instanceID = *instance.Id
+	}
+	ad := ""
+	if instance.AvailabilityDomain != nil {
+		ad = *instance.AvailabilityDomain
+	}
+	b.replyMarkdown(chatID, fmt.Sprintf(`✅ *恢复已启动*
+
+实例ID: %s
+规格: %s
+可用域: %s
+
+📍 [%s] %s`, instanceID, image.Shape, ad, client.AccountName(), client.Region()))
+
+	var trackErr error
+	if instance.WorkRequestID != "" {
+		trackCtx, trackCancel := b.withTimeout(workRequestTimeout)
+		defer trackCancel()
+		trackErr = b.trackWorkRequest(trackCtx, chatID, topicNone, client, instance.WorkRequestID, fmt.Sprintf("[%s] 实例启动", client.AccountName()))
+	}
+	if instanceID != "" && trackErr == nil {
+		b.waitSSHReady(b.runCtx, chatID, topicNone, client, instanceID, launched)
+	}
+}
@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"oci-bot/oci"
+)
+
+// Always Free allowances used for cost warnings
+const (
+	freeStorageGB  = 200 // Boot + block volume storage
+	freeA1OCPUs    = 4   // VM.Standard.A1.Flex OCPUs
+	freeA1MemoryGB = 24  // VM.Standard.A1.Flex memory
+	freeMicroCount = 2   // VM.Standard.E2.1.Micro instances
+)
+
+// showBilling reports month-to-date cost and Always Free usage for every account
+func (b *Bot) showBilling(chatID int64) {
+	b.reply(chatID, "⏳ 正在查询费用和资源用量...")
+
+	var sb strings.Builder
+	sb.WriteString("💰 *费用与免费额度*\n")
+	for _, client := range b.sortedClients() {
+		sb.WriteString("\n")
+		sb.WriteString(b.billingReport(client))
+	}
+
+	b.replyMarkdown(chatID, sb.String())
+}
+
+// billingReport builds the cost and free-tier usage section for one account
+func (b *Bot) billingReport(client oci.Service) string {
+	ctx, cancel := b.withTimeout(reportTimeout)
+	defer cancel()
+
+	var sb strings.Builder
+	var warnings []string
+	sb.WriteString(fmt.Sprintf("📍 *[%s]* %s\n", client.AccountName(), client.Region()))
+
+	cost, err := client.MonthToDateCost(ctx)
+	if err != nil {
+		sb.WriteString("⚠️ 费用查询失败: " + escapeMarkdown(describeError(err)) + "\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("💵 本月费用: %.2f %s\n", cost.Total, cost.Currency))
+		for i, svc := range cost.Services {
+			if i >= 3 || svc.Amount < 0.01 {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("  • %s: %.2f\n", escapeMarkdown(svc.Service), svc.Amount))
+		}
+		if cost.Total >= 0.01 {
+			warnings = append(warnings, "本月已产生费用")
+		}
+	}
+
+	ips, err := client.ListReservedIPs(ctx)
+	if err != nil {
+		sb.WriteString("⚠️ IP查询失败: " + escapeMarkdown(describeError(err)) + "\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("🌐 预留IP: %d/%d\n", len(ips), b.cfg.FreeReservedIPs))
+		if len(ips) > b.cfg.FreeReservedIPs {
+			warnings = append(warnings, fmt.Sprintf("预留IP超出免费额度 %d 个", len(ips)-b.cfg.FreeReservedIPs))
+		}
+	}
+
+	instances, err := client.ListInstances(ctx)
+	if err != nil {
+		sb.WriteString("⚠️ 实例查询失败: " + escapeMarkdown(describeError(err)) + "\n")
+	} else {
+		var a1OCPUs, a1Memory float32
+		micro := 0
+		for _, inst := range instances {
+			switch inst.Shape {
+			case "VM.Standard.A1.Flex":
+				a1OCPUs += inst.OCPUs
+				a1Memory += inst.MemoryGB
+			case "VM.Standard.E2.1.Micro":
+				micro++
+			}
+		}
+		sb.WriteString(fmt.Sprintf("🧩 ARM: %.0f/%d OCPU, %.0f/%d GB\n", a1OCPUs, freeA1OCPUs, a1Memory, freeA1MemoryGB))
+		sb.WriteString(fmt.Sprintf("🧮 AMD Micro: %d/%d\n", micro, freeMicroCount))
+		if a1OCPUs > freeA1OCPUs || a1Memory > freeA1MemoryGB {
+			warnings = append(warnings, "ARM 资源超出免费额度")
+		}
+		if micro > freeMicroCount {
+			warnings = append(warnings, "AMD Micro 实例超出免费额度")
+		}
+	}
+
+	var storageGB int64
+	bootVolumes, bootErr := client.ListBootVolumes(ctx)
+	blockVolumes, blockErr := client.ListBlockVolumes(ctx)
+	if bootErr != nil || blockErr != nil {
+		sb.WriteString("⚠️ 存储查询失败\n")
+	} else {
+		for _, vol := range append(bootVolumes, blockVolumes...) {
+			storageGB += vol.SizeGB
+		}
+		sb.WriteString(fmt.Sprintf("💾 块存储: %d/%d GB\n", storageGB, freeStorageGB))
+		if storageGB > freeStorageGB {
+			warnings = append(warnings, fmt.Sprintf("块存储超出免费额度 %d GB", storageGB-freeStorageGB))
+		}
+	}
+
+	_, egress, err := monthlyEgress(ctx, client)
+	if err != nil {
+		sb.WriteString("⚠️ 流量查询失败: " + escapeMarkdown(describeError(err)) + "\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("📤 本月出站: %s/%d GB\n", formatBytes(egress), b.cfg.EgressLimitGB))
+		if egress > float64(b.cfg.EgressLimitGB)*bytesPerGB {
+			warnings = append(warnings, "出站流量超出免费额度")
+		}
+	}
+
+	for _, w := range warnings {
+		sb.WriteString("⚠️ " + w + "\n")
+	}
+
+	return sb.String()
+}
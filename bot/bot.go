@@ -3,39 +3,54 @@ package bot
 import (
 	"context"
 	"fmt"
-	"log"
 	"math/rand"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"oci-bot/bot/events"
+	"oci-bot/bot/workflow"
 	"oci-bot/config"
 	"oci-bot/ippure"
+	"oci-bot/latency"
+	"oci-bot/logging"
 	"oci-bot/oci"
+	"oci-bot/route"
+	"oci-bot/state"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-// IPPurityCache stores purity info for checked IPs
-type IPPurityCache struct {
-	PurityScore string
-	IPType      string
-	IsNative    string
-}
+var logger = logging.New("bot")
+
+// createTries is how often retryCreate sends a create call before giving up
+const createTries = 3
 
 // AutoApplyConfig stores auto-apply task settings
 type AutoApplyConfig struct {
 	AccountName     string             // Selected account
 	PurityThreshold int                // Max purity score threshold (e.g., 50 means <= 50%)
 	NativeRequired  string             // "原生IP" / "非原生IP" / "any"
+	TypeRequired    string             // "机房IP" / "住宅IP" / "any"
 	MatchMode       string             // "all" (both conditions) / "any" (one condition)
 	IntervalMin     int                // Min interval seconds
 	IntervalMax     int                // Max interval seconds
 	Active          bool               // Is auto-apply running
 	Cancel          context.CancelFunc // To stop the task
 	ChatID          int64              // Chat ID to send notifications
+	UncheckedIPs    []string           // IPs kept because their purity check failed
+	Stages          pipelineStats      // Candidates checked and rejected per stage
+	Pace            *pacer             // Wait between attempts and observed call rate
+	InAttempt       bool               // A create-check-decide cycle is in progress
+	Stopping        bool               // Stop once the current cycle is done
+	Paused          bool               // Hold before the next cycle until resumed
+	resume          chan struct{}      // Closed by /resumeauto
+	BestMiss        *missedIP          // Closest candidate that did not match, for progress reports
+	NearMiss        *missedIP          // Last near miss reported, see auto_near_miss
+	Kept            *oci.PublicIPInfo  // The matching IP the task finished with
 }
 
 // AutoVPSConfig stores auto-VPS task settings
@@ -47,16 +62,22 @@ type AutoVPSConfig struct {
 	Active      bool               // Is auto-VPS running
 	Cancel      context.CancelFunc // To stop the task
 	ChatID      int64              // Chat ID to send notifications
+	Pace        *pacer             // Wait between attempts and observed call rate
+	Provision   *provisioning      // vps_recipe applied once the instance is up, nil for none
 }
 
 // AutoApplyWizard tracks the wizard setup state
 type AutoApplyWizard struct {
-	Step            int // Current step: 1=account, 2=purity, 3=native, 4=mode, 5=interval
+	Step            int // Current step: 0=profile, 1=account, 2=purity, 3=native, 4=type, 5=mode, 6=interval
 	AccountName     string
 	PurityThreshold int
 	NativeRequired  string
+	TypeRequired    string
 	MatchMode       string
+	Profile         *autoIPProfile // Chosen at step 0; skips steps 2-5
 	ChatID          int64
+	UserID          int64     // Started it; in the group only their input is taken
+	Expires         time.Time // Abandoned after this, see wizard_timeout_minutes
 }
 
 // AutoVPSWizard tracks the VPS wizard setup state
@@ -65,180 +86,510 @@ type AutoVPSWizard struct {
 	AccountName string
 	Arch        string
 	ChatID      int64
+	UserID      int64     // Started it; in the group only their input is taken
+	Expires     time.Time // Abandoned after this, see wizard_timeout_minutes
 }
 
 // Bot represents the Telegram bot
 type Bot struct {
-	api           *tgbotapi.BotAPI
-	cfg           *config.Config
-	clients       map[string]*oci.Client
-	currentClient *oci.Client
-	adminID       int64
-	mu            sync.Mutex
-	purityCache   map[string]*IPPurityCache // IP -> purity info cache
-	autoApply     *AutoApplyConfig          // Auto-apply task config
-	autoWizard    *AutoApplyWizard          // Auto-apply wizard state
-	autoVPS       *AutoVPSConfig            // Auto-VPS task config
-	vpsWizard     *AutoVPSWizard            // Auto-VPS wizard state
-}
-
-// New creates a new Telegram bot
+	api            Transport
+	cfg            *config.Config
+	clients        map[string]oci.Service // Account name -> service, guarded by mu as /addaccount adds to it
+	currentClient  oci.Service
+	adminID        int64
+	mu             sync.Mutex
+	checker        *ippure.Checker            // Purity checks, their results cached for check_cache_minutes
+	autoApply      *AutoApplyConfig           // Auto-apply task config
+	autoWizards    map[int64]*AutoApplyWizard // Chat ID -> auto-apply wizard state
+	autoVPS        *AutoVPSConfig             // Auto-VPS task config
+	jobs           *workflow.Engine           // Multi-step tasks resumed after a restart, see registerWorkflows
+	vpsWizards     map[int64]*AutoVPSWizard   // Chat ID -> auto-VPS wizard state
+	accountWizards map[int64]*accountWizard   // Chat ID -> /addaccount wizard state
+	refs           map[string]callbackTarget  // Short callback token -> OCID
+	queues         map[string]*accountQueue   // Account name -> mutating operation queue
+	store          *state.Store               // Persistent state
+	pinned         map[string]bool            // Pinned IP addresses, never deleted
+	regionStats    map[string]*regionStats    // Region -> purity statistics
+	capacity       map[string]*capacityStats  // "AD|shape" -> launch outcomes
+	outbox         []outboxEntry              // Critical notifications waiting for delivery
+	profiles       map[string]autoIPProfile   // Saved auto-apply criteria by name
+	keptAutoIPs    map[string]bool            // Auto-apply IPs kept on purpose, not leaked
+	released       map[string]releasedIP      // Auto-apply IPs released as mismatches, see known_bad_days
+	watchlist      map[string]*watchedIP      // External IP -> last purity check
+	pinnedState    map[string]pinnedSnapshot  // Pinned IP -> assignment at the last check
+	digest         *digestState               // Counters since the last daily digest
+	selections     map[int64]*ipSelection     // Chat ID -> bulk delete selection
+	renames        map[int64]*renameTarget    // Chat ID -> resource waiting for a new name
+	groupAdmins    map[int64]groupAdmin       // User ID -> cached admin status in group_chat_id
+	approvals      map[string]*approval       // ID -> destructive action waiting for a second admin
+	limiter        *rateLimiter               // Outgoing message pacing
+	runCtx         context.Context            // Cancelled when Run returns; parent of every call's context
+	statusMu       sync.Mutex
+	statuses       map[int64]*pendingStatus // Chat ID -> status lines waiting to be merged
+	probes         probeState               // Reported on health_listen
+	events         eventHub                 // Task events for control_socket clients and plugins
+	capturePhoto   time.Time                // When a broken check's screenshot was last sent, guarded by mu
+}
+
+// New creates a new bot on the configured chat platform
 func New(cfg *config.Config) (*Bot, error) {
-	api, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
+	api, err := newTransport(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Telegram bot: %w", err)
+		return nil, err
 	}
 
-	log.Printf("Telegram bot authorized: @%s", api.Self.UserName)
-
-	clients := make(map[string]*oci.Client)
-	var firstClient *oci.Client
+	clients := make(map[string]oci.Service)
 	for _, acc := range cfg.Accounts {
+		if acc.Disabled {
+			logger.Infof("Skipping disabled OCI account: [%s]", acc.Name)
+			continue
+		}
 		client, err := oci.NewClient(&acc)
 		if err != nil {
-			log.Printf("Warning: failed to create OCI client for [%s]: %v", acc.Name, err)
+			logger.Warnf("Failed to create OCI client for [%s]: %v", acc.Name, err)
 			continue
 		}
 		clients[acc.Name] = client
-		if firstClient == nil {
-			firstClient = client
-		}
-		log.Printf("Loaded OCI account: [%s] (%s)", acc.Name, acc.Region)
+		logger.Infof("Loaded OCI account: [%s] (%s)", acc.Name, acc.Region)
 	}
 
+	return NewWithServices(cfg, api, clients)
+}
+
+// NewWithServices creates a bot on top of the given Telegram transport and OCI
+// services, keyed by account name. The first account in config order becomes
+// the current one.
+func NewWithServices(cfg *config.Config, api Transport, clients map[string]oci.Service) (*Bot, error) {
 	if len(clients) == 0 {
 		return nil, fmt.Errorf("no valid OCI accounts configured")
 	}
 
+	var firstClient oci.Service
+	for _, acc := range cfg.Accounts {
+		if client, ok := clients[acc.Name]; ok {
+			firstClient = client
+			break
+		}
+	}
+	if firstClient == nil {
+		return nil, fmt.Errorf("no OCI service matches a configured account")
+	}
+
 	// Set bot commands menu
 	commands := []tgbotapi.BotCommand{
 		{Command: "accounts", Description: "列出所有账号"},
 		{Command: "use", Description: "切换账号"},
+		{Command: "region", Description: "切换区域"},
 		{Command: "newip", Description: "创建预留IP"},
 		{Command: "listip", Description: "列出IP"},
 		{Command: "delip", Description: "删除IP"},
 		{Command: "checkip", Description: "检测IP纯净度"},
+		{Command: "compare", Description: "对比多个IP"},
 		{Command: "autoip", Description: "自动刷IP"},
 		{Command: "autovps", Description: "自动申请VPS"},
 		{Command: "stopauto", Description: "停止自动刷IP"},
+		{Command: "pauseauto", Description: "暂停自动刷IP"},
+		{Command: "resumeauto", Description: "继续自动刷IP"},
+		{Command: "tune", Description: "调整运行中的自动刷IP"},
 		{Command: "stopvps", Description: "停止自动申请VPS"},
+		{Command: "profiles", Description: "自动刷IP方案"},
+		{Command: "status", Description: "自动任务状态"},
+		{Command: "cancel", Description: "取消进行中的配置或输入"},
+		{Command: "backupvps", Description: "备份实例为镜像"},
+		{Command: "restorevps", Description: "从镜像恢复实例"},
+		{Command: "volbackup", Description: "引导卷备份"},
+		{Command: "rename", Description: "重命名IP或实例"},
+		{Command: "tag", Description: "查看/设置标签"},
+		{Command: "pin", Description: "固定IP，禁止删除"},
+		{Command: "unpin", Description: "取消固定IP"},
+		{Command: "watch", Description: "关注外部IP的纯净度"},
+		{Command: "unwatch", Description: "取消关注外部IP"},
+		{Command: "orphans", Description: "未绑定的预留IP"},
+		{Command: "protected", Description: "受保护的IP"},
+		{Command: "billing", Description: "费用与免费额度"},
+		{Command: "metrics", Description: "实例监控"},
+		{Command: "network", Description: "实例网络"},
+		{Command: "exec", Description: "在实例上执行命令"},
+		{Command: "provision", Description: "在实例上部署配方"},
+		{Command: "deploy", Description: "抢实例、刷IP、部署一条龙"},
+		{Command: "jobs", Description: "未完成的多步任务"},
+		{Command: "regions", Description: "各区域IP纯净度"},
+		{Command: "exportips", Description: "导出IP检测记录"},
+		{Command: "capacity", Description: "各可用域实例容量统计"},
+		{Command: "whoami", Description: "租户与用户信息"},
+		{Command: "health", Description: "检查账号凭据"},
+		{Command: "addaccount", Description: "添加账号"},
+		{Command: "rmaccount", Description: "删除账号"},
+		{Command: "disableaccount", Description: "停用账号"},
+		{Command: "enableaccount", Description: "启用账号"},
+		{Command: "rotatekey", Description: "轮换API密钥"},
+		{Command: "settings", Description: "运行设置"},
+		{Command: "perms", Description: "查看权限"},
+		{Command: "loglevel", Description: "日志级别"},
 		{Command: "help", Description: "帮助"},
 	}
+	store, err := state.Open(cfg.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	pinned, err := loadPinned(store)
+	if err != nil {
+		return nil, err
+	}
+	regionStats, err := loadRegionStats(store)
+	if err != nil {
+		return nil, err
+	}
+	capacity, err := loadCapacityStats(store)
+	if err != nil {
+		return nil, err
+	}
+	outbox, err := loadOutbox(store)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := loadProfiles(store)
+	if err != nil {
+		return nil, err
+	}
+	keptAutoIPs, err := loadKeptAutoIPs(store)
+	if err != nil {
+		return nil, err
+	}
+	watchlist, err := loadWatchlist(store)
+	if err != nil {
+		return nil, err
+	}
+	released, err := loadReleased(store)
+	if err != nil {
+		return nil, err
+	}
+	pinnedState, err := loadPinnedState(store)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := loadDigest(store)
+	if err != nil {
+		return nil, err
+	}
+
+	checkPermissions(cfg.Permissions, commands)
+
 	cmdConfig := tgbotapi.NewSetMyCommands(commands...)
 	api.Send(cmdConfig)
-	log.Printf("Bot commands menu configured")
-
-	return &Bot{
-		api:           api,
-		cfg:           cfg,
-		clients:       clients,
-		currentClient: firstClient,
-		adminID:       cfg.TelegramAdminID,
-		purityCache:   make(map[string]*IPPurityCache),
-	}, nil
+	logger.Debugf("Bot commands menu configured")
+
+	b := &Bot{
+		api:            api,
+		cfg:            cfg,
+		clients:        clients,
+		currentClient:  firstClient,
+		adminID:        cfg.TelegramAdminID,
+		checker:        ippure.NewChecker(time.Duration(cfg.CheckCacheMinutes) * time.Minute),
+		refs:           make(map[string]callbackTarget),
+		queues:         make(map[string]*accountQueue),
+		autoWizards:    make(map[int64]*AutoApplyWizard),
+		vpsWizards:     make(map[int64]*AutoVPSWizard),
+		accountWizards: make(map[int64]*accountWizard),
+		selections:     make(map[int64]*ipSelection),
+		renames:        make(map[int64]*renameTarget),
+		groupAdmins:    make(map[int64]groupAdmin),
+		approvals:      make(map[string]*approval),
+		store:          store,
+		pinned:         pinned,
+		regionStats:    regionStats,
+		capacity:       capacity,
+		outbox:         outbox,
+		profiles:       profiles,
+		keptAutoIPs:    keptAutoIPs,
+		released:       released,
+		watchlist:      watchlist,
+		pinnedState:    pinnedState,
+		digest:         digest,
+		limiter:        newRateLimiter(),
+		statuses:       make(map[int64]*pendingStatus),
+		runCtx:         context.Background(),
+	}
+	b.jobs = workflow.New(store, jobsKey, b.supervise)
+	b.registerWorkflows()
+	if err := b.jobs.Load(); err != nil {
+		return nil, err
+	}
+	return b, nil
 }
 
 // Run starts the bot and listens for updates
 func (b *Bot) Run(ctx context.Context) error {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	b.runCtx = ctx
+	ippure.SetLimits(ippure.Limits{MaxConcurrent: b.cfg.MaxConcurrentChecks, MaxMemoryMB: b.cfg.CheckMemoryLimitMB})
+	if b.cfg.CheckMemoryLimitMB > 0 && !ippure.MemoryLimitSupported {
+		logger.Warnf("check_memory_limit_mb only limits the JavaScript heap on this system")
+	}
+	ippure.SetDebug(ippure.Debug{Dir: b.cfg.CheckDebugDir, OnCapture: b.onCheckCapture})
+	if err := ippure.SetRulesFile(b.cfg.IPPureRules); err != nil {
+		return fmt.Errorf("ippure_rules: %w", err)
+	}
+	if len(b.cfg.CheckerAgents) > 0 {
+		agents := make([]ippure.Agent, len(b.cfg.CheckerAgents))
+		for i, u := range b.cfg.CheckerAgents {
+			agents[i] = ippure.Agent{URL: u, Token: b.cfg.CheckerAgentToken}
+		}
+		ippure.SetAgents(agents)
+		logger.Infof("Purity checks run on %d checker agents", len(agents))
+	} else {
+		b.preflightChrome(ctx)
+	}
+	updates, err := b.startUpdates(ctx)
+	if err != nil {
+		return err
+	}
 
-	updates := b.api.GetUpdatesChan(u)
+	if b.cfg.LogForwardErrors {
+		b.startLogForwarding(ctx)
+	}
+	go b.supervise(ctx, "outbox", b.runOutbox)
+	go b.runRecovered("leaked IP scan", b.scanLeakedAutoIPs)
+	b.startKeepAlive(ctx)
+	b.startBackupSchedules(ctx)
+	b.startAutoIPSchedules(ctx)
+	b.resumeJobsOnStart(ctx)
+	if b.cfg.AuditCheckMinutes > 0 {
+		go b.supervise(ctx, "audit monitor", b.runAuditMonitor)
+	}
+	if b.cfg.EgressCheckHours > 0 {
+		go b.supervise(ctx, "egress monitor", b.runEgressMonitor)
+	}
+	if b.cfg.MetricsCheckMinutes > 0 {
+		go b.supervise(ctx, "CPU alert monitor", b.runCPUAlertMonitor)
+	}
+	if b.cfg.UnattachedIPCheckHours > 0 {
+		go b.supervise(ctx, "unattached IP monitor", b.runUnattachedIPMonitor)
+	}
+	if b.cfg.WatchCheckHours > 0 {
+		go b.supervise(ctx, "watchlist monitor", b.runWatchMonitor)
+	}
+	if b.cfg.PinnedCheckMinutes > 0 {
+		go b.supervise(ctx, "pinned IP monitor", b.runPinnedMonitor)
+	}
+	if b.cfg.DigestSchedule != "" {
+		go b.supervise(ctx, "daily digest", b.runDigest)
+	}
 
-	log.Println("Bot is running, waiting for commands...")
+	if b.cfg.HealthListen != "" {
+		b.startProbes(ctx)
+	}
+	if err := b.startPlugins(ctx); err != nil {
+		return err
+	}
+	b.startHooks(ctx)
+	if b.cfg.ControlSocket != "" {
+		if err := b.startControl(ctx); err != nil {
+			return err
+		}
+	}
 
+	logger.Infof("Bot is running, waiting for commands...")
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	b.probes.beat()
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Bot stopped")
+			logger.Infof("Bot stopped")
 			return nil
+		case <-heartbeat.C:
+			// Taking mu also catches a deadlock that leaves every handler stuck
+			b.mu.Lock()
+			b.mu.Unlock()
+			b.probes.beat()
 		case update := <-updates:
+			b.probes.received()
+			// Handlers may wait in an account queue, so don't block the update loop
 			if update.CallbackQuery != nil {
-				b.handleCallback(update.CallbackQuery)
+				cb := update.CallbackQuery
+				go b.runRecovered("callback "+cb.Data, func() { b.handleCallback(cb) })
+				continue
+			}
+			if update.InlineQuery != nil {
+				q := update.InlineQuery
+				go b.runRecovered("inline query", func() { b.handleInlineQuery(q) })
 				continue
 			}
 			if update.Message == nil {
 				continue
 			}
-			b.handleMessage(update.Message)
+			msg := update.Message
+			go b.runRecovered("message handler", func() { b.handleMessage(msg) })
 		}
 	}
 }
 
 // handleCallback handles inline button clicks
 func (b *Bot) handleCallback(cb *tgbotapi.CallbackQuery) {
-	if cb.From.ID != b.adminID {
+	if cb.Message == nil {
+		return
+	}
+	chatID := cb.Message.Chat.ID
+	allowed := b.accessFor(chatID, cb.From.ID, nil)
+	if allowed.none() {
 		return
 	}
 
 	data := cb.Data
-	log.Printf("Callback: %s", data)
+	logger.Debugf("Callback from %d: %s", cb.From.ID, data)
+
+	parts := strings.Split(data, ":")
+	if parts[0] == "apv" {
+		b.handleApprovalCallback(cb, allowed, parts)
+		return
+	}
+	if !allowed.allowsCallback(parts) {
+		b.api.Request(tgbotapi.NewCallback(cb.ID, "⛔ 没有权限"))
+		return
+	}
+	if what := destructiveAction(parts); what != "" && chatID == b.cfg.GroupChatID {
+		b.requestApproval(cb, what)
+		return
+	}
 
 	// Answer callback to remove loading state
 	callback := tgbotapi.NewCallback(cb.ID, "")
 	b.api.Request(callback)
 
-	parts := strings.Split(data, ":")
+	b.dispatchCallback(chatID, cb.From.ID, cb.Message.MessageID, parts)
+}
+
+// dispatchCallback runs the action of a button press by userID
+func (b *Bot) dispatchCallback(chatID, userID int64, messageID int, parts []string) {
 	if len(parts) < 2 {
 		return
 	}
-
 	action := parts[0]
 	param := parts[1]
 
 	switch action {
 	case "use":
-		b.switchAccount(cb.Message.Chat.ID, param)
+		b.switchAccount(chatID, param)
 	case "del":
-		b.deleteIP(cb.Message.Chat.ID, param)
+		b.deleteIP(chatID, param)
 	case "newip":
-		b.createIP(cb.Message.Chat.ID)
+		b.createIP(chatID)
 	case "refresh":
-		b.showIPList(cb.Message.Chat.ID)
+		filter := strings.Join(parts[1:], ":")
+		if filter == "1" {
+			filter = ""
+		}
+		b.showIPListWithHighlight(chatID, "", nil, filter)
 	case "check":
-		b.checkIPFromCallback(cb.Message.Chat.ID, param)
+		b.checkIPFromCallback(chatID, param)
 	case "autoip":
-		b.handleAutoIPCallback(cb.Message.Chat.ID, param, parts)
+		b.handleAutoIPCallback(chatID, param, parts)
 	case "autovps":
-		b.handleAutoVPSCallback(cb.Message.Chat.ID, param, parts)
+		b.handleAutoVPSCallback(chatID, param, parts)
+	case "backupvps":
+		b.backupVPSFromCallback(chatID, param)
+	case "restorevps":
+		b.doRestoreVPS(chatID, param)
+	case "releaseip":
+		b.releaseUnattachedIPs(chatID, param, time.Duration(b.cfg.UnattachedIPAgeHours)*time.Hour)
+	case "orphans":
+		b.releaseOrphans(chatID, param)
+	case "metrics":
+		b.showMetricsFromCallback(chatID, param)
+	case "network":
+		b.showNetworkFromCallback(chatID, param)
+	case "sel":
+		b.handleSelectCallback(chatID, messageID, param, parts)
+	case "ren":
+		b.handleRenameCallback(chatID, userID, param, parts)
+	case "mvip":
+		b.handleMoveIPCallback(chatID, param, parts)
+	case "vbk":
+		b.handleVolumeBackupCallback(chatID, param, parts)
+	case "prof":
+		b.handleProfileCallback(chatID, param, parts)
+	case "leaked":
+		b.handleLeakedCallback(chatID, param, parts)
+	case "rotkey":
+		b.handleRotateKeyCallback(chatID, param, parts)
+	case "watch":
+		b.handleWatchCallback(chatID, param, parts)
+	case "rgn":
+		b.switchRegion(chatID, param)
+	case "acct":
+		b.handleAccountCallback(chatID, param, parts)
+	case "cfg":
+		b.handleSettingsCallback(chatID, messageID, param, parts)
+	case "conn":
+		b.handleConnectionCallback(chatID, param, parts)
+	case "exec":
+		b.handleExecCallback(chatID, param, parts)
+	case "tune":
+		b.handleTuneCallback(chatID, messageID, param, parts)
+	case "job":
+		b.handleJobCallback(chatID, param, parts)
 	}
 }
 
 func (b *Bot) handleMessage(msg *tgbotapi.Message) {
-	log.Printf("Message from %d: %s", msg.From.ID, msg.Text)
+	logger.Debugf("Message from %d: %s", msg.From.ID, msg.Text)
 
-	if msg.From.ID != b.adminID {
+	allowed := b.accessFor(msg.Chat.ID, msg.From.ID, msg.SenderChat)
+	if allowed.none() {
 		b.reply(msg.Chat.ID, fmt.Sprintf("⛔ Unauthorized\nYour ID: %d", msg.From.ID))
 		return
 	}
 
-	// Check if we're waiting for interval input in auto-apply wizard
+	// Check if we're waiting for a new name or interval input in a wizard
 	if !msg.IsCommand() {
+		if !allowed.writes() {
+			return // Group chatter
+		}
+		if b.handleRenameInput(msg.Chat.ID, msg.From.ID, msg.Text) {
+			return
+		}
+		if b.handleAccountInput(msg) {
+			return
+		}
+
 		b.mu.Lock()
-		wizard := b.autoWizard
-		vpsWizard := b.vpsWizard
+		wizard := b.autoWizardLocked(msg.Chat.ID)
+		vpsWizard := b.vpsWizardLocked(msg.Chat.ID)
 		b.mu.Unlock()
 
-		if wizard != nil && wizard.Step == 5 {
+		// In the group, other members' messages aren't answers to the
+		// wizard someone else started
+		if wizard != nil && wizard.Step == 6 && wizard.UserID == msg.From.ID {
 			// Expecting interval input
 			b.handleIntervalInput(msg.Chat.ID, msg.Text)
 			return
 		}
-		if vpsWizard != nil && vpsWizard.Step == 3 {
+		if vpsWizard != nil && vpsWizard.Step == 3 && vpsWizard.UserID == msg.From.ID {
 			// Expecting interval input
 			b.handleVPSIntervalInput(msg.Chat.ID, msg.Text)
 			return
 		}
 
-		b.reply(msg.Chat.ID, "Use /help")
+		if msg.Chat.ID != b.cfg.GroupChatID {
+			b.reply(msg.Chat.ID, "Use /help")
+		}
 		return
 	}
 
 	cmd := msg.Command()
 	args := msg.CommandArguments()
+	if !allowed.allows(cmd) {
+		b.reply(msg.Chat.ID, fmt.Sprintf("⛔ 没有使用 /%s 的权限，/perms 查看可用命令", cmd))
+		return
+	}
 
 	switch cmd {
 	case "start", "help":
+		if ip := startCheckIP(args); ip != "" && allowed.allows("checkip") {
+			b.checkIP(msg.Chat.ID, ip)
+			return
+		}
 		b.handleHelp(msg.Chat.ID)
 	case "accounts":
 		b.showAccounts(msg.Chat.ID)
@@ -248,10 +599,12 @@ func (b *Bot) handleMessage(msg *tgbotapi.Message) {
 		} else {
 			b.showAccounts(msg.Chat.ID)
 		}
+	case "region":
+		b.handleRegion(msg.Chat.ID, args)
 	case "newip":
 		b.createIP(msg.Chat.ID)
 	case "listip":
-		b.showIPList(msg.Chat.ID)
+		b.showIPListWithHighlight(msg.Chat.ID, "", nil, args)
 	case "delip":
 		if args != "" {
 			b.deleteIP(msg.Chat.ID, args)
@@ -259,19 +612,106 @@ func (b *Bot) handleMessage(msg *tgbotapi.Message) {
 			b.showIPList(msg.Chat.ID)
 		}
 	case "checkip":
-		if args != "" {
-			b.checkIP(msg.Chat.ID, args)
-		} else {
-			b.reply(msg.Chat.ID, "用法: /checkip <IP地址>\n例如: /checkip 8.8.8.8")
+		switch ips := checkIPArgs(args); {
+		case len(ips) > 1:
+			b.checkIPs(msg.Chat.ID, ips)
+		case len(ips) == 1:
+			b.checkIP(msg.Chat.ID, ips[0])
+		default:
+			b.reply(msg.Chat.ID, "用法: /checkip <IP地址> [更多IP...]\n例如: /checkip 8.8.8.8")
 		}
+	case "compare":
+		b.handleCompare(msg.Chat.ID, args)
 	case "autoip":
-		b.startAutoIPWizard(msg.Chat.ID)
+		b.startAutoIPWizard(msg.Chat.ID, msg.From.ID)
 	case "autovps":
-		b.startAutoVPSWizard(msg.Chat.ID)
+		b.startAutoVPSWizard(msg.Chat.ID, msg.From.ID)
 	case "stopauto":
-		b.stopAutoApply(msg.Chat.ID)
+		if args == "soft" {
+			b.softStopAutoApply(msg.Chat.ID)
+		} else {
+			b.stopAutoApply(msg.Chat.ID)
+		}
+	case "pauseauto":
+		b.pauseAutoApply(msg.Chat.ID)
+	case "resumeauto":
+		b.resumeAutoApply(msg.Chat.ID)
+	case "tune":
+		b.showTune(msg.Chat.ID)
 	case "stopvps":
 		b.stopAutoVPS(msg.Chat.ID)
+	case "profiles":
+		b.handleProfiles(msg.Chat.ID, args)
+	case "status":
+		b.showStatus(msg.Chat.ID)
+	case "cancel":
+		b.cancelPending(msg.Chat.ID)
+	case "backupvps":
+		b.backupVPS(msg.Chat.ID, args)
+	case "restorevps":
+		b.restoreVPS(msg.Chat.ID)
+	case "volbackup":
+		b.showVolumeBackups(msg.Chat.ID)
+	case "billing":
+		b.showBilling(msg.Chat.ID)
+	case "metrics":
+		b.showMetrics(msg.Chat.ID, args)
+	case "network":
+		b.showNetwork(msg.Chat.ID, args)
+	case "exec":
+		if !allowed.All {
+			b.reply(msg.Chat.ID, "⛔ /exec 仅限管理员使用")
+			return
+		}
+		b.showExec(msg.Chat.ID, args)
+	case "provision":
+		b.handleProvision(msg.Chat.ID, args)
+	case "deploy":
+		b.handleDeploy(msg.Chat.ID, args)
+	case "jobs":
+		b.showJobs(msg.Chat.ID)
+	case "regions":
+		b.showRegions(msg.Chat.ID)
+	case "exportips":
+		b.exportArchive(msg.Chat.ID, args)
+	case "capacity":
+		b.showCapacity(msg.Chat.ID)
+	case "rename":
+		b.handleRename(msg.Chat.ID, msg.From.ID, args)
+	case "tag":
+		b.handleTag(msg.Chat.ID, args)
+	case "pin":
+		b.pinIP(msg.Chat.ID, args)
+	case "unpin":
+		b.unpinIP(msg.Chat.ID, args)
+	case "watch":
+		b.handleWatch(msg.Chat.ID, args)
+	case "unwatch":
+		b.handleUnwatch(msg.Chat.ID, args)
+	case "orphans":
+		b.showOrphans(msg.Chat.ID)
+	case "protected":
+		b.showProtectedIPs(msg.Chat.ID)
+	case "whoami":
+		b.showWhoami(msg.Chat.ID, args)
+	case "health":
+		b.showHealth(msg.Chat.ID)
+	case "addaccount":
+		b.startAddAccount(msg.Chat.ID)
+	case "rmaccount":
+		b.handleAccountCommand(msg.Chat.ID, "rm", args)
+	case "disableaccount":
+		b.handleAccountCommand(msg.Chat.ID, "disable", args)
+	case "enableaccount":
+		b.handleAccountCommand(msg.Chat.ID, "enable", args)
+	case "rotatekey":
+		b.handleRotateKey(msg.Chat.ID, args)
+	case "settings":
+		b.showSettings(msg.Chat.ID)
+	case "loglevel":
+		b.handleLogLevel(msg.Chat.ID, args)
+	case "perms":
+		b.showPerms(msg, args)
 	case "id":
 		b.reply(msg.Chat.ID, fmt.Sprintf("Your ID: %d", msg.From.ID))
 	default:
@@ -283,27 +723,109 @@ func (b *Bot) handleHelp(chatID int64) {
 	help := fmt.Sprintf(`🤖 *OCI IP Bot*
 
 /accounts - 选择账号
+/region [区域] - 切换当前账号的区域
 /newip - 创建预留IP
-/listip - 列出IP
-/checkip <IP> - 检测IP纯净度
+/listip [bot|k=v] - 列出IP
+/checkip <IP> [更多IP] - 检测IP纯净度，多个IP在同一浏览器中依次检测
+/compare <IP1> <IP2> ... - 对比多个IP
 /autoip - 自动刷IP
-/stopauto - 停止自动刷IP
+/stopauto [soft] - 停止自动刷IP (soft: 完成本轮后停止)
+/pauseauto - 暂停自动刷IP
+/resumeauto - 继续自动刷IP
+/tune - 调整运行中的自动刷IP的阈值和间隔
 /autovps - 自动申请VPS
 /stopvps - 停止自动申请VPS
+/profiles - 自动刷IP方案
+/status - 自动任务状态
+/cancel - 取消进行中的配置或输入
+/backupvps - 备份实例为镜像
+/restorevps - 从镜像恢复实例
+/volbackup - 引导卷备份
+/rename - 重命名IP或实例
+/tag <IP|实例> [k=v] [-k] - 查看/设置标签
+/pin <IP> - 固定IP，禁止删除
+/unpin <IP> - 取消固定IP
+/watch [IP 备注] - 关注外部IP的纯净度
+/unwatch <IP> - 取消关注外部IP
+/orphans - 未绑定的预留IP
+/protected - 受保护的IP
+/billing - 费用与免费额度
+/metrics - 实例监控
+/network - 实例网络
+/exec [实例 命令] - 在实例上执行命令
+/provision <实例> [配方] - 在实例上部署配方
+/deploy <账号> [arm|amd|预设] [方案] - 抢实例、刷IP、绑定并部署配方
+/jobs - 未完成的多步任务，可继续或放弃
+/regions - 各区域IP纯净度
+/exportips [天数] - 导出IP检测记录 (CSV)
+/capacity - 各可用域实例容量统计
+/whoami [账号] - 租户与用户信息
+/health - 检查账号凭据
+/addaccount - 添加账号
+/rmaccount [账号] - 删除账号
+/disableaccount [账号] - 停用账号
+/enableaccount [账号] - 启用账号
+/rotatekey [账号] - 轮换API密钥
+/settings - 运行设置
+/perms [用户ID] - 查看权限
+/loglevel - 查看/设置日志级别
 
 📍 *当前:* [%s] %s`, b.currentClient.AccountName(), b.currentClient.Region())
 
 	b.replyMarkdown(chatID, help)
 }
 
+// handleLogLevel shows or changes the runtime log level
+func (b *Bot) handleLogLevel(chatID int64, args string) {
+	if args == "" {
+		b.reply(chatID, fmt.Sprintf("当前日志级别: %s\n用法: /loglevel <debug|info|warn|error>", logging.GetLevel()))
+		return
+	}
+
+	level, err := logging.ParseLevel(args)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	logging.SetLevel(level)
+	logger.Infof("Log level changed to %s", level)
+	b.reply(chatID, fmt.Sprintf("✅ 日志级别已设置为: %s", level))
+}
+
+// client returns the service of an account
+func (b *Bot) client(name string) (oci.Service, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	client, ok := b.clients[name]
+	return client, ok
+}
+
+// sortedClients returns the services of all accounts ordered by name
+func (b *Bot) sortedClients() []oci.Service {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	clients := make([]oci.Service, 0, len(b.clients))
+	for _, client := range b.clients {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].AccountName() < clients[j].AccountName() })
+	return clients
+}
+
 // showAccounts shows account list with clickable buttons
 func (b *Bot) showAccounts(chatID int64) {
 	var buttons [][]tgbotapi.InlineKeyboardButton
 
-	for name, client := range b.clients {
+	b.mu.Lock()
+	current := b.currentClient
+	b.mu.Unlock()
+
+	for _, client := range b.sortedClients() {
+		name := client.AccountName()
 		label := fmt.Sprintf("%s (%s)", name, client.Region())
-		if client == b.currentClient {
-			label = "✅ " + label
+		if name == current.AccountName() {
+			label = fmt.Sprintf("✅ %s (%s)", name, current.Region())
 		}
 		btn := tgbotapi.NewInlineKeyboardButtonData(label, "use:"+name)
 		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
@@ -312,12 +834,12 @@ func (b *Bot) showAccounts(chatID int64) {
 	msg := tgbotapi.NewMessage(chatID, "� *选择账号*")
 	msg.ParseMode = tgbotapi.ModeMarkdown
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 // switchAccount switches to the specified account and shows IP list
 func (b *Bot) switchAccount(chatID int64, name string) {
-	client, ok := b.clients[name]
+	client, ok := b.client(name)
 	if !ok {
 		b.reply(chatID, "❌ 账号不存在: "+name)
 		return
@@ -333,13 +855,14 @@ func (b *Bot) switchAccount(chatID int64, name string) {
 
 // showIPList shows IP list with query and delete buttons for each IP
 func (b *Bot) showIPList(chatID int64) {
-	b.showIPListWithHighlight(chatID, "", nil)
+	b.showIPListWithHighlight(chatID, "", nil, "")
 }
 
 // showIPListWithHighlight shows IP list with optional highlight for a newly created IP
 // highlightIP: the IP address to mark as new (empty string means no highlight)
 // useClient: optional client to use (nil means use currentClient)
-func (b *Bot) showIPListWithHighlight(chatID int64, highlightIP string, useClient *oci.Client) {
+// filter: which IPs to show, see matchesIPFilter
+func (b *Bot) showIPListWithHighlight(chatID int64, highlightIP string, useClient oci.Service, filter string) {
 	b.mu.Lock()
 	client := useClient
 	if client == nil {
@@ -347,73 +870,103 @@ func (b *Bot) showIPListWithHighlight(chatID int64, highlightIP string, useClien
 	}
 	b.mu.Unlock()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := b.withTimeout(callTimeout)
 	defer cancel()
 
 	ips, err := client.ListReservedIPs(ctx)
 	if err != nil {
-		b.reply(chatID, "❌ "+err.Error())
+		b.reply(chatID, errorText(err))
 		return
 	}
 
 	header := fmt.Sprintf("📋 *[%s]*\n%s\n\n", client.AccountName(), client.Region())
 
+	if filter != "" {
+		header = fmt.Sprintf("📋 *[%s]* (%s)\n%s\n\n", client.AccountName(), filterLabel(filter), client.Region())
+		var matched []oci.PublicIPInfo
+		for _, ip := range ips {
+			if matchesIPFilter(ip, filter) {
+				matched = append(matched, ip)
+			}
+		}
+		ips = matched
+	}
+
 	if len(ips) == 0 {
 		// No IPs - show create button only
 		btn := tgbotapi.NewInlineKeyboardButtonData("➕ 申请IP", "newip:1")
 		keyboard := tgbotapi.NewInlineKeyboardMarkup([]tgbotapi.InlineKeyboardButton{btn})
+		if filter != "" {
+			allBtn := tgbotapi.NewInlineKeyboardButtonData("📋 显示全部", "refresh:1")
+			keyboard = tgbotapi.NewInlineKeyboardMarkup([]tgbotapi.InlineKeyboardButton{btn, allBtn})
+		}
 
 		msg := tgbotapi.NewMessage(chatID, header+"暂无预留IP")
 		msg.ParseMode = tgbotapi.ModeMarkdown
 		msg.ReplyMarkup = keyboard
-		b.api.Send(msg)
+		b.sendIn(topicIPList, msg)
 		return
 	}
 
 	var sb strings.Builder
 	sb.WriteString(header)
 
+	account := b.cfg.GetAccount(client.AccountName())
+	canProtect := account != nil && len(account.ProtectedCompartments) > 0
+
 	var buttons [][]tgbotapi.InlineKeyboardButton
 	for _, ip := range ips {
 		// Check if we have cached purity info for this IP
-		b.mu.Lock()
-		cache, hasPurity := b.purityCache[ip.IPAddress]
-		b.mu.Unlock()
+		cache, hasPurity := b.checker.Last(ip.IPAddress)
 
 		// Check if this is the highlighted (newly created) IP
 		isNew := highlightIP != "" && ip.IPAddress == highlightIP
+		bullet := "•"
+		if isNew {
+			bullet = "🆕"
+		} else if b.isPinned(ip.IPAddress) {
+			bullet = "📌"
+		}
+
+		// Show custom names given with /rename; a code span keeps Markdown from breaking on _ or *
+		name := ""
+		if ip.DisplayName != "" && !defaultIPName.MatchString(ip.DisplayName) {
+			name = " " + codeSpan(ip.DisplayName)
+		}
 
 		if hasPurity {
 			// Show IP with purity info (score/type/source)
-			if isNew {
-				sb.WriteString(fmt.Sprintf("🆕 `%s` (%s/%s/%s)\n", ip.IPAddress, cache.PurityScore, cache.IPType, cache.IsNative))
-			} else {
-				sb.WriteString(fmt.Sprintf("• `%s` (%s/%s/%s)\n", ip.IPAddress, cache.PurityScore, cache.IPType, cache.IsNative))
-			}
+			sb.WriteString(fmt.Sprintf("%s `%s`%s (%s/%s/%s)\n", bullet, ip.IPAddress, name, cache.PurityScore, cache.IPType, cache.IsNative))
 		} else {
 			// Show IP without purity info
-			if isNew {
-				sb.WriteString(fmt.Sprintf("🆕 `%s`\n", ip.IPAddress))
-			} else {
-				sb.WriteString(fmt.Sprintf("• `%s`\n", ip.IPAddress))
-			}
+			sb.WriteString(fmt.Sprintf("%s `%s`%s\n", bullet, ip.IPAddress, name))
 		}
 
 		// Create query and delete buttons for each IP
 		checkBtn := tgbotapi.NewInlineKeyboardButtonData("🔍 查询", "check:"+ip.IPAddress)
 		delBtn := tgbotapi.NewInlineKeyboardButtonData("🗑 删除", "del:"+ip.IPAddress)
-		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{checkBtn, delBtn})
+		row := []tgbotapi.InlineKeyboardButton{checkBtn, delBtn}
+		if canProtect {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData("🔒 保护", "mvip:"+b.callbackRef(ip.ID)))
+		}
+		buttons = append(buttons, row)
 	}
 
-	// Add create and refresh buttons at the bottom
+	// Add create, bulk delete and refresh buttons at the bottom
 	createBtn := tgbotapi.NewInlineKeyboardButtonData("➕ 申请IP", "newip:1")
-	refreshBtn := tgbotapi.NewInlineKeyboardButtonData("🔄 刷新", "refresh:1")
-	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{createBtn, refreshBtn})
+	selectBtn := tgbotapi.NewInlineKeyboardButtonData("☑️ 批量删除", "sel:start")
+	refreshBtn := tgbotapi.NewInlineKeyboardButtonData("🔄 刷新", "refresh:"+refreshFilter(filter))
+	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{createBtn, selectBtn, refreshBtn})
+	if filter == "" {
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("🤖 仅显示bot创建的IP", "refresh:bot")})
+	} else {
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("📋 显示全部", "refresh:1")})
+	}
 
 	msg := tgbotapi.NewMessage(chatID, sb.String())
 	msg.ParseMode = tgbotapi.ModeMarkdown
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	b.api.Send(msg)
+	b.sendIn(topicIPList, msg)
 }
 
 // createIP creates a new reserved IP
@@ -422,35 +975,46 @@ func (b *Bot) createIP(chatID int64) {
 	client := b.currentClient
 	b.mu.Unlock()
 
+	release, err := b.acquireAccount(b.runCtx, chatID, client.AccountName())
+	if err != nil {
+		return
+	}
 	b.reply(chatID, fmt.Sprintf("⏳ [%s] 正在创建...", client.AccountName()))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	ctx, cancel := b.withTimeout(batchTimeout)
 	defer cancel()
 
 	displayName := fmt.Sprintf("tg-%d", time.Now().Unix())
-	publicIP, err := client.CreateReservedIP(ctx, displayName)
+	var publicIP *oci.PublicIPInfo
+	err = retryCreate(ctx, attemptID(client.AccountName(), displayName, newNonce()), createTimeout, func(ctx context.Context) (err error) {
+		publicIP, err = client.CreateReservedIP(ctx, displayName)
+		return err
+	})
 	if err != nil {
-		b.reply(chatID, "❌ "+err.Error())
+		release()
+		b.reply(chatID, errorText(err))
 		return
 	}
 
-	publicIP, err = client.WaitForIPReady(ctx, publicIP.ID, 60*time.Second)
+	publicIP, err = client.WaitForIPReady(ctx, publicIP.ID, ipReadyTimeout)
+	release()
 	if err != nil {
-		b.reply(chatID, "❌ "+err.Error())
+		b.reply(chatID, errorText(err))
 		return
 	}
+	b.publish(events.Event{Type: events.IPCreated, Account: client.AccountName(), IP: publicIP.IPAddress})
 
 	// Check if auto-check is enabled
-	if b.cfg.AutoCheckIP {
+	if b.currentConfig().AutoCheckIP {
 		b.reply(chatID, fmt.Sprintf("✅ IP 创建成功: `%s`\n🔍 正在检测纯净度...", publicIP.IPAddress))
 
-		checkCtx, checkCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		checkCtx, checkCancel := b.withTimeout(checkTimeout)
 		defer checkCancel()
 
-		info, err := ippure.Check(checkCtx, publicIP.IPAddress)
+		info, err := b.checker.Check(checkCtx, publicIP.IPAddress)
 		if err != nil {
 			text := fmt.Sprintf("✅ *创建成功*\n\nIP: `%s`\n\n⚠️ 纯净度检测失败: %s\n\n📍 [%s] %s",
-				publicIP.IPAddress, err.Error(), client.AccountName(), client.Region())
+				publicIP.IPAddress, escapeMarkdown(err.Error()), client.AccountName(), client.Region())
 			checkBtn := tgbotapi.NewInlineKeyboardButtonURL("🔍 手动检测", "https://ippure.com/?ip="+publicIP.IPAddress)
 			refreshBtn := tgbotapi.NewInlineKeyboardButtonData("📋 查看列表", "refresh:1")
 			keyboard := tgbotapi.NewInlineKeyboardMarkup(
@@ -460,18 +1024,10 @@ func (b *Bot) createIP(chatID int64) {
 			msg := tgbotapi.NewMessage(chatID, text)
 			msg.ParseMode = tgbotapi.ModeMarkdown
 			msg.ReplyMarkup = keyboard
-			b.api.Send(msg)
+			b.send(msg)
 			return
 		}
-
-		// Cache the purity info
-		b.mu.Lock()
-		b.purityCache[publicIP.IPAddress] = &IPPurityCache{
-			PurityScore: info.PurityScore,
-			IPType:      info.IPType,
-			IsNative:    info.IsNative,
-		}
-		b.mu.Unlock()
+		b.recordPurity(client.Region(), info)
 
 		text := fmt.Sprintf(`✅ *创建成功*
 
@@ -495,7 +1051,7 @@ IP: `+"`%s`"+`
 		msg := tgbotapi.NewMessage(chatID, text)
 		msg.ParseMode = tgbotapi.ModeMarkdown
 		msg.ReplyMarkup = keyboard
-		b.api.Send(msg)
+		b.send(msg)
 		return
 	}
 
@@ -513,21 +1069,33 @@ IP: `+"`%s`"+`
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = tgbotapi.ModeMarkdown
 	msg.ReplyMarkup = keyboard
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 // deleteIP deletes the specified IP
 func (b *Bot) deleteIP(chatID int64, ipAddr string) {
+	if b.isPinned(ipAddr) {
+		b.reply(chatID, fmt.Sprintf("📌 %s 已固定，请先 /unpin %s", ipAddr, ipAddr))
+		return
+	}
+
 	b.mu.Lock()
 	client := b.currentClient
 	b.mu.Unlock()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Hold the queue across list and delete so a running task can't swap the IP out underneath
+	release, err := b.acquireAccount(b.runCtx, chatID, client.AccountName())
+	if err != nil {
+		return
+	}
+	defer release()
+
+	ctx, cancel := b.withTimeout(callTimeout)
 	defer cancel()
 
 	ips, err := client.ListReservedIPs(ctx)
 	if err != nil {
-		b.reply(chatID, "❌ "+err.Error())
+		b.reply(chatID, errorText(err))
 		return
 	}
 
@@ -546,7 +1114,7 @@ func (b *Bot) deleteIP(chatID int64, ipAddr string) {
 
 	err = client.DeleteReservedIP(ctx, targetID)
 	if err != nil {
-		b.reply(chatID, "❌ "+err.Error())
+		b.reply(chatID, deleteErrorText(ipAddr, err))
 		return
 	}
 
@@ -566,24 +1134,22 @@ func (b *Bot) checkIP(chatID int64, ipAddr string) {
 
 	b.reply(chatID, fmt.Sprintf("🔍 正在检测 %s ...", ipAddr))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := b.withTimeout(checkTimeout)
 	defer cancel()
 
-	info, err := ippure.Check(ctx, ipAddr)
+	registration := make(chan ipRegistration, 1)
+	go func() { registration <- lookupRegistration(ctx, ipAddr) }()
+	latencies := make(chan []latency.Result, 1)
+	go func() { latencies <- b.measureLatency(ctx, ipAddr) }()
+	routes := make(chan []route.Result, 1)
+	go func() { routes <- b.detectRoutes(ctx, ipAddr) }()
+
+	info, err := b.checker.Check(ctx, ipAddr)
 	if err != nil {
 		b.reply(chatID, "❌ 检测失败: "+err.Error())
 		return
 	}
 
-	// Cache the purity info
-	b.mu.Lock()
-	b.purityCache[ipAddr] = &IPPurityCache{
-		PurityScore: info.PurityScore,
-		IPType:      info.IPType,
-		IsNative:    info.IsNative,
-	}
-	b.mu.Unlock()
-
 	text := fmt.Sprintf(`🔍 *IP 纯净度检测*
 
 IP: `+"`%s`"+`
@@ -595,6 +1161,16 @@ IP: `+"`%s`"+`
 		info.PurityScore, info.PurityLevel,
 		info.IPType,
 		info.IsNative)
+	text += cachedNote(info)
+	if reg := (<-registration).markdown(); reg != "" {
+		text += "\n" + reg
+	}
+	if line := latencyMarkdown(<-latencies); line != "" {
+		text += "\n" + line
+	}
+	if line := routesMarkdown(<-routes); line != "" {
+		text += "\n" + line
+	}
 
 	b.replyMarkdown(chatID, text)
 }
@@ -609,24 +1185,22 @@ func (b *Bot) checkIPFromCallback(chatID int64, ipAddr string) {
 
 	b.reply(chatID, fmt.Sprintf("🔍 正在检测 %s ...", ipAddr))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := b.withTimeout(checkTimeout)
 	defer cancel()
 
-	info, err := ippure.Check(ctx, ipAddr)
+	registration := make(chan ipRegistration, 1)
+	go func() { registration <- lookupRegistration(ctx, ipAddr) }()
+	latencies := make(chan []latency.Result, 1)
+	go func() { latencies <- b.measureLatency(ctx, ipAddr) }()
+	routes := make(chan []route.Result, 1)
+	go func() { routes <- b.detectRoutes(ctx, ipAddr) }()
+
+	info, err := b.checker.Check(ctx, ipAddr)
 	if err != nil {
 		b.reply(chatID, "❌ 检测失败: "+err.Error())
 		return
 	}
 
-	// Cache the purity info
-	b.mu.Lock()
-	b.purityCache[ipAddr] = &IPPurityCache{
-		PurityScore: info.PurityScore,
-		IPType:      info.IPType,
-		IsNative:    info.IsNative,
-	}
-	b.mu.Unlock()
-
 	// Show detection result
 	text := fmt.Sprintf(`✅ *检测完成*
 
@@ -639,6 +1213,16 @@ IP: `+"`%s`"+`
 		info.PurityScore, info.PurityLevel,
 		info.IPType,
 		info.IsNative)
+	text += cachedNote(info)
+	if reg := (<-registration).markdown(); reg != "" {
+		text += "\n" + reg
+	}
+	if line := latencyMarkdown(<-latencies); line != "" {
+		text += "\n" + line
+	}
+	if line := routesMarkdown(<-routes); line != "" {
+		text += "\n" + line
+	}
 
 	b.replyMarkdown(chatID, text)
 
@@ -648,20 +1232,20 @@ IP: `+"`%s`"+`
 
 func (b *Bot) reply(chatID int64, text string) {
 	msg := tgbotapi.NewMessage(chatID, text)
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 func (b *Bot) replyMarkdown(chatID int64, text string) {
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = tgbotapi.ModeMarkdown
 	msg.DisableWebPagePreview = true
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 // ========== Auto-Apply IP Wizard ==========
 
 // startAutoIPWizard starts the auto-apply IP configuration wizard
-func (b *Bot) startAutoIPWizard(chatID int64) {
+func (b *Bot) startAutoIPWizard(chatID, userID int64) {
 	// Check if auto-apply is already running
 	b.mu.Lock()
 	if b.autoApply != nil && b.autoApply.Active {
@@ -671,15 +1255,38 @@ func (b *Bot) startAutoIPWizard(chatID int64) {
 	}
 
 	// Initialize wizard
-	b.autoWizard = &AutoApplyWizard{
-		Step:   1,
-		ChatID: chatID,
+	wizard := &AutoApplyWizard{
+		Step:    1,
+		ChatID:  chatID,
+		UserID:  userID,
+		Expires: time.Now().Add(b.wizardTimeout()),
+	}
+	if len(b.profiles) > 0 {
+		wizard.Step = 0
 	}
+	b.autoWizards[chatID] = wizard
 	b.mu.Unlock()
 
-	// Step 1: Show account selection
+	if wizard.Step == 0 {
+		b.showProfileStep(chatID)
+		return
+	}
+	b.showAccountStep(chatID)
+}
+
+// showAccountStep shows account selection (Step 1), with a shortcut for
+// accounts that have configured presets
+func (b *Bot) showAccountStep(chatID int64) {
 	var buttons [][]tgbotapi.InlineKeyboardButton
-	for name, client := range b.clients {
+	clients := b.sortedClients()
+	for _, client := range clients {
+		if name := client.AccountName(); b.autoIPPreset(name) != nil {
+			btn := tgbotapi.NewInlineKeyboardButtonData("⚡ 使用默认配置 ("+name+")", "autoip:preset:"+name)
+			buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
+		}
+	}
+	for _, client := range clients {
+		name := client.AccountName()
 		label := fmt.Sprintf("%s (%s)", name, client.Region())
 		btn := tgbotapi.NewInlineKeyboardButtonData(label, "autoip:account:"+name)
 		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
@@ -687,16 +1294,16 @@ func (b *Bot) startAutoIPWizard(chatID int64) {
 	cancelBtn := tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "autoip:cancel:")
 	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{cancelBtn})
 
-	msg := tgbotapi.NewMessage(chatID, "🔄 *自动刷IP配置* (1/5)\n\n请选择账号:")
+	msg := tgbotapi.NewMessage(chatID, "🔄 *自动刷IP配置* (1/6)\n\n请选择账号:")
 	msg.ParseMode = tgbotapi.ModeMarkdown
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 // handleAutoIPCallback handles auto-apply wizard callbacks
 func (b *Bot) handleAutoIPCallback(chatID int64, param string, parts []string) {
 	b.mu.Lock()
-	wizard := b.autoWizard
+	wizard := b.autoWizardLocked(chatID)
 	b.mu.Unlock()
 
 	if wizard == nil {
@@ -714,16 +1321,68 @@ func (b *Bot) handleAutoIPCallback(chatID int64, param string, parts []string) {
 	switch subAction {
 	case "cancel":
 		b.mu.Lock()
-		b.autoWizard = nil
+		delete(b.autoWizards, chatID)
 		b.mu.Unlock()
 		b.reply(chatID, "❌ 已取消自动刷IP配置")
 
+	case "preset":
+		// Step 1 -> confirmation, using the account's configured defaults
+		preset := b.autoIPPreset(value)
+		if preset == nil {
+			b.reply(chatID, "❌ 账号未配置默认刷IP参数: "+value)
+			return
+		}
+		b.mu.Lock()
+		wizard.AccountName = value
+		wizard.PurityThreshold = preset.PurityThreshold
+		wizard.NativeRequired = preset.NativeRequired
+		wizard.TypeRequired = preset.TypeRequired
+		wizard.MatchMode = preset.MatchMode
+		wizard.Step = 7 // Ready to confirm
+		b.mu.Unlock()
+		b.showConfirmation(chatID, preset.IntervalMin, preset.IntervalMax)
+
+	case "profile":
+		// Step 0 -> 1 with the criteria of a saved profile
+		b.mu.Lock()
+		profile, ok := b.profiles[value]
+		if ok {
+			wizard.Profile = &profile
+			wizard.PurityThreshold = profile.PurityThreshold
+			wizard.NativeRequired = profile.NativeRequired
+			wizard.TypeRequired = profile.typeRequired()
+			wizard.MatchMode = profile.MatchMode
+			wizard.Step = 1
+		}
+		b.mu.Unlock()
+		if !ok {
+			b.reply(chatID, "❌ 方案不存在: "+value)
+			return
+		}
+		b.showAccountStep(chatID)
+
+	case "manual":
+		// Step 0 -> 1
+		b.mu.Lock()
+		wizard.Step = 1
+		b.mu.Unlock()
+		b.showAccountStep(chatID)
+
 	case "account":
-		// Step 1 -> 2
+		// Step 1 -> 2, or straight to confirmation with a profile
 		b.mu.Lock()
 		wizard.AccountName = value
-		wizard.Step = 2
+		profile := wizard.Profile
+		if profile != nil {
+			wizard.Step = 7 // Ready to confirm
+		} else {
+			wizard.Step = 2
+		}
 		b.mu.Unlock()
+		if profile != nil {
+			b.showConfirmation(chatID, profile.IntervalMin, profile.IntervalMax)
+			return
+		}
 		b.showPurityStep(chatID)
 
 	case "purity":
@@ -741,13 +1400,21 @@ func (b *Bot) handleAutoIPCallback(chatID int64, param string, parts []string) {
 		wizard.NativeRequired = value
 		wizard.Step = 4
 		b.mu.Unlock()
+		b.showTypeStep(chatID)
+
+	case "type":
+		// Step 4 -> 5
+		b.mu.Lock()
+		wizard.TypeRequired = value
+		wizard.Step = 5
+		b.mu.Unlock()
 		b.showMatchModeStep(chatID)
 
 	case "mode":
-		// Step 4 -> 5
+		// Step 5 -> 6
 		b.mu.Lock()
 		wizard.MatchMode = value
-		wizard.Step = 5
+		wizard.Step = 6
 		b.mu.Unlock()
 		b.showIntervalStep(chatID)
 
@@ -762,10 +1429,18 @@ func (b *Bot) handleAutoIPCallback(chatID int64, param string, parts []string) {
 		// Keep existing IPs and start
 		b.mu.Lock()
 		config := b.autoApply
-		client, _ := b.clients[config.AccountName]
 		b.mu.Unlock()
-		b.doStartAutoApply(chatID, client, config)
+		b.doStartAutoApply(chatID, config)
+	}
+}
+
+// autoIPPreset returns the autoip defaults configured for an account, or nil
+func (b *Bot) autoIPPreset(accountName string) *config.AutoIPPreset {
+	account := b.accountConfig(accountName)
+	if _, ok := b.client(accountName); !ok || account == nil {
+		return nil
 	}
+	return account.AutoIP
 }
 
 // showPurityStep shows purity threshold selection (Step 2)
@@ -783,10 +1458,10 @@ func (b *Bot) showPurityStep(chatID int64) {
 		{tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "autoip:cancel:")},
 	}
 
-	msg := tgbotapi.NewMessage(chatID, "🔄 *自动刷IP配置* (2/5)\n\n请选择纯净度阈值 (越低越纯净):")
+	msg := tgbotapi.NewMessage(chatID, "🔄 *自动刷IP配置* (2/6)\n\n请选择纯净度阈值 (越低越纯净):")
 	msg.ParseMode = tgbotapi.ModeMarkdown
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 // showNativeStep shows native IP requirement selection (Step 3)
@@ -800,13 +1475,30 @@ func (b *Bot) showNativeStep(chatID int64) {
 		{tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "autoip:cancel:")},
 	}
 
-	msg := tgbotapi.NewMessage(chatID, "🔄 *自动刷IP配置* (3/5)\n\n请选择IP来源要求:")
+	msg := tgbotapi.NewMessage(chatID, "🔄 *自动刷IP配置* (3/6)\n\n请选择IP来源要求:")
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
+
+// showTypeStep shows IP type requirement selection (Step 4)
+func (b *Bot) showTypeStep(chatID int64) {
+	buttons := [][]tgbotapi.InlineKeyboardButton{
+		{
+			tgbotapi.NewInlineKeyboardButtonData("🏘 住宅IP", "autoip:type:住宅IP"),
+			tgbotapi.NewInlineKeyboardButtonData("🏢 机房IP", "autoip:type:机房IP"),
+		},
+		{tgbotapi.NewInlineKeyboardButtonData("🔓 不限", "autoip:type:any")},
+		{tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "autoip:cancel:")},
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "🔄 *自动刷IP配置* (4/6)\n\n请选择IP类型要求:")
 	msg.ParseMode = tgbotapi.ModeMarkdown
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	b.api.Send(msg)
+	b.send(msg)
 }
 
-// showMatchModeStep shows match mode selection (Step 4)
+// showMatchModeStep shows match mode selection (Step 5)
 func (b *Bot) showMatchModeStep(chatID int64) {
 	buttons := [][]tgbotapi.InlineKeyboardButton{
 		{tgbotapi.NewInlineKeyboardButtonData("✅ 满足全部条件", "autoip:mode:all")},
@@ -814,15 +1506,15 @@ func (b *Bot) showMatchModeStep(chatID int64) {
 		{tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "autoip:cancel:")},
 	}
 
-	msg := tgbotapi.NewMessage(chatID, "🔄 *自动刷IP配置* (4/5)\n\n请选择匹配模式:")
+	msg := tgbotapi.NewMessage(chatID, "🔄 *自动刷IP配置* (5/6)\n\n请选择匹配模式:")
 	msg.ParseMode = tgbotapi.ModeMarkdown
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	b.api.Send(msg)
+	b.send(msg)
 }
 
-// showIntervalStep asks for interval input (Step 5)
+// showIntervalStep asks for interval input (Step 6)
 func (b *Bot) showIntervalStep(chatID int64) {
-	msg := tgbotapi.NewMessage(chatID, `🔄 *自动刷IP配置* (5/5)
+	msg := tgbotapi.NewMessage(chatID, `🔄 *自动刷IP配置* (6/6)
 
 请输入操作间隔时间 (秒):
 
@@ -831,7 +1523,7 @@ func (b *Bot) showIntervalStep(chatID int64) {
 
 _直接发送消息即可_`)
 	msg.ParseMode = tgbotapi.ModeMarkdown
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 // handleIntervalInput handles the interval text input
@@ -875,9 +1567,9 @@ func (b *Bot) handleIntervalInput(chatID int64, text string) {
 	}
 
 	b.mu.Lock()
-	wizard := b.autoWizard
+	wizard := b.autoWizardLocked(chatID)
 	if wizard != nil {
-		wizard.Step = 6 // Ready to confirm
+		wizard.Step = 7 // Ready to confirm
 	}
 	b.mu.Unlock()
 
@@ -888,7 +1580,7 @@ func (b *Bot) handleIntervalInput(chatID int64, text string) {
 // showConfirmation shows the final confirmation
 func (b *Bot) showConfirmation(chatID int64, minInterval, maxInterval int) {
 	b.mu.Lock()
-	wizard := b.autoWizard
+	wizard := b.autoWizardLocked(chatID)
 	b.mu.Unlock()
 
 	if wizard == nil {
@@ -902,6 +1594,7 @@ func (b *Bot) showConfirmation(chatID int64, minInterval, maxInterval int) {
 		AccountName:     wizard.AccountName,
 		PurityThreshold: wizard.PurityThreshold,
 		NativeRequired:  wizard.NativeRequired,
+		TypeRequired:    wizard.TypeRequired,
 		MatchMode:       wizard.MatchMode,
 		IntervalMin:     minInterval,
 		IntervalMax:     maxInterval,
@@ -920,11 +1613,26 @@ func (b *Bot) showConfirmation(chatID int64, minInterval, maxInterval int) {
 		nativeText = "不限"
 	}
 
+	typeText := wizard.TypeRequired
+	if wizard.TypeRequired == "any" {
+		typeText = "不限"
+	}
+
 	modeText := "满足全部条件"
 	if wizard.MatchMode == "any" {
 		modeText = "满足任一条件"
 	}
 
+	var prefixText string
+	if account := b.accountConfig(wizard.AccountName); account != nil {
+		if len(account.AutoIPPrefixes) > 0 {
+			prefixText += "🎯 *目标地址段:* " + escapeMarkdown(strings.Join(account.AutoIPPrefixes, ", ")) + "\n"
+		}
+		if len(account.AutoIPAvoidPrefixes) > 0 {
+			prefixText += "🚫 *避开地址段:* " + escapeMarkdown(strings.Join(account.AutoIPAvoidPrefixes, ", ")) + "\n"
+		}
+	}
+
 	intervalText := fmt.Sprintf("%d秒", minInterval)
 	if minInterval != maxInterval {
 		intervalText = fmt.Sprintf("%d-%d秒 (随机)", minInterval, maxInterval)
@@ -935,10 +1643,11 @@ func (b *Bot) showConfirmation(chatID int64, minInterval, maxInterval int) {
 📍 *账号:* %s
 📊 *纯净度:* %s
 🌐 *来源:* %s
+🏢 *类型:* %s
 🔀 *匹配模式:* %s
 ⏱ *间隔时间:* %s
-
-确认开始自动刷IP?`, wizard.AccountName, purityText, nativeText, modeText, intervalText)
+%s
+确认开始自动刷IP?`, wizard.AccountName, purityText, nativeText, typeText, modeText, intervalText, prefixText)
 
 	buttons := [][]tgbotapi.InlineKeyboardButton{
 		{tgbotapi.NewInlineKeyboardButtonData("▶️ 开始刷IP", "autoip:confirm:")},
@@ -948,7 +1657,7 @@ func (b *Bot) showConfirmation(chatID int64, minInterval, maxInterval int) {
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = tgbotapi.ModeMarkdown
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 // startAutoApplyTask starts the auto-apply background task
@@ -971,14 +1680,14 @@ func (b *Bot) startAutoApplyTask(chatID int64) {
 	b.mu.Unlock()
 
 	// Check if there are existing IPs
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := b.withTimeout(callTimeout)
 	defer cancel()
 
 	ips, err := client.ListReservedIPs(ctx)
 	if err != nil {
-		b.reply(chatID, "⚠️ 检查IP列表失败: "+err.Error())
+		b.reply(chatID, "⚠️ 检查IP列表失败: "+describeError(err))
 		// Continue anyway
-		b.doStartAutoApply(chatID, client, config)
+		b.doStartAutoApply(chatID, config)
 		return
 	}
 
@@ -986,7 +1695,11 @@ func (b *Bot) startAutoApplyTask(chatID int64) {
 		// Prompt user about existing IPs
 		var ipList strings.Builder
 		for _, ip := range ips {
-			ipList.WriteString(fmt.Sprintf("• `%s`\n", ip.IPAddress))
+			if b.isPinned(ip.IPAddress) {
+				ipList.WriteString(fmt.Sprintf("📌 `%s` (固定，不会删除)\n", ip.IPAddress))
+			} else {
+				ipList.WriteString(fmt.Sprintf("• `%s`\n", ip.IPAddress))
+			}
 		}
 
 		text := fmt.Sprintf(`⚠️ *账号 [%s] 已有 %d 个IP:*
@@ -1003,87 +1716,197 @@ func (b *Bot) startAutoApplyTask(chatID int64) {
 		msg := tgbotapi.NewMessage(chatID, text)
 		msg.ParseMode = tgbotapi.ModeMarkdown
 		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-		b.api.Send(msg)
+		b.send(msg)
 		return
 	}
 
 	// No existing IPs, start directly
-	b.doStartAutoApply(chatID, client, config)
+	b.doStartAutoApply(chatID, config)
 }
 
-// doStartAutoApply actually starts the auto-apply task (called after IP check)
-func (b *Bot) doStartAutoApply(chatID int64, client *oci.Client, config *AutoApplyConfig) {
-	b.mu.Lock()
-	// Create cancelable context
-	ctx, cancel := context.WithCancel(context.Background())
-	config.Cancel = cancel
-	config.Active = true
-	config.ChatID = chatID
-	b.autoWizard = nil // Clear wizard
-	b.mu.Unlock()
+// doStartAutoApply actually starts the auto-apply task (called after IP check).
+// The task runs as a job, so a restarted bot picks it up again. When the job
+// can't start, the configuration is dropped and the error returned as well as
+// replied.
+func (b *Bot) doStartAutoApply(chatID int64, config *AutoApplyConfig) error {
+	data := &autoIPJob{Account: config.AccountName, Criteria: profileOf(config)}
+	if err := b.jobs.Start(b.runCtx, jobAutoIP, jobAutoIP, config.AccountName, chatID, data); err != nil {
+		b.mu.Lock()
+		if b.autoApply == config && !config.Active {
+			b.autoApply = nil
+		}
+		b.mu.Unlock()
+		b.reply(chatID, "❌ 无法启动自动刷IP: "+err.Error())
+		return err
+	}
 
-	b.reply(chatID, fmt.Sprintf("🚀 *自动刷IP已启动*\n\n账号: %s\n使用 /stopauto 停止", config.AccountName))
+	text := fmt.Sprintf("🚀 *自动刷IP已启动*\n\n账号: %s\n使用 /stopauto 立即停止，/stopauto soft 完成本轮后停止", escapeMarkdown(config.AccountName))
+	if !ippure.BrowserAvailable() {
+		text += "\n\n⚠️ 未找到 Chrome，不检测纯净度、类型和来源，只按综合评分、延迟、线路等条件筛选"
+	}
+	b.replyMarkdownIn(chatID, topicAuto, text)
+	return nil
+}
 
-	// Start background task
-	go b.runAutoApplyTask(ctx, client, config)
+// autoIPJob is the data of an auto-apply job
+type autoIPJob struct {
+	Account  string        `json:"account"`
+	Criteria autoIPProfile `json:"criteria"` // Kept up to date by /tune
 }
 
-// deleteAllIPsAndStart deletes all existing IPs then starts auto-apply
-func (b *Bot) deleteAllIPsAndStart(chatID int64) {
-	b.mu.Lock()
-	config := b.autoApply
-	if config == nil {
-		b.mu.Unlock()
-		b.reply(chatID, "⚠️ 配置已失效，请重新使用 /autoip")
-		return
+// autoIPWorkflow declares auto-apply as a job of one step, so that it is
+// picked up again after a restart
+func (b *Bot) autoIPWorkflow() workflow.Definition {
+	return workflow.Definition{
+		Kind:    jobAutoIP,
+		Title:   "自动刷IP",
+		NewData: func() any { return &autoIPJob{} },
+		Report:  b.reportJob,
+		Steps:   []workflow.Step{{Name: "search", Title: "刷IP", Run: b.autoIPSearchStep}},
 	}
+}
 
-	client, ok := b.clients[config.AccountName]
+// autoIPSearchStep runs auto-apply until it keeps an IP or is stopped. Either
+// way the job is done.
+func (b *Bot) autoIPSearchStep(ctx context.Context, job *workflow.Job) error {
+	data := job.Data.(*autoIPJob)
+	client, ok := b.client(data.Account)
 	if !ok {
+		b.notify(job.ChatID, topicAuto, fmt.Sprintf("❌ [%s] 自动刷IP未继续: 账号不存在或已停用", data.Account))
+		return nil
+	}
+	config := data.Criteria.task(data.Account)
+	b.mu.Lock()
+	if running := b.autoApply; running != nil && running.Active {
 		b.mu.Unlock()
-		b.reply(chatID, "❌ 账号不存在: "+config.AccountName)
-		return
+		b.replyIn(job.ChatID, topicAuto, fmt.Sprintf("⚠️ [%s] 自动刷IP未继续: [%s] 的任务正在运行", data.Account, running.AccountName))
+		return nil
 	}
-	intervalMin := config.IntervalMin
-	intervalMax := config.IntervalMax
+	b.autoApply = config
 	b.mu.Unlock()
 
-	// List and delete all IPs
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	ips, err := client.ListReservedIPs(ctx)
-	cancel()
+	b.runAutoApplyFor(ctx, job.ChatID, client, config)
+	return nil
+}
 
-	if err != nil {
-		b.reply(chatID, "❌ 获取IP列表失败: "+err.Error())
+// runAutoApplyFor runs config as the auto-apply task until it keeps an IP or
+// is stopped, and returns the IP kept. Cancelling ctx stops the task quietly.
+func (b *Bot) runAutoApplyFor(ctx context.Context, chatID int64, client oci.Service, config *AutoApplyConfig) *oci.PublicIPInfo {
+	taskCtx := b.activateAutoApply(ctx, chatID, config)
+	b.publish(events.Event{Type: events.AutoIPStarted, Account: config.AccountName})
+	b.runAutoApplyTask(taskCtx, client, config)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.autoApply == config {
+		// Cancelled with ctx rather than by /stopauto
+		config.Cancel()
+		config.Active = false
+		b.autoApply = nil
+	}
+	return config.Kept
+}
+
+// activateAutoApply marks config as running, cancelled with parent or by
+// /stopauto, and returns the context the task runs with
+func (b *Bot) activateAutoApply(parent context.Context, chatID int64, config *AutoApplyConfig) context.Context {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ctx, cancel := context.WithCancel(parent)
+	config.Cancel = cancel
+	config.Active = true
+	config.ChatID = chatID
+	config.Pace = b.newPacer(config.IntervalMin, config.IntervalMax)
+	delete(b.autoWizards, chatID) // Clear wizard
+	return ctx
+}
+
+// deleteAllIPsAndStart deletes all existing IPs then starts auto-apply
+func (b *Bot) deleteAllIPsAndStart(chatID int64) {
+	b.mu.Lock()
+	config := b.autoApply
+	b.mu.Unlock()
+	if config == nil {
+		b.reply(chatID, "⚠️ 配置已失效，请重新使用 /autoip")
+		return
+	}
+
+	ips, kept, err := b.deletableIPs(config.AccountName)
+	if err != nil {
+		b.reply(chatID, "❌ 获取IP列表失败: "+describeError(err))
+		return
+	}
+	if kept > 0 {
+		b.reply(chatID, fmt.Sprintf("📌 保留 %d 个固定的IP", kept))
+	}
+	b.deleteIPsAndStart(chatID, config, ips)
+}
+
+// deletableIPs lists the account's reserved IPs that aren't pinned, and
+// counts the pinned ones kept
+func (b *Bot) deletableIPs(accountName string) ([]oci.PublicIPInfo, int, error) {
+	client, ok := b.client(accountName)
+	if !ok {
+		return nil, 0, fmt.Errorf("账号不存在: %s", accountName)
+	}
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+	ips, err := client.ListReservedIPs(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	deletable := b.withoutPinned(ips)
+	return deletable, len(ips) - len(deletable), nil
+}
+
+// deleteIPsAndStart deletes exactly the given IPs, waiting the configured
+// interval between deletes, then starts auto-apply
+func (b *Bot) deleteIPsAndStart(chatID int64, config *AutoApplyConfig, ips []oci.PublicIPInfo) {
+	client, ok := b.client(config.AccountName)
+	if !ok {
+		b.reply(chatID, "❌ 账号不存在: "+config.AccountName)
 		return
 	}
 
 	for i, ip := range ips {
-		b.reply(chatID, fmt.Sprintf("🗑 删除IP (%d/%d): %s", i+1, len(ips), ip.IPAddress))
+		if b.isPinned(ip.IPAddress) {
+			b.status(chatID, topicAuto, fmt.Sprintf("📌 保留固定的IP: %s", ip.IPAddress))
+			continue
+		}
+		b.status(chatID, topicAuto, fmt.Sprintf("🗑 删除IP (%d/%d): %s", i+1, len(ips), ip.IPAddress))
 
-		delCtx, delCancel := context.WithTimeout(context.Background(), 30*time.Second)
-		err := client.DeleteReservedIP(delCtx, ip.ID)
+		release, err := b.acquireAccount(b.runCtx, chatID, config.AccountName)
+		if err != nil {
+			return
+		}
+		delCtx, delCancel := b.withTimeout(callTimeout)
+		err = client.DeleteReservedIP(delCtx, ip.ID)
 		delCancel()
+		release()
 
 		if err != nil {
-			b.reply(chatID, fmt.Sprintf("⚠️ 删除失败: %s", err.Error()))
+			b.status(chatID, topicAuto, deleteErrorText(ip.IPAddress, err))
 		}
 
 		// Wait interval after delete
 		if i < len(ips)-1 {
-			interval := intervalMin
-			if intervalMax > intervalMin {
-				interval = intervalMin + rand.Intn(intervalMax-intervalMin+1)
+			interval := config.IntervalMin
+			if config.IntervalMax > config.IntervalMin {
+				interval = config.IntervalMin + rand.Intn(config.IntervalMax-config.IntervalMin+1)
+			}
+			b.status(chatID, topicAuto, fmt.Sprintf("⏳ 等待 %d 秒...", interval))
+			select {
+			case <-b.runCtx.Done():
+				return
+			case <-time.After(time.Duration(interval) * time.Second):
 			}
-			b.reply(chatID, fmt.Sprintf("⏳ 等待 %d 秒...", interval))
-			time.Sleep(time.Duration(interval) * time.Second)
 		}
 	}
 
-	b.reply(chatID, "✅ 已删除所有IP，开始自动刷IP...")
+	b.replyIn(chatID, topicAuto, "✅ 已删除所有IP，开始自动刷IP...")
 
 	// Start auto-apply
-	b.doStartAutoApply(chatID, client, config)
+	b.doStartAutoApply(chatID, config)
 }
 
 // stopAutoApply stops the running auto-apply task
@@ -1101,153 +1924,507 @@ func (b *Bot) stopAutoApply(chatID int64) {
 	}
 	config.Active = false
 	b.autoApply = nil
+	unchecked := config.UncheckedIPs
+	b.mu.Unlock()
+
+	b.publish(events.Event{Type: events.AutoIPStopped, Account: config.AccountName, Reason: "stopped"})
+	b.replyMarkdownIn(chatID, topicAuto, "⏹ 已停止自动刷IP任务"+uncheckedSummary(unchecked))
+}
+
+// softStopAutoApply stops the auto-apply task once the current create-check-
+// decide cycle is done, so no freshly created IP is left undecided. Between
+// cycles it stops right away.
+func (b *Bot) softStopAutoApply(chatID int64) {
+	b.mu.Lock()
+	config := b.autoApply
+	if config == nil || !config.Active {
+		b.mu.Unlock()
+		b.reply(chatID, "⚠️ 当前没有运行中的自动刷IP任务")
+		return
+	}
+	if !config.InAttempt {
+		b.mu.Unlock()
+		b.stopAutoApply(chatID)
+		return
+	}
+	config.Stopping = true
+	b.mu.Unlock()
+
+	b.replyIn(chatID, topicAuto, "⏳ 将在本轮完成后停止自动刷IP\n使用 /stopauto 立即停止")
+}
+
+// pauseAutoApply holds the auto-apply task before its next cycle, keeping its
+// configuration and attempt count. A cycle in progress is finished first.
+func (b *Bot) pauseAutoApply(chatID int64) {
+	b.mu.Lock()
+	config := b.autoApply
+	if config == nil || !config.Active {
+		b.mu.Unlock()
+		b.reply(chatID, "⚠️ 当前没有运行中的自动刷IP任务")
+		return
+	}
+	if config.Paused {
+		b.mu.Unlock()
+		b.reply(chatID, "⚠️ 自动刷IP已暂停，使用 /resumeauto 继续")
+		return
+	}
+	config.Paused = true
+	config.resume = make(chan struct{})
+	inAttempt := config.InAttempt
+	b.mu.Unlock()
+
+	text := "⏸ 自动刷IP已暂停\n使用 /resumeauto 继续"
+	if inAttempt {
+		text = "⏸ 自动刷IP将在本轮完成后暂停\n使用 /resumeauto 继续"
+	}
+	b.replyIn(chatID, topicAuto, text)
+}
+
+// resumeAutoApply lets a paused auto-apply task continue
+func (b *Bot) resumeAutoApply(chatID int64) {
+	b.mu.Lock()
+	config := b.autoApply
+	if config == nil || !config.Active || !config.Paused {
+		b.mu.Unlock()
+		b.reply(chatID, "⚠️ 没有已暂停的自动刷IP任务")
+		return
+	}
+	config.Paused = false
+	close(config.resume)
 	b.mu.Unlock()
 
-	b.reply(chatID, "⏹ 已停止自动刷IP任务")
+	b.replyIn(chatID, topicAuto, "▶️ 自动刷IP已继续")
+}
+
+// waitWhilePaused blocks while the task is paused, until resumed or cancelled
+func (b *Bot) waitWhilePaused(ctx context.Context, config *AutoApplyConfig) {
+	b.mu.Lock()
+	paused, resume := config.Paused, config.resume
+	b.mu.Unlock()
+	if !paused {
+		return
+	}
+
+	logger.Infof("Auto-apply task paused")
+	select {
+	case <-ctx.Done():
+	case <-resume:
+		logger.Infof("Auto-apply task resumed")
+	}
+}
+
+// finishSoftStop ends a task whose soft stop was requested, after its cycle
+func (b *Bot) finishSoftStop(config *AutoApplyConfig) {
+	b.mu.Lock()
+	config.Active = false
+	if b.autoApply == config {
+		b.autoApply = nil
+	}
+	unchecked := config.UncheckedIPs
+	cancel := config.Cancel
+	b.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	logger.Infof("Auto-apply task stopped after its current attempt")
+	b.publish(events.Event{Type: events.AutoIPStopped, Account: config.AccountName, Reason: "soft stop"})
+	b.replyMarkdownIn(config.ChatID, topicAuto, "⏹ 本轮已完成，自动刷IP任务已停止"+uncheckedSummary(unchecked))
 }
 
 // runAutoApplyTask runs the auto-apply background loop
-func (b *Bot) runAutoApplyTask(ctx context.Context, client *oci.Client, config *AutoApplyConfig) {
+func (b *Bot) runAutoApplyTask(ctx context.Context, client oci.Service, config *AutoApplyConfig) {
 	attempt := 0
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Auto-apply task cancelled")
+			logger.Infof("Auto-apply task cancelled")
 			return
 		default:
 		}
 
+		b.waitWhilePaused(ctx, config)
+		if ctx.Err() != nil {
+			logger.Infof("Auto-apply task cancelled")
+			return
+		}
+
+		b.mu.Lock()
+		stopping := config.Stopping
+		config.InAttempt = !stopping
+		b.mu.Unlock()
+		if stopping {
+			b.finishSoftStop(config)
+			return
+		}
+
 		attempt++
-		log.Printf("Auto-apply attempt %d", attempt)
+		logger.Infof("Auto-apply attempt %d", attempt)
+		b.countDigest(func(d *digestState) { d.AutoAttempts++ })
 
-		// Step 1: Create IP
-		log.Printf("Creating reserved IP (attempt %d)...", attempt)
+		found := b.autoApplyAttempt(ctx, client, config, attempt)
 
-		createCtx, createCancel := context.WithTimeout(ctx, 2*time.Minute)
-		displayName := fmt.Sprintf("auto-%d", time.Now().Unix())
-		publicIP, err := client.CreateReservedIP(createCtx, displayName)
-		createCancel()
+		b.mu.Lock()
+		config.InAttempt = false
+		stopping = config.Stopping
+		b.mu.Unlock()
 
-		if err != nil {
-			log.Printf("Create failed: %s. Waiting...", err.Error())
-			b.waitInterval(ctx, config)
-			continue
+		if found {
+			return
 		}
+		if stopping {
+			b.finishSoftStop(config)
+			return
+		}
+		b.notifyProgress(config, attempt)
+
+		// Wait interval before next attempt
+		config.Pace.Wait(ctx)
+	}
+}
+
+// preflightChrome finds the Chrome local purity checks run, downloading
+// chrome-headless-shell when chrome_download is set. Without one the bot runs
+// on, checks answering ippure.Unavailable.
+func (b *Bot) preflightChrome(ctx context.Context) {
+	if b.cfg.ChromeDownload {
+		logger.Infof("Looking for Chrome, downloading chrome-headless-shell into %s if there is none...", b.cfg.ChromeDir)
+	}
+	ctx, cancel := context.WithTimeout(ctx, chromeTimeout)
+	defer cancel()
+	path, err := ippure.Preflight(ctx, ippure.ChromeOptions{Path: b.cfg.ChromePath, Download: b.cfg.ChromeDownload, Dir: b.cfg.ChromeDir})
+	if err != nil {
+		// Carry on with the API-based checks rather than fail every check
+		ippure.DisableBrowser()
+		logger.Errorf("Purity checks need Chrome: %v. Running without: only blocklist, ASN, latency and route criteria apply. "+
+			"Install Chrome or Chromium, set chrome_path, set chrome_download=true or use checker_agents", err)
+		b.alert("⚠️ 未找到 Chrome，纯净度检测不可用\n" +
+			"自动刷IP只按黑名单/ASN、延迟、线路等条件筛选。请安装 Chrome/Chromium，或设置 chrome_path、chrome_download=true、checker_agents 后重启\n\n" + err.Error())
+		return
+	}
+	logger.Infof("Purity checks use %s", path)
+}
 
-		// Wait for IP ready
-		waitCtx, waitCancel := context.WithTimeout(ctx, 60*time.Second)
-		publicIP, err = client.WaitForIPReady(waitCtx, publicIP.ID, 60*time.Second)
-		waitCancel()
+// autoApplyAttempt creates one IP, checks it and deletes it unless it matches.
+// Returns true when a matching IP was found and the task is finished.
+func (b *Bot) autoApplyAttempt(ctx context.Context, client oci.Service, config *AutoApplyConfig, attempt int) bool {
+	if b.currentConfig().AutoBurst > 1 {
+		return b.autoApplyBurst(ctx, client, config, attempt)
+	}
 
+	// Step 1: Create IP
+	publicIP := b.createCandidateIP(ctx, client, config, attempt)
+	if publicIP == nil {
+		return false
+	}
+	penalties, ok := b.screenCandidate(ctx, config, publicIP)
+	if !ok {
+		b.discardIP(ctx, client, publicIP)
+		return false
+	}
+
+	// Step 2: Check IP purity immediately
+	logger.Infof("IP created: %s. Checking purity...", publicIP.IPAddress)
+
+	info, err := b.checkWithRetries(ctx, publicIP.IPAddress)
+	if err != nil {
+		b.handleCheckFailure(ctx, client, config, publicIP, err)
+		return false
+	}
+	b.recordPurity(client.Region(), info)
+
+	// Step 3: Check if it matches criteria
+	score := penalties.withPurity(info)
+	matched := b.checkIPMatch(info, score, config)
+	b.recordStage(config, stagePurity, matched)
+	b.archiveIP(client.Region(), info, score, matched)
+	if matched {
+		info, score, matched, err = b.verifyMatch(ctx, config, publicIP, penalties, info, score)
 		if err != nil {
-			log.Printf("Wait for IP ready failed: %s", err.Error())
-			b.waitInterval(ctx, config)
-			continue
+			b.handleCheckFailure(ctx, client, config, publicIP, err)
+			return false
 		}
+	}
+	if matched && b.speedWanted(ctx, client, config, publicIP, &score) {
+		b.announceMatch(client, config, publicIP, info, score, attempt)
+		return true
+	}
+	if matched {
+		// Too slow. Not remembered as released: its purity still matches.
+		b.discardIP(ctx, client, publicIP)
+		return false
+	}
 
-		// Step 2: Check IP purity immediately
-		log.Printf("IP created: %s. Checking purity...", publicIP.IPAddress)
+	// Not matching - delete and retry
+	b.rememberReleased(publicIP.IPAddress, info, score)
+	b.noteMiss(config, publicIP.IPAddress, info, score)
+	logger.Infof("IP mismatch (score %s, %s). Deleting...", score, info.IsNative)
+	b.discardIP(ctx, client, publicIP)
+	return false
+}
 
-		checkCtx, checkCancel := context.WithTimeout(ctx, 60*time.Second)
-		info, err := ippure.Check(checkCtx, publicIP.IPAddress)
-		checkCancel()
+// createCandidateIP creates a reserved IP for auto-apply and waits until it
+// has an address. Returns nil on failure.
+func (b *Bot) createCandidateIP(ctx context.Context, client oci.Service, config *AutoApplyConfig, attempt int) *oci.PublicIPInfo {
+	logger.Debugf("Creating reserved IP (attempt %d)...", attempt)
 
-		if err != nil {
-			log.Printf("Check failed: %s. Keeping IP and continuing...", err.Error())
-			// Optional: notify user if check fails repeatedly? For now just log.
-			b.waitInterval(ctx, config)
-			continue
+	release, err := b.acquireAccount(ctx, 0, config.AccountName)
+	if err != nil {
+		return nil
+	}
+	displayName := fmt.Sprintf("%s%d", autoIPPrefix, time.Now().Unix())
+	var publicIP *oci.PublicIPInfo
+	err = retryCreate(ctx, attemptID(client.AccountName(), displayName, newNonce()), createTimeout, func(ctx context.Context) (err error) {
+		publicIP, err = client.CreateReservedIP(ctx, displayName)
+		return err
+	})
+	release()
+	config.Pace.Record(err)
+
+	if err != nil {
+		logger.Warnf("Create failed: %s. Waiting...", err.Error())
+		return nil
+	}
+
+	// Wait for IP ready
+	waitCtx, waitCancel := context.WithTimeout(ctx, ipReadyTimeout)
+	publicIP, err = client.WaitForIPReady(waitCtx, publicIP.ID, ipReadyTimeout)
+	waitCancel()
+
+	if err != nil {
+		logger.Warnf("Wait for IP ready failed: %s", err.Error())
+		return nil
+	}
+	b.publish(events.Event{Type: events.IPCreated, Account: config.AccountName, IP: publicIP.IPAddress, Attempts: attempt})
+	return publicIP
+}
+
+// retryCreate runs an OCI create call tagged with attemptID, each try limited
+// to timeout. A try that fails without a clear answer (timeout, dropped
+// connection, 5xx) is repeated with the same retry token, so a create that
+// did go through is returned instead of made twice.
+func retryCreate(ctx context.Context, attemptID string, timeout time.Duration, create func(context.Context) error) error {
+	ctx = oci.WithAttemptID(ctx, attemptID)
+	for try := 1; ; try++ {
+		tryCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := create(tryCtx)
+		cancel()
+		if err == nil || try >= createTries || ctx.Err() != nil || !oci.IsRetryable(err) {
+			return err
 		}
+		logger.Warnf("Create %s failed (%d/%d), retrying with the same token: %v", attemptID, try, createTries, err)
+	}
+}
 
-		// Step 3: Check if it matches criteria
-		match := b.checkIPMatch(info, config)
+// handleCheckFailure applies auto_check_fail to an IP whose purity check
+// failed: it is deleted, or kept and reported in the final summary.
+func (b *Bot) handleCheckFailure(ctx context.Context, client oci.Service, config *AutoApplyConfig, publicIP *oci.PublicIPInfo, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	b.countDigest(func(d *digestState) { d.CheckFailures++ })
+	b.publish(events.Event{Type: events.CheckFailed, Account: config.AccountName, IP: publicIP.IPAddress, Error: err.Error()})
+	if b.currentConfig().AutoCheckFail == "delete" && !b.isPinned(publicIP.IPAddress) {
+		logger.Errorf("Check failed for %s: %s. Deleting...", publicIP.IPAddress, err.Error())
+		b.discardIP(ctx, client, publicIP)
+		return
+	}
+	logger.Errorf("Check failed for %s: %s. Keeping IP and continuing...", publicIP.IPAddress, err.Error())
+	b.markAutoIPsKept(publicIP.IPAddress)
+	b.mu.Lock()
+	config.UncheckedIPs = append(config.UncheckedIPs, publicIP.IPAddress)
+	b.mu.Unlock()
+}
 
-		if match {
-			// Found matching IP!
-			b.mu.Lock()
-			b.purityCache[publicIP.IPAddress] = &IPPurityCache{
-				PurityScore: info.PurityScore,
-				IPType:      info.IPType,
-				IsNative:    info.IsNative,
-			}
-			config.Active = false
-			b.autoApply = nil
-			b.mu.Unlock()
+// prefixWanted reports whether an auto-apply IP is in the address ranges
+// configured for the account. Unwanted IPs should be released without a
+// purity check.
+func (b *Bot) prefixWanted(config *AutoApplyConfig, publicIP *oci.PublicIPInfo) bool {
+	account := b.accountConfig(config.AccountName)
+	if account == nil {
+		return true
+	}
+	ok, reason := account.AutoIPPrefixAllowed(publicIP.IPAddress)
+	if ok {
+		return true
+	}
+	logger.Infof("IP %s is %s. Deleting without a purity check...", publicIP.IPAddress, reason)
+	return false
+}
+
+// discardIP deletes a candidate IP that did not match, unless it is pinned.
+func (b *Bot) discardIP(ctx context.Context, client oci.Service, publicIP *oci.PublicIPInfo) {
+	if b.isPinned(publicIP.IPAddress) {
+		logger.Infof("%s is pinned, keeping it", publicIP.IPAddress)
+		return
+	}
+
+	release, err := b.acquireAccount(ctx, 0, client.AccountName())
+	if err != nil {
+		return
+	}
+	defer release()
+	delCtx, delCancel := context.WithTimeout(ctx, callTimeout)
+	err = client.DeleteReservedIP(delCtx, publicIP.ID)
+	delCancel()
+
+	if err != nil {
+		logger.Errorf("Delete failed: %s", err.Error())
+	}
+}
 
-			// Send success notification
-			text := fmt.Sprintf(`🎉 *找到符合条件的IP!*
+// announceMatch finishes the auto-apply task with a matching IP.
+func (b *Bot) announceMatch(client oci.Service, config *AutoApplyConfig, publicIP *oci.PublicIPInfo, info *ippure.IPInfo, score ipScore, attempt int) {
+	b.markAutoIPsKept(publicIP.IPAddress)
+	b.countDigest(func(d *digestState) { d.AutoMatches++ })
+	b.mu.Lock()
+	config.Active = false
+	config.Kept = publicIP
+	b.autoApply = nil
+	unchecked := config.UncheckedIPs
+	b.mu.Unlock()
+
+	// Send success notification
+	text := fmt.Sprintf(`🎉 *找到符合条件的IP!*
 
 📊 *纯净度:* %s (%s)
 🏢 *类型:* %s
 🌐 *来源:* %s
 🔢 *尝试次数:* %d`,
-				info.PurityScore, info.PurityLevel,
-				info.IPType,
-				info.IsNative,
-				attempt)
+		info.PurityScore, info.PurityLevel,
+		info.IPType,
+		info.IsNative,
+		attempt)
+	if len(score.Penalties) > 0 {
+		text += "\n🧮 *综合评分:* " + escapeMarkdown(score.String())
+	}
+	if line := latencyMarkdown(score.Latency); line != "" {
+		text += "\n" + line
+	}
+	if line := routesMarkdown(score.Routes); line != "" {
+		text += "\n" + line
+	}
+	if line := speedMarkdown(score.Speed); line != "" {
+		text += "\n" + line
+	}
+	text += uncheckedSummary(unchecked)
+
+	b.notifyMarkdown(config.ChatID, topicAuto, text)
+	logger.Infof("Auto-apply found matching IP: %s", publicIP.IPAddress)
+	b.publish(events.Event{
+		Type:     events.IPMatched,
+		Account:  config.AccountName,
+		IP:       publicIP.IPAddress,
+		Purity:   info.PurityScore,
+		IPType:   info.IPType,
+		Native:   info.IsNative,
+		Attempts: attempt,
+	})
+
+	// Show IP list with the new IP highlighted
+	b.showIPListWithHighlight(config.ChatID, publicIP.IPAddress, client, "")
+}
 
-			b.replyMarkdown(config.ChatID, text)
-			log.Printf("Auto-apply found matching IP: %s", publicIP.IPAddress)
+// checkWithRetries runs the purity check, repeating it up to auto_check_retries
+// more times when it fails.
+func (b *Bot) checkWithRetries(ctx context.Context, ipAddr string) (*ippure.IPInfo, error) {
+	checkCtx, checkCancel := context.WithTimeout(ctx, autoCheckTimeout)
+	info, err := b.checker.Check(checkCtx, ipAddr)
+	checkCancel()
+	if err != nil {
+		return b.retryCheck(ctx, ipAddr, err)
+	}
+	return info, nil
+}
 
-			// Show IP list with the new IP highlighted
-			b.showIPListWithHighlight(config.ChatID, publicIP.IPAddress, client)
-			return
+// retryCheck repeats a purity check that failed with err up to
+// auto_check_retries times
+func (b *Bot) retryCheck(ctx context.Context, ipAddr string, err error) (*ippure.IPInfo, error) {
+	retries := b.currentConfig().AutoCheckRetries
+	for retry := 0; retry < retries; retry++ {
+		logger.Warnf("Check failed for %s (retry %d/%d): %s", ipAddr, retry+1, retries, err.Error())
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Second):
 		}
 
-		// Not matching - delete and retry
-		log.Printf("IP mismatch (%s/%s). Deleting...", info.PurityScore, info.IsNative)
-
-		delCtx, delCancel := context.WithTimeout(ctx, 30*time.Second)
-		err = client.DeleteReservedIP(delCtx, publicIP.ID)
-		delCancel()
-
-		if err != nil {
-			log.Printf("Delete failed: %s", err.Error())
+		checkCtx, checkCancel := context.WithTimeout(ctx, autoCheckTimeout)
+		info, retryErr := b.checker.Check(checkCtx, ipAddr)
+		checkCancel()
+		if retryErr == nil {
+			return info, nil
 		}
-
-		// Wait interval before next attempt
-		b.waitInterval(ctx, config)
+		err = retryErr
 	}
+	return nil, err
 }
 
-// checkIPMatch checks if the IP matches the configured criteria
-func (b *Bot) checkIPMatch(info *ippure.IPInfo, config *AutoApplyConfig) bool {
-	// Parse purity score (remove % if present)
-	purityStr := strings.TrimSuffix(info.PurityScore, "%")
-	purity, err := strconv.Atoi(purityStr)
-	if err != nil {
-		purity = 100 // Default to not matching
+// uncheckedSummary lists the IPs auto-apply kept without a successful check.
+func uncheckedSummary(ips []string) string {
+	if len(ips) == 0 {
+		return ""
 	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n\n⚠️ *检测失败而保留的IP (%d):*", len(ips)))
+	for _, ip := range ips {
+		sb.WriteString("\n• `" + ip + "`")
+	}
+	return sb.String()
+}
 
-	purityOK := purity <= config.PurityThreshold
-	nativeOK := config.NativeRequired == "any" || info.IsNative == config.NativeRequired
-
-	if config.MatchMode == "all" {
-		return purityOK && nativeOK
+// cachedNote tells how old a result from the check cache is
+func cachedNote(info *ippure.IPInfo) string {
+	age := time.Since(info.Checked)
+	if age < time.Minute {
+		return ""
 	}
-	// mode == "any"
-	return purityOK || nativeOK
+	return fmt.Sprintf("\n🕒 %d 分钟前的检测结果", int(age.Minutes()))
 }
 
-// waitInterval waits for the configured interval
-func (b *Bot) waitInterval(ctx context.Context, config *AutoApplyConfig) {
-	interval := config.IntervalMin
-	if config.IntervalMax > config.IntervalMin {
-		interval = config.IntervalMin + rand.Intn(config.IntervalMax-config.IntervalMin+1)
+// purityValue parses the purity score, treating unparsable scores as 100
+// (worst) and unavailable ones as 0, i.e. leaving them out.
+func purityValue(info *ippure.IPInfo) int {
+	if !info.Available() {
+		return 0
+	}
+	// Remove % if present
+	purity, err := strconv.Atoi(strings.TrimSuffix(info.PurityScore, "%"))
+	if err != nil {
+		return 100
 	}
+	return purity
+}
 
-	log.Printf("Waiting %d seconds before next attempt", interval)
+// checkIPMatch checks if the IP matches the configured criteria. The purity
+// threshold applies to the composite score, see scoreIP.
+func (b *Bot) checkIPMatch(info *ippure.IPInfo, score ipScore, config *AutoApplyConfig) bool {
+	// The criteria can be relaxed while the task runs
+	b.mu.Lock()
+	threshold, native, ipType, mode := config.PurityThreshold, config.NativeRequired, config.TypeRequired, config.MatchMode
+	b.mu.Unlock()
 
-	select {
-	case <-ctx.Done():
-		return
-	case <-time.After(time.Duration(interval) * time.Second):
+	if !info.Available() {
+		// Checked without a browser: only the API-based score is known
+		return score.Value <= threshold
+	}
+	purityOK := score.Value <= threshold
+	nativeOK := native == "any" || info.IsNative == native
+	typeOK := ipType == "any" || info.IPType == ipType
+
+	if mode == "all" {
+		return purityOK && nativeOK && typeOK
+	}
+	// mode == "any"; an unrestricted type is not a criterion of its own
+	if ipType == "any" {
+		return purityOK || nativeOK
 	}
+	return purityOK || nativeOK || typeOK
 }
 
 // ========== Auto-VPS Wizard ==========
 
-func (b *Bot) startAutoVPSWizard(chatID int64) {
+func (b *Bot) startAutoVPSWizard(chatID, userID int64) {
 	b.mu.Lock()
 	if b.autoVPS != nil && b.autoVPS.Active {
 		b.mu.Unlock()
@@ -1255,14 +2432,17 @@ func (b *Bot) startAutoVPSWizard(chatID int64) {
 		return
 	}
 
-	b.vpsWizard = &AutoVPSWizard{
-		Step:   1,
-		ChatID: chatID,
+	b.vpsWizards[chatID] = &AutoVPSWizard{
+		Step:    1,
+		ChatID:  chatID,
+		UserID:  userID,
+		Expires: time.Now().Add(b.wizardTimeout()),
 	}
 	b.mu.Unlock()
 
 	var buttons [][]tgbotapi.InlineKeyboardButton
-	for name, client := range b.clients {
+	for _, client := range b.sortedClients() {
+		name := client.AccountName()
 		label := fmt.Sprintf("%s (%s)", name, client.Region())
 		btn := tgbotapi.NewInlineKeyboardButtonData(label, "autovps:account:"+name)
 		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
@@ -1273,12 +2453,12 @@ func (b *Bot) startAutoVPSWizard(chatID int64) {
 	msg := tgbotapi.NewMessage(chatID, "🖥️ *自动申请VPS配置* (1/3)\n\n请选择账号:")
 	msg.ParseMode = tgbotapi.ModeMarkdown
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 func (b *Bot) handleAutoVPSCallback(chatID int64, param string, parts []string) {
 	b.mu.Lock()
-	wizard := b.vpsWizard
+	wizard := b.vpsWizardLocked(chatID)
 	b.mu.Unlock()
 
 	if wizard == nil {
@@ -1295,7 +2475,7 @@ func (b *Bot) handleAutoVPSCallback(chatID int64, param string, parts []string)
 	switch subAction {
 	case "cancel":
 		b.mu.Lock()
-		b.vpsWizard = nil
+		delete(b.vpsWizards, chatID)
 		b.mu.Unlock()
 		b.reply(chatID, "❌ 已取消自动申请VPS配置")
 	case "account":
@@ -1316,18 +2496,40 @@ func (b *Bot) handleAutoVPSCallback(chatID int64, param string, parts []string)
 }
 
 func (b *Bot) showVPSArchStep(chatID int64) {
+	b.mu.Lock()
+	var presets []config.VPSPreset
+	if wizard := b.vpsWizardLocked(chatID); wizard != nil {
+		if account := b.accountConfigLocked(wizard.AccountName); account != nil {
+			presets = account.VPSPresets
+		}
+	}
+	b.mu.Unlock()
+
 	buttons := [][]tgbotapi.InlineKeyboardButton{
 		{
 			tgbotapi.NewInlineKeyboardButtonData("🧮 AMD", "autovps:arch:amd"),
 			tgbotapi.NewInlineKeyboardButtonData("🧩 ARM", "autovps:arch:arm"),
 		},
-		{tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "autovps:cancel:")},
 	}
+	// The account's [<account>.presets.<name>] templates
+	for _, preset := range presets {
+		label := "📦 " + preset.Name
+		if preset.OCPUs > 0 || preset.MemoryGB > 0 {
+			label += fmt.Sprintf(" (%g OCPU / %gGB)", preset.OCPUs, preset.MemoryGB)
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, "autovps:arch:"+preset.Name)))
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "autovps:cancel:")))
 
-	msg := tgbotapi.NewMessage(chatID, "🖥️ *自动申请VPS配置* (2/3)\n\n请选择架构:")
+	text := "🖥️ *自动申请VPS配置* (2/3)\n\n请选择架构:"
+	if len(presets) > 0 {
+		text = "🖥️ *自动申请VPS配置* (2/3)\n\n请选择架构或预设:"
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = tgbotapi.ModeMarkdown
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 func (b *Bot) showVPSIntervalStep(chatID int64) {
@@ -1340,7 +2542,7 @@ func (b *Bot) showVPSIntervalStep(chatID int64) {
 
 _直接发送消息即可_`)
 	msg.ParseMode = tgbotapi.ModeMarkdown
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 func (b *Bot) handleVPSIntervalInput(chatID int64, text string) {
@@ -1348,7 +2550,7 @@ func (b *Bot) handleVPSIntervalInput(chatID int64, text string) {
 
 	minInterval, maxInterval, err := parseInterval(text)
 	if err != nil {
-		b.reply(chatID, "❌ "+err.Error())
+		b.reply(chatID, errorText(err))
 		return
 	}
 	if minInterval < 10 {
@@ -1357,7 +2559,7 @@ func (b *Bot) handleVPSIntervalInput(chatID int64, text string) {
 	}
 
 	b.mu.Lock()
-	wizard := b.vpsWizard
+	wizard := b.vpsWizardLocked(chatID)
 	if wizard != nil {
 		wizard.Step = 4
 	}
@@ -1368,7 +2570,7 @@ func (b *Bot) handleVPSIntervalInput(chatID int64, text string) {
 
 func (b *Bot) showVPSConfirmation(chatID int64, minInterval, maxInterval int) {
 	b.mu.Lock()
-	wizard := b.vpsWizard
+	wizard := b.vpsWizardLocked(chatID)
 	b.mu.Unlock()
 
 	if wizard == nil {
@@ -1376,21 +2578,11 @@ func (b *Bot) showVPSConfirmation(chatID int64, minInterval, maxInterval int) {
 		return
 	}
 
-	account := b.cfg.GetAccount(wizard.AccountName)
-	shape := ""
-	ocpus := float32(0)
-	memory := float32(0)
-	if account != nil {
-		if wizard.Arch == "arm" {
-			shape = account.VPSShapeArm
-			ocpus = account.VPSOCPUsArm
-			memory = account.VPSMemoryGBArm
-		} else {
-			shape = account.VPSShapeAmd
-			ocpus = account.VPSOCPUsAmd
-			memory = account.VPSMemoryGBAmd
-		}
+	var template config.VPSPreset
+	if account := b.cfg.GetAccount(wizard.AccountName); account != nil {
+		template, _ = account.VPSTemplate(wizard.Arch)
 	}
+	shape, ocpus, memory := template.Shape, template.OCPUs, template.MemoryGB
 
 	b.mu.Lock()
 	b.autoVPS = &AutoVPSConfig{
@@ -1429,7 +2621,7 @@ func (b *Bot) showVPSConfirmation(chatID int64, minInterval, maxInterval int) {
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = tgbotapi.ModeMarkdown
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 func (b *Bot) startAutoVPSTask(chatID int64) {
@@ -1441,8 +2633,7 @@ func (b *Bot) startAutoVPSTask(chatID int64) {
 		return
 	}
 
-	client, ok := b.clients[config.AccountName]
-	if !ok {
+	if _, ok := b.clients[config.AccountName]; !ok {
 		b.mu.Unlock()
 		b.reply(chatID, "❌ 账号不存在: "+config.AccountName)
 		return
@@ -1459,22 +2650,36 @@ func (b *Bot) startAutoVPSTask(chatID int64) {
 		b.reply(chatID, "❌ VPS配置错误: "+err.Error())
 		return
 	}
+	provision, err := b.accountProvisioning(account)
+	if err != nil {
+		b.reply(chatID, fmt.Sprintf("❌ 配方 %s 无效: %v", account.VPSRecipe, err))
+		return
+	}
+	config.Provision = provision
 
-	b.doStartAutoVPS(chatID, client, account, config)
+	b.doStartAutoVPS(chatID, account, config)
 }
 
-func (b *Bot) doStartAutoVPS(chatID int64, client *oci.Client, account *config.OCIAccount, config *AutoVPSConfig) {
+func (b *Bot) doStartAutoVPS(chatID int64, account *config.OCIAccount, config *AutoVPSConfig) {
 	b.mu.Lock()
-	ctx, cancel := context.WithCancel(context.Background())
-	config.Cancel = cancel
-	config.Active = true
-	config.ChatID = chatID
-	b.vpsWizard = nil
+	delete(b.vpsWizards, chatID)
 	b.mu.Unlock()
 
-	b.reply(chatID, fmt.Sprintf("🚀 *自动申请VPS已启动*\n\n账号: %s\n架构: %s\n使用 /stopvps 停止", config.AccountName, strings.ToUpper(config.Arch)))
+	data := &autoVPSJob{Account: config.AccountName, Arch: config.Arch, IntervalMin: config.IntervalMin, IntervalMax: config.IntervalMax}
+	if config.Provision != nil {
+		data.Recipe, data.Params = account.VPSRecipe, &config.Provision.Params
+	}
+	// A launch that failed earlier gives way to the new one
+	if job, ok := b.jobs.Get(jobAutoVPS); ok && !job.Running {
+		b.jobs.Stop(jobAutoVPS)
+	}
+	if err := b.jobs.Start(b.runCtx, jobAutoVPS, jobAutoVPS, config.AccountName, chatID, data); err != nil {
+		b.reply(chatID, "❌ 无法启动自动申请VPS: "+err.Error())
+		return
+	}
 
-	go b.runAutoVPSTask(ctx, client, account, config)
+	b.publish(events.Event{Type: events.AutoVPSStarted, Account: config.AccountName})
+	b.replyIn(chatID, topicAuto, fmt.Sprintf("🚀 *自动申请VPS已启动*\n\n账号: %s\n架构: %s\n使用 /stopvps 停止", config.AccountName, strings.ToUpper(config.Arch)))
 }
 
 func (b *Bot) stopAutoVPS(chatID int64) {
@@ -1493,65 +2698,218 @@ func (b *Bot) stopAutoVPS(chatID int64) {
 	b.autoVPS = nil
 	b.mu.Unlock()
 
-	b.reply(chatID, "⏹ 已停止自动申请VPS任务")
+	b.publish(events.Event{Type: events.AutoVPSStopped, Account: config.AccountName, Reason: "stopped"})
+	b.replyIn(chatID, topicAuto, "⏹ 已停止自动申请VPS任务")
 }
 
-func (b *Bot) runAutoVPSTask(ctx context.Context, client *oci.Client, account *config.OCIAccount, config *AutoVPSConfig) {
-	attempt := 0
-	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Auto-VPS task cancelled")
-			return
-		default:
-		}
+// autoVPSJob is the data of an auto-VPS job
+type autoVPSJob struct {
+	Account       string        `json:"account"`
+	Arch          string        `json:"arch"`
+	IntervalMin   int           `json:"interval_min"`
+	IntervalMax   int           `json:"interval_max"`
+	LaunchName    string        `json:"launch_name,omitempty"`  // Display name of the launch attempt
+	LaunchNonce   string        `json:"launch_nonce,omitempty"` // Retry token nonce of the launch attempt
+	InstanceID    string        `json:"instance_id,omitempty"`
+	WorkRequestID string        `json:"work_request_id,omitempty"`
+	Launched      time.Time     `json:"launched"`
+	Recipe        string        `json:"recipe,omitempty"`
+	Params        *recipeParams `json:"params,omitempty"` // Secrets of the recipe, rendered into cloud-init at launch
+}
 
-		attempt++
-		displayName := fmt.Sprintf("autovps-%d", time.Now().Unix())
+// autoVPSWorkflow declares /autovps: the launch, then waiting for the
+// instance and applying vps_recipe
+func (b *Bot) autoVPSWorkflow() workflow.Definition {
+	return workflow.Definition{
+		Kind:    jobAutoVPS,
+		Title:   "自动申请VPS",
+		NewData: func() any { return &autoVPSJob{} },
+		Report:  b.reportJob,
+		Steps: []workflow.Step{
+			{Name: "launch", Title: "抢实例", Run: b.autoVPSLaunchStep},
+			{Name: "boot", Title: "实例启动", Run: b.autoVPSBootStep},
+			{Name: "probe", Title: "连通性检测", Run: b.autoVPSProbeStep},
+			{Name: "provision", Title: "部署配方", Run: b.autoVPSProvisionStep},
+		},
+	}
+}
 
-		launchDetails := b.buildVPSLaunchDetails(account, config.Arch, displayName)
-		launchCtx, launchCancel := context.WithTimeout(ctx, 3*time.Minute)
-		instance, err := client.LaunchInstance(launchCtx, launchDetails)
-		launchCancel()
+// autoVPSTarget returns the data of an auto-VPS job with its account
+func (b *Bot) autoVPSTarget(job *workflow.Job) (*autoVPSJob, oci.Service, *config.OCIAccount, error) {
+	data := job.Data.(*autoVPSJob)
+	client, ok := b.client(data.Account)
+	account := b.accountConfig(data.Account)
+	if !ok || account == nil {
+		return nil, nil, nil, fmt.Errorf("account %s is gone or disabled", data.Account)
+	}
+	return data, client, account, nil
+}
 
+// autoVPSLaunchStep launches the instance, retrying while out of capacity.
+// Meanwhile the task shows in /status and /stopvps stops it.
+func (b *Bot) autoVPSLaunchStep(ctx context.Context, job *workflow.Job) error {
+	data, client, account, err := b.autoVPSTarget(job)
+	if err != nil {
+		return err
+	}
+	userData := ""
+	if data.Params != nil {
+		provision, err := b.savedProvisioning(data.Recipe, data.Params)
 		if err != nil {
-			if isRetryableCapacityError(err) {
-				log.Printf("VPS capacity error (attempt %d): %s", attempt, err.Error())
-				b.waitVPSInterval(ctx, config)
-				continue
-			}
-
-			log.Printf("VPS launch failed: %s", err.Error())
-			b.reply(chatID, "❌ VPS申请失败: "+err.Error())
-			b.mu.Lock()
-			config.Active = false
-			b.autoVPS = nil
-			b.mu.Unlock()
-			return
+			return err
 		}
+		userData = provision.UserData
+	}
 
+	b.mu.Lock()
+	config := &AutoVPSConfig{
+		AccountName: data.Account,
+		Arch:        data.Arch,
+		IntervalMin: data.IntervalMin,
+		IntervalMax: data.IntervalMax,
+		Active:      true,
+		Cancel:      func() { b.jobs.Stop(jobAutoVPS) },
+		ChatID:      job.ChatID,
+		Pace:        b.newPacer(data.IntervalMin, data.IntervalMax),
+	}
+	b.autoVPS = config
+	b.mu.Unlock()
+	defer func() {
 		b.mu.Lock()
 		config.Active = false
-		b.autoVPS = nil
+		if b.autoVPS == config {
+			b.autoVPS = nil
+		}
 		b.mu.Unlock()
+	}()
 
-		instanceID := ""
-		if instance.Id != nil {
-			instanceID = *instance.Id
-		}
-		shape := ""
-		if instance.Shape != nil {
-			shape = *instance.Shape
+	instance, launched, attempts, err := b.launchVPS(ctx, client, account, data.Arch, userData, config.Pace, "autovps",
+		launchAttempt{data.LaunchName, data.LaunchNonce},
+		func(a launchAttempt) { job.Update(func() { data.LaunchName, data.LaunchNonce = a.Name, a.Nonce }) })
+	if err != nil {
+		if ctx.Err() == nil {
+			b.publish(events.Event{Type: events.AutoVPSFailed, Account: data.Account, Attempts: attempts, Error: err.Error()})
 		}
-		text := fmt.Sprintf(`🎉 *VPS申请成功!*
+		return err
+	}
+
+	instanceID := ""
+	if instance.Id != nil {
+		instanceID = *instance.Id
+	}
+	shape := ""
+	if instance.Shape != nil {
+		shape = *instance.Shape
+	}
+	ad := ""
+	if instance.AvailabilityDomain != nil {
+		ad = *instance.AvailabilityDomain
+	}
+	if instance.FaultDomain != nil {
+		ad += " / " + *instance.FaultDomain
+	}
+	job.Update(func() {
+		data.InstanceID, data.WorkRequestID, data.Launched = instanceID, instance.WorkRequestID, launched
+	})
+	text := fmt.Sprintf(`🎉 *VPS申请成功!*
 
 实例ID: %s
 架构: %s
 规格: %s
 区域: %s
-尝试次数: %d`, instanceID, strings.ToUpper(config.Arch), shape, client.Region(), attempt)
-		b.replyMarkdown(config.ChatID, text)
-		return
+可用域: %s
+尝试次数: %d`, instanceID, strings.ToUpper(data.Arch), shape, client.Region(), ad, attempts)
+	b.notifyMarkdown(job.ChatID, topicAuto, text)
+	b.publish(events.Event{Type: events.InstanceLaunched, Account: data.Account, InstanceID: instanceID, Attempts: attempts})
+	return nil
+}
+
+// autoVPSBootStep follows the launch's work request until the instance runs
+func (b *Bot) autoVPSBootStep(ctx context.Context, job *workflow.Job) error {
+	data, client, _, err := b.autoVPSTarget(job)
+	if err != nil || data.WorkRequestID == "" {
+		return err
+	}
+	trackCtx, trackCancel := context.WithTimeout(ctx, workRequestTimeout)
+	defer trackCancel()
+	return b.trackWorkRequest(trackCtx, job.ChatID, topicAuto, client, data.WorkRequestID, fmt.Sprintf("[%s] 实例启动", data.Account))
+}
+
+// autoVPSProbeStep waits until sshd answers. Only a recipe needs it to, the
+// wait reports its own outcome.
+func (b *Bot) autoVPSProbeStep(ctx context.Context, job *workflow.Job) error {
+	data, client, _, err := b.autoVPSTarget(job)
+	if err != nil || data.InstanceID == "" {
+		return err
+	}
+	conn := b.waitSSHReady(ctx, job.ChatID, topicAuto, client, data.InstanceID, data.Launched)
+	if conn == nil && data.Params != nil {
+		return fmt.Errorf("instance not reachable over SSH")
+	}
+	return nil
+}
+
+// autoVPSProvisionStep applies the account's vps_recipe
+func (b *Bot) autoVPSProvisionStep(ctx context.Context, job *workflow.Job) error {
+	data, client, _, err := b.autoVPSTarget(job)
+	if err != nil || data.Params == nil || data.InstanceID == "" {
+		return err
+	}
+	provision, err := b.savedProvisioning(data.Recipe, data.Params)
+	if err != nil {
+		return err
+	}
+	conn, err := b.instanceConnection(client, data.InstanceID)
+	if err != nil {
+		return err
+	}
+	return b.provision(job.ChatID, topicAuto, client, data.InstanceID, conn, provision)
+}
+
+// launchVPS launches an instance, trying again at pace while the region is
+// out of capacity or throttled. Each attempt is named after prefix, the name
+// doubling as its retry token, and save records the name before the attempt
+// is made: resuming with that name as last repeats an attempt a restart cut
+// short, finding the instance it may have launched. Returns the instance,
+// when the successful attempt started and the number of attempts.
+func (b *Bot) launchVPS(ctx context.Context, client oci.Service, account *config.OCIAccount, arch, userData string, pace *pacer,
+	prefix string, last launchAttempt, save func(launchAttempt)) (*oci.LaunchedInstance, time.Time, int, error) {
+	current := last
+	for attempt := 1; ; attempt++ {
+		if current.Name == "" {
+			current = launchAttempt{Name: fmt.Sprintf("%s-%d", prefix, time.Now().Unix()), Nonce: newNonce()}
+			save(current)
+		}
+		launchDetails := b.buildVPSLaunchDetails(account, arch, current.Name)
+		launchDetails.UserData = userData
+		launched := time.Now()
+		var instance *oci.LaunchedInstance
+		err := func() error {
+			release, err := b.acquireAccount(ctx, 0, account.Name)
+			if err != nil {
+				return err
+			}
+			defer release()
+			return retryCreate(ctx, attemptID(account.Name, current.Name, current.Nonce), launchTimeout, func(ctx context.Context) (err error) {
+				instance, err = client.LaunchInstanceWithFallback(ctx, launchDetails, account.VPSFaultDomainFallback)
+				b.recordLaunch(launchDetails.Shape, instance, err)
+				return err
+			})
+		}()
+		pace.Record(err)
+		if err == nil {
+			return instance, launched, attempt, nil
+		}
+		if ctx.Err() != nil || !oci.IsOutOfCapacity(err) && !oci.IsThrottled(err) {
+			return nil, launched, attempt, err
+		}
+
+		logger.Infof("VPS launch not possible yet (attempt %d): %s", attempt, err.Error())
+		current = launchAttempt{}
+		pace.Wait(ctx)
+		if ctx.Err() != nil {
+			return nil, launched, attempt, ctx.Err()
+		}
 	}
 }
 
@@ -1564,36 +2922,15 @@ func (b *Bot) buildVPSLaunchDetails(account *config.OCIAccount, arch, displayNam
 		BootVolumeGB:       account.VPSBootVolumeGB,
 	}
 
-	if arch == "arm" {
-		details.ImageID = account.VPSImageArm
-		details.Shape = account.VPSShapeArm
-		details.OCPUs = account.VPSOCPUsArm
-		details.MemoryGB = account.VPSMemoryGBArm
-	} else {
-		details.ImageID = account.VPSImageAmd
-		details.Shape = account.VPSShapeAmd
-		details.OCPUs = account.VPSOCPUsAmd
-		details.MemoryGB = account.VPSMemoryGBAmd
-	}
+	template, _ := account.VPSTemplate(arch)
+	details.ImageID = template.Image
+	details.Shape = template.Shape
+	details.OCPUs = template.OCPUs
+	details.MemoryGB = template.MemoryGB
 
 	return details
 }
 
-func (b *Bot) waitVPSInterval(ctx context.Context, config *AutoVPSConfig) {
-	interval := config.IntervalMin
-	if config.IntervalMax > config.IntervalMin {
-		interval = config.IntervalMin + rand.Intn(config.IntervalMax-config.IntervalMin+1)
-	}
-
-	log.Printf("Waiting %d seconds before next VPS attempt", interval)
-
-	select {
-	case <-ctx.Done():
-		return
-	case <-time.After(time.Duration(interval) * time.Second):
-	}
-}
-
 func parseInterval(text string) (int, int, error) {
 	if strings.Contains(text, "-") {
 		parts := strings.Split(text, "-")
@@ -1620,17 +2957,3 @@ func parseInterval(text string) (int, int, error) {
 	}
 	return minInterval, minInterval, nil
 }
-
-func isRetryableCapacityError(err error) bool {
-	lower := strings.ToLower(err.Error())
-	if strings.Contains(lower, "outofhostcapacity") {
-		return true
-	}
-	if strings.Contains(lower, "out of host capacity") {
-		return true
-	}
-	if strings.Contains(lower, "insufficient capacity") {
-		return true
-	}
-	return false
-}
@@ -0,0 +1,355 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"oci-bot/bot/tgfake"
+	"oci-bot/config"
+	"oci-bot/ippure"
+	"oci-bot/oci"
+	"oci-bot/oci/ocifake"
+)
+
+const testAdmin = 42 // Admin user and private chat of the test bots
+
+// newTestBot builds a bot on a fake Telegram transport and fake OCI accounts,
+// configured like a conf file with one section per account
+func newTestBot(t *testing.T, accounts ...*ocifake.Client) (*Bot, *tgfake.Transport) {
+	t.Helper()
+	dir := t.TempDir()
+	conf := "chat_id=42\nstate_file=" + filepath.Join(dir, "state.json") + "\n"
+	clients := make(map[string]oci.Service, len(accounts))
+	for _, account := range accounts {
+		conf += "\n[" + account.Name + "]\nregion=" + account.RegionName + "\n"
+		clients[account.Name] = account
+	}
+	file := filepath.Join(dir, "oci-bot.conf")
+	if err := os.WriteFile(file, []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := tgfake.New()
+	b, err := NewWithServices(cfg, api, clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Telegram's per-chat pace would make every test take seconds
+	b.limiter.chats[testAdmin] = newTokenBucket(1000, 1000)
+	return b, api
+}
+
+// lastText returns the text of the last message sent, "" when there is none
+func lastText(api *tgfake.Transport) string {
+	texts := api.Texts()
+	if len(texts) == 0 {
+		return ""
+	}
+	return texts[len(texts)-1]
+}
+
+// sentText reports whether any message sent so far contains s
+func sentText(api *tgfake.Transport, s string) bool {
+	return slices.ContainsFunc(api.Texts(), func(text string) bool { return strings.Contains(text, s) })
+}
+
+func TestCheckIPMatch(t *testing.T) {
+	b, _ := newTestBot(t, ocifake.New("main", "ap-tokyo-1"))
+
+	clean := &ippure.IPInfo{PurityScore: "10%", IPType: ippure.TypeResidential, IsNative: ippure.OriginNative}
+	dirty := &ippure.IPInfo{PurityScore: "80%", IPType: ippure.TypeDatacenter, IsNative: ippure.OriginNonNative}
+	unchecked := &ippure.IPInfo{PurityScore: ippure.Unavailable}
+
+	tests := []struct {
+		name   string
+		info   *ippure.IPInfo
+		score  ipScore
+		config AutoApplyConfig
+		want   bool
+	}{
+		{"all criteria met", clean, ipScore{Value: 10},
+			AutoApplyConfig{PurityThreshold: 20, NativeRequired: ippure.OriginNative, TypeRequired: ippure.TypeResidential, MatchMode: "all"}, true},
+		{"all with wrong type", clean, ipScore{Value: 10},
+			AutoApplyConfig{PurityThreshold: 20, NativeRequired: ippure.OriginNative, TypeRequired: ippure.TypeDatacenter, MatchMode: "all"}, false},
+		{"all over threshold", dirty, ipScore{Value: 80},
+			AutoApplyConfig{PurityThreshold: 50, NativeRequired: "any", TypeRequired: "any", MatchMode: "all"}, false},
+		{"penalties push over threshold", clean, ipScore{Value: 35},
+			AutoApplyConfig{PurityThreshold: 30, NativeRequired: "any", TypeRequired: "any", MatchMode: "all"}, false},
+		{"any with native only", dirty, ipScore{Value: 80},
+			AutoApplyConfig{PurityThreshold: 20, NativeRequired: ippure.OriginNonNative, TypeRequired: "any", MatchMode: "any"}, true},
+		{"any with unrestricted type is no criterion", dirty, ipScore{Value: 80},
+			AutoApplyConfig{PurityThreshold: 20, NativeRequired: ippure.OriginNative, TypeRequired: "any", MatchMode: "any"}, false},
+		{"any with type only", dirty, ipScore{Value: 80},
+			AutoApplyConfig{PurityThreshold: 20, NativeRequired: ippure.OriginNative, TypeRequired: ippure.TypeDatacenter, MatchMode: "any"}, true},
+		{"unavailable within threshold", unchecked, ipScore{Value: 10},
+			AutoApplyConfig{PurityThreshold: 20, NativeRequired: ippure.OriginNative, TypeRequired: ippure.TypeResidential, MatchMode: "all"}, true},
+		{"unavailable over threshold", unchecked, ipScore{Value: 30},
+			AutoApplyConfig{PurityThreshold: 20, NativeRequired: "any", TypeRequired: "any", MatchMode: "any"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := b.checkIPMatch(tt.info, tt.score, &tt.config); got != tt.want {
+				t.Errorf("checkIPMatch = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPScoreWithPurity(t *testing.T) {
+	info := &ippure.IPInfo{PurityScore: "15%"}
+
+	score := ipScore{}.withPurity(info)
+	if score.Value != 15 || score.String() != "15" {
+		t.Errorf("without penalties: got %d %q, want 15 \"15\"", score.Value, score.String())
+	}
+
+	score = ipScore{Value: 20, Penalties: []string{"+ 黑名单 ×2 +20"}}.withPurity(info)
+	if score.Value != 35 {
+		t.Errorf("Value = %d, want 35", score.Value)
+	}
+	if want := "35 (纯净度 15% + 黑名单 ×2 +20)"; score.String() != want {
+		t.Errorf("String = %q, want %q", score.String(), want)
+	}
+
+	// Unparsable scores count as the worst, unavailable ones not at all
+	if got := (ipScore{}).withPurity(&ippure.IPInfo{PurityScore: ippure.Unknown}).Value; got != 100 {
+		t.Errorf("unknown purity: Value = %d, want 100", got)
+	}
+	if got := (ipScore{Value: 5}).withPurity(&ippure.IPInfo{PurityScore: ippure.Unavailable}).Value; got != 5 {
+		t.Errorf("unavailable purity: Value = %d, want 5", got)
+	}
+}
+
+func TestAutoIPWizard(t *testing.T) {
+	b, api := newTestBot(t, ocifake.New("main", "ap-tokyo-1"), ocifake.New("backup", "us-ashburn-1"))
+
+	b.startAutoIPWizard(testAdmin, testAdmin)
+	if !strings.Contains(lastText(api), "(1/6)") {
+		t.Fatalf("start: got %q, want the account step", lastText(api))
+	}
+
+	steps := []struct {
+		data string
+		want string
+	}{
+		{"autoip:account:backup", "(2/6)"},
+		{"autoip:purity:30", "(3/6)"},
+		{"autoip:native:原生IP", "(4/6)"},
+		{"autoip:type:any", "(5/6)"},
+		{"autoip:mode:all", "(6/6)"},
+	}
+	for _, step := range steps {
+		b.handleAutoIPCallback(testAdmin, "", strings.Split(step.data, ":"))
+		if !strings.Contains(lastText(api), step.want) {
+			t.Fatalf("%s: got %q, want step %s", step.data, lastText(api), step.want)
+		}
+	}
+
+	b.handleIntervalInput(testAdmin, "5")
+	if lastText(api) != "❌ 间隔时间不能小于10秒" {
+		t.Fatalf("short interval: got %q", lastText(api))
+	}
+	b.handleIntervalInput(testAdmin, "300-200")
+	if !strings.Contains(lastText(api), "确认自动刷IP配置") {
+		t.Fatalf("interval: got %q, want the confirmation", lastText(api))
+	}
+
+	b.mu.Lock()
+	got := *b.autoApply
+	step := b.autoWizards[testAdmin].Step
+	b.mu.Unlock()
+	want := AutoApplyConfig{AccountName: "backup", PurityThreshold: 30, NativeRequired: "原生IP", TypeRequired: "any",
+		MatchMode: "all", IntervalMin: 200, IntervalMax: 300, ChatID: testAdmin}
+	if got.AccountName != want.AccountName || got.PurityThreshold != want.PurityThreshold ||
+		got.NativeRequired != want.NativeRequired || got.TypeRequired != want.TypeRequired ||
+		got.MatchMode != want.MatchMode || got.IntervalMin != want.IntervalMin ||
+		got.IntervalMax != want.IntervalMax || got.ChatID != want.ChatID {
+		t.Errorf("config = %+v, want %+v", got, want)
+	}
+	if step != 7 {
+		t.Errorf("wizard step = %d, want 7", step)
+	}
+
+	b.handleAutoIPCallback(testAdmin, "", []string{"autoip", "cancel", ""})
+	b.mu.Lock()
+	_, open := b.autoWizards[testAdmin]
+	b.mu.Unlock()
+	if open || lastText(api) != "❌ 已取消自动刷IP配置" {
+		t.Errorf("cancel: wizard open %v, last message %q", open, lastText(api))
+	}
+}
+
+func TestAutoIPWizardNeedsStart(t *testing.T) {
+	b, api := newTestBot(t, ocifake.New("main", "ap-tokyo-1"))
+
+	b.handleAutoIPCallback(testAdmin, "", []string{"autoip", "purity", "30"})
+	if lastText(api) != "⚠️ 请先使用 /autoip 开始配置" {
+		t.Errorf("got %q", lastText(api))
+	}
+}
+
+func TestGroupRenameTakesOnlyAskersAnswer(t *testing.T) {
+	client := ocifake.New("main", "ap-tokyo-1")
+	client.AddReservedIP(oci.PublicIPInfo{ID: "ocid1.publicip.a", IPAddress: "192.0.2.1"})
+	b, _ := newTestBot(t, client)
+
+	const group, asker, other = -100, 7, 8
+	b.cfg.GroupChatID = group
+	b.limiter.chats[group] = newTokenBucket(1000, 1000)
+	b.groupAdmins[asker] = groupAdmin{Admin: true, Expires: time.Now().Add(time.Hour)}
+	b.groupAdmins[other] = groupAdmin{Admin: true, Expires: time.Now().Add(time.Hour)}
+	say := func(from int64, text string) {
+		b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: from}, Chat: &tgbotapi.Chat{ID: group}, Text: text})
+	}
+	name := func() string {
+		ips, err := client.ListReservedIPs(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ips[0].DisplayName
+	}
+
+	b.askRename(group, asker, &renameTarget{AccountName: "main", Kind: "ip", ID: "ocid1.publicip.a"})
+	say(other, "hijacked")
+	if got := name(); got == "hijacked" {
+		t.Fatal("another member answered the rename prompt")
+	}
+	say(asker, "web")
+	if got := name(); got != "web" {
+		t.Errorf("name = %q, want web", got)
+	}
+}
+
+func TestDeleteIP(t *testing.T) {
+	client := ocifake.New("main", "ap-tokyo-1")
+	client.Compartment = "ocid1.compartment.work"
+	client.Protected = []string{"ocid1.compartment.work"}
+	client.AddReservedIP(oci.PublicIPInfo{ID: "ocid1.publicip.a", IPAddress: "192.0.2.1", CompartmentID: client.Compartment})
+	b, api := newTestBot(t, client)
+
+	b.deleteIP(testAdmin, "192.0.2.9")
+	if !sentText(api, "❌ 未找到: 192.0.2.9") {
+		t.Errorf("unknown IP: got %q", api.Texts())
+	}
+
+	// Protected compartments refuse the delete
+	b.deleteIP(testAdmin, "192.0.2.1")
+	if !sentText(api, "🔒 192.0.2.1 位于受保护区间，拒绝删除") {
+		t.Errorf("protected IP: got %q", api.Texts())
+	}
+
+	// Pinned IPs are refused before OCI is asked
+	client.Protected = nil
+	if err := b.setPinned("192.0.2.1", true); err != nil {
+		t.Fatal(err)
+	}
+	calls := len(client.Calls())
+	b.deleteIP(testAdmin, "192.0.2.1")
+	if want := "📌 192.0.2.1 已固定，请先 /unpin 192.0.2.1"; lastText(api) != want {
+		t.Errorf("pinned IP: got %q, want %q", lastText(api), want)
+	}
+	if len(client.Calls()) != calls {
+		t.Errorf("pinned IP: OCI called %v", client.Calls()[calls:])
+	}
+
+	if err := b.setPinned("192.0.2.1", false); err != nil {
+		t.Fatal(err)
+	}
+	b.deleteIP(testAdmin, "192.0.2.1")
+	if !sentText(api, "✅ 已删除: 192.0.2.1") {
+		t.Errorf("delete: got %q", api.Texts())
+	}
+	if got := reservedIPs(t, client); len(got) != 0 {
+		t.Errorf("IPs left after delete: %v", got)
+	}
+}
+
+func TestBulkDelete(t *testing.T) {
+	client := ocifake.New("main", "ap-tokyo-1")
+	client.Compartment = "ocid1.compartment.work"
+	for i, addr := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4"} {
+		client.AddReservedIP(oci.PublicIPInfo{ID: fmt.Sprintf("ocid1.publicip.%d", i), IPAddress: addr, CompartmentID: client.Compartment})
+	}
+	b, api := newTestBot(t, client)
+
+	// Pinned IPs are not offered
+	if err := b.setPinned("192.0.2.4", true); err != nil {
+		t.Fatal(err)
+	}
+	b.startIPSelection(testAdmin)
+	if got, want := selectionIPs(b), []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}; !slices.Equal(got, want) {
+		t.Fatalf("offered %v, want %v", got, want)
+	}
+
+	// Pinned or protected by the time of the delete: every one is refused
+	if err := b.setPinned("192.0.2.3", true); err != nil {
+		t.Fatal(err)
+	}
+	client.Protected = []string{client.Compartment}
+	b.handleSelectCallback(testAdmin, 1, "all", []string{"sel", "all"})
+	b.handleSelectCallback(testAdmin, 1, "do", []string{"sel", "do"})
+	summary := lastEdit(api)
+	for _, want := range []string{"已删除 0/3", "📌 192.0.2.3 已固定", "🔒 192.0.2.1 位于受保护区间", "🔒 192.0.2.2 位于受保护区间"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary %q lacks %q", summary, want)
+		}
+	}
+	if got := reservedIPs(t, client); len(got) != 4 {
+		t.Errorf("left %v, want all four", got)
+	}
+
+	client.Protected = nil
+	b.startIPSelection(testAdmin)
+	b.handleSelectCallback(testAdmin, 2, "toggle", []string{"sel", "toggle", "1"})
+	b.handleSelectCallback(testAdmin, 2, "do", []string{"sel", "do"})
+	if summary := lastEdit(api); !strings.HasPrefix(summary, "✅ [main] 已删除 1/1 个IP") {
+		t.Errorf("summary %q", summary)
+	}
+	if got, want := reservedIPs(t, client), []string{"192.0.2.1", "192.0.2.3", "192.0.2.4"}; !slices.Equal(got, want) {
+		t.Errorf("left %v, want %v", got, want)
+	}
+}
+
+// selectionIPs returns the IPs offered by the admin chat's bulk delete
+func selectionIPs(b *Bot) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if sel := b.selections[testAdmin]; sel != nil {
+		return sel.IPs
+	}
+	return nil
+}
+
+// lastEdit returns the text of the last message edit, "" when there is none
+func lastEdit(api *tgfake.Transport) string {
+	edits := api.Edits()
+	if len(edits) == 0 {
+		return ""
+	}
+	return edits[len(edits)-1]
+}
+
+// reservedIPs returns the addresses of the account's reserved IPs
+func reservedIPs(t *testing.T, client *ocifake.Client) []string {
+	t.Helper()
+	ips, err := client.ListReservedIPs(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var addrs []string
+	for _, ip := range ips {
+		addrs = append(addrs, ip.IPAddress)
+	}
+	return addrs
+}
@@ -0,0 +1,228 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ipSelection is the multi-select state of a bulk delete in one chat
+type ipSelection struct {
+	AccountName string
+	IPs         []string     // Addresses in display order
+	Selected    map[int]bool // Index into IPs -> selected
+}
+
+// count returns the number of selected IPs
+func (s *ipSelection) count() int {
+	n := 0
+	for _, on := range s.Selected {
+		if on {
+			n++
+		}
+	}
+	return n
+}
+
+// startIPSelection shows the current account's IPs as toggle buttons
+func (b *Bot) startIPSelection(chatID int64) {
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	ips, err := client.ListReservedIPs(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	sel := &ipSelection{AccountName: client.AccountName(), Selected: make(map[int]bool)}
+	for _, ip := range b.withoutPinned(ips) {
+		sel.IPs = append(sel.IPs, ip.IPAddress)
+	}
+	if len(sel.IPs) == 0 {
+		b.reply(chatID, "📋 没有可删除的IP")
+		return
+	}
+
+	b.mu.Lock()
+	b.selections[chatID] = sel
+	b.mu.Unlock()
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("☑️ [%s] 选择要删除的IP:", sel.AccountName))
+	msg.ReplyMarkup = selectionKeyboard(sel)
+	b.send(msg)
+}
+
+// selectionKeyboard renders the toggle buttons and actions for a selection
+func selectionKeyboard(sel *ipSelection) tgbotapi.InlineKeyboardMarkup {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for i, ip := range sel.IPs {
+		mark := "☐"
+		if sel.Selected[i] {
+			mark = "☑️"
+		}
+		btn := tgbotapi.NewInlineKeyboardButtonData(mark+" "+ip, "sel:toggle:"+strconv.Itoa(i))
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
+	}
+
+	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("全选", "sel:all"),
+		tgbotapi.NewInlineKeyboardButtonData("全不选", "sel:none"),
+	})
+	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑 删除所选 (%d)", sel.count()), "sel:do"),
+		tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "sel:cancel"),
+	})
+	return tgbotapi.NewInlineKeyboardMarkup(buttons...)
+}
+
+// handleSelectCallback handles sel:<start|toggle|all|none|do|cancel>
+func (b *Bot) handleSelectCallback(chatID int64, messageID int, action string, parts []string) {
+	if action == "start" {
+		b.startIPSelection(chatID)
+		return
+	}
+
+	b.mu.Lock()
+	sel := b.selections[chatID]
+	if sel == nil {
+		b.mu.Unlock()
+		b.reply(chatID, "⚠️ 选择已失效，请重新使用 /listip")
+		return
+	}
+
+	switch action {
+	case "toggle":
+		if len(parts) > 2 {
+			if i, err := strconv.Atoi(parts[2]); err == nil && i >= 0 && i < len(sel.IPs) {
+				sel.Selected[i] = !sel.Selected[i]
+			}
+		}
+	case "all":
+		for i := range sel.IPs {
+			sel.Selected[i] = true
+		}
+	case "none":
+		sel.Selected = make(map[int]bool)
+	case "cancel":
+		delete(b.selections, chatID)
+		b.mu.Unlock()
+		b.edit(chatID, tgbotapi.NewEditMessageText(chatID, messageID, "已取消"))
+		return
+	case "do":
+		if sel.count() == 0 {
+			b.mu.Unlock()
+			b.reply(chatID, "⚠️ 未选择任何IP")
+			return
+		}
+		delete(b.selections, chatID)
+		b.mu.Unlock()
+		b.edit(chatID, tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("🗑 [%s] 删除 %d 个IP", sel.AccountName, sel.count())))
+		b.deleteSelectedIPs(chatID, sel)
+		return
+	}
+	markup := selectionKeyboard(sel)
+	b.mu.Unlock()
+
+	b.edit(chatID, tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, markup))
+}
+
+// deleteSelectedIPs deletes the selected IPs one by one, editing a single progress message
+func (b *Bot) deleteSelectedIPs(chatID int64, sel *ipSelection) {
+	client, ok := b.client(sel.AccountName)
+	if !ok {
+		b.reply(chatID, "❌ 账号不存在: "+sel.AccountName)
+		return
+	}
+	ips, missing, err := b.selectedIPs(client, sel)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+	var failures []string
+	for _, addr := range missing {
+		failures = append(failures, "❌ 未找到: "+addr)
+	}
+	b.deleteIPs(chatID, sel.AccountName, ips, failures)
+}
+
+// selectedIPs looks up the selected addresses among the account's reserved
+// IPs, returning those no longer found as missing
+func (b *Bot) selectedIPs(client oci.Service, sel *ipSelection) ([]oci.PublicIPInfo, []string, error) {
+	ctx, cancel := b.withTimeout(callTimeout)
+	ips, err := client.ListReservedIPs(ctx)
+	cancel()
+	if err != nil {
+		return nil, nil, err
+	}
+	byAddress := make(map[string]oci.PublicIPInfo, len(ips))
+	for _, ip := range ips {
+		byAddress[ip.IPAddress] = ip
+	}
+
+	var selected []oci.PublicIPInfo
+	var missing []string
+	for i, addr := range sel.IPs {
+		if !sel.Selected[i] {
+			continue
+		}
+		if ip, found := byAddress[addr]; found {
+			selected = append(selected, ip)
+		} else {
+			missing = append(missing, addr)
+		}
+	}
+	return selected, missing, nil
+}
+
+// deleteIPs deletes exactly the given IPs of an account one by one, editing a
+// single progress message. failures are reported along with its own.
+func (b *Bot) deleteIPs(chatID int64, accountName string, ips []oci.PublicIPInfo, failures []string) {
+	client, ok := b.client(accountName)
+	if !ok {
+		b.reply(chatID, "❌ 账号不存在: "+accountName)
+		return
+	}
+
+	total := len(ips) + len(failures)
+	progress, _ := b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⏳ 删除中 0/%d", total)))
+	deleted := 0
+
+	for i, ip := range ips {
+		if b.isPinned(ip.IPAddress) {
+			failures = append(failures, fmt.Sprintf("📌 %s 已固定", ip.IPAddress))
+		} else {
+			release, err := b.acquireAccount(b.runCtx, chatID, accountName)
+			if err != nil {
+				return
+			}
+			delCtx, delCancel := b.withTimeout(callTimeout)
+			err = client.DeleteReservedIP(delCtx, ip.ID)
+			delCancel()
+			release()
+			if err != nil {
+				failures = append(failures, deleteErrorText(ip.IPAddress, err))
+			} else {
+				deleted++
+			}
+		}
+
+		text := fmt.Sprintf("⏳ 删除中 %d/%d: %s", i+1, len(ips), ip.IPAddress)
+		b.edit(chatID, tgbotapi.NewEditMessageText(chatID, progress.MessageID, text))
+	}
+
+	summary := fmt.Sprintf("✅ [%s] 已删除 %d/%d 个IP", accountName, deleted, total)
+	for _, f := range failures {
+		summary += "\n" + f
+	}
+	b.edit(chatID, tgbotapi.NewEditMessageText(chatID, progress.MessageID, summary))
+
+	b.showIPList(chatID)
+}
@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"oci-bot/ippure"
+	"oci-bot/oci"
+)
+
+// burstResult is the purity check outcome of one burst candidate.
+type burstResult struct {
+	IP      *oci.PublicIPInfo
+	Info    *ippure.IPInfo
+	Score   ipScore // Set when the check succeeded
+	Base    ipScore // Penalties before the purity check, see screenCandidate
+	Matched bool    // Met the criteria, maybe not kept as another scored better or was too slow
+	Err     error
+}
+
+// autoApplyBurst creates up to auto_burst IPs at once, checks them concurrently
+// and keeps the best scoring match, releasing the rest. Returns true when a
+// match was kept and the task is finished.
+func (b *Bot) autoApplyBurst(ctx context.Context, client oci.Service, config *AutoApplyConfig, attempt int) bool {
+	var candidates []*oci.PublicIPInfo
+	var penalties []ipScore // Of each candidate, see screenCandidate
+	burst := b.currentConfig().AutoBurst
+	for i := 0; i < burst && ctx.Err() == nil; i++ {
+		publicIP := b.createCandidateIP(ctx, client, config, attempt)
+		if publicIP == nil {
+			// Usually the IP quota; check what we have so far
+			break
+		}
+		penalty, ok := b.screenCandidate(ctx, config, publicIP)
+		if !ok {
+			b.discardIP(ctx, client, publicIP)
+			continue
+		}
+		candidates = append(candidates, publicIP)
+		penalties = append(penalties, penalty)
+	}
+	if len(candidates) == 0 {
+		return false
+	}
+
+	logger.Infof("Burst created %d IPs. Checking purity with %d workers...", len(candidates), b.cfg.AutoCheckWorkers)
+	results := b.checkConcurrently(ctx, candidates)
+
+	var matches []*burstResult
+	for i := range results {
+		r := &results[i]
+		if r.Err != nil {
+			continue
+		}
+		b.recordPurity(client.Region(), r.Info)
+		r.Base = penalties[i]
+		r.Score = r.Base.withPurity(r.Info)
+		r.Matched = b.checkIPMatch(r.Info, r.Score, config)
+		b.recordStage(config, stagePurity, r.Matched)
+		b.archiveIP(client.Region(), r.Info, r.Score, r.Matched)
+		if r.Matched {
+			matches = append(matches, r)
+		}
+	}
+
+	// Best score first; the first one confirmed and fast enough is kept
+	slices.SortStableFunc(matches, func(x, y *burstResult) int { return x.Score.Value - y.Score.Value })
+	var best *burstResult
+	for _, r := range matches {
+		info, score, confirmed, err := b.verifyMatch(ctx, config, r.IP, r.Base, r.Info, r.Score)
+		if err != nil {
+			r.Err = err
+			continue
+		}
+		r.Info, r.Score, r.Matched = info, score, confirmed
+		if confirmed && b.speedWanted(ctx, client, config, r.IP, &r.Score) {
+			best = r
+			break
+		}
+	}
+
+	for i := range results {
+		r := &results[i]
+		switch {
+		case r == best:
+		case r.Err != nil:
+			b.handleCheckFailure(ctx, client, config, r.IP, r.Err)
+		default:
+			logger.Infof("Burst candidate %s not kept (score %s, %s). Deleting...", r.IP.IPAddress, r.Score, r.Info.IsNative)
+			if !r.Matched {
+				b.rememberReleased(r.IP.IPAddress, r.Info, r.Score)
+				b.noteMiss(config, r.IP.IPAddress, r.Info, r.Score)
+			}
+			b.discardIP(ctx, client, r.IP)
+		}
+	}
+
+	if best == nil {
+		return false
+	}
+	b.announceMatch(client, config, best.IP, best.Info, best.Score, attempt)
+	return true
+}
+
+// checkConcurrently splits the IPs among auto_check_workers workers, each
+// checking its share in one browser session with CheckBatch. Failed checks
+// are retried on their own. Results are in the same order as ips.
+func (b *Bot) checkConcurrently(ctx context.Context, ips []*oci.PublicIPInfo) []burstResult {
+	results := make([]burstResult, len(ips))
+	workers := min(b.cfg.AutoCheckWorkers, len(ips))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		// Worker w takes IPs w, w+workers, ...
+		var share []int
+		var addrs []string
+		for i := w; i < len(ips); i += workers {
+			share = append(share, i)
+			addrs = append(addrs, ips[i].IPAddress)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			batchCtx, cancel := context.WithTimeout(ctx, autoCheckTimeout*time.Duration(len(addrs)))
+			checked := b.checker.CheckBatch(batchCtx, addrs)
+			cancel()
+			for j, i := range share {
+				info, err := checked[j].Info, checked[j].Err
+				if err != nil {
+					info, err = b.retryCheck(ctx, addrs[j], err)
+				}
+				results[i] = burstResult{IP: ips[i], Info: info, Err: err}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,155 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"oci-bot/oci"
+	"oci-bot/state"
+)
+
+// capacityStatsKey is the state section holding launch outcomes per AD and shape
+const capacityStatsKey = "capacity_stats"
+
+// capacityStats aggregates the launches tried in one availability domain with
+// one shape. Hours are local hours of the day.
+type capacityStats struct {
+	AD            string    `json:"ad"`
+	Shape         string    `json:"shape"`
+	Attempts      int       `json:"attempts"`
+	Launched      int       `json:"launched"`
+	OutOfCapacity int       `json:"out_of_capacity"`
+	Throttled     int       `json:"throttled"`
+	Failed        int       `json:"failed"` // Any other error
+	LastError     string    `json:"last_error,omitempty"`
+	LastAttempt   time.Time `json:"last_attempt"`
+	LastCapacity  time.Time `json:"last_capacity"` // Last launch that did not hit out-of-capacity
+	HourAttempts  [24]int   `json:"hour_attempts"`
+	HourNoCap     [24]int   `json:"hour_no_capacity"`
+}
+
+// loadCapacityStats reads the launch statistics from the store
+func loadCapacityStats(store *state.Store) (map[string]*capacityStats, error) {
+	stats := make(map[string]*capacityStats)
+	if err := store.Get(capacityStatsKey, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// recordLaunch adds the availability domains tried by a launch to the
+// statistics. err is the launch error, whose attempts are recorded when the
+// launch failed.
+func (b *Bot) recordLaunch(shape string, instance *oci.LaunchedInstance, err error) {
+	var attempts []oci.LaunchAttempt
+	var launchErr *oci.LaunchError
+	switch {
+	case instance != nil:
+		attempts = instance.Attempts
+	case errors.As(err, &launchErr):
+		attempts = launchErr.Attempts
+	}
+	if len(attempts) == 0 {
+		return
+	}
+
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, attempt := range attempts {
+		key := attempt.AvailabilityDomain + "|" + shape
+		s := b.capacity[key]
+		if s == nil {
+			s = &capacityStats{AD: attempt.AvailabilityDomain, Shape: shape}
+			b.capacity[key] = s
+		}
+		s.Attempts++
+		s.HourAttempts[now.Hour()]++
+		s.LastAttempt = now
+
+		switch {
+		case attempt.Err == nil:
+			s.Launched++
+		case oci.IsOutOfCapacity(attempt.Err):
+			s.OutOfCapacity++
+			s.HourNoCap[now.Hour()]++
+			continue
+		case oci.Classify(attempt.Err) == oci.ErrorThrottled:
+			s.Throttled++
+			continue
+		default:
+			s.Failed++
+			s.LastError = oci.ErrorMessage(attempt.Err)
+		}
+		// Launched, or failed for a reason other than capacity
+		s.LastCapacity = now
+	}
+
+	if err := b.store.Set(capacityStatsKey, b.capacity); err != nil {
+		logger.Errorf("Failed to save capacity stats: %v", err)
+	}
+}
+
+// heatmap renders the out-of-capacity share of each hour of the day, from
+// ▁ (capacity was usually there) to █ (always out of capacity); · means no
+// attempts in that hour
+func (s *capacityStats) heatmap() string {
+	levels := []rune("▁▂▃▄▅▆▇█")
+	var sb strings.Builder
+	for hour := range s.HourAttempts {
+		if s.HourAttempts[hour] == 0 {
+			sb.WriteRune('·')
+			continue
+		}
+		share := float64(s.HourNoCap[hour]) / float64(s.HourAttempts[hour])
+		sb.WriteRune(levels[int(share*float64(len(levels)-1))])
+	}
+	return sb.String()
+}
+
+// showCapacity shows per AD and shape how often launches hit out-of-capacity
+// and when capacity was last seen, most recent first
+func (b *Bot) showCapacity(chatID int64) {
+	b.mu.Lock()
+	stats := make([]capacityStats, 0, len(b.capacity))
+	for _, s := range b.capacity {
+		stats = append(stats, *s)
+	}
+	b.mu.Unlock()
+
+	if len(stats) == 0 {
+		b.reply(chatID, "📊 暂无实例启动记录\n使用 /autovps 后会按可用域统计容量情况")
+		return
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if !stats[i].LastCapacity.Equal(stats[j].LastCapacity) {
+			return stats[i].LastCapacity.After(stats[j].LastCapacity)
+		}
+		return stats[i].AD+stats[i].Shape < stats[j].AD+stats[j].Shape
+	})
+
+	var sb strings.Builder
+	sb.WriteString("📊 *实例容量统计*\n")
+	for _, s := range stats {
+		sb.WriteString(fmt.Sprintf("\n*%s*\n%s\n", escapeMarkdown(s.AD), escapeMarkdown(s.Shape)))
+		sb.WriteString(fmt.Sprintf("尝试 %d · 容量不足 %d (%.0f%%) · 限流 %d · 其他错误 %d · 成功 %d\n",
+			s.Attempts, s.OutOfCapacity, 100*float64(s.OutOfCapacity)/float64(s.Attempts), s.Throttled, s.Failed, s.Launched))
+		if s.LastCapacity.IsZero() {
+			sb.WriteString("最近有容量: 从未\n")
+		} else {
+			sb.WriteString(fmt.Sprintf("最近有容量: %s (%s 前)\n", s.LastCapacity.Format("01-02 15:04"), time.Since(s.LastCapacity).Round(time.Minute)))
+		}
+		sb.WriteString(fmt.Sprintf("最近尝试: %s\n", s.LastAttempt.Format("01-02 15:04")))
+		if s.LastError != "" {
+			sb.WriteString("最近错误: " + escapeMarkdown(s.LastError) + "\n")
+		}
+		sb.WriteString("`" + s.heatmap() + "`\n")
+	}
+	sb.WriteString("\n按小时 (0-23 时) 的容量不足比例: ▁ 多数有容量 … █ 总是不足，· 无记录")
+	b.replyMarkdown(chatID, sb.String())
+}
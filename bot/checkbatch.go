@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+)
+
+// maxCheckIPs limits a /checkip batch, the IPs are checked one after another
+const maxCheckIPs = 20
+
+// checkIPArgs splits the IPs given to /checkip, separated by spaces or commas
+func checkIPArgs(args string) []string {
+	return strings.FieldsFunc(args, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+}
+
+// checkIPs checks several IPs in one browser session and lists the results,
+// without the per-IP latency and route details of a single /checkip
+func (b *Bot) checkIPs(chatID int64, ips []string) {
+	if len(ips) > maxCheckIPs {
+		b.reply(chatID, fmt.Sprintf("❌ 一次最多检测 %d 个IP", maxCheckIPs))
+		return
+	}
+	for _, ip := range ips {
+		if net.ParseIP(ip) == nil {
+			b.reply(chatID, "❌ 无效的IP地址: "+ip)
+			return
+		}
+	}
+
+	b.reply(chatID, fmt.Sprintf("🔍 正在依次检测 %d 个IP...", len(ips)))
+	ctx, cancel := b.withTimeout(autoCheckTimeout * maxCheckIPs)
+	defer cancel()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔍 *IP 纯净度检测* (%d)\n", len(ips)))
+	for i, r := range b.checker.CheckBatch(ctx, ips) {
+		if r.Err != nil {
+			sb.WriteString(fmt.Sprintf("\n❌ `%s` %s", ips[i], escapeMarkdown(r.Err.Error())))
+			continue
+		}
+		info := r.Info
+		sb.WriteString(fmt.Sprintf("\n• `%s` %s", info.IPAddress, escapeMarkdown(info.PurityScore)))
+		if info.Available() {
+			sb.WriteString(escapeMarkdown(fmt.Sprintf(" (%s) · %s · %s", info.PurityLevel, info.IPType, info.IsNative)))
+		}
+	}
+	b.replyMarkdown(chatID, sb.String())
+}
@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"oci-bot/ippure"
+)
+
+// capturePhotoInterval spaces the screenshots sent for check_debug_notify:
+// a changed site layout breaks every check, one picture tells the story
+const capturePhotoInterval = 30 * time.Minute
+
+// onCheckCapture logs the page saved of a broken purity check and, with
+// check_debug_notify, sends its screenshot to the admin
+func (b *Bot) onCheckCapture(c ippure.Capture) {
+	files := c.Files()
+	if len(files) > 0 {
+		logger.Warnf("Purity check of %s broke (%s), page saved as %s", c.IP, c.Reason, strings.Join(files, ", "))
+	}
+	if c.Err != nil {
+		logger.Warnf("Failed to capture the page of the purity check of %s: %v", c.IP, c.Err)
+	}
+	if !b.currentConfig().CheckDebugNotify || c.Screenshot == "" {
+		return
+	}
+
+	b.mu.Lock()
+	if time.Since(b.capturePhoto) < capturePhotoInterval {
+		b.mu.Unlock()
+		return
+	}
+	b.capturePhoto = time.Now()
+	b.mu.Unlock()
+
+	photo := tgbotapi.NewPhoto(b.adminID, tgbotapi.FileBytes{Name: filepath.Base(c.Screenshot), Bytes: c.PNG})
+	photo.Caption = fmt.Sprintf("⚠️ %s 的纯净度检测失败，页面截图如下（ippure.com 可能改版）\n原因: %s\n页面已保存: %s",
+		c.IP, c.Reason, strings.Join(files, ", "))
+	if _, err := b.sendFile(b.adminID, photo); err != nil {
+		logger.Errorf("Failed to send check screenshot: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"oci-bot/iplookup"
+	"oci-bot/ippure"
+)
+
+// maxCompareIPs limits /compare, each IP costs a browser check
+const maxCompareIPs = 6
+
+// comparison is everything /compare found out about one IP
+type comparison struct {
+	IP      string
+	Info    *ippure.IPInfo // nil when the purity check failed
+	Err     error
+	Country string   // "" when unknown
+	ASN     string   // "" when unknown
+	Org     string   // WHOIS organization, "" when unknown
+	Listed  []string // DNS blocklists listing the IP
+	Failed  []string // DNS blocklists that could not be asked
+}
+
+// handleCompare checks several IPs and shows them side by side
+func (b *Bot) handleCompare(chatID int64, args string) {
+	ips := strings.Fields(args)
+	if len(ips) < 2 || len(ips) > maxCompareIPs {
+		b.reply(chatID, fmt.Sprintf("用法: /compare <IP1> <IP2> ... (2-%d 个)", maxCompareIPs))
+		return
+	}
+	for _, ip := range ips {
+		if net.ParseIP(ip) == nil {
+			b.reply(chatID, "❌ 无效的IP地址: "+ip)
+			return
+		}
+	}
+
+	b.reply(chatID, fmt.Sprintf("🔍 正在检测 %d 个IP...", len(ips)))
+	ctx, cancel := b.withTimeout(autoCheckTimeout * maxCompareIPs)
+	defer cancel()
+
+	results := b.compareIPs(ctx, ips)
+	b.replyMarkdown(chatID, renderComparison(results))
+}
+
+// compareIPs checks purity with auto_check_workers concurrent browser checks
+// and looks up origin and blocklists. Results are in the same order as ips.
+func (b *Bot) compareIPs(ctx context.Context, ips []string) []comparison {
+	results := make([]comparison, len(ips))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < b.cfg.AutoCheckWorkers && w < len(ips); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = b.compareOne(ctx, ips[i])
+			}
+		}()
+	}
+
+	for i := range ips {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// compareOne gathers the details of one IP
+func (b *Bot) compareOne(ctx context.Context, ip string) comparison {
+	c := comparison{IP: ip}
+
+	checkCtx, cancel := context.WithTimeout(ctx, autoCheckTimeout)
+	c.Info, c.Err = b.checker.Check(checkCtx, ip)
+	cancel()
+
+	lookupCtx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	if origin, err := iplookup.LookupOrigin(lookupCtx, ip); err != nil {
+		logger.Debugf("Origin lookup of %s failed: %v", ip, err)
+	} else {
+		c.Country, c.ASN = origin.Country, origin.ASN
+	}
+	c.Listed, c.Failed = iplookup.Blocklisted(lookupCtx, ip, iplookup.DefaultBlocklists)
+	sort.Strings(c.Listed)
+	if whois, err := iplookup.LookupWhois(lookupCtx, ip); err != nil {
+		logger.Debugf("WHOIS of %s failed: %v", ip, err)
+	} else {
+		c.Org = whois.Org
+	}
+	return c
+}
+
+// better reports whether a is the better IP to keep: purer, then native, then
+// on fewer blocklists
+func (a comparison) better(other comparison) bool {
+	if (a.Info == nil) != (other.Info == nil) {
+		return a.Info != nil
+	}
+	if a.Info != nil {
+		if pa, po := purityValue(a.Info), purityValue(other.Info); pa != po {
+			return pa < po
+		}
+		if na, no := a.Info.IsNative == ippure.OriginNative, other.Info.IsNative == ippure.OriginNative; na != no {
+			return na
+		}
+	}
+	return len(a.Listed) < len(other.Listed)
+}
+
+// renderComparison formats the results as a table, one row per IP, with the
+// recommended IP marked
+func renderComparison(results []comparison) string {
+	best := 0
+	for i := range results {
+		if results[i].better(results[best]) {
+			best = i
+		}
+	}
+
+	header := []string{"IP", "纯净度", "类型", "来源", "国家", "黑名单"}
+	rows := [][]string{header}
+	for i, r := range results {
+		row := []string{r.IP, "失败", "-", "-", "?", fmt.Sprintf("%d/%d", len(r.Listed), len(iplookup.DefaultBlocklists)-len(r.Failed))}
+		if i == best {
+			row[0] = "★ " + r.IP
+		}
+		if r.Info != nil {
+			row[1], row[2], row[3] = r.Info.PurityScore, r.Info.IPType, r.Info.IsNative
+		}
+		if r.Country != "" {
+			row[4] = r.Country
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], displayWidth(cell))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("⚖️ *IP 对比*\n\n```\n")
+	for _, row := range rows {
+		for i, cell := range row {
+			sb.WriteString(strings.ReplaceAll(cell, "`", "'"))
+			if i < len(row)-1 {
+				sb.WriteString(strings.Repeat(" ", widths[i]-displayWidth(cell)+2))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("```\n")
+
+	for _, r := range results {
+		var notes []string
+		if r.Err != nil {
+			notes = append(notes, "检测失败: "+escapeMarkdown(r.Err.Error()))
+		}
+		if r.ASN != "" {
+			notes = append(notes, "AS"+r.ASN)
+		}
+		if r.Org != "" {
+			notes = append(notes, escapeMarkdown(r.Org))
+		}
+		if len(r.Listed) > 0 {
+			notes = append(notes, "列入 "+escapeMarkdown(strings.Join(r.Listed, ", ")))
+		}
+		if len(notes) > 0 {
+			sb.WriteString(fmt.Sprintf("`%s` %s\n", r.IP, strings.Join(notes, " · ")))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\n★ 建议保留 `%s` (纯净度优先，其次原生、黑名单少)", results[best].IP))
+	return sb.String()
+}
+
+// displayWidth approximates the monospace width of s: East Asian characters
+// take two columns
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if r >= 0x2E80 {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
@@ -0,0 +1,131 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// shortOCID abbreviates an OCID for button labels
+func shortOCID(id string) string {
+	if len(id) <= 12 {
+		return id
+	}
+	return "…" + id[len(id)-8:]
+}
+
+// handleMoveIPCallback handles mvip:<ip>[:<compartment>|:back]
+func (b *Bot) handleMoveIPCallback(chatID int64, ipRef string, parts []string) {
+	publicIPID, ok := b.resolveRef(ipRef)
+	if !ok {
+		b.reply(chatID, "⚠️ 按钮已过期，请重新使用 /listip")
+		return
+	}
+
+	b.mu.Lock()
+	client := b.currentClient
+	account := b.cfg.GetAccount(client.AccountName())
+	b.mu.Unlock()
+
+	if account == nil || len(account.ProtectedCompartments) == 0 {
+		b.reply(chatID, "⚠️ 当前账号未配置 protected_compartments")
+		return
+	}
+
+	if len(parts) < 3 {
+		var buttons [][]tgbotapi.InlineKeyboardButton
+		for _, id := range account.ProtectedCompartments {
+			btn := tgbotapi.NewInlineKeyboardButtonData("🔒 "+shortOCID(id), "mvip:"+ipRef+":"+b.callbackRef(id))
+			buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
+		}
+		msg := tgbotapi.NewMessage(chatID, "选择要转移到的受保护区间:")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+		b.send(msg)
+		return
+	}
+
+	target := account.CompartmentID
+	if parts[2] != "back" {
+		if target, ok = b.resolveRef(parts[2]); !ok {
+			b.reply(chatID, "⚠️ 按钮已过期，请重新使用 /listip")
+			return
+		}
+	}
+
+	release, err := b.acquireAccount(b.runCtx, chatID, client.AccountName())
+	if err != nil {
+		return
+	}
+	defer release()
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	if err := client.ChangePublicIPCompartment(ctx, publicIPID, target); err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	if account.IsProtectedCompartment(target) {
+		b.reply(chatID, "✅ 已转移到受保护区间，bot 不会删除该IP\n使用 /protected 查看或移回")
+	} else {
+		b.reply(chatID, "✅ 已移回工作区间")
+		b.showIPList(chatID)
+	}
+}
+
+// showProtectedIPs lists reserved IPs in the protected compartments of the current account
+func (b *Bot) showProtectedIPs(chatID int64) {
+	b.mu.Lock()
+	client := b.currentClient
+	account := b.cfg.GetAccount(client.AccountName())
+	b.mu.Unlock()
+
+	if account == nil || len(account.ProtectedCompartments) == 0 {
+		b.reply(chatID, "⚠️ 当前账号未配置 protected_compartments")
+		return
+	}
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	var sb strings.Builder
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	sb.WriteString(fmt.Sprintf("🔒 *[%s] 受保护的IP*\n", client.AccountName()))
+
+	for _, compartment := range account.ProtectedCompartments {
+		ips, err := client.ListReservedIPsIn(ctx, compartment)
+		if err != nil {
+			sb.WriteString(fmt.Sprintf("\n⚠️ %s: %s\n", shortOCID(compartment), escapeMarkdown(describeError(err))))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n📁 %s\n", shortOCID(compartment)))
+		if len(ips) == 0 {
+			sb.WriteString("暂无\n")
+		}
+		for _, ip := range ips {
+			sb.WriteString(fmt.Sprintf("• `%s`\n", ip.IPAddress))
+			btn := tgbotapi.NewInlineKeyboardButtonData("↩️ 移回 "+ip.IPAddress, "mvip:"+b.callbackRef(ip.ID)+":back")
+			buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	if len(buttons) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	}
+	b.send(msg)
+}
+
+// deleteErrorText explains a failed delete, calling out protected compartments
+func deleteErrorText(ipAddr string, err error) string {
+	if errors.Is(err, oci.ErrProtectedCompartment) {
+		return fmt.Sprintf("🔒 %s 位于受保护区间，拒绝删除", ipAddr)
+	}
+	return fmt.Sprintf("❌ 删除 %s 失败: %s", ipAddr, describeError(err))
+}
@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/crypto/ssh"
+	"rsc.io/qr"
+)
+
+var (
+	errInstanceGone = errors.New("instance not found")
+	errNoPublicIP   = errors.New("instance has no public IP yet")
+)
+
+// connectionButtons offers the connection info of an instance as a text file
+// and as a QR code
+func (b *Bot) connectionButtons(accountName, instanceID string) tgbotapi.InlineKeyboardMarkup {
+	ref := b.callbackRef(instanceID)
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📄 连接信息", "conn:txt:"+ref+":"+accountName),
+		tgbotapi.NewInlineKeyboardButtonData("🔳 二维码", "conn:qr:"+ref+":"+accountName),
+	))
+}
+
+// connection is what a terminal app needs to log in to an instance
+type connection struct {
+	Name         string
+	Account      string
+	Region       string
+	User         string
+	IP           string
+	Fingerprints []string // SHA256 fingerprints of the keys in vps_ssh_keys
+}
+
+// command is the ssh command line
+func (c *connection) command() string {
+	return fmt.Sprintf("ssh %s@%s", c.User, c.IP)
+}
+
+// uri is the ssh:// URI mobile terminal apps open from a QR code
+func (c *connection) uri() string {
+	return fmt.Sprintf("ssh://%s@%s", c.User, net.JoinHostPort(c.IP, "22"))
+}
+
+// text is the content of the connection info file
+func (c *connection) text() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s [%s] %s\n\n", c.Name, c.Account, c.Region))
+	sb.WriteString(c.command() + "\n")
+	sb.WriteString(c.uri() + "\n")
+	if len(c.Fingerprints) > 0 {
+		sb.WriteString("\n# 使用以下公钥对应的私钥登录:\n")
+		for _, fingerprint := range c.Fingerprints {
+			sb.WriteString(fingerprint + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// sshFingerprints returns the fingerprints of authorized_keys lines, with
+// their comments
+func sshFingerprints(authorizedKeys string) []string {
+	var fingerprints []string
+	rest := []byte(authorizedKeys)
+	for len(rest) > 0 {
+		key, comment, _, next, err := ssh.ParseAuthorizedKey(rest)
+		if err != nil {
+			break
+		}
+		fingerprint := ssh.FingerprintSHA256(key)
+		if comment != "" {
+			fingerprint += " " + comment
+		}
+		fingerprints = append(fingerprints, fingerprint)
+		rest = next
+	}
+	return fingerprints
+}
+
+// instanceConnection looks up the public IP of the instance's primary VNIC
+func (b *Bot) instanceConnection(client oci.Service, instanceID string) (*connection, error) {
+	ctx, cancel := b.withTimeout(reportTimeout)
+	defer cancel()
+
+	instances, err := client.ListInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var inst *oci.InstanceInfo
+	for i := range instances {
+		if instances[i].ID == instanceID {
+			inst = &instances[i]
+		}
+	}
+	if inst == nil {
+		return nil, errInstanceGone
+	}
+
+	vnics, err := client.GetInstanceNetwork(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	conn := &connection{Name: inst.DisplayName, Account: client.AccountName(), Region: client.Region()}
+	for _, vnic := range vnics {
+		for _, ip := range vnic.PrivateIPs {
+			if ip.PublicIP != nil && (conn.IP == "" || vnic.IsPrimary && ip.IsPrimary) {
+				conn.IP = ip.PublicIP.IPAddress
+			}
+		}
+	}
+	if conn.IP == "" {
+		return nil, errNoPublicIP
+	}
+
+	if account := b.accountConfig(client.AccountName()); account != nil {
+		conn.User = account.VPSSSHUser
+		conn.Fingerprints = sshFingerprints(account.VPSSSHKeys)
+	}
+	return conn, nil
+}
+
+// handleConnectionCallback handles conn:<txt|qr>:<instance ref>:<account>
+func (b *Bot) handleConnectionCallback(chatID int64, format string, parts []string) {
+	if len(parts) < 4 {
+		return
+	}
+	instanceID, ok := b.resolveRef(parts[2])
+	if !ok {
+		b.reply(chatID, "⚠️ 按钮已过期，请使用 /network 查看实例")
+		return
+	}
+	client, ok := b.client(parts[3])
+	if !ok {
+		b.reply(chatID, "❌ 账号不存在: "+parts[3])
+		return
+	}
+
+	conn, err := b.instanceConnection(client, instanceID)
+	switch {
+	case errors.Is(err, errInstanceGone):
+		b.reply(chatID, "❌ 实例不存在或已终止")
+		return
+	case errors.Is(err, errNoPublicIP):
+		b.reply(chatID, "⏳ 实例尚未分配公网IP，启动完成后再试")
+		return
+	case err != nil:
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	var file tgbotapi.Chattable
+	switch format {
+	case "txt":
+		doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: conn.Name + "-ssh.txt", Bytes: []byte(conn.text())})
+		doc.Caption = conn.command()
+		file = doc
+	case "qr":
+		code, err := qr.Encode(conn.uri(), qr.M)
+		if err != nil {
+			b.reply(chatID, "❌ 生成二维码失败: "+err.Error())
+			return
+		}
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: conn.Name + "-ssh.png", Bytes: code.PNG()})
+		photo.Caption = conn.command()
+		file = photo
+	default:
+		return
+	}
+	if _, err := b.sendFile(chatID, file); err != nil {
+		logger.Errorf("Failed to send connection info: %v", err)
+		b.reply(chatID, "❌ 发送失败: "+err.Error())
+	}
+}
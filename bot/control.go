@@ -0,0 +1,499 @@
+package bot
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"oci-bot/bot/events"
+	"oci-bot/config"
+	"oci-bot/ippure"
+)
+
+// Control socket: JSON-RPC 2.0 over a Unix socket, one JSON object per line,
+// so local scripts can start and stop tasks and follow their results. Access
+// is limited by the socket's file mode.
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcFailed         = -32000 // The call was understood but couldn't be done
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // Absent for notifications, which get no answer
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"` // Never empty on success
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcNotification is an event pushed to a subscribed client
+type rpcNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// invalidParams is the error for a call whose arguments are wrong
+func invalidParams(format string, args ...any) *rpcError {
+	return &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// controlConn is one connected client. Answers and events share the
+// connection, so writes are serialized.
+type controlConn struct {
+	conn        net.Conn
+	writeMu     sync.Mutex
+	unsubscribe func() // Set once the client subscribed to events
+}
+
+func (c *controlConn) write(v any) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return json.NewEncoder(c.conn).Encode(v)
+}
+
+// startControl listens on control_socket until ctx is done
+func (b *Bot) startControl(ctx context.Context) error {
+	path := b.cfg.ControlSocket
+	// A socket left behind by an unclean exit would make Listen fail
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := listenPrivate(path)
+	if err != nil {
+		return fmt.Errorf("control socket: %w", err)
+	}
+	logger.Infof("Control socket listening on %s", path)
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+		os.Remove(path)
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Errorf("Control socket error: %v", err)
+				}
+				return
+			}
+			go b.runRecovered("control connection", func() { b.serveControl(ctx, conn) })
+		}
+	}()
+	return nil
+}
+
+// listenPrivate listens on a Unix socket at path that only the bot's user can
+// connect to. The socket is made in a directory of its own with mode 0700
+// and moved into place once it is 0600, so there is no moment a client of
+// another user could connect.
+func listenPrivate(path string) (*net.UnixListener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".control-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// The socket is removed under its final name, see startControl
+	listener.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// serveControl answers the calls of one client until it disconnects
+func (b *Bot) serveControl(ctx context.Context, conn net.Conn) {
+	c := &controlConn{conn: conn}
+	defer func() {
+		if c.unsubscribe != nil {
+			c.unsubscribe()
+		}
+		conn.Close()
+	}()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var req rpcRequest
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			c.write(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+			continue
+		}
+		result, err := b.controlCall(ctx, c, req)
+		if req.ID == nil {
+			continue
+		}
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+		if err != nil {
+			var rerr *rpcError
+			if !errors.As(err, &rerr) {
+				rerr = &rpcError{Code: rpcFailed, Message: err.Error()}
+			}
+			resp.Result, resp.Error = nil, rerr
+		}
+		if err := c.write(resp); err != nil {
+			logger.Debugf("Control socket write failed: %v", err)
+			return
+		}
+	}
+}
+
+// controlCall runs one method
+func (b *Bot) controlCall(ctx context.Context, c *controlConn, req rpcRequest) (any, error) {
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return nil, &rpcError{Code: rpcInvalidRequest, Message: "not a JSON-RPC 2.0 request"}
+	}
+	logger.Debugf("Control call %s", req.Method)
+
+	switch req.Method {
+	case "status":
+		return b.controlStatus(), nil
+	case "accounts":
+		return b.controlAccounts(), nil
+	case "autoip.start":
+		var params controlAutoIPParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return true, b.controlStartAutoIP(params)
+	case "autoip.stop":
+		var params struct {
+			Soft bool `json:"soft"` // Finish the current attempt first
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return true, b.controlStopAutoIP(params.Soft)
+	case "autovps.start":
+		var params controlAutoVPSParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return true, b.controlStartAutoVPS(params)
+	case "autovps.stop":
+		return true, b.controlStopAutoVPS()
+	case "subscribe":
+		if c.unsubscribe == nil {
+			ch, unsubscribe := b.events.subscribe("control client")
+			c.unsubscribe = unsubscribe
+			go b.forwardEvents(ctx, c, ch)
+		}
+		return true, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "unknown method " + req.Method}
+}
+
+// decodeParams reads the named parameters of a call, rejecting unknown ones
+func decodeParams(raw json.RawMessage, v any) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return invalidParams("%v", err)
+	}
+	return nil
+}
+
+// forwardEvents sends events to a subscribed client until it disconnects
+func (b *Bot) forwardEvents(ctx context.Context, c *controlConn, ch <-chan events.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-ch:
+			if err := c.write(rpcNotification{JSONRPC: "2.0", Method: "event", Params: e}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// controlTask describes a running task in "status"
+type controlTask struct {
+	Account  string `json:"account"`
+	Arch     string `json:"arch,omitempty"`
+	Paused   bool   `json:"paused,omitempty"`
+	Stopping bool   `json:"stopping,omitempty"`
+}
+
+func (b *Bot) controlStatus() map[string]*controlTask {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := map[string]*controlTask{"autoip": nil, "autovps": nil}
+	if c := b.autoApply; c != nil && c.Active {
+		status["autoip"] = &controlTask{Account: c.AccountName, Paused: c.Paused, Stopping: c.Stopping}
+	}
+	if c := b.autoVPS; c != nil && c.Active {
+		status["autovps"] = &controlTask{Account: c.AccountName, Arch: c.Arch}
+	}
+	return status
+}
+
+// controlAccount is an account in "accounts"
+type controlAccount struct {
+	Name   string `json:"name"`
+	Region string `json:"region"`
+}
+
+func (b *Bot) controlAccounts() []controlAccount {
+	accounts := []controlAccount{}
+	for _, client := range b.sortedClients() {
+		accounts = append(accounts, controlAccount{Name: client.AccountName(), Region: client.Region()})
+	}
+	return accounts
+}
+
+// controlAutoIPParams are the arguments of autoip.start. Criteria left out
+// come from the profile if one is named, otherwise from the account's autoip_*
+// preset.
+type controlAutoIPParams struct {
+	Account     string `json:"account"`
+	Profile     string `json:"profile"`
+	Purity      int    `json:"purity"`
+	Native      string `json:"native"` // native / non-native / any
+	Type        string `json:"type"`   // datacenter / residential / any
+	Mode        string `json:"mode"`   // all / any
+	IntervalMin int    `json:"interval_min"`
+	IntervalMax int    `json:"interval_max"`
+}
+
+// controlStartAutoIP starts auto-apply, keeping the account's existing IPs
+func (b *Bot) controlStartAutoIP(params controlAutoIPParams) error {
+	b.mu.Lock()
+	task, err := b.controlAutoIPTaskLocked(params)
+	if err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	task.ChatID = b.alertChatID()
+	b.autoApply = task
+	b.mu.Unlock()
+
+	logger.Infof("[%s] Starting auto-apply from the control socket", task.AccountName)
+	b.replyIn(task.ChatID, topicAuto, fmt.Sprintf("🔌 [%s] 控制接口启动刷IP", task.AccountName))
+	return b.doStartAutoApply(task.ChatID, task)
+}
+
+// controlAutoIPTaskLocked checks that auto-apply can start and puts its
+// configuration together. The caller holds b.mu.
+func (b *Bot) controlAutoIPTaskLocked(params controlAutoIPParams) (*AutoApplyConfig, error) {
+	if running := b.autoApply; running != nil && running.Active {
+		return nil, fmt.Errorf("auto-apply is already running on %s", running.AccountName)
+	}
+	if _, ok := b.clients[params.Account]; !ok {
+		return nil, invalidParams("unknown account %q", params.Account)
+	}
+	task := &AutoApplyConfig{
+		AccountName:     params.Account,
+		PurityThreshold: 100,
+		NativeRequired:  "any",
+		TypeRequired:    "any",
+		MatchMode:       "all",
+		IntervalMin:     300,
+		IntervalMax:     300,
+	}
+	if params.Profile != "" {
+		profile, ok := b.profiles[params.Profile]
+		if !ok {
+			return nil, invalidParams("unknown profile %q", params.Profile)
+		}
+		task.PurityThreshold, task.NativeRequired, task.TypeRequired, task.MatchMode =
+			profile.PurityThreshold, profile.NativeRequired, profile.typeRequired(), profile.MatchMode
+		task.IntervalMin, task.IntervalMax = profile.IntervalMin, profile.IntervalMax
+	} else if account := b.accountConfigLocked(params.Account); account != nil && account.AutoIP != nil {
+		preset := account.AutoIP
+		task.PurityThreshold, task.NativeRequired, task.TypeRequired, task.MatchMode =
+			preset.PurityThreshold, preset.NativeRequired, preset.TypeRequired, preset.MatchMode
+		task.IntervalMin, task.IntervalMax = preset.IntervalMin, preset.IntervalMax
+	}
+	if err := applyAutoIPParams(task, params); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// applyAutoIPParams overrides the task's criteria with those given in the call
+func applyAutoIPParams(task *AutoApplyConfig, params controlAutoIPParams) error {
+	if params.Purity != 0 {
+		if params.Purity < 1 || params.Purity > 100 {
+			return invalidParams("purity must be between 1 and 100")
+		}
+		task.PurityThreshold = params.Purity
+	}
+	switch strings.ToLower(params.Native) {
+	case "":
+	case "native":
+		task.NativeRequired = ippure.OriginNative
+	case "non-native":
+		task.NativeRequired = ippure.OriginNonNative
+	case "any":
+		task.NativeRequired = "any"
+	default:
+		return invalidParams("native must be native, non-native or any")
+	}
+	switch strings.ToLower(params.Type) {
+	case "":
+	case "datacenter":
+		task.TypeRequired = ippure.TypeDatacenter
+	case "residential":
+		task.TypeRequired = ippure.TypeResidential
+	case "any":
+		task.TypeRequired = "any"
+	default:
+		return invalidParams("type must be datacenter, residential or any")
+	}
+	switch params.Mode {
+	case "":
+	case "all", "any":
+		task.MatchMode = params.Mode
+	default:
+		return invalidParams("mode must be all or any")
+	}
+	if params.IntervalMin != 0 {
+		task.IntervalMin, task.IntervalMax = params.IntervalMin, max(params.IntervalMax, params.IntervalMin)
+	}
+	if task.IntervalMin < 10 {
+		return invalidParams("interval_min must be at least 10 seconds")
+	}
+	return nil
+}
+
+// controlStopAutoIP stops auto-apply, after the current attempt when soft
+func (b *Bot) controlStopAutoIP(soft bool) error {
+	b.mu.Lock()
+	running := b.autoApply
+	b.mu.Unlock()
+	if running == nil || !running.Active {
+		return errors.New("auto-apply is not running")
+	}
+
+	logger.Infof("[%s] Stopping auto-apply from the control socket", running.AccountName)
+	if soft {
+		b.softStopAutoApply(running.ChatID)
+	} else {
+		b.stopAutoApply(running.ChatID)
+	}
+	return nil
+}
+
+// controlAutoVPSParams are the arguments of autovps.start
+type controlAutoVPSParams struct {
+	Account     string `json:"account"`
+	Arch        string `json:"arch"` // arm / amd / preset name
+	IntervalMin int    `json:"interval_min"`
+	IntervalMax int    `json:"interval_max"`
+}
+
+// controlStartAutoVPS starts auto-VPS with the account's vps_* settings
+func (b *Bot) controlStartAutoVPS(params controlAutoVPSParams) error {
+	if params.IntervalMin == 0 {
+		params.IntervalMin = 60
+	}
+	if params.IntervalMin < 10 {
+		return invalidParams("interval_min must be at least 10 seconds")
+	}
+
+	b.mu.Lock()
+	if running := b.autoVPS; running != nil && running.Active {
+		b.mu.Unlock()
+		return fmt.Errorf("auto-VPS is already running on %s", running.AccountName)
+	}
+	_, ok := b.clients[params.Account]
+	var account *config.OCIAccount
+	if ok {
+		account = b.accountConfigLocked(params.Account)
+	}
+	b.mu.Unlock()
+	if account == nil {
+		return invalidParams("unknown account %q", params.Account)
+	}
+	if _, ok := account.VPSTemplate(params.Arch); !ok {
+		return invalidParams("arch must be arm, amd or a preset of the account")
+	}
+	if err := account.ValidateVPSConfig(params.Arch); err != nil {
+		return err
+	}
+	provision, err := b.accountProvisioning(account)
+	if err != nil {
+		return fmt.Errorf("recipe %s: %w", account.VPSRecipe, err)
+	}
+
+	task := &AutoVPSConfig{
+		AccountName: params.Account,
+		Arch:        params.Arch,
+		IntervalMin: params.IntervalMin,
+		IntervalMax: max(params.IntervalMax, params.IntervalMin),
+		Provision:   provision,
+	}
+	b.mu.Lock()
+	if running := b.autoVPS; running != nil && running.Active {
+		b.mu.Unlock()
+		return fmt.Errorf("auto-VPS is already running on %s", running.AccountName)
+	}
+	b.autoVPS = task
+	b.mu.Unlock()
+
+	logger.Infof("[%s] Starting auto-VPS from the control socket", task.AccountName)
+	b.doStartAutoVPS(b.alertChatID(), account, task)
+	return nil
+}
+
+// controlStopAutoVPS stops auto-VPS
+func (b *Bot) controlStopAutoVPS() error {
+	b.mu.Lock()
+	running := b.autoVPS
+	b.mu.Unlock()
+	if running == nil || !running.Active {
+		return errors.New("auto-VPS is not running")
+	}
+
+	logger.Infof("[%s] Stopping auto-VPS from the control socket", running.AccountName)
+	b.stopAutoVPS(running.ChatID)
+	return nil
+}
@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"oci-bot/oci/ocifake"
+)
+
+func TestControlSocket(t *testing.T) {
+	b, _ := newTestBot(t, ocifake.New("main", "ap-tokyo-1"))
+	// Unix socket paths are short, keep it out of the long test directory
+	dir, err := os.MkdirTemp("", "ctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	b.cfg.ControlSocket = filepath.Join(dir, "oci-bot.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := b.startControl(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(b.cfg.ControlSocket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode(); mode&os.ModeSocket == 0 || mode.Perm() != 0600 {
+		t.Errorf("socket mode %v, want a socket with 0600", mode)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("socket directory holds %v, want only the socket", entries)
+	}
+
+	conn, err := net.Dial("unix", b.cfg.ControlSocket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"accounts"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(line, `"main"`) {
+		t.Errorf("accounts answered %s", line)
+	}
+
+	// Stopping the bot drops the clients and the socket
+	cancel()
+	if _, err := reader.ReadString('\n'); err == nil {
+		t.Error("connection still open after stop")
+	}
+	waitFor(t, "the socket removal", func() bool {
+		_, err := os.Stat(b.cfg.ControlSocket)
+		return os.IsNotExist(err)
+	})
+}
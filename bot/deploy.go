@@ -0,0 +1,362 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"oci-bot/bot/events"
+	"oci-bot/bot/workflow"
+	"oci-bot/config"
+	"oci-bot/oci"
+)
+
+// deployment is the data of a /deploy job: an instance, a clean IP bound to
+// it and the recipe applied
+type deployment struct {
+	Account     string        `json:"account"`
+	Arch        string        `json:"arch"`
+	Criteria    autoIPProfile `json:"criteria"`               // Of the clean IP; the interval paces launches too
+	LaunchName  string        `json:"launch_name,omitempty"`  // Display name of the launch attempt
+	LaunchNonce string        `json:"launch_nonce,omitempty"` // Retry token nonce of the launch attempt
+	InstanceID  string        `json:"instance_id,omitempty"`
+	Launched    time.Time     `json:"launched"`
+	PublicIPID  string        `json:"public_ip_id,omitempty"`
+	IP          string        `json:"ip,omitempty"`
+	Recipe      string        `json:"recipe,omitempty"`
+	Params      *recipeParams `json:"params,omitempty"` // Secrets of the recipe, rendered into cloud-init at launch
+}
+
+// deployWorkflow declares the steps of /deploy
+func (b *Bot) deployWorkflow() workflow.Definition {
+	return workflow.Definition{
+		Kind:    jobDeploy,
+		Title:   "一键部署",
+		NewData: func() any { return &deployment{} },
+		Report:  b.reportDeploy,
+		Steps: []workflow.Step{
+			{Name: "launch", Title: "抢实例", Run: b.deployLaunchStep},
+			{Name: "ip", Title: "刷IP", Run: b.deployIPStep},
+			// Binding fails now and then while the instance is still settling
+			{Name: "bind", Title: "绑定IP", Retries: 2, RetryDelay: 30 * time.Second, Run: b.deployBindStep},
+			{Name: "probe", Title: "连通性检测", Run: b.deployProbeStep},
+			{Name: "provision", Title: "部署配方", Run: b.deployProvisionStep},
+		},
+	}
+}
+
+// reportDeploy announces a finished deployment
+func (b *Bot) reportDeploy(job workflow.Job, e workflow.Event) {
+	if e.Type != workflow.Finished {
+		b.reportJob(job, e)
+		return
+	}
+	d := job.Data.(*deployment)
+	took := time.Since(job.Started).Round(time.Second)
+	logger.Infof("Deployment on %s finished after %s", d.Account, took)
+	b.notify(job.ChatID, topicAuto, fmt.Sprintf("🎉 [%s] 部署完成，用时 %s\nIP: %s", d.Account, took, d.IP))
+}
+
+// handleDeploy handles /deploy [<账号> [arm|amd|预设] [方案] | resume | stop]
+func (b *Bot) handleDeploy(chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		b.showDeployment(chatID)
+		return
+	}
+	switch fields[0] {
+	case "resume":
+		if _, ok := b.jobs.Get(jobDeploy); !ok {
+			b.reply(chatID, "⚠️ 没有未完成的部署")
+			return
+		}
+		b.resumeJob(chatID, jobDeploy)
+	case "stop":
+		b.stopDeployment(chatID)
+	default:
+		b.startDeployment(chatID, fields)
+	}
+}
+
+// deployUsage explains /deploy
+const deployUsage = `用法: /deploy <账号> [arm|amd|预设] [方案]
+抢到实例后自动刷一个符合条件的IP，绑定到实例，等待 SSH 可连接后部署账号的 vps_recipe
+条件使用指定的 /profiles 方案，否则使用账号的 autoip_* 预设
+/deploy resume - 从中断的步骤继续
+/deploy stop - 停止并放弃（已创建的实例和IP保留）`
+
+// startDeployment starts a new deployment on an account
+func (b *Bot) startDeployment(chatID int64, fields []string) {
+	name := fields[0]
+	account := b.accountConfig(name)
+	if _, ok := b.client(name); !ok || account == nil {
+		b.reply(chatID, "❌ 账号不存在: "+name+"\n\n"+deployUsage)
+		return
+	}
+	arch := "arm"
+	profileName := ""
+	for _, field := range fields[1:] {
+		if _, ok := account.VPSTemplate(field); ok {
+			arch = field
+		} else {
+			profileName = field
+		}
+	}
+	if err := account.ValidateVPSConfig(arch); err != nil {
+		b.reply(chatID, "❌ VPS配置错误: "+err.Error())
+		return
+	}
+	d := &deployment{Account: name, Arch: arch}
+	if account.VPSRecipe != "" {
+		provision, err := b.accountProvisioning(account)
+		if err != nil {
+			b.reply(chatID, fmt.Sprintf("❌ 配方 %s 无效: %v", account.VPSRecipe, err))
+			return
+		}
+		d.Recipe, d.Params = account.VPSRecipe, &provision.Params
+	}
+
+	b.mu.Lock()
+	profile, ok := b.profiles[profileName]
+	b.mu.Unlock()
+	switch {
+	case profileName != "" && !ok:
+		b.reply(chatID, "❌ 方案不存在: "+profileName)
+		return
+	case ok:
+		d.Criteria = profile
+		d.Criteria.TypeRequired = profile.typeRequired()
+	case account.AutoIP != nil:
+		preset := account.AutoIP
+		d.Criteria = autoIPProfile{PurityThreshold: preset.PurityThreshold, NativeRequired: preset.NativeRequired,
+			TypeRequired: preset.TypeRequired, MatchMode: preset.MatchMode, IntervalMin: preset.IntervalMin, IntervalMax: preset.IntervalMax}
+	default:
+		b.reply(chatID, "❌ 请指定 /profiles 中的方案，或为账号配置 autoip_* 预设\n\n"+deployUsage)
+		return
+	}
+
+	if running, ok := b.jobs.Get(jobDeploy); ok {
+		b.reply(chatID, fmt.Sprintf("⚠️ [%s] 的部署尚未完成，使用 /deploy 查看，/deploy stop 放弃", running.Label))
+		return
+	}
+	if err := b.jobs.Start(b.runCtx, jobDeploy, jobDeploy, name, chatID, d); err != nil {
+		b.reply(chatID, "❌ 无法开始部署: "+err.Error())
+		return
+	}
+
+	text := fmt.Sprintf("🚀 [%s] 开始部署 %s 实例\nIP条件: %s", name, strings.ToUpper(arch), d.Criteria.summary())
+	if d.Recipe != "" {
+		text += "\n配方: " + d.Recipe
+	}
+	b.replyIn(chatID, topicAuto, text+"\n使用 /deploy 查看进度，/deploy stop 停止")
+}
+
+// stopDeployment cancels the deployment and drops its checkpoint. What it
+// created so far stays.
+func (b *Bot) stopDeployment(chatID int64) {
+	job, ok := b.jobs.Stop(jobDeploy)
+	if !ok {
+		b.reply(chatID, "⚠️ 没有未完成的部署")
+		return
+	}
+
+	d := job.Data.(*deployment)
+	text := fmt.Sprintf("⏹ [%s] 已停止部署", d.Account)
+	if d.InstanceID != "" {
+		text += "\n已创建的实例保留: " + d.InstanceID
+	}
+	if d.IP != "" {
+		text += "\n已找到的IP保留: " + d.IP
+	}
+	b.replyIn(chatID, topicAuto, text)
+}
+
+// showDeployment shows the progress of the deployment
+func (b *Bot) showDeployment(chatID int64) {
+	job, ok := b.jobs.Get(jobDeploy)
+	if !ok {
+		b.reply(chatID, "💤 没有进行中的部署\n\n"+deployUsage)
+		return
+	}
+
+	d := job.Data.(*deployment)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🧩 [%s] %s 部署，已运行 %s\n\n", d.Account, strings.ToUpper(d.Arch),
+		time.Since(job.Started).Round(time.Second)))
+	sb.WriteString(jobProgress(job))
+	if d.InstanceID != "" {
+		sb.WriteString("\n实例: " + d.InstanceID)
+	}
+	if d.IP != "" {
+		sb.WriteString("\nIP: " + d.IP)
+	}
+	if !job.Running {
+		sb.WriteString("\n\n已中断，使用 /deploy resume 继续，/deploy stop 放弃")
+	}
+	b.reply(chatID, sb.String())
+}
+
+// deploymentTarget returns the deployment of a job with its account
+func (b *Bot) deploymentTarget(job *workflow.Job) (*deployment, oci.Service, *config.OCIAccount, error) {
+	d := job.Data.(*deployment)
+	client, ok := b.client(d.Account)
+	account := b.accountConfig(d.Account)
+	if !ok || account == nil {
+		return nil, nil, nil, fmt.Errorf("account %s is gone or disabled", d.Account)
+	}
+	return d, client, account, nil
+}
+
+// deployLaunchStep launches the instance, retrying while out of capacity
+func (b *Bot) deployLaunchStep(ctx context.Context, job *workflow.Job) error {
+	d, client, account, err := b.deploymentTarget(job)
+	if err != nil {
+		return err
+	}
+	userData := ""
+	if d.Params != nil {
+		provision, err := b.savedProvisioning(d.Recipe, d.Params)
+		if err != nil {
+			return err
+		}
+		userData = provision.UserData
+	}
+
+	pace := b.newPacer(d.Criteria.IntervalMin, d.Criteria.IntervalMax)
+	instance, launched, attempts, err := b.launchVPS(ctx, client, account, d.Arch, userData, pace, "deploy",
+		launchAttempt{d.LaunchName, d.LaunchNonce},
+		func(a launchAttempt) { job.Update(func() { d.LaunchName, d.LaunchNonce = a.Name, a.Nonce }) })
+	if err != nil {
+		return err
+	}
+	if instance.Id == nil {
+		return fmt.Errorf("launch returned no instance ID")
+	}
+
+	job.Update(func() { d.InstanceID, d.Launched = *instance.Id, launched })
+	logger.Infof("Deployment on %s launched %s after %d attempts", d.Account, d.InstanceID, attempts)
+	b.notify(job.ChatID, topicAuto, fmt.Sprintf("✅ [%s] 实例已创建 (尝试 %d 次)，开始刷IP\n%s", d.Account, attempts, d.InstanceID))
+	b.publish(events.Event{Type: events.InstanceLaunched, Account: d.Account, InstanceID: d.InstanceID, Attempts: attempts})
+
+	if instance.WorkRequestID == "" {
+		return nil
+	}
+	trackCtx, trackCancel := context.WithTimeout(ctx, workRequestTimeout)
+	defer trackCancel()
+	return b.trackWorkRequest(trackCtx, job.ChatID, topicAuto, client, instance.WorkRequestID, fmt.Sprintf("[%s] 实例启动", d.Account))
+}
+
+// deployIPStep runs auto-apply with the deployment's criteria until it keeps
+// a matching IP. /status, /tune and /stopauto work on it as usual; /stopauto
+// pauses the deployment.
+func (b *Bot) deployIPStep(ctx context.Context, job *workflow.Job) error {
+	d, client, _, err := b.deploymentTarget(job)
+	if err != nil {
+		return err
+	}
+	task := d.Criteria.task(d.Account)
+	b.mu.Lock()
+	if running := b.autoApply; running != nil && running.Active {
+		b.mu.Unlock()
+		return fmt.Errorf("auto-apply is already running on %s", running.AccountName)
+	}
+	b.autoApply = task
+	b.mu.Unlock()
+
+	kept := b.runAutoApplyFor(ctx, job.ChatID, client, task)
+	if kept == nil {
+		return workflow.ErrPause
+	}
+	job.Update(func() { d.PublicIPID, d.IP = kept.ID, kept.IPAddress })
+	return nil
+}
+
+// deployBindStep binds the IP to the primary private IP of the instance in
+// place of the ephemeral public IP it was launched with
+func (b *Bot) deployBindStep(ctx context.Context, job *workflow.Job) error {
+	d, client, _, err := b.deploymentTarget(job)
+	if err != nil {
+		return err
+	}
+	// Hold the queue across reading and changing the binding, so another
+	// operation can't rebind the private IP in between
+	release, err := b.acquireAccount(ctx, 0, d.Account)
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(ctx, assignTimeout)
+	defer cancel()
+
+	vnics, err := client.GetInstanceNetwork(ctx, d.InstanceID)
+	if err != nil {
+		return err
+	}
+	var private *oci.PrivateIPInfo
+	for _, vnic := range vnics {
+		for i, ip := range vnic.PrivateIPs {
+			if vnic.IsPrimary && ip.IsPrimary {
+				private = &vnic.PrivateIPs[i]
+			}
+		}
+	}
+	if private == nil {
+		return fmt.Errorf("instance %s has no primary private IP", d.InstanceID)
+	}
+
+	if current := private.PublicIP; current != nil {
+		if current.ID == d.PublicIPID {
+			return nil // Bound before a restart
+		}
+		// A private IP maps to one public IP at a time
+		if current.Lifetime == "RESERVED" {
+			err = client.AssignReservedIP(ctx, current.ID, "")
+		} else {
+			err = client.DeleteReservedIP(ctx, current.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to free the primary private IP: %w", err)
+		}
+	}
+	if err := client.AssignReservedIP(ctx, d.PublicIPID, private.ID); err != nil {
+		return err
+	}
+	logger.Infof("Deployment bound %s to %s", d.IP, d.InstanceID)
+	b.replyIn(job.ChatID, topicAuto, fmt.Sprintf("🔗 [%s] IP %s 已绑定到实例", d.Account, d.IP))
+	return nil
+}
+
+// deployProbeStep waits until sshd answers on the bound IP
+func (b *Bot) deployProbeStep(ctx context.Context, job *workflow.Job) error {
+	d, client, _, err := b.deploymentTarget(job)
+	if err != nil {
+		return err
+	}
+	conn := b.waitSSHReady(ctx, job.ChatID, topicAuto, client, d.InstanceID, d.Launched)
+	if conn == nil {
+		return fmt.Errorf("instance not reachable over SSH")
+	}
+	if conn.IP != d.IP {
+		return fmt.Errorf("instance answers on %s instead of %s", conn.IP, d.IP)
+	}
+	return nil
+}
+
+// deployProvisionStep applies the recipe chosen at the start, with the
+// secrets already rendered into cloud-init
+func (b *Bot) deployProvisionStep(ctx context.Context, job *workflow.Job) error {
+	d, client, _, err := b.deploymentTarget(job)
+	if err != nil || d.Params == nil {
+		return err
+	}
+	provision, err := b.savedProvisioning(d.Recipe, d.Params)
+	if err != nil {
+		return err
+	}
+	conn, err := b.instanceConnection(client, d.InstanceID)
+	if err != nil {
+		return err
+	}
+	return b.provision(job.ChatID, topicAuto, client, d.InstanceID, conn, provision)
+}
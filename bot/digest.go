@@ -0,0 +1,225 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"oci-bot/oci"
+	"oci-bot/schedule"
+	"oci-bot/state"
+)
+
+const (
+	digestKey        = "digest" // State section holding the counters of the current digest period
+	maxDigestPeriod  = 7 * 24 * time.Hour
+	maxDigestChanges = 10 // Instance changes listed per account
+)
+
+// instanceEvents are the audit events reported as instance state changes
+var instanceEvents = map[string]string{
+	"LaunchInstance":    "创建",
+	"TerminateInstance": "终止",
+	"InstanceAction":    "电源操作",
+	"UpdateInstance":    "修改",
+}
+
+// digestState counts what happened since the last digest and remembers the
+// usage reported in it, so the next one can show the change
+type digestState struct {
+	Since         time.Time          `json:"since"`
+	AutoAttempts  int                `json:"auto_attempts"`
+	AutoMatches   int                `json:"auto_matches"`
+	CheckFailures int                `json:"check_failures"`
+	ReservedIPs   map[string]int     `json:"reserved_ips,omitempty"` // Account -> reserved IPs at the last digest
+	Cost          map[string]float64 `json:"cost,omitempty"`         // Account -> month-to-date cost at the last digest
+	CostMonth     string             `json:"cost_month,omitempty"`   // Month of Cost, "2006-01"
+}
+
+// loadDigest reads the digest counters from the store
+func loadDigest(store *state.Store) (*digestState, error) {
+	digest := &digestState{}
+	if err := store.Get(digestKey, digest); err != nil {
+		return nil, err
+	}
+	if digest.Since.IsZero() {
+		digest.Since = time.Now()
+	}
+	return digest, nil
+}
+
+// countDigest updates the digest counters and persists them
+func (b *Bot) countDigest(update func(d *digestState)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	update(b.digest)
+	if err := b.store.Set(digestKey, b.digest); err != nil {
+		logger.Errorf("Failed to save digest counters: %v", err)
+	}
+}
+
+// runDigest sends the digest at the times of digest_schedule
+func (b *Bot) runDigest(ctx context.Context) {
+	cron, err := schedule.Parse(b.cfg.DigestSchedule)
+	if err != nil {
+		logger.Errorf("Invalid digest_schedule: %v", err) // Already validated by config.Validate
+		return
+	}
+	logger.Infof("Daily digest started (%q)", b.cfg.DigestSchedule)
+
+	for {
+		next := cron.Next(time.Now())
+		if next.IsZero() {
+			logger.Errorf("Digest schedule never fires")
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		b.sendDigest(ctx)
+	}
+}
+
+// digestUsage is the usage of one account when the digest is sent
+type digestUsage struct {
+	Created, Deleted int      // Reserved IP creations and deletions in the audit log
+	Instances        []string // Instance changes from the audit log
+	ReservedIPs      int      // -1 when the list failed
+	Cost             *oci.CostSummary
+	AuditErr         error
+}
+
+// sendDigest summarizes the period since the last digest and starts a new one
+func (b *Bot) sendDigest(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, batchTimeout)
+	defer cancel()
+
+	b.mu.Lock()
+	previous := *b.digest
+	b.mu.Unlock()
+
+	end := time.Now()
+	start := previous.Since
+	if end.Sub(start) > maxDigestPeriod {
+		start = end.Add(-maxDigestPeriod)
+	}
+	month := end.Format("2006-01")
+
+	clients := b.sortedClients()
+	usage := make(map[string]digestUsage, len(clients))
+	for _, client := range clients {
+		name := client.AccountName()
+		usage[name] = b.digestUsage(ctx, client, start, end)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📰 *每日摘要* (%s 起)\n\n", start.Format("01-02 15:04")))
+	sb.WriteString(fmt.Sprintf("🔄 自动刷IP: 尝试 %d 次，成功 %d 次，检测失败 %d 个\n", previous.AutoAttempts, previous.AutoMatches, previous.CheckFailures))
+
+	for _, client := range clients {
+		name := client.AccountName()
+		u := usage[name]
+		sb.WriteString(fmt.Sprintf("\n*[%s]*\n", escapeMarkdown(name)))
+		if u.AuditErr != nil {
+			sb.WriteString("审计日志读取失败: " + escapeMarkdown(describeError(u.AuditErr)) + "\n")
+		} else {
+			sb.WriteString(fmt.Sprintf("预留IP: 创建 %d · 删除 %d\n", u.Created, u.Deleted))
+		}
+		if u.ReservedIPs >= 0 {
+			line := fmt.Sprintf("当前预留IP: %d", u.ReservedIPs)
+			if before, ok := previous.ReservedIPs[name]; ok {
+				line += fmt.Sprintf(" (%+d)", u.ReservedIPs-before)
+			}
+			sb.WriteString(line + "\n")
+		}
+		if u.Cost != nil {
+			line := fmt.Sprintf("本月费用: %.2f %s", u.Cost.Total, u.Cost.Currency)
+			if before, ok := previous.Cost[name]; ok && previous.CostMonth == month {
+				line += fmt.Sprintf(" (%+.2f)", u.Cost.Total-before)
+			}
+			sb.WriteString(line + "\n")
+		}
+		if len(u.Instances) > 0 {
+			sb.WriteString("实例变化:\n")
+			for _, change := range u.Instances {
+				sb.WriteString("• " + escapeMarkdown(change) + "\n")
+			}
+		}
+	}
+	b.alertMarkdown(sb.String())
+
+	b.countDigest(func(d *digestState) {
+		*d = digestState{
+			Since:       end,
+			ReservedIPs: make(map[string]int),
+			Cost:        make(map[string]float64),
+			CostMonth:   month,
+			// Counted while the digest was being built
+			AutoAttempts:  d.AutoAttempts - previous.AutoAttempts,
+			AutoMatches:   d.AutoMatches - previous.AutoMatches,
+			CheckFailures: d.CheckFailures - previous.CheckFailures,
+		}
+		for name, u := range usage {
+			if u.ReservedIPs >= 0 {
+				d.ReservedIPs[name] = u.ReservedIPs
+			}
+			if u.Cost != nil {
+				d.Cost[name] = u.Cost.Total
+			}
+		}
+	})
+}
+
+// digestUsage reads what one account did between start and end and its
+// current usage. Failures leave the parts empty.
+func (b *Bot) digestUsage(ctx context.Context, client oci.Service, start, end time.Time) digestUsage {
+	u := digestUsage{ReservedIPs: -1}
+
+	auditCtx, cancel := context.WithTimeout(ctx, reportTimeout)
+	events, err := client.ListWriteEvents(auditCtx, start, end)
+	cancel()
+	if err != nil {
+		logger.Warnf("[%s] Digest audit read failed: %v", client.AccountName(), err)
+		u.AuditErr = err
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	hidden := 0
+	for _, ev := range events {
+		switch {
+		case ev.EventName == "CreatePublicIp":
+			u.Created++
+		case ev.EventName == "DeletePublicIp":
+			u.Deleted++
+		case instanceEvents[ev.EventName] != "":
+			if len(u.Instances) == maxDigestChanges {
+				hidden++
+				continue
+			}
+			u.Instances = append(u.Instances, fmt.Sprintf("%s %s %s", ev.Time.Local().Format("01-02 15:04"), instanceEvents[ev.EventName], ev.ResourceName))
+		}
+	}
+	if hidden > 0 {
+		u.Instances = append(u.Instances, fmt.Sprintf("… 另有 %d 项", hidden))
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, callTimeout)
+	ips, err := client.ListReservedIPs(listCtx)
+	cancel()
+	if err == nil {
+		u.ReservedIPs = len(ips)
+	} else {
+		logger.Warnf("[%s] Digest IP list failed: %v", client.AccountName(), err)
+	}
+
+	costCtx, cancel := context.WithTimeout(ctx, reportTimeout)
+	u.Cost, err = client.MonthToDateCost(costCtx)
+	cancel()
+	if err != nil {
+		logger.Debugf("[%s] Digest cost read failed: %v", client.AccountName(), err)
+	}
+	return u
+}
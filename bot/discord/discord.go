@@ -0,0 +1,561 @@
+// Package discord runs the bot on Discord. Its Transport takes the Telegram
+// Bot API calls the bot makes and sends Discord messages with buttons instead;
+// slash commands, typed /commands and button clicks come back as Telegram
+// updates.
+//
+// Chat IDs are Discord snowflakes: a direct message chat has the user's ID,
+// as Telegram private chats do, a server channel its channel ID.
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"oci-bot/bot/platform"
+	"oci-bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/gorilla/websocket"
+)
+
+var logger = logging.New("discord")
+
+// Discord's limits on message components
+const (
+	maxRows          = 5
+	maxButtonsPerRow = 5
+	maxLabelLength   = 80
+)
+
+// interactionTTL is how long a button click can still be answered, Discord
+// invalidates interaction tokens after 15 minutes
+const interactionTTL = 15 * time.Minute
+
+// Options configure the Discord transport
+type Options struct {
+	Token string
+	// Register the slash commands in this server only, where they show up at
+	// once; global commands take up to an hour (optional)
+	GuildID string
+	Proxy   *url.URL // optional
+}
+
+// Transport implements the bot's Transport over a Discord bot session
+type Transport struct {
+	session *discordgo.Session
+	guildID string
+	updates *platform.Queue
+	client  *http.Client
+
+	mu           sync.Mutex
+	channels     map[int64]string                  // Chat ID -> channel ID
+	interactions map[string]*discordgo.Interaction // Callback ID -> click waiting for an answer
+}
+
+// New logs in to the Discord gateway
+func New(opts Options) (*Transport, error) {
+	session, err := discordgo.New("Bot " + opts.Token)
+	if err != nil {
+		return nil, err
+	}
+	// Message content is a privileged intent, enabled in the developer portal
+	session.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessages |
+		discordgo.IntentsDirectMessages | discordgo.IntentMessageContent
+	client := &http.Client{Timeout: 30 * time.Second}
+	if opts.Proxy != nil {
+		client.Transport = &http.Transport{Proxy: http.ProxyURL(opts.Proxy)}
+		session.Dialer = &websocket.Dialer{Proxy: http.ProxyURL(opts.Proxy), HandshakeTimeout: 45 * time.Second}
+	}
+	session.Client = client
+
+	t := &Transport{
+		session:      session,
+		guildID:      opts.GuildID,
+		updates:      platform.NewQueue(),
+		client:       client,
+		channels:     make(map[int64]string),
+		interactions: make(map[string]*discordgo.Interaction),
+	}
+	session.AddHandler(t.onMessage)
+	session.AddHandler(t.onInteraction)
+	if err := session.Open(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Discord: %w", err)
+	}
+	return t, nil
+}
+
+// UserName is the bot's Discord user name
+func (t *Transport) UserName() string {
+	return t.session.State.User.Username
+}
+
+// MessageLimit is Discord's message length limit, less room for the
+// escapes and longer bold markers content adds
+func (t *Transport) MessageLimit() int {
+	return 1800
+}
+
+// GetUpdates returns the messages and clicks received since the last call
+func (t *Transport) GetUpdates(config tgbotapi.UpdateConfig) ([]tgbotapi.Update, error) {
+	return t.updates.Get(config)
+}
+
+// onMessage turns a message into a Telegram message update
+func (t *Transport) onMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.Bot {
+		return
+	}
+	userID, err := snowflake(m.Author.ID)
+	if err != nil {
+		return
+	}
+	chatID := t.remember(m.GuildID, m.ChannelID, userID)
+
+	msg := platform.TextMessage(chatID, userID, m.Author.Username, strings.TrimSpace(m.Content))
+	msg.MessageID, _ = strconv.Atoi(m.ID)
+	if len(m.Attachments) > 0 {
+		att := m.Attachments[0]
+		msg.Document = &tgbotapi.Document{FileID: att.URL, FileName: att.Filename, FileSize: att.Size}
+	}
+	t.updates.Push(tgbotapi.Update{Message: msg})
+}
+
+// onInteraction turns a slash command into a Telegram command message and a
+// button click into a callback query. Discord wants an answer within three
+// seconds, so both are acknowledged at once.
+func (t *Transport) onInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := i.User
+	if i.Member != nil {
+		user = i.Member.User
+	}
+	if user == nil {
+		return
+	}
+	userID, err := snowflake(user.ID)
+	if err != nil {
+		return
+	}
+	chatID := t.remember(i.GuildID, i.ChannelID, userID)
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		data := i.ApplicationCommandData()
+		text := "/" + data.Name
+		for _, opt := range data.Options {
+			if opt.Type == discordgo.ApplicationCommandOptionString {
+				text += " " + opt.StringValue()
+			}
+		}
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Content: "`" + text + "`"},
+		})
+		if err != nil {
+			logger.Warnf("Failed to acknowledge %s: %v", text, err)
+		}
+		t.updates.Push(tgbotapi.Update{Message: platform.TextMessage(chatID, userID, user.Username, text)})
+
+	case discordgo.InteractionMessageComponent:
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredMessageUpdate,
+		})
+		if err != nil {
+			logger.Warnf("Failed to acknowledge button click: %v", err)
+		}
+		t.mu.Lock()
+		t.interactions[i.ID] = i.Interaction
+		t.mu.Unlock()
+		time.AfterFunc(interactionTTL, func() {
+			t.mu.Lock()
+			delete(t.interactions, i.ID)
+			t.mu.Unlock()
+		})
+
+		msg := &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}}
+		if i.Message != nil {
+			msg.MessageID, _ = strconv.Atoi(i.Message.ID)
+			msg.Text = i.Message.Content
+		}
+		t.updates.Push(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      i.ID,
+			From:    &tgbotapi.User{ID: userID, UserName: user.Username, FirstName: user.Username},
+			Message: msg,
+			Data:    i.MessageComponentData().CustomID,
+		}})
+	}
+}
+
+// remember records the channel of a chat and returns the chat ID: the user's
+// for a direct message, the channel's in a server
+func (t *Transport) remember(guildID, channelID string, userID int64) int64 {
+	chatID := userID
+	if guildID != "" {
+		chatID, _ = snowflake(channelID)
+	}
+	t.mu.Lock()
+	t.channels[chatID] = channelID
+	t.mu.Unlock()
+	return chatID
+}
+
+// channel returns the channel to send to chatID into, opening a direct
+// message channel when chatID is a user the bot hasn't heard from yet, such
+// as the admin receiving an alert after a restart
+func (t *Transport) channel(chatID int64) (string, error) {
+	t.mu.Lock()
+	channelID, ok := t.channels[chatID]
+	t.mu.Unlock()
+	if ok {
+		return channelID, nil
+	}
+
+	id := strconv.FormatInt(chatID, 10)
+	if ch, err := t.session.Channel(id); err == nil {
+		channelID = ch.ID
+	} else {
+		dm, err := t.session.UserChannelCreate(id)
+		if err != nil {
+			return "", platform.BadRequest(fmt.Errorf("unknown chat %d: %w", chatID, err))
+		}
+		channelID = dm.ID
+	}
+	t.mu.Lock()
+	t.channels[chatID] = channelID
+	t.mu.Unlock()
+	return channelID, nil
+}
+
+// Send sends a message, a file or the command list
+func (t *Transport) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		return t.sendMessage(c.ChatID, content(c.Text, c.ParseMode), platform.Keyboard(c.ReplyMarkup), nil)
+	case tgbotapi.DocumentConfig:
+		return t.sendFile(c.BaseFile, c.Caption, c.ParseMode)
+	case tgbotapi.PhotoConfig:
+		return t.sendFile(c.BaseFile, c.Caption, c.ParseMode)
+	case tgbotapi.SetMyCommandsConfig:
+		return tgbotapi.Message{}, t.setCommands(c.Commands)
+	}
+	_, err := t.Request(c)
+	return tgbotapi.Message{}, err
+}
+
+// Request makes the calls that don't send a message: edits, deletions,
+// answers to button clicks and member lookups
+func (t *Transport) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	switch c := c.(type) {
+	case tgbotapi.EditMessageTextConfig:
+		// As on Telegram, an edit without a keyboard removes it
+		text := content(c.Text, c.ParseMode)
+		return t.edit(c.BaseEdit, &text)
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return t.edit(c.BaseEdit, nil)
+	case tgbotapi.DeleteMessageConfig:
+		channelID, err := t.channel(c.ChatID)
+		if err == nil {
+			err = t.session.ChannelMessageDelete(channelID, strconv.Itoa(c.MessageID))
+		}
+		return ok(nil, convertError(err))
+	case tgbotapi.CallbackConfig:
+		return ok(nil, t.answerClick(c))
+	case tgbotapi.GetChatMemberConfig:
+		return t.chatMember(c.ChatID, c.UserID)
+	case tgbotapi.SetMyCommandsConfig:
+		return ok(nil, t.setCommands(c.Commands))
+	case tgbotapi.DeleteWebhookConfig:
+		return ok(nil, nil)
+	case tgbotapi.MessageConfig, tgbotapi.DocumentConfig, tgbotapi.PhotoConfig:
+		msg, err := t.Send(c)
+		return ok(msg, err)
+	}
+	return nil, platform.BadRequest(fmt.Errorf("%T is not supported on Discord", c))
+}
+
+// MakeRequest answers getMe while the gateway is connected. Raw sendMessage
+// calls are only made for Telegram forum topics.
+func (t *Transport) MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error) {
+	if endpoint != "getMe" {
+		return nil, platform.BadRequest(fmt.Errorf("%s is not supported on Discord", endpoint))
+	}
+	if !t.session.DataReady {
+		return nil, errors.New("not connected to the Discord gateway")
+	}
+	self := t.session.State.User
+	id, _ := snowflake(self.ID)
+	return ok(tgbotapi.User{ID: id, IsBot: true, UserName: self.Username, FirstName: self.Username}, nil)
+}
+
+// DownloadFile fetches an attachment sent to the bot, the file ID being its URL
+func (t *Transport) DownloadFile(ctx context.Context, fileID string, limit int) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileID, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", res.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, fmt.Errorf("file too large")
+	}
+	return data, nil
+}
+
+// sendMessage sends text with the keyboard as buttons. Buttons beyond what one
+// message holds go into follow-up messages.
+func (t *Transport) sendMessage(chatID int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup, files []*discordgo.File) (tgbotapi.Message, error) {
+	channelID, err := t.channel(chatID)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	pages := components(keyboard)
+	send := &discordgo.MessageSend{Content: text, Files: files}
+	if len(pages) > 0 {
+		send.Components = pages[0]
+	}
+	sent, err := t.session.ChannelMessageSendComplex(channelID, send)
+	if err != nil {
+		return tgbotapi.Message{}, convertError(err)
+	}
+	for _, page := range pages[min(1, len(pages)):] {
+		if _, err := t.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Components: page}); err != nil {
+			logger.Warnf("Failed to send more buttons: %v", err)
+		}
+	}
+	return message(sent, chatID), nil
+}
+
+// sendFile uploads a document or photo with its caption
+func (t *Transport) sendFile(base tgbotapi.BaseFile, caption, parseMode string) (tgbotapi.Message, error) {
+	name, reader, err := base.File.UploadData()
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	files := []*discordgo.File{{Name: name, Reader: reader}}
+	return t.sendMessage(base.ChatID, content(caption, parseMode), platform.Keyboard(base.ReplyMarkup), files)
+}
+
+// edit changes a message's text, when text isn't nil, and its buttons
+func (t *Transport) edit(base tgbotapi.BaseEdit, text *string) (*tgbotapi.APIResponse, error) {
+	channelID, err := t.channel(base.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	edit := discordgo.NewMessageEdit(channelID, strconv.Itoa(base.MessageID))
+	edit.Content = text
+	pages := components(base.ReplyMarkup)
+	rows := []discordgo.MessageComponent{}
+	if len(pages) > 0 {
+		rows = pages[0]
+	}
+	if len(pages) > 1 {
+		logger.Warnf("Keyboard too large for one Discord message, dropping %d rows of buttons", (len(pages)-1)*maxRows)
+	}
+	edit.Components = &rows
+	sent, err := t.session.ChannelMessageEditComplex(edit)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return ok(message(sent, base.ChatID), nil)
+}
+
+// answerClick shows the answer to a button click to the user who clicked
+func (t *Transport) answerClick(c tgbotapi.CallbackConfig) error {
+	t.mu.Lock()
+	i := t.interactions[c.CallbackQueryID]
+	delete(t.interactions, c.CallbackQueryID)
+	t.mu.Unlock()
+	if i == nil || c.Text == "" {
+		return nil
+	}
+	_, err := t.session.FollowupMessageCreate(i, false, &discordgo.WebhookParams{
+		Content: c.Text,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+	return convertError(err)
+}
+
+// chatMember reports a user with Administrator or Manage Server permission
+// in the channel as a Telegram administrator
+func (t *Transport) chatMember(chatID, userID int64) (*tgbotapi.APIResponse, error) {
+	channelID, err := t.channel(chatID)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := t.session.UserChannelPermissions(strconv.FormatInt(userID, 10), channelID)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	status := "member"
+	if perms&(discordgo.PermissionAdministrator|discordgo.PermissionManageGuild) != 0 {
+		status = "administrator"
+	}
+	return ok(tgbotapi.ChatMember{User: &tgbotapi.User{ID: userID}, Status: status}, nil)
+}
+
+// setCommands registers the bot's commands as slash commands taking their
+// arguments as one optional text option
+func (t *Transport) setCommands(commands []tgbotapi.BotCommand) error {
+	slash := make([]*discordgo.ApplicationCommand, 0, len(commands))
+	for _, c := range commands {
+		slash = append(slash, &discordgo.ApplicationCommand{
+			Name:        c.Command,
+			Description: truncate(c.Description, 100),
+			Options: []*discordgo.ApplicationCommandOption{{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "args",
+				Description: "参数",
+			}},
+		})
+	}
+	_, err := t.session.ApplicationCommandBulkOverwrite(t.session.State.User.ID, t.guildID, slash)
+	return convertError(err)
+}
+
+// components lays a keyboard out as Discord action rows, packing short rows
+// together, and splits it into pages of what one message can hold
+func components(keyboard *tgbotapi.InlineKeyboardMarkup) [][]discordgo.MessageComponent {
+	if keyboard == nil {
+		return nil
+	}
+	var rows []discordgo.ActionsRow
+	for _, row := range keyboard.InlineKeyboard {
+		for len(row) > 0 {
+			n := min(len(row), maxButtonsPerRow)
+			last := len(rows) - 1
+			if last >= 0 && len(rows[last].Components)+n <= maxButtonsPerRow {
+				rows[last].Components = append(rows[last].Components, buttons(row[:n])...)
+			} else {
+				rows = append(rows, discordgo.ActionsRow{Components: buttons(row[:n])})
+			}
+			row = row[n:]
+		}
+	}
+
+	var pages [][]discordgo.MessageComponent
+	for i, row := range rows {
+		if i%maxRows == 0 {
+			pages = append(pages, nil)
+		}
+		pages[len(pages)-1] = append(pages[len(pages)-1], row)
+	}
+	return pages
+}
+
+// buttons converts Telegram buttons: callback data becomes the custom ID
+func buttons(row []tgbotapi.InlineKeyboardButton) []discordgo.MessageComponent {
+	result := make([]discordgo.MessageComponent, 0, len(row))
+	for _, b := range row {
+		button := discordgo.Button{Label: truncate(b.Text, maxLabelLength), Style: discordgo.SecondaryButton}
+		switch {
+		case b.URL != nil:
+			button.Style, button.URL = discordgo.LinkButton, *b.URL
+		case b.CallbackData != nil:
+			button.CustomID = *b.CallbackData
+		default:
+			continue
+		}
+		result = append(result, button)
+	}
+	return result
+}
+
+// content renders a message text in Discord's Markdown, escaping plain text
+// so Discord doesn't format it
+func content(text, parseMode string) string {
+	if parseMode != tgbotapi.ModeMarkdown {
+		return escapeMarkdown(text)
+	}
+	var sb strings.Builder
+	for _, seg := range platform.ParseMarkdown(text) {
+		switch seg.Kind {
+		case platform.Text:
+			sb.WriteString(escapeMarkdown(seg.Text))
+		case platform.Bold:
+			sb.WriteString("**" + escapeMarkdown(seg.Text) + "**")
+		case platform.Italic:
+			sb.WriteString("_" + escapeMarkdown(seg.Text) + "_")
+		case platform.Code:
+			sb.WriteString("`" + seg.Text + "`")
+		case platform.Pre:
+			sb.WriteString("```\n" + seg.Text + "```")
+		case platform.Link:
+			sb.WriteString("[" + escapeMarkdown(seg.Text) + "](" + seg.URL + ")")
+		}
+	}
+	return sb.String()
+}
+
+// markdownEscaper escapes what Discord would format in plain text. Underscores
+// are left alone: they are common in names and only italicize whole words.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "`", "\\`", "~", `\~`, "|", `\|`, "[", `\[`)
+
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// message converts a sent Discord message for the bot
+func message(m *discordgo.Message, chatID int64) tgbotapi.Message {
+	id, _ := strconv.Atoi(m.ID)
+	return tgbotapi.Message{MessageID: id, Chat: &tgbotapi.Chat{ID: chatID}, Text: m.Content}
+}
+
+// ok wraps a result as a successful API response, or returns err
+func ok(result interface{}, err error) (*tgbotapi.APIResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return &tgbotapi.APIResponse{Ok: true, Result: raw}, nil
+}
+
+// convertError turns Discord's refusal of a request (HTTP 400, 403 or 404)
+// into a bad request, which the bot doesn't retry
+func convertError(err error) error {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil {
+		switch restErr.Response.StatusCode {
+		case http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound:
+			return platform.BadRequest(err)
+		}
+	}
+	return err
+}
+
+// snowflake parses a Discord ID
+func snowflake(id string) (int64, error) {
+	return strconv.ParseInt(id, 10, 64)
+}
+
+// truncate cuts s to at most n characters
+func truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"oci-bot/oci"
+)
+
+const bytesPerGB = 1 << 30
+
+// instanceEgress is the outbound transfer of one instance this month
+type instanceEgress struct {
+	Name  string
+	Bytes float64
+}
+
+// monthStart returns the first instant of the current month (UTC, matching OCI billing)
+func monthStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// monthlyEgress returns month-to-date outbound transfer per instance, largest first, and the total
+func monthlyEgress(ctx context.Context, client oci.Service) ([]instanceEgress, float64, error) {
+	instances, err := client.ListInstances(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start := monthStart(time.Now())
+	var usage []instanceEgress
+	total := 0.0
+	for _, inst := range instances {
+		if inst.State == "TERMINATED" {
+			continue
+		}
+		sent, err := client.OutboundBytes(ctx, inst.ID, start)
+		if err != nil {
+			return nil, 0, err
+		}
+		usage = append(usage, instanceEgress{Name: inst.DisplayName, Bytes: sent})
+		total += sent
+	}
+
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Bytes > usage[j].Bytes })
+	return usage, total, nil
+}
+
+// runEgressMonitor periodically warns when an account's outbound transfer
+// crosses one of the configured percentages of the free allowance
+func (b *Bot) runEgressMonitor(ctx context.Context) {
+	interval := time.Duration(b.cfg.EgressCheckHours) * time.Hour
+	logger.Infof("Egress monitor started (every %s, limit %d GB)", interval, b.cfg.EgressLimitGB)
+
+	alerted := make(map[string]int) // "account|month" -> highest percent already reported
+
+	b.checkEgress(ctx, alerted)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.checkEgress(ctx, alerted)
+		}
+	}
+}
+
+func (b *Bot) checkEgress(ctx context.Context, alerted map[string]int) {
+	month := monthStart(time.Now()).Format("2006-01")
+	limit := float64(b.cfg.EgressLimitGB) * bytesPerGB
+
+	for _, client := range b.sortedClients() {
+		name := client.AccountName()
+
+		checkCtx, cancel := context.WithTimeout(ctx, batchTimeout)
+		usage, total, err := monthlyEgress(checkCtx, client)
+		cancel()
+		if err != nil {
+			logger.Warnf("[%s] Egress check failed: %v", name, err)
+			continue
+		}
+
+		percent := total / limit * 100
+		key := name + "|" + month
+		crossed := 0
+		for _, p := range b.cfg.EgressAlertPercents {
+			if percent >= float64(p) {
+				crossed = p
+			}
+		}
+		if crossed == 0 || crossed <= alerted[key] {
+			continue
+		}
+		alerted[key] = crossed
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("📤 *[%s] 本月出站流量已达 %.1f%%*\n\n", name, percent))
+		sb.WriteString(fmt.Sprintf("已用 %s / 免费 %d GB\n\n", formatBytes(total), b.cfg.EgressLimitGB))
+		for i, u := range usage {
+			if i >= 5 {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("• %s: %s\n", escapeMarkdown(u.Name), formatBytes(u.Bytes)))
+		}
+		b.alertMarkdown(sb.String())
+	}
+}
@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"errors"
+	"net/url"
+
+	"oci-bot/oci"
+)
+
+// errorKindText names the OCI error categories in messages
+var errorKindText = map[oci.ErrorKind]string{
+	oci.ErrorQuota:     "配额已满",
+	oci.ErrorCapacity:  "容量不足",
+	oci.ErrorAuth:      "认证失败，请检查 API 密钥",
+	oci.ErrorThrottled: "请求过于频繁，已被限流",
+	oci.ErrorConflict:  "资源状态冲突，请稍后重试",
+	oci.ErrorNotFound:  "资源不存在或无权限",
+	oci.ErrorTimeout:   "请求超时",
+}
+
+// describeError turns an error into text for a message: the category of an
+// OCI error followed by the service's message, without the SDK's request
+// details. Other errors are shown as they are.
+func describeError(err error) string {
+	message := oci.ErrorMessage(err)
+	if text, ok := errorKindText[oci.Classify(err)]; ok {
+		return text + ": " + message
+	}
+	return message
+}
+
+// errorText is describeError as a failure reply
+func errorText(err error) string {
+	return "❌ " + describeError(err)
+}
+
+// withoutURL strips the request URL from an HTTP client error. Bot API URLs
+// hold the bot token, which must not reach logs or replies.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
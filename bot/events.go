@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"plugin"
+	"sync"
+	"time"
+
+	"oci-bot/bot/events"
+)
+
+// Event bus. Tasks publish events; control_socket clients and plugins
+// subscribe. Nobody is waited for: a subscriber that falls eventBuffer events
+// behind misses the following ones.
+const (
+	eventBuffer   = 64
+	pluginTimeout = time.Minute // Per HandleEvent call
+)
+
+// eventHub passes events on to their subscribers
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan events.Event]string // Subscriber -> name for logs
+}
+
+// subscribe returns a channel receiving every event from now on, and the
+// function ending the subscription
+func (h *eventHub) subscribe(name string) (<-chan events.Event, func()) {
+	ch := make(chan events.Event, eventBuffer)
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = make(map[chan events.Event]string)
+	}
+	h.subs[ch] = name
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// publish stamps the event and hands it to every subscriber
+func (b *Bot) publish(e events.Event) {
+	e.Time = time.Now()
+	logger.Debugf("Event %s [%s]", e.Type, e.Account)
+
+	b.events.mu.Lock()
+	defer b.events.mu.Unlock()
+	for ch, name := range b.events.subs {
+		select {
+		case ch <- e:
+		default:
+			logger.Warnf("Event subscriber %s is behind, dropping %s", name, e.Type)
+		}
+	}
+}
+
+// loadPlugin opens a Go plugin and creates its events.Plugin
+func loadPlugin(path string) (events.Plugin, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup(events.NewPluginSymbol)
+	if err != nil {
+		return nil, err
+	}
+	newPlugin, ok := sym.(func() (events.Plugin, error))
+	if !ok {
+		return nil, fmt.Errorf("%s is %T, want func() (events.Plugin, error)", events.NewPluginSymbol, sym)
+	}
+	return newPlugin()
+}
+
+// startPlugins loads the configured plugins and feeds them events until ctx
+// is done
+func (b *Bot) startPlugins(ctx context.Context) error {
+	for _, path := range b.cfg.Plugins {
+		p, err := loadPlugin(path)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", path, err)
+		}
+		logger.Infof("Loaded plugin %s from %s", p.Name(), path)
+
+		ch, unsubscribe := b.events.subscribe("plugin " + p.Name())
+		go func() {
+			<-ctx.Done()
+			unsubscribe()
+		}()
+		go b.supervise(ctx, "plugin "+p.Name(), func(ctx context.Context) { b.runPlugin(ctx, p, ch) })
+	}
+	return nil
+}
+
+// runPlugin hands events to p one at a time
+func (b *Bot) runPlugin(ctx context.Context, p events.Plugin, ch <-chan events.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-ch:
+			callCtx, cancel := context.WithTimeout(ctx, pluginTimeout)
+			err := p.HandleEvent(callCtx, e)
+			cancel()
+			if err != nil {
+				logger.Warnf("Plugin %s failed on %s: %v", p.Name(), e.Type, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Package events defines what the bot reports about its auto-apply and
+// auto-VPS tasks, and the interface of Go plugins that act on it.
+//
+// A plugin is built with `go build -buildmode=plugin` against the same
+// version of this module and exports
+//
+//	func NewPlugin() (events.Plugin, error)
+package events
+
+import (
+	"context"
+	"time"
+)
+
+// Event types
+const (
+	AutoIPStarted    = "autoip.started"
+	IPCreated        = "autoip.created" // A candidate IP got its address; also IPs made with /newip
+	CheckFailed      = "autoip.check_failed"
+	IPMatched        = "autoip.matched"
+	AutoIPStopped    = "autoip.stopped"
+	AutoVPSStarted   = "autovps.started"
+	InstanceLaunched = "autovps.launched"
+	AutoVPSFailed    = "autovps.failed"
+	AutoVPSStopped   = "autovps.stopped"
+)
+
+// Event is something that happened to a task. Fields that don't apply to the
+// type are empty.
+type Event struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Account    string    `json:"account,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	Purity     string    `json:"purity,omitempty"`
+	IPType     string    `json:"ip_type,omitempty"`
+	Native     string    `json:"native,omitempty"`
+	InstanceID string    `json:"instance_id,omitempty"`
+	Attempts   int       `json:"attempts,omitempty"`
+	Reason     string    `json:"reason,omitempty"` // Why a task stopped
+	Error      string    `json:"error,omitempty"`
+}
+
+// Plugin receives every event, one at a time and in order. ctx is cancelled
+// when the bot stops or the call takes too long.
+type Plugin interface {
+	Name() string
+	HandleEvent(ctx context.Context, e Event) error
+}
+
+// NewPluginSymbol is the name of the constructor a plugin exports
+const NewPluginSymbol = "NewPlugin"
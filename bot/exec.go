@@ -0,0 +1,217 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"oci-bot/oci"
+	"oci-bot/sshexec"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	sshHostKeysKey  = "ssh_host_keys" // State section holding the host key of each instance /exec logged in to
+	maxExecReplyLen = 3500            // Longer output is sent as a file
+)
+
+var errHostKeyChanged = errors.New("host key changed")
+
+// execAction is a canned /exec command offered as a button
+type execAction struct {
+	Key     string
+	Label   string
+	Command string
+}
+
+var execActions = []execAction{
+	{"disk", "💽 磁盘占用", "df -h -x tmpfs -x devtmpfs"},
+	{"mem", "🧠 内存", "free -h"},
+	{"load", "📊 负载", "uptime"},
+	{"xray", "🔁 重启 xray", "sudo systemctl restart xray && systemctl is-active xray"},
+	{"singbox", "🔁 重启 sing-box", "sudo systemctl restart sing-box && systemctl is-active sing-box"},
+	{"svc", "🩺 代理服务状态", "systemctl --no-pager --lines=5 status xray sing-box"},
+}
+
+// showExec handles /exec [instance [command]]: without a command it offers
+// the canned actions, without an instance the instance list
+func (b *Bot) showExec(chatID int64, args string) {
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	instances, err := client.ListInstances(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	name, command, _ := strings.Cut(strings.TrimSpace(args), " ")
+	if name != "" {
+		for _, inst := range instances {
+			if inst.ID == name || inst.DisplayName == name {
+				if command = strings.TrimSpace(command); command != "" {
+					b.runExec(chatID, client, inst.ID, command)
+				} else {
+					b.showExecActions(chatID, client, inst)
+				}
+				return
+			}
+		}
+		b.reply(chatID, "❌ 未找到实例: "+name)
+		return
+	}
+
+	if len(instances) == 0 {
+		b.reply(chatID, fmt.Sprintf("📋 [%s] 暂无实例", client.AccountName()))
+		return
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, inst := range instances {
+		label := fmt.Sprintf("%s (%s)", inst.DisplayName, inst.State)
+		btn := tgbotapi.NewInlineKeyboardButtonData(label, "exec:"+b.callbackRef(inst.ID))
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("💻 *[%s] 选择实例*\n\n也可直接使用 /exec <实例> <命令>", client.AccountName()))
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
+
+// showExecActions offers the canned actions for an instance
+func (b *Bot) showExecActions(chatID int64, client oci.Service, inst oci.InstanceInfo) {
+	ref := b.callbackRef(inst.ID)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := 0; i < len(execActions); i += 2 {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, action := range execActions[i:min(i+2, len(execActions))] {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(action.Label, "exec:"+ref+":"+action.Key))
+		}
+		rows = append(rows, row)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("💻 [%s] %s\n\n选择操作，或使用 /exec %s <命令>", client.AccountName(), inst.DisplayName, inst.DisplayName))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.send(msg)
+}
+
+// handleExecCallback handles exec:<instance ref>[:<action>]
+func (b *Bot) handleExecCallback(chatID int64, ref string, parts []string) {
+	instanceID, ok := b.resolveRef(ref)
+	if !ok {
+		b.reply(chatID, "⚠️ 按钮已过期，请重新使用 /exec")
+		return
+	}
+	if len(parts) < 3 {
+		b.showExec(chatID, instanceID)
+		return
+	}
+	for _, action := range execActions {
+		if action.Key == parts[2] {
+			b.mu.Lock()
+			client := b.currentClient
+			b.mu.Unlock()
+			b.runExec(chatID, client, instanceID, action.Command)
+			return
+		}
+	}
+}
+
+// runExec runs command on the instance over SSH with the account's
+// vps_ssh_private_key and replies with its output
+func (b *Bot) runExec(chatID int64, client oci.Service, instanceID, command string) {
+	account := b.accountConfig(client.AccountName())
+	if account == nil || account.VPSSSHPrivateKey == "" {
+		b.reply(chatID, fmt.Sprintf("❌ [%s] 未配置 vps_ssh_private_key，无法执行远程命令", client.AccountName()))
+		return
+	}
+	signer, err := sshexec.LoadSigner(account.VPSSSHPrivateKey, account.VPSSSHKeyPassphrase)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	conn, err := b.instanceConnection(client, instanceID)
+	switch {
+	case errors.Is(err, errInstanceGone):
+		b.reply(chatID, "❌ 实例不存在或已终止")
+		return
+	case errors.Is(err, errNoPublicIP):
+		b.reply(chatID, "❌ 实例没有公网IP")
+		return
+	case err != nil:
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	b.status(chatID, topicNone, fmt.Sprintf("⏳ %s 执行中: %s", conn.Name, command))
+	logger.Infof("Exec on %s (%s) as %s: %s", conn.Name, conn.IP, conn.User, command)
+	ctx, cancel := b.withTimeout(execTimeout)
+	defer cancel()
+	result, err := sshexec.Run(ctx, conn.IP, conn.User, signer, b.hostKeyCallback(instanceID), command)
+	switch {
+	case errors.Is(err, errHostKeyChanged):
+		b.reply(chatID, fmt.Sprintf("⛔ %s (%s) 的 SSH 主机密钥与上次登录时不同，已拒绝连接\n\n如果实例重装过系统，请删除状态文件中 %s 下该实例的记录", conn.Name, conn.IP, sshHostKeysKey))
+		return
+	case err != nil:
+		logger.Warnf("Exec on %s failed: %v", conn.Name, err)
+		b.reply(chatID, "❌ 执行失败: "+err.Error())
+		return
+	}
+
+	header := fmt.Sprintf("💻 %s $ %s\n退出码: %d", conn.Name, command, result.ExitCode)
+	if result.Truncated {
+		header += fmt.Sprintf("\n⚠️ 输出超过 %d KB，已截断", sshexec.MaxOutput>>10)
+	}
+	output := strings.TrimRight(result.Output, "\n")
+	switch {
+	case output == "":
+		b.reply(chatID, header+"\n\n(无输出)")
+	case utf16Len(output) <= maxExecReplyLen:
+		b.reply(chatID, header+"\n\n"+output)
+	default:
+		doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: conn.Name + "-output.txt", Bytes: []byte(result.Output)})
+		doc.Caption = header
+		if _, err := b.sendFile(chatID, doc); err != nil {
+			logger.Errorf("Failed to send exec output: %v", err)
+			b.reply(chatID, header+"\n\n"+output[:utf16Prefix(output, maxExecReplyLen)]+"\n…")
+		}
+	}
+}
+
+// hostKeyCallback trusts the host key an instance presents on the first
+// login and refuses a different one later. Keys are kept per instance rather
+// than per IP, as reserved IPs move between instances.
+func (b *Bot) hostKeyCallback(instanceID string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		known := make(map[string]string)
+		if err := b.store.Get(sshHostKeysKey, &known); err != nil {
+			return err
+		}
+		line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+		if trusted, ok := known[instanceID]; ok {
+			if trusted != line {
+				logger.Warnf("Host key of instance %s at %s changed to %s", instanceID, remote, ssh.FingerprintSHA256(key))
+				return errHostKeyChanged
+			}
+			return nil
+		}
+		known[instanceID] = line
+		if err := b.store.Set(sshHostKeysKey, known); err != nil {
+			logger.Errorf("Failed to save SSH host key: %v", err)
+		}
+		logger.Infof("Trusting host key %s of instance %s", ssh.FingerprintSHA256(key), instanceID)
+		return nil
+	}
+}
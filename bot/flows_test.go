@@ -0,0 +1,207 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"oci-bot/bot/tgfake"
+	"oci-bot/oci"
+	"oci-bot/oci/ocifake"
+)
+
+// runTestBot is newTestBot with the update loop running, so flows are driven
+// by updates injected into the fake transport
+func runTestBot(t *testing.T, accounts ...*ocifake.Client) (*Bot, *tgfake.Transport) {
+	t.Helper()
+	b, api := newTestBot(t, accounts...)
+	// An agent list skips looking for Chrome; the flows never check an IP
+	b.cfg.CheckerAgents = []string{"http://127.0.0.1:1"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+	return b, api
+}
+
+// waitFor waits until cond holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitText waits for a message containing s, sent after the first n messages
+func waitText(t *testing.T, api *tgfake.Transport, n int, s string) string {
+	t.Helper()
+	var found string
+	waitFor(t, "a message with "+s, func() bool {
+		texts := api.Texts()
+		if len(texts) <= n {
+			return false
+		}
+		i := slices.IndexFunc(texts[n:], func(text string) bool { return strings.Contains(text, s) })
+		if i < 0 {
+			return false
+		}
+		found = texts[n+i]
+		return true
+	})
+	return found
+}
+
+// lastKeyboard returns the callback data of the buttons of the last message
+// sent with an inline keyboard
+func lastKeyboard(api *tgfake.Transport) []string {
+	sent := api.Sent()
+	for i := len(sent) - 1; i >= 0; i-- {
+		msg, ok := sent[i].(tgbotapi.MessageConfig)
+		if !ok {
+			continue
+		}
+		markup, ok := msg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+		if !ok {
+			continue
+		}
+		var data []string
+		for _, row := range markup.InlineKeyboard {
+			for _, button := range row {
+				if button.CallbackData != nil {
+					data = append(data, *button.CallbackData)
+				}
+			}
+		}
+		return data
+	}
+	return nil
+}
+
+func TestAutoIPWizardFlow(t *testing.T) {
+	_, api := runTestBot(t, ocifake.New("main", "ap-tokyo-1"))
+
+	api.SendText(testAdmin, "/autoip")
+	waitText(t, api, 0, "(1/6)")
+	if buttons := lastKeyboard(api); !slices.Contains(buttons, "autoip:account:main") {
+		t.Fatalf("account step offers %v", buttons)
+	}
+
+	steps := []struct {
+		click  string
+		want   string
+		offers string // A button of the next step
+	}{
+		{"autoip:account:main", "(2/6)", "autoip:purity:30"},
+		{"autoip:purity:30", "(3/6)", "autoip:native:any"},
+		{"autoip:native:any", "(4/6)", "autoip:type:住宅IP"},
+		{"autoip:type:住宅IP", "(5/6)", "autoip:mode:any"},
+		{"autoip:mode:any", "(6/6)", ""},
+	}
+	for _, step := range steps {
+		n := len(api.Texts())
+		api.Click(testAdmin, step.click)
+		waitText(t, api, n, step.want)
+		if step.offers != "" && !slices.Contains(lastKeyboard(api), step.offers) {
+			t.Fatalf("after %s: buttons %v lack %s", step.click, lastKeyboard(api), step.offers)
+		}
+	}
+
+	n := len(api.Texts())
+	api.SendText(testAdmin, "120")
+	summary := waitText(t, api, n, "确认自动刷IP配置")
+	for _, want := range []string{"main", "<= 30%", "住宅IP", "满足任一条件", "120秒"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("confirmation %q lacks %q", summary, want)
+		}
+	}
+
+	n = len(api.Texts())
+	api.Click(testAdmin, "autoip:cancel:")
+	waitText(t, api, n, "已取消自动刷IP配置")
+
+	// Every button press is answered, clearing its loading state
+	answered := 0
+	for _, c := range api.Requests() {
+		if _, ok := c.(tgbotapi.CallbackConfig); ok {
+			answered++
+		}
+	}
+	if answered != len(steps)+1 {
+		t.Errorf("answered %d callbacks, want %d", answered, len(steps)+1)
+	}
+
+	// With the wizard gone, plain text is no longer taken as the interval
+	n = len(api.Texts())
+	api.SendText(testAdmin, "120")
+	waitText(t, api, n, "Use /help")
+}
+
+func TestBulkDeleteFlow(t *testing.T) {
+	client := ocifake.New("main", "ap-tokyo-1")
+	client.AddReservedIP(oci.PublicIPInfo{ID: "ocid1.publicip.a", IPAddress: "192.0.2.1"})
+	client.AddReservedIP(oci.PublicIPInfo{ID: "ocid1.publicip.b", IPAddress: "192.0.2.2"})
+	_, api := runTestBot(t, client)
+
+	api.Click(testAdmin, "sel:start")
+	waitText(t, api, 0, "选择要删除的IP")
+	if got, want := lastKeyboard(api), []string{"sel:toggle:0", "sel:toggle:1", "sel:all", "sel:none", "sel:do", "sel:cancel"}; !slices.Equal(got, want) {
+		t.Fatalf("buttons %v, want %v", got, want)
+	}
+
+	// Toggling edits the keyboard in place
+	api.Click(testAdmin, "sel:toggle:1")
+	waitFor(t, "the keyboard edit", func() bool {
+		for _, c := range api.Requests() {
+			if edit, ok := c.(tgbotapi.EditMessageReplyMarkupConfig); ok {
+				return *edit.ReplyMarkup.InlineKeyboard[1][0].CallbackData == "sel:toggle:1" &&
+					strings.HasPrefix(edit.ReplyMarkup.InlineKeyboard[1][0].Text, "☑️")
+			}
+		}
+		return false
+	})
+
+	api.Click(testAdmin, "sel:do")
+	waitFor(t, "the delete summary", func() bool {
+		return strings.HasPrefix(lastEdit(api), "✅ [main] 已删除 1/1 个IP")
+	})
+	if got := reservedIPs(t, client); !slices.Equal(got, []string{"192.0.2.1"}) {
+		t.Errorf("left %v, want 192.0.2.1", got)
+	}
+}
+
+func TestUnauthorizedUser(t *testing.T) {
+	client := ocifake.New("main", "ap-tokyo-1")
+	client.AddReservedIP(oci.PublicIPInfo{ID: "ocid1.publicip.a", IPAddress: "192.0.2.1"})
+	_, api := runTestBot(t, client)
+
+	const stranger = 7
+	api.SendText(stranger, "/delip 192.0.2.1")
+	waitText(t, api, 0, "⛔ Unauthorized\nYour ID: 7")
+
+	// Button presses of strangers are dropped without an answer
+	api.Click(stranger, "sel:start")
+	api.SendText(testAdmin, "/help")
+	waitText(t, api, 1, "OCI IP Bot")
+	for _, text := range api.Texts() {
+		if strings.Contains(text, "选择要删除的IP") {
+			t.Errorf("stranger started a bulk delete")
+		}
+	}
+	if got := reservedIPs(t, client); len(got) != 1 {
+		t.Errorf("left %v, want 192.0.2.1", got)
+	}
+}
@@ -0,0 +1,210 @@
+package bot
+
+import (
+	"html"
+	"strings"
+
+	"oci-bot/bot/platform"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Messages are written in Telegram's legacy Markdown (*bold*, _italic_,
+// `code`, ```pre```, [text](url)). Dynamic values that may contain markup
+// characters go through escapeMarkdown. Before sending, the text is converted
+// to the parse mode chosen with parse_mode, and if Telegram still rejects the
+// formatting the message is resent as plain text.
+
+// escapeMarkdown escapes markup characters in a value placed outside code
+// spans of a legacy Markdown message, e.g. display names and error strings.
+func escapeMarkdown(s string) string {
+	if !strings.ContainsAny(s, platform.MarkdownSpecial) {
+		return s
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(platform.MarkdownSpecial, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// codeSpan formats a value as inline code. Backticks can't be escaped inside
+// legacy Markdown code, so they are replaced.
+func codeSpan(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "'") + "`"
+}
+
+// escapeMarkdownV2 escapes every character MarkdownV2 reserves in plain text
+func escapeMarkdownV2(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("_*[]()~`>#+-=|{}.!\\", r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// escapeMarkdownV2Code escapes the characters MarkdownV2 reserves in code
+func escapeMarkdownV2Code(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(s)
+}
+
+// renderMarkdown converts legacy Markdown to the given parse mode; an empty
+// mode yields plain text.
+func renderMarkdown(text, mode string) string {
+	var sb strings.Builder
+	for _, seg := range platform.ParseMarkdown(text) {
+		switch mode {
+		case tgbotapi.ModeMarkdownV2:
+			switch seg.Kind {
+			case platform.Text:
+				sb.WriteString(escapeMarkdownV2(seg.Text))
+			case platform.Bold:
+				sb.WriteString("*" + escapeMarkdownV2(seg.Text) + "*")
+			case platform.Italic:
+				sb.WriteString("_" + escapeMarkdownV2(seg.Text) + "_")
+			case platform.Code:
+				sb.WriteString("`" + escapeMarkdownV2Code(seg.Text) + "`")
+			case platform.Pre:
+				sb.WriteString("```\n" + escapeMarkdownV2Code(seg.Text) + "```")
+			case platform.Link:
+				url := strings.NewReplacer("\\", "\\\\", ")", "\\)").Replace(seg.URL)
+				sb.WriteString("[" + escapeMarkdownV2(seg.Text) + "](" + url + ")")
+			}
+		case tgbotapi.ModeHTML:
+			escaped := html.EscapeString(seg.Text)
+			switch seg.Kind {
+			case platform.Text:
+				sb.WriteString(escaped)
+			case platform.Bold:
+				sb.WriteString("<b>" + escaped + "</b>")
+			case platform.Italic:
+				sb.WriteString("<i>" + escaped + "</i>")
+			case platform.Code:
+				sb.WriteString("<code>" + escaped + "</code>")
+			case platform.Pre:
+				sb.WriteString("<pre>" + escaped + "</pre>")
+			case platform.Link:
+				sb.WriteString(`<a href="` + html.EscapeString(seg.URL) + `">` + escaped + "</a>")
+			}
+		default:
+			sb.WriteString(seg.Text)
+			if seg.Kind == platform.Link {
+				sb.WriteString(" (" + seg.URL + ")")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// formatMessage converts a legacy Markdown message to the configured parse mode
+func (b *Bot) formatMessage(msg tgbotapi.MessageConfig) tgbotapi.MessageConfig {
+	if msg.ParseMode != tgbotapi.ModeMarkdown || b.cfg.ParseMode == tgbotapi.ModeMarkdown {
+		return msg
+	}
+	msg.Text = renderMarkdown(msg.Text, b.cfg.ParseMode)
+	msg.ParseMode = b.cfg.ParseMode
+	return msg
+}
+
+// plainMessage is the fallback for a message Telegram refused to parse:
+// markup removed and no parse mode.
+func plainMessage(original tgbotapi.MessageConfig) tgbotapi.MessageConfig {
+	msg := original
+	if original.ParseMode == tgbotapi.ModeMarkdown {
+		msg.Text = renderMarkdown(original.Text, "")
+	}
+	msg.ParseMode = ""
+	return msg
+}
+
+// maxMessageLength is Telegram's message limit in UTF-16 code units, with room
+// for the code fences splitMessage may add
+const maxMessageLength = 4096 - 8
+
+// splitMessage splits legacy Markdown text into chunks of at most limit UTF-16
+// code units, at line breaks where possible. A ``` block cut in two is closed
+// at the end of one chunk and reopened in the next.
+func splitMessage(text string, limit int) []string {
+	if utf16Len(text) <= limit {
+		return []string{text}
+	}
+
+	var chunks []string
+	var chunk strings.Builder
+	chunkLen := 0
+	inPre := false
+	flush := func() {
+		if chunk.Len() == 0 || (inPre && chunk.String() == "```\n") {
+			return
+		}
+		text := strings.TrimSuffix(chunk.String(), "\n")
+		if inPre {
+			text += "\n```"
+		}
+		chunks = append(chunks, text)
+		chunk.Reset()
+		chunkLen = 0
+		if inPre {
+			chunk.WriteString("```\n")
+			chunkLen = 4
+		}
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		// Hard-split a single line that is longer than a whole message
+		for utf16Len(line) > limit-8 {
+			flush()
+			cut := utf16Prefix(line, limit-8-chunkLen)
+			chunk.WriteString(line[:cut])
+			chunkLen += utf16Len(line[:cut])
+			line = line[cut:]
+		}
+		if chunkLen+utf16Len(line) > limit {
+			flush()
+		}
+		chunk.WriteString(line)
+		chunkLen += utf16Len(line)
+		if strings.Count(line, "```")%2 == 1 {
+			inPre = !inPre
+		}
+	}
+	inPre = false
+	flush()
+	return chunks
+}
+
+// utf16Len returns the length of s in UTF-16 code units, as Telegram counts
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// utf16Prefix returns the byte length of the longest prefix of s that fits in
+// n UTF-16 code units
+func utf16Prefix(s string, n int) int {
+	units := 0
+	for i, r := range s {
+		size := 1
+		if r >= 0x10000 {
+			size = 2
+		}
+		if units+size > n {
+			return i
+		}
+		units += size
+	}
+	return len(s)
+}
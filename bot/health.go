@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"oci-bot/config"
+	"oci-bot/oci"
+)
+
+// showHealth checks the credentials of every configured account: the key
+// file's permissions, format and fingerprint, then a test call for the loaded
+// accounts, so a broken key shows up with a fix instead of as auth errors later
+func (b *Bot) showHealth(chatID int64) {
+	b.mu.Lock()
+	accounts := make([]config.OCIAccount, len(b.cfg.Accounts))
+	copy(accounts, b.cfg.Accounts)
+	b.mu.Unlock()
+
+	b.reply(chatID, "🩺 检查账号凭据...")
+
+	var sb strings.Builder
+	sb.WriteString("🩺 *账号凭据检查*\n")
+	healthy := 0
+	for i := range accounts {
+		if b.writeAccountHealth(&sb, &accounts[i]) {
+			healthy++
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\n%d/%d 个账号正常", healthy, len(accounts)))
+	b.replyMarkdown(chatID, sb.String())
+}
+
+// writeAccountHealth adds the checks of one account to sb and reports whether
+// they all passed
+func (b *Bot) writeAccountHealth(sb *strings.Builder, acc *config.OCIAccount) bool {
+	client, loaded := b.client(acc.Name)
+	status := "✅ 已加载"
+	switch {
+	case acc.Disabled:
+		status = "⏸ 已停用"
+	case !loaded:
+		status = "❌ 未加载"
+	}
+	sb.WriteString(fmt.Sprintf("\n*[%s]* %s\n", escapeMarkdown(acc.Name), status))
+
+	ok := acc.Disabled || loaded
+	warnings, err := oci.CheckKeyFile(acc)
+	for _, warning := range warnings {
+		sb.WriteString("⚠️ " + escapeMarkdown(warning) + "\n")
+	}
+	if err != nil {
+		sb.WriteString("❌ 私钥: " + escapeMarkdown(err.Error()) + "\n")
+		return false
+	}
+	if len(warnings) == 0 {
+		sb.WriteString("🔑 私钥: 正常\n")
+	}
+	if !loaded {
+		return ok
+	}
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+	user, err := client.GetUserInfo(ctx)
+	if err != nil {
+		sb.WriteString("❌ API 调用失败: " + escapeMarkdown(describeError(err)) + "\n")
+		return false
+	}
+	if !user.CanUseAPIKeys {
+		sb.WriteString("❌ 该用户不允许使用 API 密钥\n")
+		return false
+	}
+	for _, key := range user.APIKeys {
+		if strings.EqualFold(key.Fingerprint, user.KeyInUse) {
+			if key.State != "" && key.State != "ACTIVE" {
+				sb.WriteString(fmt.Sprintf("⚠️ 当前密钥状态: %s\n", key.State))
+				return false
+			}
+			sb.WriteString("🌐 API 调用: 正常\n")
+			return true
+		}
+	}
+	// The call was signed with this key, so it was deleted in the meantime
+	sb.WriteString("⚠️ 当前密钥不在该用户的 API 密钥中\n")
+	return false
+}
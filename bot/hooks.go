@@ -0,0 +1,170 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"oci-bot/bot/events"
+)
+
+// Exec hooks: on_<event> commands run through sh with the event as OCI_BOT_*
+// environment variables and as JSON on stdin. Each run is appended to
+// hook_log as a JSON line.
+const maxHookOutput = 16 * 1024 // Output kept in hook_log per run
+
+// hookNames maps event types to their on_<event> names in the config
+var hookNames = map[string]string{
+	events.AutoIPStarted:    "autoip_started",
+	events.IPCreated:        "ip_created",
+	events.CheckFailed:      "check_failed",
+	events.IPMatched:        "ip_matched",
+	events.AutoIPStopped:    "autoip_stopped",
+	events.AutoVPSStarted:   "autovps_started",
+	events.InstanceLaunched: "instance_launched",
+	events.AutoVPSFailed:    "autovps_failed",
+	events.AutoVPSStopped:   "autovps_stopped",
+}
+
+// hookRun is a hook_log line
+type hookRun struct {
+	Time     time.Time    `json:"time"`
+	Event    events.Event `json:"event"`
+	Command  string       `json:"command"`
+	Duration float64      `json:"duration_seconds"`
+	ExitCode int          `json:"exit_code"`
+	Error    string       `json:"error,omitempty"` // Didn't start, timed out or was killed
+	Output   string       `json:"output,omitempty"`
+}
+
+// hookLogMu serializes appends to hook_log
+var hookLogMu sync.Mutex
+
+// startHooks runs the on_<event> commands for events until ctx is done, one
+// at a time in event order
+func (b *Bot) startHooks(ctx context.Context) {
+	if len(b.cfg.Hooks) == 0 {
+		return
+	}
+	logger.Infof("Exec hooks set for %d events, logging runs to %s", len(b.cfg.Hooks), b.cfg.HookLog)
+
+	ch, unsubscribe := b.events.subscribe("exec hooks")
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+	}()
+	go b.supervise(ctx, "exec hooks", func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-ch:
+				if command := b.cfg.Hooks[hookNames[e.Type]]; command != "" {
+					b.runHook(ctx, command, e)
+				}
+			}
+		}
+	})
+}
+
+// runHook runs command for e and records the result
+func (b *Bot) runHook(ctx context.Context, command string, e events.Event) {
+	input, err := json.Marshal(e)
+	if err != nil {
+		logger.Errorf("Hook on_%s: %v", hookNames[e.Type], err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(b.cfg.HookTimeoutSeconds)*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), hookEnv(e)...)
+	cmd.Stdin = bytes.NewReader(input)
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	killProcessGroup(cmd)
+	// Background children holding the output open don't keep the run going
+	cmd.WaitDelay = 5 * time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	out := output.Bytes()
+	if len(out) > maxHookOutput {
+		out = out[:maxHookOutput]
+	}
+	run := hookRun{
+		Time:     start,
+		Event:    e,
+		Command:  command,
+		Duration: time.Since(start).Seconds(),
+		ExitCode: cmd.ProcessState.ExitCode(),
+		Output:   string(out),
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		run.Error = "timed out"
+	case err != nil && !errors.As(err, &exitErr):
+		run.Error = err.Error()
+	}
+
+	if err != nil {
+		logger.Warnf("Hook on_%s failed (exit %d): %v", hookNames[e.Type], run.ExitCode, err)
+	} else {
+		logger.Infof("Hook on_%s ran in %.1fs", hookNames[e.Type], run.Duration)
+	}
+	if err := b.logHookRun(run); err != nil {
+		logger.Errorf("Failed to write hook_log: %v", err)
+	}
+}
+
+// hookEnv returns the event as environment variables
+func hookEnv(e events.Event) []string {
+	env := []string{
+		"OCI_BOT_EVENT=" + e.Type,
+		"OCI_BOT_TIME=" + e.Time.Format(time.RFC3339),
+	}
+	for name, value := range map[string]string{
+		"ACCOUNT":     e.Account,
+		"IP":          e.IP,
+		"PURITY":      e.Purity,
+		"IP_TYPE":     e.IPType,
+		"NATIVE":      e.Native,
+		"INSTANCE_ID": e.InstanceID,
+		"REASON":      e.Reason,
+		"ERROR":       e.Error,
+	} {
+		if value != "" {
+			env = append(env, "OCI_BOT_"+name+"="+value)
+		}
+	}
+	if e.Attempts > 0 {
+		env = append(env, "OCI_BOT_ATTEMPTS="+strconv.Itoa(e.Attempts))
+	}
+	return env
+}
+
+// logHookRun appends run to hook_log
+func (b *Bot) logHookRun(run hookRun) error {
+	line, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	hookLogMu.Lock()
+	defer hookLogMu.Unlock()
+	f, err := os.OpenFile(b.cfg.HookLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package bot
+
+import "os/exec"
+
+// killProcessGroup is a no-op where process groups aren't available; only
+// the hook's shell is killed on timeout
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package bot
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup makes cmd run in its own process group and kills the whole
+// group when cancelled, so a timed-out hook doesn't leave children running
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
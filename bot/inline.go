@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	maxInlineResults = 20       // Telegram shows at most 50, a short list is easier to pick from
+	startCheckPrefix = "check_" // /start parameter asking for a purity check
+)
+
+// startParam encodes an IP for a /start deep link, which only allows
+// letters, digits, _ and -
+var startParam = strings.NewReplacer(".", "-", ":", "_")
+
+// startParamIP decodes startParam
+var startParamIP = strings.NewReplacer("-", ".", "_", ":")
+
+// inlineIP is a cached purity result offered in inline mode
+type inlineIP struct {
+	IP          string
+	PurityScore string
+	PurityLevel string
+	IPType      string
+	IsNative    string
+	Note        string
+}
+
+// cachedPurity returns the cached purity results of IPs starting with prefix:
+// the IPs checked since the bot started and the /watch list
+func (b *Bot) cachedPurity(prefix string) []inlineIP {
+	found := make(map[string]inlineIP)
+	b.mu.Lock()
+	for ip, w := range b.watchlist {
+		if strings.HasPrefix(ip, prefix) && w.PurityScore != "" {
+			found[ip] = inlineIP{IP: ip, PurityScore: w.PurityScore, PurityLevel: w.PurityLevel, IPType: w.IPType, IsNative: w.IsNative, Note: w.Note}
+		}
+	}
+	b.mu.Unlock()
+	for _, c := range b.checker.Matching(prefix) {
+		entry := found[c.IPAddress]
+		entry.IP, entry.PurityScore, entry.PurityLevel, entry.IPType, entry.IsNative = c.IPAddress, c.PurityScore, c.PurityLevel, c.IPType, c.IsNative
+		found[c.IPAddress] = entry
+	}
+
+	results := make([]inlineIP, 0, len(found))
+	for _, entry := range found {
+		results = append(results, entry)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].IP < results[j].IP })
+	return results
+}
+
+// handleInlineQuery answers "@bot <IP>" with the cached purity of matching
+// IPs, so results can be shared into any chat. Nothing is checked here:
+// Telegram expects an answer within seconds, so an IP without a cached result
+// links to the bot chat instead.
+func (b *Bot) handleInlineQuery(q *tgbotapi.InlineQuery) {
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: q.ID,
+		Results:       []interface{}{},
+		IsPersonal:    true,
+	}
+	if !b.accessFor(q.From.ID, q.From.ID, nil).allows("checkip") {
+		b.api.Request(answer)
+		return
+	}
+
+	query := strings.TrimSpace(q.Query)
+	logger.Debugf("Inline query from %d: %s", q.From.ID, query)
+	cached := b.cachedPurity(query)
+	if len(cached) > maxInlineResults {
+		cached = cached[:maxInlineResults]
+	}
+	for _, entry := range cached {
+		title := fmt.Sprintf("%s  纯净度 %s", entry.IP, entry.PurityScore)
+		article := tgbotapi.NewInlineQueryResultArticleMarkdown(entry.IP, title, inlinePurityText(entry))
+		var details []string
+		for _, detail := range []string{entry.IPType, entry.IsNative, entry.Note} {
+			if detail != "" {
+				details = append(details, detail)
+			}
+		}
+		article.Description = strings.Join(details, " · ")
+		answer.Results = append(answer.Results, article)
+	}
+	if len(cached) == 0 && net.ParseIP(query) != nil {
+		answer.SwitchPMText = "暂无缓存结果，在 bot 中检测 " + query
+		answer.SwitchPMParameter = startCheckPrefix + startParam.Replace(query)
+	}
+
+	if _, err := b.api.Request(answer); err != nil {
+		logger.Warnf("Failed to answer inline query: %v", err)
+	}
+}
+
+// inlinePurityText is the message an inline result sends
+func inlinePurityText(entry inlineIP) string {
+	score := entry.PurityScore
+	if entry.PurityLevel != "" {
+		score += " (" + entry.PurityLevel + ")"
+	}
+	text := fmt.Sprintf("🔍 *IP 纯净度*\n\nIP: %s\n\n📊 *纯净度:* %s\n🏢 *类型:* %s\n🌐 *来源:* %s",
+		codeSpan(entry.IP), escapeMarkdown(score), escapeMarkdown(entry.IPType), escapeMarkdown(entry.IsNative))
+	if entry.Note != "" {
+		text += "\n📝 " + escapeMarkdown(entry.Note)
+	}
+	return text
+}
+
+// startCheckIP returns the IP of a "/start check_<IP>" deep link from an
+// inline query, "" for other /start parameters
+func startCheckIP(args string) string {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(args), startCheckPrefix)
+	if !ok {
+		return ""
+	}
+	return startParamIP.Replace(encoded)
+}
@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// runUnattachedIPMonitor periodically warns about reserved IPs that have been
+// unattached for longer than the configured age, since they may incur charges.
+func (b *Bot) runUnattachedIPMonitor(ctx context.Context) {
+	interval := time.Duration(b.cfg.UnattachedIPCheckHours) * time.Hour
+	logger.Infof("Unattached IP monitor started (every %s)", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.checkUnattachedIPs(ctx)
+		}
+	}
+}
+
+// checkUnattachedIPs sends one warning per account that has stale unattached IPs
+func (b *Bot) checkUnattachedIPs(ctx context.Context) {
+	maxAge := time.Duration(b.cfg.UnattachedIPAgeHours) * time.Hour
+
+	for _, client := range b.sortedClients() {
+		name := client.AccountName()
+
+		listCtx, cancel := context.WithTimeout(ctx, callTimeout)
+		ips, err := client.ListReservedIPs(listCtx)
+		cancel()
+		if err != nil {
+			logger.Warnf("[%s] Unattached IP check failed: %v", name, err)
+			continue
+		}
+
+		stale := staleUnattachedIPs(ips, maxAge)
+		if len(stale) == 0 {
+			continue
+		}
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("⚠️ *[%s] 有 %d 个未绑定的预留IP*\n\n", name, len(stale)))
+		for _, ip := range stale {
+			age := time.Since(ip.TimeCreated).Round(time.Hour)
+			sb.WriteString(fmt.Sprintf("• `%s` (%s)\n", ip.IPAddress, age))
+		}
+		sb.WriteString(fmt.Sprintf("\n账号共 %d 个预留IP，免费额度 %d 个，超出部分可能产生费用", len(ips), b.cfg.FreeReservedIPs))
+
+		releaseBtn := tgbotapi.NewInlineKeyboardButtonData("🗑 释放全部未绑定IP", "releaseip:"+name)
+		msg := tgbotapi.NewMessage(b.alertChatID(), sb.String())
+		msg.ParseMode = tgbotapi.ModeMarkdown
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup([]tgbotapi.InlineKeyboardButton{releaseBtn})
+		b.sendIn(topicAlerts, msg)
+	}
+}
+
+// releaseUnattachedIPs deletes every unattached reserved IP of the account
+// that is older than maxAge
+func (b *Bot) releaseUnattachedIPs(chatID int64, accountName string, maxAge time.Duration) {
+	client, ok := b.client(accountName)
+	if !ok {
+		b.reply(chatID, "❌ 账号不存在: "+accountName)
+		return
+	}
+
+	release, err := b.acquireAccount(b.runCtx, chatID, accountName)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	ctx, cancel := b.withTimeout(batchTimeout)
+	defer cancel()
+
+	ips, err := client.ListReservedIPs(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	stale := b.withoutPinned(staleUnattachedIPs(ips, maxAge))
+	if len(stale) == 0 {
+		b.reply(chatID, "✅ 没有需要释放的IP")
+		return
+	}
+
+	released := 0
+	for _, ip := range stale {
+		if err := client.DeleteReservedIP(ctx, ip.ID); err != nil {
+			b.status(chatID, topicNone, deleteErrorText(ip.IPAddress, err))
+			continue
+		}
+		released++
+	}
+
+	b.reply(chatID, fmt.Sprintf("✅ [%s] 已释放 %d 个未绑定IP", accountName, released))
+}
+
+// staleUnattachedIPs returns unattached IPs created more than maxAge ago
+func staleUnattachedIPs(ips []oci.PublicIPInfo, maxAge time.Duration) []oci.PublicIPInfo {
+	var stale []oci.PublicIPInfo
+	for _, ip := range ips {
+		if !isUnattached(ip) {
+			continue
+		}
+		if !ip.TimeCreated.IsZero() && time.Since(ip.TimeCreated) < maxAge {
+			continue
+		}
+		stale = append(stale, ip)
+	}
+	return stale
+}
+
+// isUnattached reports whether a reserved IP is settled and not mapped to any private IP
+func isUnattached(ip oci.PublicIPInfo) bool {
+	return ip.AssignedEntityID == "" && ip.State == "AVAILABLE"
+}
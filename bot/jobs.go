@@ -0,0 +1,158 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"oci-bot/bot/workflow"
+)
+
+const jobsKey = "jobs" // State section holding the checkpoints of unfinished workflow jobs
+
+// Workflows, each running one job at a time under its own name as job ID
+const (
+	jobAutoIP  = "autoip"  // /autoip task
+	jobAutoVPS = "autovps" // /autovps task and what follows the launch
+	jobDeploy  = "deploy"  // /deploy chain
+)
+
+// registerWorkflows declares the workflows of the bot's multi-step tasks
+func (b *Bot) registerWorkflows() {
+	b.jobs.Register(b.autoIPWorkflow())
+	b.jobs.Register(b.autoVPSWorkflow())
+	b.jobs.Register(b.deployWorkflow())
+}
+
+// reportJob is the report of every workflow: failures and pauses are sent to
+// the job's chat, retries are logged
+func (b *Bot) reportJob(job workflow.Job, e workflow.Event) {
+	switch e.Type {
+	case workflow.StepRetrying:
+		logger.Warnf("[%s] %s failed at step %s (try %d), retrying in %s: %v",
+			job.Label, job.Kind, e.Step.Name, e.Attempt, e.Step.RetryDelay, e.Err)
+	case workflow.StepFailed:
+		logger.Errorf("[%s] %s failed at step %s: %v", job.Label, job.Kind, e.Step.Name, e.Err)
+		b.notify(job.ChatID, topicAuto, fmt.Sprintf("❌ [%s] %s在「%s」中断: %s\n使用 /jobs 重试这一步或放弃",
+			job.Label, job.Title(), e.Step.Title, describeError(e.Err)))
+	case workflow.Paused:
+		b.replyIn(job.ChatID, topicAuto, fmt.Sprintf("⏸ [%s] %s暂停在「%s」\n使用 /jobs 继续或放弃",
+			job.Label, job.Title(), e.Step.Title))
+	}
+}
+
+// jobAccount returns the account a job works on
+func jobAccount(job workflow.Job) string {
+	switch data := job.Data.(type) {
+	case *autoIPJob:
+		return data.Account
+	case *autoVPSJob:
+		return data.Account
+	case *deployment:
+		return data.Account
+	}
+	return ""
+}
+
+// resumeJobsOnStart continues the jobs the bot was running when it stopped
+func (b *Bot) resumeJobsOnStart(ctx context.Context) {
+	for _, job := range b.jobs.ResumeAll(ctx) {
+		step := job.Steps()[job.StepIndex()]
+		logger.Infof("[%s] Resuming %s at step %s", job.Label, job.Kind, step.Name)
+		b.replyIn(job.ChatID, topicAuto, fmt.Sprintf("🔁 [%s] bot 已重启，从「%s」继续%s", job.Label, step.Title, job.Title()))
+	}
+}
+
+// showJobs lists the unfinished jobs with their steps, offering to resume or
+// drop the stopped ones
+func (b *Bot) showJobs(chatID int64) {
+	jobs := b.jobs.Jobs()
+	if len(jobs) == 0 {
+		b.reply(chatID, "💤 没有未完成的任务")
+		return
+	}
+
+	var sb strings.Builder
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, job := range jobs {
+		sb.WriteString(fmt.Sprintf("🧩 [%s] %s，已运行 %s\n", job.Label, job.Title(), time.Since(job.Started).Round(time.Second)))
+		sb.WriteString(jobProgress(job))
+		if job.Err != "" {
+			sb.WriteString("错误: " + job.Err + "\n")
+		}
+		sb.WriteString("\n")
+		if job.Running {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⏹ 停止 %s", job.Title()), "job:stop:"+job.ID)))
+			continue
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("▶️ 继续 %s", job.Title()), "job:resume:"+job.ID),
+			tgbotapi.NewInlineKeyboardButtonData("🗑 放弃", "job:stop:"+job.ID),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, strings.TrimSpace(sb.String()))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.sendIn(topicAuto, msg)
+}
+
+// jobProgress marks each step of a job done, running, stopped or to do
+func jobProgress(job workflow.Job) string {
+	var sb strings.Builder
+	current := job.StepIndex()
+	for i, step := range job.Steps() {
+		mark := "⬜"
+		switch {
+		case i < current:
+			mark = "✅"
+		case i == current && job.Running:
+			mark = "⏳"
+		case i == current:
+			mark = "⏸"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s\n", mark, step.Title))
+	}
+	return sb.String()
+}
+
+// handleJobCallback resumes or drops a job from /jobs
+func (b *Bot) handleJobCallback(chatID int64, action string, parts []string) {
+	if len(parts) < 3 {
+		return
+	}
+	switch action {
+	case "resume":
+		b.resumeJob(chatID, parts[2])
+	case "stop":
+		b.stopJob(chatID, parts[2])
+	}
+}
+
+// resumeJob runs a stopped job again from the step it stopped at
+func (b *Bot) resumeJob(chatID int64, id string) {
+	job, ok := b.jobs.Get(id)
+	if !ok {
+		b.reply(chatID, "⚠️ 任务已结束")
+		return
+	}
+	if err := b.jobs.Resume(b.runCtx, id, chatID); err != nil {
+		b.reply(chatID, "⚠️ 无法继续: "+err.Error())
+		return
+	}
+	b.replyIn(chatID, topicAuto, fmt.Sprintf("🔁 [%s] 从「%s」继续%s", job.Label, job.Steps()[job.StepIndex()].Title, job.Title()))
+}
+
+// stopJob cancels a job and drops its checkpoint. What it created so far
+// stays.
+func (b *Bot) stopJob(chatID int64, id string) {
+	job, ok := b.jobs.Stop(id)
+	if !ok {
+		b.reply(chatID, "⚠️ 任务已结束")
+		return
+	}
+	b.replyIn(chatID, topicAuto, fmt.Sprintf("⏹ [%s] 已停止%s", job.Label, job.Title()))
+}
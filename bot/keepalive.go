@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"oci-bot/config"
+	"oci-bot/oci"
+)
+
+// startKeepAlive starts a keep-alive task for every account that configures keepalive_hours
+func (b *Bot) startKeepAlive(ctx context.Context) {
+	for i := range b.cfg.Accounts {
+		account := &b.cfg.Accounts[i]
+		client, ok := b.client(account.Name)
+		if !ok || account.KeepAliveHours <= 0 {
+			continue
+		}
+		go b.supervise(ctx, "keep-alive ["+account.Name+"]", func(ctx context.Context) {
+			b.runKeepAlive(ctx, client, account)
+		})
+	}
+}
+
+// runKeepAlive performs light account activity on the account's schedule to
+// reduce the risk of idle-account reclamation
+func (b *Bot) runKeepAlive(ctx context.Context, client oci.Service, account *config.OCIAccount) {
+	interval := time.Duration(account.KeepAliveHours) * time.Hour
+	logger.Infof("[%s] Keep-alive started (every %s)", account.Name, interval)
+
+	// Spread accounts out so they don't all hit the API at the same moment
+	jitter := time.Duration(rand.Intn(600)) * time.Second
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(jitter):
+	}
+
+	for {
+		// Skipped while the account is disabled or removed
+		if _, ok := b.client(account.Name); ok {
+			b.keepAliveOnce(ctx, client, account)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// keepAliveOnce issues read-only API calls and optionally puts CPU load on an instance
+func (b *Bot) keepAliveOnce(ctx context.Context, client oci.Service, account *config.OCIAccount) {
+	callCtx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+
+	if _, err := client.ListReservedIPs(callCtx); err != nil {
+		logger.Warnf("[%s] Keep-alive list IPs failed: %v", account.Name, err)
+	}
+
+	instances, err := client.ListInstances(callCtx)
+	if err != nil {
+		logger.Warnf("[%s] Keep-alive list instances failed: %v", account.Name, err)
+		return
+	}
+
+	if account.KeepAliveCPUInstance == "" {
+		logger.Debugf("[%s] Keep-alive done", account.Name)
+		return
+	}
+
+	for _, inst := range instances {
+		if inst.ID != account.KeepAliveCPUInstance && inst.DisplayName != account.KeepAliveCPUInstance {
+			continue
+		}
+		if inst.State != "RUNNING" {
+			logger.Warnf("[%s] Keep-alive instance %s is %s", account.Name, inst.DisplayName, inst.State)
+			return
+		}
+
+		seconds := account.KeepAliveCPUMinutes * 60
+		// Keep one core busy; on a 4 OCPU A1 instance that is ~25% utilisation
+		script := fmt.Sprintf("timeout %d sh -c 'while :; do :; done' || true", seconds)
+		if _, err := client.RunInstanceCommand(callCtx, inst.ID, script, seconds+60); err != nil {
+			logger.Warnf("[%s] Keep-alive CPU command failed: %v", account.Name, err)
+			return
+		}
+		logger.Infof("[%s] Keep-alive CPU load started on %s for %d min", account.Name, inst.DisplayName, account.KeepAliveCPUMinutes)
+		return
+	}
+
+	logger.Warnf("[%s] Keep-alive instance not found: %s", account.Name, account.KeepAliveCPUInstance)
+}
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"oci-bot/config"
+	"oci-bot/latency"
+)
+
+// latencyOptions returns the configured vantage points
+func (b *Bot) latencyOptions() latency.Options {
+	return latency.Options{
+		Locations:       b.cfg.LatencyLocations,
+		GlobalpingToken: b.cfg.GlobalpingToken,
+		Probes:          b.cfg.LatencyProbes,
+	}
+}
+
+// measureLatency pings ip from every vantage point, nil when none is
+// configured
+func (b *Bot) measureLatency(ctx context.Context, ip string) []latency.Result {
+	if !b.cfg.LatencyVantages() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, latencyTimeout)
+	defer cancel()
+	results := latency.Measure(ctx, ip, b.latencyOptions())
+	for _, r := range results {
+		if r.Err != nil && !errors.Is(r.Err, latency.ErrUnreachable) {
+			logger.Warnf("Latency to %s from %s failed: %v", ip, r.Vantage, r.Err)
+		}
+	}
+	return results
+}
+
+// latencySummary formats results on one line, e.g. "Shanghai 48ms · Tokyo 超时"
+func latencySummary(results []latency.Result) string {
+	var parts []string
+	for _, r := range results {
+		switch {
+		case r.Err == nil:
+			parts = append(parts, fmt.Sprintf("%s %dms", r.Vantage, r.RTT.Milliseconds()))
+		case errors.Is(r.Err, latency.ErrUnreachable):
+			parts = append(parts, r.Vantage+" 超时")
+		default:
+			parts = append(parts, r.Vantage+" 失败")
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// latencyMarkdown formats results as a line for IP check results, "" for none
+func latencyMarkdown(results []latency.Result) string {
+	if len(results) == 0 {
+		return ""
+	}
+	return "📶 *延迟:* " + escapeMarkdown(latencySummary(results))
+}
+
+// latencyWanted applies the account's autoip_max_latency to results. Vantage
+// points that couldn't measure are ignored, as a failed lookup adds no
+// penalty; an unreachable IP is too slow.
+func latencyWanted(account *config.OCIAccount, results []latency.Result) (bool, string) {
+	limit := time.Duration(account.AutoIPMaxLatency) * time.Millisecond
+	for _, r := range results {
+		if len(account.AutoIPLatencyFrom) > 0 && !slices.Contains(account.AutoIPLatencyFrom, r.Vantage) {
+			continue
+		}
+		switch {
+		case errors.Is(r.Err, latency.ErrUnreachable):
+			return false, "unreachable from " + r.Vantage
+		case r.Err != nil:
+		case r.RTT > limit:
+			return false, fmt.Sprintf("%dms from %s", r.RTT.Milliseconds(), r.Vantage)
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"oci-bot/oci"
+	"oci-bot/state"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	autoIPPrefix = "auto-"         // Display name prefix of IPs created by auto-apply
+	keptAutoKey  = "auto_kept_ips" // State section holding auto-apply IPs kept on purpose
+)
+
+// loadKeptAutoIPs reads the auto-apply IPs that were kept on purpose
+func loadKeptAutoIPs(store *state.Store) (map[string]bool, error) {
+	var ips []string
+	if err := store.Get(keptAutoKey, &ips); err != nil {
+		return nil, err
+	}
+	kept := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		kept[ip] = true
+	}
+	return kept, nil
+}
+
+// markAutoIPsKept records auto-apply IPs that are kept on purpose (a match, an
+// unchecked IP or one the admin chose to keep), so they are not reported as
+// leaked.
+func (b *Bot) markAutoIPsKept(ips ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, ip := range ips {
+		b.keptAutoIPs[ip] = true
+	}
+	b.saveKeptAutoIPsLocked()
+}
+
+// saveKeptAutoIPsLocked persists the kept set. Caller must hold b.mu.
+func (b *Bot) saveKeptAutoIPsLocked() {
+	ips := make([]string, 0, len(b.keptAutoIPs))
+	for ip := range b.keptAutoIPs {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	if err := b.store.Set(keptAutoKey, ips); err != nil {
+		logger.Errorf("Failed to save kept auto-apply IPs: %v", err)
+	}
+}
+
+// leakedAutoIPs returns unattached auto-apply IPs that were never kept on
+// purpose: left behind when the bot stopped between creating and deleting one.
+func (b *Bot) leakedAutoIPs(ips []oci.PublicIPInfo) []oci.PublicIPInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var leaked []oci.PublicIPInfo
+	for _, ip := range ips {
+		if strings.HasPrefix(ip.DisplayName, autoIPPrefix) && isUnattached(ip) &&
+			!b.keptAutoIPs[ip.IPAddress] && !b.pinned[ip.IPAddress] {
+			leaked = append(leaked, ip)
+		}
+	}
+	return leaked
+}
+
+// autoApplyRunningOn reports whether auto-apply is running on the account, in
+// which case its current candidate would look leaked
+func (b *Bot) autoApplyRunningOn(accountName string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.autoApply != nil && b.autoApply.Active && b.autoApply.AccountName == accountName
+}
+
+// scanLeakedAutoIPs looks for leaked auto-apply IPs on every account at
+// startup and asks the admin what to do with them
+func (b *Bot) scanLeakedAutoIPs() {
+	var sb strings.Builder
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	existing := make(map[string]bool)
+	complete := true
+	sb.WriteString("🧹 *发现遗留的自动刷IP地址*\n\n以下IP由自动刷IP创建，但在检测或删除前 bot 已退出:\n")
+
+	for _, client := range b.sortedClients() {
+		name := client.AccountName()
+		ctx, cancel := b.withTimeout(callTimeout)
+		ips, err := client.ListReservedIPs(ctx)
+		cancel()
+		if err != nil {
+			logger.Warnf("[%s] Leaked IP scan failed: %v", name, err)
+			complete = false
+			continue
+		}
+		for _, ip := range ips {
+			existing[ip.IPAddress] = true
+		}
+
+		leaked := b.leakedAutoIPs(ips)
+		if len(leaked) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n📍 *[%s]*\n", escapeMarkdown(name)))
+		for _, ip := range leaked {
+			sb.WriteString(fmt.Sprintf("• `%s` %s\n", ip.IPAddress, escapeMarkdown(ip.DisplayName)))
+		}
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑 释放 [%s] 的 %d 个", name, len(leaked)), "leaked:del:"+name),
+			tgbotapi.NewInlineKeyboardButtonData("✅ 保留", "leaked:keep:"+name),
+		})
+	}
+
+	// Forget kept IPs that have since been released
+	if complete {
+		b.mu.Lock()
+		for ip := range b.keptAutoIPs {
+			if !existing[ip] {
+				delete(b.keptAutoIPs, ip)
+			}
+		}
+		b.saveKeptAutoIPsLocked()
+		b.mu.Unlock()
+	}
+
+	if len(buttons) == 0 {
+		logger.Debugf("No leaked auto-apply IPs")
+		return
+	}
+	logger.Warnf("Found leaked auto-apply IPs on %d account(s)", len(buttons))
+
+	msg := tgbotapi.NewMessage(b.alertChatID(), sb.String())
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.sendIn(topicAlerts, msg)
+}
+
+// handleLeakedCallback releases or keeps the leaked auto-apply IPs of an account
+func (b *Bot) handleLeakedCallback(chatID int64, action string, parts []string) {
+	if len(parts) < 3 {
+		return
+	}
+	accountName := parts[2]
+	client, ok := b.client(accountName)
+	if !ok {
+		b.reply(chatID, "❌ 账号不存在: "+accountName)
+		return
+	}
+	if b.autoApplyRunningOn(accountName) {
+		b.reply(chatID, "⚠️ 该账号正在自动刷IP，请停止后再处理")
+		return
+	}
+
+	release, err := b.acquireAccount(b.runCtx, chatID, accountName)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	ctx, cancel := b.withTimeout(batchTimeout)
+	defer cancel()
+
+	ips, err := client.ListReservedIPs(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+	leaked := b.leakedAutoIPs(ips)
+	if len(leaked) == 0 {
+		b.reply(chatID, "✅ 没有遗留的IP")
+		return
+	}
+
+	if action == "keep" {
+		addrs := make([]string, len(leaked))
+		for i, ip := range leaked {
+			addrs[i] = ip.IPAddress
+		}
+		b.markAutoIPsKept(addrs...)
+		b.reply(chatID, fmt.Sprintf("✅ [%s] 已保留 %d 个IP，不再提示", accountName, len(leaked)))
+		return
+	}
+
+	released := 0
+	for _, ip := range leaked {
+		if err := client.DeleteReservedIP(ctx, ip.ID); err != nil {
+			b.status(chatID, topicNone, deleteErrorText(ip.IPAddress, err))
+			continue
+		}
+		released++
+	}
+	b.reply(chatID, fmt.Sprintf("✅ [%s] 已释放 %d 个遗留IP", accountName, released))
+}
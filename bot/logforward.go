@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"oci-bot/logging"
+)
+
+const (
+	maxForwardEntries  = 20  // Entries per Telegram message
+	maxForwardEntryLen = 300 // Characters per entry
+)
+
+// logForwarder collects error-level log messages and mirrors them to the
+// admin chat in batches, at most once per interval
+type logForwarder struct {
+	mu      sync.Mutex
+	entries []string
+	dropped int
+}
+
+// startLogForwarding registers the error hook and flushes batches until ctx is done
+func (b *Bot) startLogForwarding(ctx context.Context) {
+	f := &logForwarder{}
+	logging.AddHook(slog.LevelError, func(_ slog.Level, module, msg string) {
+		f.add(fmt.Sprintf("[%s] %s %s", module, time.Now().Format("15:04:05"), msg))
+	})
+
+	interval := time.Duration(b.cfg.LogForwardInterval) * time.Second
+	logger.Infof("Forwarding error logs to Telegram every %s", interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if text := f.flush(); text != "" {
+					// Not kept in the outbox: logs aren't worth replaying
+					b.replyIn(b.alertChatID(), topicAlerts, text)
+				}
+			}
+		}
+	}()
+}
+
+func (f *logForwarder) add(entry string) {
+	if len(entry) > maxForwardEntryLen {
+		entry = entry[:maxForwardEntryLen] + "..."
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.entries) >= maxForwardEntries {
+		f.dropped++
+		return
+	}
+	f.entries = append(f.entries, entry)
+}
+
+// flush returns the pending batch as message text and resets the buffer
+func (f *logForwarder) flush() string {
+	f.mu.Lock()
+	entries, dropped := f.entries, f.dropped
+	f.entries, f.dropped = nil, 0
+	f.mu.Unlock()
+
+	if len(entries) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🚨 错误日志 (%d 条)\n\n", len(entries)+dropped))
+	for _, e := range entries {
+		sb.WriteString("• " + e + "\n")
+	}
+	if dropped > 0 {
+		sb.WriteString(fmt.Sprintf("\n...另有 %d 条未显示", dropped))
+	}
+	return sb.String()
+}
@@ -0,0 +1,828 @@
+// Package matrix runs the bot on Matrix through the client-server API. Its
+// Transport sends the Telegram Bot API calls the bot makes as room messages.
+// Matrix has no buttons, so a keyboard is listed under its message as
+// numbered choices, picked by sending !1, !2, ...
+//
+// Chat IDs are derived from Matrix IDs with ChatID: a direct chat, a room of
+// the bot and one user, has the user's, as Telegram private chats do, other
+// rooms their room ID's. The derivation is keyed with a secret kept in
+// Options.KeyFile, so nobody can pick a user or room ID that maps to the
+// admin's or the group's chat ID.
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"oci-bot/bot/platform"
+	"oci-bot/logging"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var logger = logging.New("matrix")
+
+const (
+	syncTimeout = 30 * time.Second // Long polling of /sync
+	syncRetry   = 5 * time.Second
+	// Requests hanging past this fail, a stuck /sync included
+	requestTimeout = syncTimeout + 30*time.Second
+)
+
+// syncFilter keeps /sync to room messages and membership changes
+const syncFilter = `{"room":{"timeline":{"types":["m.room.message","m.room.member"]}},"presence":{"types":[]},"account_data":{"types":[]}}`
+
+// choicePattern matches a message picking a keyboard choice
+var choicePattern = regexp.MustCompile(`^!(\d+)$`)
+
+// Options configure the Matrix transport
+type Options struct {
+	Homeserver  string // e.g. https://matrix.example.org
+	AccessToken string
+	// User the bot opens a direct chat with when it has something to send
+	// before they wrote to it, e.g. @me:example.org
+	Admin string
+	// File holding the key chat IDs are derived with, created the first time.
+	// Keep it: chat IDs in the config, like group_chat_id, depend on it.
+	KeyFile string
+	Proxy   *url.URL // optional
+}
+
+// Transport implements the bot's Transport over the Matrix client-server API
+type Transport struct {
+	homeserver string
+	token      string
+	key        []byte // Key of ChatID
+	self       string // The bot's user ID
+	since      string // Where Run picks up /sync
+	client     *http.Client
+	updates    *platform.Queue
+	txnPrefix  string
+
+	mu        sync.Mutex
+	txn       int
+	lastID    int
+	users     map[int64]string       // Chat ID -> user ID, for opening direct chats
+	rooms     map[int64]string       // Chat ID -> room ID
+	members   map[string]int         // Room ID -> joined members, cached
+	sent      map[int]*sentMessage   // Message ID -> event
+	keyboards map[string]*choiceList // Room ID -> latest message with choices
+	clicks    map[string]string      // Callback ID -> room ID
+}
+
+// sentMessage is a message the bot sent, kept for edits
+type sentMessage struct {
+	Room      string
+	Event     string
+	Text      string
+	ParseMode string
+}
+
+// choiceList are the numbered choices listed under a message
+type choiceList struct {
+	MessageID int
+	Data      []string // Callback data of choice n at n-1
+}
+
+// keySize is the length of the key of ChatID
+const keySize = 32
+
+// ChatID maps a Matrix user or room ID to a chat ID, a keyed hash of it
+func (t *Transport) ChatID(matrixID string) int64 {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(matrixID))
+	return int64(binary.BigEndian.Uint64(mac.Sum(nil)) >> 1)
+}
+
+// loadKey reads the key of ChatID from file, creating it with a random key
+// the first time
+func loadKey(file string) ([]byte, error) {
+	key, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		key = make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(key); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return key, f.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("%s holds %d bytes, want a %d byte key", file, len(key), keySize)
+	}
+	return key, nil
+}
+
+// New logs in with the access token. Rooms are followed once Run is called.
+func New(opts Options) (*Transport, error) {
+	key, err := loadKey(opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the Matrix ID key: %w", err)
+	}
+	client := &http.Client{Timeout: requestTimeout}
+	if opts.Proxy != nil {
+		client.Transport = &http.Transport{Proxy: http.ProxyURL(opts.Proxy)}
+	}
+	t := &Transport{
+		homeserver: strings.TrimRight(opts.Homeserver, "/"),
+		token:      opts.AccessToken,
+		key:        key,
+		client:     client,
+		updates:    platform.NewQueue(),
+		txnPrefix:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		users:      make(map[int64]string),
+		rooms:      make(map[int64]string),
+		members:    make(map[string]int),
+		sent:       make(map[int]*sentMessage),
+		keyboards:  make(map[string]*choiceList),
+		clicks:     make(map[string]string),
+	}
+	t.users[t.ChatID(opts.Admin)] = opts.Admin
+
+	var who struct {
+		UserID string `json:"user_id"`
+	}
+	if err := t.call(context.Background(), http.MethodGet, "/account/whoami", nil, &who); err != nil {
+		return nil, fmt.Errorf("failed to log in to Matrix: %w", err)
+	}
+	t.self = who.UserID
+
+	// Messages sent while the bot was offline are skipped, as their
+	// commands may be long outdated
+	if t.since, err = t.sync(context.Background(), "", 0); err != nil {
+		return nil, fmt.Errorf("failed to sync with Matrix: %w", err)
+	}
+	return t, nil
+}
+
+// UserName is the bot's Matrix user ID
+func (t *Transport) UserName() string {
+	return t.self
+}
+
+// GetUpdates returns the messages and choices received since the last call
+func (t *Transport) GetUpdates(config tgbotapi.UpdateConfig) ([]tgbotapi.Update, error) {
+	return t.updates.Get(config)
+}
+
+// Run follows /sync until ctx is done, queueing the messages of the rooms
+// the bot is in and joining those it is invited to
+func (t *Transport) Run(ctx context.Context) {
+	since := t.since
+	for ctx.Err() == nil {
+		next, err := t.sync(ctx, since, syncTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Warnf("Sync failed, retrying in %s: %v", syncRetry, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(syncRetry):
+			}
+			continue
+		}
+		since = next
+	}
+}
+
+type syncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []event `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+		Invite map[string]json.RawMessage `json:"invite"`
+	} `json:"rooms"`
+}
+
+type event struct {
+	Type    string          `json:"type"`
+	Sender  string          `json:"sender"`
+	EventID string          `json:"event_id"`
+	Content json.RawMessage `json:"content"`
+}
+
+// incoming is the content of a received room message
+type incoming struct {
+	MsgType string `json:"msgtype"`
+	Body    string `json:"body"`
+	URL     string `json:"url"`
+	Info    struct {
+		Size int `json:"size"`
+	} `json:"info"`
+	RelatesTo *struct {
+		RelType string `json:"rel_type"`
+	} `json:"m.relates_to"`
+}
+
+// sync makes one /sync call and queues the messages it returns. The first
+// call, without since, only finds out where to start.
+func (t *Transport) sync(ctx context.Context, since string, timeout time.Duration) (string, error) {
+	query := url.Values{"timeout": {strconv.FormatInt(timeout.Milliseconds(), 10)}, "filter": {syncFilter}}
+	if since != "" {
+		query.Set("since", since)
+	}
+	var resp syncResponse
+	if err := t.call(ctx, http.MethodGet, "/sync?"+query.Encode(), nil, &resp); err != nil {
+		return since, err
+	}
+
+	for roomID := range resp.Rooms.Invite {
+		if err := t.call(ctx, http.MethodPost, "/rooms/"+url.PathEscape(roomID)+"/join", struct{}{}, nil); err != nil {
+			logger.Warnf("Failed to join %s: %v", roomID, err)
+		} else {
+			logger.Infof("Joined %s", roomID)
+		}
+	}
+	if since == "" {
+		return resp.NextBatch, nil
+	}
+	for roomID, room := range resp.Rooms.Join {
+		for _, ev := range room.Timeline.Events {
+			t.handle(ctx, roomID, ev)
+		}
+	}
+	return resp.NextBatch, nil
+}
+
+// handle turns a room message into a Telegram update: a choice into a
+// callback query, anything else into a message
+func (t *Transport) handle(ctx context.Context, roomID string, ev event) {
+	if ev.Type == "m.room.member" {
+		t.mu.Lock()
+		delete(t.members, roomID)
+		t.mu.Unlock()
+		return
+	}
+	if ev.Type != "m.room.message" || ev.Sender == t.self {
+		return
+	}
+	var content incoming
+	if err := json.Unmarshal(ev.Content, &content); err != nil {
+		return
+	}
+	if content.RelatesTo != nil && content.RelatesTo.RelType == "m.replace" {
+		return // An edit
+	}
+
+	chatID := t.chatFor(ctx, roomID, ev.Sender)
+	userID := t.ChatID(ev.Sender)
+	t.mu.Lock()
+	t.users[userID] = ev.Sender
+	t.mu.Unlock()
+
+	switch content.MsgType {
+	case "m.text":
+		text := stripReplyFallback(content.Body)
+		if data, messageID, ok := t.choice(roomID, text); ok {
+			t.mu.Lock()
+			t.clicks[ev.EventID] = roomID
+			t.mu.Unlock()
+			t.updates.Push(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+				ID:      ev.EventID,
+				From:    &tgbotapi.User{ID: userID, UserName: ev.Sender, FirstName: ev.Sender},
+				Message: &tgbotapi.Message{MessageID: messageID, Chat: &tgbotapi.Chat{ID: chatID}},
+				Data:    data,
+			}})
+			return
+		}
+		t.updates.Push(tgbotapi.Update{Message: platform.TextMessage(chatID, userID, ev.Sender, text)})
+	case "m.file":
+		msg := platform.TextMessage(chatID, userID, ev.Sender, "")
+		msg.Document = &tgbotapi.Document{FileID: content.URL, FileName: content.Body, FileSize: content.Info.Size}
+		t.updates.Push(tgbotapi.Update{Message: msg})
+	}
+}
+
+// chatFor returns the chat ID of a message: the sender's in a direct chat,
+// the room's otherwise
+func (t *Transport) chatFor(ctx context.Context, roomID, sender string) int64 {
+	t.mu.Lock()
+	count, ok := t.members[roomID]
+	t.mu.Unlock()
+	if !ok {
+		var resp struct {
+			Joined map[string]json.RawMessage `json:"joined"`
+		}
+		if err := t.call(ctx, http.MethodGet, "/rooms/"+url.PathEscape(roomID)+"/joined_members", nil, &resp); err != nil {
+			logger.Warnf("Failed to list members of %s: %v", roomID, err)
+		}
+		count = len(resp.Joined)
+	}
+
+	chatID := t.ChatID(roomID)
+	if count == 2 {
+		chatID = t.ChatID(sender)
+	}
+	t.mu.Lock()
+	t.members[roomID] = count
+	t.rooms[chatID] = roomID
+	t.mu.Unlock()
+	return chatID
+}
+
+// choice returns the callback data of a choice picked with !n
+func (t *Transport) choice(roomID, text string) (string, int, bool) {
+	m := choicePattern.FindStringSubmatch(text)
+	if m == nil {
+		return "", 0, false
+	}
+	n, _ := strconv.Atoi(m[1])
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := t.keyboards[roomID]
+	if list == nil || n < 1 || n > len(list.Data) {
+		return "", 0, false
+	}
+	return list.Data[n-1], list.MessageID, true
+}
+
+// room returns the room to send to chatID into, opening a direct chat with a
+// user the bot hasn't talked to in one yet
+func (t *Transport) room(chatID int64) (string, error) {
+	t.mu.Lock()
+	roomID, ok := t.rooms[chatID]
+	user := t.users[chatID]
+	t.mu.Unlock()
+	if ok {
+		return roomID, nil
+	}
+	if user == "" {
+		return "", platform.BadRequest(fmt.Errorf("unknown chat %d", chatID))
+	}
+
+	var resp struct {
+		RoomID string `json:"room_id"`
+	}
+	create := map[string]interface{}{"is_direct": true, "invite": []string{user}, "preset": "trusted_private_chat"}
+	if err := t.call(context.Background(), http.MethodPost, "/createRoom", create, &resp); err != nil {
+		return "", fmt.Errorf("failed to open a direct chat with %s: %w", user, err)
+	}
+	logger.Infof("Opened direct chat %s with %s", resp.RoomID, user)
+	t.mu.Lock()
+	t.rooms[chatID] = resp.RoomID
+	t.members[resp.RoomID] = 2
+	t.mu.Unlock()
+	return resp.RoomID, nil
+}
+
+// Send sends a message or a file
+func (t *Transport) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		return t.sendText(c.ChatID, c.Text, c.ParseMode, platform.Keyboard(c.ReplyMarkup))
+	case tgbotapi.DocumentConfig:
+		return t.sendFile(c.BaseFile, "m.file", c.Caption, c.ParseMode)
+	case tgbotapi.PhotoConfig:
+		return t.sendFile(c.BaseFile, "m.image", c.Caption, c.ParseMode)
+	}
+	_, err := t.Request(c)
+	return tgbotapi.Message{}, err
+}
+
+// Request makes the calls that don't send a new message
+func (t *Transport) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	switch c := c.(type) {
+	case tgbotapi.EditMessageTextConfig:
+		return t.edit(c.BaseEdit, &c.Text, c.ParseMode)
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return t.edit(c.BaseEdit, nil, "")
+	case tgbotapi.DeleteMessageConfig:
+		return ok(nil, t.redact(c.MessageID))
+	case tgbotapi.CallbackConfig:
+		return ok(nil, t.answerChoice(c))
+	case tgbotapi.GetChatMemberConfig:
+		return t.chatMember(c.ChatID, c.UserID)
+	case tgbotapi.SetMyCommandsConfig, tgbotapi.DeleteWebhookConfig:
+		return ok(nil, nil)
+	case tgbotapi.MessageConfig, tgbotapi.DocumentConfig, tgbotapi.PhotoConfig:
+		msg, err := t.Send(c)
+		return ok(msg, err)
+	}
+	return nil, platform.BadRequest(fmt.Errorf("%T is not supported on Matrix", c))
+}
+
+// MakeRequest answers getMe while the homeserver answers. Raw sendMessage
+// calls are only made for Telegram forum topics.
+func (t *Transport) MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error) {
+	if endpoint != "getMe" {
+		return nil, platform.BadRequest(fmt.Errorf("%s is not supported on Matrix", endpoint))
+	}
+	if err := t.call(context.Background(), http.MethodGet, "/account/whoami", nil, nil); err != nil {
+		return nil, err
+	}
+	return ok(tgbotapi.User{ID: t.ChatID(t.self), IsBot: true, UserName: t.self}, nil)
+}
+
+// DownloadFile fetches a file sent to the bot, the file ID being its mxc:// URI
+func (t *Transport) DownloadFile(ctx context.Context, fileID string, limit int) ([]byte, error) {
+	media, ok := strings.CutPrefix(fileID, "mxc://")
+	if !ok {
+		return nil, fmt.Errorf("not a Matrix content URI: %s", fileID)
+	}
+	data, err := t.download(ctx, t.homeserver+"/_matrix/client/v1/media/download/"+media, limit)
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest {
+		// Homeservers before authenticated media
+		data, err = t.download(ctx, t.homeserver+"/_matrix/media/v3/download/"+media, limit)
+	}
+	return data, err
+}
+
+func (t *Transport) download(ctx context.Context, link string, limit int) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	res, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, responseError(res)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, fmt.Errorf("file too large")
+	}
+	return data, nil
+}
+
+// textContent is the content of a text message, or of an edit of one
+type textContent struct {
+	MsgType       string       `json:"msgtype"`
+	Body          string       `json:"body"`
+	Format        string       `json:"format,omitempty"`
+	FormattedBody string       `json:"formatted_body,omitempty"`
+	NewContent    *textContent `json:"m.new_content,omitempty"`
+	RelatesTo     *relation    `json:"m.relates_to,omitempty"`
+}
+
+type relation struct {
+	RelType string `json:"rel_type"`
+	EventID string `json:"event_id"`
+}
+
+// sendText sends a message with the keyboard listed as choices
+func (t *Transport) sendText(chatID int64, text, parseMode string, keyboard *tgbotapi.InlineKeyboardMarkup) (tgbotapi.Message, error) {
+	roomID, err := t.room(chatID)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	content, choices := render(text, parseMode, keyboard)
+	eventID, err := t.send(roomID, content)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+
+	t.mu.Lock()
+	t.lastID++
+	id := t.lastID
+	t.sent[id] = &sentMessage{Room: roomID, Event: eventID, Text: text, ParseMode: parseMode}
+	if len(choices) > 0 {
+		t.keyboards[roomID] = &choiceList{MessageID: id, Data: choices}
+	}
+	t.mu.Unlock()
+	return tgbotapi.Message{MessageID: id, Chat: &tgbotapi.Chat{ID: chatID}, Text: text}, nil
+}
+
+// sendFile uploads a file and sends it, the caption in a message of its own
+func (t *Transport) sendFile(base tgbotapi.BaseFile, msgType, caption, parseMode string) (tgbotapi.Message, error) {
+	roomID, err := t.room(base.ChatID)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	name, reader, err := base.File.UploadData()
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+
+	var uploaded struct {
+		ContentURI string `json:"content_uri"`
+	}
+	link := t.homeserver + "/_matrix/media/v3/upload?filename=" + url.QueryEscape(name)
+	if err := t.do(context.Background(), http.MethodPost, link, "application/octet-stream", bytes.NewReader(data), &uploaded); err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	file := map[string]interface{}{
+		"msgtype": msgType,
+		"body":    name,
+		"url":     uploaded.ContentURI,
+		"info":    map[string]int{"size": len(data)},
+	}
+	if _, err := t.send(roomID, file); err != nil {
+		return tgbotapi.Message{}, err
+	}
+	if caption == "" {
+		return tgbotapi.Message{Chat: &tgbotapi.Chat{ID: base.ChatID}}, nil
+	}
+	return t.sendText(base.ChatID, caption, parseMode, platform.Keyboard(base.ReplyMarkup))
+}
+
+// edit replaces a message's text, when text isn't nil, and its choices
+func (t *Transport) edit(base tgbotapi.BaseEdit, text *string, parseMode string) (*tgbotapi.APIResponse, error) {
+	t.mu.Lock()
+	msg := t.sent[base.MessageID]
+	t.mu.Unlock()
+	if msg == nil {
+		return nil, platform.BadRequest(fmt.Errorf("message %d not found", base.MessageID))
+	}
+	newText, newMode := msg.Text, msg.ParseMode
+	if text != nil {
+		newText, newMode = *text, parseMode
+	}
+
+	content, choices := render(newText, newMode, base.ReplyMarkup)
+	edit := textContent{
+		MsgType:       content.MsgType,
+		Body:          "* " + content.Body,
+		Format:        content.Format,
+		FormattedBody: "* " + content.FormattedBody,
+		NewContent:    &content,
+		RelatesTo:     &relation{RelType: "m.replace", EventID: msg.Event},
+	}
+	if _, err := t.send(msg.Room, edit); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	msg.Text, msg.ParseMode = newText, newMode
+	if len(choices) > 0 {
+		t.keyboards[msg.Room] = &choiceList{MessageID: base.MessageID, Data: choices}
+	} else if list := t.keyboards[msg.Room]; list != nil && list.MessageID == base.MessageID {
+		delete(t.keyboards, msg.Room)
+	}
+	t.mu.Unlock()
+	return ok(tgbotapi.Message{MessageID: base.MessageID, Chat: &tgbotapi.Chat{ID: base.ChatID}, Text: newText}, nil)
+}
+
+// redact deletes a message the bot sent
+func (t *Transport) redact(messageID int) error {
+	t.mu.Lock()
+	msg := t.sent[messageID]
+	delete(t.sent, messageID)
+	t.mu.Unlock()
+	if msg == nil {
+		return platform.BadRequest(fmt.Errorf("message %d not found", messageID))
+	}
+	path := "/rooms/" + url.PathEscape(msg.Room) + "/redact/" + url.PathEscape(msg.Event) + "/" + t.nextTxn()
+	return t.call(context.Background(), http.MethodPut, path, struct{}{}, nil)
+}
+
+// answerChoice sends the answer to a picked choice as a notice
+func (t *Transport) answerChoice(c tgbotapi.CallbackConfig) error {
+	t.mu.Lock()
+	roomID := t.clicks[c.CallbackQueryID]
+	delete(t.clicks, c.CallbackQueryID)
+	t.mu.Unlock()
+	if roomID == "" || c.Text == "" {
+		return nil
+	}
+	_, err := t.send(roomID, textContent{MsgType: "m.notice", Body: c.Text})
+	return err
+}
+
+// chatMember reports a user with power level 50 or more, a moderator, as a
+// Telegram administrator
+func (t *Transport) chatMember(chatID, userID int64) (*tgbotapi.APIResponse, error) {
+	roomID, err := t.room(chatID)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	user := t.users[userID]
+	t.mu.Unlock()
+
+	var levels struct {
+		Users        map[string]int `json:"users"`
+		UsersDefault int            `json:"users_default"`
+	}
+	if err := t.call(context.Background(), http.MethodGet, "/rooms/"+url.PathEscape(roomID)+"/state/m.room.power_levels", nil, &levels); err != nil {
+		return nil, err
+	}
+	level, set := levels.Users[user]
+	if !set {
+		level = levels.UsersDefault
+	}
+	status := "member"
+	if level >= 50 {
+		status = "administrator"
+	}
+	return ok(tgbotapi.ChatMember{User: &tgbotapi.User{ID: userID, UserName: user}, Status: status}, nil)
+}
+
+// send sends an event into a room and returns its event ID
+func (t *Transport) send(roomID string, content interface{}) (string, error) {
+	var resp struct {
+		EventID string `json:"event_id"`
+	}
+	path := "/rooms/" + url.PathEscape(roomID) + "/send/m.room.message/" + t.nextTxn()
+	if err := t.call(context.Background(), http.MethodPut, path, content, &resp); err != nil {
+		return "", err
+	}
+	return resp.EventID, nil
+}
+
+// nextTxn returns a transaction ID, which makes a retried send idempotent
+func (t *Transport) nextTxn() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.txn++
+	return t.txnPrefix + "." + strconv.Itoa(t.txn)
+}
+
+// call makes a client-server API request with a JSON body
+func (t *Transport) call(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	return t.do(ctx, method, t.homeserver+"/_matrix/client/v3"+path, "application/json", reader, result)
+}
+
+// do makes an authenticated request and decodes the JSON answer into result
+func (t *Transport) do(ctx context.Context, method, link, contentType string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, link, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return responseError(res)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// responseError converts a Matrix error response: rate limiting to Telegram's
+// 429 with the delay to retry after, refusals to a bad request, which the bot
+// doesn't retry
+func responseError(res *http.Response) error {
+	var body struct {
+		ErrCode      string `json:"errcode"`
+		Error        string `json:"error"`
+		RetryAfterMs int    `json:"retry_after_ms"`
+	}
+	json.NewDecoder(res.Body).Decode(&body)
+	err := fmt.Errorf("%s: %s (HTTP %d)", body.ErrCode, body.Error, res.StatusCode)
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		return &tgbotapi.Error{
+			Code:               res.StatusCode,
+			Message:            err.Error(),
+			ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: body.RetryAfterMs/1000 + 1},
+		}
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound:
+		return platform.BadRequest(err)
+	}
+	return err
+}
+
+// render converts a message to Matrix's plain body and HTML, listing the
+// keyboard under it. Returns the callback data of the numbered choices.
+func render(text, parseMode string, keyboard *tgbotapi.InlineKeyboardMarkup) (textContent, []string) {
+	segments := []platform.Segment{{Kind: platform.Text, Text: text}}
+	if parseMode == tgbotapi.ModeMarkdown {
+		segments = platform.ParseMarkdown(text)
+	}
+	var body, formatted strings.Builder
+	for _, seg := range segments {
+		escaped := htmlText(seg.Text)
+		switch seg.Kind {
+		case platform.Text:
+			body.WriteString(seg.Text)
+			formatted.WriteString(escaped)
+		case platform.Bold:
+			body.WriteString(seg.Text)
+			formatted.WriteString("<b>" + escaped + "</b>")
+		case platform.Italic:
+			body.WriteString(seg.Text)
+			formatted.WriteString("<i>" + escaped + "</i>")
+		case platform.Code:
+			body.WriteString(seg.Text)
+			formatted.WriteString("<code>" + html.EscapeString(seg.Text) + "</code>")
+		case platform.Pre:
+			body.WriteString(seg.Text)
+			formatted.WriteString("<pre><code>" + html.EscapeString(seg.Text) + "</code></pre>")
+		case platform.Link:
+			body.WriteString(seg.Text + " (" + seg.URL + ")")
+			formatted.WriteString(`<a href="` + html.EscapeString(seg.URL) + `">` + escaped + "</a>")
+		}
+	}
+
+	var choices []string
+	if keyboard != nil && len(platform.Buttons(keyboard)) > 0 {
+		body.WriteString("\n")
+		formatted.WriteString("<br>")
+		for _, row := range keyboard.InlineKeyboard {
+			var plainRow, htmlRow []string
+			for _, b := range row {
+				switch {
+				case b.CallbackData != nil:
+					choices = append(choices, *b.CallbackData)
+					n := strconv.Itoa(len(choices))
+					plainRow = append(plainRow, "!"+n+" "+b.Text)
+					htmlRow = append(htmlRow, "<code>!"+n+"</code> "+html.EscapeString(b.Text))
+				case b.URL != nil:
+					plainRow = append(plainRow, b.Text+": "+*b.URL)
+					htmlRow = append(htmlRow, `<a href="`+html.EscapeString(*b.URL)+`">`+html.EscapeString(b.Text)+"</a>")
+				}
+			}
+			body.WriteString("\n" + strings.Join(plainRow, "    "))
+			formatted.WriteString("<br>" + strings.Join(htmlRow, "&nbsp;&nbsp;&nbsp;&nbsp;"))
+		}
+	}
+	return textContent{
+		MsgType:       "m.text",
+		Body:          body.String(),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: formatted.String(),
+	}, choices
+}
+
+// htmlText escapes text for HTML, keeping its line breaks
+func htmlText(s string) string {
+	return strings.ReplaceAll(html.EscapeString(s), "\n", "<br>")
+}
+
+// stripReplyFallback removes the quote of the replied-to message clients put
+// in front of a reply's body
+func stripReplyFallback(body string) string {
+	if !strings.HasPrefix(body, "> ") {
+		return strings.TrimSpace(body)
+	}
+	lines := strings.Split(body, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], ">") {
+		i++
+	}
+	return strings.TrimSpace(strings.Join(lines[i:], "\n"))
+}
+
+// ok wraps a result as a successful API response, or returns err
+func ok(result interface{}, err error) (*tgbotapi.APIResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return &tgbotapi.APIResponse{Ok: true, Result: raw}, nil
+}
@@ -0,0 +1,36 @@
+package matrix
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestChatIDKeyed(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "matrix.key")
+	key, err := loadKey(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := loadKey(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, again) {
+		t.Fatal("key changed when read back")
+	}
+
+	// Chat IDs stay put across restarts, but can't be worked out without
+	// the key
+	a, b := &Transport{key: key}, &Transport{key: again}
+	if a.ChatID("@admin:example.org") != b.ChatID("@admin:example.org") {
+		t.Error("same key, different chat IDs")
+	}
+	other := &Transport{key: make([]byte, keySize)}
+	if a.ChatID("@admin:example.org") == other.ChatID("@admin:example.org") {
+		t.Error("chat ID doesn't depend on the key")
+	}
+	if id := a.ChatID("@admin:example.org"); id <= 0 {
+		t.Errorf("chat ID %d, want a positive one", id)
+	}
+}
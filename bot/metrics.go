@@ -0,0 +1,239 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const sparklineWidth = 20
+
+// showMetrics shows last-hour metrics of an instance, or the instance list when no name is given
+func (b *Bot) showMetrics(chatID int64, args string) {
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	instances, err := client.ListInstances(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	if args != "" {
+		for _, inst := range instances {
+			if inst.ID == args || inst.DisplayName == args {
+				b.showInstanceMetrics(chatID, client, inst)
+				return
+			}
+		}
+		b.reply(chatID, "❌ 未找到实例: "+args)
+		return
+	}
+
+	if len(instances) == 0 {
+		b.reply(chatID, fmt.Sprintf("📋 [%s] 暂无实例", client.AccountName()))
+		return
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, inst := range instances {
+		label := fmt.Sprintf("%s (%s)", inst.DisplayName, inst.State)
+		btn := tgbotapi.NewInlineKeyboardButtonData(label, "metrics:"+b.callbackRef(inst.ID))
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("📈 *[%s] 选择实例*", client.AccountName()))
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
+
+// showMetricsFromCallback resolves the instance chosen from the metrics list
+func (b *Bot) showMetricsFromCallback(chatID int64, ref string) {
+	instanceID, ok := b.resolveRef(ref)
+	if !ok {
+		b.reply(chatID, "⚠️ 按钮已过期，请重新使用 /metrics")
+		return
+	}
+	b.showMetrics(chatID, instanceID)
+}
+
+// showInstanceMetrics sends a last-hour summary with sparklines
+func (b *Bot) showInstanceMetrics(chatID int64, client oci.Service, inst oci.InstanceInfo) {
+	ctx, cancel := b.withTimeout(reportTimeout)
+	defer cancel()
+
+	metrics, err := client.GetInstanceMetrics(ctx, inst.ID, time.Hour)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	if len(metrics.CPU) == 0 && len(metrics.Memory) == 0 {
+		b.reply(chatID, fmt.Sprintf("⚠️ %s 暂无监控数据\n请确认实例已启用 Oracle Cloud Agent 的监控插件", inst.DisplayName))
+		return
+	}
+
+	cpuAvg, cpuMax := metricStats(metrics.CPU)
+	memAvg, memMax := metricStats(metrics.Memory)
+	inAvg, _ := metricStats(metrics.NetIn)
+	outAvg, _ := metricStats(metrics.NetOut)
+	seconds := metrics.Interval.Seconds()
+
+	text := fmt.Sprintf(`📈 %s (最近1小时)
+
+🧮 CPU: 平均 %.1f%% / 峰值 %.1f%%
+%s
+🧠 内存: 平均 %.1f%% / 峰值 %.1f%%
+%s
+⬇️ 入站: %s/s
+%s
+⬆️ 出站: %s/s
+%s
+
+📍 [%s] %s`,
+		inst.DisplayName,
+		cpuAvg, cpuMax, sparkline(metrics.CPU, sparklineWidth),
+		memAvg, memMax, sparkline(metrics.Memory, sparklineWidth),
+		formatBytes(inAvg/seconds), sparkline(metrics.NetIn, sparklineWidth),
+		formatBytes(outAvg/seconds), sparkline(metrics.NetOut, sparklineWidth),
+		client.AccountName(), client.Region())
+
+	b.reply(chatID, text)
+}
+
+// runCPUAlertMonitor alerts when an instance's CPU stays above the threshold
+// for the configured duration, and again once it recovers
+func (b *Bot) runCPUAlertMonitor(ctx context.Context) {
+	interval := time.Duration(b.cfg.MetricsCheckMinutes) * time.Minute
+	logger.Infof("CPU alert monitor started (every %s, >%d%% for %d min)", interval, b.cfg.CPUAlertPercent, b.cfg.CPUAlertMinutes)
+
+	alerted := make(map[string]bool) // Instance ID -> currently in alert
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.checkCPUAlerts(ctx, alerted)
+		}
+	}
+}
+
+func (b *Bot) checkCPUAlerts(ctx context.Context, alerted map[string]bool) {
+	window := time.Duration(b.cfg.CPUAlertMinutes) * time.Minute
+	threshold := float64(b.cfg.CPUAlertPercent)
+
+	for _, client := range b.sortedClients() {
+		name := client.AccountName()
+
+		listCtx, cancel := context.WithTimeout(ctx, batchTimeout)
+		instances, err := client.ListInstances(listCtx)
+		if err != nil {
+			cancel()
+			logger.Warnf("[%s] CPU alert check failed: %v", name, err)
+			continue
+		}
+
+		for _, inst := range instances {
+			if inst.State != "RUNNING" {
+				continue
+			}
+			metrics, err := client.GetInstanceMetrics(listCtx, inst.ID, window)
+			if err != nil {
+				logger.Warnf("[%s] Metrics for %s failed: %v", name, inst.DisplayName, err)
+				continue
+			}
+
+			// Require most of the window to be covered so a short gap doesn't trigger
+			high := len(metrics.CPU) >= int(window/metrics.Interval)/2
+			for _, p := range metrics.CPU {
+				if p.Value <= threshold {
+					high = false
+					break
+				}
+			}
+
+			switch {
+			case high && !alerted[inst.ID]:
+				alerted[inst.ID] = true
+				avg, _ := metricStats(metrics.CPU)
+				b.alert(fmt.Sprintf("🔥 [%s] %s CPU 持续 %d 分钟超过 %d%% (平均 %.1f%%)",
+					name, inst.DisplayName, b.cfg.CPUAlertMinutes, b.cfg.CPUAlertPercent, avg))
+			case !high && alerted[inst.ID]:
+				delete(alerted, inst.ID)
+				b.alert(fmt.Sprintf("✅ [%s] %s CPU 已恢复正常", name, inst.DisplayName))
+			}
+		}
+		cancel()
+	}
+}
+
+// metricStats returns the average and maximum of the points
+func metricStats(points []oci.MetricPoint) (avg, max float64) {
+	if len(points) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, p := range points {
+		sum += p.Value
+		max = math.Max(max, p.Value)
+	}
+	return sum / float64(len(points)), max
+}
+
+// sparkline renders points as block characters, averaging them into at most width buckets
+func sparkline(points []oci.MetricPoint, width int) string {
+	if len(points) == 0 {
+		return "(无数据)"
+	}
+	levels := []rune("▁▂▃▄▅▆▇█")
+
+	buckets := make([]float64, 0, width)
+	size := int(math.Ceil(float64(len(points)) / float64(width)))
+	for i := 0; i < len(points); i += size {
+		end := min(i+size, len(points))
+		avg, _ := metricStats(points[i:end])
+		buckets = append(buckets, avg)
+	}
+
+	lo, hi := buckets[0], buckets[0]
+	for _, v := range buckets {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+
+	var sb strings.Builder
+	for _, v := range buckets {
+		idx := 0
+		if hi > lo {
+			idx = int((v - lo) / (hi - lo) * float64(len(levels)-1))
+		}
+		sb.WriteRune(levels[idx])
+	}
+	return sb.String()
+}
+
+// formatBytes formats a byte count with binary units
+func formatBytes(n float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
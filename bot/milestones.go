@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"oci-bot/ippure"
+)
+
+// missedIP is an auto-apply candidate that did not match, kept to report
+// how close the task got
+type missedIP struct {
+	IP    string
+	Info  *ippure.IPInfo
+	Score ipScore
+}
+
+// noteMiss remembers a candidate that did not match and, with auto_near_miss,
+// reports it when its score is within the margin above the threshold and
+// closer than the last one reported.
+func (b *Bot) noteMiss(config *AutoApplyConfig, ip string, info *ippure.IPInfo, score ipScore) {
+	miss := &missedIP{IP: ip, Info: info, Score: score}
+	margin := b.currentConfig().AutoNearMiss
+
+	b.mu.Lock()
+	if config.BestMiss == nil || score.Value < config.BestMiss.Score.Value {
+		config.BestMiss = miss
+	}
+	near := margin > 0 && score.Value <= config.PurityThreshold+margin &&
+		(config.NearMiss == nil || score.Value < config.NearMiss.Score.Value)
+	if near {
+		config.NearMiss = miss
+	}
+	criteria := *config
+	b.mu.Unlock()
+	if !near {
+		return
+	}
+
+	logger.Infof("Near miss %s (score %s, threshold %d)", ip, score, criteria.PurityThreshold)
+	text := fmt.Sprintf("🎯 *接近条件的IP* [%s]\n\n%s\n\n放宽条件后从下一个IP起生效",
+		escapeMarkdown(criteria.AccountName), describeMiss(miss, &criteria))
+	b.sendTuneButtons(criteria.ChatID, text, relaxButtons(miss, &criteria))
+}
+
+// notifyProgress reports an auto-apply task still searching after every
+// auto_progress_every attempts
+func (b *Bot) notifyProgress(config *AutoApplyConfig, attempt int) {
+	every := b.currentConfig().AutoProgressEvery
+	if every == 0 || attempt%every != 0 {
+		return
+	}
+
+	b.mu.Lock()
+	criteria := *config
+	stages := config.Stages.summary()
+	b.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 *自动刷IP进度* [%s]\n\n", escapeMarkdown(criteria.AccountName)))
+	sb.WriteString(fmt.Sprintf("已尝试 %d 次，尚未找到符合条件的IP\n", attempt))
+	sb.WriteString(escapeMarkdown(criteria.Pace.Summary()) + "\n")
+	if stages != "" {
+		sb.WriteString("筛选: " + escapeMarkdown(stages) + "\n")
+	}
+	sb.WriteString("条件: " + escapeMarkdown(describeCriteria(&criteria)) + "\n")
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	if miss := criteria.BestMiss; miss != nil {
+		sb.WriteString("\n目前最接近:\n" + describeMiss(miss, &criteria))
+		buttons = relaxButtons(miss, &criteria)
+	}
+	b.sendTuneButtons(criteria.ChatID, sb.String(), buttons)
+}
+
+// describeMiss formats a missed IP with what kept it from matching
+func describeMiss(miss *missedIP, config *AutoApplyConfig) string {
+	text := fmt.Sprintf("IP: `%s`\n评分: %s (阈值 %d)", miss.IP, escapeMarkdown(miss.Score.String()), config.PurityThreshold)
+	if miss.Info.Available() {
+		text += escapeMarkdown(fmt.Sprintf("\n%s · %s", miss.Info.IPType, miss.Info.IsNative))
+	}
+	return text
+}
+
+// describeCriteria formats the match criteria of a task on one line
+func describeCriteria(config *AutoApplyConfig) string {
+	text := fmt.Sprintf("纯净度 ≤ %d", config.PurityThreshold)
+	if config.NativeRequired != "any" {
+		text += " · " + config.NativeRequired
+	}
+	if config.TypeRequired != "any" {
+		text += " · " + config.TypeRequired
+	}
+	if config.MatchMode == "any" {
+		text += " (满足其一)"
+	}
+	return text
+}
+
+// relaxButtons offers to relax each criterion a missed IP failed
+func relaxButtons(miss *missedIP, config *AutoApplyConfig) [][]tgbotapi.InlineKeyboardButton {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	if miss.Score.Value > config.PurityThreshold && miss.Score.Value <= 100 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("📈 阈值放宽到 %d", miss.Score.Value), "tune:purity:"+strconv.Itoa(miss.Score.Value))))
+	}
+	if !miss.Info.Available() {
+		return buttons
+	}
+	if config.NativeRequired != "any" && miss.Info.IsNative != config.NativeRequired {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌐 来源不限", "tune:native:any")))
+	}
+	if config.TypeRequired != "any" && miss.Info.IPType != config.TypeRequired {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🏢 类型不限", "tune:type:any")))
+	}
+	return buttons
+}
+
+// sendTuneButtons sends a Markdown auto-apply message with buttons into the
+// auto topic
+func (b *Bot) sendTuneButtons(chatID int64, text string, buttons [][]tgbotapi.InlineKeyboardButton) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.DisableWebPagePreview = true
+	if len(buttons) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	}
+	b.sendIn(topicAuto, msg)
+}
@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// showNetwork shows the VNICs of an instance, or the instance list when no name is given
+func (b *Bot) showNetwork(chatID int64, args string) {
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	instances, err := client.ListInstances(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	if args != "" {
+		for _, inst := range instances {
+			if inst.ID == args || inst.DisplayName == args {
+				b.showInstanceNetwork(chatID, client, inst)
+				return
+			}
+		}
+		b.reply(chatID, "❌ 未找到实例: "+args)
+		return
+	}
+
+	if len(instances) == 0 {
+		b.reply(chatID, fmt.Sprintf("📋 [%s] 暂无实例", client.AccountName()))
+		return
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, inst := range instances {
+		label := fmt.Sprintf("%s (%s)", inst.DisplayName, inst.State)
+		btn := tgbotapi.NewInlineKeyboardButtonData(label, "network:"+b.callbackRef(inst.ID))
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🔌 *[%s] 选择实例*", client.AccountName()))
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
+
+// showNetworkFromCallback resolves the instance chosen from the network list
+func (b *Bot) showNetworkFromCallback(chatID int64, ref string) {
+	instanceID, ok := b.resolveRef(ref)
+	if !ok {
+		b.reply(chatID, "⚠️ 按钮已过期，请重新使用 /network")
+		return
+	}
+	b.showNetwork(chatID, instanceID)
+}
+
+// showInstanceNetwork sends the VNIC, private/public IP and security list view of an instance
+func (b *Bot) showInstanceNetwork(chatID int64, client oci.Service, inst oci.InstanceInfo) {
+	ctx, cancel := b.withTimeout(reportTimeout)
+	defer cancel()
+
+	vnics, err := client.GetInstanceNetwork(ctx, inst.ID)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+	if len(vnics) == 0 {
+		b.reply(chatID, fmt.Sprintf("⚠️ %s 没有已挂载的 VNIC", inst.DisplayName))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔌 %s 网络\n", inst.DisplayName))
+
+	for _, vnic := range vnics {
+		primary := ""
+		if vnic.IsPrimary {
+			primary = " (主)"
+		}
+		sb.WriteString(fmt.Sprintf("\n📶 VNIC %s%s\n", vnic.DisplayName, primary))
+		sb.WriteString(fmt.Sprintf("   子网: %s %s\n", vnic.SubnetName, vnic.SubnetCIDR))
+		if vnic.MACAddress != "" {
+			sb.WriteString(fmt.Sprintf("   MAC: %s\n", vnic.MACAddress))
+		}
+
+		for _, ip := range vnic.PrivateIPs {
+			label := "辅助"
+			if ip.IsPrimary {
+				label = "主"
+			}
+			line := fmt.Sprintf("   • %s (%s)", ip.IPAddress, label)
+			switch {
+			case ip.PublicIP == nil:
+				line += " → 无公网IP"
+			case ip.PublicIP.Lifetime == "RESERVED":
+				line += fmt.Sprintf(" → %s (预留 %s)", ip.PublicIP.IPAddress, ip.PublicIP.DisplayName)
+			default:
+				line += fmt.Sprintf(" → %s (临时)", ip.PublicIP.IPAddress)
+			}
+			sb.WriteString(line + "\n")
+		}
+
+		for _, list := range vnic.SecurityLists {
+			sb.WriteString(fmt.Sprintf("   🛡 %s: %d 条入站, %d 条出站\n", list.DisplayName, len(list.IngressRules), list.EgressCount))
+			for _, rule := range list.IngressRules {
+				sb.WriteString("      - " + rule + "\n")
+			}
+		}
+		if vnic.NSGCount > 0 {
+			sb.WriteString(fmt.Sprintf("   🛡 网络安全组: %d 个\n", vnic.NSGCount))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("\n📍 [%s] %s", client.AccountName(), client.Region()))
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ReplyMarkup = b.connectionButtons(client.AccountName(), inst.ID)
+	b.send(msg)
+}
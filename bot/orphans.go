@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// allAccounts is the orphans callback parameter that targets every account
+const allAccounts = "*"
+
+// showOrphans lists reserved IPs that are not attached to any private IP across all accounts
+func (b *Bot) showOrphans(chatID int64) {
+	var sb strings.Builder
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	found, total := 0, 0 // All unattached IPs, and those not pinned
+	sb.WriteString("🔍 *未绑定的预留IP*\n")
+
+	for _, client := range b.sortedClients() {
+		name := client.AccountName()
+
+		ctx, cancel := b.withTimeout(callTimeout)
+		ips, err := client.ListReservedIPs(ctx)
+		cancel()
+		if err != nil {
+			sb.WriteString(fmt.Sprintf("\n📍 *[%s]* ⚠️ 查询失败: %s\n", name, escapeMarkdown(describeError(err))))
+			continue
+		}
+
+		orphans := staleUnattachedIPs(ips, 0)
+		if len(orphans) == 0 {
+			continue
+		}
+		found += len(orphans)
+
+		sb.WriteString(fmt.Sprintf("\n📍 *[%s]* %s\n", name, client.Region()))
+		for _, ip := range orphans {
+			line := fmt.Sprintf("• `%s`", ip.IPAddress)
+			if b.isPinned(ip.IPAddress) {
+				line = fmt.Sprintf("📌 `%s`", ip.IPAddress)
+			}
+			if !ip.TimeCreated.IsZero() {
+				line += fmt.Sprintf(" (%s)", time.Since(ip.TimeCreated).Round(time.Hour))
+			}
+			sb.WriteString(line + "\n")
+		}
+
+		releasable := len(b.withoutPinned(orphans))
+		if releasable == 0 {
+			continue
+		}
+		total += releasable
+		label := fmt.Sprintf("🗑 释放 [%s] 的 %d 个", name, releasable)
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(label, "orphans:"+name)})
+	}
+
+	if found == 0 {
+		b.reply(chatID, "✅ 所有账号的预留IP都已绑定")
+		return
+	}
+
+	if len(buttons) > 1 {
+		label := fmt.Sprintf("🗑 全部释放 (%d 个)", total)
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(label, "orphans:"+allAccounts)})
+	}
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	if len(buttons) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	}
+	b.send(msg)
+}
+
+// releaseOrphans releases the unattached IPs of one account, or of all accounts for "*"
+func (b *Bot) releaseOrphans(chatID int64, accountName string) {
+	if accountName != allAccounts {
+		b.releaseUnattachedIPs(chatID, accountName, 0)
+		return
+	}
+
+	for _, client := range b.sortedClients() {
+		b.releaseUnattachedIPs(chatID, client.AccountName(), 0)
+	}
+}
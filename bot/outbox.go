@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"oci-bot/state"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	outboxKey      = "outbox"         // State section holding undelivered notifications
+	outboxInterval = time.Minute      // How often delivery is retried
+	outboxMaxAge   = 24 * time.Hour   // Notifications older than this are dropped
+	outboxMaxSize  = 100              // Oldest notifications are dropped beyond this
+	outboxTimeFmt  = "01-02 15:04:05" // Original time shown on late deliveries
+)
+
+// outboxEntry is a critical notification that couldn't be delivered. Buttons
+// are not kept; they would be stale by the time it is delivered.
+type outboxEntry struct {
+	ChatID    int64     `json:"chat_id"`
+	Thread    int       `json:"thread,omitempty"`
+	Text      string    `json:"text"`
+	ParseMode string    `json:"parse_mode,omitempty"`
+	Queued    time.Time `json:"queued"`
+}
+
+// loadOutbox reads undelivered notifications from the store
+func loadOutbox(store *state.Store) ([]outboxEntry, error) {
+	var entries []outboxEntry
+	if err := store.Get(outboxKey, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// queueOutbox keeps a critical notification for later delivery
+func (b *Bot) queueOutbox(msg tgbotapi.MessageConfig, thread int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.outbox = append(b.outbox, outboxEntry{
+		ChatID:    msg.ChatID,
+		Thread:    thread,
+		Text:      msg.Text,
+		ParseMode: msg.ParseMode,
+		Queued:    time.Now(),
+	})
+	if len(b.outbox) > outboxMaxSize {
+		b.outbox = b.outbox[len(b.outbox)-outboxMaxSize:]
+	}
+	b.saveOutboxLocked()
+}
+
+// saveOutboxLocked persists the outbox. Caller must hold b.mu.
+func (b *Bot) saveOutboxLocked() {
+	if err := b.store.Set(outboxKey, b.outbox); err != nil {
+		logger.Errorf("Failed to save outbox: %v", err)
+	}
+}
+
+// runOutbox retries undelivered notifications, including ones left over from
+// before a restart
+func (b *Bot) runOutbox(ctx context.Context) {
+	ticker := time.NewTicker(outboxInterval)
+	defer ticker.Stop()
+
+	for {
+		b.flushOutbox()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// flushOutbox tries to deliver every queued notification once, oldest first,
+// and stops at the first failure since Telegram is evidently still unreachable
+func (b *Bot) flushOutbox() {
+	b.mu.Lock()
+	entries := b.outbox
+	b.mu.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+
+	handled := make(map[outboxEntry]bool, len(entries))
+	for _, entry := range entries {
+		if time.Since(entry.Queued) > outboxMaxAge {
+			logger.Warnf("Dropping notification queued at %s: %.50q", entry.Queued.Format(outboxTimeFmt), entry.Text)
+			handled[entry] = true
+			continue
+		}
+
+		msg := tgbotapi.NewMessage(entry.ChatID, fmt.Sprintf("⏰ 延迟送达 (%s)\n\n%s", entry.Queued.Format(outboxTimeFmt), entry.Text))
+		msg.ParseMode = entry.ParseMode
+		if _, err := b.transmit(b.formatMessage(msg), entry.Thread); err != nil {
+			if !isBadRequest(err) {
+				logger.Debugf("Outbox delivery still failing: %v", err)
+				break
+			}
+			if _, err := b.transmit(plainMessage(msg), entry.Thread); err != nil {
+				logger.Warnf("Dropping undeliverable notification: %v", err)
+			}
+		}
+		handled[entry] = true
+	}
+
+	if len(handled) == 0 {
+		return
+	}
+	logger.Infof("Outbox: %d of %d notifications handled", len(handled), len(entries))
+
+	// Entries are removed by identity: while we were sending, new ones may
+	// have been queued and old ones trimmed by queueOutbox
+	b.mu.Lock()
+	var kept []outboxEntry
+	for _, entry := range b.outbox {
+		if !handled[entry] {
+			kept = append(kept, entry)
+		}
+	}
+	b.outbox = kept
+	b.saveOutboxLocked()
+	b.mu.Unlock()
+}
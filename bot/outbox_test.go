@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"oci-bot/bot/tgfake"
+	"oci-bot/oci/ocifake"
+)
+
+// flakyTransport runs onSend before each send and fails the sends after the
+// first ok ones
+type flakyTransport struct {
+	*tgfake.Transport
+	ok     int
+	onSend func()
+}
+
+func (t *flakyTransport) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if t.onSend != nil {
+		t.onSend()
+	}
+	if t.ok == 0 {
+		return tgbotapi.Message{}, errors.New("network is unreachable")
+	}
+	t.ok--
+	return t.Transport.Send(c)
+}
+
+func TestFlushOutboxKeepsNewEntries(t *testing.T) {
+	b, api := newTestBot(t, ocifake.New("main", "ap-tokyo-1"))
+	for i := range outboxMaxSize {
+		b.queueOutbox(tgbotapi.NewMessage(testAdmin, fmt.Sprintf("old %d", i)), 0)
+	}
+
+	// A notification queued during the flush trims the oldest, which is
+	// being sent, and must survive the flush
+	queued := false
+	b.api = &flakyTransport{Transport: api, ok: 2, onSend: func() {
+		if !queued {
+			queued = true
+			b.queueOutbox(tgbotapi.NewMessage(testAdmin, "new"), 0)
+		}
+	}}
+	b.flushOutbox()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// old 0 was trimmed, old 1 delivered, old 2 failed
+	if len(b.outbox) != outboxMaxSize-1 {
+		t.Fatalf("%d entries left, want %d", len(b.outbox), outboxMaxSize-1)
+	}
+	if first := b.outbox[0].Text; first != "old 2" {
+		t.Errorf("first entry left %q, want old 2", first)
+	}
+	if last := b.outbox[len(b.outbox)-1].Text; last != "new" {
+		t.Errorf("last entry left %q, want new", last)
+	}
+}
+
+func TestTransmitWithRetryStopsOnShutdown(t *testing.T) {
+	b, api := newTestBot(t, ocifake.New("main", "ap-tokyo-1"))
+	b.api = &flakyTransport{Transport: api}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	b.runCtx = ctx
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if _, err := b.transmitWithRetry(tgbotapi.NewMessage(testAdmin, "hello"), 0); err == nil {
+		t.Fatal("send succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %s, want right after shutdown", elapsed)
+	}
+}
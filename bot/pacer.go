@@ -0,0 +1,210 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"oci-bot/ippure"
+	"oci-bot/oci"
+)
+
+// pacer decides how long auto-apply and auto-VPS wait between attempts and
+// records the observed call rate for /status. With auto_adaptive it shortens
+// the wait by a fifth after every call that goes through and doubles it when
+// OCI throttles, staying within auto_adaptive_min..auto_adaptive_max.
+// Otherwise it waits a random time in the interval chosen in the wizard.
+type pacer struct {
+	mu          sync.Mutex
+	adaptive    bool
+	intervalMin time.Duration // Wizard interval, used when not adaptive
+	intervalMax time.Duration
+	floor       time.Duration // Adaptive bounds
+	ceiling     time.Duration
+	current     time.Duration // Current adaptive wait
+	retuned     chan struct{} // Closed when the interval changes, see SetInterval
+
+	started      time.Time
+	calls        int
+	throttled    int
+	lastThrottle time.Time
+}
+
+// newPacer creates a pacer for a task with the wizard interval in seconds.
+// Called with b.mu held.
+func (b *Bot) newPacer(intervalMin, intervalMax int) *pacer {
+	p := &pacer{
+		adaptive:    b.cfg.AutoAdaptive,
+		intervalMin: time.Duration(intervalMin) * time.Second,
+		intervalMax: time.Duration(intervalMax) * time.Second,
+		floor:       time.Duration(b.cfg.AutoAdaptiveMin) * time.Second,
+		ceiling:     time.Duration(b.cfg.AutoAdaptiveMax) * time.Second,
+		started:     time.Now(),
+		retuned:     make(chan struct{}),
+	}
+	// Start from the middle of the wizard interval
+	p.current = p.clamp((p.intervalMin + p.intervalMax) / 2)
+	return p
+}
+
+func (p *pacer) clamp(d time.Duration) time.Duration {
+	if d < p.floor {
+		return p.floor
+	}
+	if d > p.ceiling {
+		return p.ceiling
+	}
+	return d
+}
+
+// Record notes the outcome of an OCI call. Throttling doubles the adaptive
+// wait; any other outcome means OCI accepted the call rate and shortens it.
+func (p *pacer) Record(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls++
+	if err != nil && oci.IsThrottled(err) {
+		p.throttled++
+		p.lastThrottle = time.Now()
+		p.current = p.clamp(p.current * 2)
+		return
+	}
+	p.current = p.clamp(p.current * 4 / 5)
+}
+
+// Next returns the wait before the next attempt.
+func (p *pacer) Next() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.adaptive {
+		if p.intervalMax > p.intervalMin {
+			return p.intervalMin + time.Duration(rand.Int63n(int64(p.intervalMax-p.intervalMin)+1))
+		}
+		return p.intervalMin
+	}
+	// ±10% jitter so attempts do not land on a fixed period
+	jitter := time.Duration(rand.Int63n(int64(p.current)/5+1)) - p.current/10
+	return (p.current + jitter).Round(time.Second)
+}
+
+// Wait sleeps for Next or until ctx is done. A wait under way when the
+// interval changes is shortened or extended to the new one.
+func (p *pacer) Wait(ctx context.Context) {
+	started := time.Now()
+	for {
+		remaining := p.Next() - time.Since(started)
+		logger.Debugf("Waiting %s before next attempt", remaining.Round(time.Second))
+		p.mu.Lock()
+		retuned := p.retuned
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(remaining):
+			return
+		case <-retuned:
+		}
+	}
+}
+
+// SetInterval changes the wizard interval in seconds of a running task
+func (p *pacer) SetInterval(intervalMin, intervalMax int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.intervalMin = time.Duration(intervalMin) * time.Second
+	p.intervalMax = time.Duration(intervalMax) * time.Second
+	close(p.retuned)
+	p.retuned = make(chan struct{})
+}
+
+// Adaptive reports whether the wait follows auto_adaptive rather than the
+// wizard interval
+func (p *pacer) Adaptive() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.adaptive
+}
+
+// Summary formats the observed call rate for /status.
+func (p *pacer) Summary() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elapsed := time.Since(p.started)
+	perHour := 0.0
+	if elapsed > 0 {
+		perHour = float64(p.calls) / elapsed.Hours()
+	}
+
+	text := fmt.Sprintf("运行 %s，调用 %d 次 (%.1f 次/小时)，限流 %d 次",
+		elapsed.Round(time.Second), p.calls, perHour, p.throttled)
+	if !p.lastThrottle.IsZero() {
+		text += fmt.Sprintf("，最近限流 %s 前", time.Since(p.lastThrottle).Round(time.Second))
+	}
+	if p.adaptive {
+		text += fmt.Sprintf("\n当前间隔: %s (自适应 %s-%s)", p.current, p.floor, p.ceiling)
+	} else if p.intervalMax > p.intervalMin {
+		text += fmt.Sprintf("\n间隔: %s-%s", p.intervalMin, p.intervalMax)
+	} else {
+		text += fmt.Sprintf("\n间隔: %s", p.intervalMin)
+	}
+	return text
+}
+
+// showStatus shows the running auto tasks and their observed call rates.
+func (b *Bot) showStatus(chatID int64) {
+	b.mu.Lock()
+	autoApply := b.autoApply
+	autoVPS := b.autoVPS
+	var sb strings.Builder
+	sb.WriteString("📈 *自动任务状态*\n")
+	if autoApply != nil && autoApply.Active {
+		sb.WriteString(fmt.Sprintf("\n🔄 *自动刷IP* [%s]\n%s\n", autoApply.AccountName, autoApply.Pace.Summary()))
+		if n := len(autoApply.UncheckedIPs); n > 0 {
+			sb.WriteString(fmt.Sprintf("检测失败而保留: %d 个\n", n))
+		}
+		if stages := autoApply.Stages.summary(); stages != "" {
+			sb.WriteString("筛选: " + stages + "\n")
+		}
+		if autoApply.Stopping {
+			sb.WriteString("⏳ 本轮完成后停止\n")
+		}
+		if autoApply.Paused {
+			sb.WriteString("⏸ 已暂停，/resumeauto 继续\n")
+		}
+	}
+	if autoVPS != nil && autoVPS.Active {
+		sb.WriteString(fmt.Sprintf("\n🖥️ *自动申请VPS* [%s] %s\n%s\n", autoVPS.AccountName, strings.ToUpper(autoVPS.Arch), autoVPS.Pace.Summary()))
+	}
+	idle := (autoApply == nil || !autoApply.Active) && (autoVPS == nil || !autoVPS.Active)
+	if idle {
+		sb.WriteString("\n💤 当前没有运行中的自动任务\n")
+	}
+	if !ippure.BrowserAvailable() {
+		sb.WriteString("\n⚠️ 未找到 Chrome，纯净度检测不可用\n")
+	}
+	if running, queued := ippure.Queue(); queued > 0 {
+		sb.WriteString(fmt.Sprintf("\n🔍 纯净度检测: %d 个进行中，%d 个排队\n", running, queued))
+	}
+	if n := len(b.outbox); n > 0 {
+		sb.WriteString(fmt.Sprintf("\n📮 待重发的通知: %d 条\n", n))
+	}
+	b.mu.Unlock()
+	if addrs, entries := b.sortedWatchlist(); len(addrs) > 0 {
+		sb.WriteString("\n👁 *关注的外部IP*\n")
+		for _, addr := range addrs {
+			sb.WriteString(watchLine(addr, entries[addr]))
+		}
+	}
+	if schedules := b.autoIPScheduleSummary(); schedules != "" {
+		sb.WriteString("\n⏰ *定时刷IP*\n" + escapeMarkdown(schedules))
+	}
+
+	b.replyMarkdown(chatID, sb.String())
+}
@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"oci-bot/oci"
+	"oci-bot/state"
+)
+
+// pinnedKey is the state section holding pinned IP addresses
+const pinnedKey = "pinned_ips"
+
+// loadPinned reads the pinned IP set from the store
+func loadPinned(store *state.Store) (map[string]bool, error) {
+	var ips []string
+	if err := store.Get(pinnedKey, &ips); err != nil {
+		return nil, err
+	}
+	pinned := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		pinned[ip] = true
+	}
+	return pinned, nil
+}
+
+// isPinned reports whether an IP address is pinned
+func (b *Bot) isPinned(ipAddr string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pinned[ipAddr]
+}
+
+// withoutPinned filters pinned IPs out of a list about to be deleted
+func (b *Bot) withoutPinned(ips []oci.PublicIPInfo) []oci.PublicIPInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var result []oci.PublicIPInfo
+	for _, ip := range ips {
+		if !b.pinned[ip.IPAddress] {
+			result = append(result, ip)
+		}
+	}
+	return result
+}
+
+// pinIP pins an IP address, or lists the pinned ones when no address is given
+func (b *Bot) pinIP(chatID int64, ipAddr string) {
+	if ipAddr == "" {
+		b.showPinned(chatID)
+		return
+	}
+	if net.ParseIP(ipAddr) == nil {
+		b.reply(chatID, "❌ 无效的IP地址: "+ipAddr)
+		return
+	}
+
+	if err := b.setPinned(ipAddr, true); err != nil {
+		b.reply(chatID, "❌ 保存失败: "+err.Error())
+		return
+	}
+	b.reply(chatID, fmt.Sprintf("📌 已固定 %s\n/delip、批量删除和自动刷IP都不会删除它，使用 /unpin %s 取消", ipAddr, ipAddr))
+}
+
+// unpinIP removes an IP address from the pinned set
+func (b *Bot) unpinIP(chatID int64, ipAddr string) {
+	if ipAddr == "" {
+		b.reply(chatID, "用法: /unpin <IP>")
+		return
+	}
+	if !b.isPinned(ipAddr) {
+		b.reply(chatID, "⚠️ 未固定: "+ipAddr)
+		return
+	}
+
+	if err := b.setPinned(ipAddr, false); err != nil {
+		b.reply(chatID, "❌ 保存失败: "+err.Error())
+		return
+	}
+	b.reply(chatID, "✅ 已取消固定: "+ipAddr)
+}
+
+// setPinned updates the pinned set and persists it
+func (b *Bot) setPinned(ipAddr string, pin bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if pin {
+		b.pinned[ipAddr] = true
+	} else {
+		delete(b.pinned, ipAddr)
+	}
+
+	ips := make([]string, 0, len(b.pinned))
+	for ip := range b.pinned {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return b.store.Set(pinnedKey, ips)
+}
+
+// showPinned lists the pinned IP addresses
+func (b *Bot) showPinned(chatID int64) {
+	b.mu.Lock()
+	ips := make([]string, 0, len(b.pinned))
+	for ip := range b.pinned {
+		ips = append(ips, ip)
+	}
+	b.mu.Unlock()
+
+	if len(ips) == 0 {
+		b.reply(chatID, "📌 暂无固定的IP\n用法: /pin <IP>")
+		return
+	}
+
+	sort.Strings(ips)
+	var sb strings.Builder
+	sb.WriteString("📌 *固定的IP*\n\n")
+	for _, ip := range ips {
+		sb.WriteString(fmt.Sprintf("• `%s`\n", ip))
+	}
+	b.replyMarkdown(chatID, sb.String())
+}
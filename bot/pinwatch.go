@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"oci-bot/state"
+)
+
+// pinnedStateKey is the state section holding what the pinned IP monitor last saw
+const pinnedStateKey = "pinned_state"
+
+// pinnedSnapshot is the assignment of a pinned IP at the last check
+type pinnedSnapshot struct {
+	Account  string `json:"account"`
+	State    string `json:"state"`
+	Assigned string `json:"assigned,omitempty"` // Private IP OCID, "" when unattached
+}
+
+// pinnedEvent is posted to alert_webhook_url when a pinned IP changes
+type pinnedEvent struct {
+	Event  string          `json:"event"` // "pinned_ip_changed" / "pinned_ip_missing"
+	IP     string          `json:"ip"`
+	Before pinnedSnapshot  `json:"before"`
+	After  *pinnedSnapshot `json:"after,omitempty"` // nil when the IP disappeared
+	Time   time.Time       `json:"time"`
+}
+
+// loadPinnedState reads the last seen assignments of the pinned IPs
+func loadPinnedState(store *state.Store) (map[string]pinnedSnapshot, error) {
+	snapshots := make(map[string]pinnedSnapshot)
+	if err := store.Get(pinnedStateKey, &snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// runPinnedMonitor checks the pinned IPs at start and every
+// pinned_check_minutes
+func (b *Bot) runPinnedMonitor(ctx context.Context) {
+	interval := time.Duration(b.cfg.PinnedCheckMinutes) * time.Minute
+	logger.Infof("Pinned IP monitor started (every %s)", interval)
+
+	b.checkPinnedIPs(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.checkPinnedIPs(ctx)
+		}
+	}
+}
+
+// checkPinnedIPs lists the reserved IPs of every account and alerts about
+// pinned IPs whose state or assignment changed, or that are gone. Nothing is
+// reported missing while an account could not be listed.
+func (b *Bot) checkPinnedIPs(ctx context.Context) {
+	current := make(map[string]pinnedSnapshot)
+	complete := true
+	for _, client := range b.sortedClients() {
+		name := client.AccountName()
+		listCtx, cancel := context.WithTimeout(ctx, callTimeout)
+		ips, err := client.ListReservedIPs(listCtx)
+		cancel()
+		if err != nil {
+			logger.Warnf("[%s] Pinned IP check failed: %v", name, err)
+			complete = false
+			continue
+		}
+		for _, ip := range ips {
+			current[ip.IPAddress] = pinnedSnapshot{Account: name, State: ip.State, Assigned: ip.AssignedEntityID}
+		}
+	}
+
+	now := time.Now()
+	var events []pinnedEvent
+
+	b.mu.Lock()
+	for addr := range b.pinnedState {
+		if !b.pinned[addr] {
+			delete(b.pinnedState, addr)
+		}
+	}
+	for addr := range b.pinned {
+		before, seen := b.pinnedState[addr]
+		after, found := current[addr]
+		switch {
+		case found:
+			if seen && after != before {
+				events = append(events, pinnedEvent{Event: "pinned_ip_changed", IP: addr, Before: before, After: &after, Time: now})
+			}
+			b.pinnedState[addr] = after
+		case seen && complete:
+			events = append(events, pinnedEvent{Event: "pinned_ip_missing", IP: addr, Before: before, Time: now})
+			delete(b.pinnedState, addr) // Report once
+		}
+	}
+	if err := b.store.Set(pinnedStateKey, b.pinnedState); err != nil {
+		logger.Errorf("Failed to save pinned IP state: %v", err)
+	}
+	b.mu.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].IP < events[j].IP })
+
+	var sb strings.Builder
+	sb.WriteString("🚨 *固定IP状态变化*\n\n")
+	for _, event := range events {
+		logger.Warnf("Pinned IP %s: %s", event.IP, event.Event)
+		sb.WriteString(pinnedEventLine(event))
+		b.postAlertWebhook(ctx, event)
+	}
+	sb.WriteString("\n通常意味着账号被标记或实例被回收，请尽快检查")
+	b.alertMarkdown(sb.String())
+}
+
+// pinnedEventLine describes a pinned IP change on one line
+func pinnedEventLine(event pinnedEvent) string {
+	line := fmt.Sprintf("• `%s` [%s] ", event.IP, escapeMarkdown(event.Before.Account))
+	after := event.After
+	switch {
+	case after == nil:
+		return line + "已从预留IP列表中消失\n"
+	case after.Assigned == "" && event.Before.Assigned != "":
+		line += "已解绑"
+	case after.Assigned != "" && event.Before.Assigned == "":
+		line += "已绑定"
+	case after.Assigned != event.Before.Assigned:
+		line += "已绑定到其他私有IP"
+	}
+	if after.State != event.Before.State {
+		line += fmt.Sprintf(" 状态 %s → %s", event.Before.State, after.State)
+	}
+	if after.Account != event.Before.Account {
+		line += " 账号 → " + escapeMarkdown(after.Account)
+	}
+	return line + "\n"
+}
@@ -0,0 +1,180 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"oci-bot/ippure"
+	"oci-bot/oci"
+	"oci-bot/state"
+)
+
+// releasedKey is the state section holding IPs auto-apply released as mismatches
+const releasedKey = "released_ips"
+
+// checkStage is a stage of the auto-apply candidate pipeline. Stages run
+// cheapest first so most unwanted IPs are released before the slow browser
+// check.
+type checkStage int
+
+const (
+	stagePrefix  checkStage = iota // autoip_prefixes / autoip_avoid_prefixes
+	stageSeenBad                   // Released as a mismatch within known_bad_days
+	stageLookup                    // DNS blocklist and origin penalties
+	stageLatency                   // autoip_max_latency
+	stageRoute                     // autoip_routes
+	stagePurity                    // ippure browser check
+	stageVerify                    // auto_verify_seconds, second check of matching IPs
+	stageSpeed                     // autoip_speedtest, on matching IPs only
+	stageCount
+)
+
+var stageNames = [stageCount]string{"地址段", "已知不合格", "黑名单/ASN", "延迟", "线路", "纯净度检测", "复核", "测速"}
+
+// pipelineStats counts per stage how many candidates reached it and how many
+// it rejected
+type pipelineStats struct {
+	Checked  [stageCount]int
+	Rejected [stageCount]int
+}
+
+// summary describes the stages that saw candidates, e.g.
+// "地址段 拒绝 3/20 · 纯净度检测 拒绝 16/17"
+func (s pipelineStats) summary() string {
+	var parts []string
+	for stage := range stageCount {
+		if s.Checked[stage] > 0 {
+			parts = append(parts, fmt.Sprintf("%s 拒绝 %d/%d", stageNames[stage], s.Rejected[stage], s.Checked[stage]))
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// releasedIP is the check result of an IP auto-apply released as a mismatch
+type releasedIP struct {
+	PurityScore string    `json:"purity_score"`
+	IPType      string    `json:"ip_type,omitempty"`
+	IsNative    string    `json:"is_native,omitempty"`
+	Score       int       `json:"score"` // Composite score, see ipScore
+	Released    time.Time `json:"released"`
+}
+
+// loadReleased reads the released IPs from the store
+func loadReleased(store *state.Store) (map[string]releasedIP, error) {
+	released := make(map[string]releasedIP)
+	if err := store.Get(releasedKey, &released); err != nil {
+		return nil, err
+	}
+	return released, nil
+}
+
+// knownBadTTL is how long released IPs are remembered, 0 when disabled
+func (b *Bot) knownBadTTL() time.Duration {
+	return time.Duration(b.cfg.KnownBadDays) * 24 * time.Hour
+}
+
+// recordStage counts a candidate at a stage of the config's pipeline
+func (b *Bot) recordStage(config *AutoApplyConfig, stage checkStage, passed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	config.Stages.Checked[stage]++
+	if !passed {
+		config.Stages.Rejected[stage]++
+	}
+}
+
+// screenCandidate runs the stages before the browser check on a new IP.
+// Returns false when a stage rejected it and it should be released; otherwise
+// the penalties to add to its purity score.
+func (b *Bot) screenCandidate(ctx context.Context, config *AutoApplyConfig, publicIP *oci.PublicIPInfo) (ipScore, bool) {
+	ipAddr := publicIP.IPAddress
+
+	ok := b.prefixWanted(config, publicIP)
+	b.recordStage(config, stagePrefix, ok)
+	if !ok {
+		return ipScore{}, false
+	}
+
+	b.mu.Lock()
+	seen, wasReleased := b.released[ipAddr]
+	b.mu.Unlock()
+	if wasReleased && time.Since(seen.Released) < b.knownBadTTL() {
+		// Judged again, the criteria may have changed since. Records of
+		// older versions may hold the page's English wording.
+		info := &ippure.IPInfo{IPAddress: ipAddr, PurityScore: seen.PurityScore,
+			IPType: ippure.NormalizeType(seen.IPType), IsNative: ippure.NormalizeOrigin(seen.IsNative)}
+		ok = b.checkIPMatch(info, ipScore{Value: seen.Score}, config)
+	}
+	b.recordStage(config, stageSeenBad, ok)
+	if !ok {
+		logger.Infof("IP %s was released %s ago (score %d, %s). Deleting without a purity check...",
+			ipAddr, time.Since(seen.Released).Round(time.Minute), seen.Score, seen.IsNative)
+		return ipScore{}, false
+	}
+
+	var penalties ipScore
+	if b.cfg.CompositeScoring() {
+		penalties = b.lookupPenalties(ctx, ipAddr)
+		// Purity is at least 0, so with all criteria required penalties above
+		// the threshold cannot match whatever the browser check says
+		b.mu.Lock()
+		ok = config.MatchMode != "all" || penalties.Value <= config.PurityThreshold
+		b.mu.Unlock()
+		b.recordStage(config, stageLookup, ok)
+		if !ok {
+			logger.Infof("IP %s penalties %s exceed the threshold. Deleting without a purity check...", ipAddr, penalties)
+			return ipScore{}, false
+		}
+	}
+
+	account := b.accountConfig(config.AccountName)
+	if account != nil && account.AutoIPMaxLatency > 0 {
+		penalties.Latency = b.measureLatency(ctx, ipAddr)
+		ok, reason := latencyWanted(account, penalties.Latency)
+		b.recordStage(config, stageLatency, ok)
+		if !ok {
+			logger.Infof("IP %s is too slow (%s). Deleting without a purity check...", ipAddr, reason)
+			return ipScore{}, false
+		}
+	}
+	if account != nil && len(account.AutoIPRoutes) > 0 {
+		penalties.Routes = b.detectRoutes(ctx, ipAddr)
+		ok, reason := routesWanted(account, penalties.Routes)
+		b.recordStage(config, stageRoute, ok)
+		if !ok {
+			logger.Infof("IP %s takes an unwanted route (%s). Deleting without a purity check...", ipAddr, reason)
+			return ipScore{}, false
+		}
+	}
+	return penalties, true
+}
+
+// rememberReleased records the check result of an IP released as a mismatch,
+// so the same address handed out again within known_bad_days is rejected
+// before the browser check. Expired entries are dropped on the way.
+func (b *Bot) rememberReleased(ipAddr string, info *ippure.IPInfo, score ipScore) {
+	ttl := b.knownBadTTL()
+	if ttl <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for addr, r := range b.released {
+		if time.Since(r.Released) >= ttl {
+			delete(b.released, addr)
+		}
+	}
+	b.released[ipAddr] = releasedIP{
+		PurityScore: info.PurityScore,
+		IPType:      info.IPType,
+		IsNative:    info.IsNative,
+		Score:       score.Value,
+		Released:    time.Now(),
+	}
+	if err := b.store.Set(releasedKey, b.released); err != nil {
+		logger.Errorf("Failed to save released IPs: %v", err)
+	}
+}
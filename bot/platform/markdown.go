@@ -0,0 +1,89 @@
+package platform
+
+import "strings"
+
+// The bot writes messages in Telegram's legacy Markdown (*bold*, _italic_,
+// `code`, ```pre```, [text](url)); transports parse it to render messages in
+// their platform's format.
+
+// MarkdownSpecial are the characters legacy Markdown treats as markup
+const MarkdownSpecial = "_*`["
+
+// Kind is the formatting of a legacy Markdown segment
+type Kind int
+
+const (
+	Text Kind = iota
+	Bold
+	Italic
+	Code
+	Pre
+	Link
+)
+
+// Segment is a run of text with a single formatting
+type Segment struct {
+	Kind Kind
+	Text string
+	URL  string // Link only
+}
+
+// ParseMarkdown splits legacy Markdown into segments. Unterminated markup is
+// kept as literal text, so the result always renders.
+func ParseMarkdown(text string) []Segment {
+	var segments []Segment
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			segments = append(segments, Segment{Kind: Text, Text: plain.String()})
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte(MarkdownSpecial, text[i+1]) >= 0:
+			plain.WriteByte(text[i+1])
+			i += 2
+			continue
+		case strings.HasPrefix(text[i:], "```"):
+			if end := strings.Index(text[i+3:], "```"); end >= 0 {
+				body := text[i+3 : i+3+end]
+				// Drop the language name on the opening line
+				if nl := strings.IndexByte(body, '\n'); nl >= 0 && !strings.ContainsAny(body[:nl], " \t") {
+					body = body[nl+1:]
+				}
+				flush()
+				segments = append(segments, Segment{Kind: Pre, Text: body})
+				i += 3 + end + 3
+				continue
+			}
+		case c == '`' || c == '*' || c == '_':
+			if end := strings.IndexByte(text[i+1:], c); end >= 0 {
+				kind := map[byte]Kind{'`': Code, '*': Bold, '_': Italic}[c]
+				flush()
+				segments = append(segments, Segment{Kind: kind, Text: text[i+1 : i+1+end]})
+				i += 1 + end + 1
+				continue
+			}
+		case c == '[':
+			if close := strings.Index(text[i:], "]("); close >= 0 {
+				if end := strings.IndexByte(text[i+close+2:], ')'); end >= 0 {
+					flush()
+					segments = append(segments, Segment{
+						Kind: Link,
+						Text: text[i+1 : i+close],
+						URL:  text[i+close+2 : i+close+2+end],
+					})
+					i += close + 2 + end + 1
+					continue
+				}
+			}
+		}
+		plain.WriteByte(c)
+		i++
+	}
+	flush()
+	return segments
+}
@@ -0,0 +1,104 @@
+// Package platform holds what the transports for chat platforms other than
+// Telegram share. The bot speaks the Telegram Bot API; a transport turns its
+// calls into the platform's messages and the platform's events into Telegram
+// updates, which the bot reads as if from getUpdates.
+package platform
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Queue holds incoming updates until the bot asks for them
+type Queue struct {
+	updates chan tgbotapi.Update
+
+	mu     sync.Mutex
+	lastID int
+}
+
+// NewQueue returns an empty queue
+func NewQueue() *Queue {
+	return &Queue{updates: make(chan tgbotapi.Update, 100)}
+}
+
+// Push queues an update with the next update ID
+func (q *Queue) Push(update tgbotapi.Update) {
+	q.mu.Lock()
+	q.lastID++
+	update.UpdateID = q.lastID
+	q.mu.Unlock()
+	q.updates <- update
+}
+
+// Get returns the queued updates, waiting up to config.Timeout seconds for
+// one like Telegram's long polling
+func (q *Queue) Get(config tgbotapi.UpdateConfig) ([]tgbotapi.Update, error) {
+	var updates []tgbotapi.Update
+	select {
+	case update := <-q.updates:
+		updates = append(updates, update)
+	case <-time.After(time.Duration(config.Timeout) * time.Second):
+		return nil, nil
+	}
+	for {
+		select {
+		case update := <-q.updates:
+			updates = append(updates, update)
+		default:
+			return updates, nil
+		}
+	}
+}
+
+// TextMessage returns a message with text, marking a leading /command the way
+// Telegram does so Message.Command works
+func TextMessage(chatID, fromID int64, userName, text string) *tgbotapi.Message {
+	msg := &tgbotapi.Message{
+		From: &tgbotapi.User{ID: fromID, UserName: userName, FirstName: userName},
+		Chat: &tgbotapi.Chat{ID: chatID},
+		Date: int(time.Now().Unix()),
+		Text: text,
+	}
+	if strings.HasPrefix(text, "/") {
+		end := strings.IndexAny(text, " \n")
+		if end < 0 {
+			end = len(text)
+		}
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: end}}
+	}
+	return msg
+}
+
+// BadRequest wraps err as Telegram's HTTP 400, for a message the platform
+// refused, so the bot doesn't retry it
+func BadRequest(err error) error {
+	return &tgbotapi.Error{Code: http.StatusBadRequest, Message: err.Error()}
+}
+
+// Buttons flattens a keyboard into its buttons, row by row
+func Buttons(markup *tgbotapi.InlineKeyboardMarkup) []tgbotapi.InlineKeyboardButton {
+	if markup == nil {
+		return nil
+	}
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, row := range markup.InlineKeyboard {
+		buttons = append(buttons, row...)
+	}
+	return buttons
+}
+
+// Keyboard returns the inline keyboard of a reply_markup, nil for none
+func Keyboard(markup interface{}) *tgbotapi.InlineKeyboardMarkup {
+	switch m := markup.(type) {
+	case tgbotapi.InlineKeyboardMarkup:
+		return &m
+	case *tgbotapi.InlineKeyboardMarkup:
+		return m
+	}
+	return nil
+}
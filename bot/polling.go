@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Long polling. getUpdates holds the request open for up to pollTimeout
+// seconds, so a call still pending well after that sits on a dead connection:
+// it is abandoned and a fresh one made. Failures are retried with backoff.
+const (
+	pollTimeout      = 60
+	pollStallAfter   = pollTimeout*time.Second + 30*time.Second
+	pollRetryFirst   = 3 * time.Second
+	pollRetryMax     = time.Minute
+	pollOutageNotify = time.Minute // Shorter outages are only logged
+)
+
+var errPollStalled = errors.New("getUpdates didn't return in time")
+
+type pollResult struct {
+	updates []tgbotapi.Update
+	err     error
+}
+
+// startPolling polls Telegram for updates until ctx is done. Unlike the
+// client library's GetUpdatesChan, which can't be restarted once stuck, it
+// notices a hung or failing connection, re-establishes it and tells the admin
+// once updates arrive again.
+func (b *Bot) startPolling(ctx context.Context) tgbotapi.UpdatesChannel {
+	updates := make(chan tgbotapi.Update, 100)
+	offset := 0 // Kept across restarts after a panic
+	go b.supervise(ctx, "update polling", func(ctx context.Context) { b.pollUpdates(ctx, updates, &offset) })
+	return updates
+}
+
+// pollUpdates runs getUpdates in a loop, passing new updates on. offset is
+// the next update to ask for.
+func (b *Bot) pollUpdates(ctx context.Context, updates chan<- tgbotapi.Update, offset *int) {
+	config := tgbotapi.NewUpdate(*offset)
+	config.Timeout = pollTimeout
+
+	var outageSince time.Time
+	var outageErr error
+	delay := pollRetryFirst
+	for {
+		// The request can't be cancelled, so it runs aside. An abandoned one
+		// ends when Telegram terminates it for the newer request; the updates
+		// it may still return are delivered again, as the offset wasn't
+		// advanced.
+		result := make(chan pollResult, 1)
+		go func(config tgbotapi.UpdateConfig) {
+			got, err := b.api.GetUpdates(config)
+			result <- pollResult{got, err}
+		}(config)
+
+		var r pollResult
+		select {
+		case <-ctx.Done():
+			return
+		case r = <-result:
+		case <-time.After(pollStallAfter):
+			r.err = errPollStalled
+		}
+
+		if r.err != nil {
+			if outageSince.IsZero() {
+				outageSince = time.Now()
+			}
+			outageErr = r.err
+			logger.Warnf("Failed to get updates, retrying in %s: %v", delay, r.err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, pollRetryMax)
+			continue
+		}
+
+		if !outageSince.IsZero() {
+			b.pollRecovered(time.Since(outageSince), outageErr)
+			outageSince, outageErr = time.Time{}, nil
+			delay = pollRetryFirst
+		}
+		for _, update := range r.updates {
+			if update.UpdateID < config.Offset {
+				continue
+			}
+			config.Offset = update.UpdateID + 1
+			*offset = config.Offset
+			select {
+			case updates <- update:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// pollRecovered reports getUpdates working again after failing for outage
+func (b *Bot) pollRecovered(outage time.Duration, lastErr error) {
+	logger.Infof("Receiving updates again after %s", outage.Round(time.Second))
+	if outage < pollOutageNotify {
+		return
+	}
+	b.alert(fmt.Sprintf("🔌 Telegram 连接中断 %s 后已恢复\n最后错误: %v\n\n中断期间发送的命令会在此时执行",
+		outage.Round(time.Second), lastErr))
+}
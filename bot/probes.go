@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Liveness and readiness endpoints for process supervisors. The checks run in
+// the background and the handlers only report their last results, so a probe
+// answers at once however slow Telegram or OCI are.
+const (
+	heartbeatInterval  = 30 * time.Second
+	heartbeatStale     = 3 * heartbeatInterval // /healthz fails once the update loop missed this long
+	telegramProbeEvery = time.Minute
+	ociProbeEvery      = 5 * time.Minute
+)
+
+// probeState is what /healthz and /readyz report
+type probeState struct {
+	mu            sync.Mutex
+	heartbeat     time.Time // Last turn of the update loop
+	lastUpdate    time.Time // Last update received from Telegram
+	telegramCheck time.Time
+	telegramErr   error
+	ociCheck      time.Time
+	ociHealthy    int
+	ociTotal      int
+}
+
+// beat records a turn of the update loop
+func (p *probeState) beat() {
+	p.mu.Lock()
+	p.heartbeat = time.Now()
+	p.mu.Unlock()
+}
+
+// received records an update from Telegram
+func (p *probeState) received() {
+	p.mu.Lock()
+	now := time.Now()
+	p.heartbeat, p.lastUpdate = now, now
+	p.mu.Unlock()
+}
+
+// readiness is the /readyz body
+type readiness struct {
+	Ready    bool `json:"ready"`
+	Telegram struct {
+		OK      bool      `json:"ok"`
+		Error   string    `json:"error,omitempty"`
+		Checked time.Time `json:"checked"`
+	} `json:"telegram"`
+	OCI struct {
+		Healthy int       `json:"healthy"`
+		Total   int       `json:"total"`
+		Checked time.Time `json:"checked"`
+	} `json:"oci"`
+	LastUpdate    *time.Time `json:"last_update,omitempty"`
+	LastUpdateAge int        `json:"last_update_age_seconds,omitempty"`
+}
+
+// startProbes serves /healthz and /readyz on health_listen
+func (b *Bot) startProbes(ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", b.serveHealthz)
+	mux.HandleFunc("/readyz", b.serveReadyz)
+	server := &http.Server{
+		Addr:              b.cfg.HealthListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		logger.Infof("Health endpoints listening on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("Health server error: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	go b.supervise(ctx, "health probes", b.runProbes)
+}
+
+// runProbes checks Telegram and the OCI clients periodically
+func (b *Bot) runProbes(ctx context.Context) {
+	telegram := time.NewTicker(telegramProbeEvery)
+	defer telegram.Stop()
+	ociTicker := time.NewTicker(ociProbeEvery)
+	defer ociTicker.Stop()
+
+	b.probeTelegram()
+	b.probeOCI(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-telegram.C:
+			b.probeTelegram()
+		case <-ociTicker.C:
+			b.probeOCI(ctx)
+		}
+	}
+}
+
+// probeTelegram calls getMe
+func (b *Bot) probeTelegram() {
+	_, err := b.api.MakeRequest("getMe", nil)
+	err = withoutURL(err)
+	if err != nil {
+		logger.Debugf("Telegram probe failed: %v", err)
+	}
+	b.probes.mu.Lock()
+	b.probes.telegramCheck, b.probes.telegramErr = time.Now(), err
+	b.probes.mu.Unlock()
+}
+
+// probeOCI makes a cheap call with every loaded client
+func (b *Bot) probeOCI(ctx context.Context) {
+	clients := b.sortedClients()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	healthy := 0
+	for _, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			callCtx, cancel := context.WithTimeout(ctx, callTimeout)
+			defer cancel()
+			if _, err := client.GetUserInfo(callCtx); err != nil {
+				logger.Debugf("[%s] OCI probe failed: %v", client.AccountName(), err)
+				return
+			}
+			mu.Lock()
+			healthy++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	b.probes.mu.Lock()
+	b.probes.ociCheck, b.probes.ociHealthy, b.probes.ociTotal = time.Now(), healthy, len(clients)
+	b.probes.mu.Unlock()
+}
+
+// serveHealthz answers 200 while the update loop turns, 503 when it is stuck
+func (b *Bot) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	b.probes.mu.Lock()
+	heartbeat := b.probes.heartbeat
+	b.probes.mu.Unlock()
+
+	if time.Since(heartbeat) > heartbeatStale {
+		http.Error(w, "update loop stalled since "+heartbeat.Format(time.RFC3339), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// serveReadyz answers 200 when Telegram is reachable and at least one OCI
+// account works, 503 otherwise, with the details as JSON
+func (b *Bot) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	var body readiness
+	b.probes.mu.Lock()
+	body.Telegram.OK = !b.probes.telegramCheck.IsZero() && b.probes.telegramErr == nil
+	if b.probes.telegramErr != nil {
+		body.Telegram.Error = b.probes.telegramErr.Error()
+	}
+	body.Telegram.Checked = b.probes.telegramCheck
+	body.OCI.Healthy, body.OCI.Total, body.OCI.Checked = b.probes.ociHealthy, b.probes.ociTotal, b.probes.ociCheck
+	if lastUpdate := b.probes.lastUpdate; !lastUpdate.IsZero() {
+		body.LastUpdate = &lastUpdate
+		body.LastUpdateAge = int(time.Since(lastUpdate).Seconds())
+	}
+	b.probes.mu.Unlock()
+	body.Ready = body.Telegram.OK && body.OCI.Healthy > 0
+
+	w.Header().Set("Content-Type", "application/json")
+	if !body.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,245 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"oci-bot/state"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	profilesKey       = "autoip_profiles" // State section holding saved auto-apply profiles
+	maxProfileNameLen = 32                // Keeps callback data under Telegram's 64 bytes
+)
+
+// autoIPProfile is a named set of auto-apply criteria. The account is not
+// part of it, so one profile can be reused on every account.
+type autoIPProfile struct {
+	PurityThreshold int    `json:"purity_threshold"`
+	NativeRequired  string `json:"native_required"`
+	TypeRequired    string `json:"type_required,omitempty"` // Empty in profiles saved before the type criterion
+	MatchMode       string `json:"match_mode"`
+	IntervalMin     int    `json:"interval_min"`
+	IntervalMax     int    `json:"interval_max"`
+}
+
+// summary describes the profile on one line
+func (p autoIPProfile) summary() string {
+	purity := fmt.Sprintf("纯净度<=%d%%", p.PurityThreshold)
+	if p.PurityThreshold >= 100 {
+		purity = "纯净度不限"
+	}
+	native := p.NativeRequired
+	if native == "any" {
+		native = "来源不限"
+	}
+	ipType := p.typeRequired()
+	if ipType == "any" {
+		ipType = "类型不限"
+	}
+	mode := "满足全部"
+	if p.MatchMode == "any" {
+		mode = "满足任一"
+	}
+	interval := fmt.Sprintf("%d秒", p.IntervalMin)
+	if p.IntervalMin != p.IntervalMax {
+		interval = fmt.Sprintf("%d-%d秒", p.IntervalMin, p.IntervalMax)
+	}
+	return strings.Join([]string{purity, native, ipType, mode, interval}, " · ")
+}
+
+// typeRequired returns the IP type criterion, "any" for older profiles
+func (p autoIPProfile) typeRequired() string {
+	if p.TypeRequired == "" {
+		return "any"
+	}
+	return p.TypeRequired
+}
+
+// profileOf returns the criteria of an auto-apply task as a profile
+func profileOf(config *AutoApplyConfig) autoIPProfile {
+	return autoIPProfile{
+		PurityThreshold: config.PurityThreshold,
+		NativeRequired:  config.NativeRequired,
+		TypeRequired:    config.TypeRequired,
+		MatchMode:       config.MatchMode,
+		IntervalMin:     config.IntervalMin,
+		IntervalMax:     config.IntervalMax,
+	}
+}
+
+// task returns an auto-apply task on account with the profile's criteria
+func (p autoIPProfile) task(account string) *AutoApplyConfig {
+	return &AutoApplyConfig{
+		AccountName:     account,
+		PurityThreshold: p.PurityThreshold,
+		NativeRequired:  p.NativeRequired,
+		TypeRequired:    p.typeRequired(),
+		MatchMode:       p.MatchMode,
+		IntervalMin:     p.IntervalMin,
+		IntervalMax:     p.IntervalMax,
+	}
+}
+
+// loadProfiles reads saved auto-apply profiles from the store
+func loadProfiles(store *state.Store) (map[string]autoIPProfile, error) {
+	profiles := make(map[string]autoIPProfile)
+	if err := store.Get(profilesKey, &profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// validProfileName reports whether a name fits in callback data
+func validProfileName(name string) bool {
+	return name != "" && len(name) <= maxProfileNameLen && !strings.ContainsAny(name, ": \t\n")
+}
+
+// handleProfiles handles /profiles [save|del <name>]
+func (b *Bot) handleProfiles(chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		b.showProfiles(chatID)
+		return
+	}
+	if len(fields) != 2 {
+		b.reply(chatID, "用法: /profiles [save|del <名称>]")
+		return
+	}
+
+	switch name := fields[1]; fields[0] {
+	case "save":
+		b.saveProfile(chatID, name)
+	case "del":
+		b.deleteProfile(chatID, name)
+	default:
+		b.reply(chatID, "用法: /profiles [save|del <名称>]")
+	}
+}
+
+// saveProfile saves the criteria of the current /autoip configuration
+func (b *Bot) saveProfile(chatID int64, name string) {
+	if !validProfileName(name) {
+		b.reply(chatID, fmt.Sprintf("❌ 方案名不能包含空格或冒号，且不超过 %d 字节", maxProfileNameLen))
+		return
+	}
+
+	b.mu.Lock()
+	config := b.autoApply
+	if config == nil {
+		b.mu.Unlock()
+		b.reply(chatID, "⚠️ 没有可保存的配置，请先使用 /autoip 完成配置")
+		return
+	}
+	profile := profileOf(config)
+	_, replaced := b.profiles[name]
+	b.profiles[name] = profile
+	err := b.store.Set(profilesKey, b.profiles)
+	b.mu.Unlock()
+
+	if err != nil {
+		b.reply(chatID, "❌ 保存失败: "+err.Error())
+		return
+	}
+	verb := "已保存"
+	if replaced {
+		verb = "已更新"
+	}
+	b.reply(chatID, fmt.Sprintf("💾 %s方案 %s\n%s\n\n下次 /autoip 时可直接选择", verb, name, profile.summary()))
+}
+
+// deleteProfile removes a saved profile
+func (b *Bot) deleteProfile(chatID int64, name string) {
+	b.mu.Lock()
+	_, ok := b.profiles[name]
+	var err error
+	if ok {
+		delete(b.profiles, name)
+		err = b.store.Set(profilesKey, b.profiles)
+	}
+	b.mu.Unlock()
+
+	switch {
+	case !ok:
+		b.reply(chatID, "⚠️ 方案不存在: "+name)
+	case err != nil:
+		b.reply(chatID, "❌ 保存失败: "+err.Error())
+	default:
+		b.reply(chatID, "🗑 已删除方案: "+name)
+	}
+}
+
+// sortedProfileNames returns the saved profile names in order
+func (b *Bot) sortedProfileNames() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names := make([]string, 0, len(b.profiles))
+	for name := range b.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// showProfiles lists the saved profiles with delete buttons
+func (b *Bot) showProfiles(chatID int64) {
+	names := b.sortedProfileNames()
+	if len(names) == 0 {
+		b.reply(chatID, "📋 暂无保存的方案\n完成 /autoip 配置后使用 /profiles save <名称> 保存")
+		return
+	}
+
+	var sb strings.Builder
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	sb.WriteString("📋 *自动刷IP方案*\n\n")
+	b.mu.Lock()
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("*%s*\n%s\n\n", escapeMarkdown(name), b.profiles[name].summary()))
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData("🗑 删除 "+name, "prof:del:"+name),
+		})
+	}
+	b.mu.Unlock()
+	sb.WriteString("保存当前配置: /profiles save <名称>")
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
+
+// handleProfileCallback handles the profile list buttons
+func (b *Bot) handleProfileCallback(chatID int64, action string, parts []string) {
+	if action == "del" && len(parts) >= 3 {
+		b.deleteProfile(chatID, parts[2])
+	}
+}
+
+// showProfileStep offers saved profiles before the account selection (Step 0)
+func (b *Bot) showProfileStep(chatID int64) {
+	var sb strings.Builder
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	sb.WriteString("🔄 *自动刷IP配置*\n\n选择已保存的方案，或手动配置:\n\n")
+	names := b.sortedProfileNames()
+	b.mu.Lock()
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("📋 *%s*: %s\n", escapeMarkdown(name), b.profiles[name].summary()))
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData("📋 "+name, "autoip:profile:"+name),
+		})
+	}
+	b.mu.Unlock()
+	buttons = append(buttons,
+		[]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("✏️ 手动配置", "autoip:manual:")},
+		[]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "autoip:cancel:")},
+	)
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
@@ -0,0 +1,352 @@
+package bot
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"oci-bot/config"
+	"oci-bot/oci"
+	"oci-bot/sshexec"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"rsc.io/qr"
+)
+
+// provisionTailLines is how much output of a failed recipe is shown
+const provisionTailLines = 15
+
+// recipeParams are the values recipe script and link templates can use, e.g.
+// {{.UUID}}. Secrets are generated for each instance.
+type recipeParams struct {
+	Name       string // Instance display name, empty in cloud-init scripts
+	IP         string // Public IP, empty in cloud-init scripts, which run before it is known
+	UUID       string // For VLESS / VMess
+	Password   string // For Trojan / Shadowsocks / Hysteria2
+	Port       int    // Random port in 20000-59999
+	ShortID    string // REALITY short ID
+	PrivateKey string // REALITY X25519 key pair, base64url without padding as xray prints them
+	PublicKey  string
+}
+
+// MarshalJSON seals the params like the key files when the config is
+// encrypted, so the secrets of a job checkpoint don't reach the state file in
+// plain text. They are then stored as a string.
+func (p recipeParams) MarshalJSON() ([]byte, error) {
+	type plain recipeParams
+	raw, err := json.Marshal(plain(p))
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := config.SealSecret(raw)
+	if err != nil || !config.IsEncrypted(sealed) {
+		return sealed, err
+	}
+	return json.Marshal(string(sealed))
+}
+
+// UnmarshalJSON reads params written by MarshalJSON, sealed or not
+func (p *recipeParams) UnmarshalJSON(data []byte) error {
+	type plain recipeParams
+	var sealed string
+	if json.Unmarshal(data, &sealed) == nil {
+		raw, err := config.OpenSecret([]byte(sealed))
+		if err != nil {
+			return err
+		}
+		data = raw
+	}
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+// newRecipeParams generates fresh secrets
+func newRecipeParams() (recipeParams, error) {
+	var raw [36]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return recipeParams{}, err
+	}
+	uuid := raw[:16]
+	uuid[6] = uuid[6]&0x0f | 0x40 // Version 4
+	uuid[8] = uuid[8]&0x3f | 0x80 // RFC 4122 variant
+	port, err := rand.Int(rand.Reader, big.NewInt(40000))
+	if err != nil {
+		return recipeParams{}, err
+	}
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return recipeParams{}, err
+	}
+	return recipeParams{
+		UUID:       fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16]),
+		Password:   hex.EncodeToString(raw[16:32]),
+		Port:       20000 + int(port.Int64()),
+		ShortID:    hex.EncodeToString(raw[32:36]),
+		PrivateKey: base64.RawURLEncoding.EncodeToString(key.Bytes()),
+		PublicKey:  base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+	}, nil
+}
+
+// provisioning is a recipe being applied to one instance
+type provisioning struct {
+	Recipe   *config.Recipe
+	Params   recipeParams
+	UserData string // Rendered script of a cloud-init recipe
+	script   *template.Template
+	link     *template.Template // nil without recipe_<name>_link
+}
+
+// newProvisioning reads and parses a recipe's templates, so mistakes show up
+// before an instance is launched
+func newProvisioning(recipe *config.Recipe) (*provisioning, error) {
+	params, err := newRecipeParams()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(recipe.Script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipe script: %w", err)
+	}
+	p := &provisioning{Recipe: recipe, Params: params}
+	if p.script, err = template.New(recipe.Name).Parse(string(content)); err != nil {
+		return nil, err
+	}
+	if recipe.Link != "" {
+		if p.link, err = template.New(recipe.Name + "_link").Parse(recipe.Link); err != nil {
+			return nil, err
+		}
+	}
+	// Rendering now catches unknown fields; later renders only add the name
+	// and IP
+	script, err := p.render(p.script)
+	if err != nil {
+		return nil, err
+	}
+	if recipe.CloudInit {
+		p.UserData = script
+	}
+	if p.link != nil {
+		if _, err := p.render(p.link); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// render fills a template with the instance's parameters
+func (p *provisioning) render(tmpl *template.Template) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, p.Params); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// accountProvisioning prepares the vps_recipe of the account, nil when it has
+// none
+func (b *Bot) accountProvisioning(account *config.OCIAccount) (*provisioning, error) {
+	if account.VPSRecipe == "" {
+		return nil, nil
+	}
+	recipe := b.cfg.Recipes[account.VPSRecipe]
+	if recipe == nil {
+		return nil, fmt.Errorf("recipe %s is not defined", account.VPSRecipe)
+	}
+	return newProvisioning(recipe)
+}
+
+// savedProvisioning prepares a recipe with the secrets generated when a job
+// started, so a resumed job provisions what it launched with
+func (b *Bot) savedProvisioning(name string, params *recipeParams) (*provisioning, error) {
+	recipe := b.cfg.Recipes[name]
+	if recipe == nil {
+		return nil, fmt.Errorf("recipe %s is not defined", name)
+	}
+	p, err := newProvisioning(recipe)
+	if err != nil {
+		return nil, err
+	}
+	p.Params = *params
+	if recipe.CloudInit {
+		if p.UserData, err = p.render(p.script); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// provision applies a recipe to an instance whose sshd answers: an SSH recipe
+// runs its script as root, for a cloud-init one the bot waits for cloud-init
+// when it can log in. Then the share link is sent, with a QR code for mobile
+// clients. A failed recipe is returned rather than reported, so a workflow
+// step applying it stops and can be retried.
+func (b *Bot) provision(chatID int64, t topic, client oci.Service, instanceID string, conn *connection, p *provisioning) error {
+	p.Params.Name, p.Params.IP = conn.Name, conn.IP
+	recipe := p.Recipe.Name
+	account := b.accountConfig(client.AccountName())
+	canLogin := account != nil && account.VPSSSHPrivateKey != ""
+
+	var result *sshexec.Result
+	if !p.Recipe.CloudInit || canLogin {
+		if !canLogin {
+			return fmt.Errorf("[%s] 部署 %s 失败: 未配置 vps_ssh_private_key", client.AccountName(), recipe)
+		}
+		signer, err := sshexec.LoadSigner(account.VPSSSHPrivateKey, account.VPSSSHKeyPassphrase)
+		if err != nil {
+			return fmt.Errorf("[%s] 部署 %s 失败: %w", client.AccountName(), recipe, err)
+		}
+
+		ctx, cancel := b.withTimeout(provisionTimeout)
+		defer cancel()
+		if p.Recipe.CloudInit {
+			b.status(chatID, t, fmt.Sprintf("⏳ %s 等待 cloud-init 部署 %s...", conn.Name, recipe))
+			result, err = sshexec.Run(ctx, conn.IP, conn.User, signer, b.hostKeyCallback(instanceID), "cloud-init status --wait")
+		} else {
+			var script string
+			if script, err = p.render(p.script); err == nil {
+				b.status(chatID, t, fmt.Sprintf("⏳ %s 正在部署 %s...", conn.Name, recipe))
+				logger.Infof("Running recipe %s on %s (%s)", recipe, conn.Name, conn.IP)
+				result, err = sshexec.RunScript(ctx, conn.IP, conn.User, signer, b.hostKeyCallback(instanceID), script)
+			}
+		}
+		if err != nil {
+			logger.Errorf("Recipe %s on %s failed: %v", recipe, conn.Name, err)
+			return fmt.Errorf("%s 部署 %s 失败: %w", conn.Name, recipe, err)
+		}
+		if result.ExitCode != 0 {
+			logger.Errorf("Recipe %s on %s exited with %d", recipe, conn.Name, result.ExitCode)
+			return fmt.Errorf("%s 部署 %s 失败，退出码 %d\n\n%s", conn.Name, recipe, result.ExitCode, lastLines(result.Output, provisionTailLines))
+		}
+	}
+
+	// The recipe is done: what follows only reports it, so it isn't run again
+	// over a failed share link
+	if p.link == nil {
+		b.notify(chatID, t, fmt.Sprintf("✅ %s 已部署 %s", conn.Name, recipe))
+		return nil
+	}
+	link, err := p.render(p.link)
+	if err != nil {
+		b.notify(chatID, t, fmt.Sprintf("❌ %s 已部署 %s，但生成分享链接失败: %v", conn.Name, recipe, err))
+		return nil
+	}
+	link = strings.TrimSpace(link)
+	caption := fmt.Sprintf("✅ %s 已部署 %s\n\n%s", conn.Name, recipe, link)
+	if p.Recipe.CloudInit && !canLogin {
+		caption = fmt.Sprintf("🔗 %s 的 %s 由 cloud-init 部署，可能需要再等几分钟才能连接\n\n%s", conn.Name, recipe, link)
+	}
+	b.notify(chatID, t, caption)
+
+	// Photos can't be sent into a forum topic with this client library, so
+	// the QR code is only added outside forums
+	if b.threadFor(chatID, t) != 0 {
+		return nil
+	}
+	code, err := qr.Encode(link, qr.M)
+	if err != nil {
+		logger.Warnf("Failed to encode share link as QR code: %v", err)
+		return nil
+	}
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: conn.Name + "-link.png", Bytes: code.PNG()})
+	photo.Caption = conn.Name + " " + recipe
+	if _, err := b.sendFile(chatID, photo); err != nil {
+		logger.Errorf("Failed to send share link QR code: %v", err)
+	}
+	return nil
+}
+
+// lastLines returns the last n lines of s
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// handleProvision handles /provision <instance> [recipe]: applies an SSH
+// recipe to an existing instance of the current account
+func (b *Bot) handleProvision(chatID int64, args string) {
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+	account := b.accountConfig(client.AccountName())
+
+	names := make([]string, 0, len(b.cfg.Recipes))
+	for name := range b.cfg.Recipes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		b.reply(chatID, "⚠️ 未配置部署配方，请在配置文件中添加 recipe_<名称>=<脚本文件>")
+		return
+	}
+
+	fields := strings.Fields(args)
+	recipeName := ""
+	if account != nil {
+		recipeName = account.VPSRecipe
+	}
+	if len(fields) >= 2 {
+		recipeName = fields[1]
+	} else if recipeName == "" && len(names) == 1 {
+		recipeName = names[0]
+	}
+	if len(fields) == 0 || recipeName == "" {
+		b.reply(chatID, "用法: /provision <实例> [配方]\n可用配方: "+strings.Join(names, ", "))
+		return
+	}
+	recipe := b.cfg.Recipes[recipeName]
+	if recipe == nil {
+		b.reply(chatID, fmt.Sprintf("❌ 配方不存在: %s\n可用配方: %s", recipeName, strings.Join(names, ", ")))
+		return
+	}
+	if recipe.CloudInit {
+		b.reply(chatID, fmt.Sprintf("❌ 配方 %s 通过 cloud-init 在实例首次启动时执行，无法用于已有实例", recipeName))
+		return
+	}
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	instances, err := client.ListInstances(ctx)
+	cancel()
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+	instanceID := ""
+	for _, inst := range instances {
+		if inst.ID == fields[0] || inst.DisplayName == fields[0] {
+			instanceID = inst.ID
+		}
+	}
+	if instanceID == "" {
+		b.reply(chatID, "❌ 未找到实例: "+fields[0])
+		return
+	}
+
+	p, err := newProvisioning(recipe)
+	if err != nil {
+		b.reply(chatID, fmt.Sprintf("❌ 配方 %s 无效: %v", recipeName, err))
+		return
+	}
+	conn, err := b.instanceConnection(client, instanceID)
+	switch {
+	case errors.Is(err, errNoPublicIP):
+		b.reply(chatID, "❌ 实例没有公网IP")
+		return
+	case err != nil:
+		b.reply(chatID, errorText(err))
+		return
+	}
+	if err := b.provision(chatID, topicNone, client, instanceID, conn, p); err != nil {
+		b.notify(chatID, topicNone, "❌ "+err.Error())
+	}
+}
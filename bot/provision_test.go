@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"oci-bot/config"
+)
+
+// loadConfig loads conf as the config, encrypted with pass unless it is empty
+func loadConfig(t *testing.T, conf, pass string) {
+	t.Helper()
+	content := []byte(conf)
+	if pass != "" {
+		var err error
+		if content, err = config.Encrypt(content, pass); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(t.TempDir(), "oci-bot.conf")
+	if err := os.WriteFile(file, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	config.SetPassphrase(pass)
+	if _, err := config.Load(file); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecipeParamsSealed(t *testing.T) {
+	params, err := newRecipeParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := deployment{Account: "main", Params: &params}
+
+	// Plain text while the config is
+	raw, err := json.Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), params.PrivateKey) {
+		t.Errorf("unencrypted config: params sealed in %s", raw)
+	}
+
+	loadConfig(t, "chat_id=42\n", "correct horse")
+	t.Cleanup(func() { loadConfig(t, "chat_id=42\n", "") })
+
+	sealed, err := json.Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{params.UUID, params.Password, params.PrivateKey} {
+		if strings.Contains(string(sealed), secret) {
+			t.Errorf("encrypted config: %q in %s", secret, sealed)
+		}
+	}
+
+	// Both forms read back, so checkpoints made before the config was
+	// encrypted still resume
+	for _, data := range [][]byte{sealed, raw} {
+		var got deployment
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Params == nil || *got.Params != params {
+			t.Errorf("read back %+v, want %+v", got.Params, params)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// accountQueue serializes mutating OCI operations on one account. Waiters are
+// served in arrival order, each handed the queue by the one before it;
+// read-only operations (listing IPs, instances, usage) never enter the queue
+// and keep running in parallel.
+type accountQueue struct {
+	mu      sync.Mutex
+	busy    bool
+	waiters []chan struct{} // Closed when it is the waiter's turn
+}
+
+// acquireAccount waits for the account's mutating-operation queue and returns
+// the release function. When the caller has to wait behind another operation
+// and chatID is non-zero, the user is told the request is queued. Waiting
+// ends with ctx's error when ctx is done first.
+//
+// Hold the queue only around the OCI calls that change something, not across
+// checks or waits between them, so other operations on the account aren't
+// held up for minutes.
+func (b *Bot) acquireAccount(ctx context.Context, chatID int64, accountName string) (func(), error) {
+	b.mu.Lock()
+	q, ok := b.queues[accountName]
+	if !ok {
+		q = &accountQueue{}
+		b.queues[accountName] = q
+	}
+	b.mu.Unlock()
+
+	q.mu.Lock()
+	if !q.busy {
+		q.busy = true
+		q.mu.Unlock()
+		return q.release, nil
+	}
+	turn := make(chan struct{})
+	q.waiters = append(q.waiters, turn)
+	q.mu.Unlock()
+
+	logger.Infof("[%s] Waiting for running operation", accountName)
+	if chatID != 0 {
+		b.reply(chatID, fmt.Sprintf("⏳ 账号 [%s] 有操作正在进行，已排队等待...", accountName))
+	}
+
+	select {
+	case <-turn:
+		return q.release, nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	if i := slices.Index(q.waiters, turn); i >= 0 {
+		q.waiters = slices.Delete(q.waiters, i, i+1)
+		q.mu.Unlock()
+		return nil, ctx.Err()
+	}
+	q.mu.Unlock()
+	// Handed the queue while giving up: pass it on
+	q.release()
+	return nil, ctx.Err()
+}
+
+// release hands the queue to the longest waiter, or frees it
+func (q *accountQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) == 0 {
+		q.busy = false
+		return
+	}
+	close(q.waiters[0])
+	q.waiters = q.waiters[1:]
+}
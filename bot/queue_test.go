@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"oci-bot/oci/ocifake"
+)
+
+func TestAccountQueueOrder(t *testing.T) {
+	b, _ := newTestBot(t, ocifake.New("main", "ap-tokyo-1"))
+	ctx := t.Context()
+
+	release, err := b.acquireAccount(ctx, 0, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Waiters queue up one at a time, so their arrival order is known
+	var mu sync.Mutex
+	var served []int
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := b.acquireAccount(ctx, 0, "main")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			served = append(served, i)
+			mu.Unlock()
+			release()
+		}()
+		waitFor(t, "the waiter to queue", func() bool {
+			q := b.queues["main"]
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			return len(q.waiters) == i+1
+		})
+	}
+	release()
+	wg.Wait()
+
+	if want := []int{0, 1, 2, 3, 4}; !slices.Equal(served, want) {
+		t.Errorf("served %v, want %v", served, want)
+	}
+}
+
+func TestAccountQueueCancel(t *testing.T) {
+	b, _ := newTestBot(t, ocifake.New("main", "ap-tokyo-1"))
+
+	release, err := b.acquireAccount(t.Context(), 0, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := b.acquireAccount(ctx, 0, "main"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waiting past the deadline: %v", err)
+	}
+
+	// The cancelled waiter left the queue, so it is free after release
+	release()
+	ctx, cancel = context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	release, err = b.acquireAccount(ctx, 0, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
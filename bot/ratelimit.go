@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Telegram allows about one message per second in a chat, 20 per minute in a
+// group and 30 per second overall. Short bursts are tolerated.
+const (
+	chatRate    = 1.0       // Messages per second in a private chat
+	groupRate   = 20.0 / 60 // Messages per second in a group
+	globalRate  = 30.0      // Messages per second across all chats
+	chatBurst   = 3         // Messages a chat may receive back to back
+	globalBurst = 30
+
+	// Status lines sent within this window are merged into one message
+	statusCoalesceWindow = 2 * time.Second
+)
+
+// tokenBucket is a token bucket that hands out reservations: taking a token
+// from an empty bucket returns how long to wait for it.
+type tokenBucket struct {
+	rate   float64 // Tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// reserve takes a token and returns the wait before it may be used
+func (tb *tokenBucket) reserve(now time.Time) time.Duration {
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+	tb.last = now
+
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// rateLimiter paces outgoing messages per chat and globally
+type rateLimiter struct {
+	mu     sync.Mutex
+	global *tokenBucket
+	chats  map[int64]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		global: newTokenBucket(globalRate, globalBurst),
+		chats:  make(map[int64]*tokenBucket),
+	}
+}
+
+// wait blocks until a message may be sent to chatID
+func (l *rateLimiter) wait(chatID int64) {
+	l.mu.Lock()
+	bucket := l.chats[chatID]
+	if bucket == nil {
+		rate := chatRate
+		if chatID < 0 {
+			rate = groupRate
+		}
+		bucket = newTokenBucket(rate, chatBurst)
+		l.chats[chatID] = bucket
+	}
+	now := time.Now()
+	delay := max(bucket.reserve(now), l.global.reserve(now))
+	l.mu.Unlock()
+
+	if delay > 0 {
+		logger.Debugf("Rate limiting message to %d for %s", chatID, delay)
+		time.Sleep(delay)
+	}
+}
+
+// pendingStatus collects status lines for one chat until they are flushed
+type pendingStatus struct {
+	topic topic
+	lines []string
+	timer *time.Timer
+}
+
+// status sends a short progress line. Lines arriving in quick succession are
+// merged into one message so long operations don't hit Telegram's limits.
+// Any other message to the chat flushes pending lines first, keeping order.
+// With notify_level=quiet the lines only go to the log.
+func (b *Bot) status(chatID int64, t topic, text string) {
+	if b.currentConfig().NotifyLevel == "quiet" {
+		logger.Infof("Status: %s", text)
+		return
+	}
+
+	b.statusMu.Lock()
+	pending := b.statuses[chatID]
+	if pending != nil && pending.topic != t {
+		b.statusMu.Unlock()
+		b.flushStatus(chatID)
+		b.statusMu.Lock()
+		pending = b.statuses[chatID]
+	}
+	if pending == nil {
+		pending = &pendingStatus{topic: t}
+		pending.timer = time.AfterFunc(statusCoalesceWindow, func() { b.flushStatus(chatID) })
+		b.statuses[chatID] = pending
+	}
+	pending.lines = append(pending.lines, text)
+	b.statusMu.Unlock()
+}
+
+// flushStatus sends the chat's pending status lines as one message
+func (b *Bot) flushStatus(chatID int64) {
+	b.statusMu.Lock()
+	pending := b.statuses[chatID]
+	delete(b.statuses, chatID)
+	b.statusMu.Unlock()
+
+	if pending == nil {
+		return
+	}
+	pending.timer.Stop()
+	b.replyIn(chatID, pending.topic, strings.Join(pending.lines, "\n"))
+}
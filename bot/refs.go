@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"time"
+)
+
+// refTTL is how long a callback token resolves after its button was last
+// shown. Older buttons are answered as expired.
+const refTTL = 24 * time.Hour
+
+// callbackTarget is the identifier behind a callback token
+type callbackTarget struct {
+	ID      string
+	Account string // Account the identifier belongs to, see accountRef
+	Shown   time.Time
+}
+
+// callbackRef returns a short token for a long identifier (e.g. an OCID) so it
+// fits in Telegram's 64-byte callback data limit. Resolve it with resolveRef.
+func (b *Bot) callbackRef(id string) string {
+	return b.accountRef("", id)
+}
+
+// accountRef is callbackRef for an identifier of the named account, so the
+// button acts on that account even after /use switched to another one.
+// Resolve it with resolveAccountRef.
+func (b *Bot) accountRef(accountName, id string) string {
+	key := id
+	if accountName != "" {
+		key = accountName + "\x00" + id
+	}
+	sum := sha1.Sum([]byte(key))
+	ref := hex.EncodeToString(sum[:])[:12]
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if _, ok := b.refs[ref]; !ok {
+		// Tokens are only added by listings, a good time to drop stale ones
+		for r, target := range b.refs {
+			if now.Sub(target.Shown) > refTTL {
+				delete(b.refs, r)
+			}
+		}
+	}
+	b.refs[ref] = callbackTarget{ID: id, Account: accountName, Shown: now}
+	return ref
+}
+
+// resolveRef returns the identifier registered for a callback token
+func (b *Bot) resolveRef(ref string) (string, bool) {
+	target, ok := b.resolveTarget(ref)
+	return target.ID, ok
+}
+
+// resolveAccountRef returns the account and identifier registered for a
+// callback token by accountRef
+func (b *Bot) resolveAccountRef(ref string) (accountName, id string, ok bool) {
+	target, ok := b.resolveTarget(ref)
+	return target.Account, target.ID, ok && target.Account != ""
+}
+
+// resolveTarget returns what a callback token was registered for
+func (b *Bot) resolveTarget(ref string) (callbackTarget, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	target, ok := b.refs[ref]
+	if !ok || time.Since(target.Shown) > refTTL {
+		return callbackTarget{}, false
+	}
+	return target, true
+}
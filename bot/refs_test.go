@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"oci-bot/oci/ocifake"
+)
+
+func TestCallbackRefExpires(t *testing.T) {
+	b, _ := newTestBot(t, ocifake.New("main", "ap-tokyo-1"))
+
+	old := b.callbackRef("ocid1.image.old")
+	if id, ok := b.resolveRef(old); !ok || id != "ocid1.image.old" {
+		t.Fatalf("resolveRef = %q %v", id, ok)
+	}
+
+	b.mu.Lock()
+	b.refs[old] = callbackTarget{ID: "ocid1.image.old", Shown: time.Now().Add(-refTTL - time.Minute)}
+	b.mu.Unlock()
+	if _, ok := b.resolveRef(old); ok {
+		t.Error("expired token still resolves")
+	}
+
+	// Listing something new drops the expired token
+	b.callbackRef("ocid1.image.new")
+	b.mu.Lock()
+	_, kept := b.refs[old]
+	size := len(b.refs)
+	b.mu.Unlock()
+	if kept || size != 1 {
+		t.Errorf("expired token kept %v, %d tokens, want 1", kept, size)
+	}
+}
+
+func TestAccountRefKeepsAccount(t *testing.T) {
+	b, _ := newTestBot(t, ocifake.New("main", "ap-tokyo-1"))
+
+	ref := b.accountRef("main", "ocid1.image.a")
+	// Listing the same identifier without an account doesn't take it over
+	if plain := b.callbackRef("ocid1.image.a"); plain == ref {
+		t.Fatal("plain and account tokens collide")
+	}
+	if account, id, ok := b.resolveAccountRef(ref); !ok || account != "main" || id != "ocid1.image.a" {
+		t.Errorf("resolveAccountRef = %q %q %v", account, id, ok)
+	}
+	if _, _, ok := b.resolveAccountRef(b.callbackRef("ocid1.image.b")); ok {
+		t.Error("token without an account resolves as an account token")
+	}
+}
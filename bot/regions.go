@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"oci-bot/ippure"
+	"oci-bot/state"
+)
+
+// regionStatsKey is the state section holding purity statistics per region
+const regionStatsKey = "region_stats"
+
+// regionStats aggregates the purity checks of IPs created in one OCI region
+type regionStats struct {
+	Checks    int       `json:"checks"`
+	PuritySum int       `json:"purity_sum"`
+	Best      int       `json:"best"`
+	BestIP    string    `json:"best_ip"`
+	Native    int       `json:"native"`
+	Updated   time.Time `json:"updated"`
+}
+
+// loadRegionStats reads the per-region statistics from the store
+func loadRegionStats(store *state.Store) (map[string]*regionStats, error) {
+	stats := make(map[string]*regionStats)
+	if err := store.Get(regionStatsKey, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// recordPurity adds a check result of an IP created in region to the statistics
+func (b *Bot) recordPurity(region string, info *ippure.IPInfo) {
+	if !info.Available() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.regionStats[region]
+	if s == nil {
+		s = &regionStats{Best: 101}
+		b.regionStats[region] = s
+	}
+	purity := purityValue(info)
+	s.Checks++
+	s.PuritySum += purity
+	if purity < s.Best {
+		s.Best = purity
+		s.BestIP = info.IPAddress
+	}
+	if info.IsNative == ippure.OriginNative {
+		s.Native++
+	}
+	s.Updated = time.Now()
+
+	if err := b.store.Set(regionStatsKey, b.regionStats); err != nil {
+		logger.Errorf("Failed to save region stats: %v", err)
+	}
+}
+
+// showRegions shows average and best purity per region, purest first
+func (b *Bot) showRegions(chatID int64) {
+	accounts := make(map[string][]string)
+	for _, client := range b.sortedClients() {
+		accounts[client.Region()] = append(accounts[client.Region()], client.AccountName())
+	}
+
+	b.mu.Lock()
+	regions := make([]string, 0, len(b.regionStats))
+	stats := make(map[string]regionStats, len(b.regionStats))
+	for region, s := range b.regionStats {
+		regions = append(regions, region)
+		stats[region] = *s
+	}
+	b.mu.Unlock()
+
+	if len(regions) == 0 {
+		b.reply(chatID, "🌏 暂无检测记录\n使用 /autoip 或开启 auto_check_ip 后会按区域统计纯净度")
+		return
+	}
+
+	average := func(s regionStats) float64 {
+		return float64(s.PuritySum) / float64(s.Checks)
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		return average(stats[regions[i]]) < average(stats[regions[j]])
+	})
+
+	var sb strings.Builder
+	sb.WriteString("🌏 *各区域IP纯净度* (越低越纯净)\n")
+	for _, region := range regions {
+		s := stats[region]
+		sb.WriteString(fmt.Sprintf("\n*%s*", region))
+		if names := accounts[region]; len(names) > 0 {
+			sort.Strings(names)
+			sb.WriteString(" [" + strings.Join(names, ", ") + "]")
+		}
+		sb.WriteString(fmt.Sprintf("\n平均 %.1f%% · 最佳 %d%% (`%s`)\n原生 %d/%d · 样本 %d · 更新于 %s\n",
+			average(s), s.Best, s.BestIP, s.Native, s.Checks, s.Checks, s.Updated.Format("2006-01-02")))
+	}
+	b.replyMarkdown(chatID, sb.String())
+}
@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"oci-bot/iplookup"
+)
+
+// ipRegistration is who an IP address is registered to. The organization
+// often explains a non-native result, e.g. a range still registered to the
+// provider it was bought from.
+type ipRegistration struct {
+	PTR   string                 // Reverse DNS name, "" when none or unknown
+	Whois *iplookup.Registration // nil when the WHOIS lookup failed
+}
+
+// lookupRegistration looks up reverse DNS and WHOIS concurrently. Failures
+// are logged and leave the fields empty.
+func lookupRegistration(ctx context.Context, ip string) ipRegistration {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	var reg ipRegistration
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		ptr, err := iplookup.LookupPTR(ctx, ip)
+		if err != nil {
+			logger.Debugf("Reverse DNS of %s failed: %v", ip, err)
+		}
+		reg.PTR = ptr
+	}()
+	go func() {
+		defer wg.Done()
+		whois, err := iplookup.LookupWhois(ctx, ip)
+		if err != nil {
+			logger.Debugf("WHOIS of %s failed: %v", ip, err)
+		}
+		reg.Whois = whois
+	}()
+	wg.Wait()
+	return reg
+}
+
+// markdown formats the registration as lines for IP check results, "" when
+// nothing was found
+func (r ipRegistration) markdown() string {
+	var lines []string
+	if r.Whois != nil {
+		org := r.Whois.Org
+		if org == "" {
+			org = r.Whois.NetName
+		} else if r.Whois.NetName != "" {
+			org += " (" + r.Whois.NetName + ")"
+		}
+		if r.Whois.Country != "" {
+			org += ", " + r.Whois.Country
+		}
+		lines = append(lines, "🏛 *注册组织:* "+escapeMarkdown(org))
+	}
+	if r.PTR != "" {
+		lines = append(lines, "🔁 *反向解析:* `"+strings.ReplaceAll(r.PTR, "`", "")+"`")
+	}
+	return strings.Join(lines, "\n")
+}
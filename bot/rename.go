@@ -0,0 +1,193 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultIPName matches the display names the bot gives new IPs
+var defaultIPName = regexp.MustCompile(`^(tg|auto)-\d+$`)
+
+// renameTarget is a resource waiting for its new name
+type renameTarget struct {
+	AccountName string
+	Kind        string // "ip" or "vm"
+	ID          string
+	OldName     string
+	UserID      int64 // Asked for the name; in the group only their answer is taken
+}
+
+// handleRename renames directly with "/rename <IP|实例> <新名称>", or shows a
+// picker when the new name (or everything) is missing
+func (b *Bot) handleRename(chatID, userID int64, args string) {
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	ips, err := client.ListReservedIPs(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+	instances, err := client.ListInstances(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	if args == "" {
+		b.showRenamePicker(chatID, client, ips, instances)
+		return
+	}
+
+	name, newName, _ := strings.Cut(args, " ")
+	newName = strings.TrimSpace(newName)
+
+	var target *renameTarget
+	for _, ip := range ips {
+		if ip.IPAddress == name || ip.DisplayName == name || ip.ID == name {
+			target = &renameTarget{AccountName: client.AccountName(), Kind: "ip", ID: ip.ID, OldName: ip.IPAddress}
+			break
+		}
+	}
+	if target == nil {
+		for _, inst := range instances {
+			if inst.DisplayName == name || inst.ID == name {
+				target = &renameTarget{AccountName: client.AccountName(), Kind: "vm", ID: inst.ID, OldName: inst.DisplayName}
+				break
+			}
+		}
+	}
+	if target == nil {
+		b.reply(chatID, "❌ 未找到IP或实例: "+name)
+		return
+	}
+
+	if newName == "" {
+		b.askRename(chatID, userID, target)
+		return
+	}
+	b.applyRename(chatID, client, target, newName)
+}
+
+// showRenamePicker lists IPs and instances of the account as rename buttons
+func (b *Bot) showRenamePicker(chatID int64, client oci.Service, ips []oci.PublicIPInfo, instances []oci.InstanceInfo) {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, ip := range ips {
+		label := "🌐 " + ip.IPAddress
+		if !defaultIPName.MatchString(ip.DisplayName) {
+			label += " (" + ip.DisplayName + ")"
+		}
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(label, "ren:ip:"+b.callbackRef(ip.ID)),
+		})
+	}
+	for _, inst := range instances {
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData("🖥 "+inst.DisplayName, "ren:vm:"+b.callbackRef(inst.ID)),
+		})
+	}
+	if len(buttons) == 0 {
+		b.reply(chatID, fmt.Sprintf("📋 [%s] 暂无IP或实例", client.AccountName()))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✏️ [%s] 选择要重命名的资源:", client.AccountName()))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
+
+// handleRenameCallback handles ren:<ip|vm>:<ref> from the picker
+func (b *Bot) handleRenameCallback(chatID, userID int64, kind string, parts []string) {
+	if len(parts) < 3 {
+		return
+	}
+	id, ok := b.resolveRef(parts[2])
+	if !ok {
+		b.reply(chatID, "⚠️ 按钮已过期，请重新使用 /rename")
+		return
+	}
+
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+
+	b.askRename(chatID, userID, &renameTarget{AccountName: client.AccountName(), Kind: kind, ID: id})
+}
+
+// askRename remembers the target and asks userID for the new name
+func (b *Bot) askRename(chatID, userID int64, target *renameTarget) {
+	target.UserID = userID
+	b.mu.Lock()
+	b.renames[chatID] = target
+	b.mu.Unlock()
+
+	prompt := "✏️ 请输入新名称"
+	if target.OldName != "" {
+		prompt += fmt.Sprintf(" (当前: %s)", target.OldName)
+	}
+	b.reply(chatID, prompt)
+}
+
+// handleRenameInput applies a pending rename with the text userID sent.
+// Returns false when no rename is pending for the chat, or it waits for
+// another user's answer.
+func (b *Bot) handleRenameInput(chatID, userID int64, text string) bool {
+	b.mu.Lock()
+	target := b.renames[chatID]
+	if target == nil || target.UserID != userID {
+		b.mu.Unlock()
+		return false
+	}
+	delete(b.renames, chatID)
+	b.mu.Unlock()
+
+	client, ok := b.client(target.AccountName)
+	if !ok {
+		b.reply(chatID, "❌ 账号不存在: "+target.AccountName)
+		return true
+	}
+	b.applyRename(chatID, client, target, strings.TrimSpace(text))
+	return true
+}
+
+// applyRename validates the name and updates the resource's display name
+func (b *Bot) applyRename(chatID int64, client oci.Service, target *renameTarget, newName string) {
+	if newName == "" || len(newName) > 255 || strings.IndexFunc(newName, unicode.IsControl) >= 0 {
+		b.reply(chatID, "❌ 名称需为 1-255 个字符且不含控制字符")
+		return
+	}
+
+	release, err := b.acquireAccount(b.runCtx, chatID, client.AccountName())
+	if err != nil {
+		return
+	}
+	defer release()
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	if target.Kind == "ip" {
+		err = client.RenameReservedIP(ctx, target.ID, newName)
+	} else {
+		err = client.RenameInstance(ctx, target.ID, newName)
+	}
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	if target.OldName != "" {
+		b.reply(chatID, fmt.Sprintf("✅ 已重命名: %s → %s", target.OldName, newName))
+	} else {
+		b.reply(chatID, "✅ 已重命名为: "+newName)
+	}
+}
@@ -0,0 +1,195 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"oci-bot/config"
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	keyCheckAttempts = 6                // Tries before a new key is given up on
+	keyCheckInterval = 10 * time.Second // New keys take a moment to propagate
+)
+
+// handleRotateKey asks which account's API key to rotate, or for confirmation
+func (b *Bot) handleRotateKey(chatID int64, accountName string) {
+	if accountName == "" {
+		var buttons [][]tgbotapi.InlineKeyboardButton
+		for _, client := range b.sortedClients() {
+			name := client.AccountName()
+			buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+				tgbotapi.NewInlineKeyboardButtonData(name, "rotkey:ask:"+name),
+			})
+		}
+		msg := tgbotapi.NewMessage(chatID, "🔑 选择要轮换 API 密钥的账号:")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+		b.send(msg)
+		return
+	}
+
+	if _, ok := b.client(accountName); !ok {
+		b.reply(chatID, "❌ 账号不存在: "+accountName)
+		return
+	}
+
+	text := fmt.Sprintf(`🔑 *轮换 API 密钥* [%s]
+
+将依次:
+1. 生成新的 RSA 密钥对并上传公钥
+2. 验证新密钥可用
+3. 更新配置文件中的 fingerprint 和 key\_file
+4. 删除旧密钥 (旧私钥文件保留在本地)
+
+每个用户最多 3 个 API 密钥，已满时上传会失败。`, escapeMarkdown(accountName))
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔑 确认轮换", "rotkey:go:"+accountName)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ 取消", "rotkey:cancel:")),
+	)
+	b.send(msg)
+}
+
+// handleRotateKeyCallback handles the /rotatekey buttons
+func (b *Bot) handleRotateKeyCallback(chatID int64, action string, parts []string) {
+	accountName := ""
+	if len(parts) >= 3 {
+		accountName = parts[2]
+	}
+	switch action {
+	case "ask":
+		b.handleRotateKey(chatID, accountName)
+	case "go":
+		b.rotateKey(chatID, accountName)
+	case "cancel":
+		b.reply(chatID, "❌ 已取消")
+	}
+}
+
+// accountConfig returns the configuration of an account
+func (b *Bot) accountConfig(name string) *config.OCIAccount {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.accountConfigLocked(name)
+}
+
+// accountConfigLocked is accountConfig for callers holding b.mu
+func (b *Bot) accountConfigLocked(name string) *config.OCIAccount {
+	for i := range b.cfg.Accounts {
+		if b.cfg.Accounts[i].Name == name {
+			return &b.cfg.Accounts[i]
+		}
+	}
+	return nil
+}
+
+// rotatedKeyFile returns the path the new private key is written to, next to
+// the current one
+func rotatedKeyFile(current string) string {
+	base := strings.TrimSuffix(filepath.Base(current), filepath.Ext(current))
+	return filepath.Join(filepath.Dir(current), fmt.Sprintf("%s-%s.pem", base, time.Now().Format("20060102-150405")))
+}
+
+// rotateKey replaces the account's API key. The old key stays valid until the
+// new one is verified and saved to the config, so a failure at any step leaves
+// the account usable.
+func (b *Bot) rotateKey(chatID int64, accountName string) {
+	client, ok := b.client(accountName)
+	account := b.accountConfig(accountName)
+	if !ok || account == nil {
+		b.reply(chatID, "❌ 账号不存在: "+accountName)
+		return
+	}
+	b.mu.Lock()
+	oldFingerprint, oldKeyFile := account.Fingerprint, account.KeyFile
+	b.mu.Unlock()
+
+	ctx, cancel := b.withTimeout(rotateTimeout)
+	defer cancel()
+
+	b.status(chatID, topicNone, fmt.Sprintf("🔑 [%s] 生成新密钥...", accountName))
+	privateKey, publicKey, err := oci.NewAPIKey()
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	keyFile := rotatedKeyFile(oldKeyFile)
+	sealed, err := config.SealSecret([]byte(privateKey))
+	if err == nil {
+		err = os.WriteFile(keyFile, sealed, 0600)
+	}
+	if err != nil {
+		b.reply(chatID, "❌ 保存私钥失败: "+err.Error())
+		return
+	}
+
+	fingerprint, err := client.UploadAPIKey(ctx, publicKey)
+	if err != nil {
+		os.Remove(keyFile)
+		b.reply(chatID, "❌ 上传公钥失败: "+describeError(err))
+		return
+	}
+	logger.Infof("[%s] Uploaded API key %s", accountName, fingerprint)
+
+	// abort removes the new key again; the old one was never touched
+	abort := func(reason string) {
+		if err := client.DeleteAPIKey(ctx, fingerprint); err != nil {
+			logger.Errorf("[%s] Failed to remove new API key %s: %v", accountName, fingerprint, err)
+			reason += "\n⚠️ 新密钥 " + fingerprint + " 删除失败，请在控制台手动删除"
+		}
+		os.Remove(keyFile)
+		b.reply(chatID, reason)
+	}
+
+	b.status(chatID, topicNone, fmt.Sprintf("🔑 [%s] 已上传 %s，验证中...", accountName, fingerprint))
+	for attempt := 1; ; attempt++ {
+		err = client.CheckAPIKey(ctx, fingerprint, privateKey)
+		if err == nil || attempt >= keyCheckAttempts {
+			break
+		}
+		logger.Debugf("[%s] New API key not usable yet (%d/%d): %v", accountName, attempt, keyCheckAttempts, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(keyCheckInterval):
+		}
+	}
+	if err != nil {
+		abort("❌ 新密钥验证失败，已撤销: " + describeError(err))
+		return
+	}
+
+	values := map[string]string{"fingerprint": fingerprint, "key_file": keyFile}
+	if account.KeyPassphrase != "" {
+		values["key_passphrase"] = "" // The new key is not encrypted
+	}
+	if err := config.SetAccountValues(b.cfg.File, accountName, values); err != nil {
+		abort("❌ 更新配置文件失败，已撤销: " + err.Error())
+		return
+	}
+
+	client.UseAPIKey(fingerprint, privateKey)
+	b.mu.Lock()
+	account.Fingerprint = fingerprint
+	account.KeyFile = keyFile
+	account.KeyPassphrase = ""
+	b.mu.Unlock()
+
+	text := fmt.Sprintf("✅ [%s] API 密钥已轮换\n\n新指纹: %s\n新私钥: %s", accountName, fingerprint, keyFile)
+	if err := client.DeleteAPIKey(ctx, oldFingerprint); err != nil {
+		logger.Errorf("[%s] Failed to delete old API key %s: %v", accountName, oldFingerprint, err)
+		text += fmt.Sprintf("\n\n⚠️ 旧密钥 %s 删除失败，请在控制台手动删除: %s", oldFingerprint, describeError(err))
+	} else {
+		text += fmt.Sprintf("\n\n🗑 旧密钥 %s 已删除，本地旧私钥 %s 可以删除", oldFingerprint, oldKeyFile)
+	}
+	logger.Infof("[%s] API key rotated from %s to %s", accountName, oldFingerprint, fingerprint)
+	b.reply(chatID, text)
+}
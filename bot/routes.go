@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"oci-bot/config"
+	"oci-bot/route"
+)
+
+// routeNames are the route types as shown in chat
+var routeNames = map[string]string{
+	route.CN2GIA:  "电信 CN2 GIA",
+	route.CN2GT:   "电信 CN2 GT",
+	route.CMI:     "移动 CMI",
+	route.CU9929:  "联通 9929",
+	route.CUG:     "联通 CUG",
+	route.CU4837:  "联通 4837",
+	route.CT163:   "电信 163",
+	route.Unknown: "未知",
+}
+
+// detectRoutes traces the route to ip from every route_locations entry, nil
+// when none is configured
+func (b *Bot) detectRoutes(ctx context.Context, ip string) []route.Result {
+	if len(b.cfg.RouteLocations) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, routeTimeout)
+	defer cancel()
+	results := route.Detect(ctx, ip, b.cfg.RouteLocations, b.cfg.GlobalpingToken)
+	for _, r := range results {
+		if r.Err != nil {
+			logger.Warnf("Route to %s from %s failed: %v", ip, r.Location, r.Err)
+		}
+	}
+	return results
+}
+
+// routesMarkdown formats results as a line for IP check results, "" for none
+func routesMarkdown(results []route.Result) string {
+	if len(results) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, r := range results {
+		if r.Err != nil {
+			parts = append(parts, r.Location+" 失败")
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", r.Location, routeNames[r.Type]))
+	}
+	return "🛣 *线路:* " + escapeMarkdown(strings.Join(parts, " · "))
+}
+
+// routesWanted applies the account's autoip_routes to results. Locations that
+// couldn't trace are ignored, as a failed lookup adds no penalty.
+func routesWanted(account *config.OCIAccount, results []route.Result) (bool, string) {
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		if !slices.ContainsFunc(account.AutoIPRoutes, func(want string) bool { return strings.EqualFold(want, r.Type) }) {
+			return false, fmt.Sprintf("%s from %s", r.Type, r.Location)
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"oci-bot/iplookup"
+	"oci-bot/ippure"
+	"oci-bot/latency"
+	"oci-bot/route"
+)
+
+// ipScore is the score auto-apply compares with the purity threshold: the
+// ippure percent plus the configured score_* penalties
+type ipScore struct {
+	Value     int
+	Penalties []string         // Human readable penalties, empty when none applied
+	Latency   []latency.Result // Measured for autoip_max_latency, reported with a match
+	Routes    []route.Result   // Traced for autoip_routes, reported with a match
+	Speed     string           // autoip_speedtest outcome, e.g. "312.5 Mbps"
+}
+
+// String formats the score with its penalties, e.g. "35 (纯净度 15% + 黑名单 ×2 +20)"
+func (s ipScore) String() string {
+	if len(s.Penalties) == 0 {
+		return fmt.Sprintf("%d", s.Value)
+	}
+	return fmt.Sprintf("%d (%s)", s.Value, strings.Join(s.Penalties, " "))
+}
+
+// withPurity adds the ippure percent to penalties looked up by lookupPenalties
+func (s ipScore) withPurity(info *ippure.IPInfo) ipScore {
+	score := ipScore{Value: s.Value + purityValue(info), Latency: s.Latency, Routes: s.Routes}
+	if len(s.Penalties) > 0 {
+		score.Penalties = append([]string{"纯净度 " + info.PurityScore}, s.Penalties...)
+	}
+	return score
+}
+
+// lookupPenalties looks up the score_* penalties of an IP; Value is their
+// sum. Blocklists and origin are only looked up when their weights are set;
+// a failed lookup adds no penalty.
+func (b *Bot) lookupPenalties(ctx context.Context, ipAddr string) ipScore {
+	var score ipScore
+	if !b.cfg.CompositeScoring() {
+		return score
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	if b.cfg.ScoreDNSBLWeight != 0 {
+		listed, _ := iplookup.Blocklisted(ctx, ipAddr, iplookup.DefaultBlocklists)
+		if len(listed) > 0 {
+			points := len(listed) * b.cfg.ScoreDNSBLWeight
+			score.Value += points
+			score.Penalties = append(score.Penalties, fmt.Sprintf("+ 黑名单 ×%d %+d", len(listed), points))
+		}
+	}
+
+	if len(b.cfg.ScoreASNPenalty) == 0 && b.cfg.ScoreCountryWeight == 0 {
+		return score
+	}
+	origin, err := iplookup.LookupOrigin(ctx, ipAddr)
+	if err != nil {
+		logger.Warnf("Origin lookup of %s failed, scoring without it: %v", ipAddr, err)
+		return score
+	}
+	if points, ok := b.cfg.ScoreASNPenalty[origin.ASN]; ok && points != 0 {
+		score.Value += points
+		score.Penalties = append(score.Penalties, fmt.Sprintf("+ AS%s %+d", origin.ASN, points))
+	}
+	if b.cfg.ScoreCountry != "" && origin.Country != b.cfg.ScoreCountry && b.cfg.ScoreCountryWeight != 0 {
+		score.Value += b.cfg.ScoreCountryWeight
+		score.Penalties = append(score.Penalties, fmt.Sprintf("+ 注册地 %s %+d", origin.Country, b.cfg.ScoreCountryWeight))
+	}
+	return score
+}
@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	sendAttempts      = 4                // Tries per message before giving up
+	sendRetryMaxDelay = 60 * time.Second // Longest retry_after honoured inline
+)
+
+// send sends a message to its chat, see sendIn
+func (b *Bot) send(msg tgbotapi.MessageConfig) (tgbotapi.Message, error) {
+	return b.sendIn(topicNone, msg)
+}
+
+// sendIn sends msg into the topic bound to kind t, see deliver
+func (b *Bot) sendIn(t topic, msg tgbotapi.MessageConfig) (tgbotapi.Message, error) {
+	return b.deliver(t, msg, false)
+}
+
+// deliver sends msg into the topic bound to kind t, converted to the configured
+// parse mode. Text over Telegram's length limit is split into several messages
+// with the keyboard on the last one. Transient failures are retried; when
+// Telegram rejects the formatting the message is resent as plain text.
+// Critical messages that still can't be sent are kept in the outbox and
+// delivered once Telegram is back. Returns the last message sent.
+func (b *Bot) deliver(t topic, msg tgbotapi.MessageConfig, critical bool) (tgbotapi.Message, error) {
+	b.flushStatus(msg.ChatID)
+
+	thread := b.threadFor(msg.ChatID, t)
+	chunks := splitMessage(msg.Text, b.messageLimit())
+
+	var sent tgbotapi.Message
+	var err error
+	for i, chunk := range chunks {
+		part := msg
+		part.Text = chunk
+		if i < len(chunks)-1 {
+			part.ReplyMarkup = nil
+		}
+
+		sent, err = b.transmitWithRetry(b.formatMessage(part), thread)
+		if isBadRequest(err) && part.ParseMode != "" {
+			logger.Warnf("Send rejected (%v), resending as plain text", err)
+			sent, err = b.transmitWithRetry(plainMessage(part), thread)
+		}
+		if err != nil {
+			logger.Warnf("Failed to send message to %d: %v", msg.ChatID, err)
+			if critical && !isBadRequest(err) {
+				b.queueOutbox(part, thread)
+			}
+		}
+	}
+	return sent, err
+}
+
+// sendFile sends a document or photo to chatID within the chat's rate limit
+func (b *Bot) sendFile(chatID int64, file tgbotapi.Chattable) (tgbotapi.Message, error) {
+	b.limiter.wait(chatID)
+	return b.api.Send(file)
+}
+
+// edit changes a message sent to chatID within the chat's rate limit
+func (b *Bot) edit(chatID int64, edit tgbotapi.Chattable) {
+	b.limiter.wait(chatID)
+	b.api.Request(edit)
+}
+
+// transmitWithRetry retries transmit on rate limiting (honouring retry_after)
+// and on network or server errors with exponential backoff. Waiting stops
+// when the bot shuts down.
+func (b *Bot) transmitWithRetry(msg tgbotapi.MessageConfig, thread int) (tgbotapi.Message, error) {
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		sent, err := b.transmit(msg, thread)
+		if err == nil || isBadRequest(err) || attempt >= sendAttempts {
+			return sent, err
+		}
+
+		wait := delay
+		var apiErr *tgbotapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusTooManyRequests && apiErr.RetryAfter > 0 {
+			wait = time.Duration(apiErr.RetryAfter) * time.Second
+			if wait > sendRetryMaxDelay {
+				return sent, err
+			}
+		}
+		logger.Warnf("Send failed (%v), retrying in %s", err, wait)
+		select {
+		case <-b.runCtx.Done():
+			return sent, err
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// isBadRequest reports whether Telegram refused the message itself (HTTP 400,
+// e.g. unparsable entities or an unknown chat), which retrying can't fix
+func isBadRequest(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest
+}
+
+// transmit sends msg as is once the rate limiter allows it. The bundled Bot API
+// client predates forum topics, so topic messages are sent as a raw
+// sendMessage call.
+func (b *Bot) transmit(msg tgbotapi.MessageConfig, thread int) (tgbotapi.Message, error) {
+	b.limiter.wait(msg.ChatID)
+	if thread == 0 {
+		return b.api.Send(msg)
+	}
+
+	params := tgbotapi.Params{}
+	params.AddNonZero64("chat_id", msg.ChatID)
+	params.AddNonZero("message_thread_id", thread)
+	params["text"] = msg.Text
+	params.AddNonEmpty("parse_mode", msg.ParseMode)
+	params.AddBool("disable_web_page_preview", msg.DisableWebPagePreview)
+	if err := params.AddInterface("reply_markup", msg.ReplyMarkup); err != nil {
+		return tgbotapi.Message{}, err
+	}
+
+	resp, err := b.api.MakeRequest("sendMessage", params)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	var sent tgbotapi.Message
+	err = json.Unmarshal(resp.Result, &sent)
+	return sent, err
+}
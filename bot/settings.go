@@ -0,0 +1,270 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"oci-bot/config"
+	"oci-bot/ippure"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// settingChoice is one value /settings offers for an option
+type settingChoice struct {
+	Value string // Conf value, also used in callback data
+	Label string
+}
+
+// setting is a global option /settings can change while the bot runs
+type setting struct {
+	Key     string // Conf key, or a name for options spanning several keys
+	Label   string
+	Hint    string
+	Choices []settingChoice // Options with two choices toggle on tap
+
+	get   func(c *config.Config) string
+	apply func(c *config.Config, value string)
+	// values maps a choice to the conf lines to write, Key=value when nil
+	values func(value string) map[string]string
+}
+
+var onOff = []settingChoice{{"true", "开"}, {"false", "关"}}
+
+var settings = []setting{
+	{
+		Key:     "auto_check_ip",
+		Label:   "创建后检测纯净度",
+		Hint:    "/newip 创建IP后自动检测纯净度",
+		Choices: onOff,
+		get:     func(c *config.Config) string { return strconv.FormatBool(c.AutoCheckIP) },
+		apply:   func(c *config.Config, v string) { c.AutoCheckIP = v == "true" },
+	},
+	{
+		Key:     "notify_level",
+		Label:   "通知",
+		Hint:    "精简: 删除进度、等待提示等进度消息只写入日志",
+		Choices: []settingChoice{{"all", "全部"}, {"quiet", "精简"}},
+		get:     func(c *config.Config) string { return c.NotifyLevel },
+		apply:   func(c *config.Config, v string) { c.NotifyLevel = v },
+	},
+	{
+		Key:     "auto_check_fail",
+		Label:   "检测失败的IP",
+		Hint:    "自动刷IP无法检测纯净度时保留还是删除该IP",
+		Choices: []settingChoice{{"keep", "保留"}, {"delete", "删除"}},
+		get:     func(c *config.Config) string { return c.AutoCheckFail },
+		apply:   func(c *config.Config, v string) { c.AutoCheckFail = v },
+	},
+	{
+		Key:     "auto_check_retries",
+		Label:   "检测失败重试",
+		Hint:    "纯净度检测失败后重试的次数",
+		Choices: []settingChoice{{"0", "0"}, {"1", "1"}, {"2", "2"}, {"3", "3"}},
+		get:     func(c *config.Config) string { return strconv.Itoa(c.AutoCheckRetries) },
+		apply:   func(c *config.Config, v string) { c.AutoCheckRetries, _ = strconv.Atoi(v) },
+	},
+	{
+		Key:     "auto_burst",
+		Label:   "每轮创建IP",
+		Hint:    "自动刷IP每轮创建并同时检测的IP数，注意IP配额",
+		Choices: []settingChoice{{"1", "1"}, {"3", "3"}, {"5", "5"}, {"10", "10"}},
+		get:     func(c *config.Config) string { return strconv.Itoa(c.AutoBurst) },
+		apply:   func(c *config.Config, v string) { c.AutoBurst, _ = strconv.Atoi(v) },
+	},
+	{
+		Key:     "max_concurrent_checks",
+		Label:   "同时检测",
+		Hint:    "同时运行的纯净度检测（每个一个 Chrome），超出的排队等待",
+		Choices: []settingChoice{{"1", "1"}, {"2", "2"}, {"4", "4"}, {"8", "8"}, {"0", "不限"}},
+		get:     func(c *config.Config) string { return strconv.Itoa(c.MaxConcurrentChecks) },
+		apply: func(c *config.Config, v string) {
+			c.MaxConcurrentChecks, _ = strconv.Atoi(v)
+			ippure.SetLimits(ippure.Limits{MaxConcurrent: c.MaxConcurrentChecks, MaxMemoryMB: c.CheckMemoryLimitMB})
+		},
+	},
+	{
+		Key:     "auto_adaptive",
+		Label:   "自适应间隔",
+		Hint:    "对之后启动的任务生效",
+		Choices: onOff,
+		get:     func(c *config.Config) string { return strconv.FormatBool(c.AutoAdaptive) },
+		apply:   func(c *config.Config, v string) { c.AutoAdaptive = v == "true" },
+	},
+	{
+		Key:     "auto_adaptive_range",
+		Label:   "自适应间隔范围",
+		Hint:    "最短-最长等待秒数，对之后启动的任务生效",
+		Choices: []settingChoice{{"10-600", "10-600秒"}, {"30-1800", "30-1800秒"}, {"60-3600", "60-3600秒"}, {"120-7200", "120-7200秒"}},
+		get: func(c *config.Config) string {
+			return fmt.Sprintf("%d-%d", c.AutoAdaptiveMin, c.AutoAdaptiveMax)
+		},
+		apply: func(c *config.Config, v string) {
+			lo, hi, _ := strings.Cut(v, "-")
+			c.AutoAdaptiveMin, _ = strconv.Atoi(lo)
+			c.AutoAdaptiveMax, _ = strconv.Atoi(hi)
+		},
+		values: func(v string) map[string]string {
+			lo, hi, _ := strings.Cut(v, "-")
+			return map[string]string{"auto_adaptive_min": lo, "auto_adaptive_max": hi}
+		},
+	},
+}
+
+// findSetting returns the option with key, nil when there is none
+func findSetting(key string) *setting {
+	for i := range settings {
+		if settings[i].Key == key {
+			return &settings[i]
+		}
+	}
+	return nil
+}
+
+// choiceLabel returns the label of value, value itself when it isn't offered
+func (s *setting) choiceLabel(value string) string {
+	for _, c := range s.Choices {
+		if c.Value == value {
+			return c.Label
+		}
+	}
+	return value
+}
+
+// currentConfig returns a copy of the config, safe to read while /settings
+// changes it
+func (b *Bot) currentConfig() config.Config {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return *b.cfg
+}
+
+// showSettings sends the settings menu
+func (b *Bot) showSettings(chatID int64) {
+	text, markup := b.settingsMenu()
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = markup
+	b.send(msg)
+}
+
+// settingsMenu renders the main menu: one button per option with its value
+func (b *Bot) settingsMenu() (string, tgbotapi.InlineKeyboardMarkup) {
+	cfg := b.currentConfig()
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for i := range settings {
+		s := &settings[i]
+		current := s.get(&cfg)
+		data := "cfg:open:" + s.Key
+		if len(s.Choices) == 2 {
+			// Toggle without opening a submenu
+			next := s.Choices[0].Value
+			if current == next {
+				next = s.Choices[1].Value
+			}
+			data = "cfg:set:" + s.Key + ":" + next
+		}
+		label := fmt.Sprintf("%s: %s", s.Label, s.choiceLabel(current))
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(label, data)})
+	}
+	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("✖️ 关闭", "cfg:close")})
+
+	return "⚙️ *设置*\n\n修改立即生效并写入配置文件", tgbotapi.NewInlineKeyboardMarkup(buttons...)
+}
+
+// settingMenu renders the choices of one option with the current one checked
+func (b *Bot) settingMenu(s *setting) (string, tgbotapi.InlineKeyboardMarkup) {
+	cfg := b.currentConfig()
+	current := s.get(&cfg)
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, c := range s.Choices {
+		label := c.Label
+		if c.Value == current {
+			label = "✅ " + label
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, "cfg:set:"+s.Key+":"+c.Value))
+		if len(row) == 2 {
+			buttons = append(buttons, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		buttons = append(buttons, row)
+	}
+	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", "cfg:menu")})
+
+	text := fmt.Sprintf("⚙️ *%s*\n\n%s", s.Label, s.Hint)
+	return text, tgbotapi.NewInlineKeyboardMarkup(buttons...)
+}
+
+// handleSettingsCallback handles cfg:<menu|open|set|close>, editing the menu
+// message in place
+func (b *Bot) handleSettingsCallback(chatID int64, messageID int, action string, parts []string) {
+	var text string
+	var markup tgbotapi.InlineKeyboardMarkup
+
+	switch action {
+	case "close":
+		b.edit(chatID, tgbotapi.NewEditMessageText(chatID, messageID, "⚙️ 设置已关闭"))
+		return
+	case "menu":
+		text, markup = b.settingsMenu()
+	case "open":
+		if len(parts) < 3 {
+			return
+		}
+		s := findSetting(parts[2])
+		if s == nil {
+			return
+		}
+		text, markup = b.settingMenu(s)
+	case "set":
+		if len(parts) < 4 {
+			return
+		}
+		s := findSetting(parts[2])
+		if s == nil {
+			return
+		}
+		if err := b.changeSetting(s, parts[3]); err != nil {
+			b.reply(chatID, "❌ 保存设置失败: "+err.Error())
+			return
+		}
+		text, markup = b.settingsMenu()
+	default:
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, markup)
+	edit.ParseMode = tgbotapi.ModeMarkdown
+	b.edit(chatID, edit)
+}
+
+// changeSetting writes an offered value to the conf file and applies it
+func (b *Bot) changeSetting(s *setting, value string) error {
+	offered := false
+	for _, c := range s.Choices {
+		offered = offered || c.Value == value
+	}
+	if !offered {
+		return fmt.Errorf("invalid value %q for %s", value, s.Key)
+	}
+
+	values := map[string]string{s.Key: value}
+	if s.values != nil {
+		values = s.values(value)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := config.SetGlobalValues(b.cfg.File, values); err != nil {
+		return err
+	}
+	s.apply(b.cfg, value)
+	logger.Infof("Setting %s changed to %s", s.Key, value)
+	return nil
+}
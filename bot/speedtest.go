@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	"oci-bot/config"
+	"oci-bot/oci"
+	"oci-bot/speedtest"
+)
+
+// runSpeedTest binds publicIP to the account's test instance, runs
+// autoip_speedtest against it and unbinds it again. The IP is unbound even
+// when ctx ends, so it can be released or kept as usual.
+func (b *Bot) runSpeedTest(ctx context.Context, client oci.Service, account *config.OCIAccount, publicIP *oci.PublicIPInfo) (speedtest.Result, error) {
+	if err := b.assignQueued(ctx, client, publicIP.ID, account.AutoIPSpeedTestPrivateIP); err != nil {
+		return speedtest.Result{}, err
+	}
+	defer func() {
+		if err := b.assignQueued(context.WithoutCancel(ctx), client, publicIP.ID, ""); err != nil {
+			logger.Errorf("Failed to unbind %s from the speed test instance: %v", publicIP.IPAddress, err)
+		}
+	}()
+
+	logger.Infof("IP %s bound to the speed test instance. Testing %s...", publicIP.IPAddress, account.AutoIPSpeedTest)
+	return speedtest.Run(ctx, account.AutoIPSpeedTest, publicIP.IPAddress, speedtest.DefaultDuration)
+}
+
+// assignQueued binds a reserved IP to a private IP, or unbinds it when
+// privateIPID is "", in the account's queue
+func (b *Bot) assignQueued(ctx context.Context, client oci.Service, publicIPID, privateIPID string) error {
+	release, err := b.acquireAccount(ctx, 0, client.AccountName())
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(ctx, assignTimeout)
+	defer cancel()
+	return client.AssignReservedIP(ctx, publicIPID, privateIPID)
+}
+
+// speedWanted runs the account's speed test on a matching IP, notes the
+// outcome on score and applies autoip_min_mbps. An IP whose test couldn't
+// run is kept, as a failed lookup adds no penalty. Always true when the
+// account has no autoip_speedtest.
+func (b *Bot) speedWanted(ctx context.Context, client oci.Service, config *AutoApplyConfig, publicIP *oci.PublicIPInfo, score *ipScore) bool {
+	account := b.accountConfig(config.AccountName)
+	if account == nil || account.AutoIPSpeedTest == "" {
+		return true
+	}
+
+	result, err := b.runSpeedTest(ctx, client, account, publicIP)
+	ok := true
+	switch {
+	case err != nil:
+		logger.Warnf("Speed test of %s failed: %v", publicIP.IPAddress, err)
+		score.Speed = "失败"
+	default:
+		score.Speed = result.String()
+		ok = account.AutoIPMinMbps == 0 || result.Mbps >= float64(account.AutoIPMinMbps)
+		if !ok {
+			logger.Infof("IP %s is too slow (%s, want %d Mbps). Deleting...", publicIP.IPAddress, result, account.AutoIPMinMbps)
+		}
+	}
+	b.recordStage(config, stageSpeed, ok)
+	return ok
+}
+
+// speedMarkdown formats a speed test outcome as a line for IP check results,
+// "" when none ran
+func speedMarkdown(speed string) string {
+	if speed == "" {
+		return ""
+	}
+	return fmt.Sprintf("🚀 *测速:* %s", escapeMarkdown(speed))
+}
@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Backoff of the SSH readiness probe: cloud-init usually takes a minute or
+// two after RUNNING, so polling starts fast and settles at one try every 30s
+const (
+	sshProbeFirstDelay = 2 * time.Second
+	sshProbeMaxDelay   = 30 * time.Second
+	sshDialTimeout     = 5 * time.Second
+)
+
+// sshBanner connects to port 22 and reads the server's identification line,
+// which sshd sends as soon as it accepts a connection. A bare TCP connect
+// isn't enough: a port that accepts and then drops the connection is not
+// ready for a login.
+func sshBanner(ctx context.Context, ip string) (string, error) {
+	dialer := net.Dialer{Timeout: sshDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, "22"))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(sshDialTimeout))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "SSH-") {
+		return "", fmt.Errorf("unexpected banner %q", line)
+	}
+	return line, nil
+}
+
+// waitSSHReady probes port 22 of a launched instance with backoff until sshd
+// answers, then announces that the instance can be logged in to, with the
+// time since launched and the connection buttons. RUNNING alone is usually a
+// minute or more before sshd is up. Gives up after sshReadyTimeout with a
+// hint to check the security list. Returns the connection when sshd answered,
+// nil otherwise.
+func (b *Bot) waitSSHReady(parent context.Context, chatID int64, t topic, client oci.Service, instanceID string, launched time.Time) *connection {
+	ctx, cancel := context.WithTimeout(parent, sshReadyTimeout)
+	defer cancel()
+	accountName := client.AccountName()
+
+	var conn *connection
+	var lastErr error
+	delay := sshProbeFirstDelay
+	for {
+		if conn == nil {
+			found, err := b.instanceConnection(client, instanceID)
+			switch {
+			case errors.Is(err, errInstanceGone):
+				b.notify(chatID, t, fmt.Sprintf("❌ [%s] 实例不存在或已终止", accountName))
+				return nil
+			case err != nil:
+				lastErr = err
+			default:
+				conn = found
+			}
+		}
+		if conn != nil {
+			banner, err := sshBanner(ctx, conn.IP)
+			if err == nil {
+				logger.Infof("Instance %s ready for SSH at %s after %s: %s", instanceID, conn.IP, time.Since(launched).Round(time.Second), banner)
+				break
+			}
+			lastErr = err
+		}
+
+		logger.Debugf("Instance %s not ready for SSH yet: %v", instanceID, lastErr)
+		select {
+		case <-ctx.Done():
+			if parent.Err() != nil {
+				return nil // Stopped or shutting down
+			}
+			ip := ""
+			if conn != nil {
+				ip = conn.IP
+			}
+			b.sshNotReady(chatID, t, accountName, instanceID, ip, lastErr)
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, sshProbeMaxDelay)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ 实例就绪，可登录\n\n%s\n⏱ 启动用时: %s\n📍 [%s] %s",
+		conn.command(), time.Since(launched).Round(time.Second), accountName, client.Region()))
+	msg.ReplyMarkup = b.connectionButtons(accountName, instanceID)
+	b.deliver(t, msg, true)
+	return conn
+}
+
+// sshNotReady reports an instance whose sshd didn't answer in time, still
+// offering the connection buttons for a later try
+func (b *Bot) sshNotReady(chatID int64, t topic, accountName, instanceID, ip string, lastErr error) {
+	logger.Warnf("Instance %s not ready for SSH after %s: %v", instanceID, sshReadyTimeout, lastErr)
+	text := fmt.Sprintf("⚠️ [%s] 实例 %d 分钟内未分配公网IP，请稍后使用 /network 查看", accountName, int(sshReadyTimeout.Minutes()))
+	if ip != "" {
+		text = fmt.Sprintf("⚠️ [%s] %s 的 22 端口 %d 分钟内无响应\n\n实例可能仍在初始化，也请检查安全列表是否放行 TCP 22 端口",
+			accountName, ip, int(sshReadyTimeout.Minutes()))
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = b.connectionButtons(accountName, instanceID)
+	b.deliver(t, msg, true)
+}
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"fmt"
+
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// regionSubscriptions lists the regions the current account is subscribed to
+func (b *Bot) regionSubscriptions(client oci.Service) ([]oci.RegionSubscription, error) {
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+	return client.ListRegionSubscriptions(ctx)
+}
+
+// showRegionPicker lists the current account's subscribed regions as buttons
+func (b *Bot) showRegionPicker(chatID int64) {
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+
+	regions, err := b.regionSubscriptions(client)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, region := range regions {
+		label := region.Name
+		if region.IsHome {
+			label = "🏠 " + label
+		}
+		if region.Name == client.Region() {
+			label = "✅ " + label
+		}
+		if region.Status != "READY" {
+			label += " (订阅中)"
+		}
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(label, "rgn:"+region.Name),
+		})
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🌏 *[%s] 已订阅的区域*\n\n选择区域后 /listip、/newip 等命令将在该区域操作", escapeMarkdown(client.AccountName())))
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
+
+// switchRegion points the current account at another subscribed region
+func (b *Bot) switchRegion(chatID int64, region string) {
+	b.mu.Lock()
+	name := b.currentClient.AccountName()
+	b.mu.Unlock()
+	base, _ := b.client(name)
+
+	regions, err := b.regionSubscriptions(base)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+	subscribed := false
+	for _, r := range regions {
+		if r.Name == region && r.Status == "READY" {
+			subscribed = true
+		}
+	}
+	if !subscribed {
+		b.reply(chatID, fmt.Sprintf("❌ [%s] 未订阅区域: %s", name, region))
+		return
+	}
+
+	client := base
+	if region != base.Region() {
+		client = base.InRegion(region)
+	}
+	b.mu.Lock()
+	b.currentClient = client
+	b.mu.Unlock()
+
+	logger.Infof("[%s] Switched to region %s", name, region)
+	b.reply(chatID, fmt.Sprintf("✅ [%s] 已切换到区域 %s", name, region))
+	b.showIPList(chatID)
+}
+
+// handleRegion handles /region [name]
+func (b *Bot) handleRegion(chatID int64, args string) {
+	if args == "" {
+		b.showRegionPicker(chatID)
+		return
+	}
+	b.switchRegion(chatID, args)
+}
@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	minRestartBackoff = 5 * time.Second
+	maxRestartBackoff = 5 * time.Minute
+	// A task that ran this long before panicking restarts with the minimum backoff again
+	healthyRunDuration = 10 * time.Minute
+)
+
+// supervise runs a long-lived task and restarts it with exponential backoff
+// when it panics. It returns once the task returns normally or ctx is done.
+func (b *Bot) supervise(ctx context.Context, name string, task func(ctx context.Context)) {
+	backoff := minRestartBackoff
+	for {
+		started := time.Now()
+		if !b.runRecovered(name, func() { task(ctx) }) || ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) > healthyRunDuration {
+			backoff = minRestartBackoff
+		}
+		logger.Warnf("Restarting %s in %s", name, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxRestartBackoff {
+			backoff = maxRestartBackoff
+		}
+	}
+}
+
+// runRecovered runs fn, recovering a panic by logging its stack trace and
+// notifying the admin. Reports whether fn panicked.
+func (b *Bot) runRecovered(name string, fn func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			logger.Errorf("Panic in %s: %v\n%s", name, r, debug.Stack())
+			b.alert(fmt.Sprintf("💥 %s 发生异常: %v", name, r))
+		}
+	}()
+
+	fn()
+	return false
+}
@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"oci-bot/oci"
+)
+
+// matchesIPFilter reports whether /listip shows an IP: "bot" keeps IPs the
+// bot created, "key=value" (or "key") keeps IPs with that freeform tag
+func matchesIPFilter(ip oci.PublicIPInfo, filter string) bool {
+	if filter == "bot" {
+		return ip.Tags.IsManaged()
+	}
+	key, value, hasValue := strings.Cut(filter, "=")
+	actual, ok := ip.Tags.Freeform[key]
+	return ok && (!hasValue || actual == value)
+}
+
+// filterLabel describes a /listip filter in the list header
+func filterLabel(filter string) string {
+	if filter == "bot" {
+		return "bot创建"
+	}
+	return "标签 " + filter
+}
+
+// refreshFilter keeps the current filter on the refresh button ("1" means no filter)
+func refreshFilter(filter string) string {
+	if filter == "" {
+		return "1"
+	}
+	return filter
+}
+
+// handleTag shows or edits the tags of an IP or instance:
+// "/tag <IP|实例> k=v ns.k=v -k" sets freeform, sets defined, removes freeform
+func (b *Bot) handleTag(chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		b.reply(chatID, "用法: /tag <IP|实例> [key=value] [命名空间.key=value] [-key]")
+		return
+	}
+
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	name := fields[0]
+	var (
+		tags  oci.Tags
+		apply func(oci.Tags) error
+	)
+
+	ips, err := client.ListReservedIPs(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+	for _, ip := range ips {
+		if ip.IPAddress == name || ip.DisplayName == name {
+			tags = ip.Tags
+			apply = func(t oci.Tags) error { return client.SetReservedIPTags(ctx, ip.ID, t) }
+			break
+		}
+	}
+
+	if apply == nil {
+		instances, err := client.ListInstances(ctx)
+		if err != nil {
+			b.reply(chatID, errorText(err))
+			return
+		}
+		for _, inst := range instances {
+			if inst.DisplayName == name || inst.ID == name {
+				tags = inst.Tags
+				apply = func(t oci.Tags) error { return client.SetInstanceTags(ctx, inst.ID, t) }
+				break
+			}
+		}
+	}
+
+	if apply == nil {
+		b.reply(chatID, "❌ 未找到IP或实例: "+name)
+		return
+	}
+
+	if len(fields) == 1 {
+		b.reply(chatID, formatTags(name, tags))
+		return
+	}
+
+	update, err := editTags(tags, fields[1:])
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+	release, err := b.acquireAccount(b.runCtx, chatID, client.AccountName())
+	if err != nil {
+		return
+	}
+	err = apply(update)
+	release()
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	if update.Freeform != nil {
+		tags.Freeform = update.Freeform
+	}
+	if update.Defined != nil {
+		tags.Defined = update.Defined
+	}
+	b.reply(chatID, "✅ 标签已更新\n\n"+formatTags(name, tags))
+}
+
+// editTags applies k=v, ns.k=v and -k edits to a copy of the tags. Only the
+// maps that changed are returned non-nil, so untouched tags aren't rewritten.
+func editTags(current oci.Tags, edits []string) (oci.Tags, error) {
+	var result oci.Tags
+
+	for _, edit := range edits {
+		if key, ok := strings.CutPrefix(edit, "-"); ok {
+			if result.Freeform == nil {
+				result.Freeform = maps.Clone(current.Freeform)
+			}
+			delete(result.Freeform, key)
+			continue
+		}
+
+		key, value, ok := strings.Cut(edit, "=")
+		if !ok || key == "" {
+			return oci.Tags{}, fmt.Errorf("无效的标签: %s", edit)
+		}
+
+		if namespace, name, isDefined := strings.Cut(key, "."); isDefined {
+			if result.Defined == nil {
+				result.Defined = make(map[string]map[string]interface{})
+				for ns, tags := range current.Defined {
+					result.Defined[ns] = maps.Clone(tags)
+				}
+			}
+			if result.Defined[namespace] == nil {
+				result.Defined[namespace] = make(map[string]interface{})
+			}
+			result.Defined[namespace][name] = value
+			continue
+		}
+
+		if result.Freeform == nil {
+			result.Freeform = maps.Clone(current.Freeform)
+			if result.Freeform == nil {
+				result.Freeform = make(map[string]string)
+			}
+		}
+		result.Freeform[key] = value
+	}
+
+	return result, nil
+}
+
+// formatTags lists freeform and defined tags, sorted by key
+func formatTags(name string, tags oci.Tags) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🏷 %s\n", name))
+
+	if len(tags.Freeform) == 0 && len(tags.Defined) == 0 {
+		sb.WriteString("暂无标签")
+		return sb.String()
+	}
+
+	for _, key := range sortedKeys(tags.Freeform) {
+		sb.WriteString(fmt.Sprintf("• %s = %s\n", key, tags.Freeform[key]))
+	}
+	for _, ns := range sortedKeys(tags.Defined) {
+		for _, key := range sortedKeys(tags.Defined[ns]) {
+			sb.WriteString(fmt.Sprintf("• %s.%s = %v\n", ns, key, tags.Defined[ns][key]))
+		}
+	}
+	return sb.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
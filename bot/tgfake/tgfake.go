@@ -0,0 +1,143 @@
+// Package tgfake provides an in-memory Telegram transport so wizard and
+// callback flows can run end-to-end without a bot token.
+package tgfake
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+
+	"oci-bot/bot/platform"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Transport records everything the bot sends and feeds it injected updates
+type Transport struct {
+	updates *platform.Queue
+
+	mu       sync.Mutex
+	sent     []tgbotapi.Chattable
+	requests []tgbotapi.Chattable
+	nextID   int
+	notify   chan struct{}
+}
+
+// New returns a fake transport with a buffered update queue
+func New() *Transport {
+	return &Transport{
+		updates: platform.NewQueue(),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// Send records c and returns a message with an increasing ID
+func (t *Transport) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	t.mu.Lock()
+	t.sent = append(t.sent, c)
+	t.nextID++
+	id := t.nextID
+	t.mu.Unlock()
+
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+
+	msg := tgbotapi.Message{MessageID: id}
+	if m, ok := c.(tgbotapi.MessageConfig); ok {
+		msg.Text = m.Text
+		msg.Chat = &tgbotapi.Chat{ID: m.ChatID}
+	}
+	return msg, nil
+}
+
+// Request records c and returns a successful response
+func (t *Transport) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	t.mu.Lock()
+	t.requests = append(t.requests, c)
+	t.mu.Unlock()
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+// MakeRequest records sendMessage calls like Send, so messages sent into
+// forum topics show up in Sent and Texts. Other endpoints just succeed.
+func (t *Transport) MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error) {
+	if endpoint != "sendMessage" {
+		return &tgbotapi.APIResponse{Ok: true}, nil
+	}
+	chatID, _ := strconv.ParseInt(params["chat_id"], 10, 64)
+	msg := tgbotapi.NewMessage(chatID, params["text"])
+	msg.ParseMode = params["parse_mode"]
+
+	sent, _ := t.Send(msg)
+	result, err := json.Marshal(sent)
+	if err != nil {
+		return nil, err
+	}
+	return &tgbotapi.APIResponse{Ok: true, Result: result}, nil
+}
+
+// GetUpdates returns the updates injected by SendText and Click, waiting up
+// to config.Timeout seconds for one like Telegram's long polling
+func (t *Transport) GetUpdates(config tgbotapi.UpdateConfig) ([]tgbotapi.Update, error) {
+	return t.updates.Get(config)
+}
+
+// SendText injects a text message (or /command) from the given user
+func (t *Transport) SendText(fromID int64, text string) {
+	t.updates.Push(tgbotapi.Update{Message: platform.TextMessage(fromID, fromID, "", text)})
+}
+
+// Click injects an inline button press with the given callback data
+func (t *Transport) Click(fromID int64, data string) {
+	t.updates.Push(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      data,
+		From:    &tgbotapi.User{ID: fromID},
+		Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: fromID}},
+		Data:    data,
+	}})
+}
+
+// Sent returns everything passed to Send so far
+func (t *Transport) Sent() []tgbotapi.Chattable {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]tgbotapi.Chattable(nil), t.sent...)
+}
+
+// Texts returns the text of every message sent so far
+func (t *Transport) Texts() []string {
+	var texts []string
+	for _, c := range t.Sent() {
+		if m, ok := c.(tgbotapi.MessageConfig); ok {
+			texts = append(texts, m.Text)
+		}
+	}
+	return texts
+}
+
+// Requests returns everything passed to Request so far, such as edits and
+// callback answers
+func (t *Transport) Requests() []tgbotapi.Chattable {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]tgbotapi.Chattable(nil), t.requests...)
+}
+
+// Edits returns the new text of every message edit made so far
+func (t *Transport) Edits() []string {
+	var texts []string
+	for _, c := range t.Requests() {
+		if e, ok := c.(tgbotapi.EditMessageTextConfig); ok {
+			texts = append(texts, e.Text)
+		}
+	}
+	return texts
+}
+
+// Notify returns a channel that receives a value after each Send,
+// so callers can wait for asynchronous handlers
+func (t *Transport) Notify() <-chan struct{} {
+	return t.notify
+}
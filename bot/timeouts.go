@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"context"
+	"time"
+)
+
+// Time limits of the OCI and purity check calls made by the bot. Each one
+// runs under the run context as well, so shutdown cancels it early.
+const (
+	callTimeout        = 30 * time.Second // One or a few quick OCI calls
+	reportTimeout      = time.Minute      // Queries over many resources: metrics, usage, backups
+	batchTimeout       = 2 * time.Minute  // Several changes in a row
+	createTimeout      = time.Minute      // One try of creating a reserved IP
+	ipReadyTimeout     = time.Minute      // Waiting for a new reserved IP to become available
+	launchTimeout      = 3 * time.Minute  // One try of launching an instance, fallbacks included
+	rotateTimeout      = 5 * time.Minute  // Rotating an API key, propagation included
+	backupTimeout      = 10 * time.Minute // A scheduled backup of every boot volume
+	imageWaitTimeout   = 90 * time.Minute // Waiting for a custom image to become available
+	workRequestTimeout = 20 * time.Minute // Following a launch until the instance runs
+	sshReadyTimeout    = 10 * time.Minute // Waiting for sshd on a running instance
+	checkTimeout       = 30 * time.Second // A purity check asked for in chat
+	execTimeout        = 2 * time.Minute  // A command run on an instance with /exec
+	provisionTimeout   = 15 * time.Minute // A recipe installing software on an instance
+	autoCheckTimeout   = time.Minute      // A purity check by auto-apply, which may queue behind others
+	latencyTimeout     = 30 * time.Second // Pinging a new IP from every latency vantage point
+	routeTimeout       = time.Minute      // Tracing the route to a new IP from China
+	assignTimeout      = 2 * time.Minute  // Binding an IP to the speed test or a /deploy instance, or unbinding it
+	chromeTimeout      = 10 * time.Minute // Downloading chrome-headless-shell at startup
+)
+
+// withTimeout returns a context for a call started outside a background task:
+// it ends after d or when the bot shuts down.
+func (b *Bot) withTimeout(d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(b.runCtx, d)
+}
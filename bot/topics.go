@@ -0,0 +1,78 @@
+package bot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// topic is a kind of message that can be bound to a forum topic of forum_chat_id
+type topic int
+
+const (
+	topicNone   topic = iota // The chat itself
+	topicIPList              // IP lists
+	topicAuto                // Auto-apply and auto-VPS progress
+	topicAlerts              // Background alerts and reports
+)
+
+// threadFor returns the forum topic (message_thread_id) messages of kind t to
+// chatID belong in, or 0 for the chat itself.
+func (b *Bot) threadFor(chatID int64, t topic) int {
+	if b.cfg.ForumChatID == 0 || chatID != b.cfg.ForumChatID {
+		return 0
+	}
+	switch t {
+	case topicIPList:
+		return b.cfg.TopicIPList
+	case topicAuto:
+		return b.cfg.TopicAuto
+	case topicAlerts:
+		return b.cfg.TopicAlerts
+	}
+	return 0
+}
+
+// alertChatID is where background alerts go: the forum group when configured,
+// otherwise the admin's private chat.
+func (b *Bot) alertChatID() int64 {
+	if b.cfg.ForumChatID != 0 {
+		return b.cfg.ForumChatID
+	}
+	return b.adminID
+}
+
+// replyIn sends a plain text message into the topic bound to kind t
+func (b *Bot) replyIn(chatID int64, t topic, text string) {
+	b.sendIn(t, tgbotapi.NewMessage(chatID, text))
+}
+
+// replyMarkdownIn sends a Markdown message into the topic bound to kind t
+func (b *Bot) replyMarkdownIn(chatID int64, t topic, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.DisableWebPagePreview = true
+	b.sendIn(t, msg)
+}
+
+// notify sends a critical plain text notification into the topic bound to
+// kind t; if Telegram is unreachable it is delivered later from the outbox
+func (b *Bot) notify(chatID int64, t topic, text string) {
+	b.deliver(t, tgbotapi.NewMessage(chatID, text), true)
+}
+
+// notifyMarkdown is notify for a Markdown message
+func (b *Bot) notifyMarkdown(chatID int64, t topic, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.DisableWebPagePreview = true
+	b.deliver(t, msg, true)
+}
+
+// alert sends a background alert to the alerts topic
+func (b *Bot) alert(text string) {
+	b.notify(b.alertChatID(), topicAlerts, text)
+}
+
+// alertMarkdown sends a Markdown background alert to the alerts topic
+func (b *Bot) alertMarkdown(text string) {
+	b.notifyMarkdown(b.alertChatID(), topicAlerts, text)
+}
@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+
+	"oci-bot/bot/discord"
+	"oci-bot/bot/matrix"
+	"oci-bot/config"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Transport is the subset of the Telegram Bot API the bot talks to, and so
+// the interface to the chat platform. *tgbotapi.BotAPI is the Telegram
+// implementation; discord.Transport and matrix.Transport translate the calls
+// for those platforms, and tgfake.Transport records outgoing messages and
+// injects updates for tests.
+type Transport interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error)
+	GetUpdates(config tgbotapi.UpdateConfig) ([]tgbotapi.Update, error)
+}
+
+var (
+	_ Transport = (*tgbotapi.BotAPI)(nil)
+	_ Transport = (*discord.Transport)(nil)
+	_ Transport = (*matrix.Transport)(nil)
+)
+
+// limitedTransport is a transport with a shorter message limit than Telegram's
+type limitedTransport interface {
+	MessageLimit() int
+}
+
+// followingTransport is a transport that receives updates in the background
+// once the bot runs, until its context is done
+type followingTransport interface {
+	Run(ctx context.Context)
+}
+
+// fileDownloader is a transport whose files aren't downloaded from a Bot API
+// server
+type fileDownloader interface {
+	DownloadFile(ctx context.Context, fileID string, limit int) ([]byte, error)
+}
+
+// messageLimit is the length messages are split at
+func (b *Bot) messageLimit() int {
+	if t, ok := b.api.(limitedTransport); ok {
+		return t.MessageLimit()
+	}
+	return maxMessageLength
+}
+
+// newTransport connects to the chat platform the config selects
+func newTransport(cfg *config.Config) (Transport, error) {
+	var proxy *url.URL
+	if cfg.Proxy != "" {
+		var err error
+		if proxy, err = url.Parse(cfg.Proxy); err != nil {
+			return nil, err
+		}
+	}
+
+	switch cfg.Platform {
+	case config.PlatformDiscord:
+		t, err := discord.New(discord.Options{Token: cfg.DiscordToken, GuildID: cfg.DiscordGuildID, Proxy: proxy})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Discord bot: %w", err)
+		}
+		logger.Infof("Discord bot logged in: %s", t.UserName())
+		return t, nil
+	case config.PlatformMatrix:
+		t, err := matrix.New(matrix.Options{
+			Homeserver:  cfg.MatrixHomeserver,
+			AccessToken: cfg.MatrixAccessToken,
+			Admin:       cfg.MatrixAdmin,
+			KeyFile:     filepath.Join(filepath.Dir(cfg.StateFile), "oci-bot-matrix.key"),
+			Proxy:       proxy,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Matrix bot: %w", err)
+		}
+		// Access checks compare chat IDs, which Matrix doesn't have
+		cfg.TelegramAdminID = t.ChatID(cfg.MatrixAdmin)
+		logger.Infof("Matrix bot logged in: %s", t.UserName())
+		return t, nil
+	}
+
+	api, err := newTelegramAPI(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Telegram bot: %w", err)
+	}
+	logger.Infof("Telegram bot authorized: @%s", api.Self.UserName)
+	if cfg.APIEndpoint != "" {
+		logger.Infof("Using Bot API server %s", cfg.APIEndpoint)
+	}
+	return api, nil
+}
+
+// newTelegramAPI logs in to the Bot API server at api_endpoint, going through
+// proxy when one is set
+func newTelegramAPI(cfg *config.Config) (*tgbotapi.BotAPI, error) {
+	client, err := telegramHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := tgbotapi.APIEndpoint
+	if cfg.APIEndpoint != "" {
+		endpoint = cfg.APIEndpoint + "/bot%s/%s"
+	}
+	return tgbotapi.NewBotAPIWithClient(cfg.TelegramToken, endpoint, client)
+}
+
+// telegramHTTPClient returns a client for the Bot API server, using proxy
+// when one is set
+func telegramHTTPClient(cfg *config.Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// fileLink returns the download URL of a file on the Bot API server
+func fileLink(cfg *config.Config, file tgbotapi.File) string {
+	if cfg.APIEndpoint == "" {
+		return file.Link(cfg.TelegramToken)
+	}
+	return cfg.APIEndpoint + "/file/bot" + cfg.TelegramToken + "/" + file.FilePath
+}
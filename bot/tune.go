@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	tunePurityStep   = 5  // Points per /tune threshold button
+	tuneIntervalStep = 30 // Seconds per /tune interval button
+	minAutoInterval  = 10 // Shortest interval the wizard accepts, in seconds
+)
+
+// showTune shows the criteria of the running auto-apply task with buttons
+// changing them in place
+func (b *Bot) showTune(chatID int64) {
+	text, markup, ok := b.tunePanel()
+	if !ok {
+		b.reply(chatID, "⚠️ 当前没有运行中的自动刷IP任务")
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = markup
+	b.sendIn(topicAuto, msg)
+}
+
+// tunePanel renders /tune for the running auto-apply task, false when there
+// is none. Buttons carry the resulting values, and "panel" so that pressing
+// one refreshes the message.
+func (b *Bot) tunePanel() (string, tgbotapi.InlineKeyboardMarkup, bool) {
+	b.mu.Lock()
+	config := b.autoApply
+	if config == nil || !config.Active {
+		b.mu.Unlock()
+		return "", tgbotapi.InlineKeyboardMarkup{}, false
+	}
+	criteria := *config
+	b.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🎛 *调整自动刷IP* [%s]\n\n", escapeMarkdown(criteria.AccountName)))
+	sb.WriteString("条件: " + escapeMarkdown(describeCriteria(&criteria)) + "\n")
+	adaptive := criteria.Pace.Adaptive()
+	if adaptive {
+		sb.WriteString("间隔: 自适应 (auto\\_adaptive)\n")
+	} else {
+		sb.WriteString("间隔: " + intervalText(criteria.IntervalMin, criteria.IntervalMax) + "\n")
+	}
+	sb.WriteString("\n修改立即生效，不必重启任务")
+
+	threshold := criteria.PurityThreshold
+	rows := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("纯净度 -%d", tunePurityStep),
+			fmt.Sprintf("tune:purity:%d:panel", max(threshold-tunePurityStep, 0))),
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("纯净度 +%d", tunePurityStep),
+			fmt.Sprintf("tune:purity:%d:panel", min(threshold+tunePurityStep, 100))),
+	)}
+	if !adaptive {
+		shorter := max(criteria.IntervalMin-tuneIntervalStep, minAutoInterval) - criteria.IntervalMin
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("间隔 -%d秒", tuneIntervalStep),
+				fmt.Sprintf("tune:interval:%d-%d:panel", criteria.IntervalMin+shorter, criteria.IntervalMax+shorter)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("间隔 +%d秒", tuneIntervalStep),
+				fmt.Sprintf("tune:interval:%d-%d:panel", criteria.IntervalMin+tuneIntervalStep, criteria.IntervalMax+tuneIntervalStep)),
+		))
+	}
+	return sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...), true
+}
+
+// saveTunedCriteria checkpoints changed criteria into the job running the
+// task, so that it resumes with them after a restart
+func (b *Bot) saveTunedCriteria(account string, profile autoIPProfile) {
+	b.jobs.Update(jobAutoIP, func(data any) {
+		if job := data.(*autoIPJob); job.Account == account {
+			job.Criteria = profile
+		}
+	})
+	b.jobs.Update(jobDeploy, func(data any) {
+		if d := data.(*deployment); d.Account == account {
+			d.Criteria = profile
+		}
+	})
+}
+
+// intervalText formats a wizard interval in seconds
+func intervalText(intervalMin, intervalMax int) string {
+	if intervalMax > intervalMin {
+		return fmt.Sprintf("%d-%d秒", intervalMin, intervalMax)
+	}
+	return fmt.Sprintf("%d秒", intervalMin)
+}
+
+// handleTuneCallback changes a criterion of the running auto-apply task; it
+// applies from the next candidate on. Buttons of /tune refresh its message,
+// the others are answered with a confirmation.
+func (b *Bot) handleTuneCallback(chatID int64, messageID int, action string, parts []string) {
+	if len(parts) < 3 {
+		return
+	}
+	value := parts[2]
+	panel := len(parts) > 3 && parts[3] == "panel"
+
+	b.mu.Lock()
+	config := b.autoApply
+	if config == nil || !config.Active {
+		b.mu.Unlock()
+		b.reply(chatID, "⚠️ 当前没有运行中的自动刷IP任务")
+		return
+	}
+	var text string
+	switch action {
+	case "purity":
+		threshold, err := strconv.Atoi(value)
+		if err != nil || threshold < 0 || threshold > 100 {
+			b.mu.Unlock()
+			return
+		}
+		config.PurityThreshold = threshold
+		text = fmt.Sprintf("✅ 纯净度阈值已改为 %d", threshold)
+	case "native":
+		config.NativeRequired = "any"
+		text = "✅ 已不限IP来源"
+	case "type":
+		config.TypeRequired = "any"
+		text = "✅ 已不限IP类型"
+	case "interval":
+		minText, maxText, _ := strings.Cut(value, "-")
+		intervalMin, err1 := strconv.Atoi(minText)
+		intervalMax, err2 := strconv.Atoi(maxText)
+		if err1 != nil || err2 != nil || intervalMin < minAutoInterval || intervalMax < intervalMin {
+			b.mu.Unlock()
+			return
+		}
+		config.IntervalMin, config.IntervalMax = intervalMin, intervalMax
+		config.Pace.SetInterval(intervalMin, intervalMax)
+		text = "✅ 间隔已改为 " + intervalText(intervalMin, intervalMax)
+	default:
+		b.mu.Unlock()
+		return
+	}
+	// A better miss than the last one reported is reported again under the
+	// new criteria
+	config.NearMiss = nil
+	criteria := describeCriteria(config)
+	account, profile := config.AccountName, profileOf(config)
+	b.mu.Unlock()
+
+	logger.Infof("Auto-apply criteria changed: %s (%s %s)", criteria, action, value)
+	b.saveTunedCriteria(account, profile)
+	if !panel {
+		b.replyIn(chatID, topicAuto, text+"\n条件: "+criteria)
+		return
+	}
+	panelText, markup, ok := b.tunePanel()
+	if !ok {
+		return
+	}
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, panelText, markup)
+	edit.ParseMode = tgbotapi.ModeMarkdown
+	b.edit(chatID, edit)
+}
@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	"oci-bot/ippure"
+	"oci-bot/oci"
+)
+
+// verifyMatch checks a matching IP again auto_verify_seconds later, bypassing
+// the check cache, as ippure scores of one IP fluctuate between checks. The
+// second result replaces info and score and decides whether the IP still
+// matches. A failed second check is returned as an error, to be handled like
+// any failed check. Without auto_verify_seconds, or for results made without
+// a browser, the IP matches as it is.
+func (b *Bot) verifyMatch(ctx context.Context, config *AutoApplyConfig, publicIP *oci.PublicIPInfo, penalties ipScore, info *ippure.IPInfo, score ipScore) (*ippure.IPInfo, ipScore, bool, error) {
+	delay := time.Duration(b.currentConfig().AutoVerifySeconds) * time.Second
+	if delay == 0 || !info.Available() {
+		return info, score, true, nil
+	}
+
+	logger.Infof("IP %s matches (score %s). Checking again in %s to confirm...", publicIP.IPAddress, score, delay)
+	select {
+	case <-ctx.Done():
+		return nil, score, false, ctx.Err()
+	case <-time.After(delay):
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, autoCheckTimeout)
+	second, err := b.checker.Fresh(checkCtx, publicIP.IPAddress)
+	cancel()
+	if err != nil {
+		return nil, score, false, err
+	}
+
+	score = penalties.withPurity(second)
+	matched := b.checkIPMatch(second, score, config)
+	b.recordStage(config, stageVerify, matched)
+	if !matched {
+		logger.Infof("IP %s no longer matches on the second check (purity %s → %s, score %s)",
+			publicIP.IPAddress, info.PurityScore, second.PurityScore, score)
+	}
+	return second, score, matched, nil
+}
@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"oci-bot/config"
+	"oci-bot/oci"
+	"oci-bot/schedule"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// autoBackupPrefix marks backups created by the scheduler; only these are pruned
+const autoBackupPrefix = "oci-bot-auto-"
+
+// startBackupSchedules starts a backup scheduler for every account that configures backup_schedule
+func (b *Bot) startBackupSchedules(ctx context.Context) {
+	for i := range b.cfg.Accounts {
+		account := &b.cfg.Accounts[i]
+		client, ok := b.client(account.Name)
+		if !ok || account.BackupSchedule == "" {
+			continue
+		}
+		// Already validated by config.Validate
+		cron, err := schedule.Parse(account.BackupSchedule)
+		if err != nil {
+			logger.Errorf("[%s] Invalid backup_schedule: %v", account.Name, err)
+			continue
+		}
+		go b.supervise(ctx, "backup schedule ["+account.Name+"]", func(ctx context.Context) {
+			b.runBackupSchedule(ctx, client, account, cron)
+		})
+	}
+}
+
+// runBackupSchedule backs up all boot volumes of the account at every cron tick
+func (b *Bot) runBackupSchedule(ctx context.Context, client oci.Service, account *config.OCIAccount, cron *schedule.Cron) {
+	logger.Infof("[%s] Backup schedule started (%s, keep %d)", account.Name, account.BackupSchedule, account.BackupRetention)
+
+	for {
+		next := cron.Next(time.Now())
+		if next.IsZero() {
+			logger.Errorf("[%s] backup_schedule never fires", account.Name)
+			return
+		}
+		logger.Debugf("[%s] Next scheduled backup at %s", account.Name, next.Format(time.RFC3339))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		if _, ok := b.client(account.Name); ok {
+			b.scheduledBackup(ctx, client, account)
+		}
+	}
+}
+
+// scheduledBackup creates a backup of every boot volume, prunes old automatic
+// backups beyond the retention count and reports the result
+func (b *Bot) scheduledBackup(ctx context.Context, client oci.Service, account *config.OCIAccount) {
+	callCtx, cancel := context.WithTimeout(ctx, backupTimeout)
+	defer cancel()
+
+	volumes, err := client.ListBootVolumes(callCtx)
+	if err != nil {
+		logger.Errorf("[%s] Scheduled backup failed: %v", account.Name, err)
+		b.alert(fmt.Sprintf("❌ [%s] 定时备份失败: %s", account.Name, describeError(err)))
+		return
+	}
+
+	release, err := b.acquireAccount(ctx, 0, account.Name)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("💾 [%s] 定时备份\n\n", account.Name))
+	failed := 0
+	stamp := time.Now().Format("20060102-1504")
+
+	for _, vol := range volumes {
+		if vol.State != "AVAILABLE" {
+			continue
+		}
+
+		name := fmt.Sprintf("%s%s-%s", autoBackupPrefix, vol.DisplayName, stamp)
+		if _, err := client.CreateBootVolumeBackup(callCtx, vol.ID, name); err != nil {
+			failed++
+			logger.Errorf("[%s] Backup of %s failed: %v", account.Name, vol.DisplayName, err)
+			sb.WriteString(fmt.Sprintf("❌ %s: %s\n", vol.DisplayName, describeError(err)))
+			continue
+		}
+
+		pruned, err := b.pruneBackups(callCtx, client, vol.ID, account.BackupRetention)
+		if err != nil {
+			logger.Warnf("[%s] Pruning backups of %s failed: %v", account.Name, vol.DisplayName, err)
+		}
+		line := fmt.Sprintf("✅ %s", vol.DisplayName)
+		if pruned > 0 {
+			line += fmt.Sprintf(" (清理旧备份 %d 个)", pruned)
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	if failed > 0 {
+		logger.Warnf("[%s] Scheduled backup finished with %d failures", account.Name, failed)
+	} else {
+		logger.Infof("[%s] Scheduled backup finished", account.Name)
+	}
+	b.alert(sb.String())
+}
+
+// pruneBackups deletes automatic backups of a volume beyond the newest keep
+func (b *Bot) pruneBackups(ctx context.Context, client oci.Service, volumeID string, keep int) (int, error) {
+	backups, err := client.ListBootVolumeBackups(ctx, volumeID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Newest first; manual and policy backups are left alone
+	kept, pruned := 0, 0
+	for _, backup := range backups {
+		if !strings.HasPrefix(backup.DisplayName, autoBackupPrefix) {
+			continue
+		}
+		kept++
+		if kept <= keep {
+			continue
+		}
+		if err := client.DeleteBootVolumeBackup(ctx, backup.ID); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// showVolumeBackups lists boot volumes of the current account with their backup policy
+func (b *Bot) showVolumeBackups(chatID int64) {
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+
+	ctx, cancel := b.withTimeout(reportTimeout)
+	defer cancel()
+
+	volumes, err := client.ListBootVolumes(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+	if len(volumes) == 0 {
+		b.reply(chatID, fmt.Sprintf("📋 [%s] 暂无引导卷", client.AccountName()))
+		return
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, vol := range volumes {
+		policy := "无策略"
+		if p, err := client.GetBackupPolicy(ctx, vol.ID); err != nil {
+			policy = "策略未知"
+		} else if p != nil {
+			policy = p.DisplayName
+		}
+		label := fmt.Sprintf("%s (%d GB, %s)", vol.DisplayName, vol.SizeGB, policy)
+		btn := tgbotapi.NewInlineKeyboardButtonData(label, "vbk:"+b.callbackRef(vol.ID))
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{btn})
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("💾 *[%s] 引导卷备份*", client.AccountName()))
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
+
+// handleVolumeBackupCallback handles vbk:<volume>[:now|:<policy>|:none]
+func (b *Bot) handleVolumeBackupCallback(chatID int64, volRef string, parts []string) {
+	volumeID, ok := b.resolveRef(volRef)
+	if !ok {
+		b.reply(chatID, "⚠️ 按钮已过期，请重新使用 /volbackup")
+		return
+	}
+
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+
+	if len(parts) < 3 {
+		b.showVolumeBackupMenu(chatID, client, volumeID)
+		return
+	}
+
+	ctx, cancel := b.withTimeout(reportTimeout)
+	defer cancel()
+
+	release, err := b.acquireAccount(b.runCtx, chatID, client.AccountName())
+	if err != nil {
+		return
+	}
+	defer release()
+
+	switch parts[2] {
+	case "now":
+		name := fmt.Sprintf("backup-%d", time.Now().Unix())
+		if _, err := client.CreateBootVolumeBackup(ctx, volumeID, name); err != nil {
+			b.reply(chatID, errorText(err))
+			return
+		}
+		b.reply(chatID, fmt.Sprintf("⏳ [%s] 已开始备份: %s", client.AccountName(), name))
+	case "none":
+		if err := client.SetBackupPolicy(ctx, volumeID, ""); err != nil {
+			b.reply(chatID, errorText(err))
+			return
+		}
+		b.reply(chatID, "✅ 已移除备份策略")
+	default:
+		policyID, ok := b.resolveRef(parts[2])
+		if !ok {
+			b.reply(chatID, "⚠️ 按钮已过期，请重新使用 /volbackup")
+			return
+		}
+		if err := client.SetBackupPolicy(ctx, volumeID, policyID); err != nil {
+			b.reply(chatID, errorText(err))
+			return
+		}
+		b.reply(chatID, "✅ 备份策略已更新")
+	}
+}
+
+// showVolumeBackupMenu shows recent backups of a volume and the policy choices
+func (b *Bot) showVolumeBackupMenu(chatID int64, client oci.Service, volumeID string) {
+	ctx, cancel := b.withTimeout(reportTimeout)
+	defer cancel()
+
+	backups, err := client.ListBootVolumeBackups(ctx, volumeID)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+	policies, err := client.ListBackupPolicies(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("💾 [%s] 最近备份\n\n", client.AccountName()))
+	if len(backups) == 0 {
+		sb.WriteString("暂无备份\n")
+	}
+	for i, backup := range backups {
+		if i >= 5 {
+			sb.WriteString(fmt.Sprintf("... 共 %d 个\n", len(backups)))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("• %s (%s, %s)\n", escapeMarkdown(backup.DisplayName), backup.State, backup.TimeCreated.Format("2006-01-02 15:04")))
+	}
+	sb.WriteString("\n选择备份策略或立即备份:")
+
+	volRef := b.callbackRef(volumeID)
+	buttons := [][]tgbotapi.InlineKeyboardButton{
+		{tgbotapi.NewInlineKeyboardButtonData("📸 立即备份", "vbk:"+volRef+":now")},
+	}
+	for _, policy := range policies {
+		data := "vbk:" + volRef + ":" + b.callbackRef(policy.ID)
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("📅 "+policy.DisplayName, data)})
+	}
+	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("🚫 不使用策略", "vbk:"+volRef+":none")})
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
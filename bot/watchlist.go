@@ -0,0 +1,296 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"oci-bot/ippure"
+	"oci-bot/state"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	watchlistKey     = "watchlist" // State section holding the watched external IPs
+	watchAlertPoints = 10          // Alert when a watched IP's purity score rises this much
+)
+
+// watchedIP is an address outside the OCI accounts (e.g. another provider's
+// VPS) whose purity is re-checked on a schedule
+type watchedIP struct {
+	Note        string    `json:"note,omitempty"`
+	Added       time.Time `json:"added"`
+	Checked     time.Time `json:"checked,omitempty"` // Last successful check
+	PurityScore string    `json:"purity_score,omitempty"`
+	PurityLevel string    `json:"purity_level,omitempty"`
+	IPType      string    `json:"ip_type,omitempty"`
+	IsNative    string    `json:"is_native,omitempty"`
+	LastError   string    `json:"last_error,omitempty"` // Error of the last check, cleared on success
+}
+
+// loadWatchlist reads the watched IPs from the store
+func loadWatchlist(store *state.Store) (map[string]*watchedIP, error) {
+	watched := make(map[string]*watchedIP)
+	if err := store.Get(watchlistKey, &watched); err != nil {
+		return nil, err
+	}
+	return watched, nil
+}
+
+// saveWatchlistLocked persists the watchlist. Caller must hold b.mu.
+func (b *Bot) saveWatchlistLocked() {
+	if err := b.store.Set(watchlistKey, b.watchlist); err != nil {
+		logger.Errorf("Failed to save watchlist: %v", err)
+	}
+}
+
+// handleWatch adds an IP to the watchlist and checks it, or lists the
+// watchlist when no address is given
+func (b *Bot) handleWatch(chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		b.showWatchlist(chatID)
+		return
+	}
+	ipAddr := fields[0]
+	if net.ParseIP(ipAddr) == nil {
+		b.reply(chatID, "❌ 无效的IP地址: "+ipAddr)
+		return
+	}
+	note := strings.Join(fields[1:], " ")
+
+	b.mu.Lock()
+	w, exists := b.watchlist[ipAddr]
+	if !exists {
+		w = &watchedIP{Added: time.Now()}
+		b.watchlist[ipAddr] = w
+	}
+	if note != "" || !exists {
+		w.Note = note
+	}
+	b.saveWatchlistLocked()
+	b.mu.Unlock()
+
+	if exists {
+		b.reply(chatID, fmt.Sprintf("👁 已在关注列表中: %s，重新检测...", ipAddr))
+	} else {
+		b.reply(chatID, fmt.Sprintf("👁 已关注 %s，正在检测纯净度...", ipAddr))
+	}
+
+	ctx, cancel := b.withTimeout(checkTimeout)
+	defer cancel()
+	info, err := b.checker.Fresh(ctx, ipAddr)
+	b.recordWatchCheck(ipAddr, info, err)
+	if err != nil {
+		b.reply(chatID, "❌ 检测失败: "+err.Error())
+		return
+	}
+	b.replyMarkdown(chatID, fmt.Sprintf("✅ `%s`\n📊 纯净度: %s (%s)\n🏢 类型: %s\n🌐 来源: %s",
+		ipAddr, info.PurityScore, info.PurityLevel, info.IPType, info.IsNative))
+}
+
+// handleUnwatch removes an IP from the watchlist
+func (b *Bot) handleUnwatch(chatID int64, ipAddr string) {
+	if ipAddr == "" {
+		b.reply(chatID, "用法: /unwatch <IP>")
+		return
+	}
+
+	b.mu.Lock()
+	_, ok := b.watchlist[ipAddr]
+	if ok {
+		delete(b.watchlist, ipAddr)
+		b.saveWatchlistLocked()
+	}
+	b.mu.Unlock()
+
+	if !ok {
+		b.reply(chatID, "⚠️ 未关注: "+ipAddr)
+		return
+	}
+	b.reply(chatID, "✅ 已取消关注: "+ipAddr)
+}
+
+// handleWatchCallback handles the watchlist buttons
+func (b *Bot) handleWatchCallback(chatID int64, action string, parts []string) {
+	switch action {
+	case "del":
+		// IPv6 addresses contain the separator
+		b.handleUnwatch(chatID, strings.Join(parts[2:], ":"))
+	case "check":
+		b.reply(chatID, "🔍 正在重新检测所有关注的IP...")
+		ctx, cancel := b.withTimeout(time.Duration(b.watchCount()+1) * autoCheckTimeout)
+		defer cancel()
+		b.checkWatchlist(ctx, false)
+		b.showWatchlist(chatID)
+	}
+}
+
+// watchCount returns the number of watched IPs
+func (b *Bot) watchCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.watchlist)
+}
+
+// sortedWatchlist returns copies of the watched IPs by address
+func (b *Bot) sortedWatchlist() ([]string, map[string]watchedIP) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	addrs := make([]string, 0, len(b.watchlist))
+	entries := make(map[string]watchedIP, len(b.watchlist))
+	for addr, w := range b.watchlist {
+		addrs = append(addrs, addr)
+		entries[addr] = *w
+	}
+	sort.Strings(addrs)
+	return addrs, entries
+}
+
+// watchLine formats one watched IP for the watchlist and /status
+func watchLine(addr string, w watchedIP) string {
+	line := fmt.Sprintf("• `%s`", addr)
+	if w.Note != "" {
+		line += " " + escapeMarkdown(w.Note)
+	}
+	if w.Checked.IsZero() {
+		line += "\n  未检测"
+	} else {
+		line += fmt.Sprintf("\n  %s %s · %s · %s (%s)", w.PurityScore, w.PurityLevel, w.IPType, w.IsNative, w.Checked.Format("01-02 15:04"))
+	}
+	if w.LastError != "" {
+		line += "\n  ⚠️ 最近检测失败: " + escapeMarkdown(w.LastError)
+	}
+	return line + "\n"
+}
+
+// showWatchlist lists the watched IPs with their last check results
+func (b *Bot) showWatchlist(chatID int64) {
+	addrs, entries := b.sortedWatchlist()
+	if len(addrs) == 0 {
+		b.reply(chatID, "👁 暂无关注的IP\n用法: /watch <IP> [备注]，可关注其他服务商的 VPS 等外部地址")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("👁 *关注的外部IP*\n\n")
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, addr := range addrs {
+		sb.WriteString(watchLine(addr, entries[addr]))
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData("🗑 取消关注 "+addr, "watch:del:"+addr),
+		})
+	}
+	if b.cfg.WatchCheckHours > 0 {
+		sb.WriteString(fmt.Sprintf("\n每 %d 小时自动检测，纯净度变差或类型变化时提醒", b.cfg.WatchCheckHours))
+	}
+	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("🔍 全部重新检测", "watch:check:"),
+	})
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	b.send(msg)
+}
+
+// recordWatchCheck stores a check result of a watched IP and returns the
+// previous result, zero when there was none
+func (b *Bot) recordWatchCheck(ipAddr string, info *ippure.IPInfo, err error) watchedIP {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	w, ok := b.watchlist[ipAddr]
+	if !ok {
+		return watchedIP{} // Removed while being checked
+	}
+	previous := *w
+	if err == nil && !info.Available() {
+		return previous // Nothing learned without a browser
+	}
+	if err != nil {
+		w.LastError = err.Error()
+	} else {
+		w.Checked = time.Now()
+		w.PurityScore = info.PurityScore
+		w.PurityLevel = info.PurityLevel
+		w.IPType = info.IPType
+		w.IsNative = info.IsNative
+		w.LastError = ""
+	}
+	b.saveWatchlistLocked()
+	return previous
+}
+
+// runWatchMonitor re-checks the watched IPs every watch_check_hours
+func (b *Bot) runWatchMonitor(ctx context.Context) {
+	interval := time.Duration(b.cfg.WatchCheckHours) * time.Hour
+	logger.Infof("Watchlist monitor started (every %s)", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.checkWatchlist(ctx, true)
+		}
+	}
+}
+
+// checkWatchlist re-checks every watched IP one after another. With alert set,
+// IPs whose purity got worse by watchAlertPoints or whose type or origin
+// changed are reported in one alert.
+func (b *Bot) checkWatchlist(ctx context.Context, alert bool) {
+	addrs, _ := b.sortedWatchlist()
+	var changes []string
+
+	for _, addr := range addrs {
+		if ctx.Err() != nil {
+			return
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, autoCheckTimeout)
+		info, err := b.checker.Fresh(checkCtx, addr)
+		cancel()
+		previous := b.recordWatchCheck(addr, info, err)
+		if err != nil {
+			logger.Warnf("Watchlist check of %s failed: %v", addr, err)
+			continue
+		}
+		if previous.Checked.IsZero() || !info.Available() {
+			continue
+		}
+
+		var diffs []string
+		before := purityValue(&ippure.IPInfo{PurityScore: previous.PurityScore})
+		if after := purityValue(info); after-before >= watchAlertPoints {
+			diffs = append(diffs, fmt.Sprintf("纯净度 %s → %s", previous.PurityScore, info.PurityScore))
+		}
+		// Earlier checks may have kept the page's English wording
+		if ippure.NormalizeType(previous.IPType) != info.IPType {
+			diffs = append(diffs, fmt.Sprintf("类型 %s → %s", previous.IPType, info.IPType))
+		}
+		if ippure.NormalizeOrigin(previous.IsNative) != info.IsNative {
+			diffs = append(diffs, fmt.Sprintf("来源 %s → %s", previous.IsNative, info.IsNative))
+		}
+		if len(diffs) > 0 {
+			label := fmt.Sprintf("`%s`", addr)
+			if previous.Note != "" {
+				label += " " + escapeMarkdown(previous.Note)
+			}
+			changes = append(changes, "• "+label+"\n  "+strings.Join(diffs, "，"))
+		}
+	}
+
+	if alert && len(changes) > 0 {
+		logger.Warnf("%d watched IP(s) changed", len(changes))
+		b.alertMarkdown("👁 *关注的IP有变化*\n\n" + strings.Join(changes, "\n"))
+	}
+}
@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxUpdateSize bounds a webhook request body, updates are a few KB
+const maxUpdateSize = 4 << 20
+
+// startUpdates returns the update channel: webhook mode when webhook_url is
+// configured, long polling otherwise.
+func (b *Bot) startUpdates(ctx context.Context) (tgbotapi.UpdatesChannel, error) {
+	if t, ok := b.api.(followingTransport); ok {
+		go t.Run(ctx)
+	}
+	if b.cfg.WebhookURL == "" {
+		// A leftover webhook makes getUpdates fail, so clear it first
+		if _, err := b.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			logger.Warnf("Failed to delete webhook: %v", err)
+		}
+
+		logger.Infof("Using long polling for updates")
+		return b.startPolling(ctx), nil
+	}
+
+	return b.startWebhook(ctx)
+}
+
+// startWebhook registers the webhook with Telegram and serves it on webhook_listen
+func (b *Bot) startWebhook(ctx context.Context) (tgbotapi.UpdatesChannel, error) {
+	link, err := url.Parse(b.cfg.WebhookURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook_url: %w", err)
+	}
+
+	// Telegram sends the secret back with every update, telling them apart
+	// from requests forged by anyone who learns the URL
+	secret := b.cfg.WebhookSecret
+	if secret == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(raw)
+	}
+	// tgbotapi's WebhookConfig has no secret_token, so the call is made by hand
+	params := tgbotapi.Params{"url": link.String(), "secret_token": secret}
+	if b.cfg.WebhookSelfSigned && b.cfg.WebhookCert != "" {
+		uploader, ok := b.api.(fileUploader)
+		if !ok {
+			return nil, fmt.Errorf("webhook_self_signed is not supported by this transport")
+		}
+		_, err = uploader.UploadFiles("setWebhook", params,
+			[]tgbotapi.RequestFile{{Name: "certificate", Data: tgbotapi.FilePath(b.cfg.WebhookCert)}})
+	} else {
+		_, err = b.api.MakeRequest("setWebhook", params)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set webhook: %w", withoutURL(err))
+	}
+
+	path := link.Path
+	if path == "" {
+		path = "/"
+	}
+
+	updates := make(chan tgbotapi.Update, 100)
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			logger.Warnf("Rejected webhook request from %s: wrong secret token", r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxUpdateSize)
+		update, err := decodeUpdate(r)
+		if err != nil {
+			errMsg, _ := json.Marshal(map[string]string{"error": err.Error()})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(errMsg)
+			return
+		}
+		select {
+		case updates <- update:
+		case <-r.Context().Done():
+			// Telegram sends the update again
+		case <-ctx.Done():
+		}
+	})
+
+	server := &http.Server{
+		Addr:              b.cfg.WebhookListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		var err error
+		if b.cfg.WebhookCert != "" && b.cfg.WebhookKey != "" {
+			logger.Infof("Webhook listening on %s%s (TLS)", server.Addr, path)
+			err = server.ListenAndServeTLS(b.cfg.WebhookCert, b.cfg.WebhookKey)
+		} else {
+			logger.Infof("Webhook listening on %s%s", server.Addr, path)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logger.Errorf("Webhook server error: %v", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	return updates, nil
+}
+
+// fileUploader is a transport that can upload files with a raw API call, as
+// *tgbotapi.BotAPI does
+type fileUploader interface {
+	UploadFiles(endpoint string, params tgbotapi.Params, files []tgbotapi.RequestFile) (*tgbotapi.APIResponse, error)
+}
+
+// decodeUpdate parses an update posted by Telegram to the webhook
+func decodeUpdate(r *http.Request) (tgbotapi.Update, error) {
+	var update tgbotapi.Update
+	if r.Method != http.MethodPost {
+		return update, fmt.Errorf("wrong HTTP method, POST required")
+	}
+	err := json.NewDecoder(r.Body).Decode(&update)
+	return update, err
+}
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// showWhoami shows tenancy, user and API key details of an account (default:
+// the current one), to tell look-alike tenancies apart
+func (b *Bot) showWhoami(chatID int64, accountName string) {
+	b.mu.Lock()
+	client := b.currentClient
+	b.mu.Unlock()
+	if accountName != "" {
+		var ok bool
+		if client, ok = b.client(accountName); !ok {
+			b.reply(chatID, "❌ 账号不存在: "+accountName)
+			return
+		}
+	}
+
+	ctx, cancel := b.withTimeout(callTimeout)
+	defer cancel()
+
+	tenancy, err := client.GetTenancyInfo(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+	user, err := client.GetUserInfo(ctx)
+	if err != nil {
+		b.reply(chatID, errorText(err))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🪪 *[%s]*\n\n", escapeMarkdown(client.AccountName())))
+	sb.WriteString(fmt.Sprintf("🏢 *租户:* %s\n", escapeMarkdown(tenancy.Name)))
+	if tenancy.Description != "" && tenancy.Description != tenancy.Name {
+		sb.WriteString(fmt.Sprintf("📝 %s\n", escapeMarkdown(tenancy.Description)))
+	}
+	sb.WriteString(fmt.Sprintf("🏠 *主区域:* %s\n", tenancy.HomeRegion))
+	sb.WriteString(fmt.Sprintf("📍 *当前区域:* %s\n", client.Region()))
+
+	sb.WriteString(fmt.Sprintf("\n👤 *用户:* %s\n", escapeMarkdown(user.Name)))
+	if user.Description != "" && user.Description != user.Name {
+		sb.WriteString(fmt.Sprintf("📝 %s\n", escapeMarkdown(user.Description)))
+	}
+	if user.Email != "" {
+		sb.WriteString(fmt.Sprintf("📧 %s\n", escapeMarkdown(user.Email)))
+	}
+	mfa := "未启用"
+	if user.MFA {
+		mfa = "已启用"
+	}
+	sb.WriteString(fmt.Sprintf("🔐 MFA: %s\n", mfa))
+	if !user.LastLogin.IsZero() {
+		sb.WriteString(fmt.Sprintf("🕐 上次登录: %s\n", user.LastLogin.Local().Format("2006-01-02 15:04")))
+	}
+	if !user.CanUseAPIKeys {
+		sb.WriteString("⚠️ 该用户不允许使用 API 密钥\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("\n🔑 *API 密钥 (%d/3):*\n", len(user.APIKeys)))
+	for _, key := range user.APIKeys {
+		line := "• " + codeSpan(key.Fingerprint)
+		if key.Fingerprint == user.KeyInUse {
+			line += " ✅ 当前"
+		}
+		if key.State != "" && key.State != "ACTIVE" {
+			line += " " + key.State
+		}
+		if !key.TimeCreated.IsZero() {
+			days := int(time.Since(key.TimeCreated).Hours() / 24)
+			line += fmt.Sprintf("\n  创建于 %s (%d 天前)", key.TimeCreated.Local().Format("2006-01-02"), days)
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\n_OCI API 密钥不会过期，长期未轮换可使用 /rotatekey_")
+
+	b.replyMarkdown(chatID, sb.String())
+}
@@ -0,0 +1,71 @@
+package bot
+
+import "time"
+
+// wizardTimeout is how long a wizard waits for the next step before it is
+// dropped, so an abandoned wizard does not swallow later plain-text messages.
+func (b *Bot) wizardTimeout() time.Duration {
+	return time.Duration(b.cfg.WizardTimeoutMinutes) * time.Minute
+}
+
+// autoWizardLocked returns the chat's auto-apply wizard and extends its expiry,
+// or nil when there is none or it has expired. Caller must hold b.mu.
+func (b *Bot) autoWizardLocked(chatID int64) *AutoApplyWizard {
+	wizard := b.autoWizards[chatID]
+	if wizard == nil {
+		return nil
+	}
+	if time.Now().After(wizard.Expires) {
+		delete(b.autoWizards, chatID)
+		return nil
+	}
+	wizard.Expires = time.Now().Add(b.wizardTimeout())
+	return wizard
+}
+
+// vpsWizardLocked is autoWizardLocked for the auto-VPS wizard.
+func (b *Bot) vpsWizardLocked(chatID int64) *AutoVPSWizard {
+	wizard := b.vpsWizards[chatID]
+	if wizard == nil {
+		return nil
+	}
+	if time.Now().After(wizard.Expires) {
+		delete(b.vpsWizards, chatID)
+		return nil
+	}
+	wizard.Expires = time.Now().Add(b.wizardTimeout())
+	return wizard
+}
+
+// cancelPending drops every wizard, pending rename and bulk selection of the
+// chat. Running tasks are left alone; they have /stopauto and /stopvps.
+func (b *Bot) cancelPending(chatID int64) {
+	b.mu.Lock()
+	_, autoIP := b.autoWizards[chatID]
+	_, autoVPS := b.vpsWizards[chatID]
+	_, rename := b.renames[chatID]
+	_, selection := b.selections[chatID]
+	_, addAccount := b.accountWizards[chatID]
+	delete(b.autoWizards, chatID)
+	delete(b.vpsWizards, chatID)
+	delete(b.accountWizards, chatID)
+	delete(b.renames, chatID)
+	delete(b.selections, chatID)
+
+	// Drop configs confirmed in the wizard but not started yet
+	if b.autoApply != nil && !b.autoApply.Active && b.autoApply.ChatID == chatID {
+		b.autoApply = nil
+		autoIP = true
+	}
+	if b.autoVPS != nil && !b.autoVPS.Active && b.autoVPS.ChatID == chatID {
+		b.autoVPS = nil
+		autoVPS = true
+	}
+	b.mu.Unlock()
+
+	if !autoIP && !autoVPS && !rename && !selection && !addAccount {
+		b.reply(chatID, "⚠️ 没有进行中的操作")
+		return
+	}
+	b.reply(chatID, "❌ 已取消")
+}
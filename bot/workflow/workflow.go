@@ -0,0 +1,421 @@
+// Package workflow runs multi-step jobs that survive restarts. A workflow is
+// declared as a list of named steps; a job of it records the step it is at
+// and its data in a state section after every step, so a restarted bot picks
+// each unfinished job up where it stopped.
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrPause stops a job at the step returning it, without counting as a
+// failure. The job waits there until resumed.
+var ErrPause = errors.New("job paused")
+
+// Store persists the jobs, see state.Store
+type Store interface {
+	Get(key string, v any) error
+	Set(key string, v any) error
+}
+
+// Step is one resumable unit of a workflow. Run is repeated from the start
+// after a restart, so a step either finishes what it did last time or does
+// it again safely.
+type Step struct {
+	Name       string        // Recorded in the checkpoint, keep it stable
+	Title      string        // Shown in progress reports
+	Retries    int           // Extra tries after a failure (default: 0)
+	RetryDelay time.Duration // Wait before each extra try
+	Run        func(ctx context.Context, job *Job) error
+}
+
+// Definition declares a workflow
+type Definition struct {
+	Kind    string
+	Title   string
+	Steps   []Step
+	NewData func() any             // Pointer to a zero value the data decodes into
+	Report  func(job Job, e Event) // Called outside the engine's lock, nil for none
+}
+
+// EventType is what happened to a job
+type EventType int
+
+const (
+	StepStarted EventType = iota
+	StepRetrying
+	StepFailed // The job stopped at the step, see Engine.Resume
+	Paused     // A step returned ErrPause
+	Finished
+)
+
+// Event is reported to Definition.Report
+type Event struct {
+	Type    EventType
+	Step    *Step
+	Attempt int   // Of StepRetrying, from 1
+	Err     error // Of StepRetrying and StepFailed
+}
+
+// Job is a running or stopped instance of a workflow
+type Job struct {
+	ID      string    `json:"id"`
+	Kind    string    `json:"kind"`
+	Label   string    `json:"label"`   // Shown in reports, e.g. the account
+	ChatID  int64     `json:"chat_id"` // Where reports go
+	Step    string    `json:"step"`    // Name of the step to run next
+	Started time.Time `json:"started"`
+	Halted  bool      `json:"halted,omitempty"` // Failed or paused, not resumed on start
+	Err     string    `json:"error,omitempty"`  // Why it failed
+	Data    any       `json:"data"`
+	Running bool      `json:"-"`
+
+	engine *Engine
+	def    *Definition
+	cancel context.CancelFunc
+}
+
+// record is a job as stored, with its data not decoded yet
+type record struct {
+	Job
+	Data json.RawMessage `json:"data"`
+}
+
+// Engine runs the jobs of the registered workflows
+type Engine struct {
+	store     Store
+	key       string
+	supervise func(ctx context.Context, name string, task func(ctx context.Context))
+
+	mu    sync.Mutex
+	defs  map[string]*Definition
+	jobs  map[string]*Job
+	saved map[string]json.RawMessage // Job ID -> data at the last checkpoint
+}
+
+// New returns an engine keeping its jobs in the key section of store.
+// supervise runs each job, restarting it when it panics.
+func New(store Store, key string, supervise func(ctx context.Context, name string, task func(ctx context.Context))) *Engine {
+	return &Engine{
+		store:     store,
+		key:       key,
+		supervise: supervise,
+		defs:      make(map[string]*Definition),
+		jobs:      make(map[string]*Job),
+		saved:     make(map[string]json.RawMessage),
+	}
+}
+
+// Register declares a workflow. All workflows are registered before Load.
+func (e *Engine) Register(def Definition) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.defs[def.Kind] = &def
+}
+
+// Load reads the jobs unfinished when the bot last stopped. Jobs of
+// workflows no longer registered are dropped.
+func (e *Engine) Load() error {
+	var records []record
+	if err := e.store.Get(e.key, &records); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		def := e.defs[r.Kind]
+		if def == nil {
+			continue
+		}
+		job := r.Job
+		job.Data = def.NewData()
+		if err := json.Unmarshal(r.Data, job.Data); err != nil {
+			return fmt.Errorf("failed to decode job %s: %w", job.ID, err)
+		}
+		job.engine, job.def = e, def
+		e.jobs[job.ID] = &job
+		e.saved[job.ID] = r.Data
+	}
+	return nil
+}
+
+// Start runs a new job of kind from its first step, data being what its
+// NewData returns. There is one job per ID at a time.
+func (e *Engine) Start(ctx context.Context, kind, id, label string, chatID int64, data any) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	def := e.defs[kind]
+	if def == nil {
+		return fmt.Errorf("unknown workflow %q", kind)
+	}
+	if _, ok := e.jobs[id]; ok {
+		return fmt.Errorf("job %s already exists", id)
+	}
+	job := &Job{ID: id, Kind: kind, Label: label, ChatID: chatID, Step: def.Steps[0].Name,
+		Started: time.Now(), Data: data, engine: e, def: def}
+	e.jobs[id] = job
+	if err := e.saveLocked(); err != nil {
+		delete(e.jobs, id)
+		return err
+	}
+	e.runLocked(ctx, job)
+	return nil
+}
+
+// Resume runs a stopped job again from the step it stopped at, reporting to
+// chatID from now on
+func (e *Engine) Resume(ctx context.Context, id string, chatID int64) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	job, ok := e.jobs[id]
+	if !ok {
+		return fmt.Errorf("no job %s", id)
+	}
+	if job.Running {
+		return fmt.Errorf("job %s is running", id)
+	}
+	job.Halted, job.Err, job.ChatID = false, "", chatID
+	if err := e.saveLocked(); err != nil {
+		return err
+	}
+	e.runLocked(ctx, job)
+	return nil
+}
+
+// ResumeAll runs the jobs that were running when the bot stopped, and
+// returns them
+func (e *Engine) ResumeAll(ctx context.Context) []Job {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var resumed []Job
+	for _, job := range e.jobs {
+		if !job.Running && !job.Halted {
+			e.runLocked(ctx, job)
+			resumed = append(resumed, e.snapshotLocked(job))
+		}
+	}
+	return resumed
+}
+
+// Stop cancels a job and forgets it, returning its last state
+func (e *Engine) Stop(id string) (Job, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	job, ok := e.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	if job.cancel != nil {
+		job.cancel()
+	}
+	snapshot := e.snapshotLocked(job)
+	delete(e.jobs, id)
+	delete(e.saved, id)
+	e.saveLocked()
+	return snapshot, true
+}
+
+// Get returns a copy of a job, with the data as of its last checkpoint
+func (e *Engine) Get(id string) (Job, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	job, ok := e.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return e.snapshotLocked(job), true
+}
+
+// Jobs returns copies of every job by start time
+func (e *Engine) Jobs() []Job {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	jobs := make([]Job, 0, len(e.jobs))
+	for _, job := range e.jobs {
+		jobs = append(jobs, e.snapshotLocked(job))
+	}
+	slices.SortFunc(jobs, func(a, b Job) int { return a.Started.Compare(b.Started) })
+	return jobs
+}
+
+// Steps returns the steps of the job's workflow
+func (j Job) Steps() []Step {
+	return j.def.Steps
+}
+
+// Title returns the title of the job's workflow
+func (j Job) Title() string {
+	return j.def.Title
+}
+
+// StepIndex returns the position of the step to run next, len(Steps) once
+// finished
+func (j Job) StepIndex() int {
+	i := slices.IndexFunc(j.def.Steps, func(s Step) bool { return s.Name == j.Step })
+	if i < 0 {
+		return len(j.def.Steps)
+	}
+	return i
+}
+
+// Update changes the data of a running job and checkpoints it, so progress
+// within a step survives a restart. Steps change the data only this way; fn
+// runs under the engine's lock and must not block.
+func (j *Job) Update(fn func()) {
+	e := j.engine
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fn()
+	if e.jobs[j.ID] == j {
+		e.saveLocked()
+	}
+}
+
+// Update changes the data of a job from outside its steps and checkpoints
+// it, false when there is no such job. Like Job.Update, fn must not block.
+func (e *Engine) Update(id string, fn func(data any)) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	job, ok := e.jobs[id]
+	if !ok {
+		return false
+	}
+	fn(job.Data)
+	e.saveLocked()
+	return true
+}
+
+// runLocked starts the job's goroutine
+func (e *Engine) runLocked(parent context.Context, job *Job) {
+	ctx, cancel := context.WithCancel(parent)
+	job.cancel = cancel
+	job.Running = true
+	go func() {
+		e.supervise(ctx, job.Kind+" "+job.ID, func(ctx context.Context) {
+			e.run(ctx, job)
+		})
+		cancel()
+		e.mu.Lock()
+		job.Running = false
+		job.cancel = nil
+		e.mu.Unlock()
+	}()
+}
+
+// run runs the job's steps from its checkpoint on
+func (e *Engine) run(ctx context.Context, job *Job) {
+	for {
+		e.mu.Lock()
+		i := job.StepIndex()
+		e.mu.Unlock()
+		if i == len(job.def.Steps) {
+			e.finish(job)
+			return
+		}
+		step := &job.def.Steps[i]
+		e.report(job, Event{Type: StepStarted, Step: step})
+
+		var err error
+		for attempt := 1; ; attempt++ {
+			err = step.Run(ctx, job)
+			if err == nil || ctx.Err() != nil || errors.Is(err, ErrPause) || attempt > step.Retries {
+				break
+			}
+			e.report(job, Event{Type: StepRetrying, Step: step, Attempt: attempt, Err: err})
+			select {
+			case <-ctx.Done():
+			case <-time.After(step.RetryDelay):
+			}
+		}
+		if ctx.Err() != nil {
+			// Stopped, or the bot is shutting down and resumes the job on start
+			return
+		}
+
+		e.mu.Lock()
+		if e.jobs[job.ID] != job {
+			// Stopped as the step finished
+			e.mu.Unlock()
+			return
+		}
+		if err != nil {
+			job.Halted = true
+			if !errors.Is(err, ErrPause) {
+				job.Err = err.Error()
+			}
+			e.saveLocked()
+			e.mu.Unlock()
+			if errors.Is(err, ErrPause) {
+				e.report(job, Event{Type: Paused, Step: step})
+			} else {
+				e.report(job, Event{Type: StepFailed, Step: step, Err: err})
+			}
+			return
+		}
+		if i+1 < len(job.def.Steps) {
+			job.Step = job.def.Steps[i+1].Name
+		} else {
+			job.Step = ""
+		}
+		e.saveLocked()
+		e.mu.Unlock()
+	}
+}
+
+// finish forgets a job past its last step
+func (e *Engine) finish(job *Job) {
+	e.mu.Lock()
+	if e.jobs[job.ID] != job {
+		e.mu.Unlock()
+		return
+	}
+	delete(e.jobs, job.ID)
+	delete(e.saved, job.ID)
+	e.saveLocked()
+	e.mu.Unlock()
+	e.report(job, Event{Type: Finished})
+}
+
+func (e *Engine) report(job *Job, event Event) {
+	if job.def.Report == nil {
+		return
+	}
+	e.mu.Lock()
+	snapshot := e.snapshotLocked(job)
+	e.mu.Unlock()
+	job.def.Report(snapshot, event)
+}
+
+// snapshotLocked copies a job, decoding its data from the last checkpoint so
+// the copy doesn't share it with the running steps
+func (e *Engine) snapshotLocked(job *Job) Job {
+	snapshot := *job
+	snapshot.cancel = nil
+	snapshot.Data = job.def.NewData()
+	if raw, ok := e.saved[job.ID]; ok {
+		json.Unmarshal(raw, snapshot.Data)
+	}
+	return snapshot
+}
+
+// saveLocked checkpoints every job
+func (e *Engine) saveLocked() error {
+	records := make([]record, 0, len(e.jobs))
+	for id, job := range e.jobs {
+		raw, err := json.Marshal(job.Data)
+		if err != nil {
+			return fmt.Errorf("failed to encode job %s: %w", id, err)
+		}
+		e.saved[id] = raw
+		records = append(records, record{Job: *job, Data: raw})
+	}
+	slices.SortFunc(records, func(a, b record) int { return a.Started.Compare(b.Started) })
+	return e.store.Set(e.key, records)
+}
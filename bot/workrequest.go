@@ -0,0 +1,41 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	"oci-bot/oci"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// trackWorkRequest waits for an OCI work request, showing its progress in one
+// message that is edited as the percentage moves ("⏳ [账号] 实例启动中… 45%").
+// what names the operation, e.g. "[账号] 实例启动". Returns the work request's
+// error when it fails.
+func (b *Bot) trackWorkRequest(ctx context.Context, chatID int64, t topic, client oci.Service, workRequestID, what string) error {
+	progress, sendErr := b.sendIn(t, tgbotapi.NewMessage(chatID, fmt.Sprintf("⏳ %s中… 0%%", what)))
+	edit := func(text string) {
+		if sendErr != nil {
+			return
+		}
+		b.edit(chatID, tgbotapi.NewEditMessageText(chatID, progress.MessageID, text))
+	}
+
+	shown := 0
+	err := client.WaitForWorkRequest(ctx, workRequestID, func(p oci.WorkRequestProgress) {
+		logger.Debugf("Work request %s: %s %.0f%%", p.ID, p.Status, p.Percent)
+		// The final edit below covers 100%
+		if percent := int(p.Percent); percent != shown && percent < 100 {
+			shown = percent
+			edit(fmt.Sprintf("⏳ %s中… %d%%", what, percent))
+		}
+	})
+	if err != nil {
+		logger.Errorf("Work request %s failed: %v", workRequestID, err)
+		edit(fmt.Sprintf("❌ %s失败: %s", what, describeError(err)))
+		return err
+	}
+	edit(fmt.Sprintf("✅ %s完成", what))
+	return nil
+}
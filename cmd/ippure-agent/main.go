@@ -0,0 +1,89 @@
+// Command ippure-agent runs purity checks for remote bots, so they can be
+// made from a machine with residential connectivity or more memory than the
+// bot host. List it in the bot's checker_agents.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"oci-bot/ippure"
+	"oci-bot/logging"
+)
+
+var logger = logging.New("agent")
+
+func main() {
+	listen := flag.String("listen", ":8765", "Address to listen on")
+	token := flag.String("token", os.Getenv("IPPURE_AGENT_TOKEN"), "Bearer token bots must send (default: $IPPURE_AGENT_TOKEN)")
+	maxConcurrent := flag.Int("max-concurrent", 4, "Checks running at once, more wait their turn (0 = no limit)")
+	memoryMB := flag.Int("memory-mb", 0, "Memory limit of one check's Chrome in MB (0 = no limit)")
+	certFile := flag.String("tls-cert", "", "TLS certificate file, serve HTTPS with -tls-key")
+	keyFile := flag.String("tls-key", "", "TLS private key file")
+	chrome := flag.String("chrome", "", "Chrome binary (default: found on the usual places)")
+	download := flag.Bool("download", false, "Download chrome-headless-shell into -chrome-dir when no Chrome is found")
+	chromeDir := flag.String("chrome-dir", "chrome", "Where -download puts Chrome")
+	rulesFile := flag.String("rules", "", "Rules file replacing the built-in ippure.com selectors and patterns")
+	debugDir := flag.String("debug-dir", "debug", "Where screenshots and HTML of failed checks go (empty = none)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [--listen :8765] [--token T] [--tls-cert F --tls-key F]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Serves POST /check {\"ip\": \"...\"} for the bot's checker_agents.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *token == "" {
+		logger.Warnf("No token set, anyone reaching %s can run checks", *listen)
+	}
+	ippure.SetLimits(ippure.Limits{MaxConcurrent: *maxConcurrent, MaxMemoryMB: *memoryMB})
+	if err := ippure.SetRulesFile(*rulesFile); err != nil {
+		logger.Fatalf("Rules: %v", err)
+	}
+	preflightCtx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	path, err := ippure.Preflight(preflightCtx, ippure.ChromeOptions{Path: *chrome, Download: *download, Dir: *chromeDir})
+	cancel()
+	if err != nil {
+		logger.Fatalf("Chrome: %v (install Chrome or Chromium, or use -chrome or -download)", err)
+	}
+	logger.Infof("Checks use %s", path)
+	ippure.SetDebug(ippure.Debug{Dir: *debugDir, OnCapture: func(c ippure.Capture) {
+		if files := c.Files(); len(files) > 0 {
+			logger.Warnf("Check of %s failed (%s), page saved as %s", c.IP, c.Reason, strings.Join(files, ", "))
+		}
+		if c.Err != nil {
+			logger.Warnf("Capturing the page of %s: %v", c.IP, c.Err)
+		}
+	}})
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           ippure.Handler(*token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Infof("Checker agent listening on %s", *listen)
+	if *certFile != "" {
+		err = srv.ListenAndServeTLS(*certFile, *keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatalf("Agent stopped: %v", err)
+	}
+}
@@ -1,26 +1,117 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"net"
+	"os"
+	"strings"
 	"time"
 
 	"oci-bot/ippure"
 )
 
+// result is the JSON output for one IP
+type result struct {
+	IP          string `json:"ip"`
+	PurityScore string `json:"purity_score,omitempty"`
+	PurityLevel string `json:"purity_level,omitempty"`
+	IPType      string `json:"ip_type,omitempty"`
+	IsNative    string `json:"is_native,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
 func main() {
-	ip := "217.142.240.193"
-	fmt.Println("Testing IP purity check for", ip)
-	fmt.Println("This may take 15-20 seconds...")
+	jsonOutput := flag.Bool("json", false, "Output results as JSON lines")
+	timeout := flag.Duration("timeout", 120*time.Second, "Timeout per IP check")
+	chrome := flag.String("chrome", "", "Chrome binary (default: found on the usual places)")
+	rulesFile := flag.String("rules", "", "Rules file to try instead of the built-in ippure.com selectors and patterns")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [--json] [--timeout 120s] [--chrome PATH] [--rules FILE] [IP ...]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Reads IPs from stdin (one per line) when none are given.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ips := flag.Args()
+	if len(ips) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				ips = append(ips, line)
+			}
+		}
+	}
+	if len(ips) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := ippure.SetRulesFile(*rulesFile); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if _, err := ippure.Preflight(context.Background(), ippure.ChromeOptions{Path: *chrome}); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	encoder := json.NewEncoder(os.Stdout)
+	for _, ip := range ips {
+		res := check(ip, *timeout, *jsonOutput)
+		if res.Error != "" {
+			failed = true
+		}
+
+		if *jsonOutput {
+			encoder.Encode(res)
+			continue
+		}
+		if res.Error != "" {
+			fmt.Printf("%s: Error: %s\n", ip, res.Error)
+		}
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// check runs a single purity check, printing the human-readable result unless quiet
+func check(ip string, timeout time.Duration, quiet bool) result {
+	res := result{IP: ip}
+	if net.ParseIP(ip) == nil {
+		res.Error = "invalid IP address"
+		return res
+	}
+
+	if !quiet {
+		fmt.Println("Testing IP purity check for", ip)
+		fmt.Println("This may take 15-20 seconds...")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	info, err := ippure.Check(ctx, ip)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return
+		res.Error = err.Error()
+		return res
 	}
 
-	fmt.Println("\n" + info.FormatResult())
+	if !quiet {
+		fmt.Println("\n" + info.FormatResult() + "\n")
+	}
+
+	if info.PurityScore == "未知" {
+		res.Error = "purity score not found on page"
+	}
+	res.PurityScore = info.PurityScore
+	res.PurityLevel = info.PurityLevel
+	res.IPType = info.IPType
+	res.IsNative = info.IsNative
+	return res
 }
@@ -2,12 +2,29 @@ package config
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+
+	"oci-bot/route"
+	"oci-bot/schedule"
+	"oci-bot/speedtest"
 )
 
+// envRefPattern matches ${VAR} references in config values
+var envRefPattern = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+// webhookSecretPattern is what Telegram accepts as a webhook secret_token
+var webhookSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
 // OCIAccount represents a single OCI account configuration
 type OCIAccount struct {
 	Name          string
@@ -17,6 +34,12 @@ type OCIAccount struct {
 	Region        string
 	CompartmentID string
 	KeyFile       string
+	KeyPassphrase string // Passphrase for encrypted private keys (optional)
+	Disabled      bool   // Kept in the config but not loaded, see /disableaccount
+	// Compartments the bot moves prized IPs into and never deletes from
+	ProtectedCompartments []string
+	// Public IP pool (BYOIP) new reserved IPs are taken from (optional, Oracle's pool when empty)
+	PublicIPPoolID string
 	// VPS settings
 	VPSAvailabilityDomain string
 	VPSSubnetID           string
@@ -28,37 +51,323 @@ type OCIAccount struct {
 	VPSMemoryGBArm        float32
 	VPSOCPUsAmd           float32
 	VPSMemoryGBAmd        float32
+	VPSPresets            []VPSPreset // More launch templates, from [<account>.presets.<name>] sections
 	VPSSSHKeys            string
+	VPSSSHUser            string // Login user shown in connection info (default: ubuntu)
+	VPSSSHPrivateKey      string // Private key of vps_ssh_keys for /exec (optional)
+	VPSSSHKeyPassphrase   string // Passphrase of an encrypted vps_ssh_private_key
+	VPSRecipe             string // Recipe applied to instances launched by /autovps (optional)
 	VPSBootVolumeGB       int
+	// Also try each fault domain explicitly when an AD is out of capacity
+	VPSFaultDomainFallback bool
+	// Keep-alive (保号) settings
+	KeepAliveHours       int    // Interval between keep-alive runs (0 = disabled)
+	KeepAliveCPUInstance string // Instance name or OCID to put CPU load on (optional)
+	KeepAliveCPUMinutes  int    // Duration of each CPU load run (default: 10)
+	// Boot volume backups
+	BackupSchedule  string // Cron expression for automatic backups (optional)
+	BackupRetention int    // Automatic backups kept per volume (default: 3)
+	// Default /autoip settings offered as a one-tap option (nil = none)
+	AutoIP *AutoIPPreset
+	// Scheduled auto-apply with the preset above (cron expressions, optional)
+	AutoIPStart string
+	AutoIPStop  string
+	// Address ranges for auto-apply, checked before the slow purity check (CIDRs, optional)
+	AutoIPPrefixes      []string // Only keep IPs inside these ranges
+	AutoIPAvoidPrefixes []string // Release IPs inside these ranges
+	// Release IPs slower than this many ms from every vantage point in
+	// AutoIPLatencyFrom (default: all of latency_locations and latency_probe_*)
+	AutoIPMaxLatency  int
+	AutoIPLatencyFrom []string
+	// Release IPs whose route from route_locations isn't one of these, see
+	// route.Types (optional)
+	AutoIPRoutes []string
+	// Throughput test of matching IPs: the IP is bound to the private IP
+	// AutoIPSpeedTestPrivateIP (OCID, on a test instance) and AutoIPSpeedTest
+	// (http(s) download or iperf3:// URL with {ip}) is run against it. IPs
+	// below AutoIPMinMbps are released (0 = only report).
+	AutoIPSpeedTest          string
+	AutoIPSpeedTestPrivateIP string
+	AutoIPMinMbps            int
+}
+
+// VPSPreset is a named instance template of an account, offered next to arm
+// and amd by /autovps and /deploy
+type VPSPreset struct {
+	Name     string
+	Shape    string
+	Image    string
+	OCPUs    float32 // Flex shapes only
+	MemoryGB float32
 }
 
+// VPSTemplate returns the launch settings of arch: "arm", "amd" or the name
+// of a preset. False for anything else.
+func (a *OCIAccount) VPSTemplate(arch string) (VPSPreset, bool) {
+	switch arch {
+	case "arm":
+		return VPSPreset{Name: arch, Shape: a.VPSShapeArm, Image: a.VPSImageArm, OCPUs: a.VPSOCPUsArm, MemoryGB: a.VPSMemoryGBArm}, true
+	case "amd":
+		return VPSPreset{Name: arch, Shape: a.VPSShapeAmd, Image: a.VPSImageAmd, OCPUs: a.VPSOCPUsAmd, MemoryGB: a.VPSMemoryGBAmd}, true
+	}
+	for _, preset := range a.VPSPresets {
+		if preset.Name == arch {
+			return preset, true
+		}
+	}
+	return VPSPreset{}, false
+}
+
+// AutoIPPreset is a per-account default /autoip configuration
+type AutoIPPreset struct {
+	PurityThreshold int    // Max purity score, 100 = any (default: 100)
+	NativeRequired  string // "原生IP" / "非原生IP" / "any" (default: any)
+	TypeRequired    string // "机房IP" / "住宅IP" / "any" (default: any)
+	MatchMode       string // "all" / "any" (default: all)
+	IntervalMin     int    // Seconds between attempts (default: 300)
+	IntervalMax     int
+}
+
+// autoIPPreset returns the account's preset, creating one with defaults
+func (a *OCIAccount) autoIPPreset() *AutoIPPreset {
+	if a.AutoIP == nil {
+		a.AutoIP = &AutoIPPreset{
+			PurityThreshold: 100,
+			NativeRequired:  "any",
+			TypeRequired:    "any",
+			MatchMode:       "all",
+			IntervalMin:     300,
+			IntervalMax:     300,
+		}
+	}
+	return a.AutoIP
+}
+
+// HookEvents are the events an on_<event> hook can be set for
+var HookEvents = []string{
+	"autoip_started", "ip_created", "check_failed", "ip_matched", "autoip_stopped",
+	"autovps_started", "instance_launched", "autovps_failed", "autovps_stopped",
+}
+
+// Recipe provisions new instances, e.g. installs a proxy stack. It is set up
+// by recipe_<name>, recipe_<name>_link and recipe_<name>_via keys.
+type Recipe struct {
+	Name      string
+	Script    string // Shell script template file, run as root
+	Link      string // Share link template sent once the script succeeded (optional)
+	CloudInit bool   // Pass the script as cloud-init user_data at launch instead of running it over SSH
+}
+
+// Chat platforms the bot runs on
+const (
+	PlatformTelegram = "telegram"
+	PlatformDiscord  = "discord"
+	PlatformMatrix   = "matrix"
+)
+
 // Config holds the application configuration
 type Config struct {
-	// Telegram Bot
+	// Path the configuration was loaded from
+	File string
+
+	// Chat platform the bot runs on: telegram / discord / matrix (default: telegram)
+	Platform string
+
+	// Telegram Bot. On Discord TelegramAdminID is the admin's Discord user ID,
+	// on Matrix the bot derives it from MatrixAdmin.
 	TelegramToken   string
 	TelegramAdminID int64
+	// Bot API server, e.g. a self-hosted telegram-bot-api (default: https://api.telegram.org)
+	APIEndpoint string
+	// Proxy for requests to the chat platform: http://, https:// or socks5://
+	// URL (optional, HTTPS_PROXY is honored otherwise)
+	Proxy string
+
+	// Discord bot (platform=discord)
+	DiscordToken   string
+	DiscordGuildID string // Register slash commands in this server only, where they show up at once (optional)
+
+	// Matrix bot (platform=matrix)
+	MatrixHomeserver  string
+	MatrixAccessToken string
+	MatrixAdmin       string // Admin's Matrix user ID, e.g. @me:example.org
+
+	// Telegram parse mode messages are sent with: Markdown / MarkdownV2 / HTML (default: Markdown)
+	ParseMode string
+
+	// Forum supergroup: route notifications into topics (message_thread_id)
+	ForumChatID int64 // Group receiving alerts instead of the admin chat (optional)
+	TopicIPList int   // Topic for IP lists (0 = General)
+	TopicAuto   int   // Topic for auto-apply / auto-VPS progress
+	TopicAlerts int   // Topic for background alerts and reports
+
+	// Shared group: its Telegram admins may use every command, other members
+	// only read-only ones (optional)
+	GroupChatID int64
+	// Minutes a destructive action in the group waits for a second admin (default: 5)
+	ApprovalTimeoutMinutes int
+
+	// Telegram user ID -> commands the user may run, "*" for all, from
+	// perm_<user_id> keys (optional)
+	Permissions map[int64][]string
+
+	// Provisioning recipes by name (optional)
+	Recipes map[string]*Recipe
+
+	// Wizards left unanswered for this many minutes are dropped (default: 10)
+	WizardTimeoutMinutes int
+
+	// Notification verbosity: all / quiet (default: all). Quiet keeps progress
+	// lines such as deletion steps in the log only.
+	NotifyLevel string
+
+	// Persistent bot state such as pinned IPs (default: oci-bot-state.json next to the config)
+	StateFile string
+
+	// Telegram webhook (optional, long polling is used when WebhookURL is empty)
+	WebhookURL        string // Public URL Telegram posts updates to
+	WebhookListen     string // Local listen address (default: ":8443")
+	WebhookCert       string // TLS certificate path (optional, e.g. behind a reverse proxy)
+	WebhookKey        string // TLS key path
+	WebhookSelfSigned bool   // Upload WebhookCert to Telegram as a self-signed certificate
+	WebhookSecret     string // secret_token Telegram sends with each update (default: random per start)
+
+	// Address serving /healthz and /readyz for process supervisors (optional)
+	HealthListen string
+
+	// Unix socket accepting JSON-RPC calls from local scripts (optional)
+	ControlSocket string
+
+	// Go plugins receiving task events, see package bot/events (optional)
+	Plugins []string
+
+	// Commands run on task events, by HookEvents name, from on_<event> keys
+	// (optional)
+	Hooks              map[string]string
+	HookTimeoutSeconds int    // Hooks still running after this are killed (default: 30)
+	HookLog            string // Record of hook runs (default: oci-bot-hooks.log next to the config)
+	IPArchive          string // CSV of every IP auto-apply checked (default: oci-bot-ip-archive.csv next to the config)
 
 	// IP Purity Check
 	AutoCheckIP bool // Auto check IP purity after creation (default: false)
 
+	// Auto-apply behaviour when the purity check itself fails
+	AutoCheckFail    string // keep / delete (default: keep)
+	AutoCheckRetries int    // Re-run a failed check this many times before deciding (default: 0)
+	// Check a matching IP again this many seconds later, both checks must
+	// match (default: 0 = no second check)
+	AutoVerifySeconds int
+	// Auto-apply progress notifications besides the final success
+	AutoProgressEvery int // Report progress every N attempts (default: 0 = off)
+	AutoNearMiss      int // Report IPs scoring up to N points above the threshold (default: 0 = off)
+
+	// Composite score used by auto-apply matching instead of the raw ippure
+	// percent: purity + penalties below. Off while every weight is 0.
+	ScoreDNSBLWeight   int            // Points per DNS blocklist listing the IP
+	ScoreASNPenalty    map[string]int // Points per origin ASN, e.g. "31898": 5
+	ScoreCountry       string         // Expected registration country, e.g. "JP"
+	ScoreCountryWeight int            // Points when the IP is registered elsewhere
+
+	// Latency measured to new IPs, shown by /checkip and used by
+	// autoip_max_latency (optional)
+	LatencyLocations []string          // Globalping locations, e.g. "Shanghai"
+	LatencyProbes    map[string]string // Self-hosted probes by name, from latency_probe_<name> URLs with {ip}
+	GlobalpingToken  string            // Raises Globalping's hourly limit (optional)
+
+	// Globalping locations in mainland China the route to new IPs is traced
+	// from, shown by /checkip and used by autoip_routes (optional)
+	RouteLocations []string
+
+	// Released auto-apply IPs handed out again within this many days are
+	// released without a purity check (default: 7, 0 = disabled)
+	KnownBadDays int
+
+	// Auto-apply burst mode: create several IPs per attempt and check them concurrently
+	AutoBurst        int // IPs created per attempt (default: 1, i.e. no burst)
+	AutoCheckWorkers int // Concurrent purity checks, each runs its own Chrome (default: 3)
+
+	// Purity checks of every kind: beyond MaxConcurrentChecks they queue
+	MaxConcurrentChecks int // Chrome instances at once (default: 4, 0 = no limit)
+	CheckMemoryLimitMB  int // Memory of one check's Chrome, exceeded = check fails (default: 0 = no limit)
+	CheckCacheMinutes   int // A result answers checks of the same IP this long (default: 30, 0 = always check)
+	// Remote checker agents (cmd/ippure-agent) running the checks instead of
+	// a local Chrome, tried in order (optional)
+	CheckerAgents     []string
+	CheckerAgentToken string
+	// Chrome for local checks, found on the usual places when ChromePath is
+	// empty. ChromeDownload fetches chrome-headless-shell into ChromeDir
+	// when there is none (default dir: chrome next to the config).
+	ChromePath     string
+	ChromeDownload bool
+	ChromeDir      string
+	// Screenshot and HTML of local checks that fail or find empty fields go
+	// into CheckDebugDir (default: oci-bot-debug next to the config, "off" =
+	// none). CheckDebugNotify also sends the screenshot to the admin.
+	CheckDebugDir    string
+	CheckDebugNotify bool
+	// Selectors and patterns of ippure.com replacing the built-in ones, reread
+	// when the file changes (optional, see ippure/rules.json)
+	IPPureRules string
+
+	// Adaptive wait between /autoip and /autovps attempts instead of the wizard interval
+	AutoAdaptive    bool // Shorten the wait while OCI accepts calls, back off on throttling
+	AutoAdaptiveMin int  // Shortest wait in seconds (default: 30)
+	AutoAdaptiveMax int  // Longest wait in seconds (default: 1800)
+
+	// Billing
+	FreeReservedIPs        int   // Reserved IPs covered by the free tier (default: 1)
+	UnattachedIPCheckHours int   // Unattached IP warning interval in hours (0 = disabled)
+	UnattachedIPAgeHours   int   // Warn about IPs unattached for longer than this (default: 24)
+	EgressCheckHours       int   // Outbound transfer check interval in hours (0 = disabled)
+	EgressLimitGB          int   // Free outbound transfer per month in GB (default: 10240)
+	EgressAlertPercents    []int // Warn when usage crosses these percents (default: 80,90,100)
+
+	// Monitoring
+	AuditCheckMinutes   int // Audit log polling interval in minutes (0 = disabled)
+	MetricsCheckMinutes int // CPU alert check interval in minutes (0 = disabled)
+	CPUAlertPercent     int // Alert when CPU stays above this percent (default: 90)
+	CPUAlertMinutes     int // Minutes CPU must stay above the threshold (default: 30)
+	WatchCheckHours     int // Purity re-check interval of /watch IPs in hours (0 = disabled)
+	PinnedCheckMinutes  int // Assignment check interval of pinned IPs in minutes (0 = disabled)
+
+	// Alerts are also posted as JSON to this URL (optional)
+	AlertWebhookURL string
+
+	// Daily digest of the last 24 hours, standard 5-field cron (optional)
+	DigestSchedule string
+
+	// Logging
+	LogLevel      string // debug / info / warn / error (default: info)
+	LogFormat     string // text / json (default: text)
+	LogFile       string // Log file path (optional, stderr only when empty)
+	LogMaxSizeMB  int    // Rotate log file at this size (default: 10)
+	LogMaxBackups int    // Rotated log files to keep (default: 3)
+
+	LogForwardErrors   bool // Mirror error logs to the admin chat
+	LogForwardInterval int  // Seconds between forwarded batches (default: 60)
+
 	// OCI Accounts (multiple)
 	Accounts []OCIAccount
 }
 
-// Load loads configuration from conf file (INI-style format)
+// Load loads configuration from conf file (INI-style format). An encrypted
+// file is decrypted with the passphrase given to SetPassphrase, and the bot's
+// own writes to it and to new key files are encrypted from then on.
 func Load(filename string) (*Config, error) {
-	file, err := os.Open(filename)
+	content, encrypted, err := readSecretFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open config file: %w", err)
 	}
-	defer file.Close()
+	sealSecrets = encrypted
 
-	cfg := &Config{}
+	cfg := &Config{File: filename}
 	var currentSection string
 	var currentAccount *OCIAccount
+	var currentPreset *VPSPreset
+	presets := make(map[string][]*VPSPreset) // Account name -> presets, attached once all sections are read
 	globalValues := make(map[string]string)
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
@@ -74,7 +383,14 @@ func Load(filename string) (*Config, error) {
 				cfg.Accounts = append(cfg.Accounts, *currentAccount)
 			}
 			currentSection = strings.TrimPrefix(strings.TrimSuffix(line, "]"), "[")
-			currentAccount = &OCIAccount{Name: currentSection}
+			currentAccount, currentPreset = nil, nil
+			if account, name, ok := strings.Cut(currentSection, ".presets."); ok {
+				// [<account>.presets.<name>] is a VPS preset of the account
+				currentPreset = &VPSPreset{Name: name}
+				presets[account] = append(presets[account], currentPreset)
+			} else {
+				currentAccount = &OCIAccount{Name: currentSection}
+			}
 			continue
 		}
 
@@ -85,9 +401,20 @@ func Load(filename string) (*Config, error) {
 		}
 
 		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+		value := expandEnv(strings.TrimSpace(parts[1]))
 
-		if currentAccount != nil {
+		if currentPreset != nil {
+			switch key {
+			case "shape":
+				currentPreset.Shape = value
+			case "image":
+				currentPreset.Image = value
+			case "ocpus":
+				currentPreset.OCPUs = parseFloat32(value)
+			case "memory_gb":
+				currentPreset.MemoryGB = parseFloat32(value)
+			}
+		} else if currentAccount != nil {
 			// Inside a section - OCI account settings
 			switch key {
 			case "user":
@@ -102,6 +429,10 @@ func Load(filename string) (*Config, error) {
 				currentAccount.CompartmentID = value
 			case "key_file":
 				currentAccount.KeyFile = expandHome(value)
+			case "key_passphrase":
+				currentAccount.KeyPassphrase = value
+			case "disabled":
+				currentAccount.Disabled = parseBool(value)
 			case "vps_ad":
 				currentAccount.VPSAvailabilityDomain = value
 			case "vps_subnet_id":
@@ -124,8 +455,75 @@ func Load(filename string) (*Config, error) {
 				currentAccount.VPSMemoryGBAmd = parseFloat32(value)
 			case "vps_ssh_keys":
 				currentAccount.VPSSSHKeys = value
+			case "vps_ssh_user":
+				currentAccount.VPSSSHUser = value
+			case "vps_ssh_private_key":
+				currentAccount.VPSSSHPrivateKey = value
+			case "vps_ssh_key_passphrase":
+				currentAccount.VPSSSHKeyPassphrase = value
+			case "vps_recipe":
+				currentAccount.VPSRecipe = value
 			case "vps_boot_volume_gb":
 				currentAccount.VPSBootVolumeGB = parseInt(value)
+			case "vps_fd_fallback":
+				currentAccount.VPSFaultDomainFallback = parseBool(value)
+			case "keepalive_hours":
+				currentAccount.KeepAliveHours = parseInt(value)
+			case "keepalive_cpu_instance":
+				currentAccount.KeepAliveCPUInstance = value
+			case "keepalive_cpu_minutes":
+				currentAccount.KeepAliveCPUMinutes = parseInt(value)
+			case "public_ip_pool_id":
+				currentAccount.PublicIPPoolID = value
+			case "protected_compartments":
+				currentAccount.ProtectedCompartments = parseList(value)
+			case "backup_schedule":
+				currentAccount.BackupSchedule = value
+			case "backup_retention":
+				currentAccount.BackupRetention = parseInt(value)
+			case "autoip_purity":
+				currentAccount.autoIPPreset().PurityThreshold = parseInt(value)
+			case "autoip_native":
+				switch strings.ToLower(value) {
+				case "native":
+					value = "原生IP"
+				case "non-native":
+					value = "非原生IP"
+				}
+				currentAccount.autoIPPreset().NativeRequired = value
+			case "autoip_type":
+				switch strings.ToLower(value) {
+				case "datacenter":
+					value = "机房IP"
+				case "residential":
+					value = "住宅IP"
+				}
+				currentAccount.autoIPPreset().TypeRequired = value
+			case "autoip_mode":
+				currentAccount.autoIPPreset().MatchMode = value
+			case "autoip_interval":
+				preset := currentAccount.autoIPPreset()
+				preset.IntervalMin, preset.IntervalMax = parseRange(value)
+			case "autoip_prefixes":
+				currentAccount.AutoIPPrefixes = parseList(value)
+			case "autoip_avoid_prefixes":
+				currentAccount.AutoIPAvoidPrefixes = parseList(value)
+			case "autoip_max_latency":
+				currentAccount.AutoIPMaxLatency = parseInt(value)
+			case "autoip_latency_from":
+				currentAccount.AutoIPLatencyFrom = parseList(value)
+			case "autoip_routes":
+				currentAccount.AutoIPRoutes = parseList(value)
+			case "autoip_speedtest":
+				currentAccount.AutoIPSpeedTest = value
+			case "autoip_speedtest_private_ip":
+				currentAccount.AutoIPSpeedTestPrivateIP = value
+			case "autoip_min_mbps":
+				currentAccount.AutoIPMinMbps = parseInt(value)
+			case "autoip_start":
+				currentAccount.AutoIPStart = value
+			case "autoip_stop":
+				currentAccount.AutoIPStop = value
 			}
 		} else {
 			// Global settings (Telegram)
@@ -141,16 +539,248 @@ func Load(filename string) (*Config, error) {
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	for name, list := range presets {
+		account := cfg.GetAccount(name)
+		if name == "" || account == nil {
+			return nil, fmt.Errorf("[%s.presets.%s]: no account [%s]", name, list[0].Name, name)
+		}
+		for _, preset := range list {
+			account.VPSPresets = append(account.VPSPresets, *preset)
+		}
+	}
+
+	cfg.Platform = strings.ToLower(globalValues["platform"])
+	if cfg.Platform == "" {
+		cfg.Platform = PlatformTelegram
+	}
+	cfg.DiscordToken = globalValues["discord_token"]
+	cfg.DiscordGuildID = globalValues["discord_guild_id"]
+	cfg.MatrixHomeserver = strings.TrimRight(globalValues["matrix_homeserver"], "/")
+	cfg.MatrixAccessToken = globalValues["matrix_access_token"]
+	cfg.MatrixAdmin = globalValues["matrix_admin"]
 
 	// Telegram settings
 	cfg.TelegramToken = globalValues["token"]
 	if chatID := globalValues["chat_id"]; chatID != "" {
 		cfg.TelegramAdminID, _ = strconv.ParseInt(chatID, 10, 64)
 	}
+	cfg.APIEndpoint = strings.TrimRight(globalValues["api_endpoint"], "/")
+	cfg.Proxy = globalValues["proxy"]
+
+	switch strings.ToLower(globalValues["parse_mode"]) {
+	case "", "markdown":
+		cfg.ParseMode = "Markdown"
+	case "markdownv2":
+		cfg.ParseMode = "MarkdownV2"
+	case "html":
+		cfg.ParseMode = "HTML"
+	default:
+		cfg.ParseMode = globalValues["parse_mode"]
+	}
+
+	if forumID := globalValues["forum_chat_id"]; forumID != "" {
+		cfg.ForumChatID, _ = strconv.ParseInt(forumID, 10, 64)
+	}
+	if groupID := globalValues["group_chat_id"]; groupID != "" {
+		cfg.GroupChatID, _ = strconv.ParseInt(groupID, 10, 64)
+	}
+	cfg.ApprovalTimeoutMinutes = 5
+	if v := globalValues["approval_timeout_minutes"]; v != "" {
+		cfg.ApprovalTimeoutMinutes = parseInt(v)
+	}
+	for key, value := range globalValues {
+		idText, ok := strings.CutPrefix(key, "perm_")
+		if !ok {
+			continue
+		}
+		userID, err := strconv.ParseInt(idText, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID in %s", key)
+		}
+		if cfg.Permissions == nil {
+			cfg.Permissions = make(map[int64][]string)
+		}
+		for _, command := range parseList(value) {
+			cfg.Permissions[userID] = append(cfg.Permissions[userID], strings.TrimPrefix(strings.ToLower(command), "/"))
+		}
+	}
+	if err := parseRecipes(cfg, globalValues); err != nil {
+		return nil, err
+	}
+	cfg.TopicIPList = parseInt(globalValues["topic_ip_list"])
+	cfg.TopicAuto = parseInt(globalValues["topic_auto"])
+	cfg.TopicAlerts = parseInt(globalValues["topic_alerts"])
+
+	cfg.WizardTimeoutMinutes = 10
+	if v := globalValues["wizard_timeout_minutes"]; v != "" {
+		cfg.WizardTimeoutMinutes = parseInt(v)
+	}
+
+	cfg.NotifyLevel = globalValues["notify_level"]
+	if cfg.NotifyLevel == "" {
+		cfg.NotifyLevel = "all"
+	}
+
+	cfg.StateFile = expandHome(globalValues["state_file"])
+	if cfg.StateFile == "" {
+		cfg.StateFile = filepath.Join(filepath.Dir(filename), "oci-bot-state.json")
+	}
+
+	// Webhook settings
+	cfg.WebhookURL = globalValues["webhook_url"]
+	cfg.WebhookListen = globalValues["webhook_listen"]
+	if cfg.WebhookListen == "" {
+		cfg.WebhookListen = ":8443"
+	}
+	cfg.WebhookCert = expandHome(globalValues["webhook_cert"])
+	cfg.WebhookKey = expandHome(globalValues["webhook_key"])
+	cfg.WebhookSelfSigned = parseBool(globalValues["webhook_self_signed"])
+	cfg.WebhookSecret = globalValues["webhook_secret"]
+	cfg.HealthListen = globalValues["health_listen"]
+	cfg.ControlSocket = expandHome(globalValues["control_socket"])
+	for _, path := range parseList(globalValues["plugins"]) {
+		cfg.Plugins = append(cfg.Plugins, expandHome(path))
+	}
+	for key, value := range globalValues {
+		if event, ok := strings.CutPrefix(key, "on_"); ok && value != "" {
+			if cfg.Hooks == nil {
+				cfg.Hooks = make(map[string]string)
+			}
+			cfg.Hooks[event] = expandHome(value)
+		}
+	}
+	cfg.HookTimeoutSeconds = 30
+	if v := globalValues["hook_timeout"]; v != "" {
+		cfg.HookTimeoutSeconds = parseInt(v)
+	}
+	cfg.HookLog = expandHome(globalValues["hook_log"])
+	if cfg.HookLog == "" {
+		cfg.HookLog = filepath.Join(filepath.Dir(filename), "oci-bot-hooks.log")
+	}
+	cfg.IPArchive = expandHome(globalValues["ip_archive"])
+	if cfg.IPArchive == "" {
+		cfg.IPArchive = filepath.Join(filepath.Dir(filename), "oci-bot-ip-archive.csv")
+	}
 
 	// IP Purity settings (default: false)
-	if autoCheck := globalValues["auto_check_ip"]; autoCheck == "true" || autoCheck == "1" {
-		cfg.AutoCheckIP = true
+	cfg.AutoCheckIP = parseBool(globalValues["auto_check_ip"])
+	cfg.AutoCheckFail = globalValues["auto_check_fail"]
+	if cfg.AutoCheckFail == "" {
+		cfg.AutoCheckFail = "keep"
+	}
+	cfg.AutoCheckRetries = parseInt(globalValues["auto_check_retries"])
+	cfg.AutoVerifySeconds = parseInt(globalValues["auto_verify_seconds"])
+	cfg.AutoProgressEvery = parseInt(globalValues["auto_progress_every"])
+	cfg.AutoNearMiss = parseInt(globalValues["auto_near_miss"])
+	cfg.ScoreDNSBLWeight = parseInt(globalValues["score_dnsbl_weight"])
+	cfg.ScoreASNPenalty = parseIntMap(globalValues["score_asn_penalty"])
+	cfg.ScoreCountry = strings.ToUpper(globalValues["score_country"])
+	cfg.ScoreCountryWeight = parseInt(globalValues["score_country_weight"])
+	cfg.LatencyLocations = parseList(globalValues["latency_locations"])
+	for key, value := range globalValues {
+		if name, ok := strings.CutPrefix(key, "latency_probe_"); ok && value != "" {
+			if cfg.LatencyProbes == nil {
+				cfg.LatencyProbes = make(map[string]string)
+			}
+			cfg.LatencyProbes[name] = value
+		}
+	}
+	cfg.GlobalpingToken = globalValues["globalping_token"]
+	cfg.RouteLocations = parseList(globalValues["route_locations"])
+	cfg.KnownBadDays = 7
+	if v := globalValues["known_bad_days"]; v != "" {
+		cfg.KnownBadDays = parseInt(v)
+	}
+	cfg.AutoBurst = 1
+	if v := globalValues["auto_burst"]; v != "" {
+		cfg.AutoBurst = parseInt(v)
+	}
+	cfg.AutoCheckWorkers = 3
+	if v := globalValues["auto_check_workers"]; v != "" {
+		cfg.AutoCheckWorkers = parseInt(v)
+	}
+	cfg.MaxConcurrentChecks = 4
+	if v := globalValues["max_concurrent_checks"]; v != "" {
+		cfg.MaxConcurrentChecks = parseInt(v)
+	}
+	cfg.CheckMemoryLimitMB = parseInt(globalValues["check_memory_limit_mb"])
+	cfg.CheckCacheMinutes = 30
+	if v := globalValues["check_cache_minutes"]; v != "" {
+		cfg.CheckCacheMinutes = parseInt(v)
+	}
+	cfg.CheckerAgents = parseList(globalValues["checker_agents"])
+	cfg.CheckerAgentToken = globalValues["checker_agent_token"]
+	cfg.ChromePath = expandHome(globalValues["chrome_path"])
+	cfg.ChromeDownload = parseBool(globalValues["chrome_download"])
+	cfg.ChromeDir = expandHome(globalValues["chrome_dir"])
+	if cfg.ChromeDir == "" {
+		cfg.ChromeDir = filepath.Join(filepath.Dir(filename), "chrome")
+	}
+	switch v := globalValues["check_debug_dir"]; v {
+	case "":
+		cfg.CheckDebugDir = filepath.Join(filepath.Dir(filename), "oci-bot-debug")
+	case "off":
+	default:
+		cfg.CheckDebugDir = expandHome(v)
+	}
+	cfg.CheckDebugNotify = parseBool(globalValues["check_debug_notify"])
+	cfg.IPPureRules = expandHome(globalValues["ippure_rules"])
+	cfg.AutoAdaptive = parseBool(globalValues["auto_adaptive"])
+	cfg.AutoAdaptiveMin = 30
+	if v := globalValues["auto_adaptive_min"]; v != "" {
+		cfg.AutoAdaptiveMin = parseInt(v)
+	}
+	cfg.AutoAdaptiveMax = 1800
+	if v := globalValues["auto_adaptive_max"]; v != "" {
+		cfg.AutoAdaptiveMax = parseInt(v)
+	}
+
+	// Monitoring settings
+	cfg.AuditCheckMinutes = parseInt(globalValues["audit_check_minutes"])
+	cfg.MetricsCheckMinutes = parseInt(globalValues["metrics_check_minutes"])
+	cfg.CPUAlertPercent = 90
+	if v := globalValues["cpu_alert_percent"]; v != "" {
+		cfg.CPUAlertPercent = parseInt(v)
+	}
+	cfg.CPUAlertMinutes = 30
+	if v := globalValues["cpu_alert_minutes"]; v != "" {
+		cfg.CPUAlertMinutes = parseInt(v)
+	}
+	cfg.WatchCheckHours = parseInt(globalValues["watch_check_hours"])
+	cfg.PinnedCheckMinutes = parseInt(globalValues["pinned_check_minutes"])
+	cfg.AlertWebhookURL = globalValues["alert_webhook_url"]
+	cfg.DigestSchedule = globalValues["digest_schedule"]
+
+	// Logging settings
+	cfg.LogLevel = globalValues["log_level"]
+	cfg.LogFormat = globalValues["log_format"]
+	cfg.LogFile = expandHome(globalValues["log_file"])
+	cfg.LogMaxSizeMB = parseInt(globalValues["log_max_size_mb"])
+	cfg.LogMaxBackups = parseInt(globalValues["log_max_backups"])
+	cfg.LogForwardErrors = parseBool(globalValues["log_forward_errors"])
+	cfg.LogForwardInterval = 60
+	if v := globalValues["log_forward_interval"]; v != "" {
+		cfg.LogForwardInterval = parseInt(v)
+	}
+
+	// Billing settings
+	cfg.FreeReservedIPs = 1
+	if v := globalValues["free_reserved_ips"]; v != "" {
+		cfg.FreeReservedIPs = parseInt(v)
+	}
+	cfg.UnattachedIPCheckHours = parseInt(globalValues["unattached_ip_check_hours"])
+	cfg.UnattachedIPAgeHours = 24
+	if v := globalValues["unattached_ip_age_hours"]; v != "" {
+		cfg.UnattachedIPAgeHours = parseInt(v)
+	}
+	cfg.EgressCheckHours = parseInt(globalValues["egress_check_hours"])
+	cfg.EgressLimitGB = 10240
+	if v := globalValues["egress_limit_gb"]; v != "" {
+		cfg.EgressLimitGB = parseInt(v)
+	}
+	cfg.EgressAlertPercents = []int{80, 90, 100}
+	if v := globalValues["egress_alert_percents"]; v != "" {
+		cfg.EgressAlertPercents = parseIntList(v)
 	}
 
 	return cfg, nil
@@ -158,15 +788,142 @@ func Load(filename string) (*Config, error) {
 
 // Validate checks if required configuration is present
 func (c *Config) Validate() error {
-	if c.TelegramToken == "" {
-		return fmt.Errorf("token is required")
+	switch c.Platform {
+	case PlatformTelegram:
+		if c.TelegramToken == "" {
+			return fmt.Errorf("token is required")
+		}
+	case PlatformDiscord:
+		if c.DiscordToken == "" {
+			return fmt.Errorf("discord_token is required")
+		}
+	case PlatformMatrix:
+		if c.MatrixHomeserver == "" || c.MatrixAccessToken == "" {
+			return fmt.Errorf("matrix_homeserver and matrix_access_token are required")
+		}
+		if !strings.HasPrefix(c.MatrixAdmin, "@") || !strings.Contains(c.MatrixAdmin, ":") {
+			return fmt.Errorf("matrix_admin must be a Matrix user ID such as @me:example.org")
+		}
+	default:
+		return fmt.Errorf("platform must be telegram, discord or matrix")
 	}
-	if c.TelegramAdminID == 0 {
+	if c.TelegramAdminID == 0 && c.Platform != PlatformMatrix {
 		return fmt.Errorf("chat_id is required")
 	}
+	if c.Platform != PlatformTelegram {
+		switch {
+		case c.WebhookURL != "":
+			return fmt.Errorf("webhook_url is only supported on Telegram")
+		case c.ForumChatID != 0:
+			return fmt.Errorf("forum_chat_id is only supported on Telegram")
+		case c.APIEndpoint != "":
+			return fmt.Errorf("api_endpoint is only supported on Telegram")
+		case c.ParseMode != "Markdown":
+			return fmt.Errorf("parse_mode must be markdown on %s", c.Platform)
+		}
+	}
+	if c.APIEndpoint != "" {
+		if u, err := url.Parse(c.APIEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("api_endpoint must be an http:// or https:// URL")
+		}
+	}
+	if c.Proxy != "" {
+		u, err := url.Parse(c.Proxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid proxy URL")
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("proxy must be an http://, https:// or socks5:// URL")
+		}
+	}
 	if len(c.Accounts) == 0 {
 		return fmt.Errorf("at least one OCI account section is required")
 	}
+	if c.LogFormat != "" && c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("log_format must be text or json")
+	}
+	if c.LogForwardErrors && c.LogForwardInterval < 10 {
+		return fmt.Errorf("log_forward_interval must be at least 10 seconds")
+	}
+	if c.ParseMode != "Markdown" && c.ParseMode != "MarkdownV2" && c.ParseMode != "HTML" {
+		return fmt.Errorf("parse_mode must be markdown, markdownv2 or html")
+	}
+	if c.WizardTimeoutMinutes <= 0 {
+		return fmt.Errorf("wizard_timeout_minutes must be positive")
+	}
+	if c.ApprovalTimeoutMinutes <= 0 {
+		return fmt.Errorf("approval_timeout_minutes must be positive")
+	}
+	if c.NotifyLevel != "all" && c.NotifyLevel != "quiet" {
+		return fmt.Errorf("notify_level must be all or quiet")
+	}
+	if c.AutoCheckFail != "keep" && c.AutoCheckFail != "delete" {
+		return fmt.Errorf("auto_check_fail must be keep or delete")
+	}
+	if c.AutoCheckRetries < 0 {
+		return fmt.Errorf("auto_check_retries must not be negative")
+	}
+	if c.AutoVerifySeconds < 0 {
+		return fmt.Errorf("auto_verify_seconds must not be negative")
+	}
+	if c.AutoProgressEvery < 0 {
+		return fmt.Errorf("auto_progress_every must not be negative")
+	}
+	if c.AutoNearMiss < 0 {
+		return fmt.Errorf("auto_near_miss must not be negative")
+	}
+	if c.AutoBurst < 1 {
+		return fmt.Errorf("auto_burst must be at least 1")
+	}
+	if c.AutoCheckWorkers < 1 {
+		return fmt.Errorf("auto_check_workers must be at least 1")
+	}
+	if c.MaxConcurrentChecks < 0 {
+		return fmt.Errorf("max_concurrent_checks must not be negative")
+	}
+	if c.CheckCacheMinutes < 0 {
+		return fmt.Errorf("check_cache_minutes must not be negative")
+	}
+	if c.CheckMemoryLimitMB != 0 && c.CheckMemoryLimitMB < 128 {
+		return fmt.Errorf("check_memory_limit_mb must be at least 128, Chrome needs that much to start")
+	}
+	if c.AutoAdaptive && (c.AutoAdaptiveMin < 10 || c.AutoAdaptiveMax < c.AutoAdaptiveMin) {
+		return fmt.Errorf("auto_adaptive_min must be at least 10 and not above auto_adaptive_max")
+	}
+	if c.EgressCheckHours > 0 && c.EgressLimitGB <= 0 {
+		return fmt.Errorf("egress_limit_gb must be positive")
+	}
+	if (c.WebhookCert == "") != (c.WebhookKey == "") {
+		return fmt.Errorf("webhook_cert and webhook_key must be set together")
+	}
+	if c.WebhookSecret != "" && !webhookSecretPattern.MatchString(c.WebhookSecret) {
+		return fmt.Errorf("webhook_secret must be 1-256 characters of A-Z, a-z, 0-9, _ and -")
+	}
+	for _, agent := range c.CheckerAgents {
+		if u, err := url.Parse(agent); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("checker_agents: %s must be an http:// or https:// URL", agent)
+		}
+	}
+	for name, probe := range c.LatencyProbes {
+		if u, err := url.Parse(probe); err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.Contains(probe, "{ip}") {
+			return fmt.Errorf("latency_probe_%s must be an http:// or https:// URL containing {ip}", name)
+		}
+	}
+	for event := range c.Hooks {
+		if !slices.Contains(HookEvents, event) {
+			return fmt.Errorf("on_%s: unknown event, use one of on_%s", event, strings.Join(HookEvents, ", on_"))
+		}
+	}
+	if len(c.Hooks) > 0 && c.HookTimeoutSeconds <= 0 {
+		return fmt.Errorf("hook_timeout must be positive")
+	}
+	if c.DigestSchedule != "" {
+		if _, err := schedule.Parse(c.DigestSchedule); err != nil {
+			return fmt.Errorf("digest_schedule: %w", err)
+		}
+	}
 	// Use index to modify the original slice element
 	for i := range c.Accounts {
 		// Default compartment_id to tenancy if not set
@@ -176,6 +933,63 @@ func (c *Config) Validate() error {
 		if err := c.Accounts[i].Validate(); err != nil {
 			return fmt.Errorf("account [%s]: %w", c.Accounts[i].Name, err)
 		}
+		if name := c.Accounts[i].VPSRecipe; name != "" {
+			recipe := c.Recipes[name]
+			if recipe == nil {
+				return fmt.Errorf("account [%s]: vps_recipe %s is not defined, add recipe_%s", c.Accounts[i].Name, name, name)
+			}
+			if !recipe.CloudInit && c.Accounts[i].VPSSSHPrivateKey == "" {
+				return fmt.Errorf("account [%s]: vps_recipe %s runs over SSH and needs vps_ssh_private_key", c.Accounts[i].Name, name)
+			}
+		}
+		if err := c.validateLatencyCriterion(&c.Accounts[i]); err != nil {
+			return fmt.Errorf("account [%s]: %w", c.Accounts[i].Name, err)
+		}
+		if err := c.validateRouteCriterion(&c.Accounts[i]); err != nil {
+			return fmt.Errorf("account [%s]: %w", c.Accounts[i].Name, err)
+		}
+		if err := validateSpeedTest(&c.Accounts[i]); err != nil {
+			return fmt.Errorf("account [%s]: %w", c.Accounts[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// parseRecipes reads the recipe_<name> keys: recipe_<name> is the script,
+// recipe_<name>_link the share link and recipe_<name>_via ssh or cloud-init
+func parseRecipes(cfg *Config, globalValues map[string]string) error {
+	recipe := func(name string) *Recipe {
+		if cfg.Recipes == nil {
+			cfg.Recipes = make(map[string]*Recipe)
+		}
+		if cfg.Recipes[name] == nil {
+			cfg.Recipes[name] = &Recipe{Name: name}
+		}
+		return cfg.Recipes[name]
+	}
+	for key, value := range globalValues {
+		name, ok := strings.CutPrefix(key, "recipe_")
+		if !ok {
+			continue
+		}
+		if base, ok := strings.CutSuffix(name, "_link"); ok {
+			recipe(base).Link = value
+		} else if base, ok := strings.CutSuffix(name, "_via"); ok {
+			switch value {
+			case "ssh":
+			case "cloud-init":
+				recipe(base).CloudInit = true
+			default:
+				return fmt.Errorf("%s must be ssh or cloud-init", key)
+			}
+		} else {
+			recipe(name).Script = expandHome(value)
+		}
+	}
+	for name, r := range cfg.Recipes {
+		if r.Script == "" {
+			return fmt.Errorf("recipe_%s is required by the other recipe_%s_* keys", name, name)
+		}
 	}
 	return nil
 }
@@ -201,10 +1015,77 @@ func (a *OCIAccount) Validate() error {
 	if a.CompartmentID == "" {
 		a.CompartmentID = a.Tenancy
 	}
+	if a.IsProtectedCompartment(a.CompartmentID) {
+		return fmt.Errorf("protected_compartments must not include compartment_id")
+	}
+	if a.KeepAliveCPUMinutes <= 0 {
+		a.KeepAliveCPUMinutes = 10
+	}
+	if a.VPSSSHUser == "" {
+		a.VPSSSHUser = "ubuntu"
+	}
+	if a.BackupSchedule != "" {
+		if _, err := schedule.Parse(a.BackupSchedule); err != nil {
+			return fmt.Errorf("backup_schedule: %w", err)
+		}
+	}
+	if a.BackupRetention <= 0 {
+		a.BackupRetention = 3
+	}
+	if p := a.AutoIP; p != nil {
+		if p.PurityThreshold <= 0 || p.PurityThreshold > 100 {
+			return fmt.Errorf("autoip_purity must be between 1 and 100")
+		}
+		if p.NativeRequired != "原生IP" && p.NativeRequired != "非原生IP" && p.NativeRequired != "any" {
+			return fmt.Errorf("autoip_native must be native, non-native or any")
+		}
+		if p.TypeRequired != "机房IP" && p.TypeRequired != "住宅IP" && p.TypeRequired != "any" {
+			return fmt.Errorf("autoip_type must be datacenter, residential or any")
+		}
+		if p.MatchMode != "all" && p.MatchMode != "any" {
+			return fmt.Errorf("autoip_mode must be all or any")
+		}
+		if p.IntervalMin < 10 {
+			return fmt.Errorf("autoip_interval must be at least 10 seconds")
+		}
+	}
+	for _, prefix := range slices.Concat(a.AutoIPPrefixes, a.AutoIPAvoidPrefixes) {
+		if _, _, err := net.ParseCIDR(prefix); err != nil {
+			return fmt.Errorf("autoip_prefixes / autoip_avoid_prefixes: invalid CIDR %q", prefix)
+		}
+	}
+	for i, preset := range a.VPSPresets {
+		section := fmt.Sprintf("[%s.presets.%s]", a.Name, preset.Name)
+		// The name goes into button callback data
+		if preset.Name == "" || len(preset.Name) > 32 || strings.ContainsAny(preset.Name, ": \t") {
+			return fmt.Errorf("%s: the preset name must be at most 32 bytes without spaces or colons", section)
+		}
+		if preset.Name == "arm" || preset.Name == "amd" ||
+			slices.ContainsFunc(a.VPSPresets[:i], func(p VPSPreset) bool { return p.Name == preset.Name }) {
+			return fmt.Errorf("%s: the preset name is already taken", section)
+		}
+		if preset.Shape == "" || preset.Image == "" {
+			return fmt.Errorf("%s: shape and image are required", section)
+		}
+	}
+	if a.AutoIPStart != "" {
+		if a.AutoIP == nil {
+			return fmt.Errorf("autoip_start needs the autoip_* criteria to start with")
+		}
+		if _, err := schedule.Parse(a.AutoIPStart); err != nil {
+			return fmt.Errorf("autoip_start: %w", err)
+		}
+	}
+	if a.AutoIPStop != "" {
+		if _, err := schedule.Parse(a.AutoIPStop); err != nil {
+			return fmt.Errorf("autoip_stop: %w", err)
+		}
+	}
 	return nil
 }
 
-// ValidateVPSConfig checks if VPS config is valid for the given architecture.
+// ValidateVPSConfig checks if VPS config is valid for the given architecture
+// or preset.
 func (a *OCIAccount) ValidateVPSConfig(arch string) error {
 	if a.VPSAvailabilityDomain == "" {
 		return fmt.Errorf("vps_ad is required")
@@ -232,7 +1113,10 @@ func (a *OCIAccount) ValidateVPSConfig(arch string) error {
 			return fmt.Errorf("vps_shape_amd is required")
 		}
 	default:
-		return fmt.Errorf("unsupported arch: %s", arch)
+		// Presets are complete, see Validate
+		if _, ok := a.VPSTemplate(arch); !ok {
+			return fmt.Errorf("unsupported arch: %s", arch)
+		}
 	}
 
 	return nil
@@ -260,6 +1144,14 @@ func (c *Config) AccountNames() []string {
 	return names
 }
 
+// expandEnv replaces ${VAR} references with environment variable values.
+// Only the braced form is expanded so values containing a bare "$" are kept as-is.
+func expandEnv(value string) string {
+	return envRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		return os.Getenv(ref[2 : len(ref)-1])
+	})
+}
+
 func expandHome(path string) string {
 	if strings.HasPrefix(path, "~/") {
 		home, _ := os.UserHomeDir()
@@ -268,6 +1160,10 @@ func expandHome(path string) string {
 	return path
 }
 
+func parseBool(value string) bool {
+	return value == "true" || value == "1"
+}
+
 func parseFloat32(value string) float32 {
 	if value == "" {
 		return 0
@@ -289,3 +1185,150 @@ func parseInt(value string) int {
 	}
 	return parsed
 }
+
+// parseList parses a comma separated list, dropping empty entries
+func parseList(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// AutoIPPrefixAllowed reports whether auto-apply may keep an IP address
+// according to autoip_prefixes and autoip_avoid_prefixes, with the reason
+// when it may not
+func (a *OCIAccount) AutoIPPrefixAllowed(ip string) (bool, string) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return true, ""
+	}
+	for _, prefix := range a.AutoIPAvoidPrefixes {
+		if _, network, err := net.ParseCIDR(prefix); err == nil && network.Contains(addr) {
+			return false, "in avoided range " + prefix
+		}
+	}
+	if len(a.AutoIPPrefixes) == 0 {
+		return true, ""
+	}
+	for _, prefix := range a.AutoIPPrefixes {
+		if _, network, err := net.ParseCIDR(prefix); err == nil && network.Contains(addr) {
+			return true, ""
+		}
+	}
+	return false, "outside the wanted ranges"
+}
+
+// IsProtectedCompartment reports whether the bot must not delete from the compartment
+func (a *OCIAccount) IsProtectedCompartment(compartmentID string) bool {
+	for _, id := range a.ProtectedCompartments {
+		if id == compartmentID {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIntList parses a comma separated list, skipping invalid entries
+// parseRange parses "200" or "200-300" into min and max, smaller first
+func parseRange(value string) (int, int) {
+	lo, hi, found := strings.Cut(value, "-")
+	min := parseInt(strings.TrimSpace(lo))
+	if !found {
+		return min, min
+	}
+	max := parseInt(strings.TrimSpace(hi))
+	if min > max {
+		min, max = max, min
+	}
+	return min, max
+}
+
+func parseIntList(value string) []int {
+	var result []int
+	for _, part := range strings.Split(value, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			result = append(result, n)
+		}
+	}
+	sort.Ints(result)
+	return result
+}
+
+// parseIntMap parses a comma separated list of key:number pairs, skipping
+// invalid entries
+func parseIntMap(value string) map[string]int {
+	result := make(map[string]int)
+	for _, part := range parseList(value) {
+		key, n, ok := strings.Cut(part, ":")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.Atoi(strings.TrimSpace(n)); err == nil {
+			result[strings.TrimSpace(key)] = v
+		}
+	}
+	return result
+}
+
+// LatencyVantages reports whether any latency vantage point is configured
+func (c *Config) LatencyVantages() bool {
+	return len(c.LatencyLocations) > 0 || len(c.LatencyProbes) > 0
+}
+
+// validateLatencyCriterion checks an account's autoip_max_latency against the
+// configured vantage points
+func (c *Config) validateLatencyCriterion(a *OCIAccount) error {
+	if a.AutoIPMaxLatency < 0 {
+		return fmt.Errorf("autoip_max_latency must not be negative")
+	}
+	if a.AutoIPMaxLatency > 0 && !c.LatencyVantages() {
+		return fmt.Errorf("autoip_max_latency needs latency_locations or a latency_probe_*")
+	}
+	for _, from := range a.AutoIPLatencyFrom {
+		if !slices.Contains(c.LatencyLocations, from) && c.LatencyProbes[from] == "" {
+			return fmt.Errorf("autoip_latency_from: %s is neither in latency_locations nor a latency_probe_*", from)
+		}
+	}
+	return nil
+}
+
+// validateRouteCriterion checks an account's autoip_routes
+func (c *Config) validateRouteCriterion(a *OCIAccount) error {
+	if len(a.AutoIPRoutes) > 0 && len(c.RouteLocations) == 0 {
+		return fmt.Errorf("autoip_routes needs route_locations")
+	}
+	for _, name := range a.AutoIPRoutes {
+		if !route.Valid(name) {
+			return fmt.Errorf("autoip_routes: unknown route %q, use %s", name, strings.Join(route.Types, ", "))
+		}
+	}
+	return nil
+}
+
+// validateSpeedTest checks an account's autoip_speedtest settings
+func validateSpeedTest(a *OCIAccount) error {
+	if a.AutoIPMinMbps < 0 {
+		return fmt.Errorf("autoip_min_mbps must not be negative")
+	}
+	if a.AutoIPSpeedTest == "" {
+		if a.AutoIPMinMbps > 0 || a.AutoIPSpeedTestPrivateIP != "" {
+			return fmt.Errorf("autoip_min_mbps and autoip_speedtest_private_ip need autoip_speedtest")
+		}
+		return nil
+	}
+	if err := speedtest.Valid(a.AutoIPSpeedTest); err != nil {
+		return fmt.Errorf("autoip_speedtest: %w", err)
+	}
+	if !strings.HasPrefix(a.AutoIPSpeedTestPrivateIP, "ocid1.privateip.") {
+		return fmt.Errorf("autoip_speedtest needs autoip_speedtest_private_ip, the OCID of a private IP on the test instance")
+	}
+	return nil
+}
+
+// CompositeScoring reports whether any score weight is configured
+func (c *Config) CompositeScoring() bool {
+	return c.ScoreDNSBLWeight != 0 || len(c.ScoreASNPenalty) > 0 || (c.ScoreCountry != "" && c.ScoreCountryWeight != 0)
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv is the environment variable holding the passphrase of an
+// encrypted config
+const PassphraseEnv = "OCI_BOT_PASSPHRASE"
+
+// encryptedHeader starts an encrypted file. The rest is the base64 of the
+// scrypt salt, the AES-GCM nonce and the ciphertext.
+const encryptedHeader = "# oci-bot encrypted v1\n"
+
+const saltSize = 16
+
+// ErrWrongPassphrase is returned when an encrypted file doesn't decrypt,
+// which with AES-GCM also covers a file changed after it was encrypted
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted file")
+
+// passphrase decrypts the config and the key files it points to. Files the
+// bot writes are encrypted with it once the config was loaded encrypted.
+var (
+	passphrase  string
+	sealSecrets bool
+)
+
+// SetPassphrase sets the passphrase encrypted files are read with. Call it
+// before Load.
+func SetPassphrase(p string) {
+	passphrase = p
+}
+
+// IsEncrypted reports whether content was written by Encrypt
+func IsEncrypted(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(encryptedHeader))
+}
+
+// IsEncryptedFile reports whether the file at path is encrypted
+func IsEncryptedFile(path string) (bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return IsEncrypted(content), nil
+}
+
+// deriveKey stretches the passphrase into an AES-256 key
+func deriveKey(pass string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(pass), salt, 1<<15, 8, 1, 32)
+}
+
+// Encrypt seals plain with AES-256-GCM under a key derived from pass
+func Encrypt(plain []byte, pass string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(pass, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := append(salt, nonce...)
+	sealed = gcm.Seal(sealed, nonce, plain, []byte(encryptedHeader))
+	return []byte(encryptedHeader + base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+// Decrypt opens content written by Encrypt
+func Decrypt(content []byte, pass string) ([]byte, error) {
+	if !IsEncrypted(content) {
+		return nil, fmt.Errorf("not an encrypted file")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(content[len(encryptedHeader):])))
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted file: %w", err)
+	}
+	if len(sealed) < saltSize {
+		return nil, fmt.Errorf("malformed encrypted file")
+	}
+	key, err := deriveKey(pass, sealed[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[saltSize:]
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("malformed encrypted file")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(encryptedHeader))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+// ReadSecretFile reads a file that may be encrypted, such as the config or a
+// private key, decrypting it with the passphrase
+func ReadSecretFile(path string) ([]byte, error) {
+	content, _, err := readSecretFile(path)
+	return content, err
+}
+
+// readSecretFile is ReadSecretFile also reporting whether the file was
+// encrypted
+func readSecretFile(path string) ([]byte, bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil || !IsEncrypted(content) {
+		return content, false, err
+	}
+	if passphrase == "" {
+		return nil, true, fmt.Errorf("%s is encrypted, set %s", path, PassphraseEnv)
+	}
+	plain, err := Decrypt(content, passphrase)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	return plain, true, nil
+}
+
+// SealSecret encrypts content the bot is about to write, such as a new
+// private key, when the config is encrypted, and returns it unchanged
+// otherwise
+func SealSecret(content []byte) ([]byte, error) {
+	if !sealSecrets {
+		return content, nil
+	}
+	return Encrypt(content, passphrase)
+}
+
+// OpenSecret decrypts content written by SealSecret. Content that isn't
+// encrypted is returned unchanged.
+func OpenSecret(content []byte) ([]byte, error) {
+	if !IsEncrypted(content) {
+		return content, nil
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("secret is encrypted, set %s", PassphraseEnv)
+	}
+	return Decrypt(content, passphrase)
+}
+
+// EncryptFile encrypts the file at path in place. It reports false for a
+// file that is already encrypted.
+func EncryptFile(path, pass string) (bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil || IsEncrypted(content) {
+		return false, err
+	}
+	sealed, err := Encrypt(content, pass)
+	if err != nil {
+		return false, err
+	}
+	return true, writeFileAtomic(path, sealed)
+}
+
+// DecryptFile decrypts the file at path in place. It reports false for a
+// file that isn't encrypted.
+func DecryptFile(path, pass string) (bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil || !IsEncrypted(content) {
+		return false, err
+	}
+	plain, err := Decrypt(content, pass)
+	if err != nil {
+		return false, fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	return true, writeFileAtomic(path, plain)
+}
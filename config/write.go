@@ -0,0 +1,246 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SetAccountValues rewrites key=value lines in the [account] section of the
+// conf file, appending keys the section doesn't have yet; an empty value
+// removes the key. Comments and other lines are kept as they are. The file is
+// replaced atomically, so a crash leaves either the old or the new version.
+func SetAccountValues(filename, account string, values map[string]string) error {
+	return setSectionValues(filename, account, values)
+}
+
+// SetGlobalValues is SetAccountValues for the global settings before the
+// first account section
+func SetGlobalValues(filename string, values map[string]string) error {
+	return setSectionValues(filename, "", values)
+}
+
+// setSectionValues rewrites the keys of a section, "" being the global one
+func setSectionValues(filename, section string, values map[string]string) error {
+	for _, k := range sortedKeys(values) {
+		if err := checkLine(k, values[k]); err != nil {
+			return err
+		}
+	}
+	content, err := ReadSecretFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	pending := make(map[string]string, len(values))
+	for k, v := range values {
+		pending[k] = v
+	}
+
+	var out []string
+	global := section == ""
+	inSection, found := global, global
+	// appendPending adds the keys not seen in the section after its last
+	// setting, leaving trailing comments to the next section's header
+	appendPending := func() {
+		end := len(out)
+		for end > 0 {
+			if trimmed := strings.TrimSpace(out[end-1]); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				break
+			}
+			end--
+		}
+		var added []string
+		for _, k := range sortedKeys(pending) {
+			if v := pending[k]; v != "" {
+				added = append(added, k+"="+v)
+			}
+		}
+		out = append(out[:end], append(added, out[end:]...)...)
+		clear(pending)
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			if inSection {
+				appendPending()
+			}
+			inSection = !global && trimmed == "["+section+"]"
+			found = found || inSection
+			out = append(out, line)
+			continue
+		}
+		if inSection && !strings.HasPrefix(trimmed, "#") {
+			if key, _, ok := strings.Cut(trimmed, "="); ok {
+				key = strings.TrimSpace(key)
+				if v, set := values[key]; set {
+					delete(pending, key)
+					if v != "" {
+						out = append(out, key+"="+v)
+					}
+					continue
+				}
+			}
+		}
+		out = append(out, line)
+	}
+	if !found {
+		return fmt.Errorf("account [%s] not found in %s", section, filename)
+	}
+	if inSection {
+		appendPending()
+	}
+	return replaceFile(filename, strings.Join(out, "\n")+"\n")
+}
+
+// checkLine refuses a key or value that would not stay on its own line, as
+// it could add settings or sections of its own to the file
+func checkLine(key, value string) error {
+	if strings.ContainsAny(key+value, "\r\n") {
+		return fmt.Errorf("%q must be a single line", key)
+	}
+	return nil
+}
+
+// AppendAccount adds a section with the credentials of a new account to the
+// end of the conf file. Other settings of the account are left to the user.
+func AppendAccount(filename string, a *OCIAccount) error {
+	fields := map[string]string{
+		"name": a.Name, "user": a.User, "fingerprint": a.Fingerprint, "tenancy": a.Tenancy,
+		"region": a.Region, "key_file": a.KeyFile, "key_passphrase": a.KeyPassphrase,
+	}
+	for _, k := range sortedKeys(fields) {
+		if err := checkLine(k, fields[k]); err != nil {
+			return err
+		}
+	}
+	content, err := ReadSecretFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == "["+a.Name+"]" {
+			return fmt.Errorf("account [%s] already exists in %s", a.Name, filename)
+		}
+	}
+
+	lines := []string{
+		"",
+		"[" + a.Name + "]",
+		"user=" + a.User,
+		"fingerprint=" + a.Fingerprint,
+		"tenancy=" + a.Tenancy,
+		"region=" + a.Region,
+		"key_file=" + a.KeyFile,
+	}
+	if a.KeyPassphrase != "" {
+		lines = append(lines, "key_passphrase="+a.KeyPassphrase)
+	}
+	return replaceFile(filename, strings.TrimRight(string(content), "\n")+"\n"+strings.Join(lines, "\n")+"\n")
+}
+
+// RemoveAccount deletes the [account] section and its [account.presets.*]
+// sections from the conf file, along with the comment lines directly above
+// their headers
+func RemoveAccount(filename, account string) error {
+	content, err := ReadSecretFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+
+	isHeader := func(line string) bool {
+		trimmed := strings.TrimSpace(line)
+		return strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]")
+	}
+	isAccount := func(line string) bool { return strings.TrimSpace(line) == "["+account+"]" }
+	isOwn := func(line string) bool {
+		return isAccount(line) || isHeader(line) && strings.HasPrefix(strings.TrimSpace(line), "["+account+".presets.")
+	}
+	if !slices.ContainsFunc(lines, isAccount) {
+		return fmt.Errorf("account [%s] not found in %s", account, filename)
+	}
+	for {
+		start := slices.IndexFunc(lines, isOwn)
+		if start < 0 {
+			break
+		}
+		end := len(lines)
+		if next := slices.IndexFunc(lines[start+1:], isHeader); next >= 0 {
+			end = start + 1 + next
+			// The comment directly above the next header and the blank lines
+			// before it belong to the next section
+			for end > start+1 && strings.HasPrefix(strings.TrimSpace(lines[end-1]), "#") {
+				end--
+			}
+			for end > start+1 && strings.TrimSpace(lines[end-1]) == "" {
+				end--
+			}
+		}
+		for start > 0 && strings.HasPrefix(strings.TrimSpace(lines[start-1]), "#") {
+			start--
+		}
+		if start > 0 && strings.TrimSpace(lines[start-1]) == "" {
+			start-- // The blank line separating the section
+		}
+		lines = slices.Concat(lines[:start], lines[end:])
+	}
+	return replaceFile(filename, strings.Join(lines, "\n")+"\n")
+}
+
+// replaceFile atomically replaces filename with content, keeping its mode and
+// encrypting it when the config is encrypted
+func replaceFile(filename, content string) error {
+	data, err := SealSecret([]byte(content))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt config file: %w", err)
+	}
+	return writeFileAtomic(filename, data)
+}
+
+// writeFileAtomic replaces filename with data via a temp file and rename,
+// keeping its mode, so a crash leaves either the old or the new version
+func writeFileAtomic(filename string, data []byte) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", filename, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(info.Mode().Perm())
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", filename, err)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,119 @@
+// Package globalping runs measurements on Globalping (https://globalping.io),
+// a network of probes that ping or trace an address from where they are.
+package globalping
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	apiURL   = "https://api.globalping.io/v1/measurements"
+	pollWait = 500 * time.Millisecond
+)
+
+// Client makes measurements, with a token when set to raise the hourly limit
+type Client struct {
+	Token string
+}
+
+// Probe is where a measurement ran
+type Probe struct {
+	City    string `json:"city"`
+	Country string `json:"country"`
+	ASN     int    `json:"asn"`
+	Network string `json:"network"`
+}
+
+// String describes the probe, e.g. "Shanghai, CN, AS4134"
+func (p Probe) String() string {
+	return fmt.Sprintf("%s, %s, AS%d", p.City, p.Country, p.ASN)
+}
+
+// Result is the outcome of a measurement on one probe. Result holds the
+// type-specific data, e.g. ping stats or traceroute hops.
+type Result struct {
+	Probe  Probe           `json:"probe"`
+	Result json.RawMessage `json:"result"`
+}
+
+// Measure runs a measurement of kind ("ping", "mtr", ...) to target from one
+// probe in location, e.g. "Shanghai" or "China+AS4134", and waits for it
+func (c Client) Measure(ctx context.Context, kind, target, location string, options map[string]any) (*Result, error) {
+	body, err := json.Marshal(map[string]any{
+		"type":               kind,
+		"target":             target,
+		"locations":          []map[string]any{{"magic": location, "limit": 1}},
+		"measurementOptions": options,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := c.call(ctx, http.MethodPost, apiURL, body, &created); err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollWait):
+		}
+
+		var m struct {
+			Status  string   `json:"status"`
+			Results []Result `json:"results"`
+		}
+		if err := c.call(ctx, http.MethodGet, apiURL+"/"+url.PathEscape(created.ID), nil, &m); err != nil {
+			return nil, err
+		}
+		if m.Status == "in-progress" {
+			continue
+		}
+		if len(m.Results) == 0 {
+			return nil, errors.New("globalping: no probe in " + location)
+		}
+		return &m.Results[0], nil
+	}
+}
+
+// call makes an API call and decodes the answer into v
+func (c Client) call(ctx context.Context, method, endpoint string, body []byte, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Error.Message != "" {
+			return fmt.Errorf("globalping: %s", apiErr.Error.Message)
+		}
+		return fmt.Errorf("globalping answered %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -3,9 +3,14 @@ module oci-bot
 go 1.25.5
 
 require (
+	github.com/bwmarrin/discordgo v0.29.0
 	github.com/chromedp/chromedp v0.14.2
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
+	github.com/gorilla/websocket v1.4.2
 	github.com/oracle/oci-go-sdk/v65 v65.105.2
+	golang.org/x/crypto v0.45.0
+	golang.org/x/term v0.37.0
+	rsc.io/qr v0.2.0
 )
 
 require (
@@ -18,6 +23,5 @@ require (
 	github.com/gofrs/flock v0.10.0 // indirect
 	github.com/sony/gobreaker v0.5.0 // indirect
 	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78 // indirect
-	golang.org/x/crypto v0.45.0 // indirect
 	golang.org/x/sys v0.38.0 // indirect
 )
@@ -0,0 +1,74 @@
+package iplookup
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// DefaultBlocklists are the DNS blocklists checked by Blocklisted. They are
+// free for low-volume queries.
+var DefaultBlocklists = []string{
+	"zen.spamhaus.org",
+	"bl.spamcop.net",
+	"b.barracudacentral.org",
+	"dnsbl-1.uceprotect.net",
+	"psbl.surriel.com",
+}
+
+// Blocklisted checks an IP address against DNS blocklists concurrently and
+// returns the ones listing it. Lists that could not be asked are returned
+// separately, e.g. Spamhaus refusing queries from public resolvers.
+func Blocklisted(ctx context.Context, ip string, lists []string) (listed, failed []string) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, lists
+	}
+	name := reverseName(parsed)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, list := range lists {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hit, err := queryBlocklist(ctx, name+"."+list)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				failed = append(failed, list)
+			case hit:
+				listed = append(listed, list)
+			}
+		}()
+	}
+	wg.Wait()
+	return listed, failed
+}
+
+// queryBlocklist reports whether a blocklist has an entry for name. Answers
+// in 127.255.255.0/24 are the list's error codes, not entries.
+func queryBlocklist(ctx context.Context, name string) (bool, error) {
+	addrs, err := resolver.LookupIPAddr(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, addr := range addrs {
+		v4 := addr.IP.To4()
+		if v4 == nil || v4[0] != 127 {
+			continue
+		}
+		if v4[1] == 255 && v4[2] == 255 {
+			return false, errors.New("query refused by " + name)
+		}
+		return true, nil
+	}
+	return false, nil
+}
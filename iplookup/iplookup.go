@@ -0,0 +1,85 @@
+// Package iplookup looks up public information about IP addresses: the
+// announcing network and country, reverse DNS and DNS blocklist entries over
+// DNS, and the registered organization over WHOIS.
+package iplookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// resolver is used for every lookup; PreferGo keeps behaviour the same across
+// platforms
+var resolver = &net.Resolver{PreferGo: true}
+
+// Origin is the network an IP address is announced from
+type Origin struct {
+	ASN      string // e.g. "31898"
+	Prefix   string // e.g. "129.146.0.0/16"
+	Country  string // ISO 3166 code of the registration, e.g. "US"
+	Registry string // e.g. "arin"
+}
+
+// LookupOrigin returns the origin of an IP address from Team Cymru's IP to
+// ASN DNS service
+func LookupOrigin(ctx context.Context, ip string) (*Origin, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ip)
+	}
+	zone := "origin.asn.cymru.com"
+	if parsed.To4() == nil {
+		zone = "origin6.asn.cymru.com"
+	}
+
+	records, err := resolver.LookupTXT(ctx, reverseName(parsed)+"."+zone)
+	if err != nil {
+		return nil, fmt.Errorf("origin lookup failed: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no origin for %s", ip)
+	}
+
+	// "31898 | 129.146.0.0/16 | US | arin | 2011-05-12"
+	fields := strings.Split(records[0], "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("unexpected origin record: %q", records[0])
+	}
+	return &Origin{ASN: fields[0], Prefix: fields[1], Country: fields[2], Registry: fields[3]}, nil
+}
+
+// LookupPTR returns the reverse DNS name of an IP address, "" when it has none
+func LookupPTR(ctx context.Context, ip string) (string, error) {
+	names, err := resolver.LookupAddr(ctx, ip)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return "", nil
+		}
+		return "", fmt.Errorf("reverse lookup failed: %w", err)
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	return strings.TrimSuffix(names[0], "."), nil
+}
+
+// reverseName returns the reversed label form of an address used by DNS
+// based lookups: "4.3.2.1" for 1.2.3.4, nibbles for IPv6
+func reverseName(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d", v4[3], v4[2], v4[1], v4[0])
+	}
+	v6 := ip.To16()
+	labels := make([]string, 0, 32)
+	for i := len(v6) - 1; i >= 0; i-- {
+		labels = append(labels, fmt.Sprintf("%x", v6[i]&0x0f), fmt.Sprintf("%x", v6[i]>>4))
+	}
+	return strings.Join(labels, ".")
+}
@@ -0,0 +1,123 @@
+package iplookup
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// whoisIANA answers which regional registry holds an address range
+const whoisIANA = "whois.iana.org"
+
+// Registration is the WHOIS record of the network an IP address belongs to
+type Registration struct {
+	NetName string // e.g. "ORACLE-BMC"
+	Org     string // Registered organization, e.g. "Oracle Corporation"
+	Country string // e.g. "US"
+	Server  string // WHOIS server that answered
+}
+
+// orgFields are the WHOIS attributes naming the registrant, best first. RIRs
+// disagree on the names: ARIN uses OrgName, RIPE/APNIC/AFRINIC org-name or
+// descr, LACNIC owner. ARIN reassignments to customers carry CustName.
+var orgFields = []string{"custname", "orgname", "org-name", "owner", "organization", "descr"}
+
+// LookupWhois asks IANA which registry is responsible for an IP address and
+// returns that registry's record of it
+func LookupWhois(ctx context.Context, ip string) (*Registration, error) {
+	if net.ParseIP(ip) == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	referral, err := queryWhois(ctx, whoisIANA, ip)
+	if err != nil {
+		return nil, err
+	}
+	server := whoisValues(referral, false)["refer"]
+	if server == "" {
+		return nil, fmt.Errorf("no WHOIS server for %s", ip)
+	}
+
+	query, arin := ip, server == "whois.arin.net"
+	if arin {
+		query = "n + " + ip // Networks with their organizations
+	}
+	record, err := queryWhois(ctx, server, query)
+	if err != nil {
+		return nil, err
+	}
+
+	values := whoisValues(record, arin)
+	reg := &Registration{
+		NetName: values["netname"],
+		Country: strings.ToUpper(values["country"]),
+		Server:  server,
+	}
+	for _, field := range orgFields {
+		if v := values[field]; v != "" {
+			reg.Org = v
+			break
+		}
+	}
+	if reg.NetName == "" && reg.Org == "" {
+		return nil, fmt.Errorf("no registration for %s at %s", ip, server)
+	}
+	return reg, nil
+}
+
+// queryWhois sends one query to a WHOIS server on port 43 and returns the
+// whole answer
+func queryWhois(ctx context.Context, server, query string) (string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(server, "43"))
+	if err != nil {
+		return "", fmt.Errorf("whois %s: %w", server, err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(30 * time.Second)
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := io.WriteString(conn, query+"\r\n"); err != nil {
+		return "", fmt.Errorf("whois %s: %w", server, err)
+	}
+	answer, err := io.ReadAll(io.LimitReader(conn, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("whois %s: %w", server, err)
+	}
+	return string(answer), nil
+}
+
+// whoisValues collects "key: value" lines, keys lowercased. The first value
+// of each key wins; with lastWins the last one does, ARIN lists the
+// networks containing the address from the largest to the most specific.
+func whoisValues(answer string, lastWins bool) map[string]string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(answer))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "%") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if key == "" || value == "" || strings.Contains(key, " ") {
+			continue
+		}
+		if _, seen := values[key]; !seen || lastWins {
+			values[key] = value
+		}
+	}
+	return values
+}
@@ -0,0 +1,197 @@
+package ippure
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheRetention is how long results stay around for Last after they are no
+// longer fresh enough to answer Check
+const cacheRetention = 24 * time.Hour
+
+// Checker runs Check with a cache: a result is reused for TTL, and checks of
+// an IP already being checked wait for that check instead of starting a
+// browser of their own
+type Checker struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	cache    map[string]*IPInfo // IP -> last result
+	inflight map[string]*call   // IP -> check running
+	swept    time.Time
+}
+
+// call is a check shared by everyone asking for the IP while it runs
+type call struct {
+	done    chan struct{}
+	info    *IPInfo
+	err     error
+	waiters int // Callers still waiting, the check is cancelled when none are left
+	cancel  context.CancelFunc
+}
+
+// NewChecker returns a Checker reusing results for ttl, none with 0
+func NewChecker(ttl time.Duration) *Checker {
+	return &Checker{ttl: ttl, cache: make(map[string]*IPInfo), inflight: make(map[string]*call)}
+}
+
+// SetTTL changes how long results are reused
+func (c *Checker) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
+
+// Check returns the result of a check of ip younger than the TTL, or checks
+func (c *Checker) Check(ctx context.Context, ip string) (*IPInfo, error) {
+	c.mu.Lock()
+	if info, ok := c.cache[ip]; ok && time.Since(info.Checked) < c.ttl {
+		c.mu.Unlock()
+		return info.clone(), nil
+	}
+	c.mu.Unlock()
+	return c.Fresh(ctx, ip)
+}
+
+// Fresh checks ip without looking at the cache, joining a check of ip that
+// is already running
+func (c *Checker) Fresh(ctx context.Context, ip string) (*IPInfo, error) {
+	c.mu.Lock()
+	cl, ok := c.inflight[ip]
+	if !ok {
+		// Detached from ctx: other callers may still want the result when
+		// the first one gives up
+		var runCtx context.Context
+		var cancel context.CancelFunc
+		if deadline, ok := ctx.Deadline(); ok {
+			runCtx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+		} else {
+			runCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+		}
+		cl = &call{done: make(chan struct{}), cancel: cancel}
+		c.inflight[ip] = cl
+		go c.run(runCtx, ip, cl)
+	}
+	cl.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		if cl.err != nil {
+			return nil, cl.err
+		}
+		return cl.info.clone(), nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		cl.waiters--
+		if cl.waiters == 0 {
+			// Nobody wants it anymore; the next caller starts over
+			cl.cancel()
+			if c.inflight[ip] == cl {
+				delete(c.inflight, ip)
+			}
+		}
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// CheckBatch is CheckBatch answering from the cache where it can
+func (c *Checker) CheckBatch(ctx context.Context, ips []string) []Result {
+	results := make([]Result, len(ips))
+	var missing []string
+	var at []int // Index in ips of each missing IP
+	c.mu.Lock()
+	for i, ip := range ips {
+		if info, ok := c.cache[ip]; ok && time.Since(info.Checked) < c.ttl {
+			results[i].Info = info.clone()
+		} else {
+			missing = append(missing, ip)
+			at = append(at, i)
+		}
+	}
+	c.mu.Unlock()
+	if len(missing) == 0 {
+		return results
+	}
+
+	checked := CheckBatch(ctx, missing)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for j, r := range checked {
+		results[at[j]] = r
+		if r.Err == nil {
+			c.store(r.Info)
+		}
+	}
+	return results
+}
+
+// run makes the check of a call and caches a result worth reusing
+func (c *Checker) run(ctx context.Context, ip string, cl *call) {
+	defer cl.cancel()
+	info, err := Check(ctx, ip)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inflight[ip] == cl {
+		delete(c.inflight, ip)
+	}
+	cl.info, cl.err = info, err
+	if err == nil {
+		c.store(info)
+	}
+	close(cl.done)
+}
+
+// store caches a result worth reusing, with c.mu held
+func (c *Checker) store(info *IPInfo) {
+	if info.Available() && info.PurityScore != Unknown {
+		c.cache[info.IPAddress] = info.clone()
+		c.sweep()
+	}
+}
+
+// Last returns the latest result for ip, however old, for showing next to
+// an IP without checking it
+func (c *Checker) Last(ip string) (*IPInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info, ok := c.cache[ip]
+	if !ok {
+		return nil, false
+	}
+	return info.clone(), true
+}
+
+// Matching returns the latest results of the IPs starting with prefix
+func (c *Checker) Matching(prefix string) []*IPInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var found []*IPInfo
+	for ip, info := range c.cache {
+		if strings.HasPrefix(ip, prefix) {
+			found = append(found, info.clone())
+		}
+	}
+	return found
+}
+
+// sweep drops results past cacheRetention, at most once an hour
+func (c *Checker) sweep() {
+	if time.Since(c.swept) < time.Hour {
+		return
+	}
+	c.swept = time.Now()
+	for ip, info := range c.cache {
+		if time.Since(info.Checked) > max(cacheRetention, c.ttl) {
+			delete(c.cache, ip)
+		}
+	}
+}
+
+func (info *IPInfo) clone() *IPInfo {
+	copy := *info
+	return &copy
+}
@@ -0,0 +1,309 @@
+package ippure
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// cftURL lists the current Chrome for Testing builds with their downloads
+const cftURL = "https://googlechromelabs.github.io/chrome-for-testing/last-known-good-versions-with-downloads.json"
+
+// ErrNoChrome is returned by Preflight when no Chrome could be found
+var ErrNoChrome = errors.New("no Chrome or Chromium found")
+
+// ChromeOptions says where Chrome is
+type ChromeOptions struct {
+	Path     string // Binary to use, found on the usual places when empty
+	Download bool   // Download chrome-headless-shell into Dir when none is found
+	Dir      string // Where downloads go and are looked for
+}
+
+// Unavailable fills the fields of a result made without a browser
+const Unavailable = "不可用"
+
+var (
+	chromeMu   sync.Mutex
+	chromePath string // Binary local checks run, chromedp's own search when empty
+	noBrowser  bool   // No Chrome: Check answers Unavailable without trying
+)
+
+// DisableBrowser makes Check answer at once with every field Unavailable,
+// for running without Chrome. Agents set with SetAgents are still asked.
+func DisableBrowser() {
+	chromeMu.Lock()
+	defer chromeMu.Unlock()
+	noBrowser = true
+}
+
+// BrowserAvailable reports whether checks can run, here or on an agent
+func BrowserAvailable() bool {
+	if len(agentOrder()) > 0 {
+		return true
+	}
+	chromeMu.Lock()
+	defer chromeMu.Unlock()
+	return !noBrowser
+}
+
+// Available reports whether the result comes from ippure.com, not from a
+// check made without a browser
+func (info *IPInfo) Available() bool {
+	return info.PurityScore != Unavailable
+}
+
+// chromeNames are the binaries looked up in PATH, most specific first
+var chromeNames = []string{
+	"chrome-headless-shell", "headless-shell",
+	"google-chrome", "google-chrome-stable", "chromium", "chromium-browser", "chrome",
+}
+
+// chromePaths are the usual install locations outside PATH
+var chromePaths = map[string][]string{
+	"linux": {
+		"/opt/google/chrome/chrome",
+		"/snap/bin/chromium",
+		"/usr/lib/chromium/chromium",
+		"/headless-shell/headless-shell",
+	},
+	"darwin": {
+		"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+		"/Applications/Chromium.app/Contents/MacOS/Chromium",
+	},
+	"windows": {
+		`C:\Program Files\Google\Chrome\Application\chrome.exe`,
+		`C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`,
+	},
+}
+
+// Preflight finds the Chrome local checks run, downloading one when allowed,
+// and uses it from now on. Returns its path.
+func Preflight(ctx context.Context, opts ChromeOptions) (string, error) {
+	path, err := findChrome(opts)
+	if errors.Is(err, ErrNoChrome) && opts.Download {
+		path, err = downloadHeadlessShell(ctx, opts.Dir)
+	}
+	if err != nil {
+		return "", err
+	}
+	chromeMu.Lock()
+	chromePath = path
+	chromeMu.Unlock()
+	return path, nil
+}
+
+// findChrome returns the configured binary, or the first one found in PATH,
+// the usual install locations and the download directory
+func findChrome(opts ChromeOptions) (string, error) {
+	if opts.Path != "" {
+		if err := executable(opts.Path); err != nil {
+			return "", fmt.Errorf("chrome_path %s: %w", opts.Path, err)
+		}
+		return opts.Path, nil
+	}
+	for _, name := range chromeNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	candidates := append([]string(nil), chromePaths[runtime.GOOS]...)
+	if opts.Dir != "" {
+		if platform, ok := cftPlatform(); ok {
+			candidates = append(candidates, downloadedBinary(opts.Dir, platform))
+		}
+	}
+	for _, path := range candidates {
+		if executable(path) == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%w: looked for %s in PATH and at %s", ErrNoChrome,
+		strings.Join(chromeNames, ", "), strings.Join(candidates, ", "))
+}
+
+// executable checks that path is a file that can be run
+func executable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.New("is a directory")
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		return errors.New("is not executable")
+	}
+	return nil
+}
+
+// cftPlatform names this system as Chrome for Testing does. There are no
+// Linux ARM builds.
+func cftPlatform() (string, bool) {
+	switch runtime.GOOS + "/" + runtime.GOARCH {
+	case "linux/amd64":
+		return "linux64", true
+	case "darwin/arm64":
+		return "mac-arm64", true
+	case "darwin/amd64":
+		return "mac-x64", true
+	case "windows/amd64":
+		return "win64", true
+	case "windows/386":
+		return "win32", true
+	}
+	return "", false
+}
+
+// downloadedBinary is where a downloaded chrome-headless-shell lives in dir
+func downloadedBinary(dir, platform string) string {
+	name := "chrome-headless-shell"
+	if strings.HasPrefix(platform, "win") {
+		name += ".exe"
+	}
+	return filepath.Join(dir, "chrome-headless-shell-"+platform, name)
+}
+
+// downloadHeadlessShell downloads the current stable chrome-headless-shell
+// from Chrome for Testing into dir
+func downloadHeadlessShell(ctx context.Context, dir string) (string, error) {
+	platform, ok := cftPlatform()
+	if !ok {
+		return "", fmt.Errorf("%w, and Chrome for Testing has no build for %s/%s: install Chromium from the package manager",
+			ErrNoChrome, runtime.GOOS, runtime.GOARCH)
+	}
+
+	var versions struct {
+		Channels struct {
+			Stable struct {
+				Version   string `json:"version"`
+				Downloads struct {
+					HeadlessShell []struct {
+						Platform string `json:"platform"`
+						URL      string `json:"url"`
+					} `json:"chrome-headless-shell"`
+				} `json:"downloads"`
+			} `json:"Stable"`
+		} `json:"channels"`
+	}
+	if err := getJSON(ctx, cftURL, &versions); err != nil {
+		return "", fmt.Errorf("finding chrome-headless-shell: %w", err)
+	}
+	var zipURL string
+	for _, d := range versions.Channels.Stable.Downloads.HeadlessShell {
+		if d.Platform == platform {
+			zipURL = d.URL
+		}
+	}
+	if zipURL == "" {
+		return "", fmt.Errorf("no chrome-headless-shell %s build for %s", versions.Channels.Stable.Version, platform)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	archive, err := os.CreateTemp(dir, "download-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	if err := download(ctx, zipURL, archive); err != nil {
+		return "", fmt.Errorf("downloading %s: %w", zipURL, err)
+	}
+	if err := unzip(archive.Name(), dir); err != nil {
+		return "", fmt.Errorf("unpacking %s: %w", zipURL, err)
+	}
+	path := downloadedBinary(dir, platform)
+	if err := executable(path); err != nil {
+		return "", fmt.Errorf("downloaded chrome-headless-shell: %w", err)
+	}
+	return path, nil
+}
+
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("answered %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func download(ctx context.Context, url string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("answered %s", resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// unzip extracts the archive at path into dir, keeping file modes
+func unzip(path, dir string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		target := filepath.Join(dir, f.Name)
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("%s is outside the archive", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := extract(f, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extract(f *zip.File, target string) error {
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, f.Mode().Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
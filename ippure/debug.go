@@ -0,0 +1,157 @@
+package ippure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	captureTimeout = 15 * time.Second // Taking the screenshot and HTML of a broken check
+	maxCaptures    = 20               // Captures kept in the debug directory, the oldest go
+)
+
+// Debug says what is kept of local checks that fail or find empty fields,
+// for telling a changed ippure.com layout from a bad IP
+type Debug struct {
+	Dir       string        // Screenshots and page HTML go here, nothing is kept when empty
+	OnCapture func(Capture) // Called after each capture, even a failed one (optional)
+}
+
+// Capture is what was kept of one broken check
+type Capture struct {
+	IP         string
+	Reason     string // The error, or the fields that came back empty
+	Screenshot string // Path of the PNG, empty when none could be taken
+	HTML       string // Path of the page HTML, empty when none could be read
+	PNG        []byte
+	Err        error // Why the screenshot or HTML is missing
+}
+
+// Files returns the paths of what was saved
+func (c Capture) Files() []string {
+	var files []string
+	for _, f := range []string{c.Screenshot, c.HTML} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+var (
+	debugMu sync.Mutex
+	debug   Debug
+)
+
+// SetDebug sets what is kept of the broken checks from now on
+func SetDebug(d Debug) {
+	debugMu.Lock()
+	defer debugMu.Unlock()
+	debug = d
+}
+
+// emptyFields names the fields the page didn't yield
+func emptyFields(info *IPInfo) []string {
+	var empty []string
+	for _, f := range []struct{ name, value string }{
+		{"purity", info.PurityScore},
+		{"purity_level", info.PurityLevel},
+		{"ip_type", info.IPType},
+		{"native", info.IsNative},
+	} {
+		if f.value == "" {
+			empty = append(empty, f.name)
+		}
+	}
+	return empty
+}
+
+// capture saves a screenshot and the HTML of the page the check of ip ended
+// on, when a debug directory is set. chromeCtx is the browser's context,
+// still alive after the check timed out.
+func capture(chromeCtx context.Context, ip, reason string) {
+	debugMu.Lock()
+	d := debug
+	debugMu.Unlock()
+	if d.Dir == "" {
+		return
+	}
+
+	c := save(chromeCtx, d.Dir, ip, reason)
+	if d.OnCapture != nil {
+		d.OnCapture(c)
+	}
+}
+
+// save takes the screenshot and HTML and writes them into dir
+func save(chromeCtx context.Context, dir, ip, reason string) Capture {
+	c := Capture{IP: ip, Reason: reason}
+	ctx, cancel := context.WithTimeout(chromeCtx, captureTimeout)
+	defer cancel()
+	// Taken apart: a page still loading may give one and not the other
+	var png []byte
+	var html string
+	shotErr := chromedp.Run(ctx, chromedp.FullScreenshot(&png, 100))
+	htmlErr := chromedp.Run(ctx, chromedp.OuterHTML("html", &html, chromedp.ByQuery))
+	if shotErr != nil && htmlErr != nil {
+		c.Err = fmt.Errorf("capturing the page: %w", errors.Join(shotErr, htmlErr))
+		return c
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		c.Err = err
+		return c
+	}
+
+	// Sortable by time; colons of IPv6 addresses don't go in file names
+	base := filepath.Join(dir, time.Now().Format("20060102-150405")+"-"+strings.ReplaceAll(ip, ":", "_"))
+	var errs []error
+	if shotErr != nil {
+		errs = append(errs, fmt.Errorf("screenshot: %w", shotErr))
+	} else if err := os.WriteFile(base+".png", png, 0644); err != nil {
+		errs = append(errs, err)
+	} else {
+		c.Screenshot, c.PNG = base+".png", png
+	}
+	if htmlErr != nil {
+		errs = append(errs, fmt.Errorf("page HTML: %w", htmlErr))
+	} else if err := os.WriteFile(base+".html", []byte(fmt.Sprintf("<!-- %s: %s -->\n%s", ip, reason, html)), 0644); err != nil {
+		errs = append(errs, err)
+	} else {
+		c.HTML = base + ".html"
+	}
+	c.Err = errors.Join(errs...)
+	pruneCaptures(dir)
+	return c
+}
+
+// pruneCaptures removes all but the newest maxCaptures captures from dir
+func pruneCaptures(dir string) {
+	var names []string
+	for _, pattern := range []string{"*.png", "*.html"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		names = append(names, matches...)
+	}
+	// Both files of a capture share its base name
+	bases := make([]string, 0, len(names))
+	for _, name := range names {
+		bases = append(bases, strings.TrimSuffix(name, filepath.Ext(name)))
+	}
+	slices.Sort(bases)
+	bases = slices.Compact(bases)
+	if len(bases) <= maxCaptures {
+		return
+	}
+	for _, base := range bases[:len(bases)-maxCaptures] {
+		os.Remove(base + ".png")
+		os.Remove(base + ".html")
+	}
+}
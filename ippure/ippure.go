@@ -2,8 +2,13 @@ package ippure
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os/exec"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/chromedp/chromedp"
@@ -14,12 +19,121 @@ type IPInfo struct {
 	IPAddress   string // IP address
 	PurityScore string // Purity score, e.g. "7%"
 	PurityLevel string // Purity level, e.g. "极其纯净"
-	IPType      string // IP type: 机房IP / 住宅IP
-	IsNative    string // IP origin: 原生IP / 非原生IP
+	IPType      string // IP type: TypeDatacenter / TypeResidential
+	IsNative    string // IP origin: OriginNative / OriginNonNative / OriginBroadcast
+	Checked     time.Time
 }
 
-// Check checks IP purity via ippure.com
+// Check checks IP purity via ippure.com, on the SetAgents agents when set
 func Check(ctx context.Context, ip string) (*IPInfo, error) {
+	var info *IPInfo
+	var err error
+	switch list := agentOrder(); {
+	case len(list) > 0:
+		info, err = checkRemote(ctx, ip, list)
+	case !BrowserAvailable():
+		info = &IPInfo{IPAddress: ip, PurityScore: Unavailable, PurityLevel: Unavailable, IPType: Unavailable, IsNative: Unavailable}
+	default:
+		info, err = checkLocal(ctx, ip)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.Checked = time.Now()
+	return info, nil
+}
+
+// pageTimeout bounds the check of one IP on the page, loading it included
+const pageTimeout = 60 * time.Second
+
+// Result is the outcome for one IP of CheckBatch
+type Result struct {
+	Info *IPInfo
+	Err  error
+}
+
+// CheckBatch checks ips one after another in a single browser session,
+// typing each into the page already open instead of starting Chrome and
+// loading ippure.com for every IP. Results are in the order of ips. After a
+// failed IP the rest go on in a new session.
+func CheckBatch(ctx context.Context, ips []string) []Result {
+	results := make([]Result, len(ips))
+	if len(agentOrder()) > 0 || !BrowserAvailable() {
+		for i, ip := range ips {
+			results[i].Info, results[i].Err = Check(ctx, ip)
+		}
+		return results
+	}
+
+	var s *session
+	defer func() {
+		if s != nil {
+			s.close()
+		}
+	}()
+	for i, ip := range ips {
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			continue
+		}
+		if s == nil {
+			var err error
+			if s, err = openSession(ctx); err != nil {
+				results[i].Err = err
+				continue
+			}
+		}
+		info, err := s.check(ctx, ip)
+		if err != nil {
+			// The page or the browser may be broken, start over
+			s.close()
+			s = nil
+			results[i].Err = err
+			continue
+		}
+		info.Checked = time.Now()
+		results[i].Info = info
+	}
+	return results
+}
+
+// checkLocal checks in a headless Chrome on this machine. Beyond the
+// SetLimits concurrency limit it waits for a running check to finish first.
+func checkLocal(ctx context.Context, ip string) (*IPInfo, error) {
+	s, err := openSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+	return s.check(ctx, ip)
+}
+
+// session is a headless Chrome checking IPs on ippure.com one after another.
+// It holds a SetLimits slot until closed.
+type session struct {
+	rules      *Rules
+	limits     Limits
+	chromeCtx  context.Context // The browser, outliving a ctx that ran out of time
+	liveCtx    context.Context // Ends when the browser uses too much memory
+	overMemory atomic.Bool
+	loaded     bool     // The page is open, the next IP is typed right in
+	closers    []func() // In order
+}
+
+// openSession waits for a slot and starts Chrome. The browser outlives a ctx
+// that ran out of time, to capture the page a check got stuck on; cancelling
+// ctx ends it.
+func openSession(ctx context.Context) (*session, error) {
+	r, err := activeRules()
+	if err != nil {
+		return nil, err
+	}
+	limits, release, err := acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := &session{rules: r, limits: limits}
+
 	// Chrome options for headless browsing
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
 		chromedp.Flag("headless", true),
@@ -30,146 +144,151 @@ func Check(ctx context.Context, ip string) (*IPInfo, error) {
 		chromedp.Flag("disable-background-networking", true),
 		chromedp.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"),
 	)
+	chromeMu.Lock()
+	if chromePath != "" {
+		opts = append(opts, chromedp.ExecPath(chromePath))
+	}
+	chromeMu.Unlock()
+	if limits.MaxMemoryMB > 0 {
+		// One renderer, its JavaScript heap well below the limit
+		opts = append(opts,
+			chromedp.Flag("renderer-process-limit", "1"),
+			chromedp.Flag("js-flags", fmt.Sprintf("--max-old-space-size=%d", max(limits.MaxMemoryMB/2, 64))),
+		)
+	}
 
 	// Create headless Chrome context
-	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, opts...)
-	defer allocCancel()
-
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.WithoutCancel(ctx), opts...)
 	chromeCtx, chromeCancel := chromedp.NewContext(allocCtx)
-	defer chromeCancel()
+	liveCtx, liveCancel := context.WithCancel(chromeCtx)
+	stop := context.AfterFunc(ctx, func() {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			chromeCancel()
+		}
+	})
+	s.chromeCtx, s.liveCtx = chromeCtx, liveCtx
+	s.closers = []func(){func() { stop() }, liveCancel, chromeCancel, allocCancel, release}
 
-	// Set timeout for the entire operation
-	chromeCtx, cancel := context.WithTimeout(chromeCtx, 60*time.Second)
-	defer cancel()
+	// Start Chrome without a timeout, which would end the browser with it
+	if err := chromedp.Run(chromeCtx); err != nil {
+		s.close()
+		return nil, browserError(err)
+	}
 
-	url := "https://ippure.com/"
-
-	var purityText string
-
-	// JavaScript to extract IP info
-	extractJS := `
-	(() => {
-		const result = {
-			purity: '',
-			purityLevel: '',
-			ipType: '',
-			native: ''
-		};
-		
-		const allText = document.body.innerText;
-		
-		// Match IPPure系数: format is "IPPure系数\n7% 极度纯净" or similar
-		const purityMatch = allText.match(/IPPure系数\s*\n?\s*(\d+)%\s*([^\n]*)/);
-		if (purityMatch) {
-			result.purity = purityMatch[1] + '%';
-			result.purityLevel = purityMatch[2].trim();
-		}
-		
-		// Match IP属性: format is "IP属性\n机房IP"
-		const attrMatch = allText.match(/IP属性\s*\n?\s*(机房IP|住宅IP|Data Center|Residential)/);
-		if (attrMatch) {
-			result.ipType = attrMatch[1];
+	// Watch Chrome's memory while the session lasts
+	if limits.MaxMemoryMB > 0 && MemoryLimitSupported {
+		if proc := chromedp.FromContext(chromeCtx).Browser.Process(); proc != nil {
+			stopWatch := watchMemory(proc, limits.MaxMemoryMB, func() {
+				s.overMemory.Store(true)
+				liveCancel()
+			})
+			s.closers = append([]func(){func() { stopWatch() }}, s.closers...)
 		}
-		
-		// Match IP来源: format is "IP来源\n原生IP"
-		const nativeMatch = allText.match(/IP来源\s*\n?\s*(原生IP|非原生IP|广播IP|Native IP|Broadcast)/);
-		if (nativeMatch) {
-			result.native = nativeMatch[1];
-		}
-		
-		return JSON.stringify(result);
-	})()`
-
-	err := chromedp.Run(chromeCtx,
-		// Navigate to the site
-		chromedp.Navigate(url),
-		chromedp.Sleep(3*time.Second),
+	}
+	return s, nil
+}
+
+// close ends the browser and gives the slot back
+func (s *session) close() {
+	for _, f := range s.closers {
+		f()
+	}
+}
+
+// check types ip into the page, opening it first unless it's already open
+func (s *session) check(ctx context.Context, ip string) (*IPInfo, error) {
+	deadline := time.Now().Add(pageTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	runCtx, cancel := context.WithDeadline(s.liveCtx, deadline)
+	defer cancel()
+
+	r := s.rules
+	var actions []chromedp.Action
+	if !s.loaded {
+		actions = append(actions,
+			// Navigate to the site
+			chromedp.Navigate(r.URL),
+			chromedp.Sleep(3*time.Second),
+		)
+	}
+	var text string
+	actions = append(actions,
 		// Click on the search input to focus it
-		chromedp.Click(`input`, chromedp.ByQuery),
+		chromedp.Click(r.Input, chromedp.ByQuery),
 		chromedp.Sleep(500*time.Millisecond),
 		// Select all existing text and type the IP with Enter to submit
-		chromedp.Evaluate(`
+		chromedp.Evaluate(fmt.Sprintf(`
 			(() => {
-				const input = document.querySelector('input');
+				const input = document.querySelector(%s);
 				if (input) {
 					input.focus();
 					input.select();
 				}
 			})()
-		`, nil),
-		chromedp.SendKeys(`input`, ip+"\r", chromedp.ByQuery),
+		`, jsString(r.Input)), nil),
+		chromedp.SendKeys(r.Input, ip+"\r", chromedp.ByQuery),
 		// Wait for results to load
 		chromedp.Sleep(10*time.Second),
 		// Extract the results
-		chromedp.Evaluate(extractJS, &purityText),
+		chromedp.Evaluate(fmt.Sprintf(`document.querySelector(%s)?.innerText ?? ""`, jsString(r.Text)), &text),
 	)
-	if err != nil {
-		return nil, fmt.Errorf("browser automation failed: %w", err)
+	err := chromedp.Run(runCtx, actions...)
+	if s.overMemory.Load() {
+		return nil, fmt.Errorf("browser used more than %d MB", s.limits.MaxMemoryMB)
 	}
-
-	// Parse JSON result
-	info := &IPInfo{IPAddress: ip}
-
-	// Simple JSON parsing without external lib
-	purityText = strings.TrimPrefix(purityText, `"`)
-	purityText = strings.TrimSuffix(purityText, `"`)
-	purityText = strings.ReplaceAll(purityText, `\"`, `"`)
-
-	// Extract purity
-	if idx := strings.Index(purityText, `"purity":"`); idx != -1 {
-		start := idx + len(`"purity":"`)
-		end := strings.Index(purityText[start:], `"`)
-		if end != -1 {
-			info.PurityScore = purityText[start : start+end]
-		}
-	}
-
-	// Extract purityLevel
-	if idx := strings.Index(purityText, `"purityLevel":"`); idx != -1 {
-		start := idx + len(`"purityLevel":"`)
-		end := strings.Index(purityText[start:], `"`)
-		if end != -1 {
-			info.PurityLevel = purityText[start : start+end]
-		}
-	}
-
-	// Extract ipType
-	if idx := strings.Index(purityText, `"ipType":"`); idx != -1 {
-		start := idx + len(`"ipType":"`)
-		end := strings.Index(purityText[start:], `"`)
-		if end != -1 {
-			info.IPType = purityText[start : start+end]
+	if err != nil {
+		if ctx.Err() == nil || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			capture(s.chromeCtx, ip, err.Error())
 		}
+		return nil, browserError(err)
 	}
+	s.loaded = true
 
-	// Extract native
-	if idx := strings.Index(purityText, `"native":"`); idx != -1 {
-		start := idx + len(`"native":"`)
-		end := strings.Index(purityText[start:], `"`)
-		if end != -1 {
-			info.IsNative = purityText[start : start+end]
-		}
+	info := &IPInfo{IPAddress: ip}
+	r.extract(text, info)
+	if empty := emptyFields(info); len(empty) > 0 {
+		capture(s.chromeCtx, ip, "empty fields: "+strings.Join(empty, ", "))
 	}
 
 	// Set defaults for empty fields
 	if info.PurityScore == "" {
-		info.PurityScore = "未知"
+		info.PurityScore = Unknown
 	}
 	if info.PurityLevel == "" {
-		info.PurityLevel = "未知"
+		info.PurityLevel = Unknown
 	}
 	if info.IPType == "" {
-		info.IPType = "未知"
+		info.IPType = Unknown
 	}
 	if info.IsNative == "" {
-		info.IsNative = "未知"
+		info.IsNative = Unknown
 	}
+	info.normalize()
 
 	return info, nil
 }
 
+// jsString quotes s as a JavaScript string literal
+func jsString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// browserError describes a failed chromedp run, plainly when Chrome is missing
+func browserError(err error) error {
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: install Chrome or Chromium, or set chrome_path (%v)", ErrNoChrome, err)
+	}
+	return fmt.Errorf("browser automation failed: %w", err)
+}
+
 // FormatResult formats IPInfo as a readable string
 func (info *IPInfo) FormatResult() string {
+	if !info.Available() {
+		return fmt.Sprintf("🔍 IP 纯净度检测\n\nIP: %s\n\n⚠️ 未找到 Chrome，纯净度、类型和来源不可用", info.IPAddress)
+	}
 	return fmt.Sprintf(`🔍 IP 纯净度检测
 
 IP: %s
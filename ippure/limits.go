@@ -0,0 +1,109 @@
+package ippure
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// memoryPoll is how often a check's Chrome memory is measured
+const memoryPoll = time.Second
+
+// Limits bound the headless Chrome instances started by Check
+type Limits struct {
+	MaxConcurrent int // Checks running at once, more wait their turn (0 = no limit)
+	MaxMemoryMB   int // Memory of one check's Chrome, all processes together (0 = no limit)
+}
+
+var (
+	limitsMu sync.Mutex
+	limits   Limits
+	slots    chan struct{} // Holds a token per running check, nil without a limit
+	waiting  int           // Checks waiting for a slot
+)
+
+// SetLimits applies limits to the checks started from now on. Running checks
+// keep the slot they have.
+func SetLimits(l Limits) {
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	limits = l
+	slots = nil
+	if l.MaxConcurrent > 0 {
+		slots = make(chan struct{}, l.MaxConcurrent)
+	}
+}
+
+// Queue returns how many checks are running and how many are waiting for a
+// slot. Running is only counted with a concurrency limit.
+func Queue() (running, queued int) {
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	return len(slots), waiting
+}
+
+// acquire waits for a slot and returns the limits to apply and the function
+// giving the slot back. Waiting counts against ctx like the check itself.
+func acquire(ctx context.Context) (Limits, func(), error) {
+	limitsMu.Lock()
+	l, ch := limits, slots
+	if ch == nil {
+		limitsMu.Unlock()
+		return l, func() {}, nil
+	}
+	select {
+	case ch <- struct{}{}:
+		limitsMu.Unlock()
+		return l, func() { <-ch }, nil
+	default:
+	}
+	waiting++
+	limitsMu.Unlock()
+
+	defer func() {
+		limitsMu.Lock()
+		waiting--
+		limitsMu.Unlock()
+	}()
+	select {
+	case ch <- struct{}{}:
+		return l, func() { <-ch }, nil
+	case <-ctx.Done():
+		return l, nil, fmt.Errorf("waiting for a free browser: %w", ctx.Err())
+	}
+}
+
+// watchMemory cancels the check when the Chrome tree rooted at proc uses more
+// than maxMB. Returns a function stopping the watch and reporting whether the
+// limit was hit.
+func watchMemory(proc *os.Process, maxMB int, cancel context.CancelFunc) func() bool {
+	done := make(chan struct{})
+	exceeded := make(chan bool, 1)
+	go func() {
+		ticker := time.NewTicker(memoryPoll)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				exceeded <- false
+				return
+			case <-ticker.C:
+				rss, err := treeRSS(proc.Pid)
+				if err != nil {
+					continue
+				}
+				if rss > int64(maxMB)<<20 {
+					cancel()
+					exceeded <- true
+					return
+				}
+			}
+		}
+	}()
+	return func() bool {
+		close(done)
+		return <-exceeded
+	}
+}
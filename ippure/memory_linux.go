@@ -0,0 +1,60 @@
+package ippure
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MemoryLimitSupported reports whether MaxMemoryMB is enforced for the
+// whole Chrome process tree here, not only as the JavaScript heap limit
+const MemoryLimitSupported = true
+
+// treeRSS returns the resident memory in bytes of pid and all its
+// descendants, read from /proc
+func treeRSS(pid int) (int64, error) {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return 0, err
+	}
+	children := make(map[int][]int)
+	for _, path := range stats {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue // Exited meanwhile
+		}
+		// pid (comm) state ppid ...; comm may hold spaces and parentheses
+		s := string(data)
+		fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+		if len(fields) < 2 {
+			continue
+		}
+		child, err1 := strconv.Atoi(filepath.Base(filepath.Dir(path)))
+		parent, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil {
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	var total int64
+	page := int64(os.Getpagesize())
+	queue := []int{pid}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = append(queue[1:], children[p]...)
+		data, err := os.ReadFile("/proc/" + strconv.Itoa(p) + "/statm")
+		if err != nil {
+			continue
+		}
+		// size resident shared ...
+		fields := strings.Fields(string(data))
+		if len(fields) < 2 {
+			continue
+		}
+		if resident, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			total += resident * page
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package ippure
+
+import "errors"
+
+// MemoryLimitSupported reports whether MaxMemoryMB is enforced for the
+// whole Chrome process tree here, not only as the JavaScript heap limit
+const MemoryLimitSupported = false
+
+// treeRSS is only implemented on Linux, elsewhere MaxMemoryMB only caps the
+// JavaScript heap
+func treeRSS(pid int) (int64, error) {
+	return 0, errors.New("process memory is only read on Linux")
+}
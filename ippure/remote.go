@@ -0,0 +1,184 @@
+package ippure
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Remote checks: an agent (cmd/ippure-agent) runs Check on another machine
+// and serves it as POST /check with {"ip": "..."}, answering agentResult.
+// With agents set, Check asks them in order and fails over to the next one
+// when an agent can't be reached or its check fails.
+const (
+	agentDialTimeout = 5 * time.Second // A dead agent is skipped quickly
+	agentCooldown    = 2 * time.Minute // A failed agent is tried last for this long
+	maxAgentBody     = 64 * 1024
+)
+
+// Agent is a remote checker
+type Agent struct {
+	URL   string // Base URL, e.g. https://home.example.com:8443
+	Token string // Bearer token the agent wants (optional)
+}
+
+// agentResult is the answer of an agent, an error or the check result
+type agentResult struct {
+	IP          string `json:"ip"`
+	PurityScore string `json:"purity_score,omitempty"`
+	PurityLevel string `json:"purity_level,omitempty"`
+	IPType      string `json:"ip_type,omitempty"`
+	IsNative    string `json:"is_native,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+var (
+	agentsMu  sync.Mutex
+	agents    []Agent
+	agentDown = make(map[string]time.Time) // URL -> when it last failed
+	agentHTTP = &http.Client{Transport: &http.Transport{DialContext: (&net.Dialer{Timeout: agentDialTimeout}).DialContext, Proxy: http.ProxyFromEnvironment}}
+)
+
+// SetAgents makes Check run on these agents instead of a local Chrome, in
+// order of preference. None restores local checks.
+func SetAgents(list []Agent) {
+	agentsMu.Lock()
+	defer agentsMu.Unlock()
+	agents = append([]Agent(nil), list...)
+	clear(agentDown)
+}
+
+// agentOrder returns the agents to try: healthy ones in configured order,
+// then the ones that failed recently, longest ago first
+func agentOrder() []Agent {
+	agentsMu.Lock()
+	defer agentsMu.Unlock()
+	var healthy, down []Agent
+	for _, a := range agents {
+		if t, ok := agentDown[a.URL]; ok && time.Since(t) < agentCooldown {
+			down = append(down, a)
+		} else {
+			healthy = append(healthy, a)
+		}
+	}
+	slices.SortFunc(down, func(x, y Agent) int { return agentDown[x.URL].Compare(agentDown[y.URL]) })
+	return append(healthy, down...)
+}
+
+// markAgent records whether a check on the agent at url worked
+func markAgent(url string, ok bool) {
+	agentsMu.Lock()
+	defer agentsMu.Unlock()
+	if ok {
+		delete(agentDown, url)
+	} else {
+		agentDown[url] = time.Now()
+	}
+}
+
+// checkRemote runs the check on the first agent that manages it
+func checkRemote(ctx context.Context, ip string, list []Agent) (*IPInfo, error) {
+	var errs []error
+	for _, a := range list {
+		info, err := checkOn(ctx, a, ip)
+		if err == nil {
+			markAgent(a.URL, true)
+			return info, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		markAgent(a.URL, false)
+		errs = append(errs, fmt.Errorf("%s: %w", a.URL, err))
+	}
+	return nil, fmt.Errorf("every checker agent failed: %w", errors.Join(errs...))
+}
+
+// checkOn asks one agent
+func checkOn(ctx context.Context, a Agent, ip string) (*IPInfo, error) {
+	body, err := json.Marshal(map[string]string{"ip": ip})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(a.URL, "/")+"/check", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+	resp, err := agentHTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result agentResult
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, maxAgentBody)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("agent answered %s", resp.Status)
+	}
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("agent answered %s", resp.Status)
+	}
+	info := &IPInfo{
+		IPAddress:   ip,
+		PurityScore: result.PurityScore,
+		PurityLevel: result.PurityLevel,
+		IPType:      result.IPType,
+		IsNative:    result.IsNative,
+	}
+	// Agents of older versions pass on the page's wording
+	info.normalize()
+	return info, nil
+}
+
+// Handler serves Check to remote bots as an agent. Requests need the bearer
+// token when it is set.
+func Handler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /check", func(w http.ResponseWriter, r *http.Request) {
+		answer := func(status int, result agentResult) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(result)
+		}
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			answer(http.StatusUnauthorized, agentResult{Error: "unauthorized"})
+			return
+		}
+		var req struct {
+			IP string `json:"ip"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAgentBody)).Decode(&req); err != nil || net.ParseIP(req.IP) == nil {
+			answer(http.StatusBadRequest, agentResult{IP: req.IP, Error: "invalid IP address"})
+			return
+		}
+
+		info, err := checkLocal(r.Context(), req.IP)
+		if err != nil {
+			answer(http.StatusBadGateway, agentResult{IP: req.IP, Error: err.Error()})
+			return
+		}
+		answer(http.StatusOK, agentResult{
+			IP:          req.IP,
+			PurityScore: info.PurityScore,
+			PurityLevel: info.PurityLevel,
+			IPType:      info.IPType,
+			IsNative:    info.IsNative,
+		})
+	})
+	return mux
+}
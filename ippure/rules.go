@@ -0,0 +1,141 @@
+package ippure
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRules are the rules built in, see rules.json
+//
+//go:embed rules.json
+var defaultRules []byte
+
+// Rules say how a check finds its way around ippure.com. The patterns are Go
+// regular expressions matched against the innerText of the Text element.
+type Rules struct {
+	URL    string `json:"url"`     // Page the check opens
+	Input  string `json:"input"`   // CSS selector of the search box
+	Text   string `json:"text"`    // CSS selector of the element holding the results
+	Purity string `json:"purity"`  // Group 1 the score in percent, group 2 the level (optional)
+	IPType string `json:"ip_type"` // Group 1 the IP type
+	Native string `json:"native"`  // Group 1 the IP origin
+
+	purity, ipType, native *regexp.Regexp
+}
+
+var (
+	rulesMu     sync.Mutex
+	rules       *Rules
+	rulesFile   string    // Override set with SetRulesFile, empty for the built-in rules
+	rulesLoaded time.Time // Modification time of rulesFile when it was read
+)
+
+// ParseRules reads rules in the JSON format of rules.json. Fields left out
+// keep their built-in value.
+func ParseRules(data []byte) (*Rules, error) {
+	var r Rules
+	if err := json.Unmarshal(defaultRules, &r); err != nil {
+		return nil, fmt.Errorf("built-in rules: %w", err)
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if r.URL == "" || r.Input == "" || r.Text == "" {
+		return nil, fmt.Errorf("url, input and text can't be empty")
+	}
+	for _, p := range []struct {
+		name    string
+		pattern string
+		re      **regexp.Regexp
+	}{
+		{"purity", r.Purity, &r.purity},
+		{"ip_type", r.IPType, &r.ipType},
+		{"native", r.Native, &r.native},
+	} {
+		re, err := regexp.Compile(p.pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.name, err)
+		}
+		if re.NumSubexp() < 1 {
+			return nil, fmt.Errorf("%s: the pattern needs a group around the value", p.name)
+		}
+		*p.re = re
+	}
+	return &r, nil
+}
+
+// SetRulesFile makes checks use the rules in path instead of the built-in
+// ones, reading it again whenever it changes. An empty path restores the
+// built-in rules.
+func SetRulesFile(path string) error {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules, rulesFile, rulesLoaded = nil, path, time.Time{}
+	_, err := currentRules()
+	return err
+}
+
+// activeRules returns the rules for a check, the file reread when it changed
+func activeRules() (*Rules, error) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	return currentRules()
+}
+
+// currentRules is activeRules with rulesMu held. A file that fails to parse
+// fails the checks until it is fixed, rather than scrape with stale rules.
+func currentRules() (*Rules, error) {
+	if rulesFile == "" {
+		if rules == nil {
+			r, err := ParseRules([]byte("{}"))
+			if err != nil {
+				return nil, err
+			}
+			rules = r
+		}
+		return rules, nil
+	}
+
+	info, err := os.Stat(rulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("rules file: %w", err)
+	}
+	if rules != nil && info.ModTime().Equal(rulesLoaded) {
+		return rules, nil
+	}
+	data, err := os.ReadFile(rulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("rules file: %w", err)
+	}
+	r, err := ParseRules(data)
+	if err != nil {
+		rules = nil
+		return nil, fmt.Errorf("rules file %s: %w", rulesFile, err)
+	}
+	rules, rulesLoaded = r, info.ModTime()
+	return rules, nil
+}
+
+// extract fills the fields of info the rules find in text
+func (r *Rules) extract(text string, info *IPInfo) {
+	// JavaScript's \s takes in the non-breaking spaces pages are full of, Go's doesn't
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+	if m := r.purity.FindStringSubmatch(text); m != nil {
+		info.PurityScore = m[1] + "%"
+		if len(m) > 2 {
+			info.PurityLevel = strings.TrimSpace(m[2])
+		}
+	}
+	if m := r.ipType.FindStringSubmatch(text); m != nil {
+		info.IPType = strings.TrimSpace(m[1])
+	}
+	if m := r.native.FindStringSubmatch(text); m != nil {
+		info.IsNative = strings.TrimSpace(m[1])
+	}
+}
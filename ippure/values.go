@@ -0,0 +1,59 @@
+package ippure
+
+import "strings"
+
+// Canonical IPType and IsNative values. Check answers these whatever
+// language ippure.com rendered in, so they can be compared as they are.
+const (
+	TypeDatacenter  = "机房IP"
+	TypeResidential = "住宅IP"
+
+	OriginNative    = "原生IP"
+	OriginNonNative = "非原生IP"
+	OriginBroadcast = "广播IP"
+
+	Unknown = "未知" // The page didn't show the field
+)
+
+// typeNames and originNames map the wordings seen on the page, lowercased
+// and without the IP suffix, to the canonical values
+var (
+	typeNames = map[string]string{
+		"机房": TypeDatacenter, "数据中心": TypeDatacenter, "data center": TypeDatacenter,
+		"datacenter": TypeDatacenter, "data centre": TypeDatacenter, "hosting": TypeDatacenter, "idc": TypeDatacenter,
+		"住宅": TypeResidential, "家庭宽带": TypeResidential, "residential": TypeResidential, "isp": TypeResidential,
+	}
+	originNames = map[string]string{
+		"原生": OriginNative, "native": OriginNative,
+		"非原生": OriginNonNative, "non-native": OriginNonNative, "non native": OriginNonNative, "not native": OriginNonNative,
+		"广播": OriginBroadcast, "broadcast": OriginBroadcast,
+	}
+)
+
+// NormalizeType returns the canonical IP type for a wording of the page,
+// s itself when it isn't one
+func NormalizeType(s string) string {
+	return canonical(typeNames, s)
+}
+
+// NormalizeOrigin returns the canonical IP origin for a wording of the page,
+// s itself when it isn't one
+func NormalizeOrigin(s string) string {
+	return canonical(originNames, s)
+}
+
+func canonical(names map[string]string, s string) string {
+	s = strings.TrimSpace(s)
+	key := strings.ToLower(strings.Join(strings.Fields(s), " "))
+	key = strings.TrimSpace(strings.TrimSuffix(key, "ip"))
+	if v, ok := names[key]; ok {
+		return v
+	}
+	return s
+}
+
+// normalize puts the type and origin of info in canonical form
+func (info *IPInfo) normalize() {
+	info.IPType = NormalizeType(info.IPType)
+	info.IsNative = NormalizeOrigin(info.IsNative)
+}
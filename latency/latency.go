@@ -0,0 +1,145 @@
+// Package latency measures the round trip time to an IP address from other
+// places: Globalping probes picked by location, and self-hosted probe URLs
+// that answer with a number of milliseconds.
+package latency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"oci-bot/globalping"
+)
+
+const (
+	pingPackets     = 3
+	maxProbeBodyLen = 1024
+)
+
+// ErrUnreachable is a Result's Err when no ping came back
+var ErrUnreachable = errors.New("unreachable")
+
+// Options says where to measure from
+type Options struct {
+	Locations       []string          // Globalping locations, e.g. "Shanghai" or "CN"
+	GlobalpingToken string            // Optional, raises the hourly limit
+	Probes          map[string]string // Name -> URL with {ip}, answering the RTT in ms
+}
+
+// Vantages returns the names results are reported under, locations first
+func (o Options) Vantages() []string {
+	names := append([]string(nil), o.Locations...)
+	probes := make([]string, 0, len(o.Probes))
+	for name := range o.Probes {
+		probes = append(probes, name)
+	}
+	sort.Strings(probes)
+	return append(names, probes...)
+}
+
+// Result is the latency from one vantage point
+type Result struct {
+	Vantage string        // Location or probe name as configured
+	Probe   string        // Where a Globalping probe actually was, e.g. "Shanghai, CN, AS4134"
+	RTT     time.Duration // Average round trip when Err is nil
+	Err     error         // ErrUnreachable, or why it couldn't be measured
+}
+
+// Measure pings ip from every vantage point concurrently. Results are in the
+// order of Options.Vantages.
+func Measure(ctx context.Context, ip string, opts Options) []Result {
+	vantages := opts.Vantages()
+	results := make([]Result, len(vantages))
+	var wg sync.WaitGroup
+	for i, vantage := range vantages {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i < len(opts.Locations) {
+				results[i] = ping(ctx, ip, vantage, opts.GlobalpingToken)
+			} else {
+				results[i] = probe(ctx, ip, vantage, opts.Probes[vantage])
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// ping measures from one Globalping probe in location
+func ping(ctx context.Context, ip, location, token string) Result {
+	result := Result{Vantage: location}
+	m, err := globalping.Client{Token: token}.Measure(ctx, "ping", ip, location, map[string]any{"packets": pingPackets})
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Probe = m.Probe.String()
+
+	var stats struct {
+		Stats struct {
+			Avg *float64 `json:"avg"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal(m.Result, &stats); err != nil {
+		result.Err = err
+		return result
+	}
+	if stats.Stats.Avg == nil {
+		result.Err = ErrUnreachable
+		return result
+	}
+	result.RTT = time.Duration(*stats.Stats.Avg * float64(time.Millisecond))
+	return result
+}
+
+// probe asks a self-hosted probe. It answers 2xx with the average RTT in
+// milliseconds as the body, or a negative number when nothing came back.
+func probe(ctx context.Context, ip, name, template string) Result {
+	result := Result{Vantage: name}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(template, "{ip}", ip), nil)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBodyLen))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if resp.StatusCode/100 != 2 {
+		result.Err = fmt.Errorf("probe answered %s", resp.Status)
+		return result
+	}
+	ms, err := strconv.ParseFloat(strings.TrimSpace(string(body)), 64)
+	switch {
+	case err != nil:
+		result.Err = fmt.Errorf("probe answered %q, want milliseconds", truncate(string(body), 40))
+	case ms < 0:
+		result.Err = ErrUnreachable
+	default:
+		result.RTT = time.Duration(ms * float64(time.Millisecond))
+	}
+	return result
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
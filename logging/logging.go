@@ -0,0 +1,168 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Options configures the global log output
+type Options struct {
+	Level      string // debug / info / warn / error (default: info)
+	JSON       bool   // Output JSON lines instead of text
+	File       string // Also write to this file (optional)
+	MaxSizeMB  int    // Rotate the file when it exceeds this size (default: 10)
+	MaxBackups int    // Number of rotated files to keep (default: 3)
+}
+
+// Hook receives log messages at or above its minimum level
+type Hook func(lvl slog.Level, module, msg string)
+
+type hookEntry struct {
+	minLevel slog.Level
+	fn       Hook
+}
+
+var (
+	level      = new(slog.LevelVar)
+	jsonOutput atomic.Bool
+	handler    atomic.Pointer[slog.Logger]
+
+	hooksMu sync.RWMutex
+	hooks   []hookEntry
+)
+
+func init() {
+	handler.Store(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+}
+
+// Setup configures level, format and output for all loggers
+func Setup(opts Options) error {
+	lvl, err := ParseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	level.Set(lvl)
+
+	var out io.Writer = os.Stderr
+	if opts.File != "" {
+		file, err := newRotatingFile(opts.File, opts.MaxSizeMB, opts.MaxBackups)
+		if err != nil {
+			return err
+		}
+		out = io.MultiWriter(os.Stderr, file)
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	jsonOutput.Store(opts.JSON)
+	if opts.JSON {
+		handler.Store(slog.New(slog.NewJSONHandler(out, handlerOpts)))
+	} else {
+		handler.Store(slog.New(slog.NewTextHandler(out, handlerOpts)))
+	}
+	return nil
+}
+
+// ParseLevel parses a level name; empty means info
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level: %s", name)
+}
+
+// SetLevel changes the minimum level of all loggers at runtime
+func SetLevel(lvl slog.Level) {
+	level.Set(lvl)
+}
+
+// GetLevel returns the current minimum level
+func GetLevel() slog.Level {
+	return level.Level()
+}
+
+// AddHook registers fn to be called for every message at or above minLevel,
+// regardless of the configured output level
+func AddHook(minLevel slog.Level, fn Hook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks = append(hooks, hookEntry{minLevel: minLevel, fn: fn})
+}
+
+// Logger writes leveled log messages tagged with a module name
+type Logger struct {
+	module string
+}
+
+// New returns a logger for the given module (e.g. "bot", "oci")
+func New(module string) *Logger {
+	return &Logger{module: module}
+}
+
+// Debugf logs a debug message
+func (l *Logger) Debugf(format string, args ...any) {
+	l.log(slog.LevelDebug, format, args...)
+}
+
+// Infof logs an info message
+func (l *Logger) Infof(format string, args ...any) {
+	l.log(slog.LevelInfo, format, args...)
+}
+
+// Warnf logs a warning message
+func (l *Logger) Warnf(format string, args ...any) {
+	l.log(slog.LevelWarn, format, args...)
+}
+
+// Errorf logs an error message
+func (l *Logger) Errorf(format string, args ...any) {
+	l.log(slog.LevelError, format, args...)
+}
+
+// Fatalf logs an error message and exits the process
+func (l *Logger) Fatalf(format string, args ...any) {
+	l.log(slog.LevelError, format, args...)
+	os.Exit(1)
+}
+
+func (l *Logger) log(lvl slog.Level, format string, args ...any) {
+	ctx := context.Background()
+	logger := handler.Load()
+	enabled := logger.Enabled(ctx, lvl)
+
+	hooksMu.RLock()
+	active := hooks
+	hooksMu.RUnlock()
+
+	var msg string
+	if enabled || len(active) > 0 {
+		msg = fmt.Sprintf(format, args...)
+	}
+	for _, h := range active {
+		if lvl >= h.minLevel {
+			h.fn(lvl, l.module, msg)
+		}
+	}
+
+	if !enabled {
+		return
+	}
+
+	if jsonOutput.Load() {
+		logger.Log(ctx, lvl, msg, "module", l.module)
+		return
+	}
+	logger.Log(ctx, lvl, "["+l.module+"] "+msg)
+}
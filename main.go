@@ -3,35 +3,63 @@ package main
 import (
 	"context"
 	"flag"
-	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"oci-bot/bot"
 	"oci-bot/config"
+	"oci-bot/logging"
 )
 
+var logger = logging.New("main")
+
 func main() {
 	confFile := flag.String("c", "conf", "Path to config file")
+	encrypt := flag.Bool("encrypt", false, "Encrypt the config file and the API keys it names, then exit")
+	decrypt := flag.Bool("decrypt", false, "Decrypt the config file and the API keys it names, then exit")
 	flag.Parse()
 
+	if *encrypt || *decrypt {
+		if err := convertSecrets(*confFile, *encrypt); err != nil {
+			logger.Fatalf("Failed to convert config: %v", err)
+		}
+		return
+	}
+	if err := loadPassphrase(*confFile); err != nil {
+		logger.Fatalf("Failed to load config: %v", err)
+	}
+
 	cfg, err := config.Load(*confFile)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		logger.Fatalf("Failed to load config: %v", err)
 	}
 
 	if err := cfg.Validate(); err != nil {
-		log.Fatalf("Configuration error: %v", err)
+		logger.Fatalf("Configuration error: %v", err)
 	}
 
-	log.Printf("=== OCI Reserved IP Bot ===")
-	log.Printf("Accounts: %v", cfg.AccountNames())
-	log.Printf("Admin ID: %d", cfg.TelegramAdminID)
+	if err := logging.Setup(logging.Options{
+		Level:      cfg.LogLevel,
+		JSON:       cfg.LogFormat == "json",
+		File:       cfg.LogFile,
+		MaxSizeMB:  cfg.LogMaxSizeMB,
+		MaxBackups: cfg.LogMaxBackups,
+	}); err != nil {
+		logger.Fatalf("Logging setup error: %v", err)
+	}
+
+	logger.Infof("=== OCI Reserved IP Bot ===")
+	logger.Infof("Accounts: %v", cfg.AccountNames())
+	if cfg.Platform == config.PlatformMatrix {
+		logger.Infof("Admin: %s", cfg.MatrixAdmin)
+	} else {
+		logger.Infof("Admin ID: %d", cfg.TelegramAdminID)
+	}
 
 	tgBot, err := bot.New(cfg)
 	if err != nil {
-		log.Fatalf("Failed to create bot: %v", err)
+		logger.Fatalf("Failed to create bot: %v", err)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -41,11 +69,11 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-sigChan
-		log.Println("\nStopping...")
+		logger.Infof("Stopping...")
 		cancel()
 	}()
 
 	if err := tgBot.Run(ctx); err != nil {
-		log.Fatalf("Bot error: %v", err)
+		logger.Fatalf("Bot error: %v", err)
 	}
 }
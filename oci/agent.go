@@ -0,0 +1,38 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/computeinstanceagent"
+)
+
+// RunInstanceCommand runs a shell script on an instance through the Oracle Cloud
+// Agent "Compute Instance Run Command" plugin and returns the command ID.
+// The plugin must be enabled on the instance and allowed by IAM policy.
+func (c *Client) RunInstanceCommand(ctx context.Context, instanceID, script string, timeoutSeconds int) (string, error) {
+	request := computeinstanceagent.CreateInstanceAgentCommandRequest{
+		CreateInstanceAgentCommandDetails: computeinstanceagent.CreateInstanceAgentCommandDetails{
+			CompartmentId:             common.String(c.compartmentID),
+			ExecutionTimeOutInSeconds: common.Int(timeoutSeconds),
+			Target: &computeinstanceagent.InstanceAgentCommandTarget{
+				InstanceId: common.String(instanceID),
+			},
+			Content: &computeinstanceagent.InstanceAgentCommandContent{
+				Source: computeinstanceagent.InstanceAgentCommandSourceViaTextDetails{
+					Text: common.String(script),
+				},
+				Output: computeinstanceagent.InstanceAgentCommandOutputViaTextDetails{},
+			},
+			DisplayName: common.String("oci-bot"),
+		},
+	}
+
+	response, err := c.agentClient.CreateInstanceAgentCommand(ctx, request)
+	if err != nil {
+		return "", fmt.Errorf("failed to run instance command: %w", err)
+	}
+
+	return safeString(response.Id), nil
+}
@@ -0,0 +1,136 @@
+package oci
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sync"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/identity"
+)
+
+// apiKeyBits is the size of generated API signing keys
+const apiKeyBits = 2048
+
+// keyProvider is a configuration provider whose API key can be replaced while
+// the clients built on it are in use. Requests are signed with whatever key is
+// current when they are sent.
+type keyProvider struct {
+	mu       sync.RWMutex
+	provider common.ConfigurationProvider
+}
+
+func (p *keyProvider) current() common.ConfigurationProvider {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.provider
+}
+
+func (p *keyProvider) set(provider common.ConfigurationProvider) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.provider = provider
+}
+
+func (p *keyProvider) PrivateRSAKey() (*rsa.PrivateKey, error) { return p.current().PrivateRSAKey() }
+func (p *keyProvider) KeyID() (string, error)                  { return p.current().KeyID() }
+func (p *keyProvider) TenancyOCID() (string, error)            { return p.current().TenancyOCID() }
+func (p *keyProvider) UserOCID() (string, error)               { return p.current().UserOCID() }
+func (p *keyProvider) KeyFingerprint() (string, error)         { return p.current().KeyFingerprint() }
+func (p *keyProvider) Region() (string, error)                 { return p.current().Region() }
+func (p *keyProvider) AuthType() (common.AuthConfig, error)    { return p.current().AuthType() }
+
+// NewAPIKey generates an RSA API signing key pair, returning the unencrypted
+// private key and the public key as PEM
+func NewAPIKey() (privateKeyPEM, publicKeyPEM string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, apiKeyBits)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	privateKeyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	publicKeyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}))
+	return privateKeyPEM, publicKeyPEM, nil
+}
+
+// rawProvider returns a configuration provider for this account's user with
+// the given API key
+func (c *Client) rawProvider(fingerprint, privateKeyPEM string) common.ConfigurationProvider {
+	return common.NewRawConfigurationProvider(c.tenancyID, c.userID, c.region, fingerprint, privateKeyPEM, nil)
+}
+
+// homeIdentityClient returns an Identity client for the tenancy's home region,
+// where IAM changes such as API keys have to be made
+func (c *Client) homeIdentityClient(ctx context.Context) (identity.IdentityClient, error) {
+	client := c.identityClient
+	regions, err := c.ListRegionSubscriptions(ctx)
+	if err != nil {
+		return client, fmt.Errorf("failed to find home region: %w", err)
+	}
+	if len(regions) > 0 && regions[0].IsHome {
+		client.SetRegion(regions[0].Name)
+	}
+	return client, nil
+}
+
+// UploadAPIKey adds a public key to the account's user and returns its
+// fingerprint. OCI allows at most three keys per user.
+func (c *Client) UploadAPIKey(ctx context.Context, publicKeyPEM string) (string, error) {
+	client, err := c.homeIdentityClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	response, err := client.UploadApiKey(ctx, identity.UploadApiKeyRequest{
+		UserId:              common.String(c.userID),
+		CreateApiKeyDetails: identity.CreateApiKeyDetails{Key: common.String(publicKeyPEM)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload API key: %w", err)
+	}
+	return safeString(response.ApiKey.Fingerprint), nil
+}
+
+// CheckAPIKey verifies that requests signed with the given key are accepted.
+// A freshly uploaded key can take a minute to become usable.
+func (c *Client) CheckAPIKey(ctx context.Context, fingerprint, privateKeyPEM string) error {
+	client, err := identity.NewIdentityClientWithConfigurationProvider(c.rawProvider(fingerprint, privateKeyPEM))
+	if err != nil {
+		return fmt.Errorf("failed to create Identity client: %w", err)
+	}
+	client.SetRegion(c.region)
+
+	if _, err := client.GetUser(ctx, identity.GetUserRequest{UserId: common.String(c.userID)}); err != nil {
+		return fmt.Errorf("API key %s not accepted: %w", fingerprint, err)
+	}
+	return nil
+}
+
+// UseAPIKey switches every request of this client to the given key
+func (c *Client) UseAPIKey(fingerprint, privateKeyPEM string) {
+	c.provider.set(c.rawProvider(fingerprint, privateKeyPEM))
+	logger.Infof("[%s] Now signing requests with API key %s", c.accountName, fingerprint)
+}
+
+// DeleteAPIKey removes an API key from the account's user
+func (c *Client) DeleteAPIKey(ctx context.Context, fingerprint string) error {
+	client, err := c.homeIdentityClient(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = client.DeleteApiKey(ctx, identity.DeleteApiKeyRequest{
+		UserId:      common.String(c.userID),
+		Fingerprint: common.String(fingerprint),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete API key %s: %w", fingerprint, err)
+	}
+	return nil
+}
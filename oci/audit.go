@@ -0,0 +1,76 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/audit"
+	"github.com/oracle/oci-go-sdk/v65/common"
+)
+
+// AuditEventInfo is a write operation recorded by the Audit service
+type AuditEventInfo struct {
+	ID           string
+	Time         time.Time
+	EventName    string // e.g. "TerminateInstance"
+	Action       string // HTTP method of the request
+	ResourceName string
+	ResourceID   string
+	Principal    string // User or service that made the request
+	ByBot        bool   // Made by this bot's API user through the Go SDK
+}
+
+// ListWriteEvents returns create/update/delete audit events in the compartment
+// between start and end. Read-only (GET) events are skipped.
+func (c *Client) ListWriteEvents(ctx context.Context, start, end time.Time) ([]AuditEventInfo, error) {
+	request := audit.ListEventsRequest{
+		CompartmentId: common.String(c.compartmentID),
+		StartTime:     &common.SDKTime{Time: start},
+		EndTime:       &common.SDKTime{Time: end},
+	}
+
+	var events []AuditEventInfo
+	for {
+		response, err := c.auditClient.ListEvents(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list audit events: %w", err)
+		}
+
+		for _, ev := range response.Items {
+			if ev.Data == nil || ev.Data.Request == nil {
+				continue
+			}
+			action := safeString(ev.Data.Request.Action)
+			if action == "" || action == http.MethodGet || action == http.MethodHead {
+				continue
+			}
+
+			info := AuditEventInfo{
+				ID:           safeString(ev.EventId),
+				EventName:    safeString(ev.Data.EventName),
+				Action:       action,
+				ResourceName: safeString(ev.Data.ResourceName),
+				ResourceID:   safeString(ev.Data.ResourceId),
+			}
+			if ev.EventTime != nil {
+				info.Time = ev.EventTime.Time
+			}
+			if id := ev.Data.Identity; id != nil {
+				info.Principal = safeString(id.PrincipalName)
+				info.ByBot = safeString(id.PrincipalId) == c.userID &&
+					strings.Contains(safeString(id.UserAgent), "Oracle-GoSDK")
+			}
+			events = append(events, info)
+		}
+
+		if response.OpcNextPage == nil {
+			break
+		}
+		request.Page = response.OpcNextPage
+	}
+
+	return events, nil
+}
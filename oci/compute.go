@@ -2,38 +2,73 @@ package oci
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 
 	"github.com/oracle/oci-go-sdk/v65/common"
 	"github.com/oracle/oci-go-sdk/v65/core"
+	"github.com/oracle/oci-go-sdk/v65/identity"
 )
 
 // VPSLaunchDetails stores launch parameters for a VPS instance.
 type VPSLaunchDetails struct {
 	AvailabilityDomain string
+	FaultDomain        string
 	SubnetID           string
 	ImageID            string
 	Shape              string
 	DisplayName        string
 	SSHAuthorizedKeys  string
+	UserData           string // cloud-init script run on first boot (optional)
 	OCPUs              float32
 	MemoryGB           float32
 	BootVolumeGB       int
 }
 
+// LaunchedInstance is a newly launched instance, still provisioning, and the
+// work request that tracks its launch
+type LaunchedInstance struct {
+	core.Instance
+	WorkRequestID string
+	Attempts      []LaunchAttempt // Set by LaunchInstanceWithFallback
+}
+
+// LaunchAttempt is one availability domain and fault domain tried by
+// LaunchInstanceWithFallback, with its error (nil for the one that launched)
+type LaunchAttempt struct {
+	AvailabilityDomain string
+	FaultDomain        string
+	Err                error
+}
+
+// LaunchError is returned by LaunchInstanceWithFallback when no availability
+// domain could launch the instance. It wraps the last launch error.
+type LaunchError struct {
+	Attempts []LaunchAttempt
+	Err      error
+}
+
+func (e *LaunchError) Error() string { return e.Err.Error() }
+func (e *LaunchError) Unwrap() error { return e.Err }
+
 // LaunchInstance launches a compute instance based on given details.
-func (c *Client) LaunchInstance(ctx context.Context, details VPSLaunchDetails) (*core.Instance, error) {
+func (c *Client) LaunchInstance(ctx context.Context, details VPSLaunchDetails) (*LaunchedInstance, error) {
 	launchDetails := core.LaunchInstanceDetails{
 		CompartmentId:      common.String(c.compartmentID),
 		AvailabilityDomain: common.String(details.AvailabilityDomain),
 		Shape:              common.String(details.Shape),
 		DisplayName:        common.String(details.DisplayName),
+		FreeformTags:       c.managedTags(),
 		CreateVnicDetails: &core.CreateVnicDetails{
 			SubnetId:       common.String(details.SubnetID),
 			AssignPublicIp: common.Bool(true),
 		},
 	}
 
+	if details.FaultDomain != "" {
+		launchDetails.FaultDomain = common.String(details.FaultDomain)
+	}
+
 	sourceDetails := core.InstanceSourceViaImageDetails{
 		ImageId: common.String(details.ImageID),
 	}
@@ -42,10 +77,15 @@ func (c *Client) LaunchInstance(ctx context.Context, details VPSLaunchDetails) (
 	}
 	launchDetails.SourceDetails = sourceDetails
 
+	metadata := make(map[string]string)
 	if details.SSHAuthorizedKeys != "" {
-		launchDetails.Metadata = map[string]string{
-			"ssh_authorized_keys": details.SSHAuthorizedKeys,
-		}
+		metadata["ssh_authorized_keys"] = details.SSHAuthorizedKeys
+	}
+	if details.UserData != "" {
+		metadata["user_data"] = base64.StdEncoding.EncodeToString([]byte(details.UserData))
+	}
+	if len(metadata) > 0 {
+		launchDetails.Metadata = metadata
 	}
 
 	if details.OCPUs > 0 || details.MemoryGB > 0 {
@@ -59,8 +99,11 @@ func (c *Client) LaunchInstance(ctx context.Context, details VPSLaunchDetails) (
 		launchDetails.ShapeConfig = &shapeConfig
 	}
 
+	// Each AD and fault domain tried by LaunchInstanceWithFallback is a
+	// separate launch and gets its own token
 	request := core.LaunchInstanceRequest{
 		LaunchInstanceDetails: launchDetails,
+		OpcRetryToken:         retryToken(ctx, "instance", c.accountName, c.region, details.AvailabilityDomain, details.FaultDomain),
 	}
 
 	response, err := c.computeClient.LaunchInstance(ctx, request)
@@ -68,5 +111,178 @@ func (c *Client) LaunchInstance(ctx context.Context, details VPSLaunchDetails) (
 		return nil, fmt.Errorf("failed to launch instance: %w", err)
 	}
 
-	return &response.Instance, nil
+	return &LaunchedInstance{Instance: response.Instance, WorkRequestID: safeString(response.OpcWorkRequestId)}, nil
+}
+
+// LaunchInstanceWithFallback launches an instance in details.AvailabilityDomain and,
+// when it is out of capacity, retries the tenancy's other availability domains.
+// With tryFaultDomains set, each fault domain of an AD is also tried explicitly.
+// The returned instance reports the AD and fault domain that succeeded and
+// every launch tried; on failure the attempts come with a *LaunchError.
+func (c *Client) LaunchInstanceWithFallback(ctx context.Context, details VPSLaunchDetails, tryFaultDomains bool) (*LaunchedInstance, error) {
+	ads := []string{details.AvailabilityDomain}
+	allADs, err := c.ListAvailabilityDomains(ctx)
+	if err != nil {
+		logger.Warnf("[%s] Failed to list availability domains, using %s only: %v", c.accountName, details.AvailabilityDomain, err)
+	}
+	for _, ad := range allADs {
+		if ad != details.AvailabilityDomain {
+			ads = append(ads, ad)
+		}
+	}
+
+	var attempts []LaunchAttempt
+	var lastErr error
+	for i, ad := range ads {
+		faultDomains := []string{""}
+		if tryFaultDomains {
+			fds, err := c.ListFaultDomains(ctx, ad)
+			if err != nil {
+				logger.Warnf("[%s] Failed to list fault domains in %s: %v", c.accountName, ad, err)
+			}
+			faultDomains = append(faultDomains, fds...)
+		}
+
+		for _, fd := range faultDomains {
+			attempt := details
+			attempt.AvailabilityDomain = ad
+			attempt.FaultDomain = fd
+
+			instance, err := c.LaunchInstance(ctx, attempt)
+			attempts = append(attempts, LaunchAttempt{AvailabilityDomain: ad, FaultDomain: fd, Err: err})
+			if err == nil {
+				instance.Attempts = attempts
+				return instance, nil
+			}
+			lastErr = err
+
+			if !IsOutOfCapacity(err) {
+				// Errors in the configured AD are fatal; other ADs may simply
+				// not fit the subnet or shape, so move on to the next one.
+				if i == 0 {
+					return nil, &LaunchError{Attempts: attempts, Err: err}
+				}
+				logger.Warnf("[%s] Launch in %s failed: %v", c.accountName, ad, err)
+				break
+			}
+			logger.Infof("[%s] Out of capacity in %s %s", c.accountName, ad, fd)
+		}
+	}
+
+	return nil, &LaunchError{Attempts: attempts, Err: lastErr}
+}
+
+// ListAvailabilityDomains returns the names of the tenancy's availability domains
+func (c *Client) ListAvailabilityDomains(ctx context.Context) ([]string, error) {
+	request := identity.ListAvailabilityDomainsRequest{
+		CompartmentId: common.String(c.tenancyID),
+	}
+
+	response, err := c.identityClient.ListAvailabilityDomains(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list availability domains: %w", err)
+	}
+
+	var names []string
+	for _, ad := range response.Items {
+		if ad.Name != nil {
+			names = append(names, *ad.Name)
+		}
+	}
+	return names, nil
+}
+
+// ListFaultDomains returns the fault domain names within an availability domain
+func (c *Client) ListFaultDomains(ctx context.Context, availabilityDomain string) ([]string, error) {
+	request := identity.ListFaultDomainsRequest{
+		CompartmentId:      common.String(c.tenancyID),
+		AvailabilityDomain: common.String(availabilityDomain),
+	}
+
+	response, err := c.identityClient.ListFaultDomains(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fault domains: %w", err)
+	}
+
+	var names []string
+	for _, fd := range response.Items {
+		if fd.Name != nil {
+			names = append(names, *fd.Name)
+		}
+	}
+	return names, nil
+}
+
+// InstanceInfo contains basic information about a compute instance
+type InstanceInfo struct {
+	ID                 string
+	DisplayName        string
+	Shape              string
+	State              string
+	AvailabilityDomain string
+	OCPUs              float32
+	MemoryGB           float32
+	Tags               Tags
+}
+
+// ListInstances lists compute instances in the compartment, skipping terminated ones
+func (c *Client) ListInstances(ctx context.Context) ([]InstanceInfo, error) {
+	request := core.ListInstancesRequest{
+		CompartmentId: common.String(c.compartmentID),
+	}
+
+	var instances []InstanceInfo
+	for {
+		response, err := c.computeClient.ListInstances(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list instances: %w", err)
+		}
+		for _, inst := range response.Items {
+			if inst.LifecycleState == core.InstanceLifecycleStateTerminated {
+				continue
+			}
+			instances = append(instances, toInstanceInfo(inst))
+		}
+		if response.OpcNextPage == nil {
+			break
+		}
+		request.Page = response.OpcNextPage
+	}
+
+	return instances, nil
+}
+
+// RenameInstance changes the display name of an instance
+func (c *Client) RenameInstance(ctx context.Context, instanceID, displayName string) error {
+	request := core.UpdateInstanceRequest{
+		InstanceId: common.String(instanceID),
+		UpdateInstanceDetails: core.UpdateInstanceDetails{
+			DisplayName: common.String(displayName),
+		},
+	}
+
+	if _, err := c.computeClient.UpdateInstance(ctx, request); err != nil {
+		return fmt.Errorf("failed to rename instance: %w", err)
+	}
+	return nil
+}
+
+func toInstanceInfo(inst core.Instance) InstanceInfo {
+	info := InstanceInfo{
+		ID:                 safeString(inst.Id),
+		DisplayName:        safeString(inst.DisplayName),
+		Shape:              safeString(inst.Shape),
+		State:              string(inst.LifecycleState),
+		AvailabilityDomain: safeString(inst.AvailabilityDomain),
+		Tags:               Tags{Freeform: inst.FreeformTags, Defined: inst.DefinedTags},
+	}
+	if inst.ShapeConfig != nil {
+		if inst.ShapeConfig.Ocpus != nil {
+			info.OCPUs = *inst.ShapeConfig.Ocpus
+		}
+		if inst.ShapeConfig.MemoryInGBs != nil {
+			info.MemoryGB = *inst.ShapeConfig.MemoryInGBs
+		}
+	}
+	return info
 }
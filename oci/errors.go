@@ -0,0 +1,112 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+)
+
+// ErrorKind is the category of a failed OCI call, for messages and for
+// deciding whether to wait and try again
+type ErrorKind int
+
+const (
+	ErrorOther     ErrorKind = iota
+	ErrorQuota               // A service limit or compartment quota is used up
+	ErrorCapacity            // The region has no capacity for the shape
+	ErrorAuth                // The API key or signature was rejected
+	ErrorThrottled           // Too many requests (HTTP 429)
+	ErrorConflict            // The resource is busy or in the wrong state
+	ErrorNotFound            // Missing, or hidden by missing permissions
+	ErrorTimeout             // The call did not finish in time
+)
+
+// Classify sorts err into an ErrorKind by the service error code and HTTP
+// status, falling back to the error text for errors that lost their type
+func Classify(err error) ErrorKind {
+	if err == nil {
+		return ErrorOther
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorTimeout
+	}
+
+	lower := strings.ToLower(err.Error())
+	if strings.Contains(lower, "outofhostcapacity") || strings.Contains(lower, "out of host capacity") ||
+		strings.Contains(lower, "insufficient capacity") {
+		return ErrorCapacity
+	}
+
+	var serviceErr common.ServiceError
+	if errors.As(err, &serviceErr) {
+		switch code, status := serviceErr.GetCode(), serviceErr.GetHTTPStatusCode(); {
+		case status == 429 || code == "TooManyRequests":
+			return ErrorThrottled
+		case code == "LimitExceeded" || code == "QuotaExceeded":
+			return ErrorQuota
+		case status == 401 || code == "NotAuthenticated":
+			return ErrorAuth
+		case status == 409 || code == "Conflict" || code == "IncorrectState":
+			return ErrorConflict
+		case status == 404:
+			return ErrorNotFound
+		}
+		return ErrorOther
+	}
+
+	switch {
+	case strings.Contains(lower, "toomanyrequests"):
+		return ErrorThrottled
+	case strings.Contains(lower, "limitexceeded") || strings.Contains(lower, "quotaexceeded"):
+		return ErrorQuota
+	}
+	return ErrorOther
+}
+
+// ErrorMessage returns err's text with an SDK service error cut down to the
+// service's own message, dropping the request ID, endpoint and troubleshooting
+// boilerplate around it
+func ErrorMessage(err error) string {
+	text := err.Error()
+	var serviceErr common.ServiceError
+	if errors.As(err, &serviceErr) && serviceErr.GetMessage() != "" {
+		if full, ok := serviceErr.(error); ok {
+			text = strings.Replace(text, full.Error(), serviceErr.GetMessage(), 1)
+		}
+	}
+	return text
+}
+
+// IsOutOfCapacity reports whether err is an OCI out-of-capacity error
+func IsOutOfCapacity(err error) bool {
+	return Classify(err) == ErrorCapacity
+}
+
+// IsThrottled reports whether err is OCI rate limiting (HTTP 429) or a
+// service limit being hit
+func IsThrottled(err error) bool {
+	kind := Classify(err)
+	return kind == ErrorThrottled || kind == ErrorQuota
+}
+
+// IsRetryable reports whether a create call failed in a way where it may or
+// may not have gone through: a timeout, a dropped connection or an OCI 5xx.
+// Repeating such a call is only safe with the same attempt ID.
+func IsRetryable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var serviceErr common.ServiceError
+	if errors.As(err, &serviceErr) {
+		return serviceErr.GetHTTPStatusCode() >= 500
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
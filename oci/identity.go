@@ -0,0 +1,147 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/identity"
+)
+
+// TenancyInfo describes the account's tenancy
+type TenancyInfo struct {
+	ID          string
+	Name        string
+	Description string
+	HomeRegion  string // Region name, or the region key when it can't be resolved
+}
+
+// UserInfo describes the account's user and its API signing keys
+type UserInfo struct {
+	ID            string
+	Name          string
+	Description   string
+	Email         string
+	MFA           bool
+	TimeCreated   time.Time
+	LastLogin     time.Time // Zero when unknown
+	APIKeys       []APIKeyInfo
+	KeyInUse      string // Fingerprint requests are signed with
+	CanUseAPIKeys bool
+}
+
+// APIKeyInfo describes one API signing key. OCI API keys don't expire; the
+// age tells how long ago a key was last rotated.
+type APIKeyInfo struct {
+	Fingerprint string
+	State       string
+	TimeCreated time.Time
+}
+
+// RegionSubscription is a region the tenancy is subscribed to
+type RegionSubscription struct {
+	Name   string // e.g. ap-tokyo-1
+	Key    string // e.g. NRT
+	Status string // READY or IN_PROGRESS
+	IsHome bool
+}
+
+// ListRegionSubscriptions returns the regions the tenancy can use, home region first
+func (c *Client) ListRegionSubscriptions(ctx context.Context) ([]RegionSubscription, error) {
+	response, err := c.identityClient.ListRegionSubscriptions(ctx, identity.ListRegionSubscriptionsRequest{
+		TenancyId: common.String(c.tenancyID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list region subscriptions: %w", err)
+	}
+
+	regions := make([]RegionSubscription, 0, len(response.Items))
+	for _, sub := range response.Items {
+		regions = append(regions, RegionSubscription{
+			Name:   safeString(sub.RegionName),
+			Key:    safeString(sub.RegionKey),
+			Status: string(sub.Status),
+			IsHome: sub.IsHomeRegion != nil && *sub.IsHomeRegion,
+		})
+	}
+	sort.SliceStable(regions, func(i, j int) bool {
+		if regions[i].IsHome != regions[j].IsHome {
+			return regions[i].IsHome
+		}
+		return regions[i].Name < regions[j].Name
+	})
+	return regions, nil
+}
+
+// GetTenancyInfo returns the tenancy's name, description and home region
+func (c *Client) GetTenancyInfo(ctx context.Context) (*TenancyInfo, error) {
+	response, err := c.identityClient.GetTenancy(ctx, identity.GetTenancyRequest{
+		TenancyId: common.String(c.tenancyID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenancy: %w", err)
+	}
+
+	info := &TenancyInfo{
+		ID:          safeString(response.Id),
+		Name:        safeString(response.Name),
+		Description: safeString(response.Description),
+		HomeRegion:  safeString(response.HomeRegionKey),
+	}
+
+	regions, err := c.ListRegionSubscriptions(ctx)
+	if err != nil {
+		logger.Warnf("[%s] Failed to resolve home region: %v", c.accountName, err)
+		return info, nil
+	}
+	if len(regions) > 0 && regions[0].IsHome {
+		info.HomeRegion = regions[0].Name
+	}
+	return info, nil
+}
+
+// GetUserInfo returns the account's user and its API keys
+func (c *Client) GetUserInfo(ctx context.Context) (*UserInfo, error) {
+	response, err := c.identityClient.GetUser(ctx, identity.GetUserRequest{
+		UserId: common.String(c.userID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	info := &UserInfo{
+		ID:            safeString(response.Id),
+		Name:          safeString(response.Name),
+		Description:   safeString(response.Description),
+		Email:         safeString(response.Email),
+		MFA:           response.IsMfaActivated != nil && *response.IsMfaActivated,
+		CanUseAPIKeys: response.Capabilities == nil || response.Capabilities.CanUseApiKeys == nil || *response.Capabilities.CanUseApiKeys,
+	}
+	if response.TimeCreated != nil {
+		info.TimeCreated = response.TimeCreated.Time
+	}
+	if response.LastSuccessfulLoginTime != nil {
+		info.LastLogin = response.LastSuccessfulLoginTime.Time
+	}
+	info.KeyInUse, _ = c.provider.KeyFingerprint()
+
+	keys, err := c.identityClient.ListApiKeys(ctx, identity.ListApiKeysRequest{
+		UserId: common.String(c.userID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	for _, key := range keys.Items {
+		keyInfo := APIKeyInfo{
+			Fingerprint: safeString(key.Fingerprint),
+			State:       string(key.LifecycleState),
+		}
+		if key.TimeCreated != nil {
+			keyInfo.TimeCreated = key.TimeCreated.Time
+		}
+		info.APIKeys = append(info.APIKeys, keyInfo)
+	}
+	return info, nil
+}
@@ -0,0 +1,141 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/core"
+)
+
+// Freeform tags recording the source instance's shape on custom images,
+// so a restore can launch with the same resources.
+const (
+	tagSourceShape    = "oci-bot-shape"
+	tagSourceOCPUs    = "oci-bot-ocpus"
+	tagSourceMemoryGB = "oci-bot-memory-gb"
+)
+
+// ImageInfo contains information about a custom image
+type ImageInfo struct {
+	ID          string
+	DisplayName string
+	State       string
+	Shape       string  // Source instance shape
+	OCPUs       float32 // Source instance OCPUs
+	MemoryGB    float32 // Source instance memory
+	TimeCreated time.Time
+
+	WorkRequestID string // Set by CreateImageFromInstance
+}
+
+// CreateImageFromInstance creates a custom image from an instance's boot volume
+func (c *Client) CreateImageFromInstance(ctx context.Context, instance InstanceInfo, displayName string) (*ImageInfo, error) {
+	request := core.CreateImageRequest{
+		CreateImageDetails: core.CreateImageDetails{
+			CompartmentId: common.String(c.compartmentID),
+			InstanceId:    common.String(instance.ID),
+			DisplayName:   common.String(displayName),
+			FreeformTags: map[string]string{
+				tagSourceShape:    instance.Shape,
+				tagSourceOCPUs:    strconv.FormatFloat(float64(instance.OCPUs), 'f', -1, 32),
+				tagSourceMemoryGB: strconv.FormatFloat(float64(instance.MemoryGB), 'f', -1, 32),
+			},
+		},
+	}
+
+	response, err := c.computeClient.CreateImage(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image: %w", err)
+	}
+
+	info := toImageInfo(response.Image)
+	info.WorkRequestID = safeString(response.OpcWorkRequestId)
+	return &info, nil
+}
+
+// WaitForImageAvailable waits for a custom image to be in AVAILABLE state.
+// Image creation usually takes several minutes.
+func (c *Client) WaitForImageAvailable(ctx context.Context, imageID string, timeout time.Duration) (*ImageInfo, error) {
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		request := core.GetImageRequest{
+			ImageId: common.String(imageID),
+		}
+
+		response, err := c.computeClient.GetImage(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get image status: %w", err)
+		}
+
+		switch response.Image.LifecycleState {
+		case core.ImageLifecycleStateAvailable:
+			info := toImageInfo(response.Image)
+			return &info, nil
+		case core.ImageLifecycleStateDeleted, core.ImageLifecycleStateDisabled:
+			return nil, fmt.Errorf("image entered state %s", response.Image.LifecycleState)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(15 * time.Second):
+		}
+	}
+
+	return nil, fmt.Errorf("timeout waiting for image to become available")
+}
+
+// ListCustomImages lists the available custom images owned by the
+// compartment, newest first. The service filters the state; platform images
+// are listed whatever the compartment and are left out here.
+func (c *Client) ListCustomImages(ctx context.Context) ([]ImageInfo, error) {
+	request := core.ListImagesRequest{
+		CompartmentId:  common.String(c.compartmentID),
+		LifecycleState: core.ImageLifecycleStateAvailable,
+		SortBy:         core.ListImagesSortByTimecreated,
+		SortOrder:      core.ListImagesSortOrderDesc,
+	}
+
+	var images []ImageInfo
+	for {
+		response, err := c.computeClient.ListImages(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list images: %w", err)
+		}
+		for _, img := range response.Items {
+			if img.CompartmentId == nil || *img.CompartmentId != c.compartmentID {
+				continue
+			}
+			images = append(images, toImageInfo(img))
+		}
+		if response.OpcNextPage == nil {
+			break
+		}
+		request.Page = response.OpcNextPage
+	}
+
+	return images, nil
+}
+
+func toImageInfo(img core.Image) ImageInfo {
+	info := ImageInfo{
+		ID:          safeString(img.Id),
+		DisplayName: safeString(img.DisplayName),
+		State:       string(img.LifecycleState),
+		Shape:       img.FreeformTags[tagSourceShape],
+	}
+	if v, err := strconv.ParseFloat(img.FreeformTags[tagSourceOCPUs], 32); err == nil {
+		info.OCPUs = float32(v)
+	}
+	if v, err := strconv.ParseFloat(img.FreeformTags[tagSourceMemoryGB], 32); err == nil {
+		info.MemoryGB = float32(v)
+	}
+	if img.TimeCreated != nil {
+		info.TimeCreated = img.TimeCreated.Time
+	}
+	return info
+}
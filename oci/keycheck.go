@@ -0,0 +1,162 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"oci-bot/config"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+)
+
+// CheckKeyFile inspects the API private key of an account without calling
+// OCI. It returns an error saying how to fix the key when the bot can't sign
+// requests with it, and warnings for usable keys, e.g. when other users can
+// read the file.
+func CheckKeyFile(acc *config.OCIAccount) (warnings []string, err error) {
+	_, warnings, err = readKeyFile(acc)
+	return warnings, err
+}
+
+// readKeyFile reads and checks the account's private key, see CheckKeyFile
+func readKeyFile(acc *config.OCIAccount) ([]byte, []string, error) {
+	info, err := os.Stat(acc.KeyFile)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("key file does not exist")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var warnings []string
+	if mode := info.Mode().Perm(); runtime.GOOS != "windows" && mode&0o077 != 0 {
+		warnings = append(warnings, fmt.Sprintf("key file %s is accessible by other users (mode %04o), restrict it with: chmod 600 %s", acc.KeyFile, mode, acc.KeyFile))
+	}
+
+	keyContent, err := config.ReadSecretFile(acc.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var passphrase *string
+	if acc.KeyPassphrase != "" {
+		passphrase = common.String(acc.KeyPassphrase)
+	}
+	key, formatWarning, err := checkPrivateKey(keyContent, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	if formatWarning != "" {
+		warnings = append(warnings, formatWarning)
+	}
+	if fingerprint := keyFingerprint(key); acc.Fingerprint != "" && !strings.EqualFold(fingerprint, acc.Fingerprint) {
+		return nil, nil, fmt.Errorf("fingerprint %s does not belong to this key (its fingerprint is %s): set fingerprint to the key's, or upload its public key to the user", acc.Fingerprint, fingerprint)
+	}
+	return keyContent, warnings, nil
+}
+
+// KeyEncrypted reports whether a PEM private key needs a passphrase
+func KeyEncrypted(keyContent []byte) bool {
+	block, _ := pem.Decode(keyContent)
+	return block != nil && pemEncrypted(block)
+}
+
+func pemEncrypted(block *pem.Block) bool {
+	return block.Type == "ENCRYPTED PRIVATE KEY" || strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED")
+}
+
+// checkPrivateKey verifies the key is an RSA key in a format the SDK reads and
+// decodes with the given passphrase, so a wrong file fails with a hint how to
+// convert it instead of an opaque signing error on the first request. The
+// warning notes a PEM header that doesn't match the encoding inside.
+func checkPrivateKey(keyContent []byte, passphrase *string) (*rsa.PrivateKey, string, error) {
+	block, _ := pem.Decode(keyContent)
+	if block == nil {
+		return nil, "", notPEMError(keyContent)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY", "PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
+	case "OPENSSH PRIVATE KEY":
+		return nil, "", errors.New("key appears to be an OpenSSH key; API keys must be PEM, convert a copy with: ssh-keygen -p -m PEM -f <copy of key file>")
+	case "PUBLIC KEY", "RSA PUBLIC KEY":
+		return nil, "", errors.New("this is a public key; key_file must be the private key it was generated with")
+	case "EC PRIVATE KEY", "DSA PRIVATE KEY":
+		return nil, "", errors.New("OCI API keys must be RSA keys, generate one with: openssl genrsa -out oci_api_key.pem 2048")
+	case "CERTIFICATE":
+		return nil, "", errors.New("this is a certificate, not a private key")
+	default:
+		return nil, "", fmt.Errorf("unsupported PEM block %q, expected an RSA private key", block.Type)
+	}
+
+	encrypted := pemEncrypted(block)
+	if encrypted && passphrase == nil {
+		return nil, "", fmt.Errorf("private key is encrypted but key_passphrase is not set")
+	}
+
+	var password []byte
+	if passphrase != nil {
+		password = []byte(*passphrase)
+	}
+	key, err := common.PrivateKeyFromBytesWithPassword(keyContent, password)
+	if err != nil {
+		if encrypted {
+			return nil, "", fmt.Errorf("failed to decrypt private key (wrong key_passphrase?): %w", err)
+		}
+		if parsed, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes); pkcs8Err == nil {
+			return nil, "", fmt.Errorf("OCI API keys must be RSA keys, this is a %T", parsed)
+		}
+		return nil, "", fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	var warning string
+	if !encrypted {
+		_, pkcs1Err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		switch {
+		case block.Type == "RSA PRIVATE KEY" && pkcs1Err != nil:
+			warning = "key file is labelled PKCS#1 (BEGIN RSA PRIVATE KEY) but holds a PKCS#8 key; other tools may reject it"
+		case block.Type == "PRIVATE KEY" && pkcs1Err == nil:
+			warning = "key file is labelled PKCS#8 (BEGIN PRIVATE KEY) but holds a PKCS#1 key; other tools may reject it"
+		}
+	}
+	return key, warning, nil
+}
+
+// notPEMError guesses what a non-PEM key file holds
+func notPEMError(keyContent []byte) error {
+	text := bytes.TrimSpace(keyContent)
+	switch {
+	case bytes.HasPrefix(text, []byte("ssh-")):
+		return errors.New("this is an SSH public key; key_file must be the PEM private key of the API key")
+	case bytes.HasPrefix(text, []byte("PuTTY-User-Key-File")):
+		return errors.New("key appears to be a PuTTY key; convert it with: puttygen key.ppk -O private-openssh -o key.pem")
+	}
+	_, pkcs1Err := x509.ParsePKCS1PrivateKey(keyContent)
+	_, pkcs8Err := x509.ParsePKCS8PrivateKey(keyContent)
+	if pkcs1Err == nil || pkcs8Err == nil {
+		return errors.New("key is DER encoded; convert it with: openssl pkey -inform DER -in key.der -out key.pem")
+	}
+	return errors.New("not a PEM encoded private key")
+}
+
+// keyFingerprint returns the fingerprint OCI shows for the key: the MD5 of
+// the DER public key, as colon separated hex
+func keyFingerprint(key *rsa.PrivateKey) string {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return ""
+	}
+	sum := md5.Sum(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,98 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/monitoring"
+)
+
+// MetricPoint is one aggregated metric value
+type MetricPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// InstanceMetrics holds per-minute metrics of an instance, as reported by the
+// Oracle Cloud Agent (namespace oci_computeagent)
+type InstanceMetrics struct {
+	CPU      []MetricPoint // Percent
+	Memory   []MetricPoint // Percent
+	NetIn    []MetricPoint // Bytes per minute
+	NetOut   []MetricPoint // Bytes per minute
+	Interval time.Duration // Aggregation interval of each point
+}
+
+// GetInstanceMetrics returns CPU, memory and network metrics of an instance over the last window
+func (c *Client) GetInstanceMetrics(ctx context.Context, instanceID string, window time.Duration) (*InstanceMetrics, error) {
+	end := time.Now()
+	start := end.Add(-window)
+
+	metrics := &InstanceMetrics{Interval: time.Minute}
+	queries := []struct {
+		query string
+		dest  *[]MetricPoint
+	}{
+		{fmt.Sprintf(`CpuUtilization[1m]{resourceId = "%s"}.mean()`, instanceID), &metrics.CPU},
+		{fmt.Sprintf(`MemoryUtilization[1m]{resourceId = "%s"}.mean()`, instanceID), &metrics.Memory},
+		{fmt.Sprintf(`NetworksBytesIn[1m]{resourceId = "%s"}.sum()`, instanceID), &metrics.NetIn},
+		{fmt.Sprintf(`NetworksBytesOut[1m]{resourceId = "%s"}.sum()`, instanceID), &metrics.NetOut},
+	}
+
+	for _, q := range queries {
+		points, err := c.queryMetric(ctx, "oci_computeagent", q.query, start, end)
+		if err != nil {
+			return nil, err
+		}
+		*q.dest = points
+	}
+
+	return metrics, nil
+}
+
+// queryMetric runs a Monitoring Query Language query and returns the datapoints of all matching streams
+func (c *Client) queryMetric(ctx context.Context, namespace, query string, start, end time.Time) ([]MetricPoint, error) {
+	request := monitoring.SummarizeMetricsDataRequest{
+		CompartmentId: common.String(c.compartmentID),
+		SummarizeMetricsDataDetails: monitoring.SummarizeMetricsDataDetails{
+			Namespace: common.String(namespace),
+			Query:     common.String(query),
+			StartTime: &common.SDKTime{Time: start},
+			EndTime:   &common.SDKTime{Time: end},
+		},
+	}
+
+	response, err := c.monitorClient.SummarizeMetricsData(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics: %w", err)
+	}
+
+	var points []MetricPoint
+	for _, data := range response.Items {
+		for _, dp := range data.AggregatedDatapoints {
+			if dp.Timestamp == nil || dp.Value == nil {
+				continue
+			}
+			points = append(points, MetricPoint{Time: dp.Timestamp.Time, Value: *dp.Value})
+		}
+	}
+
+	return points, nil
+}
+
+// OutboundBytes returns the total bytes sent by an instance between start and now
+func (c *Client) OutboundBytes(ctx context.Context, instanceID string, start time.Time) (float64, error) {
+	query := fmt.Sprintf(`NetworksBytesOut[1h]{resourceId = "%s"}.sum()`, instanceID)
+	points, err := c.queryMetric(ctx, "oci_computeagent", query, start, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0.0
+	for _, p := range points {
+		total += p.Value
+	}
+	return total, nil
+}
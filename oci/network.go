@@ -0,0 +1,180 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/core"
+)
+
+// VNICInfo describes a VNIC attached to an instance
+type VNICInfo struct {
+	ID            string
+	DisplayName   string
+	IsPrimary     bool
+	MACAddress    string
+	SubnetName    string
+	SubnetCIDR    string
+	PrivateIPs    []PrivateIPInfo
+	SecurityLists []SecurityListInfo
+	NSGCount      int // Network security groups attached to the VNIC
+}
+
+// PrivateIPInfo is a private IP on a VNIC and the public IP mapped to it, if any
+type PrivateIPInfo struct {
+	ID        string
+	IPAddress string
+	IsPrimary bool
+	PublicIP  *PublicIPInfo // Lifetime tells ephemeral from reserved
+}
+
+// SecurityListInfo summarises a subnet security list
+type SecurityListInfo struct {
+	ID           string
+	DisplayName  string
+	IngressRules []string // e.g. "tcp 22 from 0.0.0.0/0"
+	EgressCount  int
+}
+
+// GetInstanceNetwork returns the VNICs of an instance with their private IPs,
+// mapped public IPs and the security lists of their subnets
+func (c *Client) GetInstanceNetwork(ctx context.Context, instanceID string) ([]VNICInfo, error) {
+	attachments, err := c.computeClient.ListVnicAttachments(ctx, core.ListVnicAttachmentsRequest{
+		CompartmentId: common.String(c.compartmentID),
+		InstanceId:    common.String(instanceID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VNIC attachments: %w", err)
+	}
+
+	var vnics []VNICInfo
+	for _, att := range attachments.Items {
+		if att.LifecycleState != core.VnicAttachmentLifecycleStateAttached || att.VnicId == nil {
+			continue
+		}
+
+		vnic, err := c.vnClient.GetVnic(ctx, core.GetVnicRequest{VnicId: att.VnicId})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get VNIC: %w", err)
+		}
+
+		info := VNICInfo{
+			ID:          safeString(vnic.Id),
+			DisplayName: safeString(vnic.DisplayName),
+			MACAddress:  safeString(vnic.MacAddress),
+			NSGCount:    len(vnic.NsgIds),
+		}
+		if vnic.IsPrimary != nil {
+			info.IsPrimary = *vnic.IsPrimary
+		}
+
+		if info.PrivateIPs, err = c.listPrivateIPs(ctx, info.ID); err != nil {
+			return nil, err
+		}
+
+		subnet, err := c.vnClient.GetSubnet(ctx, core.GetSubnetRequest{SubnetId: vnic.SubnetId})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get subnet: %w", err)
+		}
+		info.SubnetName = safeString(subnet.DisplayName)
+		info.SubnetCIDR = safeString(subnet.CidrBlock)
+
+		for _, id := range subnet.SecurityListIds {
+			list, err := c.vnClient.GetSecurityList(ctx, core.GetSecurityListRequest{SecurityListId: common.String(id)})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get security list: %w", err)
+			}
+			info.SecurityLists = append(info.SecurityLists, toSecurityListInfo(list.SecurityList))
+		}
+
+		vnics = append(vnics, info)
+	}
+
+	return vnics, nil
+}
+
+// listPrivateIPs lists the private IPs of a VNIC and resolves their public IPs
+func (c *Client) listPrivateIPs(ctx context.Context, vnicID string) ([]PrivateIPInfo, error) {
+	response, err := c.vnClient.ListPrivateIps(ctx, core.ListPrivateIpsRequest{
+		VnicId: common.String(vnicID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list private IPs: %w", err)
+	}
+
+	var ips []PrivateIPInfo
+	for _, ip := range response.Items {
+		info := PrivateIPInfo{
+			ID:        safeString(ip.Id),
+			IPAddress: safeString(ip.IpAddress),
+		}
+		if ip.IsPrimary != nil {
+			info.IsPrimary = *ip.IsPrimary
+		}
+
+		public, err := c.vnClient.GetPublicIpByPrivateIpId(ctx, core.GetPublicIpByPrivateIpIdRequest{
+			GetPublicIpByPrivateIpIdDetails: core.GetPublicIpByPrivateIpIdDetails{PrivateIpId: ip.Id},
+		})
+		if err != nil {
+			// No public IP mapped to this private IP
+			if serviceErr, ok := common.IsServiceError(err); !ok || serviceErr.GetHTTPStatusCode() != http.StatusNotFound {
+				return nil, fmt.Errorf("failed to get public IP: %w", err)
+			}
+		} else {
+			info.PublicIP = &PublicIPInfo{
+				ID:               safeString(public.Id),
+				IPAddress:        safeString(public.IpAddress),
+				DisplayName:      safeString(public.DisplayName),
+				Lifetime:         string(public.Lifetime),
+				State:            string(public.LifecycleState),
+				AssignedEntityID: safeString(public.AssignedEntityId),
+			}
+		}
+
+		ips = append(ips, info)
+	}
+
+	return ips, nil
+}
+
+func toSecurityListInfo(list core.SecurityList) SecurityListInfo {
+	info := SecurityListInfo{
+		ID:          safeString(list.Id),
+		DisplayName: safeString(list.DisplayName),
+		EgressCount: len(list.EgressSecurityRules),
+	}
+
+	for _, rule := range list.IngressSecurityRules {
+		var ports *core.PortRange
+		protocol := safeString(rule.Protocol)
+		switch protocol {
+		case "6":
+			protocol = "tcp"
+			if rule.TcpOptions != nil {
+				ports = rule.TcpOptions.DestinationPortRange
+			}
+		case "17":
+			protocol = "udp"
+			if rule.UdpOptions != nil {
+				ports = rule.UdpOptions.DestinationPortRange
+			}
+		case "1":
+			protocol = "icmp"
+		}
+
+		desc := protocol
+		if ports != nil && ports.Min != nil && ports.Max != nil {
+			if *ports.Min == *ports.Max {
+				desc += fmt.Sprintf(" %d", *ports.Min)
+			} else {
+				desc += fmt.Sprintf(" %d-%d", *ports.Min, *ports.Max)
+			}
+		}
+		desc += " from " + safeString(rule.Source)
+		info.IngressRules = append(info.IngressRules, desc)
+	}
+
+	return info
+}
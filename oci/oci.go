@@ -2,65 +2,97 @@ package oci
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"slices"
 	"time"
 
 	"oci-bot/config"
+	"oci-bot/logging"
 
+	"github.com/oracle/oci-go-sdk/v65/audit"
 	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/computeinstanceagent"
 	"github.com/oracle/oci-go-sdk/v65/core"
+	"github.com/oracle/oci-go-sdk/v65/identity"
+	"github.com/oracle/oci-go-sdk/v65/monitoring"
+	"github.com/oracle/oci-go-sdk/v65/usageapi"
+	"github.com/oracle/oci-go-sdk/v65/workrequests"
 )
 
+var logger = logging.New("oci")
+
 // Client wraps the OCI VirtualNetwork client
 type Client struct {
-	vnClient      core.VirtualNetworkClient
-	computeClient core.ComputeClient
-	compartmentID string
-	region        string
-	accountName   string
+	vnClient       core.VirtualNetworkClient
+	computeClient  core.ComputeClient
+	blockClient    core.BlockstorageClient
+	agentClient    computeinstanceagent.ComputeInstanceAgentClient
+	identityClient identity.IdentityClient
+	usageClient    usageapi.UsageapiClient
+	auditClient    audit.AuditClient
+	monitorClient  monitoring.MonitoringClient
+	workClient     workrequests.WorkRequestClient
+	provider       *keyProvider // Shared by all SDK clients above
+	tenancyID      string
+	userID         string
+	compartmentID  string
+	protected      []string // Compartments DeleteReservedIP refuses to delete from
+	ipPoolID       string   // Public IP pool for new reserved IPs (empty = Oracle's pool)
+	region         string
+	accountName    string
 }
 
 // PublicIPInfo contains information about a reserved public IP
 type PublicIPInfo struct {
-	ID          string
-	IPAddress   string
-	DisplayName string
-	Lifetime    string
-	State       string
+	ID               string
+	IPAddress        string
+	DisplayName      string
+	Lifetime         string
+	State            string
+	AssignedEntityID string // Private IP the IP is attached to (empty if unattached)
+	CompartmentID    string
+	PoolID           string // Public IP pool the address came from (empty for Oracle's pool)
+	TimeCreated      time.Time
+	Tags             Tags
 }
 
+// ErrProtectedCompartment is returned when deleting an IP from a protected compartment
+var ErrProtectedCompartment = errors.New("IP is in a protected compartment")
+
 // NewClient creates a new OCI client from account config
 func NewClient(acc *config.OCIAccount) (*Client, error) {
 	// Debug logging
-	log.Printf("Creating OCI client for [%s]", acc.Name)
-	log.Printf("  Tenancy: %s", acc.Tenancy)
-	log.Printf("  User: %s", acc.User)
-	log.Printf("  Region: %s", acc.Region)
-	log.Printf("  Fingerprint: %s", acc.Fingerprint)
-	log.Printf("  KeyFile: %s", acc.KeyFile)
-
-	// Check if key file exists
-	if _, err := os.Stat(acc.KeyFile); os.IsNotExist(err) {
-		return nil, fmt.Errorf("key file does not exist: %s", acc.KeyFile)
+	logger.Infof("Creating OCI client for [%s]", acc.Name)
+	logger.Debugf("  Tenancy: %s", acc.Tenancy)
+	logger.Debugf("  User: %s", acc.User)
+	logger.Debugf("  Region: %s", acc.Region)
+	logger.Debugf("  Fingerprint: %s", acc.Fingerprint)
+	logger.Debugf("  KeyFile: %s", acc.KeyFile)
+
+	keyContent, warnings, err := readKeyFile(acc)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", acc.KeyFile, err)
+	}
+	for _, warning := range warnings {
+		logger.Warnf("[%s] %s", acc.Name, warning)
 	}
+	logger.Debugf("  Key file read OK (%d bytes)", len(keyContent))
 
-	// Read private key file content
-	keyContent, err := os.ReadFile(acc.KeyFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read key file %s: %w", acc.KeyFile, err)
+	var passphrase *string
+	if acc.KeyPassphrase != "" {
+		passphrase = common.String(acc.KeyPassphrase)
 	}
-	log.Printf("  Key file read OK (%d bytes)", len(keyContent))
 
-	configProvider := common.NewRawConfigurationProvider(
+	// Wrapped so the key can be rotated without rebuilding the clients
+	configProvider := &keyProvider{provider: common.NewRawConfigurationProvider(
 		acc.Tenancy,
 		acc.User,
 		acc.Region,
 		acc.Fingerprint,
 		string(keyContent),
-		nil,
-	)
+		passphrase,
+	)}
 
 	vnClient, err := core.NewVirtualNetworkClientWithConfigurationProvider(configProvider)
 	if err != nil {
@@ -72,18 +104,89 @@ func NewClient(acc *config.OCIAccount) (*Client, error) {
 		return nil, fmt.Errorf("failed to create Compute client: %w", err)
 	}
 
+	blockClient, err := core.NewBlockstorageClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Blockstorage client: %w", err)
+	}
+
+	agentClient, err := computeinstanceagent.NewComputeInstanceAgentClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Instance Agent client: %w", err)
+	}
+
+	identityClient, err := identity.NewIdentityClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Identity client: %w", err)
+	}
+
+	usageClient, err := usageapi.NewUsageapiClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Usage API client: %w", err)
+	}
+
+	auditClient, err := audit.NewAuditClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Audit client: %w", err)
+	}
+
+	monitorClient, err := monitoring.NewMonitoringClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Monitoring client: %w", err)
+	}
+
+	workClient, err := workrequests.NewWorkRequestClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Work Requests client: %w", err)
+	}
+
 	vnClient.SetRegion(acc.Region)
 	computeClient.SetRegion(acc.Region)
+	blockClient.SetRegion(acc.Region)
+	agentClient.SetRegion(acc.Region)
+	identityClient.SetRegion(acc.Region)
+	usageClient.SetRegion(acc.Region)
+	auditClient.SetRegion(acc.Region)
+	monitorClient.SetRegion(acc.Region)
+	workClient.SetRegion(acc.Region)
 
 	return &Client{
-		vnClient:      vnClient,
-		computeClient: computeClient,
-		compartmentID: acc.CompartmentID,
-		region:        acc.Region,
-		accountName:   acc.Name,
+		vnClient:       vnClient,
+		computeClient:  computeClient,
+		blockClient:    blockClient,
+		agentClient:    agentClient,
+		identityClient: identityClient,
+		usageClient:    usageClient,
+		auditClient:    auditClient,
+		monitorClient:  monitorClient,
+		workClient:     workClient,
+		provider:       configProvider,
+		tenancyID:      acc.Tenancy,
+		userID:         acc.User,
+		compartmentID:  acc.CompartmentID,
+		protected:      acc.ProtectedCompartments,
+		ipPoolID:       acc.PublicIPPoolID,
+		region:         acc.Region,
+		accountName:    acc.Name,
 	}, nil
 }
 
+// InRegion returns a client for the same account in another subscribed region.
+// It shares the API key, so a key rotation applies to both.
+func (c *Client) InRegion(region string) Service {
+	regional := *c
+	regional.region = region
+	regional.vnClient.SetRegion(region)
+	regional.computeClient.SetRegion(region)
+	regional.blockClient.SetRegion(region)
+	regional.agentClient.SetRegion(region)
+	regional.identityClient.SetRegion(region)
+	regional.usageClient.SetRegion(region)
+	regional.auditClient.SetRegion(region)
+	regional.monitorClient.SetRegion(region)
+	regional.workClient.SetRegion(region)
+	return &regional
+}
+
 // AccountName returns the account name
 func (c *Client) AccountName() string {
 	return c.accountName
@@ -101,7 +204,12 @@ func (c *Client) CreateReservedIP(ctx context.Context, displayName string) (*Pub
 			CompartmentId: common.String(c.compartmentID),
 			Lifetime:      core.CreatePublicIpDetailsLifetimeReserved,
 			DisplayName:   common.String(displayName),
+			FreeformTags:  c.managedTags(),
 		},
+		OpcRetryToken: retryToken(ctx, "public-ip", c.accountName, c.region),
+	}
+	if c.ipPoolID != "" {
+		request.CreatePublicIpDetails.PublicIpPoolId = common.String(c.ipPoolID)
 	}
 
 	response, err := c.vnClient.CreatePublicIp(ctx, request)
@@ -115,16 +223,27 @@ func (c *Client) CreateReservedIP(ctx context.Context, displayName string) (*Pub
 		DisplayName: safeString(response.PublicIp.DisplayName),
 		Lifetime:    string(response.PublicIp.Lifetime),
 		State:       string(response.PublicIp.LifecycleState),
+		PoolID:      safeString(response.PublicIp.PublicIpPoolId),
+		Tags:        Tags{Freeform: response.PublicIp.FreeformTags, Defined: response.PublicIp.DefinedTags},
 	}, nil
 }
 
-// DeleteReservedIP deletes a reserved public IP by its OCID
+// DeleteReservedIP deletes a reserved public IP by its OCID. IPs in a
+// protected compartment are never deleted.
 func (c *Client) DeleteReservedIP(ctx context.Context, publicIPID string) error {
+	current, err := c.vnClient.GetPublicIp(ctx, core.GetPublicIpRequest{PublicIpId: common.String(publicIPID)})
+	if err != nil {
+		return fmt.Errorf("failed to get reserved IP: %w", err)
+	}
+	if slices.Contains(c.protected, safeString(current.CompartmentId)) {
+		return ErrProtectedCompartment
+	}
+
 	request := core.DeletePublicIpRequest{
 		PublicIpId: common.String(publicIPID),
 	}
 
-	_, err := c.vnClient.DeletePublicIp(ctx, request)
+	_, err = c.vnClient.DeletePublicIp(ctx, request)
 	if err != nil {
 		return fmt.Errorf("failed to delete reserved IP: %w", err)
 	}
@@ -164,8 +283,13 @@ func (c *Client) WaitForIPReady(ctx context.Context, publicIPID string, timeout
 
 // ListReservedIPs lists all reserved public IPs in the compartment
 func (c *Client) ListReservedIPs(ctx context.Context) ([]PublicIPInfo, error) {
+	return c.ListReservedIPsIn(ctx, c.compartmentID)
+}
+
+// ListReservedIPsIn lists all reserved public IPs in the given compartment
+func (c *Client) ListReservedIPsIn(ctx context.Context, compartmentID string) ([]PublicIPInfo, error) {
 	request := core.ListPublicIpsRequest{
-		CompartmentId: common.String(c.compartmentID),
+		CompartmentId: common.String(compartmentID),
 		Scope:         core.ListPublicIpsScopeRegion,
 		Lifetime:      core.ListPublicIpsLifetimeReserved,
 	}
@@ -177,18 +301,89 @@ func (c *Client) ListReservedIPs(ctx context.Context) ([]PublicIPInfo, error) {
 
 	var ips []PublicIPInfo
 	for _, ip := range response.Items {
-		ips = append(ips, PublicIPInfo{
-			ID:          *ip.Id,
-			IPAddress:   safeString(ip.IpAddress),
-			DisplayName: safeString(ip.DisplayName),
-			Lifetime:    string(ip.Lifetime),
-			State:       string(ip.LifecycleState),
-		})
+		info := PublicIPInfo{
+			ID:               *ip.Id,
+			IPAddress:        safeString(ip.IpAddress),
+			DisplayName:      safeString(ip.DisplayName),
+			Lifetime:         string(ip.Lifetime),
+			State:            string(ip.LifecycleState),
+			AssignedEntityID: safeString(ip.AssignedEntityId),
+			CompartmentID:    safeString(ip.CompartmentId),
+			PoolID:           safeString(ip.PublicIpPoolId),
+			Tags:             Tags{Freeform: ip.FreeformTags, Defined: ip.DefinedTags},
+		}
+		if ip.TimeCreated != nil {
+			info.TimeCreated = ip.TimeCreated.Time
+		}
+		ips = append(ips, info)
 	}
 
 	return ips, nil
 }
 
+// RenameReservedIP changes the display name of a reserved public IP
+func (c *Client) RenameReservedIP(ctx context.Context, publicIPID, displayName string) error {
+	request := core.UpdatePublicIpRequest{
+		PublicIpId: common.String(publicIPID),
+		UpdatePublicIpDetails: core.UpdatePublicIpDetails{
+			DisplayName: common.String(displayName),
+		},
+	}
+
+	if _, err := c.vnClient.UpdatePublicIp(ctx, request); err != nil {
+		return fmt.Errorf("failed to rename reserved IP: %w", err)
+	}
+	return nil
+}
+
+// AssignReservedIP binds a reserved public IP to a private IP, or unbinds it
+// when privateIPID is empty, and waits until the change is done
+func (c *Client) AssignReservedIP(ctx context.Context, publicIPID, privateIPID string) error {
+	request := core.UpdatePublicIpRequest{
+		PublicIpId: common.String(publicIPID),
+		UpdatePublicIpDetails: core.UpdatePublicIpDetails{
+			PrivateIpId: common.String(privateIPID),
+		},
+	}
+	if _, err := c.vnClient.UpdatePublicIp(ctx, request); err != nil {
+		return fmt.Errorf("failed to assign reserved IP: %w", err)
+	}
+
+	want := core.PublicIpLifecycleStateAssigned
+	if privateIPID == "" {
+		want = core.PublicIpLifecycleStateAvailable
+	}
+	for {
+		response, err := c.vnClient.GetPublicIp(ctx, core.GetPublicIpRequest{PublicIpId: common.String(publicIPID)})
+		if err != nil {
+			return fmt.Errorf("failed to get public IP status: %w", err)
+		}
+		if response.PublicIp.LifecycleState == want {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timeout waiting for public IP to become %s", want)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// ChangePublicIPCompartment moves a reserved public IP into another compartment
+func (c *Client) ChangePublicIPCompartment(ctx context.Context, publicIPID, compartmentID string) error {
+	request := core.ChangePublicIpCompartmentRequest{
+		PublicIpId: common.String(publicIPID),
+		ChangePublicIpCompartmentDetails: core.ChangePublicIpCompartmentDetails{
+			CompartmentId: common.String(compartmentID),
+		},
+	}
+
+	if _, err := c.vnClient.ChangePublicIpCompartment(ctx, request); err != nil {
+		return fmt.Errorf("failed to move reserved IP: %w", err)
+	}
+	return nil
+}
+
 func safeString(s *string) string {
 	if s == nil {
 		return ""
@@ -0,0 +1,671 @@
+// Package ocifake provides an in-memory oci.Service for exercising bot logic
+// without OCI credentials.
+package ocifake
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"oci-bot/oci"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/core"
+)
+
+// Client is a fake oci.Service that keeps reserved IPs, instances and images in memory.
+// Set the *Err fields to make the corresponding calls fail.
+type Client struct {
+	Name       string
+	RegionName string
+
+	// Compartment is the working compartment; IPs in Protected compartments
+	// can't be deleted.
+	Compartment string
+	Protected   []string
+
+	// NextIPs is consumed in order by CreateReservedIP; when empty,
+	// addresses are generated from 10.0.0.1 upward.
+	NextIPs []string
+
+	CreateErr error
+	DeleteErr error
+	ListErr   error
+	LaunchErr error
+
+	Cost        *oci.CostSummary
+	AuditEvents []oci.AuditEventInfo
+	Metrics     map[string]*oci.InstanceMetrics // Instance ID -> metrics
+	Outbound    map[string]float64              // Instance ID -> bytes sent this period
+	Networks    map[string][]oci.VNICInfo       // Instance ID -> VNICs
+	Regions     []oci.RegionSubscription        // Subscribed regions (default: RegionName only)
+
+	mu        sync.Mutex
+	seq       int
+	ips       []oci.PublicIPInfo
+	instances []oci.InstanceInfo
+	images    []oci.ImageInfo
+	volumes   []oci.VolumeInfo
+	backups   []oci.BackupInfo
+	policies  map[string]string // Volume ID -> policy ID
+	apiKeys   []string          // Fingerprints of uploaded API keys
+	keyInUse  string
+	calls     []string
+}
+
+var _ oci.Service = (*Client)(nil)
+
+// New returns an empty fake account
+func New(name, region string) *Client {
+	return &Client{Name: name, RegionName: region}
+}
+
+// AccountName returns the account name
+func (c *Client) AccountName() string {
+	return c.Name
+}
+
+// Region returns the region
+func (c *Client) Region() string {
+	return c.RegionName
+}
+
+// Calls returns the names of the methods called so far, in order
+func (c *Client) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.calls...)
+}
+
+// AddReservedIP seeds an existing reserved IP
+func (c *Client) AddReservedIP(ip oci.PublicIPInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ips = append(c.ips, ip)
+}
+
+// AddInstance seeds an existing instance
+func (c *Client) AddInstance(inst oci.InstanceInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.instances = append(c.instances, inst)
+}
+
+// AddVolume seeds an existing boot volume
+func (c *Client) AddVolume(vol oci.VolumeInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.volumes = append(c.volumes, vol)
+}
+
+// CreateReservedIP creates an AVAILABLE reserved IP
+func (c *Client) CreateReservedIP(ctx context.Context, displayName string) (*oci.PublicIPInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "CreateReservedIP")
+
+	if c.CreateErr != nil {
+		return nil, c.CreateErr
+	}
+
+	c.seq++
+	addr := fmt.Sprintf("10.0.0.%d", c.seq)
+	if len(c.NextIPs) > 0 {
+		addr, c.NextIPs = c.NextIPs[0], c.NextIPs[1:]
+	}
+
+	ip := oci.PublicIPInfo{
+		ID:            fmt.Sprintf("ocid1.publicip.fake.%d", c.seq),
+		IPAddress:     addr,
+		DisplayName:   displayName,
+		Lifetime:      "RESERVED",
+		State:         "AVAILABLE",
+		CompartmentID: c.Compartment,
+		TimeCreated:   time.Now(),
+		Tags:          oci.Tags{Freeform: map[string]string{oci.ManagedTag: c.Name}},
+	}
+	c.ips = append(c.ips, ip)
+	return &ip, nil
+}
+
+// DeleteReservedIP removes a reserved IP by ID
+func (c *Client) DeleteReservedIP(ctx context.Context, publicIPID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "DeleteReservedIP")
+
+	if c.DeleteErr != nil {
+		return c.DeleteErr
+	}
+	for i, ip := range c.ips {
+		if ip.ID == publicIPID {
+			if slices.Contains(c.Protected, ip.CompartmentID) {
+				return oci.ErrProtectedCompartment
+			}
+			c.ips = append(c.ips[:i], c.ips[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("public IP not found: %s", publicIPID)
+}
+
+// WaitForIPReady returns the IP immediately since fake IPs are always available
+func (c *Client) WaitForIPReady(ctx context.Context, publicIPID string, timeout time.Duration) (*oci.PublicIPInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "WaitForIPReady")
+
+	for _, ip := range c.ips {
+		if ip.ID == publicIPID {
+			return &ip, nil
+		}
+	}
+	return nil, fmt.Errorf("public IP not found: %s", publicIPID)
+}
+
+// ListReservedIPs returns the reserved IPs in the working compartment
+func (c *Client) ListReservedIPs(ctx context.Context) ([]oci.PublicIPInfo, error) {
+	return c.listIn("ListReservedIPs", c.Compartment)
+}
+
+// ListReservedIPsIn returns the reserved IPs in the given compartment
+func (c *Client) ListReservedIPsIn(ctx context.Context, compartmentID string) ([]oci.PublicIPInfo, error) {
+	return c.listIn("ListReservedIPsIn", compartmentID)
+}
+
+func (c *Client) listIn(call, compartmentID string) ([]oci.PublicIPInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, call)
+
+	if c.ListErr != nil {
+		return nil, c.ListErr
+	}
+	var ips []oci.PublicIPInfo
+	for _, ip := range c.ips {
+		if ip.CompartmentID == compartmentID {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// ChangePublicIPCompartment moves a reserved IP to another compartment
+func (c *Client) ChangePublicIPCompartment(ctx context.Context, publicIPID, compartmentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "ChangePublicIPCompartment")
+
+	for i := range c.ips {
+		if c.ips[i].ID == publicIPID {
+			c.ips[i].CompartmentID = compartmentID
+			return nil
+		}
+	}
+	return fmt.Errorf("public IP not found: %s", publicIPID)
+}
+
+// RenameReservedIP changes the display name of a reserved IP
+func (c *Client) RenameReservedIP(ctx context.Context, publicIPID, displayName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "RenameReservedIP")
+
+	for i := range c.ips {
+		if c.ips[i].ID == publicIPID {
+			c.ips[i].DisplayName = displayName
+			return nil
+		}
+	}
+	return fmt.Errorf("public IP not found: %s", publicIPID)
+}
+
+// AssignReservedIP binds a reserved IP to a private IP, or unbinds it when
+// privateIPID is empty
+func (c *Client) AssignReservedIP(ctx context.Context, publicIPID, privateIPID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "AssignReservedIP")
+
+	for i := range c.ips {
+		if c.ips[i].ID == publicIPID {
+			c.ips[i].AssignedEntityID = privateIPID
+			c.ips[i].State = "ASSIGNED"
+			if privateIPID == "" {
+				c.ips[i].State = "AVAILABLE"
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("public IP not found: %s", publicIPID)
+}
+
+// SetReservedIPTags replaces the non-nil tag maps of a reserved IP
+func (c *Client) SetReservedIPTags(ctx context.Context, publicIPID string, tags oci.Tags) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "SetReservedIPTags")
+
+	for i := range c.ips {
+		if c.ips[i].ID == publicIPID {
+			mergeTags(&c.ips[i].Tags, tags)
+			return nil
+		}
+	}
+	return fmt.Errorf("public IP not found: %s", publicIPID)
+}
+
+// LaunchInstanceWithFallback records a RUNNING instance in the requested AD
+func (c *Client) LaunchInstanceWithFallback(ctx context.Context, details oci.VPSLaunchDetails, tryFaultDomains bool) (*oci.LaunchedInstance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "LaunchInstanceWithFallback")
+
+	if c.LaunchErr != nil {
+		attempt := oci.LaunchAttempt{AvailabilityDomain: details.AvailabilityDomain, Err: c.LaunchErr}
+		return nil, &oci.LaunchError{Attempts: []oci.LaunchAttempt{attempt}, Err: c.LaunchErr}
+	}
+
+	c.seq++
+	info := oci.InstanceInfo{
+		ID:                 fmt.Sprintf("ocid1.instance.fake.%d", c.seq),
+		DisplayName:        details.DisplayName,
+		Shape:              details.Shape,
+		State:              string(core.InstanceLifecycleStateRunning),
+		AvailabilityDomain: details.AvailabilityDomain,
+		OCPUs:              details.OCPUs,
+		MemoryGB:           details.MemoryGB,
+		Tags:               oci.Tags{Freeform: map[string]string{oci.ManagedTag: c.Name}},
+	}
+	c.instances = append(c.instances, info)
+
+	return &oci.LaunchedInstance{
+		Instance: core.Instance{
+			Id:                 common.String(info.ID),
+			DisplayName:        common.String(info.DisplayName),
+			Shape:              common.String(info.Shape),
+			AvailabilityDomain: common.String(info.AvailabilityDomain),
+			LifecycleState:     core.InstanceLifecycleStateRunning,
+		},
+		WorkRequestID: fmt.Sprintf("ocid1.workrequest.fake.%d", c.seq),
+		Attempts:      []oci.LaunchAttempt{{AvailabilityDomain: details.AvailabilityDomain}},
+	}, nil
+}
+
+// WaitForWorkRequest reports the work request as done immediately
+func (c *Client) WaitForWorkRequest(ctx context.Context, workRequestID string, onProgress func(oci.WorkRequestProgress)) error {
+	c.mu.Lock()
+	c.calls = append(c.calls, "WaitForWorkRequest")
+	c.mu.Unlock()
+
+	if onProgress != nil {
+		onProgress(oci.WorkRequestProgress{ID: workRequestID, Status: "SUCCEEDED", Percent: 100})
+	}
+	return nil
+}
+
+// ListInstances returns all instances
+func (c *Client) ListInstances(ctx context.Context) ([]oci.InstanceInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "ListInstances")
+
+	if c.ListErr != nil {
+		return nil, c.ListErr
+	}
+	return append([]oci.InstanceInfo(nil), c.instances...), nil
+}
+
+// RenameInstance changes the display name of an instance
+func (c *Client) RenameInstance(ctx context.Context, instanceID, displayName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "RenameInstance")
+
+	for i := range c.instances {
+		if c.instances[i].ID == instanceID {
+			c.instances[i].DisplayName = displayName
+			return nil
+		}
+	}
+	return fmt.Errorf("instance not found: %s", instanceID)
+}
+
+// SetInstanceTags replaces the non-nil tag maps of an instance
+func (c *Client) SetInstanceTags(ctx context.Context, instanceID string, tags oci.Tags) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "SetInstanceTags")
+
+	for i := range c.instances {
+		if c.instances[i].ID == instanceID {
+			mergeTags(&c.instances[i].Tags, tags)
+			return nil
+		}
+	}
+	return fmt.Errorf("instance not found: %s", instanceID)
+}
+
+// mergeTags mirrors OCI update semantics: a nil map leaves tags unchanged
+func mergeTags(dst *oci.Tags, src oci.Tags) {
+	if src.Freeform != nil {
+		dst.Freeform = src.Freeform
+	}
+	if src.Defined != nil {
+		dst.Defined = src.Defined
+	}
+}
+
+// CreateImageFromInstance records an AVAILABLE custom image
+func (c *Client) CreateImageFromInstance(ctx context.Context, instance oci.InstanceInfo, displayName string) (*oci.ImageInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "CreateImageFromInstance")
+
+	c.seq++
+	img := oci.ImageInfo{
+		ID:          fmt.Sprintf("ocid1.image.fake.%d", c.seq),
+		DisplayName: displayName,
+		State:       "AVAILABLE",
+		Shape:       instance.Shape,
+		OCPUs:       instance.OCPUs,
+		MemoryGB:    instance.MemoryGB,
+		TimeCreated: time.Now(),
+
+		WorkRequestID: fmt.Sprintf("ocid1.workrequest.fake.%d", c.seq),
+	}
+	c.images = append(c.images, img)
+	return &img, nil
+}
+
+// WaitForImageAvailable returns the image immediately
+func (c *Client) WaitForImageAvailable(ctx context.Context, imageID string, timeout time.Duration) (*oci.ImageInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "WaitForImageAvailable")
+
+	for _, img := range c.images {
+		if img.ID == imageID {
+			return &img, nil
+		}
+	}
+	return nil, fmt.Errorf("image not found: %s", imageID)
+}
+
+// ListCustomImages returns the AVAILABLE custom images
+func (c *Client) ListCustomImages(ctx context.Context) ([]oci.ImageInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "ListCustomImages")
+
+	var images []oci.ImageInfo
+	for _, img := range c.images {
+		if img.State == "AVAILABLE" {
+			images = append(images, img)
+		}
+	}
+	return images, nil
+}
+
+// RunInstanceCommand records the script and returns a fake command ID
+func (c *Client) RunInstanceCommand(ctx context.Context, instanceID, script string, timeoutSeconds int) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "RunInstanceCommand")
+
+	c.seq++
+	return fmt.Sprintf("ocid1.instanceagentcommand.fake.%d", c.seq), nil
+}
+
+// MonthToDateCost returns Cost, or a zero summary when unset
+func (c *Client) MonthToDateCost(ctx context.Context) (*oci.CostSummary, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "MonthToDateCost")
+
+	if c.Cost != nil {
+		return c.Cost, nil
+	}
+	return &oci.CostSummary{Currency: "USD"}, nil
+}
+
+// GetInstanceNetwork returns Networks[instanceID]
+func (c *Client) GetInstanceNetwork(ctx context.Context, instanceID string) ([]oci.VNICInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "GetInstanceNetwork")
+
+	return c.Networks[instanceID], nil
+}
+
+// ListBootVolumes returns the seeded volumes
+func (c *Client) ListBootVolumes(ctx context.Context) ([]oci.VolumeInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "ListBootVolumes")
+
+	return append([]oci.VolumeInfo(nil), c.volumes...), nil
+}
+
+// ListBlockVolumes returns no block volumes
+func (c *Client) ListBlockVolumes(ctx context.Context) ([]oci.VolumeInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "ListBlockVolumes")
+
+	return nil, nil
+}
+
+// CreateBootVolumeBackup records an AVAILABLE backup
+func (c *Client) CreateBootVolumeBackup(ctx context.Context, bootVolumeID, displayName string) (*oci.BackupInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "CreateBootVolumeBackup")
+
+	c.seq++
+	backup := oci.BackupInfo{
+		ID:           fmt.Sprintf("ocid1.bootvolumebackup.fake.%d", c.seq),
+		DisplayName:  displayName,
+		BootVolumeID: bootVolumeID,
+		State:        "AVAILABLE",
+		TimeCreated:  time.Now(),
+	}
+	c.backups = append(c.backups, backup)
+	return &backup, nil
+}
+
+// ListBootVolumeBackups returns the volume's backups, newest first
+func (c *Client) ListBootVolumeBackups(ctx context.Context, bootVolumeID string) ([]oci.BackupInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "ListBootVolumeBackups")
+
+	var backups []oci.BackupInfo
+	for i := len(c.backups) - 1; i >= 0; i-- {
+		if c.backups[i].BootVolumeID == bootVolumeID {
+			backups = append(backups, c.backups[i])
+		}
+	}
+	return backups, nil
+}
+
+// DeleteBootVolumeBackup removes a backup
+func (c *Client) DeleteBootVolumeBackup(ctx context.Context, backupID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "DeleteBootVolumeBackup")
+
+	for i, backup := range c.backups {
+		if backup.ID == backupID {
+			c.backups = append(c.backups[:i], c.backups[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("backup %s not found", backupID)
+}
+
+// ListBackupPolicies returns the Oracle-defined policies
+func (c *Client) ListBackupPolicies(ctx context.Context) ([]oci.BackupPolicyInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "ListBackupPolicies")
+
+	return []oci.BackupPolicyInfo{
+		{ID: "ocid1.volumebackuppolicy.fake.gold", DisplayName: "gold"},
+		{ID: "ocid1.volumebackuppolicy.fake.silver", DisplayName: "silver"},
+		{ID: "ocid1.volumebackuppolicy.fake.bronze", DisplayName: "bronze"},
+	}, nil
+}
+
+// GetBackupPolicy returns the policy assigned by SetBackupPolicy
+func (c *Client) GetBackupPolicy(ctx context.Context, volumeID string) (*oci.BackupPolicyInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "GetBackupPolicy")
+
+	id, ok := c.policies[volumeID]
+	if !ok {
+		return nil, nil
+	}
+	return &oci.BackupPolicyInfo{ID: id, DisplayName: id}, nil
+}
+
+// SetBackupPolicy records the volume's policy; an empty policyID removes it
+func (c *Client) SetBackupPolicy(ctx context.Context, volumeID, policyID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "SetBackupPolicy")
+
+	if c.policies == nil {
+		c.policies = make(map[string]string)
+	}
+	if policyID == "" {
+		delete(c.policies, volumeID)
+	} else {
+		c.policies[volumeID] = policyID
+	}
+	return nil
+}
+
+// ListWriteEvents returns the AuditEvents that fall between start and end
+func (c *Client) ListWriteEvents(ctx context.Context, start, end time.Time) ([]oci.AuditEventInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "ListWriteEvents")
+
+	var events []oci.AuditEventInfo
+	for _, ev := range c.AuditEvents {
+		if !ev.Time.Before(start) && ev.Time.Before(end) {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// GetInstanceMetrics returns Metrics[instanceID], or empty metrics when unset
+func (c *Client) GetInstanceMetrics(ctx context.Context, instanceID string, window time.Duration) (*oci.InstanceMetrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "GetInstanceMetrics")
+
+	if m, ok := c.Metrics[instanceID]; ok {
+		return m, nil
+	}
+	return &oci.InstanceMetrics{Interval: time.Minute}, nil
+}
+
+// OutboundBytes returns Outbound[instanceID]
+func (c *Client) OutboundBytes(ctx context.Context, instanceID string, start time.Time) (float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "OutboundBytes")
+
+	return c.Outbound[instanceID], nil
+}
+
+// GetTenancyInfo returns a tenancy named after the account
+func (c *Client) GetTenancyInfo(ctx context.Context) (*oci.TenancyInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "GetTenancyInfo")
+
+	return &oci.TenancyInfo{ID: "ocid1.tenancy.fake." + c.Name, Name: c.Name, HomeRegion: c.RegionName}, nil
+}
+
+// GetUserInfo returns a user with the uploaded API keys
+func (c *Client) GetUserInfo(ctx context.Context) (*oci.UserInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "GetUserInfo")
+
+	info := &oci.UserInfo{ID: "ocid1.user.fake." + c.Name, Name: c.Name, KeyInUse: c.keyInUse, CanUseAPIKeys: true}
+	for _, fp := range c.apiKeys {
+		info.APIKeys = append(info.APIKeys, oci.APIKeyInfo{Fingerprint: fp, State: "ACTIVE"})
+	}
+	return info, nil
+}
+
+// ListRegionSubscriptions returns Regions, or RegionName as the home region
+func (c *Client) ListRegionSubscriptions(ctx context.Context) ([]oci.RegionSubscription, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "ListRegionSubscriptions")
+
+	if len(c.Regions) > 0 {
+		return c.Regions, nil
+	}
+	return []oci.RegionSubscription{{Name: c.RegionName, Status: "READY", IsHome: true}}, nil
+}
+
+// InRegion returns an empty fake of the same account in another region
+func (c *Client) InRegion(region string) oci.Service {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "InRegion")
+
+	return &Client{Name: c.Name, RegionName: region, Regions: c.Regions}
+}
+
+// UploadAPIKey records a key and returns a generated fingerprint
+func (c *Client) UploadAPIKey(ctx context.Context, publicKeyPEM string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "UploadAPIKey")
+
+	c.seq++
+	fingerprint := fmt.Sprintf("fa:ke:%02x", c.seq)
+	c.apiKeys = append(c.apiKeys, fingerprint)
+	return fingerprint, nil
+}
+
+// CheckAPIKey accepts any uploaded key
+func (c *Client) CheckAPIKey(ctx context.Context, fingerprint, privateKeyPEM string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "CheckAPIKey")
+
+	if !slices.Contains(c.apiKeys, fingerprint) {
+		return fmt.Errorf("API key %s not accepted", fingerprint)
+	}
+	return nil
+}
+
+// UseAPIKey records the key now in use
+func (c *Client) UseAPIKey(fingerprint, privateKeyPEM string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "UseAPIKey")
+	c.keyInUse = fingerprint
+}
+
+// DeleteAPIKey forgets an uploaded key
+func (c *Client) DeleteAPIKey(ctx context.Context, fingerprint string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "DeleteAPIKey")
+
+	c.apiKeys = slices.DeleteFunc(c.apiKeys, func(fp string) bool { return fp == fingerprint })
+	return nil
+}
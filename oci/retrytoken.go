@@ -0,0 +1,34 @@
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+)
+
+// attemptKey is the context key of the attempt ID set by WithAttemptID
+type attemptKey struct{}
+
+// WithAttemptID tags ctx with the ID of a logical create attempt. Create calls
+// made with it send an opc-retry-token derived from the ID, so repeating the
+// call after a timeout returns the resource created the first time instead of
+// a second one. OCI remembers retry tokens for 24 hours, so the ID must not
+// be reused by another attempt within that time.
+func WithAttemptID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, attemptKey{}, id)
+}
+
+// retryToken derives the opc-retry-token for a create call from the attempt
+// ID in ctx and parts identifying the call. It returns nil without an attempt
+// ID, leaving the token to the SDK.
+func retryToken(ctx context.Context, parts ...string) *string {
+	id, _ := ctx.Value(attemptKey{}).(string)
+	if id == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(id + "\x00" + strings.Join(parts, "\x00")))
+	return common.String(hex.EncodeToString(sum[:])) // 64 characters, OCI's limit
+}
@@ -0,0 +1,97 @@
+package oci
+
+import (
+	"context"
+	"time"
+)
+
+// IPService manages reserved public IPs of one account
+type IPService interface {
+	AccountName() string
+	Region() string
+	CreateReservedIP(ctx context.Context, displayName string) (*PublicIPInfo, error)
+	DeleteReservedIP(ctx context.Context, publicIPID string) error
+	WaitForIPReady(ctx context.Context, publicIPID string, timeout time.Duration) (*PublicIPInfo, error)
+	ListReservedIPs(ctx context.Context) ([]PublicIPInfo, error)
+	ListReservedIPsIn(ctx context.Context, compartmentID string) ([]PublicIPInfo, error)
+	ChangePublicIPCompartment(ctx context.Context, publicIPID, compartmentID string) error
+	RenameReservedIP(ctx context.Context, publicIPID, displayName string) error
+	AssignReservedIP(ctx context.Context, publicIPID, privateIPID string) error
+	SetReservedIPTags(ctx context.Context, publicIPID string, tags Tags) error
+}
+
+// ComputeService manages compute instances and custom images of one account
+type ComputeService interface {
+	LaunchInstanceWithFallback(ctx context.Context, details VPSLaunchDetails, tryFaultDomains bool) (*LaunchedInstance, error)
+	WaitForWorkRequest(ctx context.Context, workRequestID string, onProgress func(WorkRequestProgress)) error
+	ListInstances(ctx context.Context) ([]InstanceInfo, error)
+	RenameInstance(ctx context.Context, instanceID, displayName string) error
+	SetInstanceTags(ctx context.Context, instanceID string, tags Tags) error
+	CreateImageFromInstance(ctx context.Context, instance InstanceInfo, displayName string) (*ImageInfo, error)
+	WaitForImageAvailable(ctx context.Context, imageID string, timeout time.Duration) (*ImageInfo, error)
+	ListCustomImages(ctx context.Context) ([]ImageInfo, error)
+	RunInstanceCommand(ctx context.Context, instanceID, script string, timeoutSeconds int) (string, error)
+}
+
+// NetworkService inspects instance networking
+type NetworkService interface {
+	GetInstanceNetwork(ctx context.Context, instanceID string) ([]VNICInfo, error)
+}
+
+// UsageService reports cost and storage usage of one account
+type UsageService interface {
+	MonthToDateCost(ctx context.Context) (*CostSummary, error)
+	ListBootVolumes(ctx context.Context) ([]VolumeInfo, error)
+	ListBlockVolumes(ctx context.Context) ([]VolumeInfo, error)
+}
+
+// BackupService manages boot volume backups and backup policies
+type BackupService interface {
+	CreateBootVolumeBackup(ctx context.Context, bootVolumeID, displayName string) (*BackupInfo, error)
+	ListBootVolumeBackups(ctx context.Context, bootVolumeID string) ([]BackupInfo, error)
+	DeleteBootVolumeBackup(ctx context.Context, backupID string) error
+	ListBackupPolicies(ctx context.Context) ([]BackupPolicyInfo, error)
+	GetBackupPolicy(ctx context.Context, volumeID string) (*BackupPolicyInfo, error)
+	SetBackupPolicy(ctx context.Context, volumeID, policyID string) error
+}
+
+// AuditService reads the account's audit trail
+type AuditService interface {
+	ListWriteEvents(ctx context.Context, start, end time.Time) ([]AuditEventInfo, error)
+}
+
+// MonitoringService reads instance metrics from the Monitoring service
+type MonitoringService interface {
+	GetInstanceMetrics(ctx context.Context, instanceID string, window time.Duration) (*InstanceMetrics, error)
+	OutboundBytes(ctx context.Context, instanceID string, start time.Time) (float64, error)
+}
+
+// IdentityService describes the account's tenancy and user and manages the
+// user's API signing keys
+type IdentityService interface {
+	GetTenancyInfo(ctx context.Context) (*TenancyInfo, error)
+	GetUserInfo(ctx context.Context) (*UserInfo, error)
+	ListRegionSubscriptions(ctx context.Context) ([]RegionSubscription, error)
+	UploadAPIKey(ctx context.Context, publicKeyPEM string) (string, error)
+	CheckAPIKey(ctx context.Context, fingerprint, privateKeyPEM string) error
+	UseAPIKey(fingerprint, privateKeyPEM string)
+	DeleteAPIKey(ctx context.Context, fingerprint string) error
+}
+
+// Service is everything the bot needs from an OCI account. *Client is the
+// real implementation; ocifake.Client is an in-memory one for tests.
+type Service interface {
+	IPService
+	ComputeService
+	NetworkService
+	UsageService
+	BackupService
+	AuditService
+	MonitoringService
+	IdentityService
+
+	// InRegion returns the same account in another subscribed region
+	InRegion(region string) Service
+}
+
+var _ Service = (*Client)(nil)
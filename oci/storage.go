@@ -0,0 +1,80 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/core"
+)
+
+// VolumeInfo contains information about a boot or block volume
+type VolumeInfo struct {
+	ID                 string
+	DisplayName        string
+	SizeGB             int64
+	State              string
+	AvailabilityDomain string
+}
+
+// ListBootVolumes lists boot volumes in the compartment, skipping terminated ones
+func (c *Client) ListBootVolumes(ctx context.Context) ([]VolumeInfo, error) {
+	request := core.ListBootVolumesRequest{
+		CompartmentId: common.String(c.compartmentID),
+	}
+
+	response, err := c.blockClient.ListBootVolumes(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list boot volumes: %w", err)
+	}
+
+	var volumes []VolumeInfo
+	for _, vol := range response.Items {
+		if vol.LifecycleState == core.BootVolumeLifecycleStateTerminated {
+			continue
+		}
+		info := VolumeInfo{
+			ID:                 safeString(vol.Id),
+			DisplayName:        safeString(vol.DisplayName),
+			State:              string(vol.LifecycleState),
+			AvailabilityDomain: safeString(vol.AvailabilityDomain),
+		}
+		if vol.SizeInGBs != nil {
+			info.SizeGB = *vol.SizeInGBs
+		}
+		volumes = append(volumes, info)
+	}
+
+	return volumes, nil
+}
+
+// ListBlockVolumes lists block volumes in the compartment, skipping terminated ones
+func (c *Client) ListBlockVolumes(ctx context.Context) ([]VolumeInfo, error) {
+	request := core.ListVolumesRequest{
+		CompartmentId: common.String(c.compartmentID),
+	}
+
+	response, err := c.blockClient.ListVolumes(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list block volumes: %w", err)
+	}
+
+	var volumes []VolumeInfo
+	for _, vol := range response.Items {
+		if vol.LifecycleState == core.VolumeLifecycleStateTerminated {
+			continue
+		}
+		info := VolumeInfo{
+			ID:                 safeString(vol.Id),
+			DisplayName:        safeString(vol.DisplayName),
+			State:              string(vol.LifecycleState),
+			AvailabilityDomain: safeString(vol.AvailabilityDomain),
+		}
+		if vol.SizeInGBs != nil {
+			info.SizeGB = *vol.SizeInGBs
+		}
+		volumes = append(volumes, info)
+	}
+
+	return volumes, nil
+}
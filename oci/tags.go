@@ -0,0 +1,64 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/core"
+)
+
+// ManagedTag is the freeform tag the bot puts on every IP and instance it creates
+const ManagedTag = "oci-bot"
+
+// Tags holds the freeform and defined tags of a resource. Defined tags are
+// keyed by namespace, then tag name.
+type Tags struct {
+	Freeform map[string]string
+	Defined  map[string]map[string]interface{}
+}
+
+// IsManaged reports whether the resource was created by the bot
+func (t Tags) IsManaged() bool {
+	_, ok := t.Freeform[ManagedTag]
+	return ok
+}
+
+// managedTags returns the freeform tags for a newly created resource
+func (c *Client) managedTags() map[string]string {
+	return map[string]string{ManagedTag: c.accountName}
+}
+
+// SetReservedIPTags replaces the tags of a reserved public IP. A nil map
+// leaves that kind of tag unchanged.
+func (c *Client) SetReservedIPTags(ctx context.Context, publicIPID string, tags Tags) error {
+	request := core.UpdatePublicIpRequest{
+		PublicIpId: common.String(publicIPID),
+		UpdatePublicIpDetails: core.UpdatePublicIpDetails{
+			FreeformTags: tags.Freeform,
+			DefinedTags:  tags.Defined,
+		},
+	}
+
+	if _, err := c.vnClient.UpdatePublicIp(ctx, request); err != nil {
+		return fmt.Errorf("failed to update reserved IP tags: %w", err)
+	}
+	return nil
+}
+
+// SetInstanceTags replaces the tags of an instance. A nil map leaves that
+// kind of tag unchanged.
+func (c *Client) SetInstanceTags(ctx context.Context, instanceID string, tags Tags) error {
+	request := core.UpdateInstanceRequest{
+		InstanceId: common.String(instanceID),
+		UpdateInstanceDetails: core.UpdateInstanceDetails{
+			FreeformTags: tags.Freeform,
+			DefinedTags:  tags.Defined,
+		},
+	}
+
+	if _, err := c.computeClient.UpdateInstance(ctx, request); err != nil {
+		return fmt.Errorf("failed to update instance tags: %w", err)
+	}
+	return nil
+}
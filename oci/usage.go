@@ -0,0 +1,70 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/usageapi"
+)
+
+// ServiceCost is the cost of a single OCI service
+type ServiceCost struct {
+	Service string
+	Amount  float64
+}
+
+// CostSummary contains the month-to-date cost of the tenancy
+type CostSummary struct {
+	Total    float64
+	Currency string
+	Services []ServiceCost // Sorted by amount, highest first
+}
+
+// MonthToDateCost returns the tenancy cost for the current month grouped by service
+func (c *Client) MonthToDateCost(ctx context.Context) (*CostSummary, error) {
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	request := usageapi.RequestSummarizedUsagesRequest{
+		RequestSummarizedUsagesDetails: usageapi.RequestSummarizedUsagesDetails{
+			TenantId:         common.String(c.tenancyID),
+			TimeUsageStarted: &common.SDKTime{Time: start},
+			TimeUsageEnded:   &common.SDKTime{Time: end},
+			Granularity:      usageapi.RequestSummarizedUsagesDetailsGranularityMonthly,
+			QueryType:        usageapi.RequestSummarizedUsagesDetailsQueryTypeCost,
+			GroupBy:          []string{"service"},
+		},
+	}
+
+	response, err := c.usageClient.RequestSummarizedUsages(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request usage: %w", err)
+	}
+
+	summary := &CostSummary{}
+	byService := make(map[string]float64)
+	for _, item := range response.Items {
+		if item.ComputedAmount == nil {
+			continue
+		}
+		amount := float64(*item.ComputedAmount)
+		summary.Total += amount
+		byService[safeString(item.Service)] += amount
+		if summary.Currency == "" {
+			summary.Currency = safeString(item.Currency)
+		}
+	}
+
+	for service, amount := range byService {
+		summary.Services = append(summary.Services, ServiceCost{Service: service, Amount: amount})
+	}
+	sort.Slice(summary.Services, func(i, j int) bool {
+		return summary.Services[i].Amount > summary.Services[j].Amount
+	})
+
+	return summary, nil
+}
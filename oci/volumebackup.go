@@ -0,0 +1,192 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/core"
+)
+
+// BackupInfo contains information about a boot volume backup
+type BackupInfo struct {
+	ID           string
+	DisplayName  string
+	BootVolumeID string
+	State        string
+	SizeGB       int64
+	TimeCreated  time.Time
+}
+
+// BackupPolicyInfo describes a volume backup policy
+type BackupPolicyInfo struct {
+	ID          string
+	DisplayName string
+}
+
+// CreateBootVolumeBackup starts a full backup of a boot volume
+func (c *Client) CreateBootVolumeBackup(ctx context.Context, bootVolumeID, displayName string) (*BackupInfo, error) {
+	request := core.CreateBootVolumeBackupRequest{
+		CreateBootVolumeBackupDetails: core.CreateBootVolumeBackupDetails{
+			BootVolumeId: common.String(bootVolumeID),
+			DisplayName:  common.String(displayName),
+			Type:         core.CreateBootVolumeBackupDetailsTypeFull,
+		},
+	}
+
+	response, err := c.blockClient.CreateBootVolumeBackup(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create boot volume backup: %w", err)
+	}
+
+	info := toBackupInfo(response.BootVolumeBackup)
+	return &info, nil
+}
+
+// ListBootVolumeBackups lists the backups of a boot volume, skipping terminated ones
+func (c *Client) ListBootVolumeBackups(ctx context.Context, bootVolumeID string) ([]BackupInfo, error) {
+	request := core.ListBootVolumeBackupsRequest{
+		CompartmentId: common.String(c.compartmentID),
+		BootVolumeId:  common.String(bootVolumeID),
+		SortBy:        core.ListBootVolumeBackupsSortByTimecreated,
+		SortOrder:     core.ListBootVolumeBackupsSortOrderDesc,
+	}
+
+	var backups []BackupInfo
+	for {
+		response, err := c.blockClient.ListBootVolumeBackups(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list boot volume backups: %w", err)
+		}
+		for _, backup := range response.Items {
+			if backup.LifecycleState == core.BootVolumeBackupLifecycleStateTerminated ||
+				backup.LifecycleState == core.BootVolumeBackupLifecycleStateTerminating {
+				continue
+			}
+			backups = append(backups, toBackupInfo(backup))
+		}
+		if response.OpcNextPage == nil {
+			break
+		}
+		request.Page = response.OpcNextPage
+	}
+
+	return backups, nil
+}
+
+// DeleteBootVolumeBackup deletes a boot volume backup
+func (c *Client) DeleteBootVolumeBackup(ctx context.Context, backupID string) error {
+	request := core.DeleteBootVolumeBackupRequest{
+		BootVolumeBackupId: common.String(backupID),
+	}
+
+	if _, err := c.blockClient.DeleteBootVolumeBackup(ctx, request); err != nil {
+		return fmt.Errorf("failed to delete boot volume backup: %w", err)
+	}
+	return nil
+}
+
+// ListBackupPolicies lists the Oracle-defined policies (gold/silver/bronze)
+// followed by user-defined policies in the compartment
+func (c *Client) ListBackupPolicies(ctx context.Context) ([]BackupPolicyInfo, error) {
+	var policies []BackupPolicyInfo
+	for _, compartment := range []*string{nil, common.String(c.compartmentID)} {
+		response, err := c.blockClient.ListVolumeBackupPolicies(ctx, core.ListVolumeBackupPoliciesRequest{
+			CompartmentId: compartment,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list backup policies: %w", err)
+		}
+		for _, policy := range response.Items {
+			policies = append(policies, BackupPolicyInfo{
+				ID:          safeString(policy.Id),
+				DisplayName: safeString(policy.DisplayName),
+			})
+		}
+	}
+
+	return policies, nil
+}
+
+// GetBackupPolicy returns the policy assigned to a volume, or nil when none is assigned
+func (c *Client) GetBackupPolicy(ctx context.Context, volumeID string) (*BackupPolicyInfo, error) {
+	assignment, err := c.backupPolicyAssignment(ctx, volumeID)
+	if err != nil || assignment == nil {
+		return nil, err
+	}
+
+	response, err := c.blockClient.GetVolumeBackupPolicy(ctx, core.GetVolumeBackupPolicyRequest{
+		PolicyId: assignment.PolicyId,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup policy: %w", err)
+	}
+
+	return &BackupPolicyInfo{
+		ID:          safeString(response.Id),
+		DisplayName: safeString(response.DisplayName),
+	}, nil
+}
+
+// SetBackupPolicy assigns a policy to a volume, replacing any existing assignment.
+// An empty policyID only removes the current assignment.
+func (c *Client) SetBackupPolicy(ctx context.Context, volumeID, policyID string) error {
+	assignment, err := c.backupPolicyAssignment(ctx, volumeID)
+	if err != nil {
+		return err
+	}
+
+	if assignment != nil {
+		if _, err := c.blockClient.DeleteVolumeBackupPolicyAssignment(ctx, core.DeleteVolumeBackupPolicyAssignmentRequest{
+			PolicyAssignmentId: assignment.Id,
+		}); err != nil {
+			return fmt.Errorf("failed to remove backup policy: %w", err)
+		}
+	}
+
+	if policyID == "" {
+		return nil
+	}
+
+	_, err = c.blockClient.CreateVolumeBackupPolicyAssignment(ctx, core.CreateVolumeBackupPolicyAssignmentRequest{
+		CreateVolumeBackupPolicyAssignmentDetails: core.CreateVolumeBackupPolicyAssignmentDetails{
+			AssetId:  common.String(volumeID),
+			PolicyId: common.String(policyID),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to assign backup policy: %w", err)
+	}
+	return nil
+}
+
+// backupPolicyAssignment returns the volume's policy assignment, or nil when none exists
+func (c *Client) backupPolicyAssignment(ctx context.Context, volumeID string) (*core.VolumeBackupPolicyAssignment, error) {
+	response, err := c.blockClient.GetVolumeBackupPolicyAssetAssignment(ctx, core.GetVolumeBackupPolicyAssetAssignmentRequest{
+		AssetId: common.String(volumeID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup policy assignment: %w", err)
+	}
+	if len(response.Items) == 0 {
+		return nil, nil
+	}
+	return &response.Items[0], nil
+}
+
+func toBackupInfo(backup core.BootVolumeBackup) BackupInfo {
+	info := BackupInfo{
+		ID:           safeString(backup.Id),
+		DisplayName:  safeString(backup.DisplayName),
+		BootVolumeID: safeString(backup.BootVolumeId),
+		State:        string(backup.LifecycleState),
+	}
+	if backup.SizeInGBs != nil {
+		info.SizeGB = *backup.SizeInGBs
+	}
+	if backup.TimeCreated != nil {
+		info.TimeCreated = backup.TimeCreated.Time
+	}
+	return info
+}
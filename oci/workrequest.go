@@ -0,0 +1,82 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/workrequests"
+)
+
+// workRequestPollInterval is how often WaitForWorkRequest asks for progress
+const workRequestPollInterval = 5 * time.Second
+
+// WorkRequestProgress is the state of a work request, as reported while
+// waiting for it
+type WorkRequestProgress struct {
+	ID        string
+	Operation string  // e.g. LaunchInstance, CreateImage
+	Status    string  // ACCEPTED, IN_PROGRESS, SUCCEEDED, FAILED, ...
+	Percent   float32 // 0-100
+}
+
+// WaitForWorkRequest polls a work request until it finishes, calling
+// onProgress whenever its status or percentage changes. It returns nil once
+// the work request succeeded and the errors OCI recorded when it failed or
+// was cancelled.
+func (c *Client) WaitForWorkRequest(ctx context.Context, workRequestID string, onProgress func(WorkRequestProgress)) error {
+	var last WorkRequestProgress
+	for {
+		response, err := c.workClient.GetWorkRequest(ctx, workrequests.GetWorkRequestRequest{
+			WorkRequestId: common.String(workRequestID),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get work request: %w", err)
+		}
+
+		progress := WorkRequestProgress{
+			ID:        workRequestID,
+			Operation: safeString(response.WorkRequest.OperationType),
+			Status:    string(response.WorkRequest.Status),
+		}
+		if response.WorkRequest.PercentComplete != nil {
+			progress.Percent = *response.WorkRequest.PercentComplete
+		}
+		if progress != last && onProgress != nil {
+			onProgress(progress)
+		}
+		last = progress
+
+		switch response.WorkRequest.Status {
+		case workrequests.WorkRequestStatusSucceeded:
+			return nil
+		case workrequests.WorkRequestStatusFailed, workrequests.WorkRequestStatusCanceled:
+			return c.workRequestError(ctx, workRequestID, progress)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(workRequestPollInterval):
+		}
+	}
+}
+
+// workRequestError builds the error of a failed work request from the errors
+// OCI recorded for it
+func (c *Client) workRequestError(ctx context.Context, workRequestID string, progress WorkRequestProgress) error {
+	response, err := c.workClient.ListWorkRequestErrors(ctx, workrequests.ListWorkRequestErrorsRequest{
+		WorkRequestId: common.String(workRequestID),
+	})
+	if err != nil || len(response.Items) == 0 {
+		return fmt.Errorf("%s %s", progress.Operation, strings.ToLower(progress.Status))
+	}
+
+	messages := make([]string, 0, len(response.Items))
+	for _, item := range response.Items {
+		messages = append(messages, fmt.Sprintf("%s: %s", safeString(item.Code), safeString(item.Message)))
+	}
+	return fmt.Errorf("%s %s: %s", progress.Operation, strings.ToLower(progress.Status), strings.Join(messages, "; "))
+}
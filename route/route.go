@@ -0,0 +1,165 @@
+// Package route classifies the path from mainland China to an IP address by
+// the backbone networks it crosses: CN2, 163, CMI, China Unicom 9929/4837.
+// The path is traced by Globalping probes in China; the backbone is told by
+// the hops' ASNs and well-known address ranges.
+package route
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"oci-bot/globalping"
+)
+
+const mtrPackets = 2
+
+// Route types, best first
+const (
+	CN2GIA  = "CN2 GIA" // Telecom premium: CN2 all the way
+	CN2GT   = "CN2 GT"  // Telecom CN2 abroad, 163 inside China
+	CMI     = "CMI"     // China Mobile International
+	CU9929  = "9929"    // Unicom premium (CUII)
+	CUG     = "CUG"     // Unicom Global, AS10099
+	CU4837  = "4837"    // Unicom public backbone
+	CT163   = "163"     // Telecom public backbone
+	Unknown = "unknown" // None of the above seen
+)
+
+// Types lists the route types, best first
+var Types = []string{CN2GIA, CN2GT, CMI, CU9929, CUG, CU4837, CT163, Unknown}
+
+// Backbone ranges. Only backbone hops count: the probe's own access network
+// is AS4134 or AS9808 whatever the route, so those ASNs say nothing.
+var (
+	cn2Range  = mustCIDR("59.43.0.0/16")
+	t163Range = mustCIDR("202.97.0.0/16")
+	cmiRanges = []*net.IPNet{mustCIDR("223.118.0.0/15"), mustCIDR("223.120.0.0/15")}
+)
+
+// Result is the route seen from one location
+type Result struct {
+	Location string // As configured, e.g. "China+AS4134"
+	Probe    string // Where the Globalping probe actually was
+	Type     string // One of Types when Err is nil
+	ASNs     []int  // Networks crossed, in order, without repeats
+	Err      error
+}
+
+// Detect traces ip from every location concurrently. Results are in the
+// order of locations.
+func Detect(ctx context.Context, ip string, locations []string, token string) []Result {
+	results := make([]Result, len(locations))
+	var wg sync.WaitGroup
+	for i, location := range locations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = trace(ctx, ip, location, token)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// trace runs an mtr from one Globalping probe in location and classifies it
+func trace(ctx context.Context, ip, location, token string) Result {
+	result := Result{Location: location}
+	m, err := globalping.Client{Token: token}.Measure(ctx, "mtr", ip, location, map[string]any{"packets": mtrPackets})
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Probe = m.Probe.String()
+
+	var mtr struct {
+		Hops []struct {
+			Address string `json:"resolvedAddress"`
+			ASN     []int  `json:"asn"`
+		} `json:"hops"`
+	}
+	if err := json.Unmarshal(m.Result, &mtr); err != nil {
+		result.Err = err
+		return result
+	}
+	var hops []hop
+	for _, h := range mtr.Hops {
+		hops = append(hops, hop{IP: net.ParseIP(h.Address), ASNs: h.ASN})
+		for _, asn := range h.ASN {
+			if len(result.ASNs) == 0 || result.ASNs[len(result.ASNs)-1] != asn {
+				result.ASNs = append(result.ASNs, asn)
+			}
+		}
+	}
+	result.Type = classify(hops)
+	return result
+}
+
+// hop is a router on the path
+type hop struct {
+	IP   net.IP // nil when it didn't answer
+	ASNs []int
+}
+
+// classify names the route the hops take. Telecom's 163 backbone is told by
+// its addresses alone, as AS4134 is also every Telecom access network.
+func classify(hops []hop) string {
+	var cn2, t163, cmi, cu9929, cug, cu4837 bool
+	for _, h := range hops {
+		in := func(asns ...int) bool {
+			for _, asn := range asns {
+				if slices.Contains(h.ASNs, asn) {
+					return true
+				}
+			}
+			return false
+		}
+		switch {
+		case in(4809) || h.IP != nil && cn2Range.Contains(h.IP):
+			cn2 = true
+		case h.IP != nil && t163Range.Contains(h.IP):
+			t163 = true
+		case in(58453) || h.IP != nil && slices.ContainsFunc(cmiRanges, func(n *net.IPNet) bool { return n.Contains(h.IP) }):
+			cmi = true
+		case in(9929):
+			cu9929 = true
+		case in(10099):
+			cug = true
+		case in(4837):
+			cu4837 = true
+		}
+	}
+	switch {
+	case cn2 && !t163:
+		return CN2GIA
+	case cn2:
+		return CN2GT
+	case cmi:
+		return CMI
+	case cu9929:
+		return CU9929
+	case cug:
+		return CUG
+	case cu4837:
+		return CU4837
+	case t163:
+		return CT163
+	}
+	return Unknown
+}
+
+// Valid reports whether name is one of Types, ignoring case
+func Valid(name string) bool {
+	return slices.ContainsFunc(Types, func(t string) bool { return strings.EqualFold(t, name) })
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
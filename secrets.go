@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"oci-bot/config"
+
+	"golang.org/x/term"
+)
+
+// loadPassphrase gives config the passphrase of an encrypted config file,
+// from OCI_BOT_PASSPHRASE or typed in at the terminal
+func loadPassphrase(confFile string) error {
+	encrypted, err := config.IsEncryptedFile(confFile)
+	if err != nil || !encrypted {
+		return nil // Load reports a missing file
+	}
+	pass, err := readPassphrase(false)
+	if err != nil {
+		return err
+	}
+	config.SetPassphrase(pass)
+	return nil
+}
+
+// readPassphrase takes the passphrase from the environment, removing it so
+// the browser and other child processes don't inherit it, or prompts for it.
+// confirm asks twice, for a new passphrase.
+func readPassphrase(confirm bool) (string, error) {
+	if pass := os.Getenv(config.PassphraseEnv); pass != "" {
+		os.Unsetenv(config.PassphraseEnv)
+		return pass, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("config is encrypted: set %s or start the bot in a terminal", config.PassphraseEnv)
+	}
+
+	pass, err := prompt("Config passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if pass == "" {
+		return "", errors.New("empty passphrase")
+	}
+	if confirm {
+		again, err := prompt("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != pass {
+			return "", errors.New("passphrases don't match")
+		}
+	}
+	return pass, nil
+}
+
+func prompt(text string) (string, error) {
+	fmt.Fprint(os.Stderr, text)
+	pass, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(pass), err
+}
+
+// convertSecrets encrypts or decrypts the config file and the API keys it
+// names in place, for -encrypt and -decrypt. vps_ssh_private_key is left
+// alone: it is usually the user's own SSH key, used by other programs too.
+func convertSecrets(confFile string, encrypt bool) error {
+	encrypted, err := config.IsEncryptedFile(confFile)
+	if err != nil {
+		return err
+	}
+	if encrypted == encrypt {
+		if encrypt {
+			return fmt.Errorf("%s is already encrypted", confFile)
+		}
+		return fmt.Errorf("%s is not encrypted", confFile)
+	}
+
+	pass, err := readPassphrase(encrypt)
+	if err != nil {
+		return err
+	}
+	config.SetPassphrase(pass)
+	cfg, err := config.Load(confFile)
+	if err != nil {
+		return err
+	}
+
+	files := []string{confFile}
+	for _, acc := range cfg.Accounts {
+		files = append(files, acc.KeyFile)
+	}
+	convert, done := config.DecryptFile, "Decrypted"
+	if encrypt {
+		convert, done = config.EncryptFile, "Encrypted"
+	}
+	for _, file := range files {
+		changed, err := convert(file, pass)
+		if err != nil {
+			return err
+		}
+		if changed {
+			logger.Infof("%s %s", done, file)
+		}
+	}
+	return nil
+}
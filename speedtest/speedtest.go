@@ -0,0 +1,151 @@
+// Package speedtest measures the TCP throughput to an IP address, either by
+// downloading a file it serves over HTTP or with an iperf3 client against an
+// iperf3 server listening on it.
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDuration is how long a test runs when not told otherwise
+const DefaultDuration = 10 * time.Second
+
+// Result is the outcome of a test
+type Result struct {
+	Target string        // The test URL with {ip} filled in
+	Mbps   float64       // Megabits per second received
+	Bytes  int64         // Bytes received
+	Time   time.Duration // How long the transfer ran
+}
+
+// String describes the result, e.g. "312.5 Mbps"
+func (r Result) String() string {
+	return fmt.Sprintf("%.1f Mbps", r.Mbps)
+}
+
+// Valid checks a test URL: http(s)://... to download, or iperf3://host[:port]
+func Valid(target string) error {
+	u, err := url.Parse(strings.ReplaceAll(target, "{ip}", "192.0.2.1"))
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "iperf3":
+		if u.Hostname() == "" {
+			return errors.New("iperf3 URL needs a host, e.g. iperf3://{ip}:5201")
+		}
+	default:
+		return fmt.Errorf("scheme %q, want http, https or iperf3", u.Scheme)
+	}
+	if !strings.Contains(target, "{ip}") {
+		return errors.New("URL has no {ip}")
+	}
+	return nil
+}
+
+// Run tests the throughput to ip for up to duration. target is a URL with
+// {ip}: an http(s) URL is downloaded until done or duration runs out, an
+// iperf3://{ip}:port URL runs iperf3 in reverse mode so the server sends.
+func Run(ctx context.Context, target, ip string, duration time.Duration) (Result, error) {
+	target = strings.ReplaceAll(target, "{ip}", ip)
+	u, err := url.Parse(target)
+	if err != nil {
+		return Result{Target: target}, err
+	}
+	if u.Scheme == "iperf3" {
+		return iperf3(ctx, u, duration)
+	}
+	return download(ctx, target, duration)
+}
+
+// download fetches target and counts the bytes that arrive within duration.
+// Running out of time is not an error, only the rate matters.
+func download(ctx context.Context, target string, duration time.Duration) (Result, error) {
+	result := Result{Target: target}
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return result, err
+	}
+	// A fresh connection each time, so a kept-alive one to a previous
+	// candidate never carries the test
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true, Proxy: http.ProxyFromEnvironment}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return result, fmt.Errorf("download answered %s", resp.Status)
+	}
+
+	start := time.Now()
+	result.Bytes, err = io.Copy(io.Discard, resp.Body)
+	result.Time = time.Since(start)
+	if err != nil && ctx.Err() != context.DeadlineExceeded {
+		return result, err
+	}
+	if result.Bytes == 0 || result.Time <= 0 {
+		return result, errors.New("nothing downloaded")
+	}
+	result.Mbps = float64(result.Bytes) * 8 / result.Time.Seconds() / 1e6
+	return result, nil
+}
+
+// iperf3 runs the iperf3 client against the server in u
+func iperf3(ctx context.Context, u *url.URL, duration time.Duration) (Result, error) {
+	result := Result{Target: u.String()}
+	port := u.Port()
+	if port == "" {
+		port = "5201"
+	}
+	seconds := max(int(duration.Seconds()), 1)
+	// iperf3 gives up on its own after its connect timeout; the extra time
+	// covers setting up and the final exchange
+	ctx, cancel := context.WithTimeout(ctx, duration+30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "iperf3", "--client", u.Hostname(), "--port", port,
+		"--reverse", "--json", "--time", strconv.Itoa(seconds), "--connect-timeout", "10000")
+	out, err := cmd.Output()
+
+	var report struct {
+		Error string `json:"error"`
+		End   struct {
+			SumReceived struct {
+				Seconds float64 `json:"seconds"`
+				Bytes   int64   `json:"bytes"`
+				Bps     float64 `json:"bits_per_second"`
+			} `json:"sum_received"`
+		} `json:"end"`
+	}
+	// iperf3 reports its errors in the JSON too, with a failing exit code
+	if jsonErr := json.Unmarshal(out, &report); jsonErr != nil {
+		if err != nil {
+			return result, fmt.Errorf("iperf3: %w", err)
+		}
+		return result, fmt.Errorf("iperf3 output: %w", jsonErr)
+	}
+	if report.Error != "" {
+		return result, errors.New("iperf3: " + report.Error)
+	}
+	if err != nil {
+		return result, fmt.Errorf("iperf3: %w", err)
+	}
+	sum := report.End.SumReceived
+	result.Bytes = sum.Bytes
+	result.Time = time.Duration(sum.Seconds * float64(time.Second))
+	result.Mbps = sum.Bps / 1e6
+	return result, nil
+}
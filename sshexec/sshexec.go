@@ -0,0 +1,144 @@
+// Package sshexec runs shell commands on instances over SSH, authenticating
+// with the private key matching the vps_ssh_keys the instances were launched
+// with.
+package sshexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"oci-bot/config"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	dialTimeout = 10 * time.Second
+	// MaxOutput is how much of a command's output is kept; the rest is dropped
+	MaxOutput = 64 << 10
+)
+
+// Result is the outcome of a command that ran
+type Result struct {
+	Output    string // stdout and stderr interleaved, at most MaxOutput bytes
+	Truncated bool
+	ExitCode  int // -1 when the command was killed by a signal
+}
+
+// LoadSigner reads an OpenSSH or PEM private key file, decrypting it with
+// passphrase when it is encrypted
+func LoadSigner(keyFile, passphrase string) (ssh.Signer, error) {
+	content, err := config.ReadSecretFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(content)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("SSH key %s is encrypted, set vps_ssh_key_passphrase", keyFile)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(content, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH key %s: %w", keyFile, err)
+	}
+	return signer, nil
+}
+
+// Run logs in to host:22 as user and runs command through the login shell.
+// A command exiting non-zero is not an error: its status is in the Result.
+// hostKey decides whether to trust the server; ctx ending closes the
+// connection, killing the command.
+func Run(ctx context.Context, host, user string, signer ssh.Signer, hostKey ssh.HostKeyCallback, command string) (*Result, error) {
+	return run(ctx, host, user, signer, hostKey, command, nil)
+}
+
+// RunScript is Run for a shell script, fed to bash as root on stdin so it
+// needs no upload and doesn't show up in the process list
+func RunScript(ctx context.Context, host, user string, signer ssh.Signer, hostKey ssh.HostKeyCallback, script string) (*Result, error) {
+	return run(ctx, host, user, signer, hostKey, "sudo bash -s", strings.NewReader(script))
+}
+
+func run(ctx context.Context, host, user string, signer ssh.Signer, hostKey ssh.HostKeyCallback, command string, stdin io.Reader) (*Result, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, "22"))
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, host, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKey,
+		Timeout:         dialTimeout,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	out := &limitedBuffer{limit: MaxOutput}
+	session.Stdout = out
+	session.Stderr = out
+	session.Stdin = stdin
+	err = session.Run(command)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	result := &Result{Output: string(out.buf), Truncated: out.truncated}
+	var exit *ssh.ExitError
+	var missing *ssh.ExitMissingError
+	switch {
+	case errors.As(err, &exit):
+		result.ExitCode = exit.ExitStatus()
+		if exit.Signal() != "" {
+			result.ExitCode = -1
+		}
+	case errors.As(err, &missing):
+		result.ExitCode = -1
+	case err != nil:
+		return nil, err
+	}
+	return result, nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it. stdout and stderr
+// are copied from separate goroutines, hence the lock.
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (w *limitedBuffer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if room := w.limit - len(w.buf); len(p) > room {
+		w.buf = append(w.buf, p[:max(room, 0)]...)
+		w.truncated = true
+	} else {
+		w.buf = append(w.buf, p...)
+	}
+	return len(p), nil
+}
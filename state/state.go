@@ -0,0 +1,99 @@
+// Package state persists small pieces of bot state (pinned IPs, watchlists,
+// ...) across restarts in a single JSON file.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store is a JSON file of named sections. Each Set rewrites the whole file
+// atomically, which is fine for the few kilobytes the bot keeps.
+type Store struct {
+	path string // Empty keeps state in memory only
+	mu   sync.Mutex
+	data map[string]json.RawMessage
+}
+
+// Open loads the store at path, starting empty when the file doesn't exist.
+// An empty path returns an in-memory store.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, data: make(map[string]json.RawMessage)}
+	if path == "" {
+		return s, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(content, &s.data); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get decodes the named section into v. A missing section leaves v untouched.
+func (s *Store) Get(key string, v any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, ok := s.data[key]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("failed to decode state %q: %w", key, err)
+	}
+	return nil
+}
+
+// Set stores v as the named section and writes the file
+func (s *Store) Set(key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode state %q: %w", key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = raw
+	return s.save()
+}
+
+// save writes the file via a temp file and rename so a crash never leaves it half-written
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".state-*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}